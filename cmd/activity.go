@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/schovi/shelli/internal/wait"
+	"github.com/spf13/cobra"
+)
+
+var (
+	activityIdleFlag    time.Duration
+	activityWaitFlag    bool
+	activityTimeoutFlag int
+	activityJsonFlag    bool
+)
+
+func init() {
+	activityCmd.Flags().DurationVar(&activityIdleFlag, "idle", daemon.ActivityDefaultIdle, "Silence after which the session counts as idle")
+	activityCmd.Flags().BoolVar(&activityWaitFlag, "wait", false, "Block until the session is idle")
+	activityCmd.Flags().IntVar(&activityTimeoutFlag, "timeout", 60, "Max wait time in seconds for --wait")
+	activityCmd.Flags().BoolVar(&activityJsonFlag, "json", false, "Output as JSON")
+}
+
+var activityCmd = &cobra.Command{
+	Use:   "activity <name>",
+	Short: "Show whether a session is producing output",
+	Long: `Show when a session last produced output, its output rate over the last
+second, 10 seconds and minute, and whether it is idle: silent for --idle
+(default 2s) or stopped.
+
+With --wait, block until the session is idle, e.g. until a build, download
+or REPL startup has gone quiet. Unlike a settle wait, this needs no read of
+the output. On timeout the command exits 124.
+
+Examples:
+  shelli activity build
+  shelli activity build --wait --idle 5s --timeout 600
+  shelli activity server --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runActivity,
+}
+
+func runActivity(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if activityIdleFlag < 0 {
+		return fmt.Errorf("--idle must not be negative")
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	var result *daemon.ActivityResult
+	var err error
+	if activityWaitFlag {
+		result, err = client.WaitIdle(name, activityIdleFlag, time.Duration(activityTimeoutFlag)*time.Second)
+		if errors.Is(err, wait.ErrTimeout) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(waitExitTimeoutCode)
+		}
+	} else {
+		result, err = client.Activity(name, activityIdleFlag)
+	}
+	if err != nil {
+		return err
+	}
+
+	if activityJsonFlag {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	var f fields
+	f.add("Session", "%s", result.Name)
+	f.add("State", "%s", paintState(result.State))
+	if result.Idle {
+		f.add("Activity", "idle (threshold %s)", formatDuration(result.IdleThreshold))
+	} else {
+		f.add("Activity", "active (threshold %s)", formatDuration(result.IdleThreshold))
+	}
+	if result.LastOutputAt != "" {
+		f.add("Output", "%s ago", formatDuration(result.IdleFor))
+	}
+	for _, r := range result.Rates {
+		window := fmt.Sprintf("%gs", r.Window)
+		if r.Window >= 60 {
+			window = fmt.Sprintf("%gm", r.Window/60)
+		}
+		f.add("Rate "+window, "%s/s", formatBytes(int64(r.BytesPerSec)))
+	}
+	f.add("Total", "%s", formatBytes(result.TotalBytes))
+	f.print(os.Stdout)
+	return nil
+}
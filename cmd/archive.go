@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/schovi/shelli/internal/vterm"
+	"github.com/spf13/cobra"
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Read or search archived session output",
+	Long: `Read or search the output of sessions kept in an archive directory.
+
+An archive is a directory of session files as a daemon's data dir holds them
+(<name>.out, <name>.meta): the data dir of a daemon that is gone, or a
+copy of one kept before stopped sessions are cleaned up. Archived sessions
+are queried in place, without a daemon and without becoming sessions again;
+nothing in the directory is written. --dir defaults to the daemon's data dir.`,
+}
+
+var archiveReadCmd = &cobra.Command{
+	Use:   "read <name>",
+	Short: "Print an archived session's output",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runArchiveRead,
+}
+
+var archiveSearchCmd = &cobra.Command{
+	Use:   "search <name> <pattern>",
+	Short: "Search an archived session's output",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runArchiveSearch,
+}
+
+var (
+	archiveDirFlag        string
+	archiveJsonFlag       bool
+	archiveStripAnsiFlag  bool
+	archiveHeadFlag       int
+	archiveTailFlag       int
+	archiveBeforeFlag     int
+	archiveAfterFlag      int
+	archiveIgnoreCaseFlag bool
+)
+
+func init() {
+	for _, c := range []*cobra.Command{archiveReadCmd, archiveSearchCmd} {
+		c.Flags().StringVar(&archiveDirFlag, "dir", "", "Archive directory (default: /tmp/shelli-{uid}/data)")
+		c.Flags().BoolVar(&archiveJsonFlag, "json", false, "Output as JSON")
+		c.Flags().BoolVar(&archiveStripAnsiFlag, "strip-ansi", false, "Strip ANSI escape codes")
+		archiveCmd.AddCommand(c)
+	}
+	archiveReadCmd.Flags().IntVar(&archiveHeadFlag, "head", 0, "Return first N lines")
+	archiveReadCmd.Flags().IntVar(&archiveTailFlag, "tail", 0, "Return last N lines")
+	archiveSearchCmd.Flags().IntVar(&archiveBeforeFlag, "before", 0, "Lines of context before each match")
+	archiveSearchCmd.Flags().IntVar(&archiveAfterFlag, "after", 0, "Lines of context after each match")
+	archiveSearchCmd.Flags().BoolVar(&archiveIgnoreCaseFlag, "ignore-case", false, "Case-insensitive search")
+}
+
+func archiveDir() (string, error) {
+	if archiveDirFlag != "" {
+		return archiveDirFlag, nil
+	}
+	dir, err := daemon.DefaultDataDir()
+	if err != nil {
+		return "", fmt.Errorf("get data dir: %w", err)
+	}
+	return dir, nil
+}
+
+func runArchiveRead(cmd *cobra.Command, args []string) error {
+	if archiveHeadFlag > 0 && archiveTailFlag > 0 {
+		return fmt.Errorf("--head and --tail are mutually exclusive")
+	}
+	dir, err := archiveDir()
+	if err != nil {
+		return err
+	}
+
+	result, err := daemon.ArchiveRead(dir, args[0], archiveHeadFlag, archiveTailFlag)
+	if err != nil {
+		return err
+	}
+	output := result.Output
+	if archiveStripAnsiFlag {
+		output = vterm.StripDefault(output)
+	}
+
+	if archiveJsonFlag {
+		out := map[string]interface{}{
+			"output": output,
+			"size":   result.Position,
+			"state":  result.State,
+		}
+		if result.LongLinesTruncated > 0 {
+			out["long_lines_truncated"] = result.LongLinesTruncated
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Print(output)
+	return nil
+}
+
+func runArchiveSearch(cmd *cobra.Command, args []string) error {
+	if archiveBeforeFlag < 0 || archiveAfterFlag < 0 {
+		return fmt.Errorf("--before and --after must be non-negative")
+	}
+	dir, err := archiveDir()
+	if err != nil {
+		return err
+	}
+
+	resp, err := daemon.ArchiveSearch(dir, daemon.SearchRequest{
+		Name:       args[0],
+		Pattern:    args[1],
+		Before:     archiveBeforeFlag,
+		After:      archiveAfterFlag,
+		IgnoreCase: archiveIgnoreCaseFlag,
+		StripANSI:  archiveStripAnsiFlag,
+	})
+	if err != nil {
+		return err
+	}
+
+	if archiveJsonFlag {
+		data, err := json.MarshalIndent(resp, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printSearchMatches(resp, false)
+	return nil
+}
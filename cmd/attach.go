@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+// detachKeysEnv overrides the default detach sequence.
+const detachKeysEnv = "SHELLI_DETACH_KEYS"
+
+const defaultDetachKeys = "ctrl-b,d"
+
+var (
+	attachDetachKeysFlag string
+	attachResizeFlag     bool
+)
+
+func init() {
+	attachCmd.Flags().StringVar(&attachDetachKeysFlag, "detach-keys", "", "Key sequence that detaches, e.g. ctrl-b,d or ctrl-] (default $"+detachKeysEnv+" or "+defaultDetachKeys+")")
+	attachCmd.Flags().BoolVar(&attachResizeFlag, "resize", false, "Resize the session to this terminal, and follow its size while attached")
+}
+
+var attachCmd = &cobra.Command{
+	Use:   "attach <name>",
+	Short: "Attach this terminal to a session",
+	Long: `Attach this terminal to a session, like tmux attach: the terminal is put in
+raw mode, keystrokes go to the session's PTY and its output is shown as it
+arrives. The session's current screen is drawn first.
+
+Detach with Ctrl-B d (press Ctrl-B, then d). --detach-keys or $` + detachKeysEnv + `
+picks another sequence: comma-separated keys such as "ctrl-a,q" or a single
+"ctrl-]". In a sequence of several keys, pressing the first key twice sends
+it to the session once.
+
+Attaching consumes nothing: the read position an agent uses stays where it
+was, so a human can poke at the session the agent is driving. The session
+keeps its size unless --resize is given.
+
+Examples:
+  shelli attach myshell
+  shelli attach pyrepl --detach-keys ctrl-]
+  shelli attach vim --resize`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAttach,
+}
+
+func runAttach(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	spec := attachDetachKeysFlag
+	if spec == "" {
+		spec = os.Getenv(detachKeysEnv)
+	}
+	if spec == "" {
+		spec = defaultDetachKeys
+	}
+	detachKeys, err := parseDetachKeys(spec)
+	if err != nil {
+		return err
+	}
+
+	stdin := os.Stdin.Fd()
+	if !term.IsTerminal(stdin) {
+		return fmt.Errorf("attach needs a terminal on stdin")
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	if attachResizeFlag {
+		if err := resizeToCaller(client, name); err != nil {
+			return err
+		}
+	}
+
+	offset, err := client.Size(name)
+	if err != nil {
+		return err
+	}
+	screen, err := client.Screen(name, true)
+	if err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return fmt.Errorf("raw mode: %w", err)
+	}
+	defer term.Restore(stdin, state) //nolint:errcheck
+
+	drawScreen(screen)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if attachResizeFlag {
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		defer signal.Stop(winch)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-winch:
+					resizeToCaller(client, name) //nolint:errcheck // keep the old size
+				}
+			}
+		}()
+	}
+
+	ended := make(chan error, 1)
+	go func() {
+		ended <- client.Peek(ctx, name, int64(offset), func(chunk daemon.StreamChunk) error {
+			os.Stdout.WriteString(chunk.Output) //nolint:errcheck
+			return nil
+		})
+	}()
+
+	detached := make(chan error, 1)
+	go func() { detached <- forwardInput(client, name, detachKeys) }()
+
+	var end string
+	select {
+	case err = <-ended:
+		end = fmt.Sprintf("session %q stopped", name)
+	case err = <-detached:
+		end = fmt.Sprintf("detached from %q", name)
+	}
+	// Undo what the session's output may have left set: colors, a hidden
+	// cursor.
+	os.Stdout.WriteString("\x1b[0m\x1b[?25h") //nolint:errcheck
+	term.Restore(stdin, state)                //nolint:errcheck
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\r\n[%s]\n", end)
+	return nil
+}
+
+// forwardInput sends stdin to the session until the detach sequence is
+// typed. Keys that start the sequence are held back until it is clear
+// whether they complete it.
+func forwardInput(client *daemon.Client, name string, detachKeys []byte) error {
+	buf := make([]byte, 1024)
+	matched := 0
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return fmt.Errorf("read input: %w", err)
+		}
+		var out []byte
+		for _, b := range buf[:n] {
+			switch {
+			case b == detachKeys[matched]:
+				matched++
+				if matched == len(detachKeys) {
+					if len(out) > 0 {
+						return client.Send(name, string(out), false)
+					}
+					return nil
+				}
+				continue
+			case matched == 1 && b == detachKeys[0]:
+				// The first key twice sends it once.
+				out = append(out, b)
+				matched = 0
+				continue
+			case matched > 0:
+				out = append(out, detachKeys[:matched]...)
+				matched = 0
+			}
+			out = append(out, b)
+		}
+		if len(out) > 0 {
+			if err := client.Send(name, string(out), false); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseDetachKeys reads a comma-separated key sequence: "ctrl-<key>" for a
+// control key, or a single character.
+func parseDetachKeys(spec string) ([]byte, error) {
+	var keys []byte
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		lower := strings.ToLower(key)
+		switch {
+		case strings.HasPrefix(lower, "ctrl-") && len(key) == len("ctrl-")+1:
+			c := key[len(key)-1]
+			if c >= 'a' && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			if c < '@' || c > '_' {
+				return nil, fmt.Errorf("invalid detach key %q: ctrl- takes a letter or one of @[\\]^_", key)
+			}
+			keys = append(keys, c-'@')
+		case len(key) == 1:
+			keys = append(keys, key[0])
+		default:
+			return nil, fmt.Errorf("invalid detach key %q: want ctrl-<key> or a single character", key)
+		}
+	}
+	return keys, nil
+}
+
+// drawScreen shows the session's screen on this terminal, cursor included.
+func drawScreen(screen *daemon.ScreenState) {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	b.WriteString(strings.Join(screen.Rows, "\r\n"))
+	fmt.Fprintf(&b, "\x1b[%d;%dH", screen.Cursor.Row+1, screen.Cursor.Col+1)
+	if !screen.Cursor.Visible {
+		b.WriteString("\x1b[?25l")
+	}
+	os.Stdout.WriteString(b.String()) //nolint:errcheck
+}
+
+// resizeToCaller sets the session's size to this terminal's.
+func resizeToCaller(client *daemon.Client, name string) error {
+	size, err := callerTerminalSize()
+	if err != nil {
+		return err
+	}
+	return client.Resize(name, size.Cols, size.Rows)
+}
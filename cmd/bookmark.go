@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bookmarkOnFlag     string
+	bookmarkAsFlag     string
+	bookmarkOffFlag    string
+	bookmarkOffAllFlag bool
+	bookmarkAddFlag    string
+	bookmarkDeleteFlag string
+	bookmarkJsonFlag   bool
+)
+
+func init() {
+	bookmarkCmd.Flags().StringVar(&bookmarkOnFlag, "on", "", "Bookmark every output line matching this regex")
+	bookmarkCmd.Flags().StringVar(&bookmarkAsFlag, "as", "", "With --on: name the bookmarks NAME-1, NAME-2, ... (default: match)")
+	bookmarkCmd.Flags().StringVar(&bookmarkOffFlag, "off", "", "Stop the watch named NAME")
+	bookmarkCmd.Flags().BoolVar(&bookmarkOffAllFlag, "off-all", false, "Stop all watches")
+	bookmarkCmd.Flags().StringVar(&bookmarkAddFlag, "add", "", "Bookmark the end of the output as NAME")
+	bookmarkCmd.Flags().StringVar(&bookmarkDeleteFlag, "delete", "", "Delete the bookmark NAME")
+	bookmarkCmd.Flags().BoolVar(&bookmarkJsonFlag, "json", false, "Output as JSON")
+}
+
+var bookmarkCmd = &cobra.Command{
+	Use:   "bookmark <name> [--on REGEX [--as NAME] | --off NAME | --add NAME | --delete NAME]",
+	Short: "Mark positions in a session's output, by hand or on a pattern match",
+	Long: `Mark positions in a session's output to come back to later with
+read --around-bookmark.
+
+--on starts a watch: each output line matching the regex (ANSI stripped) is
+bookmarked as it arrives, named after --as with a running number
+(failure-1, failure-2, ...). --add bookmarks the end of the output now, as a
+checkpoint. Without flags, lists the session's watches and bookmarks.
+
+Bookmarks move with the buffer like read positions do, and go away when the
+output they point at is dropped (clear, or the buffer limit). At most 1000
+are kept per session, oldest dropped first. Not for TUI sessions.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBookmark,
+}
+
+func runBookmark(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	mode, bookmark, pattern := daemon.BookmarkModeList, "", ""
+	actions := 0
+	if bookmarkOnFlag != "" {
+		mode, bookmark, pattern = daemon.BookmarkModeWatch, bookmarkAsFlag, bookmarkOnFlag
+		actions++
+	}
+	if bookmarkOffFlag != "" || bookmarkOffAllFlag {
+		mode, bookmark = daemon.BookmarkModeUnwatch, bookmarkOffFlag
+		actions++
+	}
+	if bookmarkAddFlag != "" {
+		mode, bookmark = daemon.BookmarkModeAdd, bookmarkAddFlag
+		actions++
+	}
+	if bookmarkDeleteFlag != "" {
+		mode, bookmark = daemon.BookmarkModeDelete, bookmarkDeleteFlag
+		actions++
+	}
+	if actions > 1 || (bookmarkOffFlag != "" && bookmarkOffAllFlag) {
+		return fmt.Errorf("--on, --off, --off-all, --add and --delete are mutually exclusive")
+	}
+	if bookmarkAsFlag != "" && bookmarkOnFlag == "" {
+		return fmt.Errorf("--as requires --on")
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	set, err := client.Bookmark(name, mode, bookmark, pattern)
+	if err != nil {
+		return err
+	}
+
+	if bookmarkJsonFlag {
+		data, _ := json.MarshalIndent(set, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	for _, w := range set.Watches {
+		fmt.Printf("watch %s: /%s/ (%d matched)\n", w.Prefix, w.Pattern, w.Count)
+	}
+	for _, b := range set.Bookmarks {
+		if b.Match != "" {
+			fmt.Printf("%s\t%d\t%s\n", b.Name, b.Offset, b.Match)
+		} else {
+			fmt.Printf("%s\t%d\n", b.Name, b.Offset)
+		}
+	}
+	return nil
+}
@@ -8,10 +8,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var clearJsonFlag bool
+var (
+	clearJsonFlag   bool
+	clearDryRunFlag bool
+)
 
 func init() {
 	clearCmd.Flags().BoolVar(&clearJsonFlag, "json", false, "Output as JSON")
+	clearCmd.Flags().BoolVar(&clearDryRunFlag, "dry-run", false, "Show what would be affected without doing it")
 }
 
 var clearCmd = &cobra.Command{
@@ -30,6 +34,10 @@ func runClear(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("daemon: %w", err)
 	}
 
+	if clearDryRunFlag {
+		return printDryRun(client, "clear", name, clearJsonFlag)
+	}
+
 	if err := client.Clear(name); err != nil {
 		return err
 	}
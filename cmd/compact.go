@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var compactJsonFlag bool
+
+func init() {
+	compactCmd.Flags().BoolVar(&compactJsonFlag, "json", false, "Output as JSON")
+}
+
+var compactCmd = &cobra.Command{
+	Use:   "compact <name>",
+	Short: "Rewrite session output as plain text",
+	Long: `Render the session's output buffer the way 'read --strip-ansi' would and
+replace the stored bytes with the clean text. Use it to reclaim space on
+sessions that ran full-screen apps without --tui.
+
+The read position and cursors keep pointing at the same content. Colors and
+other escape sequences are gone afterwards. Not available for TUI sessions,
+which store no raw output.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCompact,
+}
+
+func runCompact(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	before, after, err := client.Compact(name)
+	if err != nil {
+		return err
+	}
+
+	if compactJsonFlag {
+		out := map[string]interface{}{
+			"name":         name,
+			"bytes_before": before,
+			"bytes_after":  after,
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("Compacted session %q: %d -> %d bytes (%d reclaimed)\n", name, before, after, before-after)
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/schovi/shelli/internal/daemon"
 	"github.com/spf13/cobra"
@@ -11,49 +13,163 @@ import (
 var createCmd = &cobra.Command{
 	Use:   "create <name>",
 	Short: "Create a new interactive session",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runCreate,
+	Long: `Create a new interactive session.
+
+--profile NAME starts from the options saved in NAME.json, looked up in
+~/.config/shelli/profiles and .shelli/profiles of the current git repository
+(the repository's wins), or in $SHELLI_PROFILE_PATH. A profile may set
+command, env (["KEY=VALUE", ...]), cwd, cols, rows and tui; flags given on
+the command line take precedence, and --env adds to the profile's env.
+
+--on-exit COMMAND runs a shell command in the background once the session's
+process exits (also after stop, but not after kill), e.g. to send a
+notification. It gets SHELLI_SESSION, SHELLI_EXIT_CODE, SHELLI_EXIT_SIGNAL,
+SHELLI_END_REASON and the last 20 lines of output, ANSI stripped, in
+SHELLI_OUTPUT. Repeat it to run several commands in order.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCreate,
 }
 
 var (
-	createCmdFlag         string
-	createJsonFlag        bool
-	createEnvFlag         []string
-	createCwdFlag         string
-	createColsFlag        int
-	createRowsFlag        int
-	createTUIFlag         bool
-	createIfNotExistsFlag bool
+	createCmdFlag          string
+	createJsonFlag         bool
+	createEnvFlag          []string
+	createCwdFlag          string
+	createColsFlag         int
+	createRowsFlag         int
+	createTUIFlag          bool
+	createIfNotExistsFlag  bool
+	createFrameHistoryFlag int
+	createScrollbackFlag   int
+	createCaptureRawFlag   string
+	createBoundariesFlag   []string
+	createSizeFlag         string
+	createNiceFlag         int
+	createIOniceFlag       string
+	createEncodingFlag     string
+	createMirrorInputFlag  bool
+	createSwallowFlag      string
+	createCaptureFlag      string
+	createProfileFlag      string
+	createOnExitFlag       []string
+	createSnapshotFlag     string
+	createMemLimitFlag     string
+	createCPUTimeFlag      time.Duration
+	createCPULimitFlag     int
 )
 
 func init() {
 	createCmd.Flags().StringVar(&createCmdFlag, "cmd", "", "Command to run (default: $SHELL)")
+	createCmd.Flags().StringVar(&createProfileFlag, "profile", "", "Start from a saved profile (command, env, cwd, size, tui); flags given override it")
 	createCmd.Flags().BoolVar(&createJsonFlag, "json", false, "Output as JSON")
 	createCmd.Flags().StringArrayVar(&createEnvFlag, "env", nil, "Set environment variable (KEY=VALUE), can be repeated")
 	createCmd.Flags().StringVar(&createCwdFlag, "cwd", "", "Set working directory")
 	createCmd.Flags().IntVar(&createColsFlag, "cols", 80, "Terminal columns")
 	createCmd.Flags().IntVar(&createRowsFlag, "rows", 24, "Terminal rows")
+	createCmd.Flags().StringVar(&createSizeFlag, "size", "", "Terminal size: preset:<name> (default, wide, tall, large) or auto (match this terminal)")
 	createCmd.Flags().BoolVar(&createTUIFlag, "tui", false, "Enable TUI mode (auto-truncate buffer on frame boundaries)")
 	createCmd.Flags().BoolVar(&createIfNotExistsFlag, "if-not-exists", false, "Return existing session if already running instead of error")
+	createCmd.Flags().IntVar(&createFrameHistoryFlag, "frame-history", 0, "Number of past TUI frames to keep (default 0 = off, TUI mode only)")
+	createCmd.Flags().IntVar(&createScrollbackFlag, "scrollback", 0, "Number of rows scrolled off the TUI screen to keep (0 = disabled, TUI mode only)")
+	createCmd.Flags().StringVar(&createCaptureRawFlag, "capture-raw", "", "Tee unmodified PTY output to this file (timings go to <file>.timing)")
+	createCmd.Flags().IntVar(&createNiceFlag, "nice", 0, "CPU niceness for the session's processes (-20 to 19)")
+	createCmd.Flags().StringVar(&createIOniceFlag, "ionice", "", "I/O class: idle, best-effort[:0-7], realtime[:0-7] or none (Linux only)")
+	createCmd.Flags().StringVar(&createMemLimitFlag, "memory-limit", "", "Cap the session's memory (e.g. 512MB): address space per process, and all processes together on Linux with cgroup v2")
+	createCmd.Flags().DurationVar(&createCPUTimeFlag, "cpu-time-limit", 0, "Cap the CPU time of each of the session's processes (e.g. 10m); SIGXCPU ends them")
+	createCmd.Flags().IntVar(&createCPULimitFlag, "cpu-limit", 0, "Cap the session's CPU use at this percent of one CPU (over 100 for several; Linux with cgroup v2)")
+	createCmd.Flags().StringVar(&createEncodingFlag, "encoding", "", "Charset of a non-UTF-8 program (e.g. latin1, shift_jis); output is stored as UTF-8, input converted back")
+	createCmd.Flags().BoolVar(&createMirrorInputFlag, "mirror-input", false, "Record sent input inline in the output buffer (not in TUI mode)")
+	createCmd.Flags().StringVar(&createSwallowFlag, "swallow-output-until", "", "Keep startup output out of the buffer until this regex matches it, or for N ms; it is kept as the session's banner (not in TUI mode)")
+	createCmd.Flags().StringVar(&createCaptureFlag, "capture-priority", "", "How output is read: interactive (store every read at once), normal or bulk (batch reads, for floods of output); default interactive with --tui, else normal")
+	createCmd.Flags().StringArrayVar(&createOnExitFlag, "on-exit", nil, "Shell command to run when the session's process exits (exit code and final output in SHELLI_* env vars), can be repeated")
+	createCmd.Flags().StringVar(&createSnapshotFlag, "snapshot-mode", "", "How snapshots redraw: resize (jiggle the PTY size) or passive (never resize, for programs that crash on it); TUI mode only")
+	createCmd.Flags().StringSliceVar(&createBoundariesFlag, "frame-boundaries", nil, "Frame boundary detectors for frame history (see 'shelli frames boundaries', TUI mode only)")
 }
 
 func runCreate(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
+	captureRaw := createCaptureRawFlag
+	if captureRaw != "" {
+		abs, err := filepath.Abs(captureRaw)
+		if err != nil {
+			return fmt.Errorf("resolve capture path: %w", err)
+		}
+		captureRaw = abs
+	}
+
+	cols, rows := createColsFlag, createRowsFlag
+	if createSizeFlag != "" {
+		if cmd.Flags().Changed("cols") || cmd.Flags().Changed("rows") {
+			return fmt.Errorf("--size cannot be combined with --cols or --rows")
+		}
+		size, err := resolveSize(createSizeFlag)
+		if err != nil {
+			return err
+		}
+		cols, rows = size.Cols, size.Rows
+	}
+
+	var nice *int
+	if cmd.Flags().Changed("nice") {
+		nice = &createNiceFlag
+	}
+
+	limits, err := createLimits()
+	if err != nil {
+		return err
+	}
+
+	var profile *daemon.Profile
+	if createProfileFlag != "" {
+		var err error
+		if profile, err = daemon.LoadProfile(daemon.ProfileDirs(), createProfileFlag); err != nil {
+			return err
+		}
+		// Leave the size to the profile unless given.
+		if createSizeFlag == "" && !cmd.Flags().Changed("cols") {
+			cols = 0
+		}
+		if createSizeFlag == "" && !cmd.Flags().Changed("rows") {
+			rows = 0
+		}
+	}
+
 	client := daemon.NewClient()
 	if err := client.EnsureDaemon(); err != nil {
 		return fmt.Errorf("daemon: %w", err)
 	}
 
-	data, err := client.Create(name, daemon.CreateOptions{
-		Command:     createCmdFlag,
-		Env:         createEnvFlag,
-		Cwd:         createCwdFlag,
-		Cols:        createColsFlag,
-		Rows:        createRowsFlag,
-		TUIMode:     createTUIFlag,
-		IfNotExists: createIfNotExistsFlag,
-	})
+	opts := daemon.CreateOptions{
+		Command:            createCmdFlag,
+		Env:                createEnvFlag,
+		Cwd:                createCwdFlag,
+		Cols:               cols,
+		Rows:               rows,
+		TUIMode:            createTUIFlag,
+		IfNotExists:        createIfNotExistsFlag,
+		FrameHistory:       createFrameHistoryFlag,
+		Scrollback:         createScrollbackFlag,
+		CaptureRaw:         captureRaw,
+		FrameBoundaries:    createBoundariesFlag,
+		Nice:               nice,
+		IOClass:            createIOniceFlag,
+		Limits:             limits,
+		Encoding:           createEncodingFlag,
+		MirrorInput:        createMirrorInputFlag,
+		SwallowOutputUntil: createSwallowFlag,
+		CapturePriority:    createCaptureFlag,
+		OnExit:             createOnExitFlag,
+		SnapshotMode:       createSnapshotFlag,
+	}
+	if profile != nil {
+		var err error
+		if opts, err = profile.Apply(opts); err != nil {
+			return err
+		}
+	}
+
+	data, err := client.Create(name, opts)
 	if err != nil {
 		return err
 	}
@@ -71,3 +187,24 @@ func runCreate(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// createLimits builds the session's resource limits from the flags (nil
+// when none is given).
+func createLimits() (*daemon.ResourceLimits, error) {
+	limits := &daemon.ResourceLimits{CPUPercent: createCPULimitFlag}
+	if createMemLimitFlag != "" {
+		size, err := daemon.ParseSize(createMemLimitFlag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --memory-limit: %w", err)
+		}
+		limits.MemoryBytes = int64(size)
+	}
+	if createCPUTimeFlag > 0 {
+		// RLIMIT_CPU counts whole seconds.
+		limits.CPUSeconds = int64((createCPUTimeFlag + time.Second - 1) / time.Second)
+	}
+	if *limits == (daemon.ResourceLimits{}) {
+		return nil, nil
+	}
+	return limits, nil
+}
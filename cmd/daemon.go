@@ -2,13 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"regexp"
-	"strconv"
-	"strings"
 	"syscall"
 	"time"
 
@@ -19,11 +17,28 @@ import (
 
 var (
 	daemonMaxOutputFlag   string
+	daemonMaxFileOutput   string
+	daemonCompressionFlag string
+	daemonMaxMemoryFlag   string
+	daemonEvictionFlag    string
 	daemonMCPFlag         bool
+	daemonProjectTools    bool
 	daemonDataDirFlag     string
 	daemonMemoryBackend   bool
 	daemonStoppedTTLFlag  string
 	daemonLogFileFlag     string
+	daemonLogMaxSizeFlag  string
+	daemonLogMaxAgeFlag   time.Duration
+	daemonLogKeepFlag     int
+	daemonHookFlags       []string
+	daemonConfigFlag      string
+	daemonMetricsAddrFlag string
+	daemonSessionRateFlag string
+	daemonClientRateFlag  string
+	daemonListenFlag      string
+	daemonTokenFileFlag   string
+	daemonAllowUIDFlags   []int
+	daemonRequireToken    bool
 )
 
 var daemonCmd = &cobra.Command{
@@ -36,8 +51,18 @@ var daemonCmd = &cobra.Command{
 func init() {
 	daemonCmd.Flags().StringVar(&daemonMaxOutputFlag, "max-output", "10MB",
 		"Maximum output buffer size per session for memory backend (e.g., 10MB, 1GB)")
+	daemonCmd.Flags().StringVar(&daemonMaxFileOutput, "max-file-output", "",
+		"Maximum output kept on disk per session for file backend, oldest dropped first (e.g., 100MB; default: unbounded)")
+	daemonCmd.Flags().StringVar(&daemonCompressionFlag, "output-compression", "",
+		"Compress sealed output segments for file backend: gzip or none (default: none)")
+	daemonCmd.Flags().StringVar(&daemonMaxMemoryFlag, "max-memory", "",
+		"Cap the memory backend's output of all sessions together (e.g., 1GB; default: unbounded)")
+	daemonCmd.Flags().StringVar(&daemonEvictionFlag, "memory-eviction", "",
+		"What makes room under --max-memory: spill (stopped sessions' output to disk, default) or truncate (least recently read)")
 	daemonCmd.Flags().BoolVar(&daemonMCPFlag, "mcp", false,
 		"Run as MCP server (JSON-RPC over stdio)")
+	daemonCmd.Flags().BoolVar(&daemonProjectTools, "project-tools", false,
+		"With --mcp: also load custom tools from .shelli/tools in the current repository")
 	daemonCmd.Flags().StringVar(&daemonDataDirFlag, "data-dir", "",
 		"Directory for session output files (default: /tmp/shelli-{uid}/data)")
 	daemonCmd.Flags().BoolVar(&daemonMemoryBackend, "memory-backend", false,
@@ -46,55 +71,191 @@ func init() {
 		"Auto-cleanup stopped sessions after duration (e.g., 5m, 1h, 24h)")
 	daemonCmd.Flags().StringVar(&daemonLogFileFlag, "log-file", "",
 		"Write daemon logs to file (default: discard)")
+	daemonCmd.Flags().StringVar(&daemonLogMaxSizeFlag, "log-max-size", "10MB",
+		"Rotate the log file once it reaches this size (0: never)")
+	daemonCmd.Flags().DurationVar(&daemonLogMaxAgeFlag, "log-max-age", 0,
+		"Rotate the log file after writing to it this long (e.g., 24h; default: never)")
+	daemonCmd.Flags().IntVar(&daemonLogKeepFlag, "log-keep", daemon.DefaultLogKeep,
+		"Rotated log files to keep (file.1 is the newest)")
+	daemonCmd.Flags().StringArrayVar(&daemonHookFlags, "hook", nil,
+		"Run a command on a session event, as event=command (repeatable; events: pre/post-create, pre/post-send, pre/post-stop)")
+	daemonCmd.Flags().StringVar(&daemonConfigFlag, "config", "",
+		"Config file, re-read on SIGHUP or `shelli reload` (default: $SHELLI_CONFIG or ~/.config/shelli/daemon.json)")
+	daemonCmd.Flags().StringVar(&daemonMetricsAddrFlag, "metrics-addr", "",
+		"Serve Prometheus metrics over HTTP at this address, e.g. 127.0.0.1:9464 (path "+daemon.MetricsPath+"; default: off)")
+	daemonCmd.Flags().StringVar(&daemonSessionRateFlag, "session-rate-limit", "",
+		"Cap requests per session, e.g. 20/s or 600/m (default: unlimited)")
+	daemonCmd.Flags().StringVar(&daemonClientRateFlag, "client-rate-limit", "",
+		"Cap requests per client (see $"+daemon.ClientIDEnv+"), e.g. 50/s (default: unlimited)")
+	daemonCmd.Flags().StringVar(&daemonListenFlag, "listen", "",
+		"Also accept clients over TCP, e.g. tcp://0.0.0.0:7373 (token required; unencrypted, default: off)")
+	daemonCmd.Flags().StringVar(&daemonTokenFileFlag, "token-file", "",
+		"Token file for --listen and --require-token, created with a random token if missing (default: $"+daemon.TokenEnv+" or <runtime dir>/token)")
+	daemonCmd.Flags().IntSliceVar(&daemonAllowUIDFlags, "allow-uid", nil,
+		"Let this user (uid) use the socket besides the daemon's own (repeatable; default: own user only)")
+	daemonCmd.Flags().BoolVar(&daemonRequireToken, "require-token", false,
+		"Require the daemon's token in socket requests too (clients read it from the token file)")
 }
 
 func runDaemon(cmd *cobra.Command, args []string) error {
 	if daemonMCPFlag {
 		return runMCPServer()
 	}
+	if daemonProjectTools {
+		return fmt.Errorf("--project-tools requires --mcp")
+	}
 
 	if daemonLogFileFlag != "" {
-		f, err := os.OpenFile(daemonLogFileFlag, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		maxSize, err := daemon.ParseSize(daemonLogMaxSizeFlag)
 		if err != nil {
-			return fmt.Errorf("open log file: %w", err)
+			return fmt.Errorf("invalid --log-max-size: %w", err)
+		}
+		if daemonLogKeepFlag < 0 {
+			return fmt.Errorf("--log-keep must not be negative")
 		}
-		defer f.Close()
-		log.SetOutput(f)
+		logFile, err := daemon.OpenRotatingLog(daemonLogFileFlag, int64(maxSize), daemonLogMaxAgeFlag, daemonLogKeepFlag)
+		if err != nil {
+			return err
+		}
+		defer logFile.Close()
+		log.SetOutput(logFile)
 		log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
-		os.Stderr = f
+		// Route stderr writes through the log too, so they follow rotation.
+		if r, w, err := os.Pipe(); err == nil {
+			os.Stderr = w
+			go io.Copy(logFile, r) //nolint:errcheck // ends with the process
+		}
+		if err := daemon.RecordLogPath(logFile.Path()); err != nil {
+			log.Printf("record log path: %v", err)
+		}
 		log.Println("daemon starting")
+	} else {
+		daemon.RecordLogPath("") //nolint:errcheck // a stale note only misleads daemon logs
 	}
 
 	var opts []daemon.ServerOption
 
+	// Flags given explicitly override the config file, also across reloads.
+	var flagConfig daemon.Config
+
 	if daemonDataDirFlag == "" {
-		runtimeDir, err := daemon.RuntimeDir()
+		dataDir, err := daemon.DefaultDataDir()
 		if err != nil {
 			return fmt.Errorf("get runtime dir: %w", err)
 		}
-		daemonDataDirFlag = filepath.Join(runtimeDir, "data")
+		daemonDataDirFlag = dataDir
 	}
 
 	if daemonMemoryBackend {
-		maxSize, err := parseSize(daemonMaxOutputFlag)
+		if daemonCompressionFlag != "" {
+			return fmt.Errorf("--output-compression needs the file backend")
+		}
+		maxSize, err := daemon.ParseSize(daemonMaxOutputFlag)
 		if err != nil {
 			return fmt.Errorf("invalid --max-output: %w", err)
 		}
-		opts = append(opts, daemon.WithStorage(daemon.NewMemoryStorage(maxSize)))
+		if cmd.Flags().Changed("max-output") {
+			flagConfig.MaxOutput = daemonMaxOutputFlag
+		}
+		memStorage := daemon.NewMemoryStorage(maxSize)
+		budget := 0
+		if daemonMaxMemoryFlag != "" {
+			if budget, err = daemon.ParseSize(daemonMaxMemoryFlag); err != nil {
+				return fmt.Errorf("invalid --max-memory: %w", err)
+			}
+			flagConfig.MaxMemory = daemonMaxMemoryFlag
+		}
+		if daemonEvictionFlag != "" {
+			if err := daemon.ValidateEviction(daemonEvictionFlag); err != nil {
+				return fmt.Errorf("invalid --memory-eviction: %w", err)
+			}
+			flagConfig.MemoryEviction = daemonEvictionFlag
+		}
+		memStorage.SetMemoryBudget(budget, daemonEvictionFlag)
+		if err := memStorage.SetSpillDir(filepath.Join(daemonDataDirFlag, "spill")); err != nil {
+			return err
+		}
+		opts = append(opts, daemon.WithStorage(memStorage))
 	} else {
+		if daemonMaxMemoryFlag != "" || daemonEvictionFlag != "" {
+			return fmt.Errorf("--max-memory and --memory-eviction need --memory-backend")
+		}
 		fileStorage, err := daemon.NewFileStorage(daemonDataDirFlag)
 		if err != nil {
 			return fmt.Errorf("create file storage: %w", err)
 		}
+		if daemonMaxFileOutput != "" {
+			maxSize, err := daemon.ParseSize(daemonMaxFileOutput)
+			if err != nil {
+				return fmt.Errorf("invalid --max-file-output: %w", err)
+			}
+			fileStorage.SetMaxOutputSize(maxSize)
+			flagConfig.MaxFileOutput = daemonMaxFileOutput
+		}
+		if daemonCompressionFlag != "" {
+			if err := daemon.ValidateCompression(daemonCompressionFlag); err != nil {
+				return fmt.Errorf("invalid --output-compression: %w", err)
+			}
+			fileStorage.SetCompression(daemonCompressionFlag)
+			flagConfig.OutputCompression = daemonCompressionFlag
+		}
 		opts = append(opts, daemon.WithStorage(fileStorage))
 	}
 
 	if daemonStoppedTTLFlag != "" {
-		ttl, err := time.ParseDuration(daemonStoppedTTLFlag)
-		if err != nil {
+		if _, err := time.ParseDuration(daemonStoppedTTLFlag); err != nil {
 			return fmt.Errorf("invalid --stopped-ttl: %w", err)
 		}
-		opts = append(opts, daemon.WithStoppedTTL(ttl))
+		flagConfig.StoppedTTL = daemonStoppedTTLFlag
+	}
+
+	if daemonSessionRateFlag != "" {
+		if _, _, err := daemon.ParseRate(daemonSessionRateFlag); err != nil {
+			return fmt.Errorf("invalid --session-rate-limit: %w", err)
+		}
+		flagConfig.SessionRateLimit = daemonSessionRateFlag
+	}
+	if daemonClientRateFlag != "" {
+		if _, _, err := daemon.ParseRate(daemonClientRateFlag); err != nil {
+			return fmt.Errorf("invalid --client-rate-limit: %w", err)
+		}
+		flagConfig.ClientRateLimit = daemonClientRateFlag
+	}
+
+	if len(daemonAllowUIDFlags) > 0 {
+		flagConfig.AllowedUIDs = daemonAllowUIDFlags
+	}
+	if daemonRequireToken {
+		flagConfig.RequireToken = true
+	}
+	if daemonTokenFileFlag != "" {
+		opts = append(opts, daemon.WithTokenFile(daemonTokenFileFlag))
+	}
+
+	if len(daemonHookFlags) > 0 {
+		flagConfig.Hooks = daemon.Hooks{}
+		for _, spec := range daemonHookFlags {
+			event, command, err := daemon.ParseHook(spec)
+			if err != nil {
+				return fmt.Errorf("invalid --hook: %w", err)
+			}
+			flagConfig.Hooks[event] = append(flagConfig.Hooks[event], command)
+		}
+	}
+
+	// Set when `shelli daemon restart` replaced the previous daemon with
+	// this one.
+	if path := os.Getenv(daemon.HandoffEnv); path != "" {
+		os.Unsetenv(daemon.HandoffEnv)
+		opts = append(opts, daemon.WithHandoff(path))
+	}
+
+	configPath := daemonConfigFlag
+	if configPath == "" {
+		configPath = daemon.DefaultConfigPath()
+	}
+	if configPath != "" {
+		opts = append(opts, daemon.WithConfig(configPath, flagConfig))
 	}
 
 	server, err := daemon.NewServer(opts...)
@@ -102,6 +263,36 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if daemonMetricsAddrFlag != "" {
+		addr, err := server.ServeMetrics(daemonMetricsAddrFlag)
+		if err != nil {
+			return err
+		}
+		log.Printf("serving metrics at http://%s%s", addr, daemon.MetricsPath)
+	}
+
+	if daemonListenFlag != "" {
+		addr, err := daemon.ParseListen(daemonListenFlag)
+		if err != nil {
+			return err
+		}
+		tokenPath := daemonTokenFileFlag
+		if tokenPath == "" {
+			if tokenPath, err = daemon.TokenPath(); err != nil {
+				return err
+			}
+		}
+		token, err := daemon.LoadToken(tokenPath)
+		if err != nil {
+			return err
+		}
+		addr, err = server.ServeTCP(addr, token)
+		if err != nil {
+			return err
+		}
+		log.Printf("accepting clients at tcp://%s (token: $%s or %s)", addr, daemon.TokenEnv, tokenPath)
+	}
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
@@ -112,46 +303,25 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		os.Exit(0)
 	}()
 
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+
+	go func() {
+		for range hupCh {
+			changed, err := server.Reload()
+			if err != nil {
+				log.Printf("reload: %v", err)
+				continue
+			}
+			log.Printf("reloaded %s, changed: %v", configPath, changed)
+		}
+	}()
+
 	return server.Start()
 }
 
 func runMCPServer() error {
-	tools := mcp.NewToolRegistry()
+	tools := mcp.NewToolRegistry(mcp.PluginDirs(daemonProjectTools))
 	server := mcp.NewServer(tools, version)
 	return server.Run()
 }
-
-func parseSize(s string) (int, error) {
-	s = strings.TrimSpace(strings.ToUpper(s))
-	if s == "" {
-		return 0, fmt.Errorf("empty size")
-	}
-
-	re := regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(B|KB|MB|GB)?$`)
-	matches := re.FindStringSubmatch(s)
-	if matches == nil {
-		return 0, fmt.Errorf("invalid format: %s", s)
-	}
-
-	val, err := strconv.ParseFloat(matches[1], 64)
-	if err != nil {
-		return 0, err
-	}
-
-	unit := matches[2]
-	if unit == "" {
-		unit = "B"
-	}
-
-	multiplier := 1.0
-	switch unit {
-	case "KB":
-		multiplier = 1024
-	case "MB":
-		multiplier = 1024 * 1024
-	case "GB":
-		multiplier = 1024 * 1024 * 1024
-	}
-
-	return int(val * multiplier), nil
-}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+// daemonLogsPoll is how often --follow checks the log for new lines.
+const daemonLogsPoll = 250 * time.Millisecond
+
+var (
+	daemonLogsLinesFlag  int
+	daemonLogsFollowFlag bool
+	daemonLogsPathFlag   bool
+)
+
+func init() {
+	daemonLogsCmd.Flags().IntVarP(&daemonLogsLinesFlag, "lines", "n", 50, "Show the last N lines (0: all)")
+	daemonLogsCmd.Flags().BoolVarP(&daemonLogsFollowFlag, "follow", "f", false, "Keep printing new lines, across rotations, until interrupted")
+	daemonLogsCmd.Flags().BoolVar(&daemonLogsPathFlag, "path", false, "Only print the log file's path")
+	daemonCmd.AddCommand(daemonLogsCmd)
+}
+
+var daemonLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the daemon log",
+	Long: `Print the end of the log of the latest daemon started with --log-file,
+found through a note the daemon leaves in its runtime directory. The log
+stays readable after the daemon exits.
+
+Examples:
+  shelli daemon logs              # last 50 lines
+  shelli daemon logs -f           # follow, like tail -f
+  less "$(shelli daemon logs --path)"`,
+	Args: cobra.NoArgs,
+	RunE: runDaemonLogs,
+}
+
+func runDaemonLogs(cmd *cobra.Command, args []string) error {
+	path, err := daemon.LogPath()
+	if err != nil {
+		return err
+	}
+	if daemonLogsPathFlag {
+		fmt.Println(path)
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open daemon log: %w", err)
+	}
+	defer func() { f.Close() }()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("read daemon log: %w", err)
+	}
+	os.Stdout.Write(lastLines(data, daemonLogsLinesFlag))
+
+	if !daemonLogsFollowFlag {
+		return nil
+	}
+	for {
+		time.Sleep(daemonLogsPoll)
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			return fmt.Errorf("read daemon log: %w", err)
+		}
+		// After a rotation the path names a new file: drain the old one
+		// (done above) and continue from the start of the new one.
+		current, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if open, err := f.Stat(); err == nil && !os.SameFile(open, current) {
+			next, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			f.Close()
+			f = next
+		}
+	}
+}
+
+// lastLines returns the last n lines of data, or all of it for n <= 0.
+func lastLines(data []byte, n int) []byte {
+	if n <= 0 {
+		return data
+	}
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	for i := 0; i < n; i++ {
+		j := bytes.LastIndexByte(data[:end], '\n')
+		if j < 0 {
+			return data
+		}
+		end = j
+	}
+	return data[end+1:]
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	daemonCmd.AddCommand(daemonProxyCmd)
+}
+
+var daemonProxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Relay stdin/stdout to the local daemon (remote end of ssh://)",
+	Long: `Relay one connection between stdin/stdout and this machine's daemon,
+starting it if needed. Clients with $` + daemon.HostEnv + `=ssh://[USER@]HOST run it on
+HOST over ssh for each request; there is no need to run it by hand.`,
+	Args:   cobra.NoArgs,
+	Hidden: true,
+	RunE:   runDaemonProxy,
+}
+
+func runDaemonProxy(cmd *cobra.Command, args []string) error {
+	// Always the local daemon, whatever $SHELLI_HOST says here.
+	sockPath, err := daemon.SocketPath()
+	if err != nil {
+		return err
+	}
+	client := daemon.NewClientWithSocketPath(sockPath)
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	return client.Proxy(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var daemonRestartJsonFlag bool
+
+func init() {
+	daemonRestartCmd.Flags().BoolVar(&daemonRestartJsonFlag, "json", false, "Output as JSON")
+	daemonCmd.AddCommand(daemonRestartCmd)
+}
+
+var daemonRestartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "Restart the daemon, keeping running sessions alive",
+	Long: `Replace the running daemon with a fresh copy of its executable, for
+example after upgrading shelli, without killing any session. The new
+daemon keeps the process ID, the socket and the PTYs of running sessions,
+so their processes keep running and their stored output stays readable.
+
+Requests arriving meanwhile wait for the new daemon. Streams (read
+--follow, attach, events) are cut and must reconnect. Images,
+notifications, jobs and the emulator screens of TUI sessions start over.
+Needs the file storage backend (the default).`,
+	Args: cobra.NoArgs,
+	RunE: runDaemonRestart,
+}
+
+func runDaemonRestart(cmd *cobra.Command, args []string) error {
+	client := daemon.NewClient()
+	if !client.Ping() {
+		return fmt.Errorf("daemon is not running")
+	}
+
+	result, err := client.Restart()
+	if err != nil {
+		return err
+	}
+
+	if daemonRestartJsonFlag {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Restarted daemon (pid %d), %d running sessions kept\n", result.PID, result.Sessions)
+	return nil
+}
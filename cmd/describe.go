@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var describeJsonFlag bool
+
+func init() {
+	describeCmd.Flags().BoolVar(&describeJsonFlag, "json", false, "Output as JSON")
+}
+
+var describeCmd = &cobra.Command{
+	Use:   "describe <name>",
+	Short: "Sum up a session: state, prompt, last lines and recent events",
+	Long: `Sum up a session in one call: its state, command and uptime, whether the
+cursor sits at an interactive prompt (shell, python, pdb, psql, node) or after
+a question the program is waiting on ("Overwrite? [y/N]", "Password:"),
+whether a full-screen app is on the alternate screen, the last 20 lines of
+output with ANSI codes stripped, and its recent lifecycle events (created,
+resized, exited, ...).
+
+Examples:
+  shelli describe build
+  shelli describe build --json | jq .question`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDescribe,
+}
+
+func runDescribe(cmd *cobra.Command, args []string) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	d, err := client.Describe(args[0])
+	if err != nil {
+		return err
+	}
+
+	if describeJsonFlag {
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%s: %s, %s (up %s", d.Name, d.State, d.Command, formatDuration(d.UptimeSeconds))
+	if d.IdleSeconds > 0 {
+		fmt.Printf(", idle %s", formatDuration(d.IdleSeconds))
+	}
+	fmt.Println(")")
+	switch {
+	case d.AtPrompt:
+		fmt.Printf("At %s prompt\n", d.Prompt)
+	case d.Question != "":
+		fmt.Printf("Waiting for an answer: %s\n", d.Question)
+	}
+	if d.AltScreen {
+		fmt.Println("On the alternate screen")
+	}
+	if d.ExitCode != nil {
+		fmt.Printf("Exited with %d (%s)\n", *d.ExitCode, d.EndReason)
+	} else if d.EndReason != "" {
+		fmt.Printf("Ended: %s\n", d.EndReason)
+	}
+
+	if len(d.Events) > 0 {
+		fmt.Println("Events:")
+		for _, ev := range d.Events {
+			fmt.Printf("  %s %s%s\n", ev.At.Local().Format(time.TimeOnly), ev.Type, eventDetail(ev))
+		}
+	}
+	if len(d.Lines) > 0 {
+		fmt.Println("Last lines:")
+		for _, line := range d.Lines {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	return nil
+}
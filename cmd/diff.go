@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffFromFlag      string
+	diffToFlag        string
+	diffMaxLinesFlag  int
+	diffStripAnsiFlag bool
+	diffJsonFlag      bool
+)
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFromFlag, "from", "", "Start: a byte position or cursor name (default: the read position)")
+	diffCmd.Flags().StringVar(&diffToFlag, "to", "", "End: a byte position or cursor name (default: the end of the buffer)")
+	diffCmd.Flags().IntVar(&diffMaxLinesFlag, "max-lines", daemon.DiffDefaultMaxLines, "Return at most the last N added lines")
+	diffCmd.Flags().BoolVar(&diffStripAnsiFlag, "strip-ansi", false, "Strip ANSI escape codes")
+	diffCmd.Flags().BoolVar(&diffJsonFlag, "json", false, "Output as JSON")
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <name>",
+	Short: "Show the output added between two buffer positions",
+	Long: `Show the output a session added between two positions in its buffer, as
+lines with carriage-return overwrites applied, plus a summary: lines and bytes
+added and the time between the first and last of them.
+
+Positions are byte offsets (as returned by read and exec) or read cursor
+names. --from defaults to the session's read position and --to to the end of
+the buffer, so a bare diff answers "what happened since I last read?". Nothing
+is consumed: the read position and cursors do not move.
+
+Examples:
+  shelli diff build
+  shelli diff build --from 10240 --json
+  shelli diff build --from agent --max-lines 20 --strip-ansi`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDiff,
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if diffMaxLinesFlag <= 0 {
+		return fmt.Errorf("--max-lines must be positive")
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	result, err := client.Diff(name, diffFromFlag, diffToFlag, diffMaxLinesFlag, diffStripAnsiFlag)
+	if err != nil {
+		return err
+	}
+
+	if diffJsonFlag {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if result.OmittedLines > 0 {
+		fmt.Fprintf(os.Stderr, "(%d earlier lines omitted)\n", result.OmittedLines)
+	}
+	for _, line := range result.Lines {
+		fmt.Println(line)
+	}
+	sum := result.Summary
+	fmt.Fprintf(os.Stderr, "%d-%d: %d lines, %s", result.From, result.To, sum.LinesAdded, formatBytes(sum.Bytes))
+	if sum.FirstAt != "" {
+		fmt.Fprintf(os.Stderr, " over %s", formatDuration(sum.DurationSeconds))
+	}
+	fmt.Fprintln(os.Stderr)
+	return nil
+}
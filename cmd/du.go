@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var duCmd = &cobra.Command{
+	Use:   "du",
+	Short: "Show storage used by sessions and prune old ones",
+	Long: `List each session's stored output size, on-disk (file backend) or
+in-memory (memory backend) footprint, age and state. Files in the data dir
+that belong to no session are reported as orphans.
+
+Age is the time since the session stopped, or since it was created if it is
+still running.
+
+With --prune, matching sessions are killed, which deletes their output:
+  shelli du --prune --older-than 7d             # stopped sessions idle for a week
+  shelli du --prune --state any --older-than 1d # running ones too`,
+	Args: cobra.NoArgs,
+	RunE: runDu,
+}
+
+var (
+	duJsonFlag      bool
+	duPruneFlag     bool
+	duOlderThanFlag string
+	duStateFlag     string
+)
+
+func init() {
+	duCmd.Flags().BoolVar(&duJsonFlag, "json", false, "Output as JSON")
+	duCmd.Flags().BoolVar(&duPruneFlag, "prune", false, "Kill matching sessions and delete their output")
+	duCmd.Flags().StringVar(&duOlderThanFlag, "older-than", "", "Only sessions older than this (e.g. 30m, 12h, 7d)")
+	duCmd.Flags().StringVar(&duStateFlag, "state", "", "Only sessions in this state: stopped, running, or any (default: any; stopped with --prune)")
+}
+
+func runDu(cmd *cobra.Command, args []string) error {
+	var olderThan time.Duration
+	if duOlderThanFlag != "" {
+		var err error
+		if olderThan, err = parseAge(duOlderThanFlag); err != nil {
+			return fmt.Errorf("invalid --older-than: %w", err)
+		}
+	}
+
+	state := duStateFlag
+	if state == "" {
+		state = "any"
+		if duPruneFlag {
+			state = string(daemon.StateStopped)
+		}
+	}
+	switch state {
+	case "any", string(daemon.StateStopped), string(daemon.StateRunning):
+	default:
+		return fmt.Errorf("invalid --state %q: use stopped, running, or any", state)
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	report, err := client.DiskUsage()
+	if err != nil {
+		return err
+	}
+
+	// Totals cover what is listed: the matching sessions plus orphans.
+	report.TotalBytes = 0
+	for _, o := range report.Orphans {
+		report.TotalBytes += o.Bytes
+	}
+	matched := report.Sessions[:0]
+	for _, s := range report.Sessions {
+		if state != "any" && s.State != state {
+			continue
+		}
+		if s.AgeSec < olderThan.Seconds() {
+			continue
+		}
+		matched = append(matched, s)
+		report.TotalBytes += s.DiskBytes + s.MemoryBytes
+	}
+	report.Sessions = matched
+
+	var pruned []string
+	var failed []string
+	if duPruneFlag {
+		for _, s := range matched {
+			if err := client.Kill(s.Name); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", s.Name, err))
+				continue
+			}
+			pruned = append(pruned, s.Name)
+		}
+	}
+
+	if duJsonFlag {
+		out := map[string]interface{}{
+			"backend":     report.Backend,
+			"sessions":    report.Sessions,
+			"total_bytes": report.TotalBytes,
+		}
+		if report.DataDir != "" {
+			out["data_dir"] = report.DataDir
+		}
+		if len(report.Orphans) > 0 {
+			out["orphans"] = report.Orphans
+		}
+		if report.Memory != nil {
+			out["memory"] = report.Memory
+		}
+		if duPruneFlag {
+			out["pruned"] = pruned
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printUsage(report, duPruneFlag, pruned)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("prune failed for %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func printUsage(report *daemon.UsageReport, prune bool, pruned []string) {
+	if len(report.Sessions) == 0 {
+		fmt.Println("No sessions")
+	} else {
+		t := newTable("NAME", "STATE", "SIZE", "AGE")
+		for _, s := range report.Sessions {
+			size := formatBytes(s.DiskBytes)
+			if s.Spilled {
+				size += " (spilled)"
+			} else if report.Backend == "memory" {
+				size = formatBytes(s.MemoryBytes)
+			}
+			t.row(s.Name, paintState(s.State), size, formatDuration(s.AgeSec))
+		}
+		t.print(os.Stdout)
+	}
+	if len(report.Orphans) > 0 {
+		t := newTable("ORPHAN", "SIZE")
+		for _, o := range report.Orphans {
+			t.row(o.Name, formatBytes(o.Bytes))
+		}
+		t.print(os.Stdout)
+	}
+
+	where := report.Backend
+	if report.DataDir != "" {
+		where = report.DataDir
+	}
+	fmt.Printf("Total: %s (%s)\n", formatBytes(report.TotalBytes), where)
+	if m := report.Memory; m != nil && m.Budget > 0 {
+		fmt.Printf("Memory: %s of %s (%s); %d spilled (%s), %d truncated (%s) since start\n",
+			formatBytes(m.Bytes), formatBytes(m.Budget), m.Policy,
+			m.Spills, formatBytes(m.SpilledOut), m.Truncations, formatBytes(m.Truncated))
+	}
+
+	if prune {
+		fmt.Printf("Pruned %d session(s)\n", len(pruned))
+	}
+}
+
+// parseAge parses a Go duration, also accepting a whole number of days
+// ("7d").
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid day count %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+	return d, nil
+}
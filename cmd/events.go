@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var eventsJsonFlag bool
+
+func init() {
+	eventsCmd.Flags().BoolVar(&eventsJsonFlag, "json", false, "Output one JSON object per event (JSON Lines)")
+}
+
+var eventsCmd = &cobra.Command{
+	Use:   "events [pattern]",
+	Short: "Stream session lifecycle events",
+	Long: `Stream session lifecycle events as they happen, until interrupted:
+created, stopped, exited (with exit code), killed, removed (stopped-session
+TTL), output-truncated (clear, buffer limit, memory budget), spilled (output
+moved to disk by the memory budget) and resized.
+
+The optional pattern limits events to matching session names, with glob
+syntax (e.g. 'build-*'). A "dropped" event reports events lost because the
+reader fell behind.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEvents,
+}
+
+func runEvents(cmd *cobra.Command, args []string) error {
+	pattern := ""
+	if len(args) > 0 {
+		pattern = args[0]
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	enc := json.NewEncoder(os.Stdout)
+	return client.Events(ctx, pattern, nil, func(ev daemon.LifecycleEvent) error {
+		if eventsJsonFlag {
+			return enc.Encode(ev)
+		}
+		fmt.Printf("%s\t%s\t%s%s\n", ev.At.Local().Format(time.TimeOnly), ev.Type, ev.Session, eventDetail(ev))
+		return nil
+	})
+}
+
+func eventDetail(ev daemon.LifecycleEvent) string {
+	switch ev.Type {
+	case daemon.EventExited:
+		switch {
+		case ev.Signal != "":
+			return fmt.Sprintf("\t%s (%s)", ev.Reason, ev.Signal)
+		case ev.ExitCode != nil:
+			return fmt.Sprintf("\t%s (code %d)", ev.Reason, *ev.ExitCode)
+		}
+		return "\t" + ev.Reason
+	case daemon.EventOutputTruncated:
+		return fmt.Sprintf("\t%s (%s)", ev.Reason, formatBytes(ev.Bytes))
+	case daemon.EventSpilled:
+		return "\t" + formatBytes(ev.Bytes)
+	case daemon.EventResized:
+		return fmt.Sprintf("\t%dx%d", ev.Cols, ev.Rows)
+	case daemon.EventDropped:
+		return fmt.Sprintf("%d events", ev.Count)
+	}
+	return ""
+}
@@ -3,8 +3,10 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/schovi/shelli/internal/vterm"
 	"github.com/schovi/shelli/internal/daemon"
@@ -12,7 +14,7 @@ import (
 )
 
 var execCmd = &cobra.Command{
-	Use:   "exec <name> <input>",
+	Use:   "exec <name> <input> | exec <name> --steps <file> | exec <name> --fg <job>",
 	Short: "Send command and wait for result",
 	Long: `Send a command to a session and wait for the result.
 
@@ -22,38 +24,147 @@ the shell as-is (the shell may interpret them, e.g., echo -e).
 
 For precise control over escape sequences, use 'send' instead.
 
-By default waits for 500ms of silence. Use --wait for pattern matching.`,
-	Args: cobra.MinimumNArgs(2),
+By default waits for 500ms of silence. Use --wait for pattern matching, or
+--wait-prompt to return once the output ends in a known interactive prompt
+(shell PS1, python >>>, pdb/ipdb, psql =#, node >) with the cursor right
+after it, however long the command runs or pauses.
+
+The input is followed by LF, or by CR when the program has put the terminal
+in raw mode (ICANON off), like pressing Enter; --enter lf|cr overrides this.
+
+With --structured, the echoed command line and the trailing prompt are split
+off: plain output shows only the command's own output, and --json returns
+echo, body, and prompt separately. If the echo is not found, body falls back
+to the raw output.
+
+With --steps, runs a script of inputs in order and reports per-step output and
+status. The file ("-" for stdin) is either one input per line, or a JSON array
+of {"input", "wait_pattern", "settle_ms", "timeout_sec", "probe"} objects;
+unset fields use the command-line flags. Execution stops at the first step
+that times out (or fails, with --probe) unless --keep-going is set.
+
+With --max-cpu or --max-wall, the daemon watches the command (the session's
+foreground job) and stops it with SIGTERM, then SIGKILL, once it uses more CPU
+time or runs longer than allowed. The outcome is reported as "budget".
+
+With --background, the input is started as a background job ("<input> &") of
+a shell session and exec returns right away with the job's number and PID;
+'shelli jobs' lists them. --fg <job> brings one back ("fg %<job>") and waits
+for its output like a normal exec.
+
+With --delimit, a marker command is appended to the input line and exec waits
+for its answer instead of settle or a pattern, so it returns as soon as the
+command finishes however long it pauses. The marker is removed from the output
+and the buffer, and its answer reports the exit status and working directory
+as with --probe. The input must be one complete command of a shell session,
+without a trailing '&' or comment.
+
+With --idempotency-key, retrying is safe: when an exec with the same key ran
+in the session in the last 10 minutes, the input is not sent again and the
+first exec's result is returned instead (after it finishes, if it is still
+running), marked "duplicate" in --json.
+
+With --complete-lines, the output stops at its last newline: a trailing
+partial line (a spinner frame, a progress bar, a prompt) is held back so
+parsers only see whole lines. --json returns it separately as "partial".
+With --structured this applies to the body.`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: runExec,
 }
 
 var (
-	execWaitFlag      string
-	execSettleFlag    int
-	execTimeoutFlag   int
-	execStripAnsiFlag bool
-	execJsonFlag      bool
+	execWaitFlag       string
+	execSettleFlag     int
+	execTimeoutFlag    int
+	execStripAnsiFlag  bool
+	execJsonFlag       bool
+	execStructuredFlag bool
+	execProbeFlag      bool
+	execStepsFlag      string
+	execKeepGoingFlag  bool
+	execMaxCPUFlag     time.Duration
+	execMaxWallFlag    time.Duration
+	execBackgroundFlag bool
+	execFgFlag         string
+	execEnterFlag      string
+	execDelimitFlag    bool
+	execKeyFlag        string
+	execPromptFlag     bool
+	execLinesFlag      bool
 )
 
 func init() {
 	execCmd.Flags().StringVar(&execWaitFlag, "wait", "", "Wait for regex pattern match")
+	execCmd.Flags().BoolVar(&execPromptFlag, "wait-prompt", false, "Wait for an interactive prompt (shell, python, pdb, psql, node) instead of settle")
 	execCmd.Flags().IntVar(&execSettleFlag, "settle", 500, "Wait for N ms of silence (default 500)")
 	execCmd.Flags().IntVar(&execTimeoutFlag, "timeout", 10, "Max wait time in seconds")
 	execCmd.Flags().BoolVar(&execStripAnsiFlag, "strip-ansi", false, "Strip ANSI escape codes")
 	execCmd.Flags().BoolVar(&execJsonFlag, "json", false, "Output as JSON")
+	execCmd.Flags().BoolVar(&execProbeFlag, "probe", false, "Report exit status and working directory (shell sessions; probe output is hidden)")
+	execCmd.Flags().BoolVar(&execStructuredFlag, "structured", false, "Separate echoed input and trailing prompt from the output")
+	execCmd.Flags().StringVar(&execStepsFlag, "steps", "", "Run a script of inputs from file (\"-\" for stdin)")
+	execCmd.Flags().BoolVar(&execKeepGoingFlag, "keep-going", false, "With --steps, run all steps even after one fails")
+	execCmd.Flags().DurationVar(&execMaxCPUFlag, "max-cpu", 0, "Stop the command after this much CPU time, e.g. 60s (Linux only)")
+	execCmd.Flags().DurationVar(&execMaxWallFlag, "max-wall", 0, "Stop the command after running this long, e.g. 5m")
+	execCmd.Flags().BoolVar(&execBackgroundFlag, "background", false, "Start the input as a background job and return its PID (shell sessions)")
+	execCmd.Flags().StringVar(&execEnterFlag, "enter", daemon.EnterAuto, "Line terminator after the input: auto (CR when the app has the terminal in raw mode, else LF), lf, cr")
+	execCmd.Flags().BoolVar(&execDelimitFlag, "delimit", false, "Wait for a marker appended to the command instead of settle or a pattern (shell sessions)")
+	execCmd.Flags().StringVar(&execFgFlag, "fg", "", "Bring background job N (or %N) to the foreground and wait for its output")
+	execCmd.Flags().BoolVar(&execLinesFlag, "complete-lines", false, "Return only whole lines; a trailing partial line goes to \"partial\" in --json")
+	execCmd.Flags().StringVar(&execKeyFlag, "idempotency-key", "", "Key for safe retries: a repeated key returns the first exec's result without sending again")
 }
 
 func runExec(cmd *cobra.Command, args []string) error {
 	name := args[0]
 	input := strings.Join(args[1:], " ")
 
+	if execStepsFlag != "" {
+		if len(args) > 1 {
+			return fmt.Errorf("--steps cannot be combined with an input argument")
+		}
+	} else if execFgFlag != "" {
+		if len(args) > 1 {
+			return fmt.Errorf("--fg cannot be combined with an input argument")
+		}
+		input = "fg %" + strings.TrimPrefix(execFgFlag, "%")
+	} else if len(args) < 2 {
+		return fmt.Errorf("requires <name> and <input> (or --steps)")
+	}
+
+	if execBackgroundFlag {
+		if execStepsFlag != "" || execFgFlag != "" || execWaitFlag != "" || cmd.Flags().Changed("settle") ||
+			execProbeFlag || execStructuredFlag || execMaxCPUFlag > 0 || execMaxWallFlag > 0 {
+			return fmt.Errorf("--background cannot be combined with --steps, --fg, --wait, --settle, --probe, --structured, --max-cpu or --max-wall")
+		}
+	}
+
 	hasWait := execWaitFlag != ""
 	hasSettle := cmd.Flags().Changed("settle")
 
+	if execDelimitFlag && (execStepsFlag != "" || hasWait || hasSettle || execBackgroundFlag) {
+		return fmt.Errorf("--delimit cannot be combined with --steps, --wait, --settle or --background")
+	}
+
 	if hasWait && hasSettle {
 		return fmt.Errorf("--wait and --settle are mutually exclusive")
 	}
 
+	if execPromptFlag && (hasWait || hasSettle || execDelimitFlag || execBackgroundFlag) {
+		return fmt.Errorf("--wait-prompt cannot be combined with --wait, --settle, --delimit or --background")
+	}
+
+	if execKeyFlag != "" && (execStepsFlag != "" || execBackgroundFlag) {
+		return fmt.Errorf("--idempotency-key cannot be combined with --steps or --background")
+	}
+
+	if execLinesFlag && (execStepsFlag != "" || execBackgroundFlag) {
+		return fmt.Errorf("--complete-lines cannot be combined with --steps or --background")
+	}
+
+	if err := daemon.ValidateEnter(execEnterFlag); err != nil {
+		return err
+	}
+
 	client := daemon.NewClient()
 	if err := client.EnsureDaemon(); err != nil {
 		return fmt.Errorf("daemon: %w", err)
@@ -64,26 +175,66 @@ func runExec(cmd *cobra.Command, args []string) error {
 
 	if hasWait {
 		pattern = execWaitFlag
-	} else {
+	} else if !execPromptFlag {
 		settleMs = execSettleFlag
 	}
 
+	if execBackgroundFlag {
+		return runExecBackground(client, name, input)
+	}
+
+	timeoutSec := execTimeoutFlag
+	if execMaxWallFlag > 0 && !cmd.Flags().Changed("timeout") {
+		timeoutSec = 0 // wait long enough for the wall budget
+	}
+
+	if execStepsFlag != "" {
+		return runExecSteps(client, name, daemon.ExecOptions{
+			SettleMs:    settleMs,
+			WaitPattern: pattern,
+			WaitPrompt:  execPromptFlag,
+			TimeoutSec:  timeoutSec,
+			Probe:       execProbeFlag,
+			MaxCPU:      execMaxCPUFlag,
+			MaxWall:     execMaxWallFlag,
+			Enter:       execEnterFlag,
+		})
+	}
+
 	result, err := client.Exec(name, daemon.ExecOptions{
-		Input:       input,
-		SettleMs:    settleMs,
-		WaitPattern: pattern,
-		TimeoutSec:  execTimeoutFlag,
+		Input:          input,
+		SettleMs:       settleMs,
+		WaitPattern:    pattern,
+		TimeoutSec:     timeoutSec,
+		Probe:          execProbeFlag,
+		MaxCPU:         execMaxCPUFlag,
+		MaxWall:        execMaxWallFlag,
+		Enter:          execEnterFlag,
+		Delimit:        execDelimitFlag,
+		WaitPrompt:     execPromptFlag,
+		IdempotencyKey: execKeyFlag,
 	})
 	if err != nil {
-		if result == nil || result.Output == "" {
+		if result == nil || (result.Output == "" && result.Timeout == nil) {
 			return err
 		}
 		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 	}
+	if result.Duplicate && !execJsonFlag {
+		fmt.Fprintf(os.Stderr, "Note: idempotency key %q was already used; not sent again, showing the earlier result\n", execKeyFlag)
+	}
 
-	output := result.Output
+	if execStructuredFlag {
+		return printStructuredExec(result)
+	}
+
+	output, partial := result.Output, ""
+	if execLinesFlag {
+		output, partial = daemon.SplitPartialLine(output)
+	}
 	if execStripAnsiFlag {
 		output = vterm.StripDefault(output)
+		partial = vterm.StripDefault(partial)
 	}
 
 	if execJsonFlag {
@@ -92,6 +243,18 @@ func runExec(cmd *cobra.Command, args []string) error {
 			"output":   output,
 			"position": result.Position,
 		}
+		if execLinesFlag {
+			out["partial"] = partial
+		}
+		if result.ID != 0 {
+			out["exec_id"] = result.ID
+		}
+		if result.Duplicate {
+			out["duplicate"] = true
+		}
+		addProbeFields(out, result.Probe)
+		addBudgetField(out, result.Budget)
+		addTimeoutField(out, result.Timeout)
 		data, err := json.MarshalIndent(out, "", "  ")
 		if err != nil {
 			return fmt.Errorf("marshal output: %w", err)
@@ -99,7 +262,188 @@ func runExec(cmd *cobra.Command, args []string) error {
 		fmt.Println(string(data))
 	} else {
 		fmt.Print(output)
+		printProbe(result.Probe)
+		printBudget(result.Budget)
+		printTimeout(result.Timeout)
+	}
+
+	return nil
+}
+
+func runExecBackground(client *daemon.Client, name, input string) error {
+	job, err := client.Background(name, input, 0)
+	if err != nil {
+		return err
+	}
+
+	if execJsonFlag {
+		data, err := json.MarshalIndent(job, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
 	}
+	fmt.Printf("[%d] %d\n", job.ID, job.PID)
+	return nil
+}
 
+func printStructuredExec(result *daemon.ExecResult) error {
+	parts := daemon.SplitExecOutput(result.Output, result.Input)
+	var partial string
+	if execLinesFlag {
+		parts.Body, partial = daemon.SplitPartialLine(parts.Body)
+	}
+	if execStripAnsiFlag {
+		parts.Echo = vterm.StripDefault(parts.Echo)
+		parts.Body = vterm.StripDefault(parts.Body)
+		parts.Prompt = vterm.StripDefault(parts.Prompt)
+		partial = vterm.StripDefault(partial)
+	}
+
+	if !execJsonFlag {
+		fmt.Print(parts.Body)
+		printProbe(result.Probe)
+		printBudget(result.Budget)
+		printTimeout(result.Timeout)
+		return nil
+	}
+
+	out := map[string]interface{}{
+		"input":    result.Input,
+		"echo":     parts.Echo,
+		"body":     parts.Body,
+		"prompt":   parts.Prompt,
+		"split":    parts.Split,
+		"position": result.Position,
+	}
+	if execLinesFlag {
+		out["partial"] = partial
+	}
+	if result.ID != 0 {
+		out["exec_id"] = result.ID
+	}
+	if result.Duplicate {
+		out["duplicate"] = true
+	}
+	addProbeFields(out, result.Probe)
+	addBudgetField(out, result.Budget)
+	addTimeoutField(out, result.Timeout)
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal output: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func addProbeFields(out map[string]interface{}, probe *daemon.ProbeResult) {
+	if probe != nil {
+		out["exit_code"] = probe.ExitCode
+		out["cwd"] = probe.Cwd
+	}
+}
+
+// printProbe reports probe results on stderr, keeping stdout to the output.
+func printProbe(probe *daemon.ProbeResult) {
+	if probe != nil {
+		fmt.Fprintf(os.Stderr, "[exit %d, cwd %s]\n", probe.ExitCode, probe.Cwd)
+	}
+}
+
+func addBudgetField(out map[string]interface{}, budget *daemon.BudgetResult) {
+	if budget != nil {
+		out["budget"] = budget
+	}
+}
+
+// printBudget reports on stderr when a budget stopped the command.
+func printBudget(budget *daemon.BudgetResult) {
+	if budget == nil {
+		return
+	}
+	switch budget.Status {
+	case daemon.BudgetCPUExceeded, daemon.BudgetWallExceeded:
+		fmt.Fprintf(os.Stderr, "[budget %s after %.1fs cpu, %.1fs wall; sent %s]\n",
+			budget.Status, budget.CPUSec, budget.WallSec, budget.Signal)
+	}
+}
+
+func addTimeoutField(out map[string]interface{}, diag *daemon.TimeoutDiagnosis) {
+	if diag != nil {
+		out["timeout"] = diag
+	}
+}
+
+// printTimeout summarizes on stderr where a timed-out exec stood; the last
+// lines are already in the output.
+func printTimeout(diag *daemon.TimeoutDiagnosis) {
+	if diag == nil {
+		return
+	}
+	grew, prompt := "no new output", "no prompt"
+	if diag.OutputGrew {
+		grew = "output grew"
+	}
+	if diag.PromptSeen {
+		prompt = "prompt seen"
+	}
+	fmt.Fprintf(os.Stderr, "[timed out: %s, %s; suggest %s]\n", grew, prompt, diag.Suggestion)
+}
+
+func runExecSteps(client *daemon.Client, name string, defaults daemon.ExecOptions) error {
+	var data []byte
+	var err error
+	if execStepsFlag == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(execStepsFlag)
+	}
+	if err != nil {
+		return fmt.Errorf("read steps: %w", err)
+	}
+	steps, err := daemon.ParseSteps(data)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		return fmt.Errorf("no steps in %s", execStepsFlag)
+	}
+
+	results := client.ExecSteps(name, steps, defaults, execKeepGoingFlag)
+	for i := range results {
+		r := &results[i]
+		if execStructuredFlag {
+			r.Output = daemon.SplitExecOutput(r.Output, r.Input).Body
+		}
+		if execStripAnsiFlag {
+			r.Output = vterm.StripDefault(r.Output)
+		}
+	}
+
+	if execJsonFlag {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		for _, r := range results {
+			fmt.Printf("--- Step %d [%s]: %s\n", r.Step, r.Status, r.Input)
+			fmt.Print(r.Output)
+			if r.Output != "" && !strings.HasSuffix(r.Output, "\n") {
+				fmt.Println()
+			}
+			if r.Error != "" {
+				fmt.Fprintf(os.Stderr, "Step %d: %s\n", r.Step, r.Error)
+			}
+		}
+	}
+
+	for _, r := range results {
+		if r.Status != daemon.StepOK {
+			return fmt.Errorf("step %d %s", r.Step, r.Status)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execStatusIDFlag   int64
+	execStatusJsonFlag bool
+)
+
+func init() {
+	execStatusCmd.Flags().Int64Var(&execStatusIDFlag, "id", 0, "Exec ID to check (default: the session's latest exec)")
+	execStatusCmd.Flags().BoolVar(&execStatusJsonFlag, "json", false, "Output as JSON")
+}
+
+var execStatusCmd = &cobra.Command{
+	Use:   "exec-status <name>",
+	Short: "Show progress of a session's running exec",
+	Long: `Show the progress of the latest exec in a session: how long it has been
+running, how much output it has produced, how long the session has been quiet,
+and the last line of output.
+
+Use it from another shell while a long exec is waiting, or after an exec timed
+out to see whether the command is still making progress. Status is how the
+exec's wait ended (running, completed, timeout, error); the other fields always
+describe the session now.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExecStatus,
+}
+
+func runExecStatus(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	status, err := client.ExecStatus(name, execStatusIDFlag)
+	if err != nil {
+		return err
+	}
+
+	if execStatusJsonFlag {
+		data, _ := json.MarshalIndent(status, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Exec:    %d\n", status.ID)
+	fmt.Printf("Status:  %s\n", status.Status)
+	fmt.Printf("Input:   %s\n", status.Input)
+	fmt.Printf("Elapsed: %s\n", formatDuration(status.Elapsed))
+	fmt.Printf("Output:  %s\n", formatBytes(status.Bytes))
+	fmt.Printf("Idle:    %s\n", formatDuration(status.Idle))
+	if status.LastLine != "" {
+		fmt.Printf("Last:    %s\n", status.LastLine)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutputFlag string
+	exportFormatFlag string
+	exportJsonFlag   bool
+)
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportOutputFlag, "output", "o", "", "File to write (default: <name>.tar.zst, or <name>.cast for asciicast)")
+	exportCmd.Flags().StringVar(&exportFormatFlag, "format", "bundle", "Output format: bundle or asciicast")
+	exportCmd.Flags().BoolVar(&exportJsonFlag, "json", false, "Output as JSON")
+}
+
+var exportCmd = &cobra.Command{
+	Use:     "export-session <name>",
+	Aliases: []string{"export"},
+	Short:   "Export a session to a bundle file",
+	Long: `Export a session's metadata, output buffer and input history to a
+zstd-compressed tar bundle. Non-TUI bundles also hold an asciicast recording of
+the output (recording.cast).
+
+The bundle can be loaded into any daemon with 'import-session' for offline analysis.
+TUI sessions export their current screen content.
+
+With --format asciicast the output is written as an asciinema v2 recording,
+timed by when each chunk of output arrived. Play it with 'asciinema play'.
+TUI sessions only keep their screen, so they cannot be exported as asciicast.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	var ext string
+	switch exportFormatFlag {
+	case "bundle":
+		ext = ".tar.zst"
+	case "asciicast":
+		ext = ".cast"
+	default:
+		return fmt.Errorf("invalid --format %q: must be bundle or asciicast", exportFormatFlag)
+	}
+
+	path := exportOutputFlag
+	if path == "" {
+		path = name + ext
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	bundle, err := client.Export(name)
+	if err != nil {
+		return err
+	}
+	if exportFormatFlag == "asciicast" && bundle.Meta.TUIMode {
+		return fmt.Errorf("session %q is a TUI session: only its screen is kept, there is no recording to export", name)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("create %s file: %w", exportFormatFlag, err)
+	}
+	defer f.Close()
+
+	if exportFormatFlag == "asciicast" {
+		err = daemon.WriteAsciicast(f, bundle)
+	} else {
+		err = daemon.WriteBundle(f, bundle)
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", exportFormatFlag, err)
+	}
+
+	if exportJsonFlag {
+		out := map[string]interface{}{
+			"name":   name,
+			"file":   path,
+			"format": exportFormatFlag,
+			"bytes":  len(bundle.Output),
+			"status": "exported",
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("Exported session %q to %s (%d bytes of output)\n", name, path, len(bundle.Output))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var extractCmd = &cobra.Command{
+	Use:   "extract <name>",
+	Short: "Extract JSON objects and arrays from session output",
+	Long: `Extract the JSON in a session's output, with escape sequences stripped.
+
+Reads the new output since the last read (moving the read position like
+read does), or all of it with --all, and prints each JSON object or array
+found on its own line. A value must start a line, so the echoed command is
+skipped, and pretty-printed or colored output (jq) is parsed as one value.
+
+--json-path narrows each value to a field, e.g. .items[0].name; values
+without it are left out. --json wraps the values with their count and the
+read position.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExtract,
+}
+
+var (
+	extractAllFlag    bool
+	extractPathFlag   string
+	extractCursorFlag string
+	extractJsonFlag   bool
+)
+
+func init() {
+	extractCmd.Flags().BoolVar(&extractAllFlag, "all", false, "Extract from all output instead of new output")
+	extractCmd.Flags().StringVar(&extractPathFlag, "json-path", "", "Return only this field of each value, e.g. .items[0].name")
+	extractCmd.Flags().StringVar(&extractCursorFlag, "cursor", "", "Named cursor for per-consumer read tracking")
+	extractCmd.Flags().BoolVar(&extractJsonFlag, "json", false, "Output as JSON")
+}
+
+func runExtract(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	mode := daemon.ReadModeNew
+	if extractAllFlag {
+		mode = daemon.ReadModeAll
+	}
+	output, pos, err := client.ReadWithCursor(name, mode, extractCursorFlag, 0, 0)
+	if err != nil {
+		return err
+	}
+	values, err := daemon.ExtractJSONPath(output, extractPathFlag)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	if extractJsonFlag {
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{
+			"values":   values,
+			"count":    len(values),
+			"position": pos,
+		})
+	}
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fitMaxColsFlag int
+	fitMaxRowsFlag int
+	fitJsonFlag    bool
+)
+
+func init() {
+	fitCmd.Flags().IntVar(&fitMaxColsFlag, "max-cols", 0, "Never fit wider than this")
+	fitCmd.Flags().IntVar(&fitMaxRowsFlag, "max-rows", 0, "Never fit taller than this")
+	fitCmd.Flags().BoolVar(&fitJsonFlag, "json", false, "Output as JSON")
+}
+
+var fitCmd = &cobra.Command{
+	Use:   "fit <name>",
+	Short: "Shrink a TUI session's terminal to its content",
+	Long: `Resize a TUI session's terminal to the rows and columns its screen actually
+uses, so snapshots are not padded with blank space. The terminal only
+shrinks (down to 20x2); --max-cols and --max-rows cap it further.
+
+The app redraws at the new size, so check the result with 'read --snapshot'.
+Requires TUI mode (--tui on create).`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFit,
+}
+
+func runFit(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if fitMaxColsFlag < 0 || fitMaxRowsFlag < 0 {
+		return fmt.Errorf("--max-cols and --max-rows must not be negative")
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	result, err := client.Fit(name, fitMaxColsFlag, fitMaxRowsFlag)
+	if err != nil {
+		return err
+	}
+
+	if fitJsonFlag {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if result.Cols == result.FromCols && result.Rows == result.FromRows {
+		fmt.Printf("Session %q already fits its content at %dx%d\n", name, result.Cols, result.Rows)
+		return nil
+	}
+	fmt.Printf("Resized session %q from %dx%d to %dx%d (content %dx%d)\n",
+		name, result.FromCols, result.FromRows, result.Cols, result.Rows, result.UsedCols, result.UsedRows)
+	return nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/schovi/shelli/internal/vterm"
+	"github.com/spf13/cobra"
+)
+
+var framesCmd = &cobra.Command{
+	Use:   "frames",
+	Short: "Inspect TUI frame history",
+	Long: `Inspect the frame history of a TUI session.
+
+TUI sessions keep the last few rendered frames, each captured just before the
+app started a redraw. Read one with 'shelli read <name> --frame -N'.`,
+}
+
+var framesListCmd = &cobra.Command{
+	Use:   "list <name>",
+	Short: "List captured frames of a TUI session",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFramesList,
+}
+
+var framesBoundariesCmd = &cobra.Command{
+	Use:   "boundaries",
+	Short: "List available frame boundary detectors",
+	Long: `List the frame boundary detectors a TUI session can use.
+
+Select them per session with 'shelli create --tui --frame-boundaries a,b'.
+Without the flag, the defaults are used.`,
+	Args: cobra.NoArgs,
+	RunE: runFramesBoundaries,
+}
+
+var framesJsonFlag bool
+
+func init() {
+	framesListCmd.Flags().BoolVar(&framesJsonFlag, "json", false, "Output as JSON")
+	framesCmd.AddCommand(framesListCmd)
+	framesCmd.AddCommand(framesBoundariesCmd)
+}
+
+func runFramesBoundaries(cmd *cobra.Command, args []string) error {
+	defaults := make(map[string]bool, len(vterm.DefaultBoundaries))
+	for _, name := range vterm.DefaultBoundaries {
+		defaults[name] = true
+	}
+	for _, name := range vterm.BoundaryNames() {
+		if defaults[name] {
+			fmt.Printf("%s\t(default)\n", name)
+		} else {
+			fmt.Println(name)
+		}
+	}
+	return nil
+}
+
+func runFramesList(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	frames, err := client.Frames(name)
+	if err != nil {
+		return err
+	}
+
+	if framesJsonFlag {
+		data, err := json.MarshalIndent(frames, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(frames) == 0 {
+		fmt.Println("No frames captured")
+		return nil
+	}
+	for _, f := range frames {
+		fmt.Printf("%d\t%s\tv%d\t%d lines\n", f.Index, f.CapturedAt, f.Version, f.Lines)
+	}
+	return nil
+}
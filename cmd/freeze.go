@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var freezeJsonFlag bool
+
+func init() {
+	freezeCmd.Flags().BoolVar(&freezeJsonFlag, "json", false, "Output as JSON")
+}
+
+var freezeCmd = &cobra.Command{
+	Use:   "freeze <name>",
+	Short: "Halt a session's program (SIGSTOP) until thaw",
+	Long: `Halt a session's program with SIGSTOP until 'shelli thaw'.
+
+The session's process group and the job in the foreground (if it runs in a
+group of its own) are stopped, so a runaway build or a script about to do
+damage can be held while you decide what to do with it. Nothing is lost:
+'thaw' continues it where it stopped. Unlike 'pause', which only stops
+storing output, the program itself does not run.
+
+While frozen, send and exec are refused; list and info show the session as
+frozen. stop and kill work as usual.
+
+Examples:
+  shelli freeze deploy
+  shelli thaw deploy`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFreeze,
+}
+
+func runFreeze(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	result, err := client.Freeze(name)
+	if err != nil {
+		return err
+	}
+
+	if freezeJsonFlag {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Printf("Froze session %q (continue with 'shelli thaw %s')\n", name, name)
+	return nil
+}
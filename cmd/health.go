@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var healthJsonFlag bool
+
+func init() {
+	healthCmd.Flags().BoolVar(&healthJsonFlag, "json", false, "Output as JSON")
+}
+
+var healthCmd = &cobra.Command{
+	Use:   "health <name>",
+	Short: "Check that a session's process and PTY are alive",
+	Long: `Check a session's liveness beyond its running/stopped state: the child's
+process state (R running, S sleeping, D disk wait, T stopped, Z zombie), when
+it last produced output, and whether its PTY still accepts writes.
+
+Status is one of ok, suspended (stopped by SIGSTOP/Ctrl+Z or a tracer),
+zombie, pty_error or exited. Exits non-zero unless the status is ok, so it
+works as a probe:
+
+  shelli health server || shelli kill server`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHealth,
+}
+
+func runHealth(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	health, err := client.Health(name)
+	if err != nil {
+		return err
+	}
+
+	if healthJsonFlag {
+		data, _ := json.MarshalIndent(health, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		var f fields
+		f.add("Status", "%s", paintHealth(health.Status))
+		if health.Status != daemon.HealthExited {
+			if health.ProcessState != "" {
+				f.add("Process", "%s", health.ProcessState)
+			}
+			if health.PTYOK {
+				f.add("PTY", "ok")
+			} else {
+				f.add("PTY", "broken")
+			}
+			f.add("Idle", "%s", formatDuration(health.IdleSeconds))
+			f.add("Responsive", "%v", health.Responsive)
+		}
+		if health.Detail != "" {
+			f.add("Detail", "%s", health.Detail)
+		}
+		f.print(os.Stdout)
+	}
+
+	if health.Status != daemon.HealthOK {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("session %q is %s", name, health.Status)
+	}
+	return nil
+}
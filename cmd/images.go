@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	imagesSaveFlag   int
+	imagesOutputFlag string
+	imagesJsonFlag   bool
+)
+
+func init() {
+	imagesCmd.Flags().IntVar(&imagesSaveFlag, "save", 0, "Save image with this ID to a file")
+	imagesCmd.Flags().StringVarP(&imagesOutputFlag, "output", "o", "", "File to write with --save (default: <name>-<id>.<ext>)")
+	imagesCmd.Flags().BoolVar(&imagesJsonFlag, "json", false, "Output as JSON")
+}
+
+var imagesCmd = &cobra.Command{
+	Use:   "images <name>",
+	Short: "List or save inline images a session displayed",
+	Long: `List or save inline images a session displayed.
+
+Images sent with the iTerm2 (OSC 1337 File=) or kitty graphics protocol are
+removed from the output buffer and kept per session (the last 20).
+Save one with --save <id>.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImages,
+}
+
+func runImages(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	if imagesSaveFlag > 0 {
+		return runImagesSave(client, name)
+	}
+
+	images, err := client.Images(name)
+	if err != nil {
+		return err
+	}
+
+	if imagesJsonFlag {
+		data, err := json.MarshalIndent(images, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(images) == 0 {
+		fmt.Println("No images captured")
+		return nil
+	}
+	for _, img := range images {
+		format := img.Format
+		if format == "" {
+			format = "unknown"
+		}
+		fmt.Printf("%d\t%s\t%s\t%d bytes\t%s\n", img.ID, img.Protocol, format, img.Bytes, img.Name)
+	}
+	return nil
+}
+
+func runImagesSave(client *daemon.Client, name string) error {
+	img, err := client.Image(name, imagesSaveFlag)
+	if err != nil {
+		return err
+	}
+
+	path := imagesOutputFlag
+	if path == "" {
+		path = fmt.Sprintf("%s-%d.%s", name, img.ID, imageExt(img.Format))
+	}
+	if err := os.WriteFile(path, img.Data, 0600); err != nil {
+		return fmt.Errorf("write image: %w", err)
+	}
+
+	if imagesJsonFlag {
+		out := map[string]interface{}{
+			"id":     img.ID,
+			"file":   path,
+			"bytes":  len(img.Data),
+			"format": img.Format,
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("Saved image %d to %s (%d bytes)\n", img.ID, path, len(img.Data))
+	}
+	return nil
+}
+
+func imageExt(format string) string {
+	switch format {
+	case "png", "gif":
+		return format
+	case "jpeg":
+		return "jpg"
+	}
+	return "bin"
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importNameFlag string
+	importJsonFlag bool
+)
+
+func init() {
+	importCmd.Flags().StringVar(&importNameFlag, "name", "", "Session name to import as (default: name stored in bundle)")
+	importCmd.Flags().BoolVar(&importJsonFlag, "json", false, "Output as JSON")
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import-session <file>",
+	Short: "Import a session from a bundle file",
+	Long: `Import a session bundle created by 'export-session'.
+
+The imported session is always stopped: its output can be read and searched,
+but there is no process to send input to.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("open bundle file: %w", err)
+	}
+	defer f.Close()
+
+	bundle, err := daemon.ReadBundle(f)
+	if err != nil {
+		return fmt.Errorf("read bundle: %w", err)
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	data, err := client.Import(importNameFlag, bundle)
+	if err != nil {
+		return err
+	}
+
+	if importJsonFlag {
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("Imported session %q (%.0f bytes of output)\n", data["name"], data["bytes"])
+	}
+	return nil
+}
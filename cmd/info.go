@@ -3,6 +3,9 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/schovi/shelli/internal/daemon"
@@ -40,37 +43,152 @@ func runInfo(cmd *cobra.Command, args []string) error {
 		data, _ := json.MarshalIndent(info, "", "  ")
 		fmt.Println(string(data))
 	} else {
-		fmt.Printf("Session: %s\n", info.Name)
-		fmt.Printf("State:   %s\n", info.State)
-		fmt.Printf("PID:     %d\n", info.PID)
-		fmt.Printf("Command: %s\n", info.Command)
-		fmt.Printf("Created: %s\n", info.CreatedAt)
+		var f fields
+		f.add("Session", "%s", info.Name)
+		if info.Frozen {
+			f.add("State", "%s (%s since %s)", paintState(info.State), paintState("frozen"), info.FrozenSince)
+		} else {
+			f.add("State", "%s", paintState(info.State))
+		}
+		f.add("PID", "%d", info.PID)
+		f.add("Command", "%s", info.Command)
+		if age := formatAge(info.CreatedAt); age != "" {
+			f.add("Created", "%s (%s ago)", info.CreatedAt, age)
+		} else {
+			f.add("Created", "%s", info.CreatedAt)
+		}
 		if info.StoppedAt != "" {
-			fmt.Printf("Stopped: %s\n", info.StoppedAt)
+			f.add("Stopped", "%s", info.StoppedAt)
+		}
+		if info.ExitCode != nil {
+			if info.ExitSignal != "" {
+				f.add("Exit", "%d (%s)", *info.ExitCode, info.ExitSignal)
+			} else {
+				f.add("Exit", "%d", *info.ExitCode)
+			}
+		}
+		if info.EndReason != "" {
+			if info.EndError != "" {
+				f.add("Ended", "%s: %s", info.EndReason, info.EndError)
+			} else {
+				f.add("Ended", "%s", info.EndReason)
+			}
 		}
 		if info.Uptime > 0 {
-			fmt.Printf("Uptime:  %s\n", formatDuration(info.Uptime))
+			f.add("Uptime", "%s", formatDuration(info.Uptime))
+		}
+		if info.ClockSkew != 0 {
+			f.add("Wall", "%s (clock changed or system slept; skew %+.0fs)", formatDuration(info.WallUptime), info.ClockSkew)
+		}
+		if info.Idle > 0 {
+			f.add("Idle", "%s", formatDuration(info.Idle))
+		}
+		if info.BytesBuffered < 1024 {
+			f.add("Buffer", "%s", formatBytes(info.BytesBuffered))
+		} else {
+			f.add("Buffer", "%s (%d bytes)", formatBytes(info.BytesBuffered), info.BytesBuffered)
+		}
+		f.add("ReadPos", "%d", info.ReadPosition)
+		f.add("Size", "%dx%d", info.Cols, info.Rows)
+		if info.FrameHistory > 0 {
+			f.add("Frames", "%d kept", info.FrameHistory)
+		}
+		if len(info.FrameBoundaries) > 0 {
+			f.add("Bounds", "%s", strings.Join(info.FrameBoundaries, ", "))
+		}
+		if info.Scrollback > 0 {
+			f.add("Scroll", "%d rows", info.Scrollback)
+		}
+		if info.CaptureRaw != "" {
+			f.add("Capture", "%s", info.CaptureRaw)
+		}
+		if info.Workspace != "" {
+			f.add("Repo", "%s", info.Workspace)
+		}
+		if info.Cwd != "" {
+			f.add("Cwd", "%s", info.Cwd)
+		}
+		if info.Nice != nil {
+			f.add("Nice", "%d", *info.Nice)
+		}
+		if info.IOClass != "" {
+			f.add("IOnice", "%s", info.IOClass)
+		}
+		if l := info.Limits; l != nil {
+			var parts []string
+			if l.MemoryBytes > 0 {
+				parts = append(parts, "memory "+formatBytes(l.MemoryBytes))
+			}
+			if l.CPUSeconds > 0 {
+				parts = append(parts, fmt.Sprintf("cpu time %s", time.Duration(l.CPUSeconds)*time.Second))
+			}
+			if l.CPUPercent > 0 {
+				parts = append(parts, fmt.Sprintf("cpu %d%%", l.CPUPercent))
+			}
+			f.add("Limits", "%s", strings.Join(parts, ", "))
+			if l.Cgroup != "" {
+				f.add("Cgroup", "%s", l.Cgroup)
+			}
+		}
+		if info.Encoding != "" {
+			f.add("Charset", "%s", info.Encoding)
+		}
+		if info.Paused {
+			f.add("Paused", "since %s (%s dropped while paused)", info.PausedSince, formatBytes(info.PausedDropped))
+		} else if info.PausedDropped > 0 {
+			f.add("Paused", "no (%s dropped while paused)", formatBytes(info.PausedDropped))
+		}
+		if info.Swallowing {
+			f.add("Banner", "waiting for readiness (%s held back)", formatBytes(int64(len(info.Banner))))
+		} else if info.Banner != "" {
+			f.add("Banner", "%s held back (see --json)", formatBytes(int64(len(info.Banner))))
+		}
+		if info.MirrorInput {
+			f.add("Mirror", "sent input recorded in buffer")
+		}
+		if info.ResizeCrash {
+			f.add("Snapshot", "%s (crashed on a snapshot resize)", info.SnapshotMode)
+		} else if info.SnapshotMode != "" {
+			f.add("Snapshot", "%s", info.SnapshotMode)
+		}
+		for _, command := range info.OnExit {
+			f.add("OnExit", "%s", command)
+		}
+		if info.TerminalMode != "" {
+			f.add("Input", "%s mode", info.TerminalMode)
 		}
-		fmt.Printf("Buffer:  %d bytes\n", info.BytesBuffered)
-		fmt.Printf("ReadPos: %d\n", info.ReadPosition)
-		fmt.Printf("Size:    %dx%d\n", info.Cols, info.Rows)
-		if len(info.Cursors) > 0 {
+		if info.AltScreen {
+			f.add("Screen", "alternate (full-screen app)")
+		} else if info.State == string(daemon.StateRunning) {
+			f.add("Screen", "main")
+		}
+		if info.Health != nil && info.Health.Status != daemon.HealthExited {
+			f.add("Health", "%s", describeHealth(info.Health))
+		}
+		f.add("Traffic", "%s from PTY, %s to PTY", formatBytes(info.PTYBytesIn), formatBytes(info.PTYBytesOut))
+		f.add("Reads", "%d (%s returned)", info.Reads.Calls, formatBytes(info.Reads.Bytes))
+		if c := info.Capture; c != nil {
+			f.add("Sched", "%s capture: %d PTY reads in %d batches, largest %s, delay avg %.1fms max %.1fms",
+				c.Priority, c.Reads, c.Batches, formatBytes(c.MaxBatchBytes), c.AvgDelayMs, c.MaxDelayMs)
+		}
+		f.print(os.Stdout)
+		if len(info.Cursors) > 0 || len(info.CursorReads) > 0 {
 			fmt.Printf("Cursors:\n")
-			for name, pos := range info.Cursors {
-				fmt.Printf("  %s: %d\n", name, pos)
+			names := make([]string, 0, len(info.Cursors))
+			for name := range info.Cursors {
+				names = append(names, name)
+			}
+			for name := range info.CursorReads {
+				if _, ok := info.Cursors[name]; !ok {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				stat := info.CursorReads[name]
+				fmt.Printf("  %s: %d (%d reads, %s returned)\n", name, info.Cursors[name], stat.Calls, formatBytes(stat.Bytes))
 			}
 		}
 	}
 	return nil
 }
-
-func formatDuration(seconds float64) string {
-	d := time.Duration(seconds * float64(time.Second))
-	if d < time.Minute {
-		return fmt.Sprintf("%.1fs", seconds)
-	}
-	if d < time.Hour {
-		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
-	}
-	return fmt.Sprintf("%dh%dm%ds", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
-}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var jobsJsonFlag bool
+
+func init() {
+	jobsCmd.Flags().BoolVar(&jobsJsonFlag, "json", false, "Output as JSON")
+}
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs <name>",
+	Short: "List background jobs started with exec --background",
+	Long: `List the background jobs started in a session with 'exec --background':
+job number, PID, whether the process is still running, and the command.
+
+Use 'exec <name> --fg <job>' to bring one to the foreground. Jobs started by
+typing "&" directly are not tracked.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runJobs,
+}
+
+func runJobs(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	jobs, err := client.Jobs(name)
+	if err != nil {
+		return err
+	}
+
+	if jobsJsonFlag {
+		data, _ := json.MarshalIndent(jobs, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(jobs) == 0 {
+		fmt.Println("No background jobs")
+		return nil
+	}
+	t := newTable("JOB", "PID", "STATUS", "AGE", "COMMAND")
+	for _, j := range jobs {
+		t.row(fmt.Sprintf("[%d]", j.ID), strconv.Itoa(j.PID), paintState(j.Status), formatAge(j.Started), j.Command)
+	}
+	t.print(os.Stdout)
+	return nil
+}
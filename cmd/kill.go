@@ -8,10 +8,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var killJsonFlag bool
+var (
+	killJsonFlag   bool
+	killDryRunFlag bool
+)
 
 func init() {
 	killCmd.Flags().BoolVar(&killJsonFlag, "json", false, "Output as JSON")
+	killCmd.Flags().BoolVar(&killDryRunFlag, "dry-run", false, "Show what would be affected without doing it")
 }
 
 var killCmd = &cobra.Command{
@@ -21,7 +25,8 @@ var killCmd = &cobra.Command{
 
 To stop a session but keep output accessible for later reading, use 'stop' instead.
 
-This is a destructive operation and cannot be undone.`,
+This is a destructive operation and cannot be undone. Use --dry-run to see
+what it would affect first (also on stop and clear).`,
 	Args: cobra.ExactArgs(1),
 	RunE: runKill,
 }
@@ -34,6 +39,10 @@ func runKill(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("daemon: %w", err)
 	}
 
+	if killDryRunFlag {
+		return printDryRun(client, "kill", name, killJsonFlag)
+	}
+
 	if err := client.Kill(name); err != nil {
 		return err
 	}
@@ -50,3 +59,44 @@ func runKill(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+// printDryRun prints what action would do to session name, for the
+// --dry-run flag of stop, kill and clear.
+func printDryRun(client *daemon.Client, action, name string, asJSON bool) error {
+	impact, err := client.DryRun(action, name)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(impact, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if impact.NoOp {
+		fmt.Printf("%s %q would do nothing: it is %s\n", action, name, impact.State)
+		return nil
+	}
+	fmt.Printf("%s %q (%s, %s) would:\n", action, name, impact.State, impact.Command)
+	if impact.TerminatesProcess {
+		fmt.Printf("  terminate process %d\n", impact.PID)
+	}
+	if !impact.OutputKept {
+		fmt.Printf("  delete %s of output (%s unread)\n", formatBytes(impact.DiscardedBytes), formatBytes(impact.UnreadBytes))
+	}
+	if impact.OutputKept {
+		fmt.Printf("  keep %s of output readable", formatBytes(impact.BufferBytes))
+		if impact.RemovedAfterSeconds > 0 {
+			fmt.Printf(" for %s", formatDuration(impact.RemovedAfterSeconds))
+		}
+		fmt.Println()
+	}
+	if impact.Archived {
+		fmt.Printf("  leave its files in %s for shelli archive\n", impact.DataDir)
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"strconv"
 
 	"github.com/schovi/shelli/internal/daemon"
 	"github.com/spf13/cobra"
@@ -11,13 +13,32 @@ import (
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all sessions",
-	RunE:  runList,
+	Long: `List all sessions.
+
+Sessions created from inside a git repository are tagged with the repo root.
+Use --here to list only the sessions of the repository you are in.
+
+--verbose adds a HEALTH column: the state reports what the daemon last saw,
+while health checks that the child is not suspended (SIGSTOP) or a zombie
+and that its PTY still takes writes. See 'shelli health'.
+
+Sessions halted with 'shelli freeze' are listed as frozen. Stopped sessions
+show why they ended: exited, killed (by a signal not sent by stop), stopped,
+pty-error (reading the terminal failed; see 'shelli info') or
+daemon-shutdown.`,
+	RunE: runList,
 }
 
-var listJsonFlag bool
+var (
+	listJsonFlag    bool
+	listHereFlag    bool
+	listVerboseFlag bool
+)
 
 func init() {
 	listCmd.Flags().BoolVar(&listJsonFlag, "json", false, "Output as JSON")
+	listCmd.Flags().BoolVar(&listHereFlag, "here", false, "Only sessions created from the current git repository")
+	listCmd.Flags().BoolVarP(&listVerboseFlag, "verbose", "v", false, "Check and show each session's health")
 }
 
 func runList(cmd *cobra.Command, args []string) error {
@@ -26,11 +47,23 @@ func runList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("daemon: %w", err)
 	}
 
-	sessions, err := client.List()
+	list := client.List
+	if listVerboseFlag {
+		list = client.ListVerbose
+	}
+	sessions, err := list()
 	if err != nil {
 		return err
 	}
 
+	if listHereFlag {
+		workspace := daemon.CurrentWorkspace()
+		if workspace == "" {
+			return fmt.Errorf("--here: not inside a git repository")
+		}
+		sessions = daemon.FilterWorkspace(sessions, workspace)
+	}
+
 	if listJsonFlag {
 		data, err := json.MarshalIndent(sessions, "", "  ")
 		if err != nil {
@@ -42,10 +75,36 @@ func runList(cmd *cobra.Command, args []string) error {
 			fmt.Println("No sessions")
 			return nil
 		}
+		if listVerboseFlag {
+			t := newTable("NAME", "STATE", "HEALTH", "PID", "AGE", "COMMAND")
+			for _, s := range sessions {
+				health := ""
+				if s.Health != nil {
+					health = paintHealth(s.Health.Status)
+				}
+				t.row(s.Name, listState(s), health, strconv.Itoa(s.PID), formatAge(s.CreatedAt), s.Command)
+			}
+			t.print(os.Stdout)
+			return nil
+		}
+		t := newTable("NAME", "STATE", "PID", "AGE", "COMMAND")
 		for _, s := range sessions {
-			fmt.Printf("%s\t%s\t%d\t%s\n", s.Name, s.State, s.PID, s.Command)
+			t.row(s.Name, listState(s), strconv.Itoa(s.PID), formatAge(s.CreatedAt), s.Command)
 		}
+		t.print(os.Stdout)
 	}
 
 	return nil
 }
+
+// listState is the STATE column: frozen replaces running for sessions
+// halted with freeze, and stopped sessions say why they ended.
+func listState(s daemon.SessionInfo) string {
+	if s.Frozen {
+		return paintState("frozen")
+	}
+	if s.EndReason != "" && s.State != string(daemon.StateRunning) {
+		return paintState(s.State) + " (" + s.EndReason + ")"
+	}
+	return paintState(s.State)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	locatePositionFlag int64
+	locateLineFlag     int
+	locateNewlinesFlag string
+	locateJsonFlag     bool
+)
+
+func init() {
+	locateCmd.Flags().Int64Var(&locatePositionFlag, "position", 0, "Byte position to map to a line (as returned by read/exec)")
+	locateCmd.Flags().IntVar(&locateLineFlag, "line", 0, "Line number to map to a position (as returned by search)")
+	locateCmd.Flags().StringVar(&locateNewlinesFlag, "newlines", "", "Count lines as search does in this mode: raw (default), lf, display")
+	locateCmd.Flags().BoolVar(&locateJsonFlag, "json", false, "Output as JSON")
+}
+
+var locateCmd = &cobra.Command{
+	Use:   "locate <name> --position N | --line N",
+	Short: "Map a buffer position to a line number, or back",
+	Long: `Map a byte position in a session's output buffer (as returned by read or
+exec) to its line number and column, or a line number (as returned by search)
+to the position where the line starts. Lines are counted by the daemon, so the
+buffer is not downloaded.
+
+The result also gives the line's start and end offsets, ready for a ranged
+read (MCP read with offset and limit). Use the same --newlines mode as the
+search whose line numbers you are following.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLocate,
+}
+
+func runLocate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	hasPosition := cmd.Flags().Changed("position")
+	hasLine := cmd.Flags().Changed("line")
+	if hasPosition == hasLine {
+		return fmt.Errorf("exactly one of --position or --line is required")
+	}
+	if hasLine && locateLineFlag <= 0 {
+		return fmt.Errorf("--line must be positive")
+	}
+	if err := daemon.ValidateNewlines(locateNewlinesFlag); err != nil {
+		return err
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	lp, err := client.Locate(name, locatePositionFlag, locateLineFlag, locateNewlinesFlag)
+	if err != nil {
+		return err
+	}
+
+	if locateJsonFlag {
+		data, _ := json.MarshalIndent(lp, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Printf("Position: %d\n", lp.Position)
+	fmt.Printf("Line:     %d of %d\n", lp.Line, lp.TotalLines)
+	fmt.Printf("Column:   %d\n", lp.Column)
+	fmt.Printf("Span:     %d-%d\n", lp.LineStart, lp.LineEnd)
+	return nil
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Show daemon and session metrics",
+	Long: `Print the daemon's metrics in the Prometheus text format: requests and
+their latency by action, how waits (exec, wait_any, wait_exit) ended, and per
+session the PTY bytes read and written, truncations reported to readers and
+output in storage. Counters start from zero when the daemon restarts.
+
+To have Prometheus or an OpenTelemetry collector scrape them, start the daemon
+with --metrics-addr, which serves the same text over HTTP at ` + daemon.MetricsPath + `.
+
+Examples:
+  shelli metrics
+  shelli metrics | grep shelli_waits_total`,
+	Args: cobra.NoArgs,
+	RunE: runMetrics,
+}
+
+func runMetrics(cmd *cobra.Command, args []string) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	text, err := client.Metrics()
+	if err != nil {
+		return err
+	}
+	fmt.Print(text)
+	return nil
+}
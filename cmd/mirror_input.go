@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var mirrorInputJsonFlag bool
+
+func init() {
+	mirrorInputCmd.Flags().BoolVar(&mirrorInputJsonFlag, "json", false, "Output as JSON")
+}
+
+var mirrorInputCmd = &cobra.Command{
+	Use:   "mirror-input <name> on|off",
+	Short: "Record sent input in a session's output buffer",
+	Long: `Turn input mirroring on or off for a running session.
+
+While on, everything sent to the session (send, exec) is recorded inline in
+its buffer where it was sent, so the transcript shows input even for programs
+that do not echo it, such as password prompts. Records look like
+⟦input: ls -la\n⟧ in reverse video; control characters are written as \r, \n,
+\t, \e or \xNN. Pattern waits (exec, read --wait, wait) ignore them.
+
+Mirrored input includes anything typed into a password prompt.
+Not available for TUI sessions. Use 'create --mirror-input' to start with
+mirroring on.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMirrorInput,
+}
+
+func runMirrorInput(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	var on bool
+	switch args[1] {
+	case "on":
+		on = true
+	case "off":
+	default:
+		return fmt.Errorf("expected on or off, got %q", args[1])
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	if err := client.MirrorInput(name, on); err != nil {
+		return err
+	}
+
+	if mirrorInputJsonFlag {
+		data, _ := json.MarshalIndent(map[string]interface{}{
+			"name":         name,
+			"mirror_input": on,
+		}, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Printf("Input mirroring for session %q is %s\n", name, args[1])
+	return nil
+}
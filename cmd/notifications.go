@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	notificationsAfterFlag int
+	notificationsJsonFlag  bool
+)
+
+func init() {
+	notificationsCmd.Flags().IntVar(&notificationsAfterFlag, "after", 0, "Only show notifications with an ID above this")
+	notificationsCmd.Flags().BoolVar(&notificationsJsonFlag, "json", false, "Output as JSON")
+}
+
+var notificationsCmd = &cobra.Command{
+	Use:   "notifications <name>",
+	Short: "Show bells and desktop notifications a session sent",
+	Long: `Show bells and desktop notifications a session sent.
+
+BEL characters and OSC 9 / OSC 777 notification sequences are removed from the
+output buffer and recorded per session (the last 100). Poll for new ones with
+--after <last seen id>.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNotifications,
+}
+
+func runNotifications(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	notes, err := client.Notifications(name, notificationsAfterFlag)
+	if err != nil {
+		return err
+	}
+
+	if notificationsJsonFlag {
+		data, err := json.MarshalIndent(notes, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(notes) == 0 {
+		fmt.Println("No notifications")
+		return nil
+	}
+	for _, n := range notes {
+		switch {
+		case n.Kind == "bell":
+			fmt.Printf("%d\t%s\tbell\n", n.ID, n.At)
+		case n.Title != "":
+			fmt.Printf("%d\t%s\t%s: %s\n", n.ID, n.At, n.Title, n.Body)
+		default:
+			fmt.Printf("%d\t%s\t%s\n", n.ID, n.At, n.Body)
+		}
+	}
+	return nil
+}
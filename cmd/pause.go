@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var pauseJsonFlag bool
+
+func init() {
+	pauseCmd.Flags().BoolVar(&pauseJsonFlag, "json", false, "Output as JSON")
+}
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause <name>",
+	Short: "Stop storing a session's output",
+	Long: `Stop storing a running session's output until 'shelli resume'.
+
+The program keeps running and its output is still read from the PTY, so it
+never blocks; the output is just discarded and counted. Use it around a noisy
+phase (a dependency download, a verbose build step) that would bury the output
+you want to read. Bells, notifications, inline images and --capture-raw files still see
+everything.
+
+Not for TUI sessions, whose screen has to see all output.
+
+Examples:
+  shelli pause build
+  shelli exec build "npm install" --settle 3000
+  shelli resume build`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPause,
+}
+
+func runPause(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	if err := client.Pause(name); err != nil {
+		return err
+	}
+
+	if pauseJsonFlag {
+		data, _ := json.MarshalIndent(map[string]interface{}{
+			"name":   name,
+			"paused": true,
+		}, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Printf("Paused output capture of session %q\n", name)
+	return nil
+}
@@ -22,39 +22,102 @@ var readCmd = &cobra.Command{
 
 By default, returns new output since last read (instant).
 Use --all for all output from session start (instant).
-Use --wait or --settle for blocking read (returns new output).`,
+Use --wait or --settle for blocking read (returns new output).
+Use --wait-prompt to block until the output ends in an interactive prompt
+(shell, python, pdb/ipdb, psql, node) with the cursor right after it.
+Use --render to get the text as it appeared on screen: carriage-return
+overwrites, backspaces, and cursor movement are applied at the session width.
+Use --tail-bytes N for a cheap peek at the end of a huge buffer: the last N
+bytes, starting at a character and escape-sequence boundary.
+Use --newlines lf or display to normalize line endings before --head/--tail
+count lines (display applies carriage-return overwrites within each line).
+Use --since 2m or --since-ts <RFC 3339 time> for the output that arrived in a
+time window rather than at a byte offset.
+Use --around-bookmark NAME --context N for the N lines either side of a
+bookmark (see shelli bookmark), e.g. the failure a watch marked in a huge log.
+Use --lines for a JSON array of {line_number, text, offset, ts} records, ANSI
+stripped per line, numbered from the start of the buffer; --json wraps it
+with the position.
+Use --max-bytes N to page through a large backlog N bytes at a time: plain
+reads then move the read position only past the page returned, so reading
+again gets the next page. With --all or --offset POS the read position does
+not move; --json reports the position the next page starts at and whether
+there is more. --max-lines N pages by lines instead (at most 1 MiB a page).
+A page that has more after it prints a token on stderr (next in --json);
+--continue TOKEN reads the page after it, whatever the mode.
+
+If the daemon cannot be reached, plain reads fall back to the session files
+on disk (read-only; the read position is not advanced). Use --offline to
+skip the daemon entirely, e.g. to inspect sessions after a daemon crash.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runRead,
 }
 
 var (
-	readAllFlag       bool
-	readHeadFlag      int
-	readTailFlag      int
-	readWaitFlag      string
-	readSettleFlag    int
-	readTimeoutFlag   int
-	readStripAnsiFlag bool
-	readJsonFlag      bool
-	readFollowFlag    bool
-	readFollowMsFlag  int
-	readSnapshotFlag  bool
-	readCursorFlag    string
+	readAllFlag        bool
+	readHeadFlag       int
+	readTailFlag       int
+	readWaitFlag       string
+	readSettleFlag     int
+	readTimeoutFlag    int
+	readStripAnsiFlag  bool
+	readRenderFlag     bool
+	readJsonFlag       bool
+	readFollowFlag     bool
+	readFollowMsFlag   int
+	readSnapshotFlag   bool
+	readHoldSizeFlag   bool
+	readCursorFlag     string
+	readFrameFlag      int
+	readScrollbackFlag bool
+	readOfflineFlag    bool
+	readDataDirFlag    string
+	readNewlinesFlag   string
+	readTailBytesFlag  int
+	readSinceFlag      time.Duration
+	readSinceTsFlag    string
+	readWaitPromptFlag bool
+	readBookmarkFlag   string
+	readContextFlag    int
+	readLinesFlag      bool
+	readMaxBytesFlag   int64
+	readMaxLinesFlag   int
+	readOffsetFlag     int64
+	readContinueFlag   string
 )
 
 func init() {
 	readCmd.Flags().BoolVar(&readAllFlag, "all", false, "Read all output from session start")
 	readCmd.Flags().IntVar(&readHeadFlag, "head", 0, "Return first N lines of buffer")
 	readCmd.Flags().IntVar(&readTailFlag, "tail", 0, "Return last N lines of buffer")
+	readCmd.Flags().IntVar(&readTailBytesFlag, "tail-bytes", 0, "Return at most the last N bytes of buffer, cut at a safe boundary")
+	readCmd.Flags().DurationVar(&readSinceFlag, "since", 0, "Return output that arrived in the last duration (e.g. 2m, 90s)")
+	readCmd.Flags().StringVar(&readSinceTsFlag, "since-ts", "", "Return output that arrived at or after an RFC 3339 time")
+	readCmd.Flags().StringVar(&readBookmarkFlag, "around-bookmark", "", "Return the lines around a bookmark (see shelli bookmark)")
+	readCmd.Flags().IntVar(&readContextFlag, "context", 20, "With --around-bookmark: lines to show before and after the bookmarked line")
+	readCmd.Flags().Int64Var(&readMaxBytesFlag, "max-bytes", 0, "Return at most N bytes, as a page of a large backlog (see --offset)")
+	readCmd.Flags().IntVar(&readMaxLinesFlag, "max-lines", 0, "Return at most N lines, as a page of a large backlog")
+	readCmd.Flags().Int64Var(&readOffsetFlag, "offset", 0, "Read from this byte position of the buffer without moving the read position")
+	readCmd.Flags().StringVar(&readContinueFlag, "continue", "", "Read the page after the one that printed this token")
+	readCmd.Flags().BoolVar(&readLinesFlag, "lines", false, "Return JSON line records with line numbers and arrival times, ANSI stripped")
 	readCmd.Flags().StringVar(&readWaitFlag, "wait", "", "Wait for regex pattern match")
 	readCmd.Flags().IntVar(&readSettleFlag, "settle", 0, "Wait for N ms of silence")
+	readCmd.Flags().BoolVar(&readWaitPromptFlag, "wait-prompt", false, "Wait for an interactive prompt (shell, python, pdb, psql, node)")
 	readCmd.Flags().IntVar(&readTimeoutFlag, "timeout", 10, "Max wait time in seconds (for blocking modes)")
 	readCmd.Flags().BoolVar(&readStripAnsiFlag, "strip-ansi", false, "Strip ANSI escape codes")
+	readCmd.Flags().BoolVar(&readRenderFlag, "render", false, "Render output as it appeared on screen, at session width")
 	readCmd.Flags().BoolVar(&readJsonFlag, "json", false, "Output as JSON")
 	readCmd.Flags().BoolVarP(&readFollowFlag, "follow", "f", false, "Follow output continuously (like tail -f)")
 	readCmd.Flags().IntVar(&readFollowMsFlag, "follow-ms", 100, "Poll interval for --follow in milliseconds")
+	readCmd.Flags().MarkDeprecated("follow-ms", "--follow output is now pushed by the daemon") //nolint:errcheck // the flag exists
 	readCmd.Flags().BoolVar(&readSnapshotFlag, "snapshot", false, "Force TUI redraw and read clean frame (TUI sessions only)")
+	readCmd.Flags().BoolVar(&readHoldSizeFlag, "hold-size", false, "With --snapshot: don't resize the PTY, settle on the emulator screen (for externally watched sessions)")
 	readCmd.Flags().StringVar(&readCursorFlag, "cursor", "", "Named cursor for per-consumer read tracking")
+	readCmd.Flags().IntVar(&readFrameFlag, "frame", 0, "Read a past TUI frame (-1 = most recent, -2 = one before, ...)")
+	readCmd.Flags().BoolVar(&readScrollbackFlag, "screen-scrollback", false, "Read TUI scrollback rows followed by the current screen (TUI sessions only)")
+	readCmd.Flags().BoolVar(&readOfflineFlag, "offline", false, "Read session files directly without the daemon (read-only)")
+	readCmd.Flags().StringVar(&readNewlinesFlag, "newlines", "", "Normalize line endings before --head/--tail: raw (default), lf, or display")
+	readCmd.Flags().StringVar(&readDataDirFlag, "data-dir", "", "Session files directory for offline reads (default: /tmp/shelli-{uid}/data)")
 }
 
 func runRead(cmd *cobra.Command, args []string) error {
@@ -62,7 +125,7 @@ func runRead(cmd *cobra.Command, args []string) error {
 
 	hasWait := readWaitFlag != ""
 	hasSettle := readSettleFlag > 0
-	blocking := hasWait || hasSettle
+	blocking := hasWait || hasSettle || readWaitPromptFlag
 
 	modeCount := 0
 	if readAllFlag {
@@ -88,11 +151,119 @@ func runRead(cmd *cobra.Command, args []string) error {
 	if hasWait && hasSettle {
 		return fmt.Errorf("--wait and --settle are mutually exclusive")
 	}
+	if readWaitPromptFlag && (hasWait || hasSettle) {
+		return fmt.Errorf("--wait-prompt cannot be combined with --wait or --settle")
+	}
+
+	if readHoldSizeFlag && !readSnapshotFlag {
+		return fmt.Errorf("--hold-size requires --snapshot")
+	}
 
 	if readCursorFlag != "" && (readSnapshotFlag || readFollowFlag) {
 		return fmt.Errorf("--cursor cannot be combined with --snapshot or --follow")
 	}
 
+	if readRenderFlag && (readFrameFlag != 0 || readScrollbackFlag || readSnapshotFlag || readFollowFlag) {
+		return fmt.Errorf("--render cannot be combined with --frame, --screen-scrollback, --snapshot, or --follow")
+	}
+
+	if err := daemon.ValidateNewlines(readNewlinesFlag); err != nil {
+		return err
+	}
+	if readNewlinesFlag != "" && (readRenderFlag || readFrameFlag != 0 || readScrollbackFlag || readSnapshotFlag || readFollowFlag) {
+		return fmt.Errorf("--newlines cannot be combined with --render, --frame, --screen-scrollback, --snapshot, or --follow")
+	}
+
+	if readMaxBytesFlag != 0 || readMaxLinesFlag != 0 || cmd.Flags().Changed("offset") || readContinueFlag != "" {
+		if readMaxBytesFlag < 0 || readMaxLinesFlag < 0 || readOffsetFlag < 0 {
+			return fmt.Errorf("--max-bytes, --max-lines, and --offset must not be negative")
+		}
+		if readHeadFlag > 0 || readTailFlag > 0 || blocking || readLinesFlag || readSinceFlag != 0 || readSinceTsFlag != "" || readBookmarkFlag != "" || readTailBytesFlag != 0 || readFollowFlag || readSnapshotFlag || readFrameFlag != 0 || readScrollbackFlag || readOfflineFlag || readNewlinesFlag != "" {
+			return fmt.Errorf("--max-bytes, --max-lines, --offset, and --continue cannot be combined with --head, --tail, --wait, --settle, --wait-prompt, --lines, --since, --since-ts, --around-bookmark, --tail-bytes, --follow, --snapshot, --frame, --screen-scrollback, --offline, or --newlines")
+		}
+		if cmd.Flags().Changed("offset") && (readAllFlag || readCursorFlag != "") {
+			return fmt.Errorf("--offset cannot be combined with --all or --cursor")
+		}
+		if readContinueFlag != "" && (readAllFlag || readCursorFlag != "" || cmd.Flags().Changed("offset")) {
+			return fmt.Errorf("--continue cannot be combined with --all, --cursor, or --offset")
+		}
+		return runReadPage(name, cmd.Flags().Changed("offset"))
+	}
+
+	if readLinesFlag {
+		if blocking || readSinceFlag != 0 || readSinceTsFlag != "" || readBookmarkFlag != "" || readTailBytesFlag != 0 || readFollowFlag || readSnapshotFlag || readFrameFlag != 0 || readScrollbackFlag || readOfflineFlag || readRenderFlag {
+			return fmt.Errorf("--lines cannot be combined with --wait, --settle, --wait-prompt, --since, --since-ts, --around-bookmark, --tail-bytes, --follow, --snapshot, --frame, --screen-scrollback, --offline, or --render")
+		}
+		return runReadLines(name)
+	}
+
+	if readSinceFlag < 0 {
+		return fmt.Errorf("--since requires a positive duration")
+	}
+	if readSinceFlag > 0 || readSinceTsFlag != "" {
+		if readSinceFlag > 0 && readSinceTsFlag != "" {
+			return fmt.Errorf("--since and --since-ts are mutually exclusive")
+		}
+		if readAllFlag || readTailBytesFlag != 0 || blocking || readFollowFlag || readSnapshotFlag || readFrameFlag != 0 || readScrollbackFlag || readCursorFlag != "" || readOfflineFlag {
+			return fmt.Errorf("--since cannot be combined with --all, --tail-bytes, --wait, --settle, --follow, --snapshot, --frame, --screen-scrollback, --cursor, or --offline")
+		}
+		since := time.Now().Add(-readSinceFlag)
+		if readSinceTsFlag != "" {
+			t, err := time.Parse(time.RFC3339Nano, readSinceTsFlag)
+			if err != nil {
+				return fmt.Errorf("invalid --since-ts %q: want an RFC 3339 time like 2025-01-02T15:04:05Z", readSinceTsFlag)
+			}
+			since = t
+		}
+		return runReadSince(name, since)
+	}
+
+	if readBookmarkFlag != "" {
+		if readSinceFlag > 0 || readSinceTsFlag != "" || readAllFlag || readTailBytesFlag != 0 || blocking || readFollowFlag || readSnapshotFlag || readFrameFlag != 0 || readScrollbackFlag || readCursorFlag != "" || readOfflineFlag {
+			return fmt.Errorf("--around-bookmark cannot be combined with --since, --all, --tail-bytes, --wait, --settle, --follow, --snapshot, --frame, --screen-scrollback, --cursor, or --offline")
+		}
+		if readContextFlag < 0 {
+			return fmt.Errorf("--context must not be negative")
+		}
+		return runReadAroundBookmark(name)
+	}
+	if cmd.Flags().Changed("context") {
+		return fmt.Errorf("--context requires --around-bookmark")
+	}
+
+	if readTailBytesFlag < 0 {
+		return fmt.Errorf("--tail-bytes requires a positive integer")
+	}
+	if readTailBytesFlag > 0 {
+		if readAllFlag || readHeadFlag > 0 || readTailFlag > 0 || blocking || readFollowFlag || readSnapshotFlag || readFrameFlag != 0 || readScrollbackFlag || readCursorFlag != "" || readNewlinesFlag != "" {
+			return fmt.Errorf("--tail-bytes cannot be combined with --all, --head, --tail, --wait, --settle, --follow, --snapshot, --frame, --screen-scrollback, --cursor, or --newlines")
+		}
+		if !readOfflineFlag {
+			return runReadTailBytes(name)
+		}
+	}
+
+	if readOfflineFlag {
+		if blocking || readFollowFlag || readSnapshotFlag || readFrameFlag != 0 || readScrollbackFlag {
+			return fmt.Errorf("--offline cannot be combined with --wait, --settle, --follow, --snapshot, --frame, or --screen-scrollback")
+		}
+		return runReadOffline(name)
+	}
+
+	if readFrameFlag != 0 {
+		if readSnapshotFlag || readFollowFlag || readAllFlag || blocking || readCursorFlag != "" {
+			return fmt.Errorf("--frame cannot be combined with --snapshot, --follow, --all, --wait, --settle, or --cursor")
+		}
+		return runReadFrame(name)
+	}
+
+	if readScrollbackFlag {
+		if readSnapshotFlag || readFollowFlag || readAllFlag || blocking || readCursorFlag != "" {
+			return fmt.Errorf("--screen-scrollback cannot be combined with --snapshot, --follow, --all, --wait, --settle, or --cursor")
+		}
+		return runReadScrollback(name)
+	}
+
 	if readSnapshotFlag {
 		if readFollowFlag || readAllFlag || hasWait {
 			return fmt.Errorf("--snapshot cannot be combined with --follow, --all, or --wait")
@@ -109,11 +280,16 @@ func runRead(cmd *cobra.Command, args []string) error {
 
 	client := daemon.NewClient()
 	if err := client.EnsureDaemon(); err != nil {
-		return fmt.Errorf("daemon: %w", err)
+		if blocking {
+			return fmt.Errorf("daemon: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "daemon unavailable (%v), reading session files offline\n", err)
+		return runReadOffline(name)
 	}
 
 	var output string
 	var pos int
+	var truncations int
 	var err error
 
 	headLines := readHeadFlag
@@ -133,31 +309,33 @@ func runRead(cmd *cobra.Command, args []string) error {
 				TimeoutSec:    readTimeoutFlag,
 				StartPosition: startPos,
 				SizeFunc:      func() (int, error) { return client.Size(name) },
+				MatchFilter:   daemon.RemoveInputMirror,
+				WaitForPrompt: readWaitPromptFlag,
+				CursorFunc:    func() (string, int, error) { return client.CursorLine(name) },
 			},
 		)
 		if err == nil {
+			output = daemon.NormalizeNewlines(output, readNewlinesFlag)
 			if headLines > 0 || tailLines > 0 {
 				output = daemon.LimitLines(output, headLines, tailLines)
 			}
-			if readCursorFlag != "" {
-				if _, _, advErr := client.ReadWithCursor(name, "new", readCursorFlag, 0, 0); advErr != nil {
+			advanced, advErr := client.ReadDetailed(name, "new", readCursorFlag, 0, 0)
+			if advErr != nil {
+				if readCursorFlag != "" {
 					return fmt.Errorf("advance cursor: %w", advErr)
 				}
-			} else {
-				if _, _, advErr := client.Read(name, "new", 0, 0); advErr != nil {
-					return fmt.Errorf("advance read position: %w", advErr)
-				}
+				return fmt.Errorf("advance read position: %w", advErr)
 			}
+			truncations = advanced.Truncations
 		}
 	} else {
 		mode := daemon.ReadModeNew
 		if readAllFlag || readHeadFlag > 0 || readTailFlag > 0 {
 			mode = daemon.ReadModeAll
 		}
-		if readCursorFlag != "" {
-			output, pos, err = client.ReadWithCursor(name, mode, readCursorFlag, headLines, tailLines)
-		} else {
-			output, pos, err = client.Read(name, mode, headLines, tailLines)
+		var result *daemon.ReadResult
+		if result, err = client.ReadNormalized(name, mode, readCursorFlag, readNewlinesFlag, headLines, tailLines); err == nil {
+			output, pos, truncations = result.Output, result.Position, result.Truncations
 		}
 	}
 
@@ -165,7 +343,11 @@ func runRead(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if readStripAnsiFlag {
+	if readRenderFlag {
+		if output, err = renderOutput(client, name, output); err != nil {
+			return err
+		}
+	} else if readStripAnsiFlag {
 		output = vterm.StripDefault(output)
 	}
 
@@ -174,18 +356,90 @@ func runRead(cmd *cobra.Command, args []string) error {
 			"output":   output,
 			"position": pos,
 		}
+		if truncations > 0 {
+			out["truncations_since_last_read"] = truncations
+		}
 		data, err := json.MarshalIndent(out, "", "  ")
 		if err != nil {
 			return fmt.Errorf("marshal output: %w", err)
 		}
 		fmt.Println(string(data))
 	} else {
+		if truncations > 0 {
+			fmt.Fprintf(os.Stderr, "warning: unread output was dropped %d time(s) since the last read (clear or buffer limit); positions were reset\n", truncations)
+		}
 		fmt.Print(output)
 	}
 
 	return nil
 }
 
+func runReadOffline(name string) error {
+	dataDir := readDataDirFlag
+	if dataDir == "" {
+		var err error
+		if dataDir, err = daemon.DefaultDataDir(); err != nil {
+			return fmt.Errorf("get data dir: %w", err)
+		}
+	}
+
+	mode := daemon.ReadModeNew
+	if readAllFlag || readHeadFlag > 0 || readTailFlag > 0 || readTailBytesFlag > 0 {
+		mode = daemon.ReadModeAll
+	}
+	// Line limits have to count normalized lines, so apply them here.
+	head, tail := readHeadFlag, readTailFlag
+	if readNewlinesFlag != "" {
+		head, tail = 0, 0
+	}
+	result, err := daemon.OfflineRead(dataDir, name, mode, readCursorFlag, head, tail)
+	if err != nil {
+		return err
+	}
+
+	output := result.Output
+	if readTailBytesFlag > 0 {
+		output = string(daemon.SafeTailBytes([]byte(output), readTailBytesFlag))
+	}
+	if readNewlinesFlag != "" {
+		output = daemon.NormalizeNewlines(output, readNewlinesFlag)
+		if readHeadFlag > 0 || readTailFlag > 0 {
+			output = daemon.LimitLines(output, readHeadFlag, readTailFlag)
+		}
+	}
+	if readRenderFlag {
+		output = vterm.Render(output, result.Cols)
+	} else if readStripAnsiFlag {
+		output = vterm.StripDefault(output)
+	}
+
+	if readJsonFlag {
+		out := map[string]interface{}{
+			"output":   output,
+			"position": result.Position,
+			"state":    result.State,
+			"offline":  true,
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(output)
+	}
+	return nil
+}
+
+// renderOutput renders output at the session's terminal width.
+func renderOutput(client *daemon.Client, name, output string) (string, error) {
+	info, err := client.Info(name)
+	if err != nil {
+		return "", err
+	}
+	return vterm.Render(output, info.Cols), nil
+}
+
 func runReadSnapshot(name string) error {
 	client := daemon.NewClient()
 	if err := client.EnsureDaemon(); err != nil {
@@ -193,7 +447,297 @@ func runReadSnapshot(name string) error {
 	}
 
 	settleMs := readSettleFlag
-	output, pos, err := client.Snapshot(name, settleMs, readTimeoutFlag, readHeadFlag, readTailFlag)
+	snap, err := client.SnapshotDetailed(name, settleMs, readTimeoutFlag, readHeadFlag, readTailFlag, readHoldSizeFlag)
+	if err != nil {
+		return err
+	}
+
+	output := snap.Output
+	if readStripAnsiFlag {
+		output = vterm.StripDefault(output)
+	}
+
+	if readJsonFlag {
+		out := map[string]interface{}{
+			"output":         output,
+			"position":       snap.Position,
+			"settled":        snap.Settled,
+			"waited_ms":      snap.WaitedMs,
+			"resize_cycles":  snap.ResizeCycles,
+			"bytes_captured": snap.BytesCaptured,
+		}
+		if snap.FromFrame {
+			out["from_frame"] = true
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(output)
+		if !snap.Settled {
+			fmt.Fprintf(os.Stderr, "\nwarning: screen did not settle within %s (%s captured); it may be mid-redraw\n", formatDuration(float64(snap.WaitedMs)/1000), formatBytes(snap.BytesCaptured))
+		}
+	}
+
+	return nil
+}
+
+func runReadPage(name string, fromOffset bool) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	mode := daemon.ReadModeNew
+	if readAllFlag {
+		mode = daemon.ReadModeAll
+	} else if fromOffset {
+		mode = daemon.ReadModeRange
+	}
+	result, err := client.ReadPage(name, daemon.PageOptions{
+		Mode:     mode,
+		Cursor:   readCursorFlag,
+		Offset:   readOffsetFlag,
+		MaxBytes: readMaxBytesFlag,
+		MaxLines: readMaxLinesFlag,
+		Continue: readContinueFlag,
+	})
+	if err != nil {
+		return err
+	}
+
+	output := result.Output
+	if readRenderFlag {
+		if output, err = renderOutput(client, name, output); err != nil {
+			return err
+		}
+	} else if readStripAnsiFlag {
+		output = vterm.StripDefault(output)
+	}
+
+	if readJsonFlag {
+		out := map[string]interface{}{
+			"output":   output,
+			"position": result.Position,
+		}
+		if readMaxBytesFlag > 0 || readMaxLinesFlag > 0 || readContinueFlag != "" {
+			out["more"] = result.More
+		}
+		if result.Next != "" {
+			out["next"] = result.Next
+		}
+		if result.Truncations > 0 {
+			out["truncations_since_last_read"] = result.Truncations
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if result.Truncations > 0 {
+		fmt.Fprintf(os.Stderr, "warning: unread output was dropped %d time(s) since the last read (clear or buffer limit); positions were reset\n", result.Truncations)
+	}
+	fmt.Print(output)
+	if result.More {
+		fmt.Fprintf(os.Stderr, "\nmore output from position %d (--continue %s)\n", result.Position, result.Next)
+	}
+	return nil
+}
+
+func runReadTailBytes(name string) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		fmt.Fprintf(os.Stderr, "daemon unavailable (%v), reading session files offline\n", err)
+		return runReadOffline(name)
+	}
+
+	output, pos, err := client.ReadTailBytes(name, readTailBytesFlag)
+	if err != nil {
+		return err
+	}
+
+	if readRenderFlag {
+		if output, err = renderOutput(client, name, output); err != nil {
+			return err
+		}
+	} else if readStripAnsiFlag {
+		output = vterm.StripDefault(output)
+	}
+
+	if readJsonFlag {
+		out := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(output)
+	}
+
+	return nil
+}
+
+func runReadSince(name string, since time.Time) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	output, pos, err := client.ReadSince(name, since)
+	if err != nil {
+		return err
+	}
+
+	output = daemon.NormalizeNewlines(output, readNewlinesFlag)
+	if readHeadFlag > 0 || readTailFlag > 0 {
+		output = daemon.LimitLines(output, readHeadFlag, readTailFlag)
+	}
+	if readRenderFlag {
+		if output, err = renderOutput(client, name, output); err != nil {
+			return err
+		}
+	} else if readStripAnsiFlag {
+		output = vterm.StripDefault(output)
+	}
+
+	if readJsonFlag {
+		out := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+			"since":    since.Format(time.RFC3339Nano),
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(output)
+	}
+
+	return nil
+}
+
+func runReadLines(name string) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	mode := daemon.ReadModeNew
+	if readAllFlag || readHeadFlag > 0 || readTailFlag > 0 {
+		mode = daemon.ReadModeAll
+	}
+	result, err := client.ReadLines(name, mode, readCursorFlag, readNewlinesFlag, readHeadFlag, readTailFlag)
+	if err != nil {
+		return err
+	}
+
+	var out interface{} = result.Lines
+	if readJsonFlag {
+		out = result
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal output: %w", err)
+	}
+	fmt.Println(string(data))
+	if result.Truncations > 0 && !readJsonFlag {
+		fmt.Fprintf(os.Stderr, "warning: unread output was dropped %d time(s) since the last read (clear or buffer limit); positions were reset\n", result.Truncations)
+	}
+	return nil
+}
+
+func runReadAroundBookmark(name string) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	output, pos, bookmark, err := client.ReadAroundBookmark(name, readBookmarkFlag, readContextFlag, readContextFlag)
+	if err != nil {
+		return err
+	}
+
+	output = daemon.NormalizeNewlines(output, readNewlinesFlag)
+	if readHeadFlag > 0 || readTailFlag > 0 {
+		output = daemon.LimitLines(output, readHeadFlag, readTailFlag)
+	}
+	if readRenderFlag {
+		if output, err = renderOutput(client, name, output); err != nil {
+			return err
+		}
+	} else if readStripAnsiFlag {
+		output = vterm.StripDefault(output)
+	}
+
+	if readJsonFlag {
+		out := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+			"bookmark": bookmark,
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(output)
+	}
+
+	return nil
+}
+
+func runReadFrame(name string) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	output, pos, err := client.ReadFrame(name, readFrameFlag, readHeadFlag, readTailFlag)
+	if err != nil {
+		return err
+	}
+
+	if readStripAnsiFlag {
+		output = vterm.StripDefault(output)
+	}
+
+	if readJsonFlag {
+		out := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+			"frame":    readFrameFlag,
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(output)
+	}
+
+	return nil
+}
+
+func runReadScrollback(name string) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	output, pos, err := client.ReadScrollback(name, readHeadFlag, readTailFlag)
 	if err != nil {
 		return err
 	}
@@ -235,28 +779,12 @@ func runReadFollow(name string) error {
 		cancel()
 	}()
 
-	if readFollowMsFlag <= 0 {
-		readFollowMsFlag = 100
-	}
-	pollInterval := time.Duration(readFollowMsFlag) * time.Millisecond
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-ticker.C:
-			output, _, err := client.Read(name, daemon.ReadModeNew, 0, 0)
-			if err != nil {
-				return err
-			}
-			if output != "" {
-				if readStripAnsiFlag {
-					output = vterm.StripDefault(output)
-				}
-				fmt.Print(output)
-			}
+	return client.Stream(ctx, name, "", func(chunk daemon.StreamChunk) error {
+		output := chunk.Output
+		if readStripAnsiFlag {
+			output = vterm.StripDefault(output)
 		}
-	}
+		fmt.Print(output)
+		return nil
+	})
 }
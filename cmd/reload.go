@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var reloadJsonFlag bool
+
+func init() {
+	reloadCmd.Flags().BoolVar(&reloadJsonFlag, "json", false, "Output as JSON")
+}
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Make the daemon re-read its config file",
+	Long: `Re-read the daemon config file (same as sending the daemon SIGHUP).
+Hooks and stopped_ttl apply at once; a new max_output applies to each
+session's buffer from its next write. Flags the daemon was started with
+still take precedence over the file.`,
+	Args: cobra.NoArgs,
+	RunE: runReload,
+}
+
+func runReload(cmd *cobra.Command, args []string) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	result, err := client.Reload()
+	if err != nil {
+		return err
+	}
+
+	if reloadJsonFlag {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(result.Changed) == 0 {
+		fmt.Printf("Reloaded %s: no changes\n", result.Config)
+	} else {
+		fmt.Printf("Reloaded %s: changed %s\n", result.Config, strings.Join(result.Changed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// Human output shared by commands: aligned tables and key/value blocks,
+// colored states, humanized sizes and durations. JSON output bypasses it.
+
+var noColorFlag bool
+
+// ANSI SGR codes used by paint.
+const (
+	colorBold  = "1"
+	colorRed   = "31"
+	colorGreen = "32"
+	colorGrey  = "90"
+)
+
+// colorEnabled reports whether output may be colored: stdout is a terminal,
+// NO_COLOR is unset (https://no-color.org) and --no-color was not given.
+func colorEnabled() bool {
+	if noColorFlag || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func paint(s, code string) string {
+	if s == "" || !colorEnabled() {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// paintState colors a session or job state: green while running, grey once
+// it has ended.
+func paintState(state string) string {
+	switch state {
+	case "running":
+		return paint(state, colorGreen)
+	case "stopped", "done":
+		return paint(state, colorGrey)
+	case "frozen":
+		return paint(state, colorRed)
+	}
+	return state
+}
+
+// paintHealth colors a health status: green when ok, grey once exited, red
+// otherwise.
+func paintHealth(status string) string {
+	switch status {
+	case daemon.HealthOK:
+		return paint(status, colorGreen)
+	case daemon.HealthExited:
+		return paint(status, colorGrey)
+	}
+	return paint(status, colorRed)
+}
+
+// describeHealth is a one-line health summary, e.g. "ok (S, idle 2m0s)".
+func describeHealth(h *daemon.Health) string {
+	if h.Status == daemon.HealthExited {
+		return paintHealth(h.Status)
+	}
+	var notes []string
+	if h.ProcessState != "" {
+		notes = append(notes, "process "+h.ProcessState)
+	}
+	if !h.PTYOK {
+		notes = append(notes, "pty broken")
+	}
+	if h.IdleSeconds >= 1 {
+		notes = append(notes, "idle "+formatDuration(h.IdleSeconds))
+	}
+	s := paintHealth(h.Status)
+	if len(notes) > 0 {
+		s += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return s
+}
+
+// visibleWidth is the number of characters s takes on screen.
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(vterm.StripDefault(s))
+}
+
+// table prints rows under a header, padding columns to their widest cell.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func newTable(header ...string) *table {
+	return &table{header: header}
+}
+
+func (t *table) row(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+func (t *table) print(w io.Writer) {
+	widths := make([]int, len(t.header))
+	for _, cells := range append([][]string{t.header}, t.rows...) {
+		for i, cell := range cells {
+			widths[i] = max(widths[i], visibleWidth(cell))
+		}
+	}
+	line := func(cells []string, style func(string) string) {
+		var b strings.Builder
+		for i, cell := range cells {
+			if i == len(cells)-1 {
+				b.WriteString(style(cell)) // no trailing padding
+				break
+			}
+			b.WriteString(style(cell))
+			b.WriteString(strings.Repeat(" ", widths[i]-visibleWidth(cell)+2))
+		}
+		fmt.Fprintln(w, b.String())
+	}
+	line(t.header, func(s string) string { return paint(s, colorBold) })
+	for _, cells := range t.rows {
+		line(cells, func(s string) string { return s })
+	}
+}
+
+// fields prints "Key: value" lines with the values aligned.
+type fields struct {
+	keys   []string
+	values []string
+}
+
+func (f *fields) add(key, format string, args ...interface{}) {
+	f.keys = append(f.keys, key)
+	f.values = append(f.values, fmt.Sprintf(format, args...))
+}
+
+func (f *fields) print(w io.Writer) {
+	width := 0
+	for _, k := range f.keys {
+		width = max(width, len(k))
+	}
+	for i, k := range f.keys {
+		fmt.Fprintf(w, "%s:%s %s\n", k, strings.Repeat(" ", width-len(k)), f.values[i])
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+func formatDuration(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	if d < time.Minute {
+		return fmt.Sprintf("%.1fs", seconds)
+	}
+	if d < time.Hour {
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh%dm%ds", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%dd%dh%dm", int(d.Hours())/24, int(d.Hours())%24, int(d.Minutes())%60)
+}
+
+// formatAge is how long ago an RFC 3339 timestamp was, or "" if it does not
+// parse.
+func formatAge(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return ""
+	}
+	d := time.Since(t)
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	return formatDuration(d.Seconds())
+}
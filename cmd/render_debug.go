@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/schovi/shelli/internal/vterm"
+	"github.com/spf13/cobra"
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Run captured bytes through the rendering pipeline",
+	Long: `Run captured terminal output through shelli's rendering pipeline outside the
+daemon and print the result. Use it to reproduce a rendering bug with the exact
+bytes that caused it, without a live session.
+
+Modes:
+  strip    what --strip-ansi returns (regex strip, or the emulator when the
+           output moves the cursor or edits the screen)
+  render   what read --render returns (carriage returns, backspaces and
+           cursor movement applied)
+  screen   what a TUI session's screen shows after the output (--rows high;
+           --styled keeps colors)
+
+Raw output can be captured with 'shelli read <name> --all > capture.bin' or
+taken from a session's .out file.
+
+Examples:
+  shelli render --input capture.bin
+  shelli render --mode screen --cols 120 --rows 40 --input capture.bin
+  cat capture.bin | shelli render --mode render --cols 60`,
+	Args: cobra.NoArgs,
+	RunE: runRender,
+}
+
+var (
+	renderInputFlag  string
+	renderModeFlag   string
+	renderColsFlag   int
+	renderRowsFlag   int
+	renderStyledFlag bool
+)
+
+func init() {
+	renderCmd.Flags().StringVar(&renderInputFlag, "input", "-", "File with the captured bytes (\"-\" for stdin)")
+	renderCmd.Flags().StringVar(&renderModeFlag, "mode", "strip", "Pipeline to run: strip, render or screen")
+	renderCmd.Flags().IntVar(&renderColsFlag, "cols", 80, "Terminal width")
+	renderCmd.Flags().IntVar(&renderRowsFlag, "rows", 24, "Terminal height (screen mode)")
+	renderCmd.Flags().BoolVar(&renderStyledFlag, "styled", false, "Keep colors and styles (screen mode)")
+}
+
+func runRender(cmd *cobra.Command, args []string) error {
+	if renderColsFlag <= 0 || renderRowsFlag <= 0 {
+		return fmt.Errorf("--cols and --rows must be positive")
+	}
+	switch renderModeFlag {
+	case "strip", "render", "screen":
+	default:
+		return fmt.Errorf("unknown --mode %q (use strip, render or screen)", renderModeFlag)
+	}
+	if renderStyledFlag && renderModeFlag != "screen" {
+		return fmt.Errorf("--styled requires --mode screen")
+	}
+
+	var raw []byte
+	var err error
+	if renderInputFlag == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(renderInputFlag)
+	}
+	if err != nil {
+		return fmt.Errorf("read input: %w", err)
+	}
+
+	switch renderModeFlag {
+	case "strip":
+		fmt.Print(vterm.Strip(string(raw), renderColsFlag))
+	case "render":
+		fmt.Print(vterm.Render(string(raw), renderColsFlag))
+	case "screen":
+		screen := vterm.New(renderColsFlag, renderRowsFlag)
+		defer screen.Close()
+		go screen.ReadResponses(io.Discard)
+		screen.Write(raw)
+		if renderStyledFlag {
+			fmt.Print(screen.Render())
+		} else {
+			fmt.Println(screen.String())
+		}
+	}
+	return nil
+}
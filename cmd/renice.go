@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reniceNiceFlag   int
+	reniceIOniceFlag string
+	reniceJsonFlag   bool
+)
+
+func init() {
+	reniceCmd.Flags().IntVar(&reniceNiceFlag, "nice", 0, "CPU niceness (-20 to 19; lowering it needs privileges)")
+	reniceCmd.Flags().StringVar(&reniceIOniceFlag, "ionice", "", "I/O class: idle, best-effort[:0-7], realtime[:0-7] or none (Linux only)")
+	reniceCmd.Flags().BoolVar(&reniceJsonFlag, "json", false, "Output as JSON")
+}
+
+var reniceCmd = &cobra.Command{
+	Use:   "renice <name>",
+	Short: "Change a session's CPU and I/O priority",
+	Long:  `Change the niceness and I/O class of a running session's process group. At least one of --nice or --ionice must be specified.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRenice,
+}
+
+func runRenice(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	var nice *int
+	if cmd.Flags().Changed("nice") {
+		nice = &reniceNiceFlag
+	}
+	if nice == nil && reniceIOniceFlag == "" {
+		return fmt.Errorf("at least one of --nice or --ionice is required")
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	if err := client.Renice(name, nice, reniceIOniceFlag); err != nil {
+		return err
+	}
+
+	if reniceJsonFlag {
+		out := map[string]interface{}{
+			"name":   name,
+			"status": "reniced",
+		}
+		if nice != nil {
+			out["nice"] = *nice
+		}
+		if reniceIOniceFlag != "" {
+			out["io_class"] = reniceIOniceFlag
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	switch {
+	case nice != nil && reniceIOniceFlag != "":
+		fmt.Printf("Reniced session %q to nice %d, ionice %s\n", name, *nice, reniceIOniceFlag)
+	case nice != nil:
+		fmt.Printf("Reniced session %q to nice %d\n", name, *nice)
+	default:
+		fmt.Printf("Reniced session %q to ionice %s\n", name, reniceIOniceFlag)
+	}
+	return nil
+}
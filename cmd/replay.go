@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/schovi/shelli/internal/vterm"
+	"github.com/spf13/cobra"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <file|name>",
+	Short: "Replay recorded output frame by frame, or a session's input",
+	Long: `Replay session output into a local terminal emulator and render it frame by frame.
+
+The source can be a bundle created by 'export-session', a raw output file
+(e.g. a session's .out file), or the name of a session in the daemon.
+
+Frames are cut at redraw sequences (clear screen, cursor home, sync update);
+plain line-based output is replayed one line per frame. Frames are played
+with the gaps recorded between them (divided by --speed); raw output files
+and other sources without chunk times advance every --interval instead.
+
+Use --step to advance manually: Enter shows the next frame, q quits.
+
+With --input, the session's input is replayed instead: a fresh session is
+started with the same command, working directory and size, and everything
+sent to the original is sent again with the original timing (scaled by
+--speed). Use it to turn an interactive exploration into a reproducible run.
+Environment variables given on create are not carried over.
+
+Examples:
+  shelli replay build.shelli --speed 2x
+  shelli replay explore --input --speed 4x
+  shelli replay explore --input --into explore-2`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReplay,
+}
+
+var (
+	replaySpeedFlag    string
+	replayIntervalFlag int
+	replayToFrameFlag  int
+	replayStepFlag     bool
+	replayPlainFlag    bool
+	replayColsFlag     int
+	replayRowsFlag     int
+	replayInputFlag    bool
+	replayIntoFlag     string
+)
+
+func init() {
+	replayCmd.Flags().StringVar(&replaySpeedFlag, "speed", "1x", "Playback speed multiplier (e.g., 2x, 0.5)")
+	replayCmd.Flags().IntVar(&replayIntervalFlag, "interval", 100, "Delay between frames in milliseconds at 1x speed when the output has no recorded timing")
+	replayCmd.Flags().IntVar(&replayToFrameFlag, "to-frame", 0, "Fast-forward to frame N (1-based), render it and exit")
+	replayCmd.Flags().BoolVar(&replayStepFlag, "step", false, "Advance frames manually (Enter = next, q = quit)")
+	replayCmd.Flags().BoolVar(&replayPlainFlag, "plain", false, "Render plain text instead of ANSI-styled output")
+	replayCmd.Flags().IntVar(&replayColsFlag, "cols", 0, "Emulator columns, or the fresh session's with --input (default: from session metadata, or 80)")
+	replayCmd.Flags().IntVar(&replayRowsFlag, "rows", 0, "Emulator rows, or the fresh session's with --input (default: from session metadata, or 24)")
+	replayCmd.Flags().BoolVar(&replayInputFlag, "input", false, "Replay the session's input into a fresh session")
+	replayCmd.Flags().StringVar(&replayIntoFlag, "into", "", "With --input: name of the fresh session (default: <name>-replay)")
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	speed, err := parseSpeed(replaySpeedFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --speed: %w", err)
+	}
+	if replayToFrameFlag < 0 {
+		return fmt.Errorf("--to-frame requires a positive integer")
+	}
+	if replayInputFlag {
+		if replayToFrameFlag > 0 || replayStepFlag || replayPlainFlag || cmd.Flags().Changed("interval") {
+			return fmt.Errorf("--input cannot be combined with --to-frame, --step, --plain or --interval")
+		}
+		return runReplayInput(args[0], speed)
+	}
+	if replayIntoFlag != "" {
+		return fmt.Errorf("--into requires --input")
+	}
+
+	bundle, err := loadReplaySource(args[0])
+	if err != nil {
+		return err
+	}
+
+	cols, rows := bundle.Meta.Cols, bundle.Meta.Rows
+	if replayColsFlag > 0 {
+		cols = replayColsFlag
+	}
+	if replayRowsFlag > 0 {
+		rows = replayRowsFlag
+	}
+	if cols <= 0 {
+		cols = 80
+	}
+	if rows <= 0 {
+		rows = 24
+	}
+
+	frames := vterm.SplitFrames(bundle.Output)
+	if len(frames) == 0 {
+		return fmt.Errorf("nothing to replay: output is empty")
+	}
+	if replayToFrameFlag > len(frames) {
+		return fmt.Errorf("--to-frame %d out of range (%d frames)", replayToFrameFlag, len(frames))
+	}
+
+	screen := vterm.New(cols, rows)
+	defer screen.Close()
+	go screen.ReadResponses(io.Discard)
+
+	interval := time.Duration(float64(replayIntervalFlag) * float64(time.Millisecond) / speed)
+	stdin := bufio.NewReader(os.Stdin)
+
+	var offset int64
+	for i, frame := range frames {
+		start := offset
+		offset += int64(len(frame))
+		screen.Write([]byte(vterm.NormalizeNewlines(string(frame))))
+
+		if replayToFrameFlag > 0 && i+1 < replayToFrameFlag {
+			continue
+		}
+
+		renderReplayFrame(screen, i+1, len(frames))
+
+		if replayToFrameFlag > 0 || i == len(frames)-1 {
+			break
+		}
+
+		if replayStepFlag {
+			line, err := stdin.ReadString('\n')
+			if err != nil || strings.TrimSpace(line) == "q" {
+				break
+			}
+			continue
+		}
+		time.Sleep(frameDelay(bundle, start, offset, speed, interval))
+	}
+
+	return nil
+}
+
+// runReplayInput starts a fresh session like name and sends it name's logged
+// input, keeping the gaps between inputs (divided by speed).
+func runReplayInput(name string, speed float64) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	recorded, err := client.InputLog(name)
+	if err != nil {
+		return err
+	}
+	if len(recorded.Events) == 0 {
+		return fmt.Errorf("session %q has no logged input to replay", name)
+	}
+	if recorded.Truncated {
+		fmt.Fprintf(os.Stderr, "warning: input log of %q reached its limit; only the first %d inputs are replayed\n", name, len(recorded.Events))
+	}
+
+	target := replayIntoFlag
+	if target == "" {
+		target = name + "-replay"
+	}
+	opts := daemon.CreateOptions{
+		Command:  recorded.Command,
+		Cwd:      recorded.Cwd,
+		Cols:     recorded.Cols,
+		Rows:     recorded.Rows,
+		TUIMode:  recorded.TUIMode,
+		Encoding: recorded.Encoding,
+	}
+	if replayColsFlag > 0 {
+		opts.Cols = replayColsFlag
+	}
+	if replayRowsFlag > 0 {
+		opts.Rows = replayRowsFlag
+	}
+	if _, err := client.Create(target, opts); err != nil {
+		return err
+	}
+
+	// Gaps are measured from creation, so the first input also waits for
+	// the program to start as it did originally.
+	last := recorded.CreatedAt
+	for i, event := range recorded.Events {
+		time.Sleep(time.Duration(float64(event.At.Sub(last)) / speed))
+		last = event.At
+		if err := client.Send(target, event.Data, false); err != nil {
+			return fmt.Errorf("replay input %d of %d: %w", i+1, len(recorded.Events), err)
+		}
+	}
+
+	fmt.Printf("Replayed %d inputs from %q into session %q\n", len(recorded.Events), name, target)
+	return nil
+}
+
+// frameDelay is how long to show the frame at output offset start before
+// the next one, which starts at next: the recorded gap between them divided
+// by speed, or interval when either has no recorded time.
+func frameDelay(bundle *daemon.SessionBundle, start, next int64, speed float64, interval time.Duration) time.Duration {
+	from, ok := bundle.OutputTime(start)
+	if !ok {
+		return interval
+	}
+	to, ok := bundle.OutputTime(next)
+	if !ok {
+		return interval
+	}
+	return time.Duration(float64(to.Sub(from)) / speed)
+}
+
+func renderReplayFrame(screen *vterm.Screen, n, total int) {
+	content := screen.Render()
+	if replayPlainFlag {
+		content = screen.String()
+	}
+	fmt.Print("\x1b[H\x1b[2J")
+	fmt.Println(content)
+	fmt.Printf("\x1b[7m frame %d/%d \x1b[0m\n", n, total)
+}
+
+// loadReplaySource resolves a replay argument to a bundle: an export bundle,
+// a raw output file, or a session name fetched from the daemon.
+func loadReplaySource(arg string) (*daemon.SessionBundle, error) {
+	data, err := os.ReadFile(arg)
+	if err == nil {
+		if bundle, bErr := daemon.ReadBundle(bytes.NewReader(data)); bErr == nil {
+			return bundle, nil
+		}
+		return &daemon.SessionBundle{Output: data}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read %s: %w", arg, err)
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return nil, fmt.Errorf("daemon: %w", err)
+	}
+	return client.Export(arg)
+}
+
+func parseSpeed(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(strings.ToLower(s)), "x")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) || v <= 0 {
+		return 0, fmt.Errorf("speed must be a positive finite number")
+	}
+	return v, nil
+}
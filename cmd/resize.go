@@ -12,18 +12,20 @@ var (
 	resizeColsFlag int
 	resizeRowsFlag int
 	resizeJsonFlag bool
+	resizeAutoFlag bool
 )
 
 func init() {
 	resizeCmd.Flags().IntVar(&resizeColsFlag, "cols", 0, "Terminal columns")
 	resizeCmd.Flags().IntVar(&resizeRowsFlag, "rows", 0, "Terminal rows")
 	resizeCmd.Flags().BoolVar(&resizeJsonFlag, "json", false, "Output as JSON")
+	resizeCmd.Flags().BoolVar(&resizeAutoFlag, "auto", false, "Match the size of the terminal running this command")
 }
 
 var resizeCmd = &cobra.Command{
 	Use:   "resize <name>",
 	Short: "Resize terminal dimensions",
-	Long:  `Change the terminal dimensions of a running session. At least one of --cols or --rows must be specified.`,
+	Long:  `Change the terminal dimensions of a running session. At least one of --cols, --rows or --auto must be specified.`,
 	Args:  cobra.ExactArgs(1),
 	RunE:  runResize,
 }
@@ -31,8 +33,18 @@ var resizeCmd = &cobra.Command{
 func runResize(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
+	if resizeAutoFlag {
+		if resizeColsFlag > 0 || resizeRowsFlag > 0 {
+			return fmt.Errorf("--auto cannot be combined with --cols or --rows")
+		}
+		size, err := callerTerminalSize()
+		if err != nil {
+			return err
+		}
+		resizeColsFlag, resizeRowsFlag = size.Cols, size.Rows
+	}
 	if resizeColsFlag <= 0 && resizeRowsFlag <= 0 {
-		return fmt.Errorf("at least one of --cols or --rows is required")
+		return fmt.Errorf("at least one of --cols, --rows or --auto is required")
 	}
 
 	client := daemon.NewClient()
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var resumeJsonFlag bool
+
+func init() {
+	resumeCmd.Flags().BoolVar(&resumeJsonFlag, "json", false, "Output as JSON")
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <name>",
+	Short: "Store a paused session's output again",
+	Long:  `Resume storing the output of a session paused with 'shelli pause' and report how many bytes the pause discarded.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runResume,
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	result, err := client.Resume(name)
+	if err != nil {
+		return err
+	}
+
+	if resumeJsonFlag {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Printf("Resumed output capture of session %q (paused %s, %s dropped)\n",
+		name, formatDuration(result.PausedFor), formatBytes(result.DroppedBytes))
+	return nil
+}
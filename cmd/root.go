@@ -26,17 +26,54 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&noColorFlag, "no-color", false, "Disable colored output (also set by NO_COLOR)")
+
 	rootCmd.AddCommand(daemonCmd)
 	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(siblingCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(readCmd)
 	rootCmd.AddCommand(sendCmd)
 	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(runOnceCmd)
+	rootCmd.AddCommand(execStatusCmd)
+	rootCmd.AddCommand(jobsCmd)
 	rootCmd.AddCommand(stopCmd)
 	rootCmd.AddCommand(killCmd)
 	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(extractCmd)
+	rootCmd.AddCommand(waitCmd)
+	rootCmd.AddCommand(waitExitCmd)
+	rootCmd.AddCommand(locateCmd)
+	rootCmd.AddCommand(bookmarkCmd)
+	rootCmd.AddCommand(diffCmd)
 	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(describeCmd)
+	rootCmd.AddCommand(healthCmd)
 	rootCmd.AddCommand(clearCmd)
+	rootCmd.AddCommand(compactCmd)
+	rootCmd.AddCommand(duCmd)
+	rootCmd.AddCommand(metricsCmd)
 	rootCmd.AddCommand(resizeCmd)
+	rootCmd.AddCommand(fitCmd)
+	rootCmd.AddCommand(screenCmd)
+	rootCmd.AddCommand(attachCmd)
+	rootCmd.AddCommand(reniceCmd)
+	rootCmd.AddCommand(mirrorInputCmd)
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(freezeCmd)
+	rootCmd.AddCommand(thawCmd)
+	rootCmd.AddCommand(activityCmd)
+	rootCmd.AddCommand(reloadCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(replayCmd)
+	rootCmd.AddCommand(renderCmd)
+	rootCmd.AddCommand(framesCmd)
+	rootCmd.AddCommand(imagesCmd)
+	rootCmd.AddCommand(notificationsCmd)
+	rootCmd.AddCommand(eventsCmd)
 	rootCmd.AddCommand(versionCmd)
 }
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runOnceCmdFlag       string
+	runOnceInputFlag     string
+	runOnceWaitFlag      string
+	runOnceSettleFlag    int
+	runOnceTimeoutFlag   int
+	runOnceEnvFlag       []string
+	runOnceCwdFlag       string
+	runOnceColsFlag      int
+	runOnceRowsFlag      int
+	runOnceStripAnsiFlag bool
+	runOnceJsonFlag      bool
+)
+
+func init() {
+	runOnceCmd.Flags().StringVar(&runOnceCmdFlag, "cmd", "", "Command to run (required)")
+	runOnceCmd.Flags().StringVar(&runOnceInputFlag, "input", "", "Line to send once the program's startup output settles")
+	runOnceCmd.Flags().StringVar(&runOnceWaitFlag, "wait", "", "Wait for regex pattern in the output")
+	runOnceCmd.Flags().IntVar(&runOnceSettleFlag, "settle", 0, "Wait until output is quiet for N ms (default: 500 with --input, else wait for exit)")
+	runOnceCmd.Flags().IntVar(&runOnceTimeoutFlag, "timeout", 10, "Max seconds to wait")
+	runOnceCmd.Flags().StringArrayVar(&runOnceEnvFlag, "env", nil, "Set environment variable (KEY=VALUE), can be repeated")
+	runOnceCmd.Flags().StringVar(&runOnceCwdFlag, "cwd", "", "Set working directory")
+	runOnceCmd.Flags().IntVar(&runOnceColsFlag, "cols", 0, "Terminal columns (default: 80)")
+	runOnceCmd.Flags().IntVar(&runOnceRowsFlag, "rows", 0, "Terminal rows (default: 24)")
+	runOnceCmd.Flags().BoolVar(&runOnceStripAnsiFlag, "strip-ansi", false, "Strip ANSI escape codes")
+	runOnceCmd.Flags().BoolVar(&runOnceJsonFlag, "json", false, "Output as JSON")
+}
+
+var runOnceCmd = &cobra.Command{
+	Use:   "run-once",
+	Short: "Run a command in a throwaway session and print its output",
+	Long: `Run a command in a session that exists only for this call.
+
+The daemon creates the session, sends --input (if any) once the program's
+startup output has settled, waits, and kills the session before answering,
+also when the wait fails or the client goes away. This replaces create, exec,
+read and kill with their cleanup-on-failure logic.
+
+With --input the output starts after the input, so banners are left out, and
+the wait ends on --wait, after --settle ms of quiet (default 500), or when the
+program exits. Without --input the wait ends when the command exits, or on
+--wait or --settle when given. The exit status is reported on stderr when the
+command finished.
+
+Examples:
+  shelli run-once --cmd "python3" --input "print(1+1)"
+  shelli run-once --cmd "make test" --timeout 120
+  shelli run-once --cmd "psql mydb" --input "\dt" --wait "rows\)"`,
+	Args: cobra.NoArgs,
+	RunE: runRunOnce,
+}
+
+func runRunOnce(cmd *cobra.Command, args []string) error {
+	if runOnceCmdFlag == "" {
+		return fmt.Errorf("--cmd is required")
+	}
+	if runOnceWaitFlag != "" && cmd.Flags().Changed("settle") {
+		return fmt.Errorf("--wait and --settle are mutually exclusive")
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	result, err := client.RunOnce(daemon.RunOnceOptions{
+		Command:     runOnceCmdFlag,
+		Input:       runOnceInputFlag,
+		WaitPattern: runOnceWaitFlag,
+		SettleMs:    runOnceSettleFlag,
+		TimeoutSec:  runOnceTimeoutFlag,
+		Env:         runOnceEnvFlag,
+		Cwd:         runOnceCwdFlag,
+		Cols:        runOnceColsFlag,
+		Rows:        runOnceRowsFlag,
+		StripANSI:   runOnceStripAnsiFlag,
+	})
+	if err != nil {
+		return err
+	}
+
+	if runOnceJsonFlag {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Print(result.Output)
+	switch {
+	case result.Status == daemon.RunOnceTimeout:
+		fmt.Fprintf(os.Stderr, "Warning: timed out after %ds; the session was killed\n", runOnceTimeoutFlag)
+	case result.ExitCode != nil && result.Signal != "":
+		fmt.Fprintf(os.Stderr, "[exit %d, %s]\n", *result.ExitCode, result.Signal)
+	case result.ExitCode != nil:
+		fmt.Fprintf(os.Stderr, "[exit %d]\n", *result.ExitCode)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	screenStyledFlag bool
+	screenJsonFlag   bool
+)
+
+func init() {
+	screenCmd.Flags().BoolVar(&screenStyledFlag, "styled", false, "Keep colors and styles in the rows")
+	screenCmd.Flags().BoolVar(&screenJsonFlag, "json", false, "Output as JSON (rows array, cursor, cols, source)")
+}
+
+var screenCmd = &cobra.Command{
+	Use:   "screen <name>",
+	Short: "Show a session's terminal screen row by row",
+	Long: `Show what a session's terminal displays, one line per screen row, with the
+cursor position on stderr. With --json the rows come as an array, with the
+cursor's 0-based row and column and whether it is visible.
+
+TUI sessions report their live screen. For other sessions the end of the
+output buffer is replayed into a terminal of the session's size, so this also
+works for stopped sessions.
+
+Examples:
+  shelli screen editor
+  shelli screen editor --json | jq -r '.rows[0]'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runScreen,
+}
+
+func runScreen(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	screen, err := client.Screen(name, screenStyledFlag)
+	if err != nil {
+		return err
+	}
+
+	if screenJsonFlag {
+		data, err := json.MarshalIndent(screen, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for _, row := range screen.Rows {
+		fmt.Println(row)
+	}
+	visibility := ""
+	if !screen.Cursor.Visible {
+		visibility = ", hidden"
+	}
+	fmt.Fprintf(os.Stderr, "[cursor row %d, col %d%s]\n", screen.Cursor.Row, screen.Cursor.Col, visibility)
+	return nil
+}
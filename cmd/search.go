@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/schovi/shelli/internal/vterm"
 	"github.com/schovi/shelli/internal/daemon"
@@ -14,8 +15,17 @@ var searchCmd = &cobra.Command{
 	Short: "Search session output for patterns",
 	Long: `Search session output buffer for regex patterns with context.
 
-Returns matching lines with optional context lines before and after.`,
-	Args: cobra.ExactArgs(2),
+Returns matching lines with optional context lines before and after.
+Use --limit N to get the matches N at a time: when there are more, a token
+is printed (next in --json), and --continue TOKEN returns the matches after
+them with the same pattern and settings (the pattern argument may then be
+left out). --offset N skips the first N matches.
+Use --render to search what the terminal shows rather than the raw output
+lines: the buffer is replayed through a terminal emulator at the session's
+size (TUI sessions use their live screen), so text drawn by cursor
+movement matches as it appears, and each match reports its screen row and
+column (row 0 is the top of the screen, negative rows scrolled off above).`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: runSearch,
 }
 
@@ -26,6 +36,11 @@ var (
 	searchIgnoreCaseFlag bool
 	searchStripAnsiFlag bool
 	searchJsonFlag      bool
+	searchNewlinesFlag  string
+	searchOffsetFlag    int
+	searchLimitFlag     int
+	searchContinueFlag  string
+	searchRenderFlag    bool
 )
 
 func init() {
@@ -35,11 +50,21 @@ func init() {
 	searchCmd.Flags().BoolVar(&searchIgnoreCaseFlag, "ignore-case", false, "Case-insensitive search")
 	searchCmd.Flags().BoolVar(&searchStripAnsiFlag, "strip-ansi", false, "Strip ANSI escape codes before searching")
 	searchCmd.Flags().BoolVar(&searchJsonFlag, "json", false, "Output as JSON")
+	searchCmd.Flags().StringVar(&searchNewlinesFlag, "newlines", "", "Normalize line endings before splitting lines: raw (default), lf, or display")
+	searchCmd.Flags().IntVar(&searchOffsetFlag, "offset", 0, "Skip the first N matches")
+	searchCmd.Flags().IntVar(&searchLimitFlag, "limit", 0, "Return at most N matches")
+	searchCmd.Flags().StringVar(&searchContinueFlag, "continue", "", "Return the matches after the page that printed this token")
+	searchCmd.Flags().BoolVar(&searchRenderFlag, "render", false, "Search the rendered terminal rows and report screen row/col")
 }
 
 func runSearch(cmd *cobra.Command, args []string) error {
 	name := args[0]
-	pattern := args[1]
+	var pattern string
+	if len(args) > 1 {
+		pattern = args[1]
+	} else if searchContinueFlag == "" {
+		return fmt.Errorf("a pattern is required unless --continue is given")
+	}
 
 	if searchAroundFlag > 0 && (searchBeforeFlag > 0 || searchAfterFlag > 0) {
 		return fmt.Errorf("--around is mutually exclusive with --before/--after")
@@ -55,6 +80,12 @@ func runSearch(cmd *cobra.Command, args []string) error {
 	if before < 0 || after < 0 {
 		return fmt.Errorf("--before, --after, and --around must be non-negative")
 	}
+	if searchOffsetFlag < 0 || searchLimitFlag < 0 {
+		return fmt.Errorf("--offset and --limit must be non-negative")
+	}
+	if searchRenderFlag && searchNewlinesFlag != "" {
+		return fmt.Errorf("--render cannot be combined with --newlines")
+	}
 
 	client := daemon.NewClient()
 	if err := client.EnsureDaemon(); err != nil {
@@ -68,6 +99,11 @@ func runSearch(cmd *cobra.Command, args []string) error {
 		After:      after,
 		IgnoreCase: searchIgnoreCaseFlag,
 		StripANSI:  searchStripAnsiFlag,
+		Newlines:   searchNewlinesFlag,
+		Offset:     searchOffsetFlag,
+		Limit:      searchLimitFlag,
+		Continue:   searchContinueFlag,
+		Render:     searchRenderFlag,
 	})
 	if err != nil {
 		return err
@@ -82,40 +118,56 @@ func runSearch(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	printSearchMatches(resp, searchStripAnsiFlag)
+	if resp.Next != "" {
+		fmt.Fprintf(os.Stderr, "\n%d of %d matches shown (--continue %s)\n", len(resp.Matches), resp.TotalMatches, resp.Next)
+	}
+	return nil
+}
+
+// printSearchMatches prints search matches with line numbers and context,
+// stripping ANSI codes from the display when stripANSI is set.
+func printSearchMatches(resp *daemon.SearchResponse, stripANSI bool) {
 	if len(resp.Matches) == 0 {
 		fmt.Println("No matches found.")
-		return nil
+		return
 	}
 
 	for i, match := range resp.Matches {
 		if i > 0 {
 			fmt.Println()
 		}
-		fmt.Printf("--- Match at line %d ---\n", match.LineNumber)
+		if match.Screen != nil {
+			fmt.Printf("--- Match at screen row %d, col %d ---\n", match.Screen.Row, match.Screen.Col)
+		} else {
+			fmt.Printf("--- Match at line %d ---\n", match.LineNumber)
+		}
 
 		startLine := match.LineNumber - len(match.Before)
 		for j, line := range match.Before {
 			display := line
-			if searchStripAnsiFlag {
+			if stripANSI {
 				display = vterm.StripDefault(line)
 			}
 			fmt.Printf("%4d: %s\n", startLine+j, display)
 		}
 
 		display := match.Line
-		if searchStripAnsiFlag {
+		if stripANSI {
 			display = vterm.StripDefault(match.Line)
 		}
 		fmt.Printf(">%3d: %s\n", match.LineNumber, display)
 
 		for j, line := range match.After {
 			display := line
-			if searchStripAnsiFlag {
+			if stripANSI {
 				display = vterm.StripDefault(line)
 			}
 			fmt.Printf("%4d: %s\n", match.LineNumber+1+j, display)
 		}
 	}
 
-	return nil
+	if resp.LongLinesTruncated > 0 {
+		fmt.Printf("\n(%d lines longer than %d bytes were truncated)\n", resp.LongLinesTruncated, daemon.MaxLineLength)
+	}
 }
@@ -9,10 +9,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var sendJsonFlag bool
+var (
+	sendJsonFlag bool
+	sendKeyFlag  string
+)
 
 func init() {
 	sendCmd.Flags().BoolVar(&sendJsonFlag, "json", false, "Output as JSON")
+	sendCmd.Flags().StringVar(&sendKeyFlag, "idempotency-key", "", "Key for safe retries: input already sent with this key is not sent again")
 }
 
 var sendCmd = &cobra.Command{
@@ -23,6 +27,10 @@ var sendCmd = &cobra.Command{
 Each argument is sent as a separate write to the PTY.
 Escape sequences are always interpreted. No newline is added automatically.
 
+With --idempotency-key, retrying is safe: input sent with the same key in the
+last 10 minutes is not sent again. With several inputs, each is keyed
+"<key>/<n>", so a retry sends only those that did not get through.
+
 Escape sequences:
   \x00-\xFF  Hex byte (e.g., \x03 for Ctrl+C)
   \n         Newline (LF)
@@ -60,15 +68,30 @@ func runSend(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("daemon: %w", err)
 	}
 
-	totalBytes := 0
-	for _, input := range inputs {
+	totalBytes, duplicates := 0, 0
+	for i, input := range inputs {
 		interpreted, err := escape.Interpret(input)
 		if err != nil {
 			return fmt.Errorf("escape sequence error: %w", err)
 		}
 
-		if err := client.Send(name, interpreted, false); err != nil {
-			return err
+		if sendKeyFlag == "" {
+			if err := client.Send(name, interpreted, false); err != nil {
+				return err
+			}
+		} else {
+			key := sendKeyFlag
+			if len(inputs) > 1 {
+				key = fmt.Sprintf("%s/%d", sendKeyFlag, i+1)
+			}
+			dup, err := client.SendOnce(name, interpreted, false, key)
+			if err != nil {
+				return err
+			}
+			if dup {
+				duplicates++
+				continue
+			}
 		}
 		totalBytes += len(interpreted)
 	}
@@ -80,8 +103,15 @@ func runSend(cmd *cobra.Command, args []string) error {
 			"count":  len(inputs),
 			"bytes":  totalBytes,
 		}
+		if sendKeyFlag != "" {
+			out["duplicates"] = duplicates
+		}
 		data, _ := json.MarshalIndent(out, "", "  ")
 		fmt.Println(string(data))
+	case duplicates == len(inputs):
+		fmt.Printf("Already sent to %q (idempotency key %q); nothing sent\n", name, sendKeyFlag)
+	case duplicates > 0:
+		fmt.Printf("Sent %d of %d inputs to %q (%d bytes); the rest were already sent\n", len(inputs)-duplicates, len(inputs), name, totalBytes)
 	case len(inputs) == 1:
 		fmt.Printf("Sent to %q (%d bytes)\n", name, totalBytes)
 	default:
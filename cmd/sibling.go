@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	siblingCmdFlag  string
+	siblingEnvFlag  []string
+	siblingJsonFlag bool
+)
+
+func init() {
+	siblingCmd.Flags().StringVar(&siblingCmdFlag, "cmd", "", "Command to run (default: $SHELL)")
+	siblingCmd.Flags().StringArrayVar(&siblingEnvFlag, "env", nil, "Set environment variable (KEY=VALUE) on top of the original's, can be repeated")
+	siblingCmd.Flags().BoolVar(&siblingJsonFlag, "json", false, "Output as JSON")
+}
+
+var siblingCmd = &cobra.Command{
+	Use:   "sibling <name> <newname>",
+	Short: "Open a new session next to an existing one",
+	Long: `Create session newname next to session name: in the directory name's
+process is in now (after any cd), with the environment variables name was
+created with (--env adds to them) and at name's terminal size. name is not
+touched, so this gives a second shell beside a busy one, e.g. to run
+diagnostics while a build is running.
+
+Without --cmd the new session runs your shell, not name's command. Outside
+Linux the current directory is found with lsof; without it, the directory
+name started in is used.
+
+Examples:
+  shelli sibling build diag
+  shelli sibling server logs --cmd "tail -f app.log"`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSibling,
+}
+
+func runSibling(cmd *cobra.Command, args []string) error {
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	data, err := client.Sibling(args[0], args[1], siblingCmdFlag, siblingEnvFlag)
+	if err != nil {
+		return err
+	}
+
+	if siblingJsonFlag {
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	fmt.Printf("Created session %q next to %q (pid: %.0f, cmd: %s, cwd: %s)\n",
+		data["name"], args[0], data["pid"], data["command"], data["cwd"])
+	return nil
+}
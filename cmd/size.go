@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/creack/pty"
+	"github.com/schovi/shelli/internal/daemon"
+)
+
+// resolveSize turns a --size value into dimensions: "auto" for the invoking
+// terminal, otherwise a "preset:<name>".
+func resolveSize(spec string) (daemon.TerminalSize, error) {
+	if spec == "auto" {
+		return callerTerminalSize()
+	}
+	return daemon.ParseSizePreset(spec)
+}
+
+// callerTerminalSize reads the invoking terminal's size (TIOCGWINSZ) from
+// the first standard stream that is a terminal, falling back to /dev/tty
+// when all of them are redirected.
+func callerTerminalSize() (daemon.TerminalSize, error) {
+	for _, f := range []*os.File{os.Stdin, os.Stdout, os.Stderr} {
+		if rows, cols, err := pty.Getsize(f); err == nil && cols > 0 && rows > 0 {
+			return daemon.TerminalSize{Cols: cols, Rows: rows}, nil
+		}
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return daemon.TerminalSize{}, fmt.Errorf("detect terminal size: no terminal attached")
+	}
+	defer tty.Close()
+	rows, cols, err := pty.Getsize(tty)
+	if err != nil || cols <= 0 || rows <= 0 {
+		return daemon.TerminalSize{}, fmt.Errorf("detect terminal size: no terminal attached")
+	}
+	return daemon.TerminalSize{Cols: cols, Rows: rows}, nil
+}
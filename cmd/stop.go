@@ -8,10 +8,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var stopJsonFlag bool
+var (
+	stopJsonFlag   bool
+	stopDryRunFlag bool
+)
 
 func init() {
 	stopCmd.Flags().BoolVar(&stopJsonFlag, "json", false, "Output as JSON")
+	stopCmd.Flags().BoolVar(&stopDryRunFlag, "dry-run", false, "Show what would be affected without doing it")
 }
 
 var stopCmd = &cobra.Command{
@@ -30,6 +34,10 @@ func runStop(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("daemon: %w", err)
 	}
 
+	if stopDryRunFlag {
+		return printDryRun(client, "stop", name, stopJsonFlag)
+	}
+
 	if err := client.Stop(name); err != nil {
 		return err
 	}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var thawJsonFlag bool
+
+func init() {
+	thawCmd.Flags().BoolVar(&thawJsonFlag, "json", false, "Output as JSON")
+}
+
+var thawCmd = &cobra.Command{
+	Use:   "thaw <name>",
+	Short: "Continue a frozen session's program (SIGCONT)",
+	Long:  `Continue a session halted with 'shelli freeze' by sending SIGCONT to the process groups the freeze stopped.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runThaw,
+}
+
+func runThaw(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	result, err := client.Thaw(name)
+	if err != nil {
+		return err
+	}
+
+	if thawJsonFlag {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Printf("Thawed session %q (frozen %s)\n", name, formatDuration(result.FrozenFor))
+	return nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	waitAnyFlag        string
+	waitIgnoreCaseFlag bool
+	waitTimeoutFlag    int
+	waitJsonFlag       bool
+)
+
+func init() {
+	waitCmd.Flags().StringVar(&waitAnyFlag, "any", "", "Regex to wait for in any session's new output")
+	waitCmd.Flags().BoolVarP(&waitIgnoreCaseFlag, "ignore-case", "i", false, "Case-insensitive matching")
+	waitCmd.Flags().IntVar(&waitTimeoutFlag, "timeout", 60, "Max wait time in seconds")
+	waitCmd.Flags().BoolVar(&waitJsonFlag, "json", false, "Output as JSON")
+}
+
+var waitCmd = &cobra.Command{
+	Use:   "wait --any <pattern> [filter]",
+	Short: "Wait for a pattern in whichever session prints it first",
+	Long: `Wait until the unread output of any session matches a regex, and report
+which session matched first. Useful after starting jobs in several sessions
+when only the first failure (or success) matters.
+
+The optional filter is a glob on session names, e.g. 'build-*'; without it
+every session is watched. Output after each session's read position counts,
+so a match printed before the wait started is found too. The read position
+is not moved. TUI sessions are not watched.
+
+Examples:
+  shelli wait --any 'FAIL|panic' 'test-*'                      # first failure among test-* sessions
+  shelli wait --any 'BUILD (SUCCESS|FAILED)' --timeout 600 --json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runWait,
+}
+
+func runWait(cmd *cobra.Command, args []string) error {
+	if waitAnyFlag == "" {
+		return fmt.Errorf("--any <pattern> is required")
+	}
+
+	var filter string
+	if len(args) > 0 {
+		filter = args[0]
+	}
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	result, err := client.WaitAny(filter, waitAnyFlag, waitIgnoreCaseFlag, waitTimeoutFlag)
+	if err != nil {
+		return err
+	}
+
+	if waitJsonFlag {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Printf("%s: %s\n", result.Session, result.Match)
+	return nil
+}
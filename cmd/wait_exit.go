@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/schovi/shelli/internal/wait"
+	"github.com/spf13/cobra"
+)
+
+// waitExitTimeoutCode is the exit status on timeout, as timeout(1) uses.
+const waitExitTimeoutCode = 124
+
+var (
+	waitExitTimeoutFlag int
+	waitExitJsonFlag    bool
+)
+
+func init() {
+	waitExitCmd.Flags().IntVar(&waitExitTimeoutFlag, "timeout", 0, "Max wait time in seconds (0: no limit)")
+	waitExitCmd.Flags().BoolVar(&waitExitJsonFlag, "json", false, "Output as JSON")
+}
+
+var waitExitCmd = &cobra.Command{
+	Use:   "wait-exit <name>",
+	Short: "Wait for a session's command to finish and exit with its status",
+	Long: `Block until the process inside a session exits, then exit with its exit
+code, so scripts can branch on whether the command succeeded. A process
+killed by a signal exits 128 plus the signal number, as in a shell. On
+timeout the command exits 124. A session that already exited returns at once.
+
+Examples:
+  shelli create tests --cmd "make test"
+  shelli wait-exit tests && echo passed
+  shelli wait-exit tests --timeout 600 --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWaitExit,
+}
+
+func runWaitExit(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client := daemon.NewClient()
+	if err := client.EnsureDaemon(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	result, err := client.WaitExit(name, waitExitTimeoutFlag)
+	if errors.Is(err, wait.ErrTimeout) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(waitExitTimeoutCode)
+	}
+	if err != nil {
+		return err
+	}
+
+	if waitExitJsonFlag {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+	} else if result.ExitCode != nil {
+		if result.Signal != "" {
+			fmt.Printf("Session %q exited with code %d (%s)\n", name, *result.ExitCode, result.Signal)
+		} else {
+			fmt.Printf("Session %q exited with code %d\n", name, *result.ExitCode)
+		}
+	}
+
+	if result.ExitCode == nil {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("session %q has stopped, but its exit code is unknown", name)
+	}
+	if *result.ExitCode != 0 {
+		os.Exit(min(*result.ExitCode, 255))
+	}
+	return nil
+}
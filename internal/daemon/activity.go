@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ActivityDefaultIdle is how long a session must be silent to count as idle
+// when the caller does not say.
+const ActivityDefaultIdle = 2 * time.Second
+
+// ActivityPollInterval is how often WaitIdle asks the daemon again.
+const ActivityPollInterval = 100 * time.Millisecond
+
+// activityWindows are the spans output rates are reported over.
+var activityWindows = []time.Duration{time.Second, 10 * time.Second, time.Minute}
+
+// activityMeter counts PTY output per second of the daemon's monotonic
+// clock, over the longest activity window.
+type activityMeter struct {
+	mu      sync.Mutex
+	seconds [60]int64 // second of each bucket, +1 (0 = unused)
+	bytes   [60]int64
+}
+
+func (m *activityMeter) add(n int, now time.Duration) {
+	sec := int64(now/time.Second) + 1
+	i := sec % int64(len(m.bytes))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.seconds[i] != sec {
+		m.seconds[i] = sec
+		m.bytes[i] = 0
+	}
+	m.bytes[i] += int64(n)
+}
+
+// rate returns the average bytes per second over the window ending now,
+// counting the current second as a whole one.
+func (m *activityMeter) rate(window, now time.Duration) float64 {
+	n := int64(window / time.Second)
+	sec := int64(now/time.Second) + 1
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total int64
+	for i, s := range m.seconds {
+		if s > sec-n && s <= sec {
+			total += m.bytes[i]
+		}
+	}
+	return float64(total) / float64(n)
+}
+
+// ActivityRate is a session's average output rate over a recent window.
+type ActivityRate struct {
+	Window      float64 `json:"window_seconds"`
+	BytesPerSec float64 `json:"bytes_per_second"`
+}
+
+// ActivityResult tells whether a session is producing output.
+type ActivityResult struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Idle is set once the session has been silent for IdleThreshold, or
+	// has stopped.
+	Idle          bool           `json:"idle"`
+	IdleThreshold float64        `json:"idle_threshold_seconds"`
+	IdleFor       float64        `json:"idle_seconds,omitempty"`
+	LastOutputAt  string         `json:"last_output_at,omitempty"`
+	TotalBytes    int64          `json:"total_bytes"`
+	Rates         []ActivityRate `json:"rates,omitempty"`
+}
+
+// handleActivity reports when a session last produced output, its recent
+// output rates, and whether it has been silent for req.IdleMs (default
+// ActivityDefaultIdle).
+func (s *Server) handleActivity(req Request) Response {
+	if req.IdleMs < 0 {
+		return Response{Success: false, Error: "idle threshold must not be negative"}
+	}
+	threshold := ActivityDefaultIdle
+	if req.IdleMs > 0 {
+		threshold = time.Duration(req.IdleMs) * time.Millisecond
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+
+	result := ActivityResult{
+		Name:          req.Name,
+		State:         string(h.state),
+		IdleThreshold: threshold.Seconds(),
+		TotalBytes:    h.traffic.ptyIn.Load(),
+	}
+	if h.clock.started == 0 {
+		// Recovered from storage: nothing was seen by this daemon.
+		result.Idle = true
+		return Response{Success: true, Data: result}
+	}
+
+	now := monoNow()
+	idle := now - time.Duration(h.clock.lastOutput.Load())
+	result.IdleFor = idle.Seconds()
+	result.LastOutputAt = time.Now().Add(-idle).Format(time.RFC3339Nano)
+	result.Idle = h.state != StateRunning || idle >= threshold
+	for _, w := range activityWindows {
+		result.Rates = append(result.Rates, ActivityRate{Window: w.Seconds(), BytesPerSec: h.activity.rate(w, now)})
+	}
+	return Response{Success: true, Data: result}
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActivityMeter(t *testing.T) {
+	var m activityMeter
+	base := 100 * time.Second
+	m.add(500, base)
+	m.add(500, base+300*time.Millisecond)
+	m.add(2000, base+5*time.Second)
+
+	now := base + 5*time.Second + 500*time.Millisecond
+	if got := m.rate(time.Second, now); got != 2000 {
+		t.Errorf("1s rate = %v, want 2000", got)
+	}
+	if got := m.rate(10*time.Second, now); got != 300 {
+		t.Errorf("10s rate = %v, want 300", got)
+	}
+
+	// A bucket reused a minute later starts from zero.
+	m.add(60, base+60*time.Second)
+	if got := m.rate(time.Second, base+60*time.Second); got != 60 {
+		t.Errorf("rate after wrap = %v, want 60", got)
+	}
+}
+
+func TestActivity(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("act", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("act")
+
+	if err := client.Send("act", "for i in 1 2 3 4 5 6 7 8; do echo tick-$i; sleep 0.1; done", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "act", "tick-2")
+
+	busy, err := client.Activity("act", 500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Activity: %v", err)
+	}
+	if busy.Idle || busy.LastOutputAt == "" || len(busy.Rates) != 3 || busy.Rates[0].BytesPerSec == 0 {
+		t.Errorf("activity while printing = %+v", busy)
+	}
+
+	quiet, err := client.WaitIdle("act", 500*time.Millisecond, 5*time.Second)
+	if err != nil {
+		t.Fatalf("WaitIdle: %v", err)
+	}
+	if !quiet.Idle || quiet.IdleFor < 0.5 {
+		t.Errorf("activity after WaitIdle = %+v", quiet)
+	}
+	if all, _, _ := client.Read("act", ReadModeAll, 0, 0); !strings.Contains(all, "tick-8") {
+		t.Errorf("WaitIdle returned before the loop finished: %q", all)
+	}
+
+	if _, err := client.Activity("missing", 0); err == nil {
+		t.Error("Activity of a missing session should fail")
+	}
+}
@@ -0,0 +1,38 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// An archive is a directory of session files in FileStorage's layout: the
+// data dir of a daemon that is gone, or a copy of one kept past cleanup.
+// ArchiveRead and ArchiveSearch query it without a daemon and without
+// registering its sessions; nothing in it is written.
+
+// ArchiveRead returns the whole buffer of session name in the archive dir,
+// limited to its first headLines or last tailLines lines when set.
+func ArchiveRead(dir, name string, headLines, tailLines int) (*OfflineResult, error) {
+	return OfflineRead(dir, name, ReadModeAll, "", headLines, tailLines)
+}
+
+// ArchiveSearch searches the buffer of session req.Name in the archive dir,
+// as search does a stopped session's.
+func ArchiveSearch(dir string, req SearchRequest) (*SearchResponse, error) {
+	if err := ValidateNewlines(req.Newlines); err != nil {
+		return nil, err
+	}
+	if req.Render {
+		return nil, fmt.Errorf("render searches need a session the daemon serves")
+	}
+	result, err := ArchiveRead(dir, req.Name, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	output := result.Output
+	if req.StripANSI {
+		output = vterm.StripDefault(output)
+	}
+	return searchOutput(NormalizeNewlines(output, req.Newlines), req)
+}
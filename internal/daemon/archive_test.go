@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveSearch(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := &SessionMeta{Name: "build", State: StateStopped, ReadPos: 100}
+	if err := storage.Create("build", meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Append("build", []byte("compiling\r\n\x1b[31mERROR\x1b[0m: missing\r\ndone\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(filepath.Join(dir, "build.meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := ArchiveSearch(dir, SearchRequest{Name: "build", Pattern: "^error:", IgnoreCase: true, StripANSI: true, Newlines: NewlinesLF, Before: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TotalMatches != 1 || resp.Matches[0].LineNumber != 2 || resp.Matches[0].Line != "ERROR: missing" {
+		t.Fatalf("matches = %+v, want line 2", resp.Matches)
+	}
+	if got := resp.Matches[0].Before; len(got) != 1 || got[0] != "compiling" {
+		t.Errorf("before = %q, want [compiling]", got)
+	}
+
+	// The whole buffer, whatever was read before.
+	result, err := ArchiveRead(dir, "build", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "done\r\n" {
+		t.Errorf("tail = %q, want the last line", result.Output)
+	}
+
+	after, err := os.ReadFile(filepath.Join(dir, "build.meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Error("archive queries changed the session's meta")
+	}
+
+	if _, err := ArchiveSearch(dir, SearchRequest{Name: "gone", Pattern: "x"}); err == nil {
+		t.Error("search of a session not in the archive succeeded")
+	}
+}
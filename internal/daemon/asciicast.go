@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// asciicastHeader is the first line of an asciicast v2 recording.
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// WriteAsciicast writes a bundle's output as an asciicast v2 recording: one
+// output event per chunk, timed from the session's creation. Output without
+// chunk times (recorded before the daemon kept them) plays at time 0.
+// Events never split a UTF-8 character.
+func WriteAsciicast(w io.Writer, b *SessionBundle) error {
+	cols, rows := b.Meta.Cols, b.Meta.Rows
+	if cols <= 0 {
+		cols = 80
+	}
+	if rows <= 0 {
+		rows = 24
+	}
+	header := asciicastHeader{
+		Version: 2,
+		Width:   cols,
+		Height:  rows,
+		Title:   b.Meta.Name,
+		Env:     map[string]string{"TERM": "xterm-256color"},
+	}
+	start := b.Meta.CreatedAt
+	if !start.IsZero() {
+		header.Timestamp = start.Unix()
+	}
+	if len(b.Chunks) > 0 && (start.IsZero() || b.Chunks[0].At.Before(start)) {
+		start = b.Chunks[0].At
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	var carry []byte
+	var last float64
+	emit := func(at float64, data []byte) error {
+		data = append(carry, data...)
+		cut := completeUTF8(data)
+		carry = append([]byte(nil), data[cut:]...)
+		if cut == 0 {
+			return nil
+		}
+		last = max(last, at) // events must not go back in time
+		return enc.Encode([]interface{}{last, "o", string(data[:cut])})
+	}
+
+	output := b.Output
+	if len(b.Chunks) == 0 || b.Chunks[0].Offset > 0 {
+		end := int64(len(output))
+		if len(b.Chunks) > 0 {
+			end = min(b.Chunks[0].Offset, end)
+		}
+		if err := emit(0, output[:end]); err != nil {
+			return fmt.Errorf("write event: %w", err)
+		}
+	}
+	for i, c := range b.Chunks {
+		from := min(c.Offset, int64(len(output)))
+		to := int64(len(output))
+		if i+1 < len(b.Chunks) {
+			to = min(b.Chunks[i+1].Offset, to)
+		}
+		if to <= from {
+			continue
+		}
+		if err := emit(max(0, c.At.Sub(start).Seconds()), output[from:to]); err != nil {
+			return fmt.Errorf("write event: %w", err)
+		}
+	}
+	if len(carry) > 0 {
+		if err := enc.Encode([]interface{}{last, "o", string(carry)}); err != nil {
+			return fmt.Errorf("write event: %w", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// completeUTF8 returns the length of data without a UTF-8 character cut
+// off at its end.
+func completeUTF8(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
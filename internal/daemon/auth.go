@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+)
+
+// CodeUnauthorized is the Response.Code of a request refused because of who
+// sent it: a socket peer outside allowed_uids, or a missing or wrong token.
+const CodeUnauthorized = "unauthorized"
+
+// errPeerCredUnsupported is peerUID's error where the platform cannot name
+// a socket peer.
+var errPeerCredUnsupported = errors.New("peer credentials are not supported on this platform")
+
+// socketAuth is who may use the daemon socket. Guarded by Server.configMu.
+type socketAuth struct {
+	// allowedUIDs are users besides the daemon's own that may connect.
+	allowedUIDs []int
+	// token, when set (require_token), must be in every request.
+	token string
+}
+
+// authorize checks a request before it is handled. On TCP connections
+// tcpToken is the token every request must carry. On the socket, the peer's
+// user must be the daemon's or in allowed_uids, checked through the kernel's
+// peer credentials, and with require_token the request must carry the
+// token too.
+func (s *Server) authorize(conn net.Conn, req Request, tcpToken string) error {
+	if tcpToken != "" {
+		if !tokenMatches(req.Token, tcpToken) {
+			return fmt.Errorf("missing or wrong token (set $%s)", TokenEnv)
+		}
+		return nil
+	}
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+
+	s.configMu.RLock()
+	auth := s.socketAuth
+	s.configMu.RUnlock()
+
+	uid, err := peerUID(unixConn)
+	switch {
+	case errors.Is(err, errPeerCredUnsupported) && len(auth.allowedUIDs) == 0:
+		// Only the socket directory's permissions keep others out.
+	case err != nil:
+		return fmt.Errorf("cannot identify the connecting user: %v", err)
+	case uid != os.Getuid() && !slices.Contains(auth.allowedUIDs, uid):
+		return fmt.Errorf("user %d may not use this daemon (not in allowed_uids)", uid)
+	}
+	if auth.token != "" && !tokenMatches(req.Token, auth.token) {
+		return fmt.Errorf("missing or wrong token (the daemon requires one; set $%s)", TokenEnv)
+	}
+	return nil
+}
+
+func tokenMatches(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// localToken is the token a client sends to the local daemon: $SHELLI_TOKEN,
+// else the daemon's token file if there is one. Other users cannot read the
+// file, so a socket reachable by them through a leaked path is still of no
+// use with require_token.
+func localToken() string {
+	if token := os.Getenv(TokenEnv); token != "" {
+		return token
+	}
+	path, err := TokenPath()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- our own runtime dir
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// tokenFile is where the daemon's token lives: the file given to
+// WithTokenFile, or TokenPath.
+func (s *Server) tokenFile() (string, error) {
+	if s.tokenPath != "" {
+		return s.tokenPath, nil
+	}
+	return TokenPath()
+}
+
+// WithTokenFile sets the file the daemon's token is read from, or written
+// to when it has none yet (see LoadToken).
+func WithTokenFile(path string) ServerOption {
+	return func(s *Server) {
+		s.tokenPath = path
+	}
+}
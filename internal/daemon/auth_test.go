@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	// The daemon takes $SHELLI_TOKEN over its token file, and so do clients.
+	t.Setenv(TokenEnv, "s3cret")
+	dir := t.TempDir()
+	client, cleanup := setupTestServer(t,
+		WithConfig(filepath.Join(dir, "daemon.json"), Config{RequireToken: true}),
+		WithTokenFile(filepath.Join(dir, "token")))
+	defer cleanup()
+
+	if _, err := client.List(); err != nil {
+		t.Errorf("list with the token: %v", err)
+	}
+
+	client.token = ""
+	if _, err := client.List(); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("list without the token: %v", err)
+	}
+	client.token = "wrong"
+	if client.Ping() {
+		t.Error("ping with a wrong token should be refused")
+	}
+}
+
+func TestAllowedUIDs(t *testing.T) {
+	dir := t.TempDir()
+	srv, client, cleanup := startTestServer(t,
+		WithConfig(filepath.Join(dir, "daemon.json"), Config{AllowedUIDs: []int{os.Getuid() + 1}}))
+	defer cleanup()
+
+	// The daemon's own user is always let in.
+	if _, err := client.List(); err != nil {
+		t.Fatalf("list as the daemon's user: %v", err)
+	}
+
+	conn, err := net.Dial("unix", srv.socketPath())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	uid, err := peerUID(conn.(*net.UnixConn))
+	if err != nil || uid != os.Getuid() {
+		t.Errorf("peerUID = %d, %v; want %d", uid, err, os.Getuid())
+	}
+
+	if _, err := (Config{AllowedUIDs: []int{-1}}).settings(); err == nil {
+		t.Error("a negative uid should be rejected")
+	}
+}
@@ -0,0 +1,126 @@
+package daemon
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BannerMaxBytes caps how much startup output a banner window holds back.
+// Past it the window gives up and the output goes to the buffer as usual.
+const BannerMaxBytes = 64 << 10
+
+// bannerWindow holds back a session's startup output (REPL banners, login
+// messages) until the program is ready, so the first exec reads a clean
+// buffer. What it held back is kept as the session's banner.
+type bannerWindow struct {
+	mu       sync.Mutex
+	re       *regexp.Regexp // readiness pattern; nil when timed
+	deadline time.Time      // end of a timed window
+	open     bool
+	banner   []byte
+}
+
+// newBannerWindow parses create's swallow_output_until: a number of
+// milliseconds after start, or a regex the startup output ends with.
+func newBannerWindow(spec string, start time.Time) (*bannerWindow, error) {
+	if ms, err := strconv.Atoi(spec); err == nil {
+		if ms <= 0 {
+			return nil, fmt.Errorf("invalid swallow_output_until %q: milliseconds must be positive", spec)
+		}
+		return &bannerWindow{deadline: start.Add(time.Duration(ms) * time.Millisecond), open: true}, nil
+	}
+	re, err := regexp.Compile(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid swallow_output_until pattern: %w", err)
+	}
+	return &bannerWindow{re: re, open: true}, nil
+}
+
+// swallow takes output while the window is open and returns what belongs in
+// the buffer: nothing, the output after the readiness match, or everything
+// once the window has closed. closed reports that this call closed it.
+func (w *bannerWindow) swallow(data []byte, now time.Time) (rest []byte, closed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.open {
+		return data, false
+	}
+	if w.re == nil && !now.Before(w.deadline) {
+		w.open = false
+		return data, true
+	}
+	w.banner = append(w.banner, data...)
+	if w.re != nil {
+		if loc := w.re.FindIndex(w.banner); loc != nil {
+			rest = append([]byte(nil), w.banner[loc[1]:]...)
+			w.banner = w.banner[:loc[1]]
+			w.open = false
+			return rest, true
+		}
+	}
+	if len(w.banner) > BannerMaxBytes {
+		rest, w.banner = w.banner, nil
+		w.open = false
+		return rest, true
+	}
+	return nil, false
+}
+
+// close ends the window early (the session got input or stopped) and
+// reports whether it was still open.
+func (w *bannerWindow) close() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	was := w.open
+	w.open = false
+	return was
+}
+
+// state returns the banner so far and whether the window is still open.
+// A timed window counts as closed once its deadline passed.
+func (w *bannerWindow) state(now time.Time) (banner string, open bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	open = w.open && (w.re != nil || now.Before(w.deadline))
+	return string(w.banner), open
+}
+
+// closeBanner ends a session's banner window and saves what it held back.
+func (s *Server) closeBanner(name string, w *bannerWindow) {
+	if w == nil || !w.close() {
+		return
+	}
+	s.saveBanner(name, w)
+}
+
+// saveBanner records a closed window's banner in the session metadata.
+func (s *Server) saveBanner(name string, w *bannerWindow) {
+	banner, _ := w.state(time.Now())
+	if banner == "" {
+		return
+	}
+	s.storage.UpdateMeta(name, func(meta *SessionMeta) {
+		meta.Banner = banner
+	})
+}
+
+// bannerInfo adds the banner to an info result. Callers hold Server.mu.
+func (h *sessionHandle) bannerInfo(result map[string]interface{}, meta *SessionMeta) {
+	if h.banner == nil {
+		if meta.Banner != "" {
+			result["banner"] = meta.Banner
+		}
+		return
+	}
+	banner, open := h.banner.state(time.Now())
+	if banner != "" {
+		result["banner"] = banner
+	}
+	if open {
+		result["swallowing_output"] = true
+	}
+}
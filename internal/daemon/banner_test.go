@@ -0,0 +1,93 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBannerWindow(t *testing.T) {
+	start := time.Now()
+
+	w, err := newBannerWindow(`READY\r?\n`, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest, closed := w.swallow([]byte("Welcome v1.0\r\nREA"), start); len(rest) != 0 || closed {
+		t.Errorf("swallow before readiness = %q, %v", rest, closed)
+	}
+	rest, closed := w.swallow([]byte("DY\r\n>>> "), start)
+	if string(rest) != ">>> " || !closed {
+		t.Errorf("swallow at readiness = %q, %v, want the prompt after the match", rest, closed)
+	}
+	if banner, open := w.state(start); banner != "Welcome v1.0\r\nREADY\r\n" || open {
+		t.Errorf("state = %q, %v", banner, open)
+	}
+	if rest, _ := w.swallow([]byte("later"), start); string(rest) != "later" {
+		t.Errorf("swallow after close = %q", rest)
+	}
+
+	timed, err := newBannerWindow("100", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest, _ := timed.swallow([]byte("early"), start.Add(50*time.Millisecond)); len(rest) != 0 {
+		t.Errorf("timed swallow before deadline = %q", rest)
+	}
+	if rest, closed := timed.swallow([]byte("late"), start.Add(150*time.Millisecond)); string(rest) != "late" || !closed {
+		t.Errorf("timed swallow after deadline = %q, %v", rest, closed)
+	}
+
+	for _, spec := range []string{"0", "-5", "("} {
+		if _, err := newBannerWindow(spec, start); err == nil {
+			t.Errorf("newBannerWindow(%q) should fail", spec)
+		}
+	}
+}
+
+func TestCreateSwallowOutputUntil(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	_, err := client.Create("banner", CreateOptions{
+		Command:            "echo Welcome-banner; echo READY; exec sh",
+		SwallowOutputUntil: `READY\r?\n`,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("banner")
+
+	deadline := time.Now().Add(5 * time.Second)
+	var info *InfoResponse
+	for time.Now().Before(deadline) {
+		if info, err = client.Info("banner"); err != nil {
+			t.Fatalf("Info: %v", err)
+		}
+		if !info.Swallowing {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if info.Swallowing || !strings.Contains(info.Banner, "Welcome-banner") {
+		t.Fatalf("info banner = %q, swallowing %v", info.Banner, info.Swallowing)
+	}
+
+	if err := client.Send("banner", "echo after-$((1+1))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "banner", "after-2")
+
+	all, _, err := client.Read("banner", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if strings.Contains(all, "Welcome-banner") || strings.Contains(all, "READY") {
+		t.Errorf("buffer holds the banner: %q", all)
+	}
+
+	if _, err := client.Create("banner-tui", CreateOptions{Command: "sh", TUIMode: true, SwallowOutputUntil: "100"}); err == nil {
+		client.Kill("banner-tui")
+		t.Error("swallow_output_until should be refused in TUI mode")
+	}
+}
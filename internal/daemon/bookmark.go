@@ -0,0 +1,365 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// Bookmark actions (Request.Mode of the bookmark action).
+const (
+	BookmarkModeList    = "list"
+	BookmarkModeWatch   = "watch"   // bookmark every line matching Pattern, named Bookmark-N
+	BookmarkModeUnwatch = "unwatch" // stop the watch named Bookmark ("" for all)
+	BookmarkModeAdd     = "add"     // bookmark the end of the output as Bookmark
+	BookmarkModeDelete  = "delete"
+)
+
+// DefaultBookmarkPrefix names the bookmarks of a watch given no name.
+const DefaultBookmarkPrefix = "match"
+
+// bookmarkMatchLen caps the matched line kept with a bookmark.
+const bookmarkMatchLen = 200
+
+// Bookmark is a named position in a session's output: the start of a line
+// a watch matched, or where the output ended when it was added by hand.
+// Offsets move with the buffer like cursors do; a bookmark whose output was
+// dropped is removed.
+type Bookmark struct {
+	Name    string    `json:"name"`
+	Offset  int64     `json:"offset"`
+	Pattern string    `json:"pattern,omitempty"` // the watch that set it
+	Match   string    `json:"match,omitempty"`   // the matched line, ANSI stripped
+	At      time.Time `json:"at"`
+}
+
+// BookmarkWatch bookmarks every output line matching Pattern as Prefix-N.
+type BookmarkWatch struct {
+	Pattern string `json:"pattern"`
+	Prefix  string `json:"prefix"`
+	Count   int    `json:"count"` // bookmarks set so far, the N of the latest
+}
+
+// BookmarkSet is a session's watches and bookmarks, oldest first.
+type BookmarkSet struct {
+	Watches   []BookmarkWatch `json:"watches"`
+	Bookmarks []Bookmark      `json:"bookmarks"`
+}
+
+// addBookmark records b, dropping the oldest bookmarks past the limit.
+func (m *SessionMeta) addBookmark(b Bookmark) {
+	m.Bookmarks = append(m.Bookmarks, b)
+	if over := len(m.Bookmarks) - MaxSessionBookmarks; over > 0 {
+		m.Bookmarks = slices.Delete(m.Bookmarks, 0, over)
+	}
+}
+
+func (m *SessionMeta) bookmark(name string) (Bookmark, bool) {
+	for _, b := range m.Bookmarks {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return Bookmark{}, false
+}
+
+// bookmarkWatcher matches a session's output against its watches as capture
+// stores it. Only complete lines are matched: carryLen counts the bytes of
+// the line still being written, whose first bookmarkLineLimit bytes are in
+// carry.
+type bookmarkWatcher struct {
+	mu       sync.Mutex
+	rules    []bookmarkRule
+	carry    []byte
+	carryLen int64
+}
+
+type bookmarkRule struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// bookmarkLineLimit is how much of a line watches look at.
+const bookmarkLineLimit = 4096
+
+func (w *bookmarkWatcher) active() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.rules) > 0
+}
+
+func (w *bookmarkWatcher) set(watches []BookmarkWatch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rules = w.rules[:0]
+	for _, watch := range watches {
+		w.rules = append(w.rules, bookmarkRule{pattern: watch.Pattern, re: regexp.MustCompile(watch.Pattern)})
+	}
+	if len(w.rules) == 0 {
+		w.carry, w.carryLen = nil, 0
+	}
+}
+
+// bookmarkHit is a line matched by the watch with pattern.
+type bookmarkHit struct {
+	pattern string
+	offset  int64
+	line    string
+}
+
+// scan matches the lines text completes. end is the buffer size after text
+// was stored, so the line being carried starts at end-len(text)-carryLen.
+func (w *bookmarkWatcher) scan(text []byte, end int64) []bookmarkHit {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var hits []bookmarkHit
+	lineStart := end - int64(len(text)) - w.carryLen
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n')
+		if i < 0 {
+			w.keep(text)
+			break
+		}
+		w.keep(text[:i])
+		line := RemoveInputMirror(vterm.StripDefault(string(w.carry)))
+		line = strings.TrimRight(line, "\r")
+		for _, rule := range w.rules {
+			if rule.re.MatchString(line) {
+				hits = append(hits, bookmarkHit{pattern: rule.pattern, offset: max(0, lineStart), line: line})
+			}
+		}
+		lineStart += w.carryLen + 1
+		w.carry, w.carryLen = w.carry[:0], 0
+		text = text[i+1:]
+	}
+	return hits
+}
+
+func (w *bookmarkWatcher) keep(part []byte) {
+	if room := bookmarkLineLimit - len(w.carry); room > 0 {
+		w.carry = append(w.carry, part[:min(room, len(part))]...)
+	}
+	w.carryLen += int64(len(part))
+}
+
+// watchBookmarks records bookmarks for the lines of text, just stored, that
+// match the session's watches.
+func (s *Server) watchBookmarks(name string, h *sessionHandle, storage OutputStorage, text []byte) {
+	end, err := storage.Size(name)
+	if err != nil {
+		return
+	}
+	hits := h.bookmarks.scan(text, end)
+	if len(hits) == 0 {
+		return
+	}
+	now := s.clock.Now()
+	storage.UpdateMeta(name, func(m *SessionMeta) {
+		for _, hit := range hits {
+			i := slices.IndexFunc(m.BookmarkWatches, func(w BookmarkWatch) bool { return w.Pattern == hit.pattern })
+			if i < 0 {
+				continue
+			}
+			watch := &m.BookmarkWatches[i]
+			watch.Count++
+			m.addBookmark(Bookmark{
+				Name:    fmt.Sprintf("%s-%d", watch.Prefix, watch.Count),
+				Offset:  hit.offset,
+				Pattern: hit.pattern,
+				Match:   truncateRunes(hit.line, bookmarkMatchLen),
+				At:      now,
+			})
+		}
+	})
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// handleBookmark manages a session's bookmarks and the watches that set
+// them. Every mode returns the resulting BookmarkSet.
+func (s *Server) handleBookmark(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	screen := h.screen
+	storage := s.storage
+	s.mu.Unlock()
+
+	if screen != nil {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is in TUI mode (bookmarks point into raw output)", req.Name)}
+	}
+
+	// opErr is a refusal from inside an update, err a failure to save it.
+	var opErr, err error
+	switch req.Mode {
+	case "", BookmarkModeList:
+	case BookmarkModeWatch:
+		if req.Pattern == "" {
+			return Response{Success: false, Error: "watch needs a pattern"}
+		}
+		if _, err := regexp.Compile(req.Pattern); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("invalid pattern: %v", err)}
+		}
+		prefix := req.Bookmark
+		if prefix == "" {
+			prefix = DefaultBookmarkPrefix
+		}
+		if err := validateBookmarkName(prefix); err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		var watches []BookmarkWatch
+		err = storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+			for _, w := range m.BookmarkWatches {
+				if w.Prefix == prefix || w.Pattern == req.Pattern {
+					opErr = fmt.Errorf("a watch for %q or named %q already exists", req.Pattern, prefix)
+					return
+				}
+			}
+			m.BookmarkWatches = append(m.BookmarkWatches, BookmarkWatch{Pattern: req.Pattern, Prefix: prefix})
+			watches = m.BookmarkWatches
+		})
+		if err == nil && opErr == nil {
+			h.bookmarks.set(watches)
+		}
+	case BookmarkModeUnwatch:
+		var watches []BookmarkWatch
+		err = storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+			if req.Bookmark == "" {
+				m.BookmarkWatches = nil
+				return
+			}
+			i := slices.IndexFunc(m.BookmarkWatches, func(w BookmarkWatch) bool { return w.Prefix == req.Bookmark })
+			if i < 0 {
+				opErr = fmt.Errorf("no watch named %q", req.Bookmark)
+				return
+			}
+			m.BookmarkWatches = slices.Delete(m.BookmarkWatches, i, i+1)
+			watches = m.BookmarkWatches
+		})
+		if err == nil && opErr == nil {
+			h.bookmarks.set(watches)
+		}
+	case BookmarkModeAdd:
+		if err := validateBookmarkName(req.Bookmark); err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		end, sizeErr := storage.Size(req.Name)
+		if sizeErr != nil {
+			return Response{Success: false, Error: fmt.Sprintf("get size: %v", sizeErr)}
+		}
+		now := s.clock.Now()
+		err = storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+			if _, ok := m.bookmark(req.Bookmark); ok {
+				opErr = fmt.Errorf("bookmark %q already exists", req.Bookmark)
+				return
+			}
+			m.addBookmark(Bookmark{Name: req.Bookmark, Offset: end, At: now})
+		})
+	case BookmarkModeDelete:
+		err = storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+			i := slices.IndexFunc(m.Bookmarks, func(b Bookmark) bool { return b.Name == req.Bookmark })
+			if i < 0 {
+				opErr = fmt.Errorf("bookmark %q not found", req.Bookmark)
+				return
+			}
+			m.Bookmarks = slices.Delete(m.Bookmarks, i, i+1)
+		})
+	default:
+		return Response{Success: false, Error: fmt.Sprintf("unknown bookmark mode %q (expected list, watch, unwatch, add or delete)", req.Mode)}
+	}
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("update meta: %v", err)}
+	}
+	if opErr != nil {
+		return Response{Success: false, Error: opErr.Error()}
+	}
+
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+	set := BookmarkSet{Watches: meta.BookmarkWatches, Bookmarks: meta.Bookmarks}
+	if set.Watches == nil {
+		set.Watches = []BookmarkWatch{}
+	}
+	if set.Bookmarks == nil {
+		set.Bookmarks = []Bookmark{}
+	}
+	return Response{Success: true, Data: set}
+}
+
+func validateBookmarkName(name string) error {
+	if name == "" {
+		return fmt.Errorf("bookmark name is required")
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return fmt.Errorf("invalid bookmark name %q: no spaces or control characters", name)
+	}
+	return nil
+}
+
+// aroundOffset returns the span of data from before lines above the line
+// holding offset to after lines below it.
+func aroundOffset(data []byte, offset int64, before, after int) (int64, int64) {
+	offset = min(max(offset, 0), int64(len(data)))
+	start := int64(bytes.LastIndexByte(data[:offset], '\n') + 1)
+	for ; before > 0 && start > 0; before-- {
+		start = int64(bytes.LastIndexByte(data[:start-1], '\n') + 1)
+	}
+	end := offset
+	for lines := after + 1; lines > 0 && end < int64(len(data)); lines-- {
+		i := bytes.IndexByte(data[end:], '\n')
+		if i < 0 {
+			end = int64(len(data))
+			break
+		}
+		end += int64(i) + 1
+	}
+	return start, end
+}
+
+// bookmarkReadWindow is how much output readAround reads on each side of
+// the offset to begin with.
+const bookmarkReadWindow = 64 * 1024
+
+// readAround reads the span aroundOffset returns for the stored output,
+// with ReadRange over a window around offset that doubles until it holds
+// the lines, rather than reading the whole buffer. It returns the span and
+// its start and end offsets.
+func readAround(storage OutputStorage, name string, offset int64, before, after int) ([]byte, int64, int64, error) {
+	size, err := storage.Size(name)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	offset = min(max(offset, 0), size)
+	for window := int64(bookmarkReadWindow); ; window *= 2 {
+		from := max(0, offset-window)
+		data, err := storage.ReadRange(name, from, min(size, offset+window)-from)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		to := from + int64(len(data))
+		start, end := aroundOffset(data, offset-from, before, after)
+		// A span touching the window's edge may go on past it.
+		startDone := start > 0 || from == 0
+		endDone := end < int64(len(data)) || to >= size
+		if startDone && endDone || from == 0 && offset+window >= size {
+			return data[start:end], from + start, from + end, nil
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBookmarkWatch(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("log", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("log")
+
+	if _, err := client.Bookmark("log", BookmarkModeWatch, "failure", "^FAIL: "); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if _, err := client.Bookmark("log", BookmarkModeWatch, "failure", "panic"); err == nil {
+		t.Error("a second watch with the same name should be refused")
+	}
+	if _, err := client.Bookmark("log", BookmarkModeWatch, "bad", "("); err == nil {
+		t.Error("an invalid pattern should be refused")
+	}
+
+	script := `for i in $(seq 1 100); do if [ $i = 40 ] || [ $i = 70 ]; then echo "FAIL: case-$i"; else echo "ok $i"; fi; done; echo done-$((1+1))`
+	if err := client.Send("log", script, true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "log", "done-2")
+
+	set, err := client.Bookmark("log", BookmarkModeList, "", "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(set.Watches) != 1 || set.Watches[0].Count != 2 {
+		t.Fatalf("watches = %+v", set.Watches)
+	}
+	var names []string
+	for _, b := range set.Bookmarks {
+		names = append(names, b.Name)
+	}
+	// The echoed command line holds "FAIL: " too, but not at its start.
+	if strings.Join(names, ",") != "failure-1,failure-2" {
+		t.Fatalf("bookmarks = %+v", set.Bookmarks)
+	}
+	if set.Bookmarks[1].Match != "FAIL: case-70" {
+		t.Errorf("failure-2 match = %q", set.Bookmarks[1].Match)
+	}
+
+	output, _, b, err := client.ReadAroundBookmark("log", "failure-1", 2, 1)
+	if err != nil {
+		t.Fatalf("ReadAroundBookmark: %v", err)
+	}
+	if got := strings.ReplaceAll(output, "\r", ""); got != "ok 38\nok 39\nFAIL: case-40\nok 41\n" {
+		t.Errorf("around failure-1 = %q", output)
+	}
+	if b == nil || b.Name != "failure-1" {
+		t.Errorf("bookmark = %+v", b)
+	}
+
+	if _, _, _, err := client.ReadAroundBookmark("log", "failure-9", 1, 1); err == nil {
+		t.Error("reading around a missing bookmark should fail")
+	}
+
+	// Manual bookmarks mark the end of the output.
+	set, err = client.Bookmark("log", BookmarkModeAdd, "checkpoint", "")
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	size, _ := client.Size("log")
+	if last := set.Bookmarks[len(set.Bookmarks)-1]; last.Name != "checkpoint" || last.Offset != int64(size) {
+		t.Errorf("checkpoint = %+v, size %d", last, size)
+	}
+
+	if _, err := client.Bookmark("log", BookmarkModeUnwatch, "failure", ""); err != nil {
+		t.Fatalf("unwatch: %v", err)
+	}
+	if err := client.Send("log", "echo FAIL: again", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "log", "FAIL: again\r\n")
+	set, _ = client.Bookmark("log", BookmarkModeList, "", "")
+	if len(set.Bookmarks) != 3 {
+		t.Errorf("bookmarks after unwatch = %+v", set.Bookmarks)
+	}
+
+	if err := client.Clear("log"); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	set, _ = client.Bookmark("log", BookmarkModeList, "", "")
+	if len(set.Bookmarks) != 0 {
+		t.Errorf("bookmarks after clear = %+v", set.Bookmarks)
+	}
+}
+
+func TestBookmarkDropOutput(t *testing.T) {
+	m := SessionMeta{Bookmarks: []Bookmark{{Name: "a", Offset: 10}, {Name: "b", Offset: 50}}}
+	earlier := m.Bookmarks
+	m.dropOutput(20)
+	if len(m.Bookmarks) != 1 || m.Bookmarks[0].Name != "b" || m.Bookmarks[0].Offset != 30 {
+		t.Errorf("bookmarks = %+v", m.Bookmarks)
+	}
+	if earlier[0].Name != "a" || earlier[1].Offset != 50 {
+		t.Errorf("dropOutput changed an earlier copy: %+v", earlier)
+	}
+}
+
+func TestMemoryLoadMetaCopiesBookmarks(t *testing.T) {
+	storage := NewMemoryStorage(0)
+	storage.Create("m", &SessionMeta{Name: "m"})
+	storage.UpdateMeta("m", func(m *SessionMeta) {
+		m.Bookmarks = []Bookmark{{Name: "a", Offset: 1}}
+		m.BookmarkWatches = []BookmarkWatch{{Pattern: "x", Prefix: "x"}}
+	})
+
+	loaded, err := storage.LoadMeta("m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.UpdateMeta("m", func(m *SessionMeta) {
+		m.Bookmarks[0].Offset = 99
+		m.BookmarkWatches[0].Count = 7
+	})
+	if loaded.Bookmarks[0].Offset != 1 || loaded.BookmarkWatches[0].Count != 0 {
+		t.Errorf("loaded meta shares memory with the stored one: %+v %+v", loaded.Bookmarks, loaded.BookmarkWatches)
+	}
+}
+
+func TestReadAroundWidensWindow(t *testing.T) {
+	storage := NewMemoryStorage(0)
+	storage.Create("r", &SessionMeta{Name: "r"})
+	long := strings.Repeat("x", 3*bookmarkReadWindow)
+	data := "first\n" + long + "\nmark here\n" + long + "\nlast"
+	storage.Append("r", []byte(data))
+
+	offset := int64(strings.Index(data, "mark"))
+	got, start, end, err := readAround(storage, "r", offset, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := long + "\nmark here\n" + long + "\n"
+	if string(got) != want || start != 6 || end != int64(len(data)-len("last")) {
+		t.Errorf("readAround = %d bytes [%d, %d), want %d bytes [6, %d)", len(got), start, end, len(want), len(data)-len("last"))
+	}
+}
+
+func TestAroundOffset(t *testing.T) {
+	data := []byte("one\ntwo\nthree\nfour\nfive")
+	for _, tc := range []struct {
+		offset        int64
+		before, after int
+		want          string
+	}{
+		{8, 1, 1, "two\nthree\nfour\n"},
+		{10, 0, 0, "three\n"},
+		{0, 5, 0, "one\n"},
+		{19, 1, 5, "four\nfive"},
+		{int64(len(data)), 0, 0, "five"},
+	} {
+		start, end := aroundOffset(data, tc.offset, tc.before, tc.after)
+		if got := string(data[start:end]); got != tc.want {
+			t.Errorf("aroundOffset(%d, %d, %d) = %q, want %q", tc.offset, tc.before, tc.after, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Budget outcomes reported with exec results.
+const (
+	BudgetRunning      = "running"       // the command was still running when exec returned
+	BudgetCompleted    = "completed"     // the command finished within its budget
+	BudgetCPUExceeded  = "cpu_exceeded"  // stopped for using too much CPU time
+	BudgetWallExceeded = "wall_exceeded" // stopped for running too long
+)
+
+// BudgetResult is the state of an exec's CPU/wall-time budget.
+type BudgetResult struct {
+	Status  string  `json:"status"`
+	CPUSec  float64 `json:"cpu_seconds"`
+	WallSec float64 `json:"wall_seconds"`
+	Signal  string  `json:"signal,omitempty"` // last signal sent on breach: SIGTERM (SIGINT for the session process) or SIGKILL
+}
+
+// execBudget watches the foreground job of a session: the PTY's foreground
+// process group. In a shell that is the command being run. When the session
+// process itself is in the foreground (a REPL, or a shell builtin loop) it is
+// measured too, but only killed while it is busy burning CPU, so an idle
+// shell is never stopped for exceeding a wall budget.
+type execBudget struct {
+	maxCPU  time.Duration
+	maxWall time.Duration
+	leader  int // session PID
+	start   time.Time
+	stop    chan struct{}
+
+	mu     sync.Mutex
+	result BudgetResult
+	// target is the group being measured; base is its CPU time when the
+	// budget started, so work done before the exec is not counted.
+	target int
+	base   time.Duration
+	busy   bool // target's CPU time grew during the last poll
+}
+
+func newExecBudget(maxCPU, maxWall time.Duration, leader int) *execBudget {
+	return &execBudget{
+		maxCPU:  maxCPU,
+		maxWall: maxWall,
+		leader:  leader,
+		start:   time.Now(),
+		stop:    make(chan struct{}),
+		result:  BudgetResult{Status: BudgetRunning},
+	}
+}
+
+// Result returns the budget's current state. Once the exec is over, a
+// budget still watching an idle session process is done: the command
+// finished too quickly to be seen as a job.
+func (b *execBudget) Result(execDone bool) BudgetResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if execDone && b.result.Status == BudgetRunning && (b.target == 0 || b.target == b.leader) && !b.busy {
+		b.finishLocked(BudgetCompleted, "")
+		b.cancelLocked()
+	}
+	r := b.result
+	if r.Status == BudgetRunning {
+		r.WallSec = time.Since(b.start).Seconds()
+	}
+	return r
+}
+
+// Cancel stops watching without touching the job.
+func (b *execBudget) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cancelLocked()
+}
+
+func (b *execBudget) cancelLocked() {
+	select {
+	case <-b.stop:
+	default:
+		close(b.stop)
+	}
+}
+
+// watch polls the session's foreground group until the job finishes, the
+// budget is breached, or it is cancelled.
+func (b *execBudget) watch(ptmx *os.File) {
+	ticker := time.NewTicker(BudgetPollInterval)
+	defer ticker.Stop()
+	var last time.Duration
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		pgrp, err := foregroundGroup(ptmx)
+		if err != nil {
+			b.finish(BudgetCompleted, "")
+			return
+		}
+
+		b.mu.Lock()
+		switch {
+		case b.target == 0 && pgrp == b.leader:
+			b.target = pgrp
+			b.base, _ = groupCPUTime(pgrp)
+			last = b.base
+		case b.target == 0 || (b.target == b.leader && pgrp != b.leader):
+			b.target = pgrp
+			b.base, last = 0, 0
+		case b.target != b.leader && pgrp != b.target:
+			// The job's group left the foreground: it finished.
+			b.mu.Unlock()
+			b.finish(BudgetCompleted, "")
+			return
+		}
+		target, base := b.target, b.base
+		b.mu.Unlock()
+
+		total, _ := groupCPUTime(target)
+		cpu := total - base
+		wall := time.Since(b.start)
+		b.mu.Lock()
+		b.busy = total > last
+		b.result.CPUSec = cpu.Seconds()
+		busy := b.busy
+		b.mu.Unlock()
+		last = total
+
+		switch {
+		case b.maxCPU > 0 && cpu > b.maxCPU:
+			b.enforce(target, BudgetCPUExceeded)
+			return
+		case b.maxWall > 0 && wall > b.maxWall && (target != b.leader || busy):
+			b.enforce(target, BudgetWallExceeded)
+			return
+		}
+	}
+}
+
+// enforce sends SIGTERM to the group, then SIGKILL if it is still there
+// after BudgetKillGrace. The session process itself gets SIGINT first
+// instead, which interrupts a runaway shell loop or REPL statement the way
+// Ctrl-C would; interactive shells ignore SIGTERM.
+func (b *execBudget) enforce(pgrp int, status string) {
+	first, name := syscall.SIGTERM, "SIGTERM"
+	if pgrp == b.leader {
+		first, name = syscall.SIGINT, "SIGINT"
+	}
+	syscall.Kill(-pgrp, first)
+	b.finish(status, name)
+
+	deadline := time.Now().Add(BudgetKillGrace)
+	for time.Now().Before(deadline) {
+		if syscall.Kill(-pgrp, 0) != nil {
+			return
+		}
+		if pgrp == b.leader {
+			// Still alive is expected; done once it stops burning CPU.
+			before, _ := groupCPUTime(pgrp)
+			time.Sleep(BudgetPollInterval)
+			if after, _ := groupCPUTime(pgrp); after == before {
+				return
+			}
+			continue
+		}
+		time.Sleep(BudgetPollInterval)
+	}
+	if syscall.Kill(-pgrp, syscall.SIGKILL) == nil {
+		b.mu.Lock()
+		b.result.Signal = "SIGKILL"
+		b.mu.Unlock()
+	}
+}
+
+func (b *execBudget) finish(status, signal string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.finishLocked(status, signal)
+}
+
+func (b *execBudget) finishLocked(status, signal string) {
+	if b.result.Status != BudgetRunning {
+		return
+	}
+	b.result.Status = status
+	b.result.Signal = signal
+	b.result.WallSec = time.Since(b.start).Seconds()
+}
+
+// foregroundGroup returns the PTY's foreground process group (tcgetpgrp).
+func foregroundGroup(ptmx *os.File) (int, error) {
+	conn, err := ptmx.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var pgrp int32
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))) // #nosec G103 -- ioctl needs a pointer to the result
+	}); err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
+
+// validateBudget rejects budgets the platform cannot enforce.
+func validateBudget(maxCPU, maxWall time.Duration) error {
+	if maxCPU < 0 || maxWall < 0 {
+		return fmt.Errorf("budgets must not be negative")
+	}
+	if maxCPU > 0 {
+		if _, err := groupCPUTime(os.Getpid()); err != nil {
+			return fmt.Errorf("cpu budgets are not supported here: %w", err)
+		}
+	}
+	return nil
+}
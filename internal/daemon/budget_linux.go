@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc/<pid>/stat. It is
+// 100 on every Linux architecture Go supports.
+const clockTicks = 100
+
+// groupCPUTime sums user and system CPU time of the live processes in a
+// process group, read from /proc. Each process also counts the CPU time of
+// the children it has waited for (cutime, cstime), so work done by short
+// lived subprocesses such as compiler runs under make is not lost when
+// they exit.
+func groupCPUTime(pgrp int) (time.Duration, error) {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return 0, err
+	}
+	if len(stats) == 0 {
+		return 0, os.ErrNotExist
+	}
+	var ticks int64
+	for _, path := range stats {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // exited meanwhile
+		}
+		// The command name is parenthesised and may contain spaces.
+		i := strings.LastIndexByte(string(data), ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(data[i+1:]))
+		// fields[0] is field 3 (state): pgrp is field 5, utime 14, stime 15,
+		// cutime 16, cstime 17.
+		if len(fields) < 15 {
+			continue
+		}
+		if g, err := strconv.Atoi(fields[2]); err != nil || g != pgrp {
+			continue
+		}
+		for _, f := range fields[11:15] {
+			t, _ := strconv.ParseInt(f, 10, 64)
+			ticks += t
+		}
+	}
+	return time.Duration(ticks) * time.Second / clockTicks, nil
+}
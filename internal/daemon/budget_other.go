@@ -0,0 +1,12 @@
+//go:build !linux
+
+package daemon
+
+import (
+	"errors"
+	"time"
+)
+
+func groupCPUTime(pgrp int) (time.Duration, error) {
+	return 0, errors.New("process CPU time needs /proc (Linux only)")
+}
@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecWallBudget(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("budget", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("budget")
+
+	result, err := client.Exec("budget", ExecOptions{Input: "echo quick", MaxWall: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	if result.Budget == nil || result.Budget.Status != BudgetCompleted {
+		t.Errorf("quick command budget = %+v, want completed", result.Budget)
+	}
+
+	start := time.Now()
+	result, err = client.Exec("budget", ExecOptions{
+		Input:     "sleep 30",
+		SettleMs:  2000,
+		SettleSet: true,
+		MaxWall:   500 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	if result.Budget == nil || result.Budget.Status != BudgetWallExceeded {
+		t.Fatalf("sleep budget = %+v, want wall_exceeded", result.Budget)
+	}
+	if result.Budget.Signal != "SIGTERM" {
+		t.Errorf("signal = %q, want SIGTERM", result.Budget.Signal)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("exec took %v; sleep was not stopped", elapsed)
+	}
+
+	// The shell survives and keeps working.
+	result, err = client.Exec("budget", ExecOptions{Input: "echo still-here"})
+	if err != nil {
+		t.Fatalf("exec after breach: %v", err)
+	}
+	if result.Budget != nil {
+		t.Errorf("exec without limits should not report a budget, got %+v", result.Budget)
+	}
+}
+
+func TestGroupCPUTimeCountsReapedChildren(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process CPU time needs /proc")
+	}
+	pgrp := syscall.Getpgrp()
+	before, err := groupCPUTime(pgrp)
+	if err != nil {
+		t.Fatalf("groupCPUTime: %v", err)
+	}
+	// A child in our group burns CPU and is reaped before the next reading.
+	burn := exec.Command("sh", "-c", `end=$(($(date +%s) + 1)); while [ "$(date +%s)" -lt "$end" ]; do :; done`)
+	if err := burn.Run(); err != nil {
+		t.Fatalf("burn: %v", err)
+	}
+	child := burn.ProcessState.UserTime() + burn.ProcessState.SystemTime()
+	after, err := groupCPUTime(pgrp)
+	if err != nil {
+		t.Fatalf("groupCPUTime: %v", err)
+	}
+	if child < 100*time.Millisecond {
+		t.Skipf("child used only %v of CPU", child)
+	}
+	if got := after - before; got < child/2 {
+		t.Errorf("group CPU grew by %v after a child used %v", got, child)
+	}
+}
@@ -0,0 +1,188 @@
+package daemon
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	bundleMetaFile      = "meta.json"
+	bundleOutputFile    = "output"
+	bundleChunksFile    = "chunks.json"
+	bundleInputFile     = "input.json"
+	bundleRecordingFile = "recording.cast"
+)
+
+// gzipMagic starts bundles written before they were zstd-compressed; they
+// are still read.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// SessionBundle is a portable copy of a session's metadata, output and
+// input history. It is produced by export-session and consumed by
+// import-session so a session's history can be loaded by another daemon
+// for offline analysis.
+type SessionBundle struct {
+	Meta   SessionMeta `json:"meta"`
+	Output []byte      `json:"output"`
+	// Chunks are the output's chunk times, if the daemon recorded any.
+	Chunks []Chunk `json:"chunks,omitempty"`
+	// Input is what was sent to the session (see inputlog.go); truncated
+	// when the log reached InputLogMaxBytes.
+	Input          []InputEvent `json:"input,omitempty"`
+	InputTruncated bool         `json:"input_truncated,omitempty"`
+}
+
+// bundleInput is the input history file of a bundle.
+type bundleInput struct {
+	Events    []InputEvent `json:"events"`
+	Truncated bool         `json:"truncated,omitempty"`
+}
+
+// OutputTime returns when the byte at offset of b.Output was recorded, or
+// false when the bundle has no chunk times covering it.
+func (b *SessionBundle) OutputTime(offset int64) (time.Time, bool) {
+	return chunkTimeAt(b.Chunks, offset)
+}
+
+// WriteBundle writes b to w as a zstd-compressed tar archive. Besides the
+// files ReadBundle loads, non-TUI sessions get an asciicast recording of
+// their output (recording.cast), so the archive can be unpacked and played
+// with asciinema without shelli.
+func WriteBundle(w io.Writer, b *SessionBundle) error {
+	metaData, err := json.MarshalIndent(b.Meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal meta: %w", err)
+	}
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("open zstd: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+
+	now := time.Now()
+	type file struct {
+		name string
+		data []byte
+	}
+	files := []file{
+		{bundleMetaFile, metaData},
+		{bundleOutputFile, b.Output},
+	}
+	if len(b.Chunks) > 0 {
+		chunkData, err := json.Marshal(b.Chunks)
+		if err != nil {
+			return fmt.Errorf("marshal chunks: %w", err)
+		}
+		files = append(files, file{bundleChunksFile, chunkData})
+	}
+	if len(b.Input) > 0 || b.InputTruncated {
+		inputData, err := json.Marshal(bundleInput{Events: b.Input, Truncated: b.InputTruncated})
+		if err != nil {
+			return fmt.Errorf("marshal input: %w", err)
+		}
+		files = append(files, file{bundleInputFile, inputData})
+	}
+	if !b.Meta.TUIMode && len(b.Output) > 0 {
+		var cast bytes.Buffer
+		if err := WriteAsciicast(&cast, b); err != nil {
+			return fmt.Errorf("write recording: %w", err)
+		}
+		files = append(files, file{bundleRecordingFile, cast.Bytes()})
+	}
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    f.name,
+			Mode:    0600,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("write %s header: %w", f.name, err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close tar: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("close zstd: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle reads a bundle written by WriteBundle, or a gzip-compressed
+// one from before bundles were zstd-compressed.
+func ReadBundle(r io.Reader) (*SessionBundle, error) {
+	br := bufio.NewReader(r)
+	var archive io.Reader
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("open gzip: %w", err)
+		}
+		defer gz.Close()
+		archive = gz
+	} else {
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("open zstd: %w", err)
+		}
+		defer zr.Close()
+		archive = zr
+	}
+
+	var b SessionBundle
+	var hasMeta bool
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar: %w", err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", hdr.Name, err)
+		}
+
+		switch hdr.Name {
+		case bundleMetaFile:
+			if err := json.Unmarshal(data, &b.Meta); err != nil {
+				return nil, fmt.Errorf("parse meta: %w", err)
+			}
+			hasMeta = true
+		case bundleOutputFile:
+			b.Output = data
+		case bundleChunksFile:
+			if err := json.Unmarshal(data, &b.Chunks); err != nil {
+				return nil, fmt.Errorf("parse chunks: %w", err)
+			}
+		case bundleInputFile:
+			var input bundleInput
+			if err := json.Unmarshal(data, &input); err != nil {
+				return nil, fmt.Errorf("parse input: %w", err)
+			}
+			b.Input, b.InputTruncated = input.Events, input.Truncated
+		}
+	}
+
+	if !hasMeta {
+		return nil, fmt.Errorf("bundle has no %s", bundleMetaFile)
+	}
+	return &b, nil
+}
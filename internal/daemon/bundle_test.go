@@ -0,0 +1,222 @@
+package daemon
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	in := &SessionBundle{
+		Meta: SessionMeta{
+			Name:      "py",
+			Command:   "python3",
+			PID:       1234,
+			State:     StateStopped,
+			CreatedAt: created,
+			Cols:      120,
+			Rows:      40,
+		},
+		Output: []byte(">>> print('hi')\r\nhi\r\n\x1b[31mred\x1b[0m"),
+		Chunks: []Chunk{{Offset: 0, At: created}, {Offset: 17, At: created.Add(time.Second)}},
+		Input:  []InputEvent{{At: created, Data: "print('hi')\n"}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, in); err != nil {
+		t.Fatalf("WriteBundle: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		t.Errorf("bundle is not zstd-compressed: % x", buf.Bytes()[:4])
+	}
+	if !strings.Contains(bundleFile(t, buf.Bytes(), bundleRecordingFile), `"hi`) {
+		t.Error("bundle has no recording of the output")
+	}
+
+	out, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadBundle: %v", err)
+	}
+	if out.Meta.Name != "py" || out.Meta.Command != "python3" || out.Meta.Cols != 120 {
+		t.Errorf("meta mismatch: %+v", out.Meta)
+	}
+	if !out.Meta.CreatedAt.Equal(created) {
+		t.Errorf("created_at = %v, want %v", out.Meta.CreatedAt, created)
+	}
+	if !bytes.Equal(out.Output, in.Output) {
+		t.Errorf("output = %q, want %q", out.Output, in.Output)
+	}
+	if len(out.Chunks) != 2 || out.Chunks[1].Offset != 17 || !out.Chunks[1].At.Equal(created.Add(time.Second)) {
+		t.Errorf("chunks = %+v, want %+v", out.Chunks, in.Chunks)
+	}
+	if len(out.Input) != 1 || out.Input[0].Data != "print('hi')\n" || !out.Input[0].At.Equal(created) {
+		t.Errorf("input = %+v, want %+v", out.Input, in.Input)
+	}
+}
+
+// bundleFile returns the named file of a zstd bundle.
+func bundleFile(t *testing.T, bundle []byte, name string) string {
+	t.Helper()
+	zr, err := zstd.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatalf("open zstd: %v", err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("%s not in bundle: %v", name, err)
+		}
+		if hdr.Name == name {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("read %s: %v", name, err)
+			}
+			return string(data)
+		}
+	}
+}
+
+func TestReadGzipBundle(t *testing.T) {
+	meta, _ := json.Marshal(SessionMeta{Name: "old", Command: "sh"})
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{bundleMetaFile, meta}, {bundleOutputFile, []byte("old output")}} {
+		tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0600, Size: int64(len(f.data))})
+		tw.Write(f.data)
+	}
+	tw.Close()
+	gz.Close()
+
+	b, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadBundle: %v", err)
+	}
+	if b.Meta.Name != "old" || string(b.Output) != "old output" {
+		t.Errorf("bundle = %+v", b)
+	}
+}
+
+func TestReadBundleRejectsGarbage(t *testing.T) {
+	if _, err := ReadBundle(strings.NewReader("not a bundle")); err == nil {
+		t.Fatal("expected error for non-bundle input")
+	}
+}
+
+func TestImportedMetaWhitelist(t *testing.T) {
+	exit := 1
+	src := &SessionMeta{
+		Name:            "src",
+		Command:         "sh",
+		PID:             4242,
+		State:           StateRunning,
+		ExitCode:        &exit,
+		Cols:            100,
+		Rows:            30,
+		TUIMode:         true,
+		ReadPos:         5,
+		Cursors:         map[string]int64{"x": 3},
+		Workspace:       "/home/someone/project",
+		OnExit:          []string{"rm -rf /tmp/x"},
+		Limits:          &ResourceLimits{},
+		Bookmarks:       []Bookmark{{Name: "in", Offset: 4}, {Name: "past", Offset: 99}},
+		BookmarkWatches: []BookmarkWatch{{Pattern: "x"}},
+	}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	meta := importedMeta("dst", src, 10, now)
+	if meta.Name != "dst" || meta.Command != "sh" || meta.State != StateStopped {
+		t.Errorf("meta = %+v", meta)
+	}
+	if meta.PID != 0 {
+		t.Errorf("PID = %d, want 0", meta.PID)
+	}
+	if meta.ExitCode == nil || *meta.ExitCode != 1 || meta.Cols != 100 || meta.Rows != 30 {
+		t.Errorf("descriptive fields lost: %+v", meta)
+	}
+	if meta.TUIMode || meta.ReadPos != 0 || meta.Cursors != nil {
+		t.Errorf("reader state kept: %+v", meta)
+	}
+	if meta.Workspace != "" || meta.OnExit != nil || meta.Limits != nil || meta.BookmarkWatches != nil {
+		t.Errorf("process settings kept: %+v", meta)
+	}
+	if len(meta.Bookmarks) != 1 || meta.Bookmarks[0].Name != "in" {
+		t.Errorf("bookmarks = %+v, want only the one inside the output", meta.Bookmarks)
+	}
+	if meta.StoppedAt == nil || !meta.StoppedAt.Equal(now) || !meta.CreatedAt.Equal(now) {
+		t.Errorf("times = %v/%v, want %v", meta.CreatedAt, meta.StoppedAt, now)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("export-src", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("export-src")
+
+	if err := client.Send("export-src", "echo exported-line", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "export-src", "exported-line")
+
+	bundle, err := client.Export("export-src")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if !strings.Contains(string(bundle.Output), "exported-line") {
+		t.Fatalf("bundle output missing content: %q", bundle.Output)
+	}
+
+	if _, err := client.Import("export-src", bundle); err == nil {
+		t.Fatal("import over existing session should fail")
+	}
+
+	if _, err := client.Import("export-dst", bundle); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	defer client.Kill("export-dst")
+
+	info, err := client.Info("export-dst")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.State != string(StateStopped) {
+		t.Errorf("imported state = %s, want stopped", info.State)
+	}
+
+	output, _, err := client.Read("export-dst", "all", 0, 0)
+	if err != nil {
+		t.Fatalf("read imported: %v", err)
+	}
+	if !strings.Contains(output, "exported-line") {
+		t.Errorf("imported output = %q, want to contain exported-line", output)
+	}
+
+	log, err := client.InputLog("export-dst")
+	if err != nil {
+		t.Fatalf("input log: %v", err)
+	}
+	if len(log.Events) == 0 || !strings.Contains(log.Events[0].Data, "echo exported-line") {
+		t.Errorf("imported input = %+v, want the exported send", log.Events)
+	}
+
+	if err := client.Send("export-dst", "echo nope", true); err == nil {
+		t.Error("send to imported session should fail")
+	}
+}
@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// rawCapture tees unmodified PTY output to a file for bug reports. The data
+// file holds the raw bytes; a sibling <path>.timing file holds one
+// "<delay-seconds> <byte-count>" line per chunk, the format scriptreplay(1)
+// reads. The data file can be dropped into the vterm test corpus as is.
+type rawCapture struct {
+	data   *os.File
+	timing *os.File
+	last   time.Time
+}
+
+func openRawCapture(path string) (*rawCapture, error) {
+	data, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // #nosec G304 -- path is chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("open capture file: %w", err)
+	}
+	timing, err := os.OpenFile(path+".timing", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // #nosec G304 -- path is chosen by the user
+	if err != nil {
+		data.Close()
+		return nil, fmt.Errorf("open capture timing file: %w", err)
+	}
+	return &rawCapture{data: data, timing: timing, last: time.Now()}, nil
+}
+
+func (c *rawCapture) Write(p []byte) error {
+	now := time.Now()
+	delay := now.Sub(c.last).Seconds()
+	c.last = now
+
+	if _, err := c.data.Write(p); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(c.timing, "%.6f %d\n", delay, len(p))
+	return err
+}
+
+func (c *rawCapture) Close() error {
+	errData := c.data.Close()
+	errTiming := c.timing.Close()
+	if errData != nil {
+		return errData
+	}
+	return errTiming
+}
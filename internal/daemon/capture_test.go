@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureRaw(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "capture.bin")
+	_, err := client.Create("cap", CreateOptions{
+		Command:    "printf 'hello\\033[1mbold\\033[0m\\n'",
+		CaptureRaw: path,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	waitForOutput(t, client, "cap", "bold")
+
+	var data []byte
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		data, _ = os.ReadFile(path)
+		if bytes.Contains(data, []byte("\x1b[0m")) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !bytes.Contains(data, []byte("hello\x1b[1mbold\x1b[0m")) {
+		t.Fatalf("capture = %q, want raw escape sequences preserved", data)
+	}
+
+	timing, err := os.ReadFile(path + ".timing")
+	if err != nil {
+		t.Fatalf("read timing: %v", err)
+	}
+	total := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(timing)), "\n") {
+		var delay float64
+		var n int
+		if _, err := fmt.Sscanf(line, "%f %d", &delay, &n); err != nil {
+			t.Fatalf("bad timing line %q: %v", line, err)
+		}
+		total += n
+	}
+	if total != len(data) {
+		t.Errorf("timing byte total = %d, want %d", total, len(data))
+	}
+
+	info, err := client.Info("cap")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.CaptureRaw != path {
+		t.Errorf("info capture_raw = %q, want %q", info.CaptureRaw, path)
+	}
+}
+
+func TestCaptureRawBadPath(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	_, err := client.Create("cap", CreateOptions{
+		Command:    "cat",
+		CaptureRaw: filepath.Join(t.TempDir(), "missing", "capture.bin"),
+	})
+	if err == nil {
+		t.Fatal("expected error for unwritable capture path")
+	}
+}
@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Capture priorities set how a session's output is pulled off its PTY.
+// Interactive stores every read as it arrives, for the lowest latency; bulk
+// reads into a large buffer and coalesces reads for a while before storing
+// them as one batch, so a session spewing output costs the daemon (storage,
+// screen, event subscribers) a fraction of the work per byte.
+const (
+	CapturePriorityInteractive = "interactive"
+	CapturePriorityNormal      = "normal"
+	CapturePriorityBulk        = "bulk"
+)
+
+// capturePolicy is how a capture priority reads: the read buffer size and
+// the poll budget, how long after the first byte of a batch capture keeps
+// reading before storing it.
+type capturePolicy struct {
+	bufferSize int
+	coalesce   time.Duration
+}
+
+var capturePolicies = map[string]capturePolicy{
+	CapturePriorityInteractive: {bufferSize: ReadBufferSize},
+	CapturePriorityNormal:      {bufferSize: 4 * ReadBufferSize, coalesce: 2 * time.Millisecond},
+	CapturePriorityBulk:        {bufferSize: 16 * ReadBufferSize, coalesce: 25 * time.Millisecond},
+}
+
+// resolveCapturePriority validates a create's capture priority. Unset, TUI
+// sessions are interactive, so screens redraw without delay, and others
+// normal.
+func resolveCapturePriority(priority string, tui bool) (string, error) {
+	switch {
+	case priority == "" && tui:
+		return CapturePriorityInteractive, nil
+	case priority == "":
+		return CapturePriorityNormal, nil
+	}
+	if _, ok := capturePolicies[priority]; !ok {
+		return "", fmt.Errorf("invalid capture priority %q (expected interactive, normal or bulk)", priority)
+	}
+	return priority, nil
+}
+
+// coalescePoll is how often coalescing looks for more output while the
+// PTY has none pending.
+const coalescePoll = time.Millisecond
+
+// coalesceRead keeps reading into buf after a read of n bytes until buf is
+// full, until passes or the PTY fails, returning the bytes read, the number
+// of reads that returned any, and the error that ended it. PTY reads block
+// (read deadlines do not apply to them), so it only reads what the kernel
+// says is pending.
+func coalesceRead(f *os.File, buf []byte, n int, until time.Time) (int, int, error) {
+	reads := 1
+	for n < len(buf) && time.Now().Before(until) {
+		pending, err := pendingInput(f)
+		if err != nil {
+			break
+		}
+		if pending == 0 {
+			time.Sleep(coalescePoll)
+			continue
+		}
+		m, err := f.Read(buf[n:min(n+pending, len(buf))])
+		if m > 0 {
+			n += m
+			reads++
+		}
+		if err != nil {
+			return n, reads, err
+		}
+	}
+	return n, reads, nil
+}
+
+// pendingInput returns how many bytes a read of f would return without
+// blocking.
+func pendingInput(f *os.File) (int, error) {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var pending int32
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlPendingInput, uintptr(unsafe.Pointer(&pending))) // #nosec G103 -- ioctl needs a pointer to the result
+	}); err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(pending), nil
+}
+
+// captureStats counts how capture pulled a session's output off its PTY,
+// to check a priority does what it says under load. Written only by the
+// session's capture goroutine; kept in memory like traffic.
+type captureStats struct {
+	reads      atomic.Int64
+	batches    atomic.Int64 // stores: one per read unless reads coalesce
+	bytes      atomic.Int64
+	maxBatch   atomic.Int64
+	delayTotal atomic.Int64 // nanoseconds from a batch's first read to it being stored
+	delayMax   atomic.Int64
+}
+
+// record counts one batch of n bytes from reads PTY reads, stored delay
+// after its first byte was read.
+func (c *captureStats) record(reads, n int, delay time.Duration) {
+	c.reads.Add(int64(reads))
+	c.batches.Add(1)
+	c.bytes.Add(int64(n))
+	if int64(n) > c.maxBatch.Load() {
+		c.maxBatch.Store(int64(n))
+	}
+	c.delayTotal.Add(int64(delay))
+	if int64(delay) > c.delayMax.Load() {
+		c.delayMax.Store(int64(delay))
+	}
+}
+
+// CaptureInfo is a session's capture priority and what capture did under it.
+type CaptureInfo struct {
+	Priority      string  `json:"priority"`
+	Reads         int64   `json:"reads"`
+	Batches       int64   `json:"batches"`
+	Bytes         int64   `json:"bytes"`
+	MaxBatchBytes int64   `json:"max_batch_bytes"`
+	AvgDelayMs    float64 `json:"avg_delay_ms"`
+	MaxDelayMs    float64 `json:"max_delay_ms"`
+}
+
+// captureInfo reports the session's capture stats. Callers hold Server.mu.
+func (h *sessionHandle) captureInfo() CaptureInfo {
+	info := CaptureInfo{
+		Priority:      h.capturePriority,
+		Reads:         h.captureStats.reads.Load(),
+		Batches:       h.captureStats.batches.Load(),
+		Bytes:         h.captureStats.bytes.Load(),
+		MaxBatchBytes: h.captureStats.maxBatch.Load(),
+		MaxDelayMs:    float64(h.captureStats.delayMax.Load()) / float64(time.Millisecond),
+	}
+	if info.Batches > 0 {
+		info.AvgDelayMs = float64(h.captureStats.delayTotal.Load()) / float64(info.Batches) / float64(time.Millisecond)
+	}
+	return info
+}
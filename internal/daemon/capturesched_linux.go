@@ -0,0 +1,5 @@
+package daemon
+
+import "syscall"
+
+const ioctlPendingInput = syscall.TIOCINQ
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package daemon
+
+// ioctlPendingInput is FIONREAD, which the syscall package does not define
+// outside Linux.
+const ioctlPendingInput = 0x4004667f
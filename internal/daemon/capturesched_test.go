@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCapturePriority(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	for _, priority := range []string{CapturePriorityInteractive, CapturePriorityBulk} {
+		if _, err := client.Create(priority, CreateOptions{Command: "sh", CapturePriority: priority}); err != nil {
+			t.Fatalf("create %s: %v", priority, err)
+		}
+		defer client.Kill(priority)
+		if err := client.Send(priority, "seq 1 20000; echo flood-$((1+1))", true); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	for _, priority := range []string{CapturePriorityInteractive, CapturePriorityBulk} {
+		waitForOutput(t, client, priority, "flood-2")
+		info, err := client.Info(priority)
+		if err != nil {
+			t.Fatalf("info: %v", err)
+		}
+		c := info.Capture
+		if c == nil || c.Priority != priority {
+			t.Fatalf("%s capture = %+v", priority, c)
+		}
+		if c.Bytes != info.PTYBytesIn {
+			t.Errorf("%s captured %d bytes, pty_bytes_in = %d", priority, c.Bytes, info.PTYBytesIn)
+		}
+		switch priority {
+		case CapturePriorityInteractive:
+			if c.Batches != c.Reads || c.MaxBatchBytes > ReadBufferSize {
+				t.Errorf("interactive capture should store every read: %+v", c)
+			}
+		case CapturePriorityBulk:
+			if c.Batches >= c.Reads || c.MaxBatchBytes <= ReadBufferSize {
+				t.Errorf("bulk capture should coalesce reads under load: %+v", c)
+			}
+		}
+	}
+
+	// TUI sessions default to interactive, others to normal.
+	if _, err := client.Create("tui", CreateOptions{Command: "sh", TUIMode: true}); err != nil {
+		t.Fatalf("create tui: %v", err)
+	}
+	defer client.Kill("tui")
+	if _, err := client.Create("plain", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create plain: %v", err)
+	}
+	defer client.Kill("plain")
+	for name, want := range map[string]string{"tui": CapturePriorityInteractive, "plain": CapturePriorityNormal} {
+		info, err := client.Info(name)
+		if err != nil {
+			t.Fatalf("info: %v", err)
+		}
+		if info.Capture == nil || info.Capture.Priority != want {
+			t.Errorf("%s capture = %+v, want priority %s", name, info.Capture, want)
+		}
+	}
+
+	_, err := client.Create("bad", CreateOptions{Command: "sh", CapturePriority: "urgent"})
+	if err == nil || !strings.Contains(err.Error(), "invalid capture priority") {
+		t.Errorf("create with an unknown priority: %v", err)
+	}
+}
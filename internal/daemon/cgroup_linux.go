@@ -0,0 +1,145 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	cgroupFS = "/sys/fs/cgroup"
+	// cgroupDaemonLeaf is the cgroup the daemon moves its own cgroup's
+	// processes into, so that cgroup can hand controllers to sessions.
+	cgroupDaemonLeaf = "shelli-daemon"
+	cgroupCPUPeriod  = 100000 // µs
+)
+
+var cgroupParent struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// sessionCgroupParent returns the cgroup v2 directory session cgroups are
+// made in: the daemon's own. A cgroup with processes in it cannot enable
+// controllers for its children, so on first use its processes move to a
+// leaf below it (shelli-daemon), as container runtimes do.
+func sessionCgroupParent() (string, error) {
+	cgroupParent.once.Do(func() {
+		cgroupParent.dir, cgroupParent.err = setupCgroupParent()
+	})
+	return cgroupParent.dir, cgroupParent.err
+}
+
+func setupCgroupParent() (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupFS, "cgroup.controllers")); err != nil {
+		return "", errors.New("cgroup v2 is not mounted at " + cgroupFS)
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	var rel string
+	for _, line := range strings.Split(string(data), "\n") {
+		if p, ok := strings.CutPrefix(line, "0::"); ok {
+			rel = p
+			break
+		}
+	}
+	if rel == "" {
+		return "", errors.New("daemon is not in a cgroup v2 hierarchy")
+	}
+
+	dir := filepath.Join(cgroupFS, rel)
+	if filepath.Base(dir) == cgroupDaemonLeaf {
+		// Moved there before a daemon restart.
+		dir = filepath.Dir(dir)
+	} else if rel != "/" {
+		leaf := filepath.Join(dir, cgroupDaemonLeaf)
+		if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+			return "", err
+		}
+		procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+		if err != nil {
+			return "", err
+		}
+		for _, pid := range strings.Fields(string(procs)) {
+			if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(pid), 0644); err != nil {
+				return "", fmt.Errorf("move process %s to %s: %w", pid, leaf, err)
+			}
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+memory +cpu"), 0644); err != nil {
+		return "", fmt.Errorf("enable memory and cpu controllers in %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// newSessionCgroup makes a cgroup for a session with memory.max and cpu.max
+// set from l, and returns its directory ("" when l needs none). Without
+// cgroup v2 the rlimits alone apply, unless a CPU percentage was asked for.
+func newSessionCgroup(name string, l *ResourceLimits) (string, error) {
+	if l == nil || (l.MemoryBytes == 0 && l.CPUPercent == 0) {
+		return "", nil
+	}
+	dir, err := makeSessionCgroup(name, l)
+	if err != nil {
+		if l.CPUPercent > 0 {
+			return "", fmt.Errorf("cpu limit needs cgroup v2: %w", err)
+		}
+		log.Printf("limits[%s]: no cgroup, memory limit is per process: %v", name, err)
+		return "", nil
+	}
+	return dir, nil
+}
+
+func makeSessionCgroup(name string, l *ResourceLimits) (string, error) {
+	parent, err := sessionCgroupParent()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(parent, "shelli-"+name)
+	os.Remove(dir) // left empty by a daemon that died
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+	if l.MemoryBytes > 0 {
+		if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(strconv.FormatInt(l.MemoryBytes, 10)), 0644); err != nil {
+			os.Remove(dir)
+			return "", err
+		}
+	}
+	if l.CPUPercent > 0 {
+		quota := fmt.Sprintf("%d %d", l.CPUPercent*cgroupCPUPeriod/100, cgroupCPUPeriod)
+		if err := os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(quota), 0644); err != nil {
+			os.Remove(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// joinCgroup moves a session's process into its cgroup; processes it
+// starts afterwards follow.
+func joinCgroup(dir string, pid int) error {
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("join cgroup: %w", err)
+	}
+	return nil
+}
+
+// removeCgroup removes a session's cgroup once its process has exited. It
+// stays while processes the session left behind still run in it.
+func removeCgroup(dir string) {
+	if dir == "" {
+		return
+	}
+	if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+		log.Printf("limits: remove cgroup %s: %v", dir, err)
+	}
+}
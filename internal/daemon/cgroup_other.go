@@ -0,0 +1,19 @@
+//go:build !linux
+
+package daemon
+
+import "errors"
+
+// newSessionCgroup: only Linux has cgroups; the rlimits alone apply.
+func newSessionCgroup(name string, l *ResourceLimits) (string, error) {
+	if l != nil && l.CPUPercent > 0 {
+		return "", errors.New("cpu limit needs cgroup v2 (Linux only)")
+	}
+	return "", nil
+}
+
+func joinCgroup(dir string, pid int) error {
+	return errors.New("cgroups are only supported on Linux")
+}
+
+func removeCgroup(dir string) {}
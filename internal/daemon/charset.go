@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// lookupEncoding resolves a charset name (WHATWG labels such as latin1,
+// shift_jis, euc-kr, gbk, koi8-r). UTF-8 returns nil: nothing to
+// transcode.
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	if canonical, _ := htmlindex.Name(enc); strings.EqualFold(canonical, "utf-8") {
+		return nil, nil
+	}
+	return enc, nil
+}
+
+// outputDecoder transcodes a session's PTY output to UTF-8 as it streams
+// in. A multibyte character split across two reads is held back until the
+// rest arrives; one left incomplete when the process exits is dropped.
+type outputDecoder struct {
+	t       transform.Transformer
+	pending []byte
+}
+
+func newOutputDecoder(enc encoding.Encoding) *outputDecoder {
+	return &outputDecoder{t: enc.NewDecoder()}
+}
+
+// decode returns the UTF-8 text for p plus any bytes held back from the
+// previous call.
+func (d *outputDecoder) decode(p []byte) []byte {
+	src := append(d.pending, p...)
+	d.pending = nil
+	out := make([]byte, 0, len(src)*2)
+	dst := make([]byte, len(src)*4+utf8Slack)
+	for {
+		nDst, nSrc, err := d.t.Transform(dst, src, false)
+		out = append(out, dst[:nDst]...)
+		src = src[nSrc:]
+		switch {
+		case errors.Is(err, transform.ErrShortDst):
+			continue
+		case errors.Is(err, transform.ErrShortSrc):
+			d.pending = append([]byte(nil), src...)
+		}
+		return out
+	}
+}
+
+// utf8Slack leaves room for a replacement character when a single source
+// byte expands.
+const utf8Slack = 16
+
+// encodeInput converts UTF-8 input to the session's encoding.
+func encodeInput(enc encoding.Encoding, name, input string) (string, error) {
+	out, err := enc.NewEncoder().String(input)
+	if err != nil {
+		return "", fmt.Errorf("input is not representable in %s", name)
+	}
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutputDecoderSplitsMultibyte(t *testing.T) {
+	enc, err := lookupEncoding("shift_jis")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	// "日本" in Shift_JIS, split inside the second character.
+	raw := []byte{0x93, 0xfa, 0x96, 0x7b}
+	d := newOutputDecoder(enc)
+	got := string(d.decode(raw[:3])) + string(d.decode(raw[3:]))
+	if got != "日本" {
+		t.Errorf("decoded %q, want %q", got, "日本")
+	}
+}
+
+func TestLookupEncoding(t *testing.T) {
+	if enc, err := lookupEncoding("utf-8"); err != nil || enc != nil {
+		t.Errorf("utf-8: got %v, %v; want no transcoding", enc, err)
+	}
+	if _, err := lookupEncoding("klingon"); err == nil {
+		t.Error("expected error for unknown encoding")
+	}
+}
+
+func TestSessionEncoding(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("latin", CreateOptions{Command: "sh", Encoding: "latin1"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("latin")
+
+	// \351 is é in latin1; the shell prints the raw byte.
+	if err := client.Send("latin", `printf 'caf\351-done\n'`, true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "latin", "café-done")
+
+	// Input goes out as latin1 and its echo comes back as UTF-8.
+	if err := client.Send("latin", "echo naïve-input", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "latin", "\nnaïve-input")
+
+	if err := client.Send("latin", "echo 日本", true); err == nil || !strings.Contains(err.Error(), "not representable") {
+		t.Errorf("send of unencodable input: err = %v", err)
+	}
+
+	info, err := client.Info("latin")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.Encoding != "latin1" {
+		t.Errorf("info encoding = %q, want latin1", info.Encoding)
+	}
+
+	if _, err := client.Create("bad-enc", CreateOptions{Command: "sh", Encoding: "klingon"}); err == nil {
+		client.Kill("bad-enc")
+		t.Error("expected error for unknown encoding")
+	}
+}
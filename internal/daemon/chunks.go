@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ChunkTimeGranularity is how close together appends may arrive to share
+// one timing record. Programs often write a line in several small pieces;
+// recording each would cost more than the output itself.
+const ChunkTimeGranularity = 10 * time.Millisecond
+
+// Chunk records when output was stored: the bytes from Offset up to the next
+// chunk's offset were appended at At. Offsets index the stored output, so
+// they move back when output is dropped from the front.
+type Chunk struct {
+	Offset int64     `json:"offset"`
+	At     time.Time `json:"at"`
+}
+
+// addChunk records a write at offset, unless the last chunk is recent
+// enough to cover it.
+func addChunk(chunks []Chunk, offset int64, at time.Time) []Chunk {
+	if n := len(chunks); n > 0 && at.Sub(chunks[n-1].At) < ChunkTimeGranularity {
+		return chunks
+	}
+	return append(chunks, Chunk{Offset: offset, At: at})
+}
+
+// dropChunks moves chunks back after n bytes were dropped from the front of
+// the output. The chunk the cut falls into is kept, starting at 0.
+func dropChunks(chunks []Chunk, n int64) []Chunk {
+	first := 0
+	for first+1 < len(chunks) && chunks[first+1].Offset <= n {
+		first++
+	}
+	kept := make([]Chunk, 0, len(chunks)-first)
+	for _, c := range chunks[first:] {
+		kept = append(kept, Chunk{Offset: max(0, c.Offset-n), At: c.At})
+	}
+	return kept
+}
+
+// clipChunks keeps the chunks that start within the first size bytes, for
+// output cut back to size.
+func clipChunks(chunks []Chunk, size int64) []Chunk {
+	kept := make([]Chunk, 0, len(chunks))
+	for _, c := range chunks {
+		if c.Offset >= size && len(kept) > 0 {
+			break
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// scaleChunks maps chunks onto output rewritten from oldSize to newSize
+// bytes in the same order (e.g. compacted), in proportion. Times stay in
+// order but are only approximate afterwards.
+func scaleChunks(chunks []Chunk, oldSize, newSize int64) []Chunk {
+	if oldSize <= 0 {
+		return clipChunks(chunks, newSize)
+	}
+	scaled := make([]Chunk, 0, len(chunks))
+	for _, c := range chunks {
+		offset := c.Offset * newSize / oldSize
+		if n := len(scaled); n > 0 && scaled[n-1].Offset == offset {
+			continue // keep the earlier time for the merged bytes
+		}
+		scaled = append(scaled, Chunk{Offset: offset, At: c.At})
+	}
+	return scaled
+}
+
+// chunkOffsetAt returns the offset of the first chunk stored at or after t,
+// or ok=false when all chunks are older.
+func chunkOffsetAt(chunks []Chunk, t time.Time) (offset int64, ok bool) {
+	for _, c := range chunks {
+		if !c.At.Before(t) {
+			return c.Offset, true
+		}
+	}
+	return 0, false
+}
+
+// sinceOffset is where a session's output stored at or after since starts:
+// its size when nothing is that recent.
+func sinceOffset(storage OutputStorage, name string, since time.Time) (int64, error) {
+	size, err := storage.Size(name)
+	if err != nil {
+		return 0, fmt.Errorf("get size: %v", err)
+	}
+	chunks, err := storage.Chunks(name)
+	if err != nil {
+		return 0, fmt.Errorf("read chunk times: %v", err)
+	}
+	if len(chunks) == 0 && size > 0 {
+		return 0, fmt.Errorf("session %q has no output times recorded", name)
+	}
+	if offset, ok := chunkOffsetAt(chunks, since); ok {
+		return min(offset, size), nil
+	}
+	return size, nil
+}
+
+// ParseSince reads a read-since argument: a duration back from now (2m,
+// 90s) or an RFC 3339 time.
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("invalid since %q: duration must not be negative", s)
+		}
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %q: want a duration like 2m or an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// chunkTimeAt returns when the byte at offset was stored: the time of the
+// last chunk starting at or before it.
+func chunkTimeAt(chunks []Chunk, offset int64) (time.Time, bool) {
+	i := sort.Search(len(chunks), func(i int) bool { return chunks[i].Offset > offset })
+	if i == 0 {
+		return time.Time{}, false
+	}
+	return chunks[i-1].At, true
+}
@@ -0,0 +1,198 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkHelpers(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+
+	var chunks []Chunk
+	chunks = addChunk(chunks, 0, at(0))
+	chunks = addChunk(chunks, 5, at(0).Add(ChunkTimeGranularity/2)) // coalesced
+	chunks = addChunk(chunks, 10, at(1))
+	chunks = addChunk(chunks, 20, at(2))
+	if len(chunks) != 3 {
+		t.Fatalf("addChunk kept %d chunks, want 3: %+v", len(chunks), chunks)
+	}
+
+	dropped := dropChunks(chunks, 15)
+	if len(dropped) != 2 || dropped[0].Offset != 0 || !dropped[0].At.Equal(at(1)) || dropped[1].Offset != 5 {
+		t.Errorf("dropChunks(15) = %+v", dropped)
+	}
+
+	clipped := clipChunks(chunks, 10)
+	if len(clipped) != 1 || clipped[0].Offset != 0 {
+		t.Errorf("clipChunks(10) = %+v", clipped)
+	}
+
+	scaled := scaleChunks(chunks, 40, 4)
+	if len(scaled) != 3 || scaled[1].Offset != 1 || scaled[2].Offset != 2 {
+		t.Errorf("scaleChunks(40, 4) = %+v", scaled)
+	}
+	merged := scaleChunks(chunks, 40, 1)
+	if len(merged) != 1 || !merged[0].At.Equal(at(0)) {
+		t.Errorf("scaleChunks(40, 1) = %+v, want one chunk at the earliest time", merged)
+	}
+}
+
+func TestStorageRecordsChunks(t *testing.T) {
+	file, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]OutputStorage{"memory": NewMemoryStorage(0), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			if err := s.Create("c", &SessionMeta{Name: "c"}); err != nil {
+				t.Fatal(err)
+			}
+			s.Append("c", []byte("first "))
+			time.Sleep(2 * ChunkTimeGranularity)
+			s.Append("c", []byte("second"))
+
+			chunks, err := s.Chunks("c")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunks) != 2 || chunks[0].Offset != 0 || chunks[1].Offset != 6 {
+				t.Fatalf("chunks = %+v, want offsets 0 and 6", chunks)
+			}
+			if !chunks[1].At.After(chunks[0].At) {
+				t.Errorf("chunk times not increasing: %+v", chunks)
+			}
+
+			if err := s.Clear("c"); err != nil {
+				t.Fatal(err)
+			}
+			if chunks, _ := s.Chunks("c"); len(chunks) != 0 {
+				t.Errorf("chunks after clear = %+v", chunks)
+			}
+		})
+	}
+}
+
+func TestMemoryStorageDropsChunks(t *testing.T) {
+	s := NewMemoryStorage(10)
+	s.Create("m", &SessionMeta{Name: "m"})
+	s.Append("m", []byte("aaaaaaaa"))
+	time.Sleep(2 * ChunkTimeGranularity)
+	s.Append("m", []byte("bbbbbbbb"))
+
+	chunks, _ := s.Chunks("m")
+	if len(chunks) != 2 || chunks[0].Offset != 0 || chunks[1].Offset != 2 {
+		t.Fatalf("chunks = %+v, want offsets 0 and 2 after overflow", chunks)
+	}
+}
+
+func TestWriteAsciicast(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &SessionBundle{
+		Meta:   SessionMeta{Name: "rec", CreatedAt: created, Cols: 100, Rows: 30},
+		Output: []byte("$ echo hé\r\nhé\r\n"),
+		Chunks: []Chunk{
+			{Offset: 0, At: created.Add(500 * time.Millisecond)},
+			{Offset: 9, At: created.Add(2 * time.Second)}, // splits "é"
+			{Offset: 12, At: created.Add(time.Second)},    // clock went back
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAsciicast(&buf, b); err != nil {
+		t.Fatalf("WriteAsciicast: %v", err)
+	}
+
+	sc := bufio.NewScanner(&buf)
+	sc.Scan()
+	var header asciicastHeader
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	if header.Version != 2 || header.Width != 100 || header.Height != 30 || header.Timestamp != created.Unix() {
+		t.Errorf("header = %+v", header)
+	}
+
+	var times []float64
+	var text strings.Builder
+	for sc.Scan() {
+		var event []interface{}
+		if err := json.Unmarshal(sc.Bytes(), &event); err != nil {
+			t.Fatalf("event %q: %v", sc.Text(), err)
+		}
+		times = append(times, event[0].(float64))
+		if event[1] != "o" {
+			t.Errorf("event type = %v, want o", event[1])
+		}
+		text.WriteString(event[2].(string))
+	}
+	if text.String() != string(b.Output) {
+		t.Errorf("output = %q, want %q", text.String(), b.Output)
+	}
+	want := []float64{0.5, 2, 2}
+	if len(times) != len(want) {
+		t.Fatalf("times = %v, want %v", times, want)
+	}
+	for i := range want {
+		if times[i] != want[i] {
+			t.Errorf("times = %v, want %v", times, want)
+			break
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if got, err := ParseSince("2m", now); err != nil || !got.Equal(now.Add(-2*time.Minute)) {
+		t.Errorf("ParseSince(2m) = %v, %v", got, err)
+	}
+	if got, err := ParseSince("2025-01-01T11:00:00Z", now); err != nil || !got.Equal(now.Add(-time.Hour)) {
+		t.Errorf("ParseSince(rfc3339) = %v, %v", got, err)
+	}
+	for _, s := range []string{"-1m", "yesterday"} {
+		if _, err := ParseSince(s, now); err == nil {
+			t.Errorf("ParseSince(%q) should fail", s)
+		}
+	}
+}
+
+func TestReadSince(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("since", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("since")
+
+	if err := client.Send("since", "echo old-$((1+1))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "since", "old-2")
+	time.Sleep(200 * time.Millisecond)
+
+	mark := time.Now()
+	if err := client.Send("since", "echo new-$((2+2))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "since", "new-4")
+
+	output, pos, err := client.ReadSince("since", mark)
+	if err != nil {
+		t.Fatalf("ReadSince: %v", err)
+	}
+	if !strings.Contains(output, "new-4") || strings.Contains(output, "old-2") {
+		t.Errorf("since output = %q, want only the new command", output)
+	}
+	if all, _, _ := client.Read("since", ReadModeAll, 0, 0); pos != len(all) {
+		t.Errorf("position = %d, want %d", pos, len(all))
+	}
+
+	if output, _, err := client.ReadSince("since", time.Now().Add(time.Minute)); err != nil || output != "" {
+		t.Errorf("future since = %q, %v, want empty", output, err)
+	}
+}
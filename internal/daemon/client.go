@@ -1,11 +1,14 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/schovi/shelli/internal/wait"
@@ -13,20 +16,51 @@ import (
 
 type Client struct {
 	customSocketPath string
+
+	// dialer reaches a daemon given by $SHELLI_HOST (or
+	// NewClientWithDialer) instead of the local socket; host names it in
+	// errors. A remote daemon is never started by the client.
+	dialer  Dialer
+	host    string
+	hostErr error
+	token   string
 }
 
+// NewClient returns a client of the local daemon, or of the one $SHELLI_HOST
+// points at. Requests carry $SHELLI_TOKEN, or for the local daemon its token
+// file's, if any.
 func NewClient() *Client {
-	return &Client{}
+	host := os.Getenv(HostEnv)
+	if host == "" {
+		return &Client{token: localToken()}
+	}
+	dialer, err := ParseHost(host)
+	return &Client{dialer: dialer, host: host, hostErr: err, token: os.Getenv(TokenEnv)}
 }
 
 func NewClientWithSocketPath(path string) *Client {
-	return &Client{customSocketPath: path}
+	return &Client{customSocketPath: path, token: localToken()}
+}
+
+// NewClientWithDialer returns a client of the daemon dial reaches, sending
+// token with each request.
+func NewClientWithDialer(dial Dialer, host, token string) *Client {
+	return &Client{dialer: dial, host: host, token: token}
 }
 
 func (c *Client) EnsureDaemon() error {
 	if c.Ping() {
 		return nil
 	}
+	if c.hostErr != nil {
+		return c.hostErr
+	}
+	if c.dialer != nil {
+		if err := c.pingErr(); err != nil {
+			return fmt.Errorf("daemon at %s: %w", c.host, err)
+		}
+		return fmt.Errorf("daemon at %s is not answering", c.host)
+	}
 
 	exePath, err := os.Executable()
 	if err != nil {
@@ -62,19 +96,65 @@ func (c *Client) EnsureDaemon() error {
 	return fmt.Errorf("daemon failed to start within %s. Socket: %s. Try: rm %s && shelli daemon", DaemonStartTimeout, sockPath, sockPath)
 }
 
+// Ping reports whether the daemon answers. It makes a single attempt.
 func (c *Client) Ping() bool {
-	resp, err := c.send(Request{Action: "ping"})
-	return err == nil && resp.Success
+	return c.pingErr() == nil
+}
+
+func (c *Client) pingErr() error {
+	resp, _, err := c.roundTrip(Request{Action: "ping", Version: ProtocolVersion})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
 }
 
 type CreateOptions struct {
-	Command     string
-	Env         []string
-	Cwd         string
-	Cols        int
-	Rows        int
-	TUIMode     bool
-	IfNotExists bool
+	Command         string
+	Env             []string
+	Cwd             string
+	Cols            int
+	Rows            int
+	TUIMode         bool
+	IfNotExists     bool
+	FrameHistory    int
+	Scrollback      int
+	CaptureRaw      string
+	FrameBoundaries []string
+	// Workspace tags the session with a git repo root. Defaults to the
+	// repo containing the caller's working directory.
+	Workspace string
+	// Nice and IOClass lower the session's CPU and I/O priority (nil and ""
+	// leave them inherited from the daemon).
+	Nice    *int
+	IOClass string
+	// Limits caps the session's memory and CPU (see ResourceLimits).
+	Limits *ResourceLimits
+	// Encoding is the charset the program speaks when it is not UTF-8
+	// (e.g. latin1, shift_jis). Output is stored as UTF-8 and input is
+	// converted back.
+	Encoding string
+	// MirrorInput records everything sent to the session inline in its
+	// buffer (see MirrorOpen). Not available in TUI mode.
+	MirrorInput bool
+	// SwallowOutputUntil holds back startup output until a regex matches
+	// it, or for a number of milliseconds, keeping it as the session's
+	// banner instead of in the buffer. Input ends it early.
+	SwallowOutputUntil string
+	// CapturePriority is how the daemon reads the session's output:
+	// CapturePriorityInteractive, CapturePriorityNormal or
+	// CapturePriorityBulk ("" for interactive in TUI mode, else normal).
+	CapturePriority string
+	// OnExit are shell commands run when the session's process exits, as
+	// the daemon's exit hooks are (see HookExit).
+	OnExit []string
+	// SnapshotMode is how snapshots of a TUI session get a fresh screen:
+	// SnapshotModeResize or SnapshotModePassive ("" for resize, unless the
+	// same command crashed on a snapshot resize before).
+	SnapshotMode string
 }
 
 func (c *Client) Create(name string, opts CreateOptions) (map[string]interface{}, error) {
@@ -82,16 +162,59 @@ func (c *Client) Create(name string, opts CreateOptions) (map[string]interface{}
 		return nil, err
 	}
 
+	workspace := opts.Workspace
+	if workspace == "" {
+		workspace = CurrentWorkspace()
+	}
+
 	resp, err := c.send(Request{
-		Action:      "create",
-		Name:        name,
-		Command:     opts.Command,
-		Env:         opts.Env,
-		Cwd:         opts.Cwd,
-		Cols:        opts.Cols,
-		Rows:        opts.Rows,
-		TUIMode:     opts.TUIMode,
-		IfNotExists: opts.IfNotExists,
+		Action:             "create",
+		Name:               name,
+		Command:            opts.Command,
+		Env:                opts.Env,
+		Cwd:                opts.Cwd,
+		Cols:               opts.Cols,
+		Rows:               opts.Rows,
+		TUIMode:            opts.TUIMode,
+		IfNotExists:        opts.IfNotExists,
+		FrameHistory:       opts.FrameHistory,
+		Scrollback:         opts.Scrollback,
+		CaptureRaw:         opts.CaptureRaw,
+		FrameBoundaries:    opts.FrameBoundaries,
+		Workspace:          workspace,
+		Nice:               opts.Nice,
+		IOClass:            opts.IOClass,
+		Limits:             opts.Limits,
+		Encoding:           opts.Encoding,
+		MirrorInput:        opts.MirrorInput,
+		SwallowOutputUntil: opts.SwallowOutputUntil,
+		CapturePriority:    opts.CapturePriority,
+		OnExit:             opts.OnExit,
+		SnapshotMode:       opts.SnapshotMode,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+	return extractMapData(resp)
+}
+
+// Sibling creates session name next to session from: in the directory
+// from's process is in now, with from's create environment plus env and
+// at from's terminal size, running command (default: the user's shell).
+func (c *Client) Sibling(from, name, command string, env []string) (map[string]interface{}, error) {
+	if err := ValidateSessionName(name); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(Request{
+		Action:  "sibling",
+		From:    from,
+		Name:    name,
+		Command: command,
+		Env:     env,
 	})
 	if err != nil {
 		return nil, err
@@ -103,7 +226,16 @@ func (c *Client) Create(name string, opts CreateOptions) (map[string]interface{}
 }
 
 func (c *Client) List() ([]SessionInfo, error) {
-	resp, err := c.send(Request{Action: "list"})
+	return c.list(false)
+}
+
+// ListVerbose lists sessions with a health check of each.
+func (c *Client) ListVerbose() ([]SessionInfo, error) {
+	return c.list(true)
+}
+
+func (c *Client) list(verbose bool) ([]SessionInfo, error) {
+	resp, err := c.send(Request{Action: "list", Verbose: verbose})
 	if err != nil {
 		return nil, err
 	}
@@ -123,184 +255,190 @@ func (c *Client) List() ([]SessionInfo, error) {
 }
 
 func (c *Client) Read(name, mode string, headLines, tailLines int) (string, int, error) {
-	resp, err := c.send(Request{
-		Action:    "read",
-		Name:      name,
-		Mode:      mode,
-		HeadLines: headLines,
-		TailLines: tailLines,
-	})
-	if err != nil {
-		return "", 0, err
-	}
-	if !resp.Success {
-		return "", 0, fmt.Errorf("%s", resp.Error)
-	}
-
-	data, err := extractMapData(resp)
-	if err != nil {
-		return "", 0, err
-	}
-
-	output, ok := data["output"].(string)
-	if !ok {
-		return "", 0, fmt.Errorf("missing or invalid output field")
-	}
-	posFloat, ok := data["position"].(float64)
-	if !ok {
-		return "", 0, fmt.Errorf("missing or invalid position field")
-	}
-	return output, int(posFloat), nil
+	return c.ReadWithCursor(name, mode, "", headLines, tailLines)
 }
 
-func (c *Client) Snapshot(name string, settleMs, timeoutSec, headLines, tailLines int) (string, int, error) {
-	resp, err := c.send(Request{
-		Action:     "read",
-		Name:       name,
-		Snapshot:   true,
-		SettleMs:   settleMs,
-		TimeoutSec: timeoutSec,
-		HeadLines:  headLines,
-		TailLines:  tailLines,
-	})
-	if err != nil {
-		return "", 0, err
-	}
-	if !resp.Success {
-		return "", 0, fmt.Errorf("%s", resp.Error)
-	}
+// ReadResult is a read with the history-loss counter of new-mode reads.
+type ReadResult struct {
+	Output   string
+	Position int
+	// Truncations counts how often unread output was dropped (clear, or a
+	// memory buffer wrapping) since this reader's previous new-mode read.
+	Truncations int
+	// More is set by ReadPage when output goes on past Position; Next is
+	// then the token that reads the next page.
+	More bool
+	Next string
+}
 
-	data, err := extractMapData(resp)
-	if err != nil {
-		return "", 0, err
-	}
+// Stream calls fn with a session's output as the daemon pushes it, until the
+// session stops (fn then gets a final chunk with End set), fn returns an
+// error, or ctx is done. It starts with the unread output and, like a read,
+// moves the read position (or cursor's) along.
+func (c *Client) Stream(ctx context.Context, name, cursor string, fn func(StreamChunk) error) error {
+	return c.stream(ctx, Request{Action: "stream", Name: name, Cursor: cursor}, fn)
+}
 
-	output, ok := data["output"].(string)
-	if !ok {
-		return "", 0, fmt.Errorf("missing or invalid output field")
-	}
-	posFloat, ok := data["position"].(float64)
-	if !ok {
-		return "", 0, fmt.Errorf("missing or invalid position field")
-	}
-	return output, int(posFloat), nil
+// Peek is Stream without consuming: it starts with the output stored from
+// offset on and leaves the read position and cursors alone.
+func (c *Client) Peek(ctx context.Context, name string, offset int64, fn func(StreamChunk) error) error {
+	return c.stream(ctx, Request{Action: "stream", Name: name, Mode: StreamModePeek, Offset: offset}, fn)
 }
 
-func (c *Client) Send(name, input string, newline bool) error {
-	resp, err := c.send(Request{
-		Action:  "send",
-		Name:    name,
-		Input:   input,
-		Newline: newline,
-	})
+func (c *Client) stream(ctx context.Context, req Request, fn func(StreamChunk) error) error {
+	conn, err := c.dial()
 	if err != nil {
 		return err
 	}
-	if !resp.Success {
-		return fmt.Errorf("%s", resp.Error)
-	}
-	return nil
-}
+	defer conn.Close()
 
-func (c *Client) Stop(name string) error {
-	resp, err := c.send(Request{
-		Action: "stop",
-		Name:   name,
-	})
-	if err != nil {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req.Version = ProtocolVersion
+	req.Client = defaultClientID()
+	req.Token = c.token
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
 		return err
 	}
-	if !resp.Success {
-		return fmt.Errorf("%s", resp.Error)
+
+	dec := json.NewDecoder(conn)
+	for {
+		var resp struct {
+			Success bool        `json:"success"`
+			Error   string      `json:"error,omitempty"`
+			Data    StreamChunk `json:"data"`
+		}
+		if err := dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("stream: %w", err)
+		}
+		if !resp.Success {
+			return fmt.Errorf("%s", resp.Error)
+		}
+		if err := fn(resp.Data); err != nil {
+			return err
+		}
+		if resp.Data.End {
+			return nil
+		}
 	}
-	return nil
 }
 
-func (c *Client) Kill(name string) error {
-	resp, err := c.send(Request{
-		Action: "kill",
-		Name:   name,
-	})
+// Events calls fn with the lifecycle events of sessions matching pattern
+// (a path.Match pattern, "" for all) as the daemon pushes them, until fn
+// returns an error or ctx is done. ready, if not nil, is called once the
+// daemon has subscribed, so events after it are not missed.
+func (c *Client) Events(ctx context.Context, pattern string, ready func(), fn func(LifecycleEvent) error) error {
+	conn, err := c.dial()
 	if err != nil {
 		return err
 	}
-	if !resp.Success {
-		return fmt.Errorf("%s", resp.Error)
-	}
-	return nil
-}
+	defer conn.Close()
 
-type SearchRequest struct {
-	Name       string
-	Pattern    string
-	Before     int
-	After      int
-	IgnoreCase bool
-	StripANSI  bool
-}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
 
-type SearchMatch struct {
-	LineNumber int      `json:"line_number"`
-	Line       string   `json:"line"`
-	Before     []string `json:"before"`
-	After      []string `json:"after"`
-}
+	req := Request{Action: "events", Name: pattern, Version: ProtocolVersion, Client: defaultClientID(), Token: c.token}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
 
-type SearchResponse struct {
-	Matches      []SearchMatch `json:"matches"`
-	TotalMatches int           `json:"total_matches"`
+	dec := json.NewDecoder(conn)
+	for first := true; ; first = false {
+		var resp struct {
+			Success bool            `json:"success"`
+			Error   string          `json:"error,omitempty"`
+			Data    *LifecycleEvent `json:"data"`
+		}
+		if err := dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("events: %w", err)
+		}
+		if !resp.Success {
+			return fmt.Errorf("%s", resp.Error)
+		}
+		if first {
+			if ready != nil {
+				ready()
+			}
+			continue
+		}
+		if resp.Data == nil {
+			continue
+		}
+		if err := fn(*resp.Data); err != nil {
+			return err
+		}
+	}
 }
 
-type InfoResponse struct {
-	Name          string           `json:"name"`
-	State         string           `json:"state"`
-	PID           int              `json:"pid"`
-	Command       string           `json:"command"`
-	CreatedAt     string           `json:"created_at"`
-	StoppedAt     string           `json:"stopped_at,omitempty"`
-	BytesBuffered int64            `json:"bytes_buffered"`
-	ReadPosition  int64            `json:"read_position"`
-	Cols          int              `json:"cols"`
-	Rows          int              `json:"rows"`
-	TUIMode       bool             `json:"tui_mode,omitempty"`
-	Uptime        float64          `json:"uptime_seconds,omitempty"`
-	Cursors       map[string]int64 `json:"cursors,omitempty"`
+// ReadDetailed reads like ReadWithCursor and also returns the truncation
+// counter.
+func (c *Client) ReadDetailed(name, mode, cursor string, headLines, tailLines int) (*ReadResult, error) {
+	return c.ReadNormalized(name, mode, cursor, "", headLines, tailLines)
 }
 
-func (c *Client) Clear(name string) error {
+// ReadNormalized is ReadDetailed with line endings rewritten for the
+// newlines mode (see NormalizeNewlines) before head/tail are applied.
+func (c *Client) ReadNormalized(name, mode, cursor, newlines string, headLines, tailLines int) (*ReadResult, error) {
 	resp, err := c.send(Request{
-		Action: "clear",
-		Name:   name,
+		Action:    "read",
+		Name:      name,
+		Mode:      mode,
+		Cursor:    cursor,
+		HeadLines: headLines,
+		TailLines: tailLines,
+		Newlines:  newlines,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !resp.Success {
-		return fmt.Errorf("%s", resp.Error)
+		return nil, fmt.Errorf("%s", resp.Error)
 	}
-	return nil
-}
 
-func (c *Client) Resize(name string, cols, rows int) error {
-	resp, err := c.send(Request{
-		Action: "resize",
-		Name:   name,
-		Cols:   cols,
-		Rows:   rows,
-	})
+	data, err := extractMapData(resp)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !resp.Success {
-		return fmt.Errorf("%s", resp.Error)
+
+	output, ok := data["output"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing or invalid output field")
 	}
-	return nil
+	posFloat, ok := data["position"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("missing or invalid position field")
+	}
+	truncations, _ := data["truncations_since_last_read"].(float64)
+	return &ReadResult{Output: output, Position: int(posFloat), Truncations: int(truncations)}, nil
 }
 
-func (c *Client) Info(name string) (*InfoResponse, error) {
+// LinesResult is a read returned as line records.
+type LinesResult struct {
+	Lines              []LineRecord `json:"lines"`
+	Position           int          `json:"position"`
+	LongLinesTruncated int          `json:"long_lines_truncated,omitempty"`
+	Truncations        int          `json:"truncations_since_last_read,omitempty"`
+}
+
+// ReadLines reads like ReadNormalized but returns the output as line
+// records with line numbers and arrival times, ANSI stripped per line.
+func (c *Client) ReadLines(name, mode, cursor, newlines string, headLines, tailLines int) (*LinesResult, error) {
 	resp, err := c.send(Request{
-		Action: "info",
-		Name:   name,
+		Action:    "read",
+		Name:      name,
+		Mode:      mode,
+		Cursor:    cursor,
+		HeadLines: headLines,
+		TailLines: tailLines,
+		Newlines:  newlines,
+		Lines:     true,
 	})
 	if err != nil {
 		return nil, err
@@ -309,23 +447,56 @@ func (c *Client) Info(name string) (*InfoResponse, error) {
 		return nil, fmt.Errorf("%s", resp.Error)
 	}
 
-	data, _ := json.Marshal(resp.Data)
-	var result InfoResponse
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal data: %w", err)
+	}
+	var result LinesResult
 	if err := json.Unmarshal(data, &result); err != nil {
-		return nil, fmt.Errorf("parse response: %w", err)
+		return nil, fmt.Errorf("unmarshal lines: %w", err)
 	}
 	return &result, nil
 }
 
-func (c *Client) Search(req SearchRequest) (*SearchResponse, error) {
+// Snapshot forces a TUI redraw and returns the settled screen. With holdSize
+// the PTY is not resized to trigger the redraw.
+func (c *Client) Snapshot(name string, settleMs, timeoutSec, headLines, tailLines int, holdSize bool) (string, int, error) {
+	result, err := c.SnapshotDetailed(name, settleMs, timeoutSec, headLines, tailLines, holdSize)
+	if err != nil {
+		return "", 0, err
+	}
+	return result.Output, int(result.Position), nil
+}
+
+// SnapshotResult is a snapshot with how it went: whether the screen
+// settled before the timeout, and what it took.
+type SnapshotResult struct {
+	Output   string `json:"output"`
+	Position int64  `json:"position"`
+	// Settled is false when the screen was still changing (or empty) when
+	// the timeout ran out; Output is then the screen at that moment.
+	Settled       bool  `json:"settled"`
+	WaitedMs      int64 `json:"waited_ms"`
+	ResizeCycles  int   `json:"resize_cycles"`
+	BytesCaptured int64 `json:"bytes_captured"`
+	// FromFrame is set when the screen was empty and Output is the latest
+	// captured frame instead.
+	FromFrame bool `json:"from_frame,omitempty"`
+	SizeHeld  bool `json:"size_held,omitempty"`
+}
+
+// SnapshotDetailed takes a snapshot like Snapshot and reports whether it
+// settled.
+func (c *Client) SnapshotDetailed(name string, settleMs, timeoutSec, headLines, tailLines int, holdSize bool) (*SnapshotResult, error) {
 	resp, err := c.send(Request{
-		Action:     "search",
-		Name:       req.Name,
-		Pattern:    req.Pattern,
-		Before:     req.Before,
-		After:      req.After,
-		IgnoreCase: req.IgnoreCase,
-		StripANSI:  req.StripANSI,
+		Action:     "read",
+		Name:       name,
+		Snapshot:   true,
+		HoldSize:   holdSize,
+		SettleMs:   settleMs,
+		TimeoutSec: timeoutSec,
+		HeadLines:  headLines,
+		TailLines:  tailLines,
 	})
 	if err != nil {
 		return nil, err
@@ -335,19 +506,18 @@ func (c *Client) Search(req SearchRequest) (*SearchResponse, error) {
 	}
 
 	data, _ := json.Marshal(resp.Data)
-	var result SearchResponse
+	var result SnapshotResult
 	if err := json.Unmarshal(data, &result); err != nil {
 		return nil, fmt.Errorf("parse response: %w", err)
 	}
 	return &result, nil
 }
 
-func (c *Client) ReadWithCursor(name, mode, cursor string, headLines, tailLines int) (string, int, error) {
+func (c *Client) ReadFrame(name string, frame, headLines, tailLines int) (string, int, error) {
 	resp, err := c.send(Request{
 		Action:    "read",
 		Name:      name,
-		Mode:      mode,
-		Cursor:    cursor,
+		Frame:     frame,
 		HeadLines: headLines,
 		TailLines: tailLines,
 	})
@@ -374,109 +544,1889 @@ func (c *Client) ReadWithCursor(name, mode, cursor string, headLines, tailLines
 	return output, int(posFloat), nil
 }
 
-func (c *Client) Size(name string) (int, error) {
-	resp, err := c.send(Request{Action: "size", Name: name})
+// ReadRange returns limit bytes of the buffer starting at offset (all the
+// rest when limit is 0) and the position just past them. It does not move
+// the read position.
+func (c *Client) ReadRange(name string, offset, limit int) (string, int, error) {
+	resp, err := c.send(Request{
+		Action: "read",
+		Name:   name,
+		Mode:   ReadModeRange,
+		Offset: int64(offset),
+		Limit:  int64(limit),
+	})
 	if err != nil {
-		return 0, err
+		return "", 0, err
 	}
 	if !resp.Success {
-		return 0, fmt.Errorf("%s", resp.Error)
+		return "", 0, fmt.Errorf("%s", resp.Error)
 	}
+
 	data, err := extractMapData(resp)
 	if err != nil {
-		return 0, err
+		return "", 0, err
+	}
+
+	output, ok := data["output"].(string)
+	if !ok {
+		return "", 0, fmt.Errorf("missing or invalid output field")
+	}
+	posFloat, ok := data["position"].(float64)
+	if !ok {
+		return "", 0, fmt.Errorf("missing or invalid position field")
+	}
+	return output, int(posFloat), nil
+}
+
+// PageOptions is one page of a ReadPage read.
+type PageOptions struct {
+	Mode   string
+	Cursor string
+	// Offset is where a range read starts.
+	Offset int64
+	// MaxBytes and MaxLines bound the page.
+	MaxBytes int64
+	MaxLines int
+	// Continue is a previous page's Next; it stands in for Mode, Cursor,
+	// Offset and, unless they are set, MaxBytes and MaxLines.
+	Continue string
+}
+
+// ReadPage reads at most opts.MaxBytes bytes and opts.MaxLines lines of
+// output: for new and all reads from where they start, for range reads from
+// opts.Offset. Position is where the next page starts; a new read moves the
+// read position only that far, so repeated new reads page through a
+// backlog.
+func (c *Client) ReadPage(name string, opts PageOptions) (*ReadResult, error) {
+	resp, err := c.send(Request{
+		Action:   "read",
+		Name:     name,
+		Mode:     opts.Mode,
+		Cursor:   opts.Cursor,
+		Offset:   opts.Offset,
+		MaxBytes: opts.MaxBytes,
+		MaxLines: opts.MaxLines,
+		Continue: opts.Continue,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := extractMapData(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	output, ok := data["output"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing or invalid output field")
+	}
+	posFloat, ok := data["position"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("missing or invalid position field")
+	}
+	truncations, _ := data["truncations_since_last_read"].(float64)
+	more, _ := data["more"].(bool)
+	next, _ := data["next"].(string)
+	return &ReadResult{Output: output, Position: int(posFloat), Truncations: int(truncations), More: more, Next: next}, nil
+}
+
+// ReadTailBytes returns at most the last n bytes of a session's output,
+// starting at a character and escape-sequence boundary. The read position
+// does not move.
+func (c *Client) ReadTailBytes(name string, n int) (string, int, error) {
+	resp, err := c.send(Request{
+		Action: "read",
+		Name:   name,
+		Mode:   ReadModeTail,
+		Limit:  int64(n),
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	if !resp.Success {
+		return "", 0, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := extractMapData(resp)
+	if err != nil {
+		return "", 0, err
+	}
+
+	output, ok := data["output"].(string)
+	if !ok {
+		return "", 0, fmt.Errorf("missing or invalid output field")
+	}
+	posFloat, ok := data["position"].(float64)
+	if !ok {
+		return "", 0, fmt.Errorf("missing or invalid position field")
+	}
+	return output, int(posFloat), nil
+}
+
+// ReadSince returns the output stored at or after since (to the precision
+// of ChunkTimeGranularity) and the position just past it. It does not move
+// the read position.
+func (c *Client) ReadSince(name string, since time.Time) (string, int, error) {
+	resp, err := c.send(Request{
+		Action: "read",
+		Name:   name,
+		Mode:   ReadModeSince,
+		Since:  since.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	if !resp.Success {
+		return "", 0, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := extractMapData(resp)
+	if err != nil {
+		return "", 0, err
+	}
+
+	output, ok := data["output"].(string)
+	if !ok {
+		return "", 0, fmt.Errorf("missing or invalid output field")
+	}
+	posFloat, ok := data["position"].(float64)
+	if !ok {
+		return "", 0, fmt.Errorf("missing or invalid position field")
+	}
+	return output, int(posFloat), nil
+}
+
+// ReadAroundBookmark returns the lines around a bookmark: before lines
+// above the bookmarked line, the line, and after lines below it. It does
+// not move the read position.
+func (c *Client) ReadAroundBookmark(name, bookmark string, before, after int) (string, int, *Bookmark, error) {
+	resp, err := c.send(Request{
+		Action:   "read",
+		Name:     name,
+		Mode:     ReadModeBookmark,
+		Bookmark: bookmark,
+		Before:   before,
+		After:    after,
+	})
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if !resp.Success {
+		return "", 0, nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	var result struct {
+		Output   string    `json:"output"`
+		Position int       `json:"position"`
+		Bookmark *Bookmark `json:"bookmark"`
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("marshal response: %w", err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", 0, nil, fmt.Errorf("unmarshal read result: %w", err)
+	}
+	return result.Output, result.Position, result.Bookmark, nil
+}
+
+func (c *Client) ReadScrollback(name string, headLines, tailLines int) (string, int, error) {
+	resp, err := c.send(Request{
+		Action:           "read",
+		Name:             name,
+		ScreenScrollback: true,
+		HeadLines:        headLines,
+		TailLines:        tailLines,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	if !resp.Success {
+		return "", 0, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := extractMapData(resp)
+	if err != nil {
+		return "", 0, err
+	}
+
+	output, ok := data["output"].(string)
+	if !ok {
+		return "", 0, fmt.Errorf("missing or invalid output field")
+	}
+	posFloat, ok := data["position"].(float64)
+	if !ok {
+		return "", 0, fmt.Errorf("missing or invalid position field")
+	}
+	return output, int(posFloat), nil
+}
+
+type FrameInfo struct {
+	Index      int    `json:"index"`
+	Version    uint64 `json:"version"`
+	CapturedAt string `json:"captured_at"`
+	Lines      int    `json:"lines"`
+}
+
+func (c *Client) Frames(name string) ([]FrameInfo, error) {
+	resp, err := c.send(Request{Action: "frames", Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	var frames []FrameInfo
+	if err := json.Unmarshal(data, &frames); err != nil {
+		return nil, fmt.Errorf("unmarshal frames: %w", err)
+	}
+	return frames, nil
+}
+
+type ImageInfo struct {
+	ID         int    `json:"id"`
+	Protocol   string `json:"protocol"`
+	Name       string `json:"name,omitempty"`
+	Format     string `json:"format,omitempty"`
+	Bytes      int    `json:"bytes"`
+	CapturedAt string `json:"captured_at"`
+}
+
+type ImageData struct {
+	ImageInfo
+	Data []byte `json:"data"`
+}
+
+func (c *Client) Images(name string) ([]ImageInfo, error) {
+	resp, err := c.send(Request{Action: "images", Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	var images []ImageInfo
+	if err := json.Unmarshal(data, &images); err != nil {
+		return nil, fmt.Errorf("unmarshal images: %w", err)
+	}
+	return images, nil
+}
+
+func (c *Client) Image(name string, id int) (*ImageData, error) {
+	resp, err := c.send(Request{Action: "images", Name: name, ImageID: id})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	var img ImageData
+	if err := json.Unmarshal(data, &img); err != nil {
+		return nil, fmt.Errorf("unmarshal image: %w", err)
+	}
+	return &img, nil
+}
+
+type NotificationInfo struct {
+	ID    int    `json:"id"`
+	Kind  string `json:"kind"`
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+	At    string `json:"at"`
+}
+
+// Notifications returns bells and notifications with an ID above afterID.
+func (c *Client) Notifications(name string, afterID int) ([]NotificationInfo, error) {
+	resp, err := c.send(Request{Action: "notifications", Name: name, AfterID: afterID})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	var notes []NotificationInfo
+	if err := json.Unmarshal(data, &notes); err != nil {
+		return nil, fmt.Errorf("unmarshal notifications: %w", err)
+	}
+	return notes, nil
+}
+
+func (c *Client) Send(name, input string, newline bool) error {
+	resp, err := c.send(Request{
+		Action:  "send",
+		Name:    name,
+		Input:   input,
+		Newline: newline,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
+
+// SendOnce is Send under an idempotency key: when a send or exec with the
+// key ran in the session recently, nothing is sent and duplicate is true.
+func (c *Client) SendOnce(name, input string, newline bool, key string) (duplicate bool, err error) {
+	resp, err := c.send(Request{
+		Action:         "send",
+		Name:           name,
+		Input:          input,
+		Newline:        newline,
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		return false, err
+	}
+	if !resp.Success {
+		return false, fmt.Errorf("%s", resp.Error)
+	}
+	data, _ := resp.Data.(map[string]interface{})
+	duplicate, _ = data["duplicate"].(bool)
+	return duplicate, nil
+}
+
+// SendLine sends input followed by the line terminator for an enter mode
+// (EnterAuto, EnterLF, EnterCR).
+func (c *Client) SendLine(name, input, enter string) error {
+	resp, err := c.send(Request{
+		Action:  "send",
+		Name:    name,
+		Input:   input,
+		Newline: true,
+		Enter:   enter,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
+
+func (c *Client) Stop(name string) error {
+	resp, err := c.send(Request{
+		Action: "stop",
+		Name:   name,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
+
+func (c *Client) Kill(name string) error {
+	resp, err := c.send(Request{
+		Action: "kill",
+		Name:   name,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
+
+// DiskUsage reports per-session storage use and stray files in the data dir.
+func (c *Client) DiskUsage() (*UsageReport, error) {
+	resp, err := c.send(Request{Action: "du"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	var report UsageReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("unmarshal usage: %w", err)
+	}
+	return &report, nil
+}
+
+// Metrics returns the daemon's metrics in the Prometheus text format.
+func (c *Client) Metrics() (string, error) {
+	resp, err := c.send(Request{Action: "metrics"})
+	if err != nil {
+		return "", err
+	}
+	if !resp.Success {
+		return "", fmt.Errorf("%s", resp.Error)
+	}
+	text, _ := resp.Data.(string)
+	return text, nil
+}
+
+type SearchRequest struct {
+	Name       string
+	Pattern    string
+	Before     int
+	After      int
+	IgnoreCase bool
+	StripANSI  bool
+	Newlines   string
+	// Offset and Limit page through the matches: skip the first Offset,
+	// return at most Limit (all when 0). Continue is a previous response's
+	// Next, which stands in for all the other fields but Name.
+	Offset   int
+	Limit    int
+	Continue string
+	// Render searches the rows of the emulated terminal (scrollback, then
+	// the screen) instead of the raw output lines; matches get a Screen
+	// position.
+	Render bool
+}
+
+type SearchMatch struct {
+	LineNumber int        `json:"line_number"`
+	Line       string     `json:"line"`
+	Before     []string   `json:"before"`
+	After      []string   `json:"after"`
+	Screen     *ScreenPos `json:"screen,omitempty"`
+}
+
+type SearchResponse struct {
+	Matches            []SearchMatch `json:"matches"`
+	TotalMatches       int           `json:"total_matches"`
+	LongLinesTruncated int           `json:"long_lines_truncated,omitempty"`
+	Next               string        `json:"next,omitempty"`
+	// RenderedFrom is the buffer position a render search of a long buffer
+	// started replaying at.
+	RenderedFrom int64 `json:"rendered_from,omitempty"`
+}
+
+type InfoResponse struct {
+	Name            string              `json:"name"`
+	State           string              `json:"state"`
+	PID             int                 `json:"pid"`
+	Command         string              `json:"command"`
+	CreatedAt       string              `json:"created_at"`
+	StoppedAt       string              `json:"stopped_at,omitempty"`
+	ExitCode        *int                `json:"exit_code,omitempty"`
+	ExitSignal      string              `json:"exit_signal,omitempty"`
+	EndReason       string              `json:"end_reason,omitempty"`
+	EndError        string              `json:"end_error,omitempty"`
+	BytesBuffered   int64               `json:"bytes_buffered"`
+	ReadPosition    int64               `json:"read_position"`
+	Cols            int                 `json:"cols"`
+	Rows            int                 `json:"rows"`
+	TUIMode         bool                `json:"tui_mode,omitempty"`
+	Uptime          float64             `json:"uptime_seconds,omitempty"`
+	WallUptime      float64             `json:"wall_uptime_seconds,omitempty"`
+	Idle            float64             `json:"idle_seconds,omitempty"`
+	ClockSkew       float64             `json:"clock_skew_seconds,omitempty"`
+	Cursors         map[string]int64    `json:"cursors,omitempty"`
+	FrameHistory    int                 `json:"frame_history,omitempty"`
+	Scrollback      int                 `json:"scrollback,omitempty"`
+	CaptureRaw      string              `json:"capture_raw,omitempty"`
+	FrameBoundaries []string            `json:"frame_boundaries,omitempty"`
+	Workspace       string              `json:"workspace,omitempty"`
+	Cwd             string              `json:"cwd,omitempty"`
+	Nice            *int                `json:"nice,omitempty"`
+	IOClass         string              `json:"io_class,omitempty"`
+	Limits          *ResourceLimits     `json:"limits,omitempty"`
+	Encoding        string              `json:"encoding,omitempty"`
+	MirrorInput     bool                `json:"mirror_input,omitempty"`
+	OnExit          []string            `json:"on_exit,omitempty"`
+	SnapshotMode    string              `json:"snapshot_mode,omitempty"`
+	ResizeCrash     bool                `json:"resize_crash,omitempty"`
+	Banner          string              `json:"banner,omitempty"`
+	Swallowing      bool                `json:"swallowing_output,omitempty"`
+	Paused          bool                `json:"paused,omitempty"`
+	PausedSince     string              `json:"paused_since,omitempty"`
+	PausedDropped   int64               `json:"paused_dropped_bytes,omitempty"`
+	Frozen          bool                `json:"frozen,omitempty"`
+	FrozenSince     string              `json:"frozen_since,omitempty"`
+	TerminalMode    string              `json:"terminal_mode,omitempty"`
+	AltScreen       bool                `json:"alt_screen"`
+	Health          *Health             `json:"health,omitempty"`
+	PTYBytesIn      int64               `json:"pty_bytes_in"`
+	PTYBytesOut     int64               `json:"pty_bytes_out"`
+	Reads           ReadStat            `json:"reads"`
+	CursorReads     map[string]ReadStat `json:"cursor_reads,omitempty"`
+	Capture         *CaptureInfo        `json:"capture,omitempty"`
+}
+
+// Health probes whether a session's child and PTY are alive.
+func (c *Client) Health(name string) (*Health, error) {
+	resp, err := c.send(Request{Action: "health", Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, _ := json.Marshal(resp.Data)
+	var result Health
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &result, nil
+}
+
+// DryRun reports what action ("stop", "kill" or "clear") would do to
+// session name, without doing it.
+func (c *Client) DryRun(action, name string) (*Impact, error) {
+	resp, err := c.send(Request{Action: action, Name: name, DryRun: true})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, _ := json.Marshal(resp.Data)
+	var result Impact
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *Client) Clear(name string) error {
+	resp, err := c.send(Request{
+		Action: "clear",
+		Name:   name,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
+
+// Compact rewrites the session's stored output as plain text. It returns
+// the buffer size before and after.
+func (c *Client) Compact(name string) (int, int, error) {
+	resp, err := c.send(Request{Action: "compact", Name: name})
+	if err != nil {
+		return 0, 0, err
+	}
+	if !resp.Success {
+		return 0, 0, fmt.Errorf("%s", resp.Error)
+	}
+	data, err := extractMapData(resp)
+	if err != nil {
+		return 0, 0, err
+	}
+	before, _ := data["bytes_before"].(float64)
+	after, _ := data["bytes_after"].(float64)
+	return int(before), int(after), nil
+}
+
+func (c *Client) Resize(name string, cols, rows int) error {
+	resp, err := c.send(Request{
+		Action: "resize",
+		Name:   name,
+		Cols:   cols,
+		Rows:   rows,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
+
+// Fit shrinks a TUI session's terminal to the content on its screen. Positive
+// maxCols and maxRows cap the size further.
+func (c *Client) Fit(name string, maxCols, maxRows int) (*FitResult, error) {
+	resp, err := c.send(Request{Action: "fit", Name: name, Cols: maxCols, Rows: maxRows})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal fit result: %w", err)
+	}
+	var result FitResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal fit result: %w", err)
+	}
+	return &result, nil
+}
+
+// InputLog returns the input sent to a session and the settings needed to
+// replay it into a fresh one.
+func (c *Client) InputLog(name string) (*InputLog, error) {
+	resp, err := c.send(Request{Action: "input_log", Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal input log: %w", err)
+	}
+	var result InputLog
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal input log: %w", err)
+	}
+	return &result, nil
+}
+
+// RunOnceOptions describe a throwaway session for RunOnce.
+type RunOnceOptions struct {
+	Command string
+	// Input is sent as one line once the program's startup output has
+	// settled. Without it RunOnce waits for the command to exit.
+	Input       string
+	WaitPattern string
+	SettleMs    int
+	TimeoutSec  int
+	Env         []string
+	Cwd         string
+	Cols        int
+	Rows        int
+	StripANSI   bool
+}
+
+// RunOnce runs a command in a session the daemon creates for the call and
+// kills before answering, even when the wait fails or times out.
+func (c *Client) RunOnce(opts RunOnceOptions) (*RunOnceResult, error) {
+	timeoutSec := opts.TimeoutSec
+	if timeoutSec <= 0 {
+		timeoutSec = 10
+	}
+	resp, err := c.send(Request{
+		Action:     "run_once",
+		Command:    opts.Command,
+		Input:      opts.Input,
+		Pattern:    opts.WaitPattern,
+		SettleMs:   opts.SettleMs,
+		TimeoutSec: timeoutSec,
+		Env:        opts.Env,
+		Cwd:        opts.Cwd,
+		Cols:       opts.Cols,
+		Rows:       opts.Rows,
+		StripANSI:  opts.StripANSI,
+		Workspace:  CurrentWorkspace(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal run_once result: %w", err)
+	}
+	var result RunOnceResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal run_once result: %w", err)
+	}
+	return &result, nil
+}
+
+// Freeze stops the session's program with SIGSTOP until Thaw.
+func (c *Client) Freeze(name string) (*FreezeResult, error) {
+	return c.freezeAction("freeze", name)
+}
+
+// Thaw continues a frozen session's program with SIGCONT.
+func (c *Client) Thaw(name string) (*FreezeResult, error) {
+	return c.freezeAction("thaw", name)
+}
+
+func (c *Client) freezeAction(action, name string) (*FreezeResult, error) {
+	resp, err := c.send(Request{Action: action, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s result: %w", action, err)
+	}
+	var result FreezeResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal %s result: %w", action, err)
+	}
+	return &result, nil
+}
+
+// Activity reports when the session last produced output, its recent
+// output rates, and whether it has been silent for idle (0 for
+// ActivityDefaultIdle).
+func (c *Client) Activity(name string, idle time.Duration) (*ActivityResult, error) {
+	resp, err := c.send(Request{Action: "activity", Name: name, IdleMs: int(idle / time.Millisecond)})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal activity result: %w", err)
+	}
+	var result ActivityResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal activity result: %w", err)
+	}
+	return &result, nil
+}
+
+// WaitIdle polls a session's activity until it has been silent for idle
+// (or stopped), or timeout passes.
+func (c *Client) WaitIdle(name string, idle, timeout time.Duration) (*ActivityResult, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		result, err := c.Activity(name, idle)
+		if err != nil {
+			return nil, err
+		}
+		if result.Idle {
+			return result, nil
+		}
+		if !time.Now().Before(deadline) {
+			return result, fmt.Errorf("%w waiting for session %q to go idle", wait.ErrTimeout, name)
+		}
+		time.Sleep(ActivityPollInterval)
+	}
+}
+
+// Pause stops storing the session's output until Resume. The program keeps
+// running and its output is read and discarded.
+func (c *Client) Pause(name string) error {
+	resp, err := c.send(Request{Action: "pause", Name: name})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
+
+// Resume stores the session's output again and reports how much the pause
+// discarded.
+func (c *Client) Resume(name string) (*PauseResult, error) {
+	resp, err := c.send(Request{Action: "resume", Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal resume result: %w", err)
+	}
+	var result PauseResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal resume result: %w", err)
+	}
+	return &result, nil
+}
+
+// CursorLine returns the session's terminal line under the cursor and the
+// cursor's column.
+func (c *Client) CursorLine(name string) (string, int, error) {
+	screen, err := c.Screen(name, false)
+	if err != nil {
+		return "", 0, err
+	}
+	line := ""
+	if row := screen.Cursor.Row; row >= 0 && row < len(screen.Rows) {
+		line = screen.Rows[row]
+	}
+	return line, screen.Cursor.Col, nil
+}
+
+// Screen returns the session's terminal as rows of text (ANSI-styled when
+// styled is set) with the cursor position.
+func (c *Client) Screen(name string, styled bool) (*ScreenState, error) {
+	resp, err := c.send(Request{Action: "screen", Name: name, Styled: styled})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal screen: %w", err)
+	}
+	var result ScreenState
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal screen: %w", err)
+	}
+	return &result, nil
+}
+
+// Describe returns a one-call summary of a session: state, prompt or
+// pending question at the cursor, last lines and recent events.
+func (c *Client) Describe(name string) (*Description, error) {
+	resp, err := c.send(Request{Action: "describe", Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal description: %w", err)
+	}
+	var result Description
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal description: %w", err)
+	}
+	return &result, nil
+}
+
+// Renice changes the CPU and I/O priority of a running session's process
+// group. A nil nice or empty ioClass leaves that setting alone.
+func (c *Client) Renice(name string, nice *int, ioClass string) error {
+	resp, err := c.send(Request{
+		Action:  "renice",
+		Name:    name,
+		Nice:    nice,
+		IOClass: ioClass,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
+
+// MirrorInput turns recording of sent input in the session's buffer on or
+// off.
+func (c *Client) MirrorInput(name string, on bool) error {
+	resp, err := c.send(Request{
+		Action:      "mirror_input",
+		Name:        name,
+		MirrorInput: on,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
+
+// ReloadResult reports what a config reload changed.
+type ReloadResult struct {
+	Config  string   `json:"config"`
+	Changed []string `json:"changed"`
+}
+
+// Reload makes the daemon re-read its config file.
+func (c *Client) Reload() (*ReloadResult, error) {
+	resp, err := c.send(Request{Action: "reload"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal reload result: %w", err)
+	}
+	var result ReloadResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal reload result: %w", err)
+	}
+	return &result, nil
+}
+
+// RestartResult reports a daemon restart.
+type RestartResult struct {
+	PID      int `json:"pid"`
+	Sessions int `json:"sessions"`
+}
+
+// Restart makes the daemon exec a fresh copy of its executable, handing
+// over its running sessions, and waits until the new daemon answers.
+func (c *Client) Restart() (*RestartResult, error) {
+	resp, err := c.send(Request{Action: "restart"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal restart result: %w", err)
+	}
+	var result RestartResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal restart result: %w", err)
+	}
+
+	deadline := time.Now().Add(DaemonStartTimeout)
+	for !c.Ping() {
+		if time.Now().After(deadline) {
+			return &result, fmt.Errorf("daemon did not come back within %s", DaemonStartTimeout)
+		}
+		time.Sleep(DaemonPollInterval)
+	}
+	return &result, nil
+}
+
+func (c *Client) Info(name string) (*InfoResponse, error) {
+	resp, err := c.send(Request{
+		Action: "info",
+		Name:   name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, _ := json.Marshal(resp.Data)
+	var result InfoResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *Client) Search(req SearchRequest) (*SearchResponse, error) {
+	resp, err := c.send(Request{
+		Action:     "search",
+		Name:       req.Name,
+		Pattern:    req.Pattern,
+		Before:     req.Before,
+		After:      req.After,
+		IgnoreCase: req.IgnoreCase,
+		StripANSI:  req.StripANSI,
+		Newlines:   req.Newlines,
+		Offset:     int64(req.Offset),
+		Limit:      int64(req.Limit),
+		Continue:   req.Continue,
+		Render:     req.Render,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, _ := json.Marshal(resp.Data)
+	var result SearchResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *Client) ReadWithCursor(name, mode, cursor string, headLines, tailLines int) (string, int, error) {
+	result, err := c.ReadDetailed(name, mode, cursor, headLines, tailLines)
+	if err != nil {
+		return "", 0, err
+	}
+	return result.Output, result.Position, nil
+}
+
+func (c *Client) Size(name string) (int, error) {
+	resp, err := c.send(Request{Action: "size", Name: name})
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Success {
+		return 0, fmt.Errorf("%s", resp.Error)
+	}
+	data, err := extractMapData(resp)
+	if err != nil {
+		return 0, err
+	}
+	sizeFloat, ok := data["size"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("missing size field")
+	}
+	return int(sizeFloat), nil
+}
+
+func (c *Client) Export(name string) (*SessionBundle, error) {
+	resp, err := c.send(Request{Action: "export", Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, _ := json.Marshal(resp.Data)
+	var result SessionBundle
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *Client) Import(name string, bundle *SessionBundle) (map[string]interface{}, error) {
+	resp, err := c.send(Request{Action: "import", Name: name, Bundle: bundle})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+	return extractMapData(resp)
+}
+
+type ExecOptions struct {
+	Input       string
+	SettleMs    int
+	WaitPattern string
+	TimeoutSec  int
+	SettleSet   bool
+	Probe       bool // ask the shell for exit status and cwd afterwards
+	// MaxCPU and MaxWall have the daemon stop the command (SIGTERM, then
+	// SIGKILL) once it uses more CPU time or runs longer than allowed.
+	MaxCPU  time.Duration
+	MaxWall time.Duration
+	// Enter is the line terminator after Input: EnterAuto (default), EnterLF
+	// or EnterCR.
+	Enter string
+	// Delimit appends a marker command to Input and waits for its answer
+	// instead of settle or WaitPattern. The marker is removed from the
+	// output and the buffer, and its answer fills Probe. Input must be one
+	// complete command line of a shell session.
+	Delimit bool
+	// WaitPrompt waits until the output ends in an interactive prompt (see
+	// wait.Prompts) with the terminal's cursor right after it, instead of
+	// settle or WaitPattern.
+	WaitPrompt bool
+	// IdempotencyKey makes retries safe: when an exec with the same key ran
+	// in the session recently, the input is not sent again and that exec's
+	// result is returned (waiting for it if it is still running).
+	IdempotencyKey string
+}
+
+type ExecResult struct {
+	ID       int64 // exec_id for exec_status; 0 if the daemon does not track execs
+	Input    string
+	Output   string
+	Position int
+	Probe    *ProbeResult      // set when ExecOptions.Probe succeeded
+	Budget   *BudgetResult     // set when a budget was requested
+	Timeout  *TimeoutDiagnosis // set when the wait timed out
+	// Duplicate is set when ExecOptions.IdempotencyKey was used before:
+	// nothing was sent and this is the first exec's result.
+	Duplicate bool
+}
+
+// ProbeResult is a shell session's state after a command.
+type ProbeResult struct {
+	ExitCode int    `json:"exit_code"`
+	Cwd      string `json:"cwd"`
+}
+
+func (c *Client) Exec(name string, opts ExecOptions) (*ExecResult, error) {
+	if opts.IdempotencyKey != "" {
+		return c.execOnce(name, opts)
+	}
+	return c.exec(name, opts)
+}
+
+func (c *Client) exec(name string, opts ExecOptions) (*ExecResult, error) {
+	_, startPos, err := c.Read(name, "all", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	budgeted := opts.MaxCPU > 0 || opts.MaxWall > 0
+	if budgeted {
+		if err := c.startBudget(name, opts.MaxCPU, opts.MaxWall); err != nil {
+			return nil, err
+		}
+	}
+
+	line := opts.Input
+	waitPattern := opts.WaitPattern
+	var nonce, suffix string
+	if opts.Delimit {
+		info, err := c.Info(name)
+		if err != nil {
+			return nil, err
+		}
+		nonce = newNonce()
+		suffix = delimiterSuffix(info.Command, nonce)
+		line += suffix
+		waitPattern = delimiterPattern(nonce)
+	}
+
+	id := c.execBegin(name, opts.Input)
+
+	enter := opts.Enter
+	if enter == "" {
+		enter = EnterAuto
+	}
+	if err := c.SendLine(name, line, enter); err != nil {
+		c.execEnd(name, id, ExecFailed)
+		return nil, err
+	}
+
+	settleMs := opts.SettleMs
+	if waitPattern == "" && settleMs == 0 && !opts.SettleSet && !opts.WaitPrompt {
+		settleMs = 500
+	}
+
+	timeoutSec := opts.TimeoutSec
+	if timeoutSec == 0 {
+		timeoutSec = 10
+		// Give a wall budget the chance to fire before the wait gives up.
+		if opts.MaxWall > 0 {
+			timeoutSec = max(timeoutSec, int((opts.MaxWall+BudgetKillGrace).Seconds())+1)
+		}
+	}
+
+	output, pos, err := wait.ForOutput(
+		func() (string, int, error) { return c.Read(name, "all", 0, 0) },
+		wait.Config{
+			Pattern:       waitPattern,
+			SettleMs:      settleMs,
+			TimeoutSec:    timeoutSec,
+			StartPosition: startPos,
+			SizeFunc:      func() (int, error) { return c.Size(name) },
+			MatchFilter:   RemoveInputMirror,
+			WaitForPrompt: opts.WaitPrompt,
+			CursorFunc:    func() (string, int, error) { return c.CursorLine(name) },
+		},
+	)
+
+	switch {
+	case err == nil:
+		c.execEnd(name, id, ExecCompleted)
+	case errors.Is(err, wait.ErrTimeout):
+		c.execEnd(name, id, ExecTimedOut)
+	default:
+		c.execEnd(name, id, ExecFailed)
+	}
+
+	result := &ExecResult{ID: id, Input: opts.Input, Output: output, Position: pos}
+	if opts.Delimit {
+		if exitCode, cwd, ok := parseProbe([]byte(output), nonce); ok {
+			result.Probe = &ProbeResult{ExitCode: exitCode, Cwd: cwd}
+		}
+		if err == nil {
+			result.Position -= c.removeDelimiter(name, startPos, nonce)
+		}
+		result.Output = string(removeDelimiter([]byte(output), suffix, nonce))
+	}
+	if errors.Is(err, wait.ErrTimeout) {
+		result.Timeout = DiagnoseTimeout(output, opts.Input)
+	}
+	if budgeted {
+		if budget, budgetErr := c.budgetResult(name); budgetErr == nil {
+			result.Budget = budget
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if opts.Probe && result.Probe == nil {
+		probe, err := c.Probe(name, 0)
+		if err != nil {
+			return result, fmt.Errorf("probe: %w", err)
+		}
+		result.Probe = probe
+	}
+
+	return result, nil
+}
+
+// execOnce runs an exec under its idempotency key. When the key was used
+// before, the input is not sent again: the first exec's result is returned,
+// once that exec has finished.
+func (c *Client) execOnce(name string, opts ExecOptions) (*ExecResult, error) {
+	if err := ValidateIdempotencyKey(opts.IdempotencyKey); err != nil {
+		return nil, err
+	}
+	nonce := newNonce()
+	claim, err := c.claimKey(name, opts.IdempotencyKey, nonce)
+	if err != nil {
+		return nil, err
 	}
-	sizeFloat, ok := data["size"].(float64)
-	if !ok {
-		return 0, fmt.Errorf("missing size field")
+	if !claim.Duplicate {
+		result, err := c.exec(name, opts)
+		c.recordKey(name, opts.IdempotencyKey, result, err)
+		return result, err
 	}
-	return int(sizeFloat), nil
+
+	timeout := time.Duration(opts.TimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	for !claim.Done {
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%w waiting for the earlier exec with idempotency key %q in session %q to finish (read the session for its output)", wait.ErrTimeout, opts.IdempotencyKey, name)
+		}
+		time.Sleep(IdempotencyPollInterval)
+		if claim, err = c.claimKey(name, opts.IdempotencyKey, nonce); err != nil {
+			return nil, err
+		}
+		if !claim.Duplicate {
+			return nil, fmt.Errorf("idempotency key %q expired while waiting for the earlier exec in session %q", opts.IdempotencyKey, name)
+		}
+	}
+	return claim.replay()
 }
 
-type ExecOptions struct {
-	Input       string
-	SettleMs    int
-	WaitPattern string
-	TimeoutSec  int
-	SettleSet   bool
+// claimKey registers an exec's idempotency key, or returns the call that
+// already holds it. nonce identifies this exec, so a claim retried after a
+// lost connection does not find itself.
+func (c *Client) claimKey(name, key, nonce string) (*KeyClaim, error) {
+	resp, err := c.send(Request{Action: "claim_key", Name: name, IdempotencyKey: key, Nonce: nonce})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal key claim: %w", err)
+	}
+	var claim KeyClaim
+	if err := json.Unmarshal(data, &claim); err != nil {
+		return nil, fmt.Errorf("unmarshal key claim: %w", err)
+	}
+	return &claim, nil
 }
 
-type ExecResult struct {
-	Input    string
-	Output   string
-	Position int
+// recordKey hands the daemon an exec's result for retries with its key.
+// Best effort: if it fails, retries wait for the result until they time out.
+func (c *Client) recordKey(name, key string, result *ExecResult, execErr error) {
+	req := Request{Action: "record_key", Name: name, IdempotencyKey: key, Outcome: ExecCompleted}
+	if result != nil {
+		req.Result, _ = json.Marshal(result)
+	}
+	if execErr != nil {
+		req.Failure = execErr.Error()
+		req.Outcome = ExecFailed
+		if errors.Is(execErr, wait.ErrTimeout) {
+			req.Outcome = ExecTimedOut
+		}
+	}
+	c.send(req)
 }
 
-func (c *Client) Exec(name string, opts ExecOptions) (*ExecResult, error) {
-	_, startPos, err := c.Read(name, "all", 0, 0)
+// replay turns a finished duplicate's record back into what its exec
+// returned.
+func (k *KeyClaim) replay() (*ExecResult, error) {
+	var result *ExecResult
+	if len(k.Result) > 0 && string(k.Result) != "null" {
+		result = &ExecResult{}
+		if err := json.Unmarshal(k.Result, result); err != nil {
+			return nil, fmt.Errorf("unmarshal earlier exec result: %w", err)
+		}
+		result.Duplicate = true
+	}
+	switch {
+	case k.Outcome == ExecTimedOut:
+		// Keep the error a timeout for callers that check with errors.Is.
+		return result, fmt.Errorf("%w%s", wait.ErrTimeout, strings.TrimPrefix(k.Failure, wait.ErrTimeout.Error()))
+	case k.Failure != "":
+		return result, errors.New(k.Failure)
+	case result == nil:
+		return nil, fmt.Errorf("earlier exec left no result")
+	}
+	return result, nil
+}
+
+// removeDelimiter has the daemon strip a delimited exec's marker from the
+// buffer after offset and returns how many bytes it removed. Cleanup is best
+// effort: on failure the marker stays and it returns 0.
+func (c *Client) removeDelimiter(name string, offset int, nonce string) int {
+	resp, err := c.send(Request{Action: "remove_delimiter", Name: name, Offset: int64(offset), Nonce: nonce})
+	if err != nil || !resp.Success {
+		return 0
+	}
+	data, err := extractMapData(resp)
+	if err != nil {
+		return 0
+	}
+	removed, _ := data["removed"].(float64)
+	return int(removed)
+}
+
+// execBegin registers an exec with the daemon for exec_status and returns
+// its ID. Progress tracking is best effort: on failure it returns 0.
+func (c *Client) execBegin(name, input string) int64 {
+	resp, err := c.send(Request{Action: "exec_begin", Name: name, Input: input})
+	if err != nil || !resp.Success {
+		return 0
+	}
+	data, err := extractMapData(resp)
+	if err != nil {
+		return 0
+	}
+	id, _ := data["exec_id"].(float64)
+	return int64(id)
+}
+
+func (c *Client) execEnd(name string, id int64, outcome string) {
+	if id == 0 {
+		return
+	}
+	c.send(Request{Action: "exec_end", Name: name, ExecID: id, Outcome: outcome})
+}
+
+// ExecStatus reports the progress of the session's latest exec, or of exec
+// id when it is non-zero and still the latest.
+func (c *Client) ExecStatus(name string, id int64) (*ExecStatus, error) {
+	resp, err := c.send(Request{Action: "exec_status", Name: name, ExecID: id})
 	if err != nil {
 		return nil, err
 	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal exec status: %w", err)
+	}
+	var status ExecStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("unmarshal exec status: %w", err)
+	}
+	return &status, nil
+}
+
+func (c *Client) startBudget(name string, maxCPU, maxWall time.Duration) error {
+	resp, err := c.send(Request{
+		Action:    "budget",
+		Name:      name,
+		MaxCPUMs:  maxCPU.Milliseconds(),
+		MaxWallMs: maxWall.Milliseconds(),
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
 
-	if err := c.Send(name, opts.Input, true); err != nil {
+func (c *Client) budgetResult(name string) (*BudgetResult, error) {
+	resp, err := c.send(Request{Action: "budget_result", Name: name})
+	if err != nil {
 		return nil, err
 	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
 
-	settleMs := opts.SettleMs
-	if opts.WaitPattern == "" && settleMs == 0 && !opts.SettleSet {
-		settleMs = 500
+	data, _ := json.Marshal(resp.Data)
+	var result BudgetResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
 	}
+	return &result, nil
+}
 
-	timeoutSec := opts.TimeoutSec
-	if timeoutSec == 0 {
-		timeoutSec = 10
+// ExecSteps runs an exec script: each step is sent and waited on with its own
+// condition, falling back to defaults. Unless keepGoing is set, the steps
+// after the first one that does not succeed are skipped.
+func (c *Client) ExecSteps(name string, steps []ExecStep, defaults ExecOptions, keepGoing bool) []StepResult {
+	results := make([]StepResult, len(steps))
+	failed := false
+	for i, step := range steps {
+		r := &results[i]
+		r.Step = i + 1
+		r.Input = step.Input
+		if failed && !keepGoing {
+			r.Status = StepSkipped
+			continue
+		}
+
+		opts := step.options(defaults)
+		probe := opts.Probe
+		opts.Probe = false
+
+		result, err := c.Exec(name, opts)
+		switch {
+		case result == nil:
+			r.Status = StepError
+			r.Error = err.Error()
+		case err != nil:
+			r.Status = StepTimeout
+			r.Error = err.Error()
+		default:
+			r.Status = StepOK
+		}
+		if result != nil {
+			r.Output = result.Output
+			r.Position = result.Position
+		}
+
+		if probe && r.Status == StepOK {
+			if p, err := c.Probe(name, 0); err != nil {
+				r.Status = StepError
+				r.Error = fmt.Sprintf("probe: %v", err)
+			} else {
+				r.ExitCode = &p.ExitCode
+				r.Cwd = p.Cwd
+				if p.ExitCode != 0 {
+					r.Status = StepFailed
+				}
+			}
+		}
+		if r.Status != StepOK {
+			failed = true
+		}
 	}
+	return results
+}
 
-	output, pos, err := wait.ForOutput(
-		func() (string, int, error) { return c.Read(name, "all", 0, 0) },
-		wait.Config{
-			Pattern:       opts.WaitPattern,
-			SettleMs:      settleMs,
-			TimeoutSec:    timeoutSec,
-			StartPosition: startPos,
-			SizeFunc:      func() (int, error) { return c.Size(name) },
-		},
-	)
+// Probe runs a hidden command in a shell session to read the last exit
+// status and working directory. Its output is removed from the buffer.
+func (c *Client) Probe(name string, timeoutSec int) (*ProbeResult, error) {
+	resp, err := c.send(Request{Action: "probe", Name: name, TimeoutSec: timeoutSec})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, _ := json.Marshal(resp.Data)
+	var result ProbeResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &result, nil
+}
+
+// Background starts input as a background job of a shell session
+// ("<input> &") and returns the job, with its PID and shell job number.
+func (c *Client) Background(name, input string, timeoutSec int) (*Job, error) {
+	input = strings.TrimSpace(input)
+	if strings.HasSuffix(input, "&") && !strings.HasSuffix(input, "&&") {
+		input = strings.TrimSpace(strings.TrimSuffix(input, "&"))
+	}
+	if input == "" {
+		return nil, fmt.Errorf("no command to run in the background")
+	}
+	if err := c.Send(name, input+" &", true); err != nil {
+		return nil, err
+	}
 
-	result := &ExecResult{Input: opts.Input, Output: output, Position: pos}
+	resp, err := c.send(Request{Action: "track_job", Name: name, Input: input, TimeoutSec: timeoutSec})
 	if err != nil {
-		return result, err
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
 	}
 
-	return result, nil
+	data, _ := json.Marshal(resp.Data)
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &job, nil
 }
 
-func (c *Client) send(req Request) (*Response, error) {
-	var sockPath string
-	if c.customSocketPath != "" {
-		sockPath = c.customSocketPath
-	} else {
-		var err error
-		sockPath, err = SocketPath()
+// Jobs lists the background jobs started in a session.
+func (c *Client) Jobs(name string) ([]Job, error) {
+	resp, err := c.send(Request{Action: "jobs", Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	var jobs []Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("unmarshal jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// WaitAny waits until the unread output of any session matching filter (a
+// glob; empty for all) matches pattern. The daemon caps each call below the
+// connection deadline, so longer waits resume with the returned positions.
+func (c *Client) WaitAny(filter, pattern string, ignoreCase bool, timeoutSec int) (*WaitAnyResult, error) {
+	deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
+	var positions map[string]int64
+	for {
+		resp, err := c.send(Request{
+			Action:     "wait_any",
+			Filter:     filter,
+			Pattern:    pattern,
+			IgnoreCase: ignoreCase,
+			TimeoutSec: max(1, int(time.Until(deadline).Seconds()+0.999)),
+			Positions:  positions,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !resp.Success {
+			return nil, fmt.Errorf("%s", resp.Error)
+		}
+
+		data, err := json.Marshal(resp.Data)
+		if err != nil {
+			return nil, fmt.Errorf("marshal response: %w", err)
+		}
+		var result WaitAnyResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("unmarshal wait result: %w", err)
+		}
+		if result.Matched {
+			return &result, nil
+		}
+		if !time.Now().Before(deadline) {
+			return &result, fmt.Errorf("%w waiting for pattern %q in any session", wait.ErrTimeout, pattern)
+		}
+		positions = result.Positions
+	}
+}
+
+// WaitExit blocks until the process of a session exits and returns its exit
+// status. timeoutSec <= 0 waits without limit; on timeout the result is not
+// exited and the error wraps wait.ErrTimeout.
+func (c *Client) WaitExit(name string, timeoutSec int) (*ExitResult, error) {
+	deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
+	for {
+		req := Request{Action: "wait_exit", Name: name}
+		if timeoutSec > 0 {
+			req.TimeoutSec = max(1, int(time.Until(deadline).Seconds()+0.999))
+		}
+		resp, err := c.send(req)
 		if err != nil {
 			return nil, err
 		}
+		if !resp.Success {
+			return nil, fmt.Errorf("%s", resp.Error)
+		}
+
+		data, err := json.Marshal(resp.Data)
+		if err != nil {
+			return nil, fmt.Errorf("marshal response: %w", err)
+		}
+		var result ExitResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("unmarshal exit result: %w", err)
+		}
+		if result.Exited {
+			return &result, nil
+		}
+		if timeoutSec > 0 && !time.Now().Before(deadline) {
+			return &result, fmt.Errorf("%w waiting for session %q to exit", wait.ErrTimeout, name)
+		}
 	}
-	conn, err := net.Dial("unix", sockPath)
+}
+
+// Locate maps a buffer position to its line, or, when line is positive, a
+// line to its position. newlines selects how lines are counted, as for
+// search.
+// Bookmark runs a bookmark action (BookmarkModeList, ...) on a session:
+// bookmark is the name of the bookmark, or of the watch setting them, and
+// pattern the regex a watch matches lines with. It returns the session's
+// watches and bookmarks afterwards.
+func (c *Client) Bookmark(name, mode, bookmark, pattern string) (*BookmarkSet, error) {
+	resp, err := c.send(Request{Action: "bookmark", Name: name, Mode: mode, Bookmark: bookmark, Pattern: pattern})
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	var set BookmarkSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("unmarshal bookmarks: %w", err)
+	}
+	return &set, nil
+}
+
+func (c *Client) Locate(name string, position int64, line int, newlines string) (*LinePosition, error) {
+	resp, err := c.send(Request{Action: "locate", Name: name, Offset: position, Line: line, Newlines: newlines})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	var lp LinePosition
+	if err := json.Unmarshal(data, &lp); err != nil {
+		return nil, fmt.Errorf("unmarshal locate result: %w", err)
+	}
+	return &lp, nil
+}
+
+// Diff returns the output added between two positions without consuming it.
+// from and to are byte positions or cursor names; empty means the read
+// position and the end of the buffer. maxLines bounds the lines returned
+// (0 for DiffDefaultMaxLines).
+func (c *Client) Diff(name, from, to string, maxLines int, stripANSI bool) (*DiffResult, error) {
+	resp, err := c.send(Request{Action: "diff", Name: name, From: from, To: to, TailLines: maxLines, StripANSI: stripANSI})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	var result DiffResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal diff result: %w", err)
+	}
+	return &result, nil
+}
+
+// idempotentActions can be repeated safely when the connection fails after
+// the request was written.
+var idempotentActions = map[string]bool{
+	"list":          true,
+	"reload":        true, // re-reads the same file
+	"du":            true,
+	"budget":        true, // restarts the same budget
+	"budget_result": true,
+	"exec_end":      true,
+	"exec_status":   true,
+	"jobs":          true,
+	"wait_any":      true, // resumes from the same positions
+	"wait_exit":     true,
+	"locate":        true,
+	"diff":          true,
+	"input_log":     true,
+	"activity":      true,
+	"claim_key":     true, // the nonce makes a repeated claim find itself
+	"record_key":    true,
+	"search":        true,
+	"info":          true,
+	"health":        true,
+	"size":          true,
+	"resize":        true,
+	"fit":           true, // refits to the same content
+	"screen":        true,
+	"mirror_input":  true, // sets the same state again
+	"export":        true,
+	"frames":        true,
+	"images":        true,
+	"notifications": true,
+	"ping":          true,
+}
+
+// ConnError is returned when the daemon cannot be reached, or the connection
+// broke before a response arrived.
+type ConnError struct {
+	Action string
+	// MaybeDelivered is set when the request had been written before the
+	// connection failed. The daemon may have acted on it; non-idempotent
+	// actions (send, create, kill, ...) are not retried in that case.
+	MaybeDelivered bool
+	Err            error
+}
+
+func (e *ConnError) Error() string {
+	if e.MaybeDelivered {
+		return fmt.Sprintf("daemon connection lost during %s (it may have been applied; not retried): %v", e.Action, e.Err)
+	}
+	return fmt.Sprintf("daemon unavailable for %s: %v", e.Action, e.Err)
+}
 
-	conn.SetDeadline(time.Now().Add(ClientDeadline))
+func (e *ConnError) Unwrap() error { return e.Err }
 
+// send delivers req to the daemon. Failed connections are retried with
+// backoff, restarting the daemon if needed. A connection that fails after the
+// request was written is retried only for idempotent actions. Requests
+// refused by a rate limit are repeated after the daemon's retry-after hint,
+// for up to RateLimitMaxWait in all.
+func (c *Client) send(req Request) (*Response, error) {
 	req.Version = ProtocolVersion
+	if req.Client == "" {
+		req.Client = defaultClientID()
+	}
+
+	backoff := ClientRetryBackoff
+	var throttled time.Duration
+	for attempt := 0; ; {
+		resp, sent, err := c.roundTrip(req)
+		if err == nil && resp.Code == CodeRateLimited {
+			// Refused before the daemon acted on it, so any action may
+			// be repeated.
+			retryAfter := time.Duration(resp.RetryAfter * float64(time.Second))
+			if throttled+retryAfter > RateLimitMaxWait {
+				return nil, &RateLimitError{Action: req.Action, Message: resp.Error, RetryAfter: retryAfter}
+			}
+			throttled += retryAfter
+			time.Sleep(retryAfter)
+			continue
+		}
+		if err == nil {
+			return resp, nil
+		}
+		// A keyed send is not repeated by the daemon, and a dry run
+		// changes nothing.
+		if sent && !retrySafe(req) {
+			return nil, &ConnError{Action: req.Action, MaybeDelivered: true, Err: err}
+		}
+		if attempt >= ClientRetries {
+			return nil, &ConnError{Action: req.Action, Err: err}
+		}
+		attempt++
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if !sent && c.customSocketPath == "" && c.dialer == nil {
+			c.EnsureDaemon() //nolint:errcheck // the next attempt reports the failure
+		}
+	}
+}
+
+// retrySafe reports whether req may be sent again after the connection
+// failed once it was written.
+func retrySafe(req Request) bool {
+	switch {
+	case idempotentActions[req.Action]:
+		return true
+	case req.DryRun: // changes nothing
+		return true
+	case req.Action == "send":
+		return req.IdempotencyKey != "" // not repeated by the daemon
+	case req.Action == "read":
+		// New-mode reads, continued ones included, move the read position
+		// or cursor: a repeat would come back empty and the output the lost
+		// response carried would be skipped.
+		return req.Snapshot || req.Continue == "" && req.Mode != "" && req.Mode != ReadModeNew
+	}
+	return false
+}
+
+// roundTrip makes one request. sent reports whether the request was written
+// to the daemon before an error occurred.
+func (c *Client) roundTrip(req Request) (resp *Response, sent bool, err error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	req.Token = c.token
+	deadline := ClientDeadline
+	if req.Action == "run_once" {
+		// The daemon holds the connection for the whole run.
+		deadline += time.Duration(req.TimeoutSec) * time.Second
+	}
+	conn.SetDeadline(time.Now().Add(deadline))
 
 	if err := json.NewEncoder(conn).Encode(req); err != nil {
-		return nil, err
+		return nil, true, err
 	}
 
-	var resp Response
-	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
-		return nil, err
+	var r Response
+	if err := json.NewDecoder(conn).Decode(&r); err != nil {
+		return nil, true, err
 	}
 
-	return &resp, nil
+	return &r, true, nil
+}
+
+func (c *Client) dial() (net.Conn, error) {
+	if c.hostErr != nil {
+		return nil, c.hostErr
+	}
+	if c.dialer != nil {
+		return c.dialer()
+	}
+	sockPath := c.customSocketPath
+	if sockPath == "" {
+		var err error
+		if sockPath, err = SocketPath(); err != nil {
+			return nil, err
+		}
+	}
+	return net.Dial("unix", sockPath)
 }
 
 func extractMapData(resp *Response) (map[string]interface{}, error) {
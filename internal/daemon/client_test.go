@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/schovi/shelli/internal/wait"
+)
+
+// dropServer accepts connections, reads one request line, and closes the
+// connection without answering.
+func dropServer(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	sockPath := filepath.Join(t.TempDir(), "drop.sock")
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			bufio.NewReader(conn).ReadString('\n') //nolint:errcheck
+			conn.Close()
+		}
+	}()
+	return sockPath, &accepted
+}
+
+func TestClientRetriesIdempotent(t *testing.T) {
+	sockPath, accepted := dropServer(t)
+	client := NewClientWithSocketPath(sockPath)
+
+	_, err := client.List()
+	var connErr *ConnError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("List error = %v, want ConnError", err)
+	}
+	if got := accepted.Load(); got != ClientRetries+1 {
+		t.Errorf("List attempts = %d, want %d", got, ClientRetries+1)
+	}
+}
+
+func TestClientDoesNotRetryDeliveredSend(t *testing.T) {
+	sockPath, accepted := dropServer(t)
+	client := NewClientWithSocketPath(sockPath)
+
+	err := client.Send("s", "rm -rf build", true)
+	var connErr *ConnError
+	if !errors.As(err, &connErr) || !connErr.MaybeDelivered {
+		t.Fatalf("Send error = %v, want ConnError with MaybeDelivered", err)
+	}
+	if got := accepted.Load(); got != 1 {
+		t.Errorf("Send attempts = %d, want 1", got)
+	}
+}
+
+func TestClientDoesNotRetryConsumingRead(t *testing.T) {
+	sockPath, accepted := dropServer(t)
+	client := NewClientWithSocketPath(sockPath)
+
+	_, _, err := client.Read("s", ReadModeNew, 0, 0)
+	var connErr *ConnError
+	if !errors.As(err, &connErr) || !connErr.MaybeDelivered {
+		t.Fatalf("Read error = %v, want ConnError with MaybeDelivered", err)
+	}
+	if got := accepted.Load(); got != 1 {
+		t.Errorf("new-mode Read attempts = %d, want 1", got)
+	}
+
+	accepted.Store(0)
+	client.Read("s", ReadModeAll, 0, 0)
+	if got := accepted.Load(); got != ClientRetries+1 {
+		t.Errorf("all-mode Read attempts = %d, want %d", got, ClientRetries+1)
+	}
+}
+
+func TestRetrySafe(t *testing.T) {
+	for _, tc := range []struct {
+		req  Request
+		want bool
+	}{
+		{Request{Action: "read", Mode: ReadModeNew}, false},
+		{Request{Action: "read"}, false},
+		{Request{Action: "read", Mode: ReadModeNew, Cursor: "c"}, false},
+		{Request{Action: "read", Mode: ReadModeAll, Continue: "token"}, false},
+		{Request{Action: "read", Mode: ReadModeAll}, true},
+		{Request{Action: "read", Mode: ReadModeTail}, true},
+		{Request{Action: "read", Mode: ReadModeRange, Offset: 10}, true},
+		{Request{Action: "read", Snapshot: true}, true},
+		{Request{Action: "send"}, false},
+		{Request{Action: "send", IdempotencyKey: "k"}, true},
+		{Request{Action: "list"}, true},
+	} {
+		if got := retrySafe(tc.req); got != tc.want {
+			t.Errorf("retrySafe(%+v) = %v, want %v", tc.req, got, tc.want)
+		}
+	}
+}
+
+func TestClientReconnectsAfterRestart(t *testing.T) {
+	tmpDir := t.TempDir()
+	newServer := func() (*Server, chan error) {
+		srv, err := NewServer(WithStorage(NewMemoryStorage(1024)), WithSocketDir(tmpDir))
+		if err != nil {
+			t.Fatalf("NewServer: %v", err)
+		}
+		errCh := make(chan error, 1)
+		go func() { errCh <- srv.Start() }()
+		return srv, errCh
+	}
+
+	srv, errCh := newServer()
+	client := NewClientWithSocketPath(srv.socketPath())
+	deadline := time.Now().Add(2 * time.Second)
+	for !client.Ping() {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	srv.Shutdown()
+	<-errCh
+
+	// Bring the daemon back while the client is retrying.
+	restarted := make(chan *Server, 1)
+	go func() {
+		time.Sleep(ClientRetryBackoff)
+		srv, _ := newServer()
+		restarted <- srv
+	}()
+	defer func() { (<-restarted).Shutdown() }()
+
+	if _, err := client.List(); err != nil {
+		t.Fatalf("List after restart: %v", err)
+	}
+}
+
+func TestExecWaitPrompt(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("prompt", CreateOptions{Command: "sh", Env: []string{"PS1=$ "}}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("prompt")
+	waitForOutput(t, client, "prompt", "$ ")
+
+	// A pause longer than the default settle must not end the wait.
+	result, err := client.Exec("prompt", ExecOptions{Input: "sleep 1; echo done-$((1+1))", WaitPrompt: true, TimeoutSec: 5})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if !strings.Contains(result.Output, "done-2") || !strings.HasSuffix(result.Output, "$ ") {
+		t.Errorf("output = %q, want the command's output up to the prompt", result.Output)
+	}
+
+	_, err = client.Exec("prompt", ExecOptions{Input: "cat", WaitPrompt: true, TimeoutSec: 1})
+	if !errors.Is(err, wait.ErrTimeout) {
+		t.Errorf("Exec without a prompt: err = %v, want timeout", err)
+	}
+}
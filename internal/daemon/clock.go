@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// daemonStart anchors the daemon's monotonic timestamps. Durations between
+// monoNow readings ignore wall-clock changes (NTP steps, manual changes);
+// like CLOCK_MONOTONIC, they do not advance while the machine is suspended.
+var daemonStart = time.Now()
+
+// monoNow returns how long the daemon has been running, by the monotonic
+// clock.
+func monoNow() time.Duration {
+	return time.Since(daemonStart)
+}
+
+// Clock is the daemon's wall clock and sleep, replaceable with WithClock so
+// tests can step through timeouts instead of waiting them out.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// systemClock is the real clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// SkewThreshold is how far wall-clock and monotonic uptime may drift apart
+// before info reports the difference as clock_skew_seconds.
+const SkewThreshold = time.Second
+
+// sessionClock holds monotonic timestamps of a session started by this
+// daemon. Sessions recovered from storage have none.
+type sessionClock struct {
+	started    time.Duration // monoNow at create; 0 if unknown
+	lastOutput atomic.Int64  // monoNow of the latest PTY output, in ns
+}
+
+func (c *sessionClock) start() {
+	c.started = monoNow()
+	c.lastOutput.Store(int64(c.started))
+}
+
+func (c *sessionClock) noteOutput() {
+	c.lastOutput.Store(int64(monoNow()))
+}
+
+// clockInfo adds uptime and idle durations of a running session to an info
+// result. uptime_seconds and idle_seconds come from the monotonic clock;
+// wall_uptime_seconds is measured from created_at and, when the two differ
+// (the wall clock was changed or the machine slept), clock_skew_seconds is
+// the difference.
+func (h *sessionHandle) clockInfo(result map[string]interface{}) {
+	if h.state != StateRunning {
+		return
+	}
+	wall := time.Now().Round(0).Sub(h.createdAt.Round(0))
+	result["wall_uptime_seconds"] = wall.Seconds()
+	if h.clock.started == 0 {
+		result["uptime_seconds"] = wall.Seconds()
+		return
+	}
+	now := monoNow()
+	uptime := now - h.clock.started
+	result["uptime_seconds"] = uptime.Seconds()
+	result["idle_seconds"] = (now - time.Duration(h.clock.lastOutput.Load())).Seconds()
+	if skew := wall - uptime; math.Abs(float64(skew)) >= float64(SkewThreshold) {
+		result["clock_skew_seconds"] = skew.Seconds()
+	}
+}
@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockInfoReportsSkew(t *testing.T) {
+	// A wall clock stepped forward by an hour after the session started
+	// looks like a created_at an hour in the past.
+	h := &sessionHandle{state: StateRunning, createdAt: time.Now().Add(-time.Hour).Round(0)}
+	h.clock.start()
+
+	result := map[string]interface{}{}
+	h.clockInfo(result)
+
+	if uptime := result["uptime_seconds"].(float64); uptime > 5 {
+		t.Errorf("uptime_seconds = %v, want close to 0", uptime)
+	}
+	if wall := result["wall_uptime_seconds"].(float64); wall < 3590 {
+		t.Errorf("wall_uptime_seconds = %v, want about 3600", wall)
+	}
+	skew, ok := result["clock_skew_seconds"].(float64)
+	if !ok || skew < 3590 {
+		t.Errorf("clock_skew_seconds = %v, want about 3600", result["clock_skew_seconds"])
+	}
+}
+
+func TestInfoUptimeAndIdle(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("clock", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("clock")
+	if err := client.Send("clock", "echo tick", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "clock", "tick")
+	time.Sleep(200 * time.Millisecond)
+
+	info, err := client.Info("clock")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.Uptime <= 0 || info.WallUptime <= 0 {
+		t.Errorf("uptime = %v, wall uptime = %v; want both > 0", info.Uptime, info.WallUptime)
+	}
+	if info.Idle <= 0 || info.Idle > info.Uptime {
+		t.Errorf("idle = %v, want in (0, %v]", info.Idle, info.Uptime)
+	}
+	if info.ClockSkew != 0 {
+		t.Errorf("clock_skew_seconds = %v, want 0", info.ClockSkew)
+	}
+}
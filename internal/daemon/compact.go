@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// compactChunkLines bounds how many lines are rendered at once. Strip sizes
+// its emulator to the input, so rendering a long buffer in one go would let
+// early lines scroll off the emulator's screen.
+const compactChunkLines = 1000
+
+// compactOutput renders raw session output to the plain text a read would
+// return. Each mark (a read position or cursor offset into data) is mapped to
+// the matching offset in the result; segments between marks are rendered
+// separately so that a mark always lands on the same content.
+func compactOutput(data []byte, marks []int64) ([]byte, map[int64]int64) {
+	cuts := make([]int64, 0, len(marks)+1)
+	for _, m := range marks {
+		if m > 0 && m < int64(len(data)) {
+			cuts = append(cuts, m)
+		}
+	}
+	slices.Sort(cuts)
+	cuts = slices.Compact(cuts)
+	cuts = append(cuts, int64(len(data)))
+
+	mapped := make(map[int64]int64, len(marks))
+	for _, m := range marks {
+		if m <= 0 {
+			mapped[m] = 0
+		}
+	}
+
+	out := make([]byte, 0, len(data)/2)
+	var start int64
+	for _, end := range cuts {
+		out = appendCompacted(out, data[start:end])
+		mapped[end] = int64(len(out))
+		start = end
+	}
+	for _, m := range marks {
+		if m > int64(len(data)) {
+			mapped[m] = int64(len(out))
+		}
+	}
+	return out, mapped
+}
+
+// appendCompacted renders seg in chunks of compactChunkLines lines.
+func appendCompacted(out, seg []byte) []byte {
+	for len(seg) > 0 {
+		n := chunkEnd(seg, compactChunkLines)
+		chunk := seg[:n]
+		seg = seg[n:]
+
+		clean := vterm.StripDefault(string(chunk))
+		out = append(out, clean...)
+		// Strip drops trailing blank lines; keep the line break that ended
+		// the chunk so the next chunk starts on its own line.
+		if chunk[len(chunk)-1] == '\n' && (len(clean) == 0 || clean[len(clean)-1] != '\n') {
+			out = append(out, '\n')
+		}
+	}
+	return out
+}
+
+// chunkEnd returns the length of the first n lines of data, or len(data).
+func chunkEnd(data []byte, n int) int {
+	end := 0
+	for i := 0; i < n; i++ {
+		j := bytes.IndexByte(data[end:], '\n')
+		if j < 0 {
+			return len(data)
+		}
+		end += j + 1
+	}
+	return end
+}
@@ -0,0 +1,92 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompactOutput(t *testing.T) {
+	data := []byte("\x1b[31mred\x1b[0m\r\nab\x1b[1Gc\r\n\x1b[1mbold\x1b[0m\r\n")
+	mid := int64(strings.Index(string(data), "\x1b[1mbold"))
+
+	out, mapped := compactOutput(data, []int64{0, mid, int64(len(data))})
+	if got, want := string(out), "red\ncb\nbold\n"; got != want {
+		t.Fatalf("compacted = %q, want %q", got, want)
+	}
+	if mapped[0] != 0 {
+		t.Errorf("mark 0 -> %d", mapped[0])
+	}
+	if got := string(out[mapped[mid]:]); got != "bold\n" {
+		t.Errorf("content after mid mark = %q, want %q", got, "bold\n")
+	}
+	if mapped[int64(len(data))] != int64(len(out)) {
+		t.Errorf("end mark -> %d, want %d", mapped[int64(len(data))], len(out))
+	}
+}
+
+func TestCompactOutputChunks(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < compactChunkLines*3; i++ {
+		b.WriteString("\x1b[32mline\x1b[0m\x1b[5G!\r\n")
+	}
+	out, _ := compactOutput([]byte(b.String()), nil)
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != compactChunkLines*3 {
+		t.Fatalf("got %d lines, want %d", len(lines), compactChunkLines*3)
+	}
+	for i, line := range lines {
+		if line != "line!" {
+			t.Fatalf("line %d = %q", i, line)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cmd := `printf '\033[31mfirst\033[0m\n'; sleep 0.5; printf '\033[32msecond\033[0m\n'`
+	if _, err := client.Create("compact", CreateOptions{Command: cmd}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	waitForOutput(t, client, "compact", "first")
+	if _, _, err := client.Read("compact", ReadModeNew, 0, 0); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	waitForOutput(t, client, "compact", "second")
+
+	before, after, err := client.Compact("compact")
+	if err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if after >= before {
+		t.Errorf("compact did not shrink the buffer: %d -> %d", before, after)
+	}
+
+	all, _, err := client.Read("compact", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read all: %v", err)
+	}
+	if all != "first\nsecond\n" {
+		t.Errorf("all output = %q", all)
+	}
+	unread, _, err := client.Read("compact", ReadModeNew, 0, 0)
+	if err != nil {
+		t.Fatalf("Read new: %v", err)
+	}
+	if unread != "second\n" {
+		t.Errorf("unread output = %q, want %q", unread, "second\n")
+	}
+}
+
+func TestCompactTUI(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("tui", CreateOptions{Command: "sleep 5", TUIMode: true}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, _, err := client.Compact("tui"); err == nil || !strings.Contains(err.Error(), "TUI mode") {
+		t.Errorf("Compact on TUI session: err = %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigFileEnv overrides the daemon config file location.
+const ConfigFileEnv = "SHELLI_CONFIG"
+
+// Config is the daemon config file, JSON at DefaultConfigPath. Daemon flags
+// given on the command line take precedence over it. SIGHUP or the reload
+// action re-reads the file; see Server.Reload.
+type Config struct {
+	StoppedTTL    string              `json:"stopped_ttl,omitempty"`     // Go duration, e.g. "1h"
+	MaxOutput     string              `json:"max_output,omitempty"`      // memory backend buffer size, e.g. "50MB"
+	MaxFileOutput string              `json:"max_file_output,omitempty"` // file backend cap per session, e.g. "100MB"; unset is unbounded
+	Hooks         map[string][]string `json:"hooks,omitempty"`           // event -> commands
+	// OutputCompression compresses the file backend's sealed output
+	// segments: "gzip" or "none" (the default; see storage_compress.go).
+	OutputCompression string `json:"output_compression,omitempty"`
+	// MaxMemory caps the memory backend's output of all sessions together,
+	// e.g. "1GB" (unset is unbounded); MemoryEviction is what makes room:
+	// spill (the default) or truncate (see membudget.go).
+	MaxMemory      string `json:"max_memory,omitempty"`
+	MemoryEviction string `json:"memory_eviction,omitempty"`
+	// SessionRateLimit and ClientRateLimit cap requests per session and per
+	// client, e.g. "20/s" or "600/m"; unset is unlimited.
+	SessionRateLimit string `json:"session_rate_limit,omitempty"`
+	ClientRateLimit  string `json:"client_rate_limit,omitempty"`
+	// AllowedUIDs are users besides the daemon's own that may use its
+	// socket. RequireToken makes socket requests carry the daemon's token
+	// (see LoadToken), as TCP requests always do.
+	AllowedUIDs  []int `json:"allowed_uids,omitempty"`
+	RequireToken bool  `json:"require_token,omitempty"`
+}
+
+// DefaultConfigPath returns $SHELLI_CONFIG, or ~/.config/shelli/daemon.json.
+func DefaultConfigPath() string {
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		return path
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(config, "shelli", "daemon.json")
+}
+
+// LoadConfig reads the config file at path. A missing file is an empty
+// config.
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Merge returns c with the settings over sets replacing its own. Hooks are
+// replaced per event.
+func (c Config) Merge(over Config) Config {
+	if over.StoppedTTL != "" {
+		c.StoppedTTL = over.StoppedTTL
+	}
+	if over.MaxOutput != "" {
+		c.MaxOutput = over.MaxOutput
+	}
+	if over.MaxFileOutput != "" {
+		c.MaxFileOutput = over.MaxFileOutput
+	}
+	if over.OutputCompression != "" {
+		c.OutputCompression = over.OutputCompression
+	}
+	if over.MaxMemory != "" {
+		c.MaxMemory = over.MaxMemory
+	}
+	if over.MemoryEviction != "" {
+		c.MemoryEviction = over.MemoryEviction
+	}
+	if over.SessionRateLimit != "" {
+		c.SessionRateLimit = over.SessionRateLimit
+	}
+	if over.ClientRateLimit != "" {
+		c.ClientRateLimit = over.ClientRateLimit
+	}
+	if len(over.AllowedUIDs) > 0 {
+		c.AllowedUIDs = over.AllowedUIDs
+	}
+	if over.RequireToken {
+		c.RequireToken = true
+	}
+	if len(over.Hooks) > 0 {
+		hooks := Hooks{}
+		for event, commands := range c.Hooks {
+			hooks[event] = commands
+		}
+		for event, commands := range over.Hooks {
+			hooks[event] = commands
+		}
+		c.Hooks = hooks
+	}
+	return c
+}
+
+// settings are the parsed values of a Config that the daemon applies.
+type settings struct {
+	stoppedTTL    time.Duration
+	maxOutput     int
+	maxFileOutput int
+	compression   string
+	maxMemory     int
+	eviction      string
+	hooks         Hooks
+	sessionRate   float64 // requests per second; 0 is unlimited
+	sessionBurst  int
+	clientRate    float64
+	clientBurst   int
+	allowedUIDs   []int
+	requireToken  bool
+}
+
+func (c Config) settings() (settings, error) {
+	st := settings{maxOutput: DefaultMaxOutputSize, hooks: Hooks{}}
+	if c.StoppedTTL != "" {
+		ttl, err := time.ParseDuration(c.StoppedTTL)
+		if err != nil || ttl < 0 {
+			return st, fmt.Errorf("stopped_ttl %q: want a duration like 1h", c.StoppedTTL)
+		}
+		st.stoppedTTL = ttl
+	}
+	if c.MaxOutput != "" {
+		size, err := ParseSize(c.MaxOutput)
+		if err != nil {
+			return st, fmt.Errorf("max_output: %w", err)
+		}
+		st.maxOutput = size
+	}
+	if c.MaxFileOutput != "" {
+		size, err := ParseSize(c.MaxFileOutput)
+		if err != nil {
+			return st, fmt.Errorf("max_file_output: %w", err)
+		}
+		st.maxFileOutput = size
+	}
+	if c.OutputCompression != "" {
+		if err := ValidateCompression(c.OutputCompression); err != nil {
+			return st, fmt.Errorf("output_compression: %w", err)
+		}
+		st.compression = c.OutputCompression
+	}
+	if c.MaxMemory != "" {
+		size, err := ParseSize(c.MaxMemory)
+		if err != nil {
+			return st, fmt.Errorf("max_memory: %w", err)
+		}
+		st.maxMemory = size
+	}
+	st.eviction = EvictionSpill
+	if c.MemoryEviction != "" {
+		if err := ValidateEviction(c.MemoryEviction); err != nil {
+			return st, fmt.Errorf("memory_eviction: %w", err)
+		}
+		st.eviction = c.MemoryEviction
+	}
+	if c.SessionRateLimit != "" {
+		var err error
+		if st.sessionRate, st.sessionBurst, err = ParseRate(c.SessionRateLimit); err != nil {
+			return st, fmt.Errorf("session_rate_limit: %w", err)
+		}
+	}
+	if c.ClientRateLimit != "" {
+		var err error
+		if st.clientRate, st.clientBurst, err = ParseRate(c.ClientRateLimit); err != nil {
+			return st, fmt.Errorf("client_rate_limit: %w", err)
+		}
+	}
+	for _, uid := range c.AllowedUIDs {
+		if uid < 0 {
+			return st, fmt.Errorf("allowed_uids: invalid uid %d", uid)
+		}
+	}
+	st.allowedUIDs = c.AllowedUIDs
+	st.requireToken = c.RequireToken
+	for event, commands := range c.Hooks {
+		if !slices.Contains(hookEvents, event) {
+			return st, fmt.Errorf("hooks: unknown event %q (valid: %s)", event, strings.Join(hookEvents, ", "))
+		}
+		st.hooks[event] = commands
+	}
+	return st, nil
+}
+
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(B|KB|MB|GB)?$`)
+
+// ParseSize parses a byte size such as "512KB" or "10MB" (binary units).
+func ParseSize(s string) (int, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	matches := sizePattern.FindStringSubmatch(s)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid format: %s", s)
+	}
+
+	val, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	multiplier := 1.0
+	switch matches[2] {
+	case "KB":
+		multiplier = 1024
+	case "MB":
+		multiplier = 1024 * 1024
+	case "GB":
+		multiplier = 1024 * 1024 * 1024
+	}
+
+	return int(val * multiplier), nil
+}
+
+// Reload re-reads the config file given to WithConfig and applies it:
+// hooks, rate limits, socket access and the stopped-session TTL take effect
+// at once (rate limits start over with full allowances), and a new memory
+// buffer limit or memory budget applies from the next write, and output
+// compression from the next segment sealed. It returns
+// the names of the settings that changed. Settings fixed at startup (the
+// storage backend and data dir) are not reloaded.
+func (s *Server) Reload() ([]string, error) {
+	if s.configPath == "" {
+		return nil, fmt.Errorf("daemon was started without a config file")
+	}
+	file, err := LoadConfig(s.configPath)
+	if err != nil {
+		return nil, err
+	}
+	st, err := file.Merge(s.configFlags).settings()
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", s.configPath, err)
+	}
+
+	auth := socketAuth{allowedUIDs: st.allowedUIDs}
+	if st.requireToken {
+		path, err := s.tokenFile()
+		if err != nil {
+			return nil, err
+		}
+		if auth.token, err = LoadToken(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var changed []string
+
+	s.mu.Lock()
+	if st.stoppedTTL != s.stoppedTTL {
+		s.stoppedTTL = st.stoppedTTL
+		changed = append(changed, "stopped_ttl")
+	}
+	s.mu.Unlock()
+
+	if mem, ok := s.storage.(*MemoryStorage); ok && mem.SetMaxOutputSize(st.maxOutput) {
+		changed = append(changed, "max_output")
+	}
+	if mem, ok := s.storage.(*MemoryStorage); ok && mem.SetMemoryBudget(st.maxMemory, st.eviction) {
+		changed = append(changed, "max_memory")
+	}
+	if fs, ok := s.storage.(*FileStorage); ok && fs.SetMaxOutputSize(st.maxFileOutput) {
+		changed = append(changed, "max_file_output")
+	}
+	if fs, ok := s.storage.(*FileStorage); ok && fs.SetCompression(st.compression) {
+		changed = append(changed, "output_compression")
+	}
+	if s.sessionLimit.set(st.sessionRate, st.sessionBurst) {
+		changed = append(changed, "session_rate_limit")
+	}
+	if s.clientLimit.set(st.clientRate, st.clientBurst) {
+		changed = append(changed, "client_rate_limit")
+	}
+
+	s.configMu.Lock()
+	if !hooksEqual(s.hooks, st.hooks) {
+		s.hooks = st.hooks
+		changed = append(changed, "hooks")
+	}
+	if !slices.Equal(s.socketAuth.allowedUIDs, auth.allowedUIDs) {
+		changed = append(changed, "allowed_uids")
+	}
+	if s.socketAuth.token != auth.token {
+		changed = append(changed, "require_token")
+	}
+	s.socketAuth = auth
+	s.configMu.Unlock()
+
+	return changed, nil
+}
+
+func hooksEqual(a, b Hooks) bool {
+	for _, event := range hookEvents {
+		if !slices.Equal(a[event], b[event]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) handleReload() Response {
+	changed, err := s.Reload()
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	if changed == nil {
+		changed = []string{}
+	}
+	return Response{Success: true, Data: map[string]interface{}{
+		"config":  s.configPath,
+		"changed": changed,
+	}}
+}
@@ -0,0 +1,112 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConfigMerge(t *testing.T) {
+	file := Config{
+		StoppedTTL: "1h",
+		MaxOutput:  "1MB",
+		Hooks:      map[string][]string{HookPreCreate: {"a"}, HookPostStop: {"b"}},
+	}
+	flags := Config{StoppedTTL: "5m", Hooks: map[string][]string{HookPreCreate: {"c"}}}
+
+	got := file.Merge(flags)
+	if got.StoppedTTL != "5m" || got.MaxOutput != "1MB" {
+		t.Errorf("merged = %+v", got)
+	}
+	if !slices.Equal(got.Hooks[HookPreCreate], []string{"c"}) || !slices.Equal(got.Hooks[HookPostStop], []string{"b"}) {
+		t.Errorf("merged hooks = %v", got.Hooks)
+	}
+	if !slices.Equal(file.Hooks[HookPreCreate], []string{"a"}) {
+		t.Error("merge modified the receiver's hooks")
+	}
+}
+
+func TestConfigSettingsErrors(t *testing.T) {
+	for _, c := range []Config{
+		{StoppedTTL: "soon"},
+		{MaxOutput: "lots"},
+		{MaxFileOutput: "heaps"},
+		{OutputCompression: "zstd"},
+		{Hooks: map[string][]string{"on-boot": {"true"}}},
+	} {
+		if _, err := c.settings(); err == nil {
+			t.Errorf("settings(%+v): expected error", c)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int{
+		"100":    100,
+		"512KB":  512 * 1024,
+		"10mb":   10 * 1024 * 1024,
+		"1.5 GB": 1536 * 1024 * 1024,
+	}
+	for in, want := range tests {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := ParseSize("10TB"); err == nil {
+		t.Error("ParseSize(10TB): expected error")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.json")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"stopped_ttl": "1h"}`)
+
+	storage := NewMemoryStorage(DefaultMaxOutputSize)
+	srv, err := NewServer(
+		WithStorage(storage),
+		WithSocketDir(t.TempDir()),
+		WithConfig(path, Config{Hooks: map[string][]string{HookPostCreate: {"true"}}}),
+	)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if srv.stoppedTTL != time.Hour {
+		t.Errorf("stoppedTTL = %v, want 1h", srv.stoppedTTL)
+	}
+
+	write(`{"stopped_ttl": "1h", "max_output": "1KB", "hooks": {"post-create": ["false"], "pre-send": ["true"]}}`)
+	changed, err := srv.Reload()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !slices.Equal(changed, []string{"max_output", "hooks"}) {
+		t.Errorf("changed = %v, want [max_output hooks]", changed)
+	}
+	if storage.maxOutputSize != 1024 {
+		t.Errorf("maxOutputSize = %d, want 1024", storage.maxOutputSize)
+	}
+	// The post-create flag wins over the file.
+	if got := srv.hooksFor(HookPostCreate); !slices.Equal(got, []string{"true"}) {
+		t.Errorf("post-create hooks = %v, want [true]", got)
+	}
+	if got := srv.hooksFor(HookPreSend); !slices.Equal(got, []string{"true"}) {
+		t.Errorf("pre-send hooks = %v, want [true]", got)
+	}
+
+	write(`{"stopped_ttl": "never"}`)
+	if _, err := srv.Reload(); err == nil {
+		t.Error("expected error for invalid config")
+	}
+	if srv.stoppedTTL != time.Hour {
+		t.Errorf("failed reload changed stoppedTTL to %v", srv.stoppedTTL)
+	}
+}
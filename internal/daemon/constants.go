@@ -10,12 +10,36 @@ const (
 	ClientDeadline       = 30 * time.Second
 	DaemonStartTimeout   = 5 * time.Second
 	DaemonPollInterval   = 100 * time.Millisecond
-	DefaultMaxOutputSize = 10 * 1024 * 1024 // 10 MB
+	ClientRetries        = 3                      // extra attempts after a failed connection
+	ClientRetryBackoff   = 100 * time.Millisecond // doubled after each attempt
+	DefaultMaxOutputSize = 10 * 1024 * 1024       // 10 MB
+	MaxLineLength        = 16 * 1024              // per line in head/tail reads and search results
+	CompressSegmentSize  = 8 * 1024 * 1024        // uncapped sessions seal output this large for compression
 
 	DefaultSnapshotSettleMs = 300
 	SnapshotPollInterval    = 25 * time.Millisecond
 	SnapshotResizePause     = 200 * time.Millisecond
 
-	ReadModeNew = "new"
-	ReadModeAll = "all"
+	DefaultProbeTimeoutSec = 5
+	ProbeSettle            = 150 * time.Millisecond
+
+	RestartDrainTimeout = 5 * time.Second // requests in progress get this long to finish before a restart
+
+	HookTimeout     = 10 * time.Second
+	HookOutputLines = 20        // output lines an exit hook gets in SHELLI_OUTPUT
+	HookOutputBytes = 64 * 1024 // looked back for them
+
+	BudgetPollInterval = 250 * time.Millisecond
+	BudgetKillGrace    = 2 * time.Second // SIGTERM → SIGKILL on a budget breach
+
+	MaxSessionImages        = 20
+	MaxSessionNotifications = 100
+	MaxSessionBookmarks     = 1000
+
+	ReadModeNew      = "new"
+	ReadModeAll      = "all"
+	ReadModeRange    = "range"    // bytes [offset, offset+limit) of the buffer
+	ReadModeTail     = "tail"     // the last limit bytes, cut at a safe boundary
+	ReadModeSince    = "since"    // output stored at or after the since time
+	ReadModeBookmark = "bookmark" // the lines around a bookmark
 )
@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogKeep is how many rotated daemon log files are kept.
+const DefaultLogKeep = 3
+
+// logPathFile is where the daemon records its log file, in the runtime dir,
+// so `shelli daemon logs` finds it without being told.
+const logPathFile = "daemon.log-path"
+
+// RotatingLog is a log file that rotates itself: once it would grow past
+// maxSize, or has been written to for longer than maxAge, it is renamed to
+// <path>.1 (older rotations shift to .2, .3, ...), a fresh file is started,
+// and only keep rotated files are kept.
+type RotatingLog struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	keep    int
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	started time.Time
+}
+
+// OpenRotatingLog opens path for appending. maxSize and maxAge of 0 disable
+// that trigger; keep of 0 deletes the old file on rotation.
+func OpenRotatingLog(path string, maxSize int64, maxAge time.Duration, keep int) (*RotatingLog, error) {
+	l := &RotatingLog{path: path, maxSize: maxSize, maxAge: maxAge, keep: keep}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *RotatingLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644) // #nosec G302 -- logs are meant to be readable
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	l.f = f
+	l.size = info.Size()
+	l.started = time.Now()
+	return nil
+}
+
+// Path returns the active log file.
+func (l *RotatingLog) Path() string {
+	return l.path
+}
+
+func (l *RotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.due(int64(len(p))) {
+		if err := l.rotate(); err != nil {
+			fmt.Fprintf(l.f, "log rotation failed: %v\n", err)
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+func (l *RotatingLog) due(next int64) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.maxSize > 0 && l.size+next > l.maxSize {
+		return true
+	}
+	return l.maxAge > 0 && time.Since(l.started) >= l.maxAge
+}
+
+// rotate shifts <path>.N to <path>.N+1, dropping those past keep, and moves
+// the active file to <path>.1. Callers hold l.mu.
+func (l *RotatingLog) rotate() error {
+	for n := l.keep; n >= 1; n-- {
+		from := l.rotatedPath(n)
+		if n == l.keep {
+			os.Remove(from)
+			continue
+		}
+		if err := os.Rename(from, l.rotatedPath(n+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	l.f.Close()
+	if l.keep > 0 {
+		if err := os.Rename(l.path, l.rotatedPath(1)); err != nil {
+			return errors.Join(err, l.open())
+		}
+	} else {
+		os.Remove(l.path)
+	}
+	return l.open()
+}
+
+func (l *RotatingLog) rotatedPath(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+func (l *RotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// RecordLogPath notes the daemon's log file in the runtime dir; an empty
+// path clears the note, for a daemon that does not log.
+func RecordLogPath(path string) error {
+	runtimeDir, err := RuntimeDir()
+	if err != nil {
+		return err
+	}
+	note := filepath.Join(runtimeDir, logPathFile)
+	if path == "" {
+		if err := os.Remove(note); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(runtimeDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(note, []byte(abs+"\n"), 0600)
+}
+
+// LogPath returns the log file of the latest daemon started with
+// --log-file. It is kept after the daemon exits, for post-mortems.
+func LogPath() (string, error) {
+	runtimeDir, err := RuntimeDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(runtimeDir, logPathFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("the daemon does not log: restart it with --log-file")
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	l, err := OpenRotatingLog(path, 10, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n", "six\n"} {
+		if _, err := l.Write([]byte(line)); err != nil {
+			t.Fatalf("write %q: %v", line, err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "six\n",
+		path + ".1": "four\nfive\n",
+		path + ".2": "three\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", filepath.Base(file), err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), data, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists beyond keep=2", filepath.Base(path))
+	}
+}
+
+func TestRotatingLogKeepNone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	l, err := OpenRotatingLog(path, 8, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	l.Write([]byte("first\n"))
+	l.Write([]byte("second\n"))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second\n" {
+		t.Errorf("log = %q, want only the newest write", data)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Error("rotated file kept with keep=0")
+	}
+}
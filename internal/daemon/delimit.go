@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// delimiterSuffix is what a delimited exec appends to its input line: the
+// probe command, run right after the input, so its answer marks the end of
+// the command's output and carries its exit status and working directory.
+func delimiterSuffix(command, nonce string) string {
+	return "; " + strings.TrimSpace(probeCommand(command, nonce))
+}
+
+// delimiterPattern matches the whole answer line of a delimited exec's
+// marker, not the echo of the command that prints it.
+func delimiterPattern(nonce string) string {
+	return `__shelli:` + regexp.QuoteMeta(nonce) + `:\d+:[^\r\n]*\r?\n`
+}
+
+func newNonce() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// removeDelimiter takes a delimited exec's marker out of output: the
+// appended command from the echoed input and the marker's answer. Output
+// the command printed without a final newline stays.
+func removeDelimiter(output []byte, suffix, nonce string) []byte {
+	output = bytes.ReplaceAll(output, []byte(suffix), nil)
+	return regexp.MustCompile(delimiterPattern(nonce)).ReplaceAll(output, nil)
+}
+
+// handleRemoveDelimiter strips the marker of a finished delimited exec from
+// the buffer after req.Offset, so later reads see only the command and its
+// output.
+func (s *Server) handleRemoveDelimiter(req Request) Response {
+	if req.Nonce == "" {
+		return Response{Success: false, Error: "nonce is required"}
+	}
+
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	command := h.command
+	storage := s.storage
+	s.mu.Unlock()
+
+	// Capture waits from the read until the rewrite is done, so output
+	// arriving meanwhile is neither lost nor put before the kept text.
+	s.captureGate.Lock()
+	defer s.captureGate.Unlock()
+
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+	data, err := storage.ReadAll(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+	}
+	if req.Offset < 0 || req.Offset > int64(len(data)) {
+		return Response{Success: true, Data: map[string]interface{}{"removed": 0}}
+	}
+
+	tail := removeDelimiter(data[req.Offset:], delimiterSuffix(command, req.Nonce), req.Nonce)
+	removed := len(data) - int(req.Offset) - len(tail)
+	if removed > 0 {
+		kept := append(data[:req.Offset:req.Offset], tail...)
+		if err := rewriteOutput(storage, req.Name, meta, kept); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("remove delimiter: %v", err)}
+		}
+	}
+	return Response{Success: true, Data: map[string]interface{}{"removed": removed}}
+}
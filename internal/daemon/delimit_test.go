@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRemoveDelimiter(t *testing.T) {
+	suffix := delimiterSuffix("sh", "n1")
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "echo and answer",
+			output: "ls" + suffix + "\r\na b\r\n__shelli:n1:0:/tmp\r\n$ ",
+			want:   "ls\r\na b\r\n$ ",
+		},
+		{
+			name:   "output without final newline",
+			output: "printf x" + suffix + "\r\nx__shelli:n1:0:/tmp\r\n$ ",
+			want:   "printf x\r\nx$ ",
+		},
+		{
+			name:   "other nonce stays",
+			output: "__shelli:n2:0:/tmp\r\n",
+			want:   "__shelli:n2:0:/tmp\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(removeDelimiter([]byte(tt.output), suffix, "n1")); got != tt.want {
+				t.Errorf("removeDelimiter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecDelimit(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("delimit", CreateOptions{Command: "sh", Cwd: "/tmp"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("delimit")
+
+	// A pause longer than the default settle must not end the wait.
+	result, err := client.Exec("delimit", ExecOptions{Input: "sleep 1; echo done; false", Delimit: true})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if !strings.Contains(result.Output, "done") || strings.Contains(result.Output, "shelli") {
+		t.Errorf("output = %q, want the command's output without the marker", result.Output)
+	}
+	if result.Probe == nil || result.Probe.ExitCode != 1 || result.Probe.Cwd != "/tmp" {
+		t.Errorf("probe = %+v, want exit 1 in /tmp", result.Probe)
+	}
+
+	all, pos, err := client.Read("delimit", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if strings.Contains(all, "shelli") {
+		t.Errorf("marker left in buffer: %q", all)
+	}
+	if pos < result.Position {
+		t.Errorf("read position %d behind exec position %d", pos, result.Position)
+	}
+}
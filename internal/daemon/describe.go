@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/schovi/shelli/internal/vterm"
+	"github.com/schovi/shelli/internal/wait"
+)
+
+// DescribeLines is how many of the last output lines describe returns.
+const DescribeLines = 20
+
+// DescribeTailBytes is how much of the end of a non-TUI session's buffer
+// describe looks at for its last lines.
+const DescribeTailBytes = 64 * 1024
+
+// questionSuffix matches the end of a line a program is waiting on for an
+// answer: "Continue? ", "Password: ", "[y/N] ".
+var questionSuffix = regexp.MustCompile(`(\?|:|\[[yYnN]/[yYnN]\]|\([yYnN]/[yYnN]\))\s*$`)
+
+// Description is the describe action's summary of a session: what it runs,
+// whether it is waiting for input, and what it printed last.
+type Description struct {
+	Name          string       `json:"name"`
+	State         SessionState `json:"state"`
+	Command       string       `json:"command"`
+	UptimeSeconds float64      `json:"uptime_seconds"`
+	IdleSeconds   float64      `json:"idle_seconds,omitempty"`
+	TUIMode       bool         `json:"tui_mode,omitempty"`
+	AltScreen     bool         `json:"alt_screen"`
+	// AtPrompt is set when the cursor sits right after an interactive
+	// prompt; Prompt says which (shell, python, ...).
+	AtPrompt bool   `json:"at_prompt"`
+	Prompt   string `json:"prompt,omitempty"`
+	// Question is the line the cursor waits after when it looks like a
+	// question rather than a prompt ("Overwrite? [y/N]", "Password:").
+	Question  string           `json:"question,omitempty"`
+	ExitCode  *int             `json:"exit_code,omitempty"`
+	EndReason string           `json:"end_reason,omitempty"`
+	Lines     []string         `json:"lines"`
+	Events    []LifecycleEvent `json:"events,omitempty"`
+}
+
+// handleDescribe sums a session up in one response, for agents deciding
+// what to do next: info's state, the prompt and question at the cursor, the
+// last DescribeLines lines ANSI stripped, and its recent lifecycle events.
+func (s *Server) handleDescribe(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	d := &Description{
+		Name:      h.name,
+		State:     h.state,
+		Command:   h.command,
+		AltScreen: h.altScreen,
+		TUIMode:   h.screen != nil,
+	}
+	clock := make(map[string]interface{})
+	h.clockInfo(clock)
+	if h.stoppedAt != nil {
+		d.UptimeSeconds = h.stoppedAt.Sub(h.createdAt).Seconds()
+	}
+	storage := s.storage
+	s.mu.Unlock()
+
+	if uptime, ok := clock["uptime_seconds"].(float64); ok {
+		d.UptimeSeconds = uptime
+	}
+	if idle, ok := clock["idle_seconds"].(float64); ok {
+		d.IdleSeconds = idle
+	}
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+	d.ExitCode, d.EndReason = meta.ExitCode, meta.EndReason
+
+	screen, err := s.sessionScreen(req.Name, false)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	if d.State == StateRunning {
+		d.Prompt, d.AtPrompt, d.Question = cursorWait(screen)
+	}
+
+	if d.TUIMode {
+		d.Lines = lastLines(screen.Rows, DescribeLines)
+	} else {
+		size, err := storage.Size(req.Name)
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("get size: %v", err)}
+		}
+		data, err := storage.ReadFrom(req.Name, max(0, size-DescribeTailBytes))
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+		}
+		text := string(data)
+		if size > DescribeTailBytes {
+			// Start on a line boundary rather than inside an escape sequence.
+			text = text[strings.IndexByte(text, '\n')+1:]
+		}
+		text = vterm.StripDefault(NormalizeNewlines(text, NewlinesDisplay))
+		d.Lines = lastLines(strings.Split(text, "\n"), DescribeLines)
+	}
+
+	d.Events = s.events.recentEvents(req.Name)
+	return Response{Success: true, Data: d}
+}
+
+// cursorWait looks at the terminal line the cursor is on: a known prompt
+// right before the cursor, or else a question the program waits on.
+func cursorWait(screen *ScreenState) (prompt string, atPrompt bool, question string) {
+	row := screen.Cursor.Row
+	if row < 0 || row >= len(screen.Rows) {
+		return "", false, ""
+	}
+	line, col := screen.Rows[row], screen.Cursor.Col
+	if prompt, ok := wait.PromptAtCursor(line, col); ok {
+		return prompt, true, ""
+	}
+	runes := []rune(line)
+	if col > len(runes) {
+		col = len(runes)
+	}
+	if strings.TrimSpace(string(runes[col:])) != "" {
+		return "", false, ""
+	}
+	if before := strings.TrimSpace(string(runes[:col])); before != "" && questionSuffix.MatchString(before) {
+		return "", false, before
+	}
+	return "", false, ""
+}
+
+// lastLines returns the last n lines that are not blank trailing ones, with
+// trailing spaces trimmed.
+func lastLines(lines []string, n int) []string {
+	end := len(lines)
+	for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	out := make([]string, 0, min(n, end))
+	for _, line := range lines[max(0, end-n):end] {
+		out = append(out, strings.TrimRight(line, " \t"))
+	}
+	return out
+}
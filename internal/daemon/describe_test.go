@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("ask", CreateOptions{Command: "sleep 60"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("ask")
+	term := fake.terminal(t)
+	term.Write([]byte("\x1b[32mline one\x1b[0m\r\nline two\r\nOverwrite config? [y/N] "))
+	waitForOutput(t, client, "ask", "[y/N] ")
+
+	d, err := client.Describe("ask")
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if d.State != StateRunning || d.Command != "sleep 60" || d.AtPrompt {
+		t.Errorf("describe = %+v, want running sleep 60 not at a prompt", d)
+	}
+	if d.Question != "Overwrite config? [y/N]" {
+		t.Errorf("question = %q", d.Question)
+	}
+	if want := []string{"line one", "line two", "Overwrite config? [y/N]"}; !reflect.DeepEqual(d.Lines, want) {
+		t.Errorf("lines = %q, want %q", d.Lines, want)
+	}
+
+	term.Write([]byte("y\r\ndone\r\n$ "))
+	waitForOutput(t, client, "ask", "done\r\n$ ")
+	if err := client.Resize("ask", 100, 30); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+
+	d, err = client.Describe("ask")
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if !d.AtPrompt || d.Prompt != "shell" || d.Question != "" {
+		t.Errorf("at_prompt = %v, prompt = %q, question = %q; want the shell prompt", d.AtPrompt, d.Prompt, d.Question)
+	}
+	var types []string
+	for _, ev := range d.Events {
+		types = append(types, ev.Type)
+	}
+	if want := []string{EventCreated, EventResized}; !reflect.DeepEqual(types, want) {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+
+	if _, err := client.Describe("missing"); err == nil {
+		t.Error("Describe of a missing session succeeded")
+	}
+}
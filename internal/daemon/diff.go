@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// DiffDefaultMaxLines bounds the lines a diff returns unless the caller asks
+// for more; the summary still counts them all.
+const DiffDefaultMaxLines = 500
+
+// DiffResult is the output added to a session's buffer between two
+// positions.
+type DiffResult struct {
+	Name string `json:"name"`
+	From int64  `json:"from"`
+	To   int64  `json:"to"`
+	// Lines are the added lines, newest last, with carriage-return
+	// overwrites applied. The first may continue a line begun before From.
+	Lines        []string    `json:"lines"`
+	OmittedLines int         `json:"omitted_lines,omitempty"` // older lines left out of Lines
+	Summary      DiffSummary `json:"summary"`
+}
+
+// DiffSummary sizes up a diff.
+type DiffSummary struct {
+	LinesAdded int   `json:"lines_added"`
+	Bytes      int64 `json:"bytes"`
+	// FirstAt and LastAt are when the first and last added bytes arrived,
+	// and DurationSeconds the time between them; unset without output.
+	FirstAt         string  `json:"first_at,omitempty"`
+	LastAt          string  `json:"last_at,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// diffPosition resolves a diff endpoint: a byte position, a read cursor's
+// name, or def when empty.
+func diffPosition(spec string, meta *SessionMeta, def int64) (int64, error) {
+	if spec == "" {
+		return def, nil
+	}
+	if pos, err := strconv.ParseInt(spec, 10, 64); err == nil {
+		return pos, nil
+	}
+	pos, ok := meta.Cursors[spec]
+	if !ok {
+		return 0, fmt.Errorf("unknown cursor %q (use a byte position or a cursor name)", spec)
+	}
+	return pos, nil
+}
+
+// chunkTime returns when the byte at offset was stored.
+func chunkTime(chunks []Chunk, offset int64) (time.Time, bool) {
+	var at time.Time
+	found := false
+	for _, c := range chunks {
+		if c.Offset > offset {
+			break
+		}
+		at, found = c.At, true
+	}
+	return at, found
+}
+
+// handleDiff returns the output stored between two positions (from defaults
+// to the read position, to to the end of the buffer) as lines plus a summary.
+// Nothing is consumed.
+func (s *Server) handleDiff(req Request) Response {
+	if req.TailLines < 0 {
+		return Response{Success: false, Error: "max lines must not be negative"}
+	}
+
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	screen := h.screen
+	storage := s.storage
+	s.mu.Unlock()
+
+	if screen != nil {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is in TUI mode (positions refer to raw output)", req.Name)}
+	}
+
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+	size, err := storage.Size(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("get size: %v", err)}
+	}
+	from, err := diffPosition(req.From, meta, min(meta.ReadPos, size))
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	to, err := diffPosition(req.To, meta, size)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	if from < 0 || to > size || from > to {
+		return Response{Success: false, Error: fmt.Sprintf("invalid range %d-%d (buffer is 0-%d)", from, to, size)}
+	}
+
+	data, err := storage.ReadFrom(req.Name, from)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+	}
+	data = data[:min(int64(len(data)), to-from)]
+
+	text := string(data)
+	if req.StripANSI {
+		text = vterm.StripDefault(text)
+	}
+	text = NormalizeNewlines(text, NewlinesDisplay)
+	lines := []string{}
+	if text != "" {
+		lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+
+	result := DiffResult{
+		Name:    req.Name,
+		From:    from,
+		To:      to,
+		Lines:   lines,
+		Summary: DiffSummary{LinesAdded: len(lines), Bytes: to - from},
+	}
+	maxLines := req.TailLines
+	if maxLines == 0 {
+		maxLines = DiffDefaultMaxLines
+	}
+	if len(lines) > maxLines {
+		result.OmittedLines = len(lines) - maxLines
+		result.Lines = lines[result.OmittedLines:]
+	}
+
+	if to > from {
+		chunks, err := storage.Chunks(req.Name)
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("read chunk times: %v", err)}
+		}
+		first, okFirst := chunkTime(chunks, from)
+		last, okLast := chunkTime(chunks, to-1)
+		if okFirst && okLast {
+			result.Summary.FirstAt = first.Format(time.RFC3339Nano)
+			result.Summary.LastAt = last.Format(time.RFC3339Nano)
+			result.Summary.DurationSeconds = last.Sub(first).Seconds()
+		}
+	}
+	return Response{Success: true, Data: result}
+}
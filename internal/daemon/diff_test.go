@@ -0,0 +1,79 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("diff", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("diff")
+
+	if err := client.Send("diff", "echo before-$((1+1))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "diff", "before-2")
+	if _, err := client.ReadDetailed("diff", ReadModeNew, "", 0, 0); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if _, err := client.ReadDetailed("diff", ReadModeNew, "agent", 0, 0); err != nil {
+		t.Fatalf("Read cursor: %v", err)
+	}
+
+	if err := client.Send("diff", "for i in 1 2 3; do echo after-$i; sleep 0.1; done", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "diff", "after-3")
+
+	d, err := client.Diff("diff", "", "", 0, false)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	text := strings.Join(d.Lines, "\n")
+	if strings.Contains(text, "before-2") || !strings.Contains(text, "after-1") || !strings.Contains(text, "after-3") {
+		t.Errorf("diff since the read position = %q", d.Lines)
+	}
+	if d.Summary.LinesAdded != len(d.Lines) || d.Summary.Bytes != d.To-d.From || d.Summary.FirstAt == "" {
+		t.Errorf("summary = %+v for %d lines, %d-%d", d.Summary, len(d.Lines), d.From, d.To)
+	}
+	if d.Summary.DurationSeconds < 0.15 {
+		t.Errorf("duration = %v, want the loop's sleeps", d.Summary.DurationSeconds)
+	}
+
+	// Nothing was consumed, and a cursor name works as an endpoint.
+	byCursor, err := client.Diff("diff", "agent", fmt.Sprint(d.To), 0, false)
+	if err != nil {
+		t.Fatalf("Diff from cursor: %v", err)
+	}
+	if byCursor.From != d.From || strings.Join(byCursor.Lines, "\n") != text {
+		t.Errorf("diff from cursor = %+v, want %+v", byCursor, d)
+	}
+
+	last, err := client.Diff("diff", "0", "", 1, false)
+	if err != nil {
+		t.Fatalf("Diff max lines: %v", err)
+	}
+	if len(last.Lines) != 1 || last.OmittedLines != last.Summary.LinesAdded-1 {
+		t.Errorf("max lines 1 = %+v", last)
+	}
+
+	empty, err := client.Diff("diff", fmt.Sprint(d.To), "", 0, false)
+	if err != nil {
+		t.Fatalf("empty Diff: %v", err)
+	}
+	if len(empty.Lines) != 0 || empty.Summary.Bytes != 0 || empty.Summary.FirstAt != "" {
+		t.Errorf("empty diff = %+v", empty)
+	}
+
+	for _, r := range [][2]string{{"10", "5"}, {"0", fmt.Sprint(d.To + 100)}, {"nope", ""}} {
+		if _, err := client.Diff("diff", r[0], r[1], 0, false); err == nil {
+			t.Errorf("Diff(%q, %q) should fail", r[0], r[1])
+		}
+	}
+}
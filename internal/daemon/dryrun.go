@@ -0,0 +1,99 @@
+package daemon
+
+import "fmt"
+
+// Impact is what a stop, kill or clear would do to a session, worked out
+// from its current state by a dry run that leaves it untouched. Agents use
+// it to confirm a destructive action against real data before taking it.
+type Impact struct {
+	Action  string       `json:"action"`
+	Name    string       `json:"name"`
+	State   SessionState `json:"state"`
+	PID     int          `json:"pid,omitempty"`
+	Command string       `json:"command"`
+	// NoOp is set when the action would change nothing (stopping a session
+	// that is already stopped).
+	NoOp bool `json:"no_op,omitempty"`
+	// TerminatesProcess is set when the action ends a running process.
+	TerminatesProcess bool `json:"terminates_process"`
+
+	BufferBytes int64 `json:"buffer_bytes"`
+	// UnreadBytes is the output past the read position, which nobody has
+	// read yet.
+	UnreadBytes int64 `json:"unread_bytes"`
+	Cursors     int   `json:"cursors,omitempty"`
+	Bookmarks   int   `json:"bookmarks,omitempty"`
+	// DiscardedBytes is how much stored output the action deletes.
+	DiscardedBytes int64 `json:"discarded_bytes"`
+
+	// OutputKept is set when read and search still serve the output after
+	// the action.
+	OutputKept bool `json:"output_kept"`
+	// Archived is set when the output stays in the data dir, where shelli
+	// archive can query it once no daemon serves it (file backend only).
+	Archived bool   `json:"archived"`
+	DataDir  string `json:"data_dir,omitempty"`
+	// RemovedAfterSeconds is stopped_ttl: how long after a stop the session
+	// and its files are cleaned up.
+	RemovedAfterSeconds float64 `json:"removed_after_seconds,omitempty"`
+}
+
+// handleDryRun reports the Impact of req.Action (stop, kill or clear) on
+// session req.Name without taking it.
+func (s *Server) handleDryRun(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	running := h.state == StateRunning
+	impact := &Impact{
+		Action:  req.Action,
+		Name:    h.name,
+		State:   h.state,
+		Command: h.command,
+	}
+	if running {
+		impact.PID = h.pid
+	}
+	ttl := s.stoppedTTL
+	storage := s.storage
+	s.mu.Unlock()
+
+	size, err := storage.Size(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("get size: %v", err)}
+	}
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+	impact.BufferBytes = size
+	impact.UnreadBytes = max(0, size-meta.ReadPos)
+	impact.Cursors = len(meta.Cursors)
+	impact.Bookmarks = len(meta.Bookmarks)
+
+	fs, onDisk := storage.(*FileStorage)
+	switch req.Action {
+	case "stop":
+		impact.NoOp = !running
+		impact.TerminatesProcess = running
+		impact.OutputKept = true
+		if onDisk {
+			impact.Archived = true
+			impact.DataDir = fs.dataDir
+		}
+		if ttl > 0 {
+			impact.RemovedAfterSeconds = ttl.Seconds()
+		}
+	case "kill":
+		impact.TerminatesProcess = running
+		impact.DiscardedBytes = size
+	case "clear":
+		impact.DiscardedBytes = size
+	default:
+		return Response{Success: false, Error: fmt.Sprintf("dry_run does not apply to %s", req.Action)}
+	}
+	return Response{Success: true, Data: impact}
+}
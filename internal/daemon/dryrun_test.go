@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDryRun(t *testing.T) {
+	fake := newFakePTY()
+	fs, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	client, cleanup := setupTestServer(t, WithPTYDriver(fake), WithStorage(fs), WithStoppedTTL(time.Hour))
+	defer cleanup()
+
+	if _, err := client.Create("doomed", CreateOptions{Command: "sleep 60"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("doomed")
+	term := fake.terminal(t)
+	term.Write([]byte("0123456789"))
+	waitForOutput(t, client, "doomed", "789")
+
+	kill, err := client.DryRun("kill", "doomed")
+	if err != nil {
+		t.Fatalf("DryRun kill: %v", err)
+	}
+	if !kill.TerminatesProcess || kill.PID == 0 || kill.BufferBytes != 10 || kill.DiscardedBytes != 10 || kill.OutputKept || kill.Archived {
+		t.Errorf("kill impact = %+v, want the process and all 10 bytes gone", kill)
+	}
+
+	stop, err := client.DryRun("stop", "doomed")
+	if err != nil {
+		t.Fatalf("DryRun stop: %v", err)
+	}
+	if !stop.TerminatesProcess || stop.DiscardedBytes != 0 || !stop.OutputKept || !stop.Archived || stop.DataDir == "" || stop.RemovedAfterSeconds != 3600 {
+		t.Errorf("stop impact = %+v, want output kept and archived for an hour", stop)
+	}
+
+	// Nothing was done: the session runs on with its output.
+	info, err := client.Info("doomed")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.State != string(StateRunning) {
+		t.Errorf("state after dry runs = %s, want running", info.State)
+	}
+	if out, _, err := client.Read("doomed", ReadModeAll, 0, 0); err != nil || out != "0123456789" {
+		t.Errorf("output after dry runs = %q, %v", out, err)
+	}
+
+	if err := client.Stop("doomed"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	again, err := client.DryRun("stop", "doomed")
+	if err != nil {
+		t.Fatalf("DryRun stop again: %v", err)
+	}
+	if !again.NoOp || again.TerminatesProcess {
+		t.Errorf("stop of a stopped session = %+v, want a no-op", again)
+	}
+
+	clear, err := client.DryRun("clear", "doomed")
+	if err != nil {
+		t.Fatalf("DryRun clear: %v", err)
+	}
+	if clear.TerminatesProcess || clear.DiscardedBytes != 10 || clear.UnreadBytes != 10 {
+		t.Errorf("clear impact = %+v, want 10 unread bytes discarded", clear)
+	}
+
+	if _, err := client.DryRun("kill", "missing"); err == nil {
+		t.Error("dry run on a missing session succeeded")
+	}
+}
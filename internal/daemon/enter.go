@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Enter modes: the line terminator exec sends after its input. A terminal's
+// Enter key sends CR, which the line discipline turns into LF for programs
+// reading lines (ICANON). Programs that put the terminal in raw mode (node's
+// REPL, prompt_toolkit apps) read the CR themselves and may ignore LF.
+const (
+	EnterAuto = "auto" // CR when the session's program has ICANON off, else LF
+	EnterLF   = "lf"
+	EnterCR   = "cr"
+)
+
+// Terminal modes reported by info, which decide what EnterAuto sends.
+const (
+	TerminalCanonical = "canonical"
+	TerminalRaw       = "raw"
+)
+
+var enterModes = []string{EnterAuto, EnterLF, EnterCR}
+
+// ValidateEnter checks an enter mode; "" means the caller's default.
+func ValidateEnter(mode string) error {
+	switch mode {
+	case "", EnterAuto, EnterLF, EnterCR:
+		return nil
+	}
+	return fmt.Errorf("unknown enter mode %q (valid: %s)", mode, strings.Join(enterModes, ", "))
+}
+
+// lineEnding returns the terminator for mode. "" is LF, as for plain sends.
+// Auto falls back to LF when the terminal mode cannot be read.
+func lineEnding(ptmx *os.File, mode string) string {
+	switch mode {
+	case EnterCR:
+		return "\r"
+	case EnterAuto:
+		if canonical, err := canonicalMode(ptmx); err == nil && !canonical {
+			return "\r"
+		}
+	}
+	return "\n"
+}
+
+// canonicalMode reports whether the PTY is in canonical (line) mode, as set
+// by the program on the other side (tcgetattr on the master reads the
+// terminal's settings).
+func canonicalMode(ptmx *os.File) (bool, error) {
+	conn, err := ptmx.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+	var t syscall.Termios
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(&t))) // #nosec G103 -- ioctl needs a pointer to the result
+	}); err != nil {
+		return false, err
+	}
+	if errno != 0 {
+		return false, errno
+	}
+	return t.Lflag&syscall.ICANON != 0, nil
+}
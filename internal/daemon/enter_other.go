@@ -0,0 +1,7 @@
+//go:build !linux
+
+package daemon
+
+import "syscall"
+
+const ioctlGetTermios = syscall.TIOCGETA
@@ -0,0 +1,55 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSendLineEnter(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	tests := []struct {
+		name  string
+		stty  string
+		enter string
+		want  string
+	}{
+		{"canonical-auto", "-echo", EnterAuto, `\n`},
+		{"raw-auto", "raw -echo", EnterAuto, `\r`},
+		{"raw-lf", "raw -echo", EnterLF, `\n`},
+		{"canonical-cr", "-echo", EnterCR, `\n`}, // ICRNL turns it into LF
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Report the two bytes that arrive: "x" and the terminator.
+			cmd := "stty " + tt.stty + "; echo ready; dd bs=1 count=2 2>/dev/null | od -An -c"
+			if _, err := client.Create(tt.name, CreateOptions{Command: "sh -c '" + cmd + "'"}); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			defer client.Kill(tt.name)
+			waitForOutput(t, client, tt.name, "ready")
+
+			info, err := client.Info(tt.name)
+			if err != nil {
+				t.Fatalf("info: %v", err)
+			}
+			wantMode := TerminalCanonical
+			if strings.HasPrefix(tt.stty, "raw") {
+				wantMode = TerminalRaw
+			}
+			if info.TerminalMode != wantMode {
+				t.Errorf("terminal mode = %q, want %q", info.TerminalMode, wantMode)
+			}
+
+			if err := client.SendLine(tt.name, "x", tt.enter); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			waitForOutput(t, client, tt.name, tt.want)
+		})
+	}
+
+	if err := ValidateEnter("crlf"); err == nil {
+		t.Error("expected error for unknown enter mode")
+	}
+}
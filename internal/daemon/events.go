@@ -0,0 +1,250 @@
+package daemon
+
+import (
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is something that happened to a session, delivered to in-process
+// subscribers (see Server.Subscribe). The concrete types are OutputChunk,
+// StateChange, ScreenChange, SizeChange and Truncation.
+type Event interface {
+	SessionName() string
+	Time() time.Time
+}
+
+// EventHeader carries the fields every event has.
+type EventHeader struct {
+	Session string
+	At      time.Time
+}
+
+func (e EventHeader) SessionName() string { return e.Session }
+func (e EventHeader) Time() time.Time     { return e.At }
+
+// OutputChunk is output read from a session's PTY, after inline images and
+// notifications were removed.
+type OutputChunk struct {
+	EventHeader
+	Data []byte
+}
+
+// StateChange reports a session being created, stopping, or being removed.
+// From is empty for a new session; Removed is set when the session is gone
+// (kill, or the stopped-session TTL expired, which sets Expired too).
+// Reason is the end reason of a session that stopped (see exit.go), with
+// the exit status when the process exited on its own.
+type StateChange struct {
+	EventHeader
+	From    SessionState
+	To      SessionState
+	Removed bool
+	Expired bool
+
+	Reason   string
+	ExitCode *int
+	Signal   string
+}
+
+// ScreenChange reports a session's program switching to the alternate screen
+// (AltScreen set), as full-screen apps do when they start, or back to the
+// main screen.
+type ScreenChange struct {
+	EventHeader
+	AltScreen bool
+}
+
+// SizeChange reports a session's terminal being resized (resize, fit).
+type SizeChange struct {
+	EventHeader
+	Cols int
+	Rows int
+}
+
+// Truncation values for Reason.
+const (
+	TruncationClear        = "clear"         // the buffer was cleared
+	TruncationBufferLimit  = "buffer_limit"  // the memory buffer dropped its oldest output
+	TruncationMemoryBudget = "memory_budget" // evicted to keep all sessions within max_memory
+)
+
+// Truncation reports stored output being dropped.
+type Truncation struct {
+	EventHeader
+	Reason string
+	Bytes  int64
+}
+
+// Spill reports the memory budget moving a stopped session's output (Bytes)
+// to disk, where it stays readable.
+type Spill struct {
+	EventHeader
+	Bytes int64
+}
+
+// EventBufferSize is the channel capacity of a subscription.
+const EventBufferSize = 256
+
+// RecentEvents is how many lifecycle events of each session the daemon
+// keeps for describe.
+const RecentEvents = 10
+
+// Subscription receives events until it is closed. Events are dropped, not
+// queued, when C is full, so a slow subscriber never stalls a session.
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	filter  string
+	dropped atomic.Uint64
+	bus     *eventBus
+
+	// lifecycle skips OutputChunk and ScreenChange events, so output
+	// floods cannot crowd the rest out of C.
+	lifecycle bool
+}
+
+// Dropped returns how many events did not fit into C.
+func (sub *Subscription) Dropped() uint64 {
+	return sub.dropped.Load()
+}
+
+// Close ends the subscription and closes C.
+func (sub *Subscription) Close() {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+	if _, ok := sub.bus.subs[sub]; ok {
+		delete(sub.bus.subs, sub)
+		close(sub.ch)
+	}
+}
+
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+	// recent holds each session's last RecentEvents lifecycle events, kept
+	// whether anyone subscribes or not.
+	recent map[string][]LifecycleEvent
+}
+
+// active reports whether anyone is listening, so callers can skip building
+// events nobody receives.
+func (b *eventBus) active() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs) > 0
+}
+
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remember(e)
+	for sub := range b.subs {
+		if sub.lifecycle {
+			switch e.(type) {
+			case OutputChunk, ScreenChange:
+				continue
+			}
+		}
+		if sub.filter != "" {
+			if ok, _ := path.Match(sub.filter, e.SessionName()); !ok {
+				continue
+			}
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// remember adds e to its session's recent events; a session that is gone
+// takes its events with it. Callers hold b.mu.
+func (b *eventBus) remember(e Event) {
+	ev, ok := lifecycleEvent(e)
+	if !ok {
+		return
+	}
+	if ev.Type == EventKilled || ev.Type == EventRemoved {
+		delete(b.recent, ev.Session)
+		return
+	}
+	if b.recent == nil {
+		b.recent = make(map[string][]LifecycleEvent)
+	}
+	events := append(b.recent[ev.Session], ev)
+	if len(events) > RecentEvents {
+		events = append([]LifecycleEvent(nil), events[len(events)-RecentEvents:]...)
+	}
+	b.recent[ev.Session] = events
+}
+
+// recentEvents returns a session's recent lifecycle events, oldest first.
+func (b *eventBus) recentEvents(name string) []LifecycleEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]LifecycleEvent(nil), b.recent[name]...)
+}
+
+// Subscribe returns a subscription to events of sessions whose name matches
+// filter, a path.Match pattern such as "build-*"; an empty filter matches
+// every session. It is an in-process API for programs embedding the daemon,
+// independent of the socket protocol.
+func (s *Server) Subscribe(filter string) (*Subscription, error) {
+	return s.subscribe(filter, false)
+}
+
+func (s *Server) subscribe(filter string, lifecycle bool) (*Subscription, error) {
+	if filter != "" {
+		if _, err := path.Match(filter, ""); err != nil {
+			return nil, err
+		}
+	}
+	ch := make(chan Event, EventBufferSize)
+	sub := &Subscription{C: ch, ch: ch, filter: filter, lifecycle: lifecycle, bus: &s.events}
+
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+	if s.events.subs == nil {
+		s.events.subs = make(map[*Subscription]struct{})
+	}
+	s.events.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// setAltScreen records a session's switch to or from the alternate screen
+// and publishes it.
+func (s *Server) setAltScreen(name string, h *sessionHandle, active bool) {
+	s.mu.Lock()
+	h.altScreen = active
+	s.mu.Unlock()
+	s.events.publish(ScreenChange{
+		EventHeader: EventHeader{Session: name, At: time.Now()},
+		AltScreen:   active,
+	})
+}
+
+func (s *Server) publishState(name string, from, to SessionState, removed bool) {
+	s.events.publish(StateChange{
+		EventHeader: EventHeader{Session: name, At: time.Now()},
+		From:        from,
+		To:          to,
+		Removed:     removed,
+	})
+}
+
+// publishStop publishes a running session stopping, with why it ended.
+// Called with s.mu held.
+func (s *Server) publishStop(name string, h *sessionHandle) {
+	s.events.publish(StateChange{
+		EventHeader: EventHeader{Session: name, At: time.Now()},
+		From:        StateRunning,
+		To:          StateStopped,
+		Reason:      h.endReason,
+		ExitCode:    h.exitCode,
+		Signal:      h.exitSignal,
+	})
+}
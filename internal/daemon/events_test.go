@@ -0,0 +1,269 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscribeEvents(t *testing.T) {
+	storage := NewMemoryStorage(1024 * 1024)
+	srv, err := NewServer(WithStorage(storage), WithSocketDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	go srv.Start()
+	defer srv.Shutdown()
+
+	client := NewClientWithSocketPath(srv.socketPath())
+	deadline := time.Now().Add(2 * time.Second)
+	for !client.Ping() {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sub, err := srv.Subscribe("ev-*")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	if _, err := client.Create("other", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create other: %v", err)
+	}
+	defer client.Kill("other")
+	if _, err := client.Create("ev-1", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := client.Send("ev-1", "echo event-marker", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	var output strings.Builder
+	var created bool
+	next := func() Event {
+		t.Helper()
+		select {
+		case e := <-sub.C:
+			if e.SessionName() != "ev-1" {
+				t.Fatalf("event for %q passed filter", e.SessionName())
+			}
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events (output so far %q)", output.String())
+			return nil
+		}
+	}
+	// The first occurrence is the echoed command line.
+	for strings.Count(output.String(), "event-marker") < 2 {
+		switch e := next().(type) {
+		case StateChange:
+			if e.From == "" && e.To == StateRunning {
+				created = true
+			}
+		case OutputChunk:
+			output.Write(e.Data)
+		}
+	}
+	if !created {
+		t.Error("no StateChange for session creation")
+	}
+
+	if err := client.Clear("ev-1"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	for {
+		if e, ok := next().(Truncation); ok {
+			if e.Reason != TruncationClear || e.Bytes == 0 {
+				t.Errorf("truncation = %+v, want clear with bytes", e)
+			}
+			break
+		}
+	}
+
+	if err := client.Kill("ev-1"); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	for {
+		if e, ok := next().(StateChange); ok && e.Removed {
+			break
+		}
+	}
+}
+
+func TestSubscribeInvalidFilter(t *testing.T) {
+	srv, err := NewServer(WithStorage(NewMemoryStorage(1024)), WithSocketDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if _, err := srv.Subscribe("["); err == nil {
+		t.Error("expected error for malformed filter")
+	}
+}
+
+func TestAltScreenEvents(t *testing.T) {
+	storage := NewMemoryStorage(1024 * 1024)
+	srv, err := NewServer(WithStorage(storage), WithSocketDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	go srv.Start()
+	defer srv.Shutdown()
+
+	client := NewClientWithSocketPath(srv.socketPath())
+	deadline := time.Now().Add(2 * time.Second)
+	for !client.Ping() {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sub, err := srv.Subscribe("alt")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	if _, err := client.Create("alt", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("alt")
+
+	nextSwitch := func() bool {
+		t.Helper()
+		for {
+			select {
+			case e := <-sub.C:
+				if sc, ok := e.(ScreenChange); ok {
+					return sc.AltScreen
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for a screen change")
+			}
+		}
+	}
+
+	if err := client.Send("alt", `printf '\033[?1049h'`, true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if !nextSwitch() {
+		t.Fatal("first switch should enter the alternate screen")
+	}
+	info, err := client.Info("alt")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if !info.AltScreen {
+		t.Error("info should report the alternate screen")
+	}
+
+	if err := client.Send("alt", `printf '\033[?1049l'`, true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if nextSwitch() {
+		t.Fatal("second switch should leave the alternate screen")
+	}
+	if info, err = client.Info("alt"); err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.AltScreen {
+		t.Error("info should report the main screen")
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if err := client.Events(context.Background(), "[", nil, nil); err == nil || !strings.Contains(err.Error(), "invalid session pattern") {
+		t.Errorf("malformed pattern: err = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{})
+	events := make(chan LifecycleEvent, 64)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Events(ctx, "lc-*", func() { close(ready) }, func(ev LifecycleEvent) error {
+			events <- ev
+			return nil
+		})
+	}()
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatalf("Events: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out subscribing")
+	}
+
+	next := func(want string) LifecycleEvent {
+		t.Helper()
+		for {
+			select {
+			case ev := <-events:
+				if !strings.HasPrefix(ev.Session, "lc-") {
+					t.Fatalf("event for %q passed the pattern", ev.Session)
+				}
+				if ev.Type == want {
+					return ev
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for a %s event", want)
+			}
+		}
+	}
+
+	if _, err := client.Create("other", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("other")
+	if _, err := client.Create("lc-sh", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if ev := next(EventCreated); ev.Session != "lc-sh" {
+		t.Errorf("created = %+v", ev)
+	}
+	if err := client.Resize("lc-sh", 100, 30); err != nil {
+		t.Fatalf("resize: %v", err)
+	}
+	if ev := next(EventResized); ev.Cols != 100 || ev.Rows != 30 {
+		t.Errorf("resized = %+v", ev)
+	}
+	client.Send("lc-sh", "echo some-output", true)
+	waitForOutput(t, client, "lc-sh", "some-output\r\n")
+	if err := client.Clear("lc-sh"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if ev := next(EventOutputTruncated); ev.Reason != TruncationClear || ev.Bytes == 0 {
+		t.Errorf("output-truncated = %+v", ev)
+	}
+	if err := client.Stop("lc-sh"); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if ev := next(EventStopped); ev.Reason != EndReasonStopped {
+		t.Errorf("stopped = %+v", ev)
+	}
+	if err := client.Kill("lc-sh"); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	next(EventKilled)
+
+	if _, err := client.Create("lc-exit", CreateOptions{Command: "sh -c 'exit 4'"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("lc-exit")
+	if ev := next(EventExited); ev.Session != "lc-exit" || ev.Reason != EndReasonExited || ev.ExitCode == nil || *ev.ExitCode != 4 {
+		t.Errorf("exited = %+v", ev)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Events after cancel: %v", err)
+	}
+}
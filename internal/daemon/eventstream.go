@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+)
+
+// Lifecycle event types, the Type of a LifecycleEvent.
+const (
+	EventCreated         = "created"          // a session started
+	EventStopped         = "stopped"          // the stop action ended it
+	EventExited          = "exited"           // its process ended on its own (or its PTY failed)
+	EventKilled          = "killed"           // the kill action removed it
+	EventRemoved         = "removed"          // the stopped-session TTL removed it
+	EventOutputTruncated = "output-truncated" // stored output was dropped (clear, buffer limit)
+	EventResized         = "resized"          // its terminal was resized
+	EventSpilled         = "spilled"          // the memory budget moved its output to disk
+	// EventDropped reports Count events lost because the subscriber fell
+	// behind.
+	EventDropped = "dropped"
+)
+
+// LifecycleEvent is one frame of an events stream: something that happened
+// to a session, in a form meant for orchestrators rather than for the
+// output pipeline (see Event for the in-process types).
+type LifecycleEvent struct {
+	Type    string    `json:"type"`
+	Session string    `json:"session,omitempty"`
+	At      time.Time `json:"at"`
+
+	// exited and stopped
+	Reason   string `json:"reason,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Signal   string `json:"signal,omitempty"`
+
+	// output-truncated: Reason is clear, buffer_limit or memory_budget;
+	// spilled
+	Bytes int64 `json:"bytes,omitempty"`
+
+	// resized
+	Cols int `json:"cols,omitempty"`
+	Rows int `json:"rows,omitempty"`
+
+	// dropped
+	Count uint64 `json:"count,omitempty"`
+}
+
+// lifecycleEvent converts a bus event for the events stream; ok is false
+// for events the stream does not carry.
+func lifecycleEvent(e Event) (ev LifecycleEvent, ok bool) {
+	ev = LifecycleEvent{Session: e.SessionName(), At: e.Time()}
+	switch e := e.(type) {
+	case StateChange:
+		switch {
+		case e.Expired:
+			ev.Type = EventRemoved
+		case e.Removed:
+			ev.Type = EventKilled
+		case e.From == "" && e.To == StateRunning:
+			ev.Type = EventCreated
+		case e.To == StateStopped && e.Reason == EndReasonStopped:
+			ev.Type = EventStopped
+			ev.Reason = e.Reason
+		case e.To == StateStopped:
+			ev.Type = EventExited
+			ev.Reason, ev.ExitCode, ev.Signal = e.Reason, e.ExitCode, e.Signal
+		default:
+			return ev, false
+		}
+	case Truncation:
+		ev.Type, ev.Reason, ev.Bytes = EventOutputTruncated, e.Reason, e.Bytes
+	case SizeChange:
+		ev.Type, ev.Cols, ev.Rows = EventResized, e.Cols, e.Rows
+	case Spill:
+		ev.Type, ev.Bytes = EventSpilled, e.Bytes
+	default:
+		return ev, false
+	}
+	return ev, true
+}
+
+// handleEvents pushes lifecycle events of the sessions matching req.Name, a
+// path.Match pattern ("" for all), over conn until the client hangs up.
+// Frames are Responses, one JSON object per line: first one without data
+// once the subscription is in place, then one LifecycleEvent each.
+func (s *Server) handleEvents(conn net.Conn, req Request) {
+	sub, err := s.subscribe(req.Name, true)
+	if err != nil {
+		s.sendResponse(conn, Response{Success: false, Error: "invalid session pattern: " + err.Error()})
+		return
+	}
+	defer sub.Close()
+
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn) //nolint:errcheck // any error means the client is gone
+		close(gone)
+	}()
+
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(Response{Success: true}); err != nil {
+		return
+	}
+	var reported uint64
+	for {
+		select {
+		case <-gone:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if dropped := sub.Dropped(); dropped > reported {
+				lost := LifecycleEvent{Type: EventDropped, At: time.Now(), Count: dropped - reported}
+				if enc.Encode(Response{Success: true, Data: lost}) != nil {
+					return
+				}
+				reported = dropped
+			}
+			ev, ok := lifecycleEvent(e)
+			if !ok {
+				continue
+			}
+			if enc.Encode(Response{Success: true, Data: ev}) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"strings"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// timeoutTailLines is how many lines of output a timeout diagnosis keeps.
+const timeoutTailLines = 5
+
+// Next steps suggested for a timed-out exec.
+const (
+	SuggestRead      = "read"      // a prompt is back: the command finished, check the output
+	SuggestRetry     = "retry"     // still printing: wait longer
+	SuggestInterrupt = "interrupt" // silent since the input: it may be stuck
+)
+
+// TimeoutDiagnosis describes where an exec stood when its wait timed out,
+// so the caller can decide what to do next without another call.
+type TimeoutDiagnosis struct {
+	LastLines  []string `json:"last_lines"`  // ANSI stripped
+	OutputGrew bool     `json:"output_grew"` // anything after the echoed input
+	PromptSeen bool     `json:"prompt_seen"` // the output ends in a prompt
+	Suggestion string   `json:"suggestion"`
+}
+
+// DiagnoseTimeout inspects the output an exec collected before it timed out.
+func DiagnoseTimeout(output, input string) *TimeoutDiagnosis {
+	parts := SplitExecOutput(output, input)
+	d := &TimeoutDiagnosis{
+		LastLines:  tailLines(output, timeoutTailLines),
+		OutputGrew: strings.TrimSpace(vterm.StripDefault(parts.Body+parts.Prompt)) != "",
+		PromptSeen: parts.Prompt != "",
+	}
+	switch {
+	case d.PromptSeen:
+		d.Suggestion = SuggestRead
+	case d.OutputGrew:
+		d.Suggestion = SuggestRetry
+	default:
+		d.Suggestion = SuggestInterrupt
+	}
+	return d
+}
+
+// tailLines returns the last n lines of output as they would look on
+// screen, without trailing blank lines.
+func tailLines(output string, n int) []string {
+	text := NormalizeNewlines(vterm.StripDefault(output), NewlinesDisplay)
+	lines := strings.Split(strings.TrimRight(text, " \t\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return []string{}
+	}
+	lines = lines[max(0, len(lines)-n):]
+	for i, line := range lines {
+		lines[i], _ = truncateLine(strings.TrimRight(line, " \t"), MaxLineLength)
+	}
+	return lines
+}
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiagnoseTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		input  string
+		expect TimeoutDiagnosis
+	}{
+		{
+			name:   "prompt back, pattern never matched",
+			output: "make\r\ndone\r\n\x1b[32m$\x1b[0m ",
+			input:  "make",
+			expect: TimeoutDiagnosis{
+				LastLines:  []string{"make", "done", "$"},
+				OutputGrew: true,
+				PromptSeen: true,
+				Suggestion: SuggestRead,
+			},
+		},
+		{
+			name:   "still printing",
+			output: "make\r\n1\r\n2\r\n3\r\n4\r\n5\r\n6\r\n",
+			input:  "make",
+			expect: TimeoutDiagnosis{
+				LastLines:  []string{"2", "3", "4", "5", "6"},
+				OutputGrew: true,
+				Suggestion: SuggestRetry,
+			},
+		},
+		{
+			name:   "only the echo",
+			output: "sleep 100\r\n",
+			input:  "sleep 100",
+			expect: TimeoutDiagnosis{
+				LastLines:  []string{"sleep 100"},
+				Suggestion: SuggestInterrupt,
+			},
+		},
+		{
+			name:   "nothing at all",
+			output: "",
+			input:  "cat",
+			expect: TimeoutDiagnosis{
+				LastLines:  []string{},
+				Suggestion: SuggestInterrupt,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiagnoseTimeout(tt.output, tt.input)
+			if !reflect.DeepEqual(*got, tt.expect) {
+				t.Errorf("DiagnoseTimeout(%q) = %+v, want %+v", tt.output, *got, tt.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"strings"
+	"time"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// Exec outcomes reported by exec_status.
+const (
+	ExecRunning   = "running"   // the exec is still waiting for its output
+	ExecCompleted = "completed" // the wait condition was met
+	ExecTimedOut  = "timeout"   // the wait gave up
+	ExecFailed    = "error"     // the exec failed otherwise
+)
+
+// ExecStatus is the progress of a session's latest exec, for callers other
+// than the one waiting on it. Status is how the exec's wait ended; the
+// command itself may run on after a timeout, so Elapsed, Bytes, Idle and
+// LastLine always describe the session now.
+type ExecStatus struct {
+	ID       int64   `json:"exec_id"`
+	Session  string  `json:"session"`
+	Input    string  `json:"input"`
+	Status   string  `json:"status"`
+	Started  string  `json:"started_at"`
+	Elapsed  float64 `json:"elapsed_seconds"`
+	Bytes    int64   `json:"bytes"`        // PTY output since the exec started
+	Idle     float64 `json:"idle_seconds"` // time since the session last produced output
+	LastLine string  `json:"last_line"`    // last non-empty line of output, ANSI stripped
+}
+
+// execProgress tracks an exec from exec_begin to exec_end. Guarded by
+// Server.mu.
+type execProgress struct {
+	id        int64
+	input     string
+	startedAt time.Time
+	started   time.Duration // monoNow at begin
+	ptyBase   int64         // session's PTY bytes in at begin
+	status    string
+}
+
+// lastLineLookback is how much output is scanned for the last line.
+const lastLineLookback = 4096
+
+// lastLine returns the last non-empty line of a session's output as it
+// would look on screen.
+func lastLine(screen *vterm.Screen, storage OutputStorage, name string) string {
+	var text string
+	if screen != nil {
+		text = screen.String()
+	} else {
+		size, err := storage.Size(name)
+		if err != nil {
+			return ""
+		}
+		from := max(0, size-lastLineLookback-TailBytesLookback)
+		data, err := storage.ReadFrom(name, from)
+		if err != nil {
+			return ""
+		}
+		text = vterm.StripDefault(string(SafeTailBytes(data, lastLineLookback)))
+		text = NormalizeNewlines(text, NewlinesDisplay)
+	}
+	lines := strings.Split(text, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			line, _ = truncateLine(line, MaxLineLength)
+			return line
+		}
+	}
+	return ""
+}
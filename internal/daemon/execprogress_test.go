@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExecStatus(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("progress", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("progress")
+
+	if _, err := client.ExecStatus("progress", 0); err == nil {
+		t.Fatal("expected error before any exec")
+	}
+
+	type execDone struct {
+		result *ExecResult
+		err    error
+	}
+	done := make(chan execDone, 1)
+	go func() {
+		result, err := client.Exec("progress", ExecOptions{
+			Input:       "echo step-one; sleep 1; echo step-two",
+			WaitPattern: `(?m)^step-two`,
+			TimeoutSec:  10,
+		})
+		done <- execDone{result, err}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	var status *ExecStatus
+	for {
+		var err error
+		status, err = client.ExecStatus("progress", 0)
+		if err == nil && status.LastLine == "step-one" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("exec never reported progress: %+v, %v", status, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if status.Status != ExecRunning {
+		t.Errorf("status = %q, want %q", status.Status, ExecRunning)
+	}
+	if status.Bytes <= 0 {
+		t.Errorf("bytes = %d, want > 0", status.Bytes)
+	}
+
+	d := <-done
+	if d.err != nil {
+		t.Fatalf("exec: %v", d.err)
+	}
+	if d.result.ID != status.ID {
+		t.Errorf("exec id = %d, status reported %d", d.result.ID, status.ID)
+	}
+
+	final, err := client.ExecStatus("progress", d.result.ID)
+	if err != nil {
+		t.Fatalf("exec status: %v", err)
+	}
+	if final.Status != ExecCompleted {
+		t.Errorf("status = %q, want %q", final.Status, ExecCompleted)
+	}
+	if final.Elapsed < 1 {
+		t.Errorf("elapsed = %.2f, want >= 1", final.Elapsed)
+	}
+	if _, err := client.ExecStatus("progress", d.result.ID+1); err == nil {
+		t.Error("expected error for unknown exec id")
+	}
+}
@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// promptSuffix matches the end of a typical interactive prompt: shell
+// ($ # % >), zsh/starship arrows, and "Password:"-style questions.
+var promptSuffix = regexp.MustCompile(`[$#%>❯➜»:]\s*$`)
+
+// ExecParts is exec output split into the terminal's echo of the input, the
+// command's own output, and the prompt printed after it.
+type ExecParts struct {
+	Echo   string `json:"echo"`
+	Body   string `json:"body"`
+	Prompt string `json:"prompt"`
+	// Split is false when the echo was not found. Body then holds the raw
+	// output, minus any prompt.
+	Split bool `json:"split"`
+}
+
+// SplitExecOutput separates the echoed input and the trailing prompt from
+// exec output. The echo is the leading lines that end with the input; the
+// prompt is a final unterminated line that looks like a prompt. Parts keep
+// their escape sequences; matching is done on stripped text.
+func SplitExecOutput(output, input string) ExecParts {
+	var parts ExecParts
+	rest := output
+
+	echoLines := strings.Count(input, "\n") + 1
+	lastInput := strings.TrimSpace(input[strings.LastIndex(input, "\n")+1:])
+	if end := nthLineEnd(rest, echoLines); end > 0 {
+		echo := rest[:end]
+		line := strings.TrimRight(vterm.StripDefault(echo), "\r\n ")
+		if lastInput == "" || strings.HasSuffix(line, lastInput) {
+			parts.Echo = echo
+			parts.Split = true
+			rest = rest[end:]
+		}
+	}
+
+	if i := strings.LastIndex(rest, "\n"); i < len(rest)-1 {
+		last := rest[i+1:]
+		plain := vterm.StripDefault(last)
+		if strings.TrimSpace(plain) != "" && promptSuffix.MatchString(plain) {
+			parts.Prompt = last
+			rest = rest[:i+1]
+		}
+	}
+
+	parts.Body = rest
+	return parts
+}
+
+// nthLineEnd returns the index just past the n-th newline in s, or -1.
+func nthLineEnd(s string, n int) int {
+	end := 0
+	for i := 0; i < n; i++ {
+		j := strings.IndexByte(s[end:], '\n')
+		if j < 0 {
+			return -1
+		}
+		end += j + 1
+	}
+	return end
+}
+
+// SplitPartialLine holds back the unterminated last line of exec output, so
+// lines holds only whole lines: a spinner frame, progress bar or prompt
+// without a newline ends up in partial instead.
+func SplitPartialLine(output string) (lines, partial string) {
+	i := strings.LastIndexByte(output, '\n')
+	return output[:i+1], output[i+1:]
+}
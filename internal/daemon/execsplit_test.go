@@ -0,0 +1,89 @@
+package daemon
+
+import "testing"
+
+func TestSplitExecOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		input  string
+		expect ExecParts
+	}{
+		{
+			name:   "shell command",
+			output: "echo hi\r\nhi\r\n$ ",
+			input:  "echo hi",
+			expect: ExecParts{Echo: "echo hi\r\n", Body: "hi\r\n", Prompt: "$ ", Split: true},
+		},
+		{
+			name:   "bracketed paste and colored prompt",
+			output: "ls\r\n\x1b[?2004l\ra b\r\n\x1b[?2004h\x1b[32muser@host\x1b[0m:~$ ",
+			input:  "ls",
+			expect: ExecParts{
+				Echo:   "ls\r\n",
+				Body:   "\x1b[?2004l\ra b\r\n",
+				Prompt: "\x1b[?2004h\x1b[32muser@host\x1b[0m:~$ ",
+				Split:  true,
+			},
+		},
+		{
+			name:   "no output",
+			output: "cd /tmp\r\n$ ",
+			input:  "cd /tmp",
+			expect: ExecParts{Echo: "cd /tmp\r\n", Prompt: "$ ", Split: true},
+		},
+		{
+			name:   "python repl",
+			output: "1+1\r\n2\r\n>>> ",
+			input:  "1+1",
+			expect: ExecParts{Echo: "1+1\r\n", Body: "2\r\n", Prompt: ">>> ", Split: true},
+		},
+		{
+			name:   "still running, no prompt",
+			output: "make\r\nbuilding...\r\n",
+			input:  "make",
+			expect: ExecParts{Echo: "make\r\n", Body: "building...\r\n", Split: true},
+		},
+		{
+			name:   "partial line that is not a prompt",
+			output: "printf abc\r\nabc",
+			input:  "printf abc",
+			expect: ExecParts{Echo: "printf abc\r\n", Body: "abc", Split: true},
+		},
+		{
+			name:   "echo disabled",
+			output: "secret accepted\r\n$ ",
+			input:  "hunter2",
+			expect: ExecParts{Body: "secret accepted\r\n", Prompt: "$ "},
+		},
+		{
+			name:   "multi-line input",
+			output: "for i in 1 2; do\r\n> echo $i; done\r\n1\r\n2\r\n$ ",
+			input:  "for i in 1 2; do\necho $i; done",
+			expect: ExecParts{Echo: "for i in 1 2; do\r\n> echo $i; done\r\n", Body: "1\r\n2\r\n", Prompt: "$ ", Split: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitExecOutput(tt.output, tt.input)
+			if got != tt.expect {
+				t.Errorf("SplitExecOutput(%q, %q) =\n%+v\nwant\n%+v", tt.output, tt.input, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestSplitPartialLine(t *testing.T) {
+	for _, tc := range []struct{ output, lines, partial string }{
+		{"a\r\nb\r\n", "a\r\nb\r\n", ""},
+		{"a\r\nb\r\n$ ", "a\r\nb\r\n", "$ "},
+		{"a\nbuilding \\\r|", "a\n", "building \\\r|"},
+		{"no newline", "", "no newline"},
+		{"", "", ""},
+	} {
+		lines, partial := SplitPartialLine(tc.output)
+		if lines != tc.lines || partial != tc.partial {
+			t.Errorf("SplitPartialLine(%q) = %q, %q; want %q, %q", tc.output, lines, partial, tc.lines, tc.partial)
+		}
+	}
+}
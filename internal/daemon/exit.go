@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// End reasons say why a session stopped running (SessionMeta.EndReason,
+// end_reason in info and list).
+const (
+	EndReasonExited   = "exited"    // the process exited on its own
+	EndReasonKilled   = "killed"    // a signal ended the process, not sent by stop
+	EndReasonStopped  = "stopped"   // the stop action
+	EndReasonPTYError = "pty-error" // reading the PTY failed while the process ran; see EndError
+	// EndReasonDaemonShutdown: the daemon shut down (or died) with the
+	// session running.
+	EndReasonDaemonShutdown = "daemon-shutdown"
+)
+
+// ptyClosed reports whether a PTY read error is the normal end of a
+// session: EIO (Linux) or EOF once the last process holding the terminal
+// has closed it, or the daemon having closed the PTY itself.
+func ptyClosed(err error) bool {
+	return err == nil || errors.Is(err, syscall.EIO) || errors.Is(err, io.EOF) || errors.Is(err, os.ErrClosed)
+}
+
+// endReason classifies how a session's capture ended: readErr is the PTY
+// read error that ended it (nil when told to stop) and ps the process's
+// exit status.
+func endReason(readErr error, ps *os.ProcessState) (reason, detail string) {
+	if !ptyClosed(readErr) {
+		return EndReasonPTYError, readErr.Error()
+	}
+	if ps != nil {
+		if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return EndReasonKilled, ""
+		}
+	}
+	return EndReasonExited, ""
+}
+
+// ExitResult is the outcome of waiting for a session's process to exit.
+// ExitCode is nil while the process runs, and for sessions whose exit the
+// daemon did not see (stopped before a daemon restart).
+type ExitResult struct {
+	Exited   bool   `json:"exited"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Signal   string `json:"signal,omitempty"`
+}
+
+// exitStatus turns a finished process into a shell-style exit code: its
+// status, or 128 plus the signal number when a signal killed it.
+func exitStatus(ps *os.ProcessState) (code int, signal string) {
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal()), ws.Signal().String()
+	}
+	return ps.ExitCode(), ""
+}
+
+// recordExit stores the exit status of a session's process and why it
+// ended, unless stop or shutdown already said, and wakes wait-exit callers.
+// readErr is the PTY read error that ended capture. Callers hold Server.mu.
+func (s *Server) recordExit(name string, h *sessionHandle, ps *os.ProcessState, readErr error) {
+	if h.endReason == "" {
+		h.endReason, h.endError = endReason(readErr, ps)
+	}
+	var code *int
+	var signal string
+	if ps != nil {
+		c, sig := exitStatus(ps)
+		code, signal = &c, sig
+		h.exitCode = code
+		h.exitSignal = signal
+	}
+	if s.handles[name] == h {
+		s.storage.UpdateMeta(name, func(meta *SessionMeta) {
+			if code != nil {
+				meta.ExitCode = code
+				meta.ExitSignal = signal
+			}
+			meta.EndReason = h.endReason
+			meta.EndError = h.endError
+		})
+	}
+	if h.exited != nil {
+		close(h.exited)
+		h.exited = nil
+	}
+}
+
+func (h *sessionHandle) exitResult() ExitResult {
+	return ExitResult{Exited: h.state != StateRunning, ExitCode: h.exitCode, Signal: h.exitSignal}
+}
+
+// handleWaitExit blocks until a session's process has exited and its status
+// is known, or req.TimeoutSec passes (capped below the client deadline; the
+// client asks again). A stopped session whose exit the daemon never saw
+// returns at once without a code.
+func (s *Server) handleWaitExit(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	exited := h.exited
+	if exited == nil {
+		result := h.exitResult()
+		s.mu.Unlock()
+		return Response{Success: true, Data: result}
+	}
+	s.mu.Unlock()
+
+	timeout := time.Duration(req.TimeoutSec) * time.Second
+	if maxTimeout := ClientDeadline - 5*time.Second; timeout <= 0 || timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-exited:
+	case <-timer.C:
+		s.metrics.countWait("wait_exit", ExecTimedOut)
+		return Response{Success: true, Data: ExitResult{}}
+	}
+	s.metrics.countWait("wait_exit", ExecCompleted)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Response{Success: true, Data: h.exitResult()}
+}
@@ -0,0 +1,137 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/schovi/shelli/internal/wait"
+)
+
+func TestWaitExit(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("fails", CreateOptions{Command: "sh -c 'exit 3'"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("fails")
+
+	result, err := client.WaitExit("fails", 5)
+	if err != nil {
+		t.Fatalf("WaitExit: %v", err)
+	}
+	if !result.Exited || result.ExitCode == nil || *result.ExitCode != 3 || result.Signal != "" {
+		t.Errorf("result = %+v, want exit code 3", result)
+	}
+	info, err := client.Info("fails")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.ExitCode == nil || *info.ExitCode != 3 {
+		t.Errorf("info exit code = %v, want 3", info.ExitCode)
+	}
+	if info.EndReason != EndReasonExited {
+		t.Errorf("info end reason = %q, want %q", info.EndReason, EndReasonExited)
+	}
+
+	// Already exited: returns at once.
+	if again, err := client.WaitExit("fails", 1); err != nil || *again.ExitCode != 3 {
+		t.Errorf("second WaitExit = %+v, %v", again, err)
+	}
+
+	if _, err := client.Create("sleeper", CreateOptions{Command: "sleep 30"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("sleeper")
+
+	result, err = client.WaitExit("sleeper", 1)
+	if !errors.Is(err, wait.ErrTimeout) || result == nil || result.Exited {
+		t.Fatalf("WaitExit on a running session = %+v, %v; want a timeout", result, err)
+	}
+
+	if err := client.Stop("sleeper"); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	result, err = client.WaitExit("sleeper", 5)
+	if err != nil {
+		t.Fatalf("WaitExit after stop: %v", err)
+	}
+	if result.ExitCode == nil || *result.ExitCode != 128+15 || result.Signal != "terminated" {
+		t.Errorf("result = %+v, want 143 (terminated)", result)
+	}
+	if info, _ := client.Info("sleeper"); info == nil || info.EndReason != EndReasonStopped {
+		t.Errorf("info after stop = %+v, want end reason %q", info, EndReasonStopped)
+	}
+
+	if _, err := client.WaitExit("missing", 1); err == nil {
+		t.Error("WaitExit on a missing session should fail")
+	}
+}
+
+func TestEndReason(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("victim", CreateOptions{Command: "sleep 30"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("victim")
+	info, err := client.Info("victim")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	syscall.Kill(info.PID, syscall.SIGKILL)
+	if _, err := client.WaitExit("victim", 5); err != nil {
+		t.Fatalf("WaitExit: %v", err)
+	}
+	sessions, err := client.List()
+	if err != nil || len(sessions) != 1 || sessions[0].EndReason != EndReasonKilled {
+		t.Errorf("list = %+v, %v; want end reason %q", sessions, err, EndReasonKilled)
+	}
+
+	signaled := exec.Command("sh", "-c", "kill -9 $$")
+	signaled.Run() //nolint:errcheck // it is killed on purpose
+	exited := exec.Command("true")
+	exited.Run() //nolint:errcheck
+	for _, tc := range []struct {
+		err    error
+		ps     *os.ProcessState
+		reason string
+	}{
+		{&os.PathError{Op: "read", Path: "/dev/ptmx", Err: syscall.EIO}, exited.ProcessState, EndReasonExited},
+		{io.EOF, exited.ProcessState, EndReasonExited},
+		{nil, signaled.ProcessState, EndReasonKilled},
+		{&os.PathError{Op: "read", Path: "/dev/ptmx", Err: syscall.EBADF}, exited.ProcessState, EndReasonPTYError},
+	} {
+		if reason, detail := endReason(tc.err, tc.ps); reason != tc.reason || (reason == EndReasonPTYError) != (detail != "") {
+			t.Errorf("endReason(%v) = %q, %q; want %q", tc.err, reason, detail, tc.reason)
+		}
+	}
+}
+
+func TestEndReasonShutdown(t *testing.T) {
+	// Shut down by hand rather than through the cleanup.
+	srv, client, _ := startTestServer(t)
+
+	if _, err := client.Create("left", CreateOptions{Command: "sleep 30"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	srv.Shutdown()
+	meta, err := srv.storage.LoadMeta("left")
+	if err != nil {
+		t.Fatalf("load meta: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for meta.State != StateStopped && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		meta, _ = srv.storage.LoadMeta("left")
+	}
+	if meta.EndReason != EndReasonDaemonShutdown {
+		t.Errorf("end reason after shutdown = %q, want %q", meta.EndReason, EndReasonDaemonShutdown)
+	}
+}
@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// ExtractJSON returns the JSON objects and arrays in terminal output, in
+// order, with escape sequences stripped first. A value must start a line
+// (after indentation), so the echo of a command holding JSON is not picked
+// up; anything after the value on its last line, such as a prompt, is
+// ignored. Numbers are kept as json.Number so they survive unchanged.
+func ExtractJSON(output string) []interface{} {
+	text := vterm.StripDefault(output)
+	var values []interface{}
+	for i := 0; i < len(text); {
+		lineEnd := strings.IndexByte(text[i:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += i + 1
+		}
+		line := text[i:lineEnd]
+		start := i + len(line) - len(strings.TrimLeft(line, " \t\r"))
+		if start < len(text) && (text[start] == '{' || text[start] == '[') {
+			dec := json.NewDecoder(strings.NewReader(text[start:]))
+			dec.UseNumber()
+			var v interface{}
+			if err := dec.Decode(&v); err == nil {
+				values = append(values, v)
+				end := start + int(dec.InputOffset())
+				if next := strings.IndexByte(text[end:], '\n'); next >= 0 {
+					i = end + next + 1
+				} else {
+					i = len(text)
+				}
+				continue
+			}
+		}
+		i = lineEnd
+	}
+	return values
+}
+
+// jsonPathStep is one key or array index of a JSON path.
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath parses a path like ".items[0].name": keys separated by dots
+// (the leading dot is optional) and [N] array indexes. "" and "." are the
+// whole value.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	var steps []jsonPathStep
+	rest := strings.TrimPrefix(path, ".")
+	for rest != "" {
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: missing ]", path)
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: bad index %q", path, rest[1:end])
+			}
+			steps = append(steps, jsonPathStep{index: n, isIndex: true})
+			rest = rest[end+1:]
+		case rest[0] == '.':
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' || rest[0] == '[' {
+				return nil, fmt.Errorf("invalid JSON path %q: empty key", path)
+			}
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			steps = append(steps, jsonPathStep{key: rest[:end]})
+			rest = rest[end:]
+		}
+	}
+	return steps, nil
+}
+
+// lookupJSONPath follows steps into v, a value decoded by ExtractJSON. ok is
+// false when a key or index is missing or the value has the wrong type.
+func lookupJSONPath(v interface{}, steps []jsonPathStep) (interface{}, bool) {
+	for _, step := range steps {
+		if step.isIndex {
+			arr, ok := v.([]interface{})
+			if !ok || step.index >= len(arr) {
+				return nil, false
+			}
+			v = arr[step.index]
+			continue
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[step.key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// ExtractJSONPath is ExtractJSON narrowed to the value at path in each
+// extracted value; values without it are left out.
+func ExtractJSONPath(output, path string) ([]interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	for _, v := range ExtractJSON(output) {
+		if found, ok := lookupJSONPath(v, steps); ok {
+			values = append(values, found)
+		}
+	}
+	return values, nil
+}
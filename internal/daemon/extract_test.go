@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExtractJSON(t *testing.T) {
+	output := "curl -s localhost/api | jq '{\"ok\": true}'\r\n" +
+		"\x1b[1;39m{\r\n  \x1b[0m\x1b[34;1m\"items\"\x1b[0m: [{\"name\": \"a\", \"size\": 12345678901234567890}, {\"name\": \"b\"}]\r\n}\x1b[0m\r\n" +
+		"[INFO] not json\r\n" +
+		"  [1, 2]\r\n" +
+		"{\"status\":\"done\"}$ "
+	values := ExtractJSON(output)
+	data, _ := json.Marshal(values)
+	want := `[{"items":[{"name":"a","size":12345678901234567890},{"name":"b"}]},[1,2],{"status":"done"}]`
+	if string(data) != want {
+		t.Errorf("ExtractJSON = %s\nwant %s", data, want)
+	}
+
+	for path, want := range map[string]string{
+		".items[1].name": `["b"]`,
+		"items[0]":       `[{"name":"a","size":12345678901234567890}]`,
+		"status":         `["done"]`,
+		".":              want,
+		".items[5]":      `[]`,
+	} {
+		values, err := ExtractJSONPath(output, path)
+		if err != nil {
+			t.Fatalf("ExtractJSONPath(%q): %v", path, err)
+		}
+		if data, _ := json.Marshal(values); string(data) != want {
+			t.Errorf("ExtractJSONPath(%q) = %s, want %s", path, data, want)
+		}
+	}
+
+	for _, path := range []string{".items[", ".items[-1]", "a..b", "a."} {
+		if _, err := ExtractJSONPath(output, path); err == nil {
+			t.Errorf("ExtractJSONPath(%q) should fail", path)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when Advance is called. Sleep blocks
+// until the clock has been advanced past its end.
+type fakeClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	sleepers []fakeSleeper
+}
+
+type fakeSleeper struct {
+	until time.Time
+	wake  chan struct{}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.mu.Lock()
+	wake := make(chan struct{})
+	c.sleepers = append(c.sleepers, fakeSleeper{until: c.now.Add(d), wake: wake})
+	c.mu.Unlock()
+	<-wake
+}
+
+// Advance moves the clock on by d and wakes the sleeps that have ended.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	kept := c.sleepers[:0]
+	for _, s := range c.sleepers {
+		if s.until.After(c.now) {
+			kept = append(kept, s)
+		} else {
+			close(s.wake)
+		}
+	}
+	c.sleepers = kept
+}
+
+// waitForSleepers waits (in real time) until n goroutines sleep on c.
+func (c *fakeClock) waitForSleepers(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		got := len(c.sleepers)
+		c.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d sleepers", n)
+}
+
+// fakePTY is a PTYDriver whose terminals are socket pairs: the daemon gets
+// one end, the test the other, so the test decides exactly what the program
+// prints and sees every byte sent to it. The command still runs, with its
+// stdio on /dev/null, so stop and kill have a process to act on. Resizes
+// are recorded rather than applied.
+type fakePTY struct {
+	terms chan *os.File
+
+	mu       sync.Mutex
+	sizes    [][2]int
+	notified int
+}
+
+func newFakePTY() *fakePTY {
+	return &fakePTY{terms: make(chan *os.File, 16)}
+}
+
+func (f *fakePTY) Start(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Non-blocking, so the daemon's read deadlines work.
+	if err := syscall.SetNonblock(fds[0], true); err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		syscall.Close(fds[0])
+		syscall.Close(fds[1])
+		return nil, err
+	}
+	f.terms <- os.NewFile(uintptr(fds[1]), "fake-pty-program")
+	return os.NewFile(uintptr(fds[0]), "fake-pty"), nil
+}
+
+func (f *fakePTY) Resize(_ *os.File, cols, rows int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sizes = append(f.sizes, [2]int{cols, rows})
+	return nil
+}
+
+func (f *fakePTY) NotifyResize(*os.Process) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notified++
+	return nil
+}
+
+// resizes returns the sizes set so far and how many redraws were asked for.
+func (f *fakePTY) resizes() ([][2]int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][2]int(nil), f.sizes...), f.notified
+}
+
+// terminal returns the program's end of the next session created.
+func (f *fakePTY) terminal(t *testing.T) *os.File {
+	t.Helper()
+	select {
+	case term := <-f.terms:
+		t.Cleanup(func() { term.Close() })
+		return term
+	case <-time.After(5 * time.Second):
+		t.Fatal("no session was started")
+		return nil
+	}
+}
+
+func TestFakePTY(t *testing.T) {
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("fake", CreateOptions{Command: "sleep 60"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("fake")
+	term := fake.terminal(t)
+
+	if _, err := term.Write([]byte("$ ")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	waitForOutput(t, client, "fake", "$ ")
+
+	if err := client.Send("fake", "hello", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	buf := make([]byte, 64)
+	term.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := term.Read(buf)
+	if err != nil || string(buf[:n]) != "hello\n" {
+		t.Errorf("program got %q, %v; want %q", buf[:n], err, "hello\n")
+	}
+}
+
+func TestFakePTYResize(t *testing.T) {
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("fake", CreateOptions{Command: "sleep 60"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("fake")
+	fake.terminal(t)
+
+	if err := client.Resize("fake", 120, 40); err != nil {
+		t.Fatalf("resize: %v", err)
+	}
+	sizes, notified := fake.resizes()
+	if len(sizes) != 1 || sizes[0] != [2]int{120, 40} {
+		t.Errorf("driver sizes = %v, want [[120 40]]", sizes)
+	}
+	if notified != 1 {
+		t.Errorf("driver redraws = %d, want 1", notified)
+	}
+}
+
+func TestStoppedTTLFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	fake := newFakePTY()
+	srv, client, cleanup := startTestServer(t, WithClock(clock), WithPTYDriver(fake), WithStoppedTTL(time.Hour))
+	defer cleanup()
+
+	if _, err := client.Create("ttl", CreateOptions{Command: "sleep 60"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	fake.terminal(t)
+	if err := client.Stop("ttl"); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	// The capture goroutine records the stop time again once the process
+	// is reaped.
+	if _, err := client.WaitExit("ttl", 5); err != nil {
+		t.Fatalf("wait exit: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	srv.cleanupExpiredSessions()
+	if _, err := client.Info("ttl"); err != nil {
+		t.Fatalf("session removed at exactly the TTL: %v", err)
+	}
+
+	clock.Advance(time.Second)
+	srv.cleanupExpiredSessions()
+	if _, err := client.Info("ttl"); err == nil {
+		t.Error("session should be removed once the TTL passed")
+	}
+}
+
+func TestSnapshotSettleFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithClock(clock), WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("tui", CreateOptions{Command: "sleep 60", TUIMode: true}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("tui")
+	term := fake.terminal(t)
+	term.Write([]byte("frame one"))
+	waitForOutput(t, client, "tui", "frame one")
+
+	type result struct {
+		output  string
+		settled bool
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		snap, err := client.SnapshotDetailed("tui", 100, 5, 0, 0, true)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{snap.Output, snap.Settled, nil}
+	}()
+
+	// Output every poll keeps the snapshot from settling.
+	for i := 0; i < 10; i++ {
+		clock.waitForSleepers(t, 1)
+		term.Write([]byte("."))
+		waitForOutput(t, client, "tui", "frame one"+strings.Repeat(".", i+1))
+		clock.Advance(SnapshotPollInterval)
+	}
+	select {
+	case r := <-done:
+		t.Fatalf("snapshot returned while output was changing: %q, %v", r.output, r.err)
+	default:
+	}
+
+	// The poll after the last write saw it; 100ms of quiet takes four more.
+	for i := 0; i < 3; i++ {
+		clock.waitForSleepers(t, 1)
+		clock.Advance(SnapshotPollInterval)
+	}
+	clock.waitForSleepers(t, 1)
+	select {
+	case r := <-done:
+		t.Fatalf("snapshot returned before the settle time: %q, %v", r.output, r.err)
+	default:
+	}
+	clock.Advance(SnapshotPollInterval)
+	select {
+	case r := <-done:
+		if r.err != nil || !strings.Contains(r.output, "frame one..........") || !r.settled {
+			t.Errorf("snapshot = %q, settled %v, %v", r.output, r.settled, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("snapshot did not settle after 100ms of quiet")
+	}
+}
+
+func TestSnapshotDeadlineFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithClock(clock), WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("busy", CreateOptions{Command: "sleep 60", TUIMode: true}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("busy")
+	term := fake.terminal(t)
+	term.Write([]byte("frame"))
+	waitForOutput(t, client, "busy", "frame")
+
+	type result struct {
+		snap *SnapshotResult
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		snap, err := client.SnapshotDetailed("busy", 100, 1, 0, 0, false)
+		done <- result{snap, err}
+	}()
+
+	// The resize jiggle, then output every poll until the 1s timeout.
+	clock.waitForSleepers(t, 1)
+	clock.Advance(SnapshotResizePause)
+	polls := int(time.Second / SnapshotPollInterval)
+	for i := 0; i < polls; i++ {
+		clock.waitForSleepers(t, 1)
+		term.Write([]byte("."))
+		waitForOutput(t, client, "busy", "frame"+strings.Repeat(".", i+1))
+		clock.Advance(SnapshotPollInterval)
+	}
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatalf("snapshot: %v", r.err)
+		}
+		want := SnapshotResult{
+			Output:        "frame" + strings.Repeat(".", polls),
+			Position:      r.snap.Position,
+			Settled:       false,
+			WaitedMs:      (SnapshotResizePause + time.Second).Milliseconds(),
+			ResizeCycles:  1,
+			BytesCaptured: int64(polls),
+		}
+		if *r.snap != want {
+			t.Errorf("snapshot = %+v, want %+v", *r.snap, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("snapshot did not return at its timeout")
+	}
+}
+
+func TestStopKillGraceFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithClock(clock), WithPTYDriver(fake))
+	defer cleanup()
+
+	ready := filepath.Join(t.TempDir(), "ready")
+	script := "trap '' TERM; touch " + ready + "; while :; do sleep 0.1; done"
+	if _, err := client.Create("stubborn", CreateOptions{Command: script}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("stubborn")
+	fake.terminal(t)
+	info, err := client.Info("stubborn")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(ready); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the program did not start")
+		}
+	}
+
+	if err := client.Stop("stubborn"); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	clock.waitForSleepers(t, 1)
+	time.Sleep(200 * time.Millisecond)
+	if err := syscall.Kill(info.PID, 0); err != nil {
+		t.Fatalf("the program ignoring SIGTERM should live until the grace period ends: %v", err)
+	}
+
+	clock.Advance(KillGracePeriod)
+	for deadline := time.Now().Add(5 * time.Second); syscall.Kill(info.PID, 0) == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the program should be killed after the grace period")
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package daemon
+
+import "sync"
+
+// outputSubscriber consumes a piece of a session's output. Subscribers run
+// on the capture goroutine, one after another, so they must not block.
+type outputSubscriber func(text []byte)
+
+// outputHub is a session's output fan-out: capture publishes each piece of
+// output once and the hub hands it to every consumer. While the session
+// runs, captureOutput subscribes storage (the session's screen or output
+// buffer), the event bus (Server.Subscribe, wait_any) and the bookmark
+// watches, in that order, so the later ones find the output stored.
+//
+// Stream and attach clients cannot keep up with capture and must not slow
+// it down, so they listen instead: after each publish they get a wakeup
+// and read the new output from storage at their own pace.
+type outputHub struct {
+	mu        sync.Mutex
+	subs      []*outputSubscription
+	listeners map[chan struct{}]struct{}
+}
+
+type outputSubscription struct {
+	fn outputSubscriber
+}
+
+// subscribe adds fn to the hub's consumers until the returned func is
+// called.
+func (hub *outputHub) subscribe(fn outputSubscriber) (cancel func()) {
+	sub := &outputSubscription{fn: fn}
+	hub.mu.Lock()
+	hub.subs = append(hub.subs, sub)
+	hub.mu.Unlock()
+	return func() {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		for i, s := range hub.subs {
+			if s == sub {
+				hub.subs = append(hub.subs[:i:i], hub.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// listen returns a channel that receives a value after output was
+// published. Wakeups coalesce: however much output arrives before the
+// listener gets to it, one value is waiting. The returned func stops
+// listening.
+func (hub *outputHub) listen() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	hub.mu.Lock()
+	if hub.listeners == nil {
+		hub.listeners = make(map[chan struct{}]struct{})
+	}
+	hub.listeners[ch] = struct{}{}
+	hub.mu.Unlock()
+	return ch, func() {
+		hub.mu.Lock()
+		delete(hub.listeners, ch)
+		hub.mu.Unlock()
+	}
+}
+
+// publish hands text to every consumer, then wakes the listeners.
+func (hub *outputHub) publish(text []byte) {
+	hub.mu.Lock()
+	subs := hub.subs
+	hub.mu.Unlock()
+	for _, sub := range subs {
+		sub.fn(text)
+	}
+	hub.wake()
+}
+
+// wake wakes the listeners without publishing, for output stored outside
+// the capture loop (mirrored input).
+func (hub *outputHub) wake() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for ch := range hub.listeners {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputHubPublishesInOrder(t *testing.T) {
+	var hub outputHub
+	var got []string
+	cancelA := hub.subscribe(func(text []byte) { got = append(got, "a:"+string(text)) })
+	hub.subscribe(func(text []byte) { got = append(got, "b:"+string(text)) })
+
+	hub.publish([]byte("1"))
+	cancelA()
+	hub.publish([]byte("2"))
+
+	if want := "a:1 b:1 b:2"; strings.Join(got, " ") != want {
+		t.Errorf("deliveries = %v, want %s", got, want)
+	}
+}
+
+func TestOutputHubListenCoalesces(t *testing.T) {
+	var hub outputHub
+	wake, stop := hub.listen()
+
+	hub.publish([]byte("x"))
+	hub.publish([]byte("y"))
+	select {
+	case <-wake:
+	default:
+		t.Fatal("no wakeup after publish")
+	}
+	select {
+	case <-wake:
+		t.Fatal("wakeups did not coalesce")
+	default:
+	}
+
+	stop()
+	hub.publish([]byte("z"))
+	select {
+	case <-wake:
+		t.Fatal("woken after stop")
+	default:
+	}
+}
+
+func TestStreamWokenByMirroredInput(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("fan-mirror", CreateOptions{Command: "stty -echo; cat >/dev/null", MirrorInput: true}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("fan-mirror")
+
+	chunks := make(chan StreamChunk, 16)
+	go client.Stream(t.Context(), "fan-mirror", "", func(c StreamChunk) error {
+		chunks <- c
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	if err := client.Send("fan-mirror", "mirrored-line", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	// cat prints nothing, so only the mirrored input can wake the stream
+	// before its one-second poll.
+	deadline := time.After(streamPoll / 2)
+	for {
+		select {
+		case c := <-chunks:
+			if strings.Contains(c.Output, "mirrored-line") {
+				return
+			}
+		case <-deadline:
+			t.Fatal("stream not woken by mirrored input")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Smallest size fit shrinks a session to.
+const (
+	fitMinCols = 20
+	fitMinRows = 2
+)
+
+// FitResult reports a fit: the content found on screen and the size the
+// session went from and to.
+type FitResult struct {
+	UsedCols int `json:"used_cols"`
+	UsedRows int `json:"used_rows"`
+	FromCols int `json:"from_cols"`
+	FromRows int `json:"from_rows"`
+	Cols     int `json:"cols"`
+	Rows     int `json:"rows"`
+}
+
+// contentExtent returns how many columns and rows the non-blank text of a
+// plain screen dump takes up.
+func contentExtent(text string) (cols, rows int) {
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " ")
+		if line == "" {
+			continue
+		}
+		cols = max(cols, ansi.StringWidth(line))
+		rows = i + 1
+	}
+	return cols, rows
+}
+
+// fitSize is the size for content of the given extent: never larger than
+// the current size or the positive bounds, never below the minimum.
+func fitSize(used, current, bound, floor int) int {
+	size := min(max(used, floor), current)
+	if bound > 0 {
+		size = min(size, bound)
+	}
+	return size
+}
+
+// handleFit shrinks a TUI session's terminal to the rows and columns its
+// screen uses, so snapshots carry no blank padding. req.Cols and req.Rows,
+// when set, cap the result further.
+func (s *Server) handleFit(req Request) Response {
+	if req.Cols < 0 || req.Rows < 0 {
+		return Response{Success: false, Error: "cols and rows must not be negative"}
+	}
+
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	screen := h.screen
+	storage := s.storage
+	s.mu.Unlock()
+
+	if screen == nil {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is not in TUI mode (fit needs a rendered screen)", req.Name)}
+	}
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+
+	result := FitResult{FromCols: meta.Cols, FromRows: meta.Rows}
+	result.UsedCols, result.UsedRows = contentExtent(screen.String())
+	result.Cols = fitSize(result.UsedCols, meta.Cols, req.Cols, fitMinCols)
+	result.Rows = fitSize(result.UsedRows, meta.Rows, req.Rows, fitMinRows)
+
+	if result.Cols != meta.Cols || result.Rows != meta.Rows {
+		resp := s.handleResize(Request{Name: req.Name, Cols: result.Cols, Rows: result.Rows})
+		if !resp.Success {
+			return resp
+		}
+	}
+	return Response{Success: true, Data: result}
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentExtent(t *testing.T) {
+	tests := []struct {
+		text       string
+		cols, rows int
+	}{
+		{"", 0, 0},
+		{"$ ls", 4, 1},
+		{"a\n\nlonger line   \n\n", 11, 3},
+		{"日本語\nx", 6, 2},
+	}
+	for _, tt := range tests {
+		cols, rows := contentExtent(tt.text)
+		if cols != tt.cols || rows != tt.rows {
+			t.Errorf("contentExtent(%q) = %dx%d, want %dx%d", tt.text, cols, rows, tt.cols, tt.rows)
+		}
+	}
+}
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		used, current, bound, floor int
+		want                        int
+	}{
+		{used: 30, current: 80, floor: 20, want: 30},
+		{used: 5, current: 80, floor: 20, want: 20},
+		{used: 30, current: 80, bound: 25, floor: 20, want: 25},
+		{used: 100, current: 80, floor: 20, want: 80},
+		{used: 5, current: 10, floor: 20, want: 10},
+	}
+	for _, tt := range tests {
+		if got := fitSize(tt.used, tt.current, tt.bound, tt.floor); got != tt.want {
+			t.Errorf("fitSize(%d, %d, %d, %d) = %d, want %d", tt.used, tt.current, tt.bound, tt.floor, got, tt.want)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("tui", CreateOptions{Command: "sh", TUIMode: true}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("tui")
+
+	// Clear the screen so only the marker line and the prompt are on it.
+	if err := client.Send("tui", `printf '\033[H\033[2J'; echo fit-$((20+22))-xxxxxxxxxxxxxxxxxxxxxxxxx`, true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out, _, err := client.Read("tui", ReadModeAll, 0, 0)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if strings.Contains(out, "fit-42-") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for output, got %q", out)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	result, err := client.Fit("tui", 0, 0)
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if result.FromCols != 80 || result.FromRows != 24 {
+		t.Errorf("from = %dx%d, want 80x24", result.FromCols, result.FromRows)
+	}
+	if result.UsedCols != len("fit-42-xxxxxxxxxxxxxxxxxxxxxxxxx") || result.Cols != result.UsedCols {
+		t.Errorf("cols = %d (used %d), want the marker's width", result.Cols, result.UsedCols)
+	}
+	if result.Rows != fitMinRows {
+		t.Errorf("rows = %d (used %d), want %d", result.Rows, result.UsedRows, fitMinRows)
+	}
+
+	info, err := client.Info("tui")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.Cols != result.Cols || info.Rows != result.Rows {
+		t.Errorf("session is %dx%d, want %dx%d", info.Cols, info.Rows, result.Cols, result.Rows)
+	}
+
+	if _, err := client.Create("plain", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("plain")
+	if _, err := client.Fit("plain", 0, 0); err == nil {
+		t.Error("Fit on a non-TUI session should fail")
+	}
+}
@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// sessionFreeze tracks the process groups a freeze stopped. Unlike a capture
+// pause, a freeze halts the program itself. Guarded by Server.mu.
+type sessionFreeze struct {
+	groups []int // in the order they were stopped; nil when not frozen
+	since  time.Time
+}
+
+func (f *sessionFreeze) frozen() bool {
+	return len(f.groups) > 0
+}
+
+// thaw continues the frozen groups, last stopped first, and clears the
+// freeze. Groups that are gone are skipped.
+func (f *sessionFreeze) thaw() {
+	for i := len(f.groups) - 1; i >= 0; i-- {
+		syscall.Kill(-f.groups[i], syscall.SIGCONT)
+	}
+	f.groups = nil
+}
+
+// freezeInfo adds the freeze state to an info result. Callers hold Server.mu.
+func (h *sessionHandle) freezeInfo(result map[string]interface{}) {
+	if h.freeze.frozen() {
+		result["frozen"] = true
+		result["frozen_since"] = h.freeze.since.Format(time.RFC3339)
+	}
+}
+
+// FreezeResult reports a freeze or thaw.
+type FreezeResult struct {
+	Name string `json:"name"`
+	// Groups are the process groups stopped or continued: the session's
+	// own and, when a job is in the foreground, the job's.
+	Groups    []int   `json:"process_groups"`
+	FrozenFor float64 `json:"frozen_seconds,omitempty"`
+}
+
+// handleFreeze sends SIGSTOP to the session's process group and to the
+// PTY's foreground group. The session's own group goes first, so a shell is
+// already stopped when its job stops and cannot take the job out of the
+// foreground.
+func (s *Server) handleFreeze(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if h.state != StateRunning || h.pty == nil {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is stopped", req.Name)}
+	}
+	if h.freeze.frozen() {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is already frozen", req.Name)}
+	}
+
+	groups := []int{h.pid}
+	if fg, err := foregroundGroup(h.pty.File()); err == nil && fg > 0 && fg != h.pid {
+		groups = append(groups, fg)
+	}
+	for i, g := range groups {
+		if err := syscall.Kill(-g, syscall.SIGSTOP); err != nil {
+			if i == 0 {
+				return Response{Success: false, Error: fmt.Sprintf("freeze session %q: %v", req.Name, err)}
+			}
+			groups = groups[:i] // the job finished in the meantime
+			break
+		}
+	}
+
+	h.freeze.groups = groups
+	h.freeze.since = time.Now()
+	return Response{Success: true, Data: FreezeResult{Name: req.Name, Groups: groups}}
+}
+
+// handleThaw sends SIGCONT to the groups a freeze stopped.
+func (s *Server) handleThaw(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if !h.freeze.frozen() {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is not frozen", req.Name)}
+	}
+
+	result := FreezeResult{
+		Name:      req.Name,
+		Groups:    h.freeze.groups,
+		FrozenFor: time.Since(h.freeze.since).Seconds(),
+	}
+	h.freeze.thaw()
+	return Response{Success: true, Data: result}
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFreezeThaw(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("freeze", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("freeze")
+
+	if err := client.Send("freeze", "i=0; while true; do i=$((i+1)); echo tick-$i; sleep 0.05; done", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "freeze", "tick-3")
+
+	result, err := client.Freeze("freeze")
+	if err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	if len(result.Groups) == 0 {
+		t.Error("Freeze reported no process groups")
+	}
+	if _, err := client.Freeze("freeze"); err == nil {
+		t.Error("Freeze of a frozen session should fail")
+	}
+	if err := client.Send("freeze", "x", false); err == nil || !strings.Contains(err.Error(), "frozen") {
+		t.Errorf("Send to a frozen session: err = %v, want frozen", err)
+	}
+
+	// Let output already in flight land, then check nothing new comes.
+	time.Sleep(200 * time.Millisecond)
+	before, _, _ := client.Read("freeze", ReadModeAll, 0, 0)
+	time.Sleep(300 * time.Millisecond)
+	after, _, _ := client.Read("freeze", ReadModeAll, 0, 0)
+	if after != before {
+		t.Errorf("output grew while frozen: %q", strings.TrimPrefix(after, before))
+	}
+
+	info, err := client.Info("freeze")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if !info.Frozen || info.Health == nil || info.Health.Status != HealthSuspended {
+		t.Errorf("info frozen = %v, health = %+v", info.Frozen, info.Health)
+	}
+	sessions, err := client.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(sessions) != 1 || !sessions[0].Frozen {
+		t.Errorf("List = %+v, want the session frozen", sessions)
+	}
+
+	if _, err := client.Thaw("freeze"); err != nil {
+		t.Fatalf("Thaw: %v", err)
+	}
+	if _, err := client.Thaw("freeze"); err == nil {
+		t.Error("Thaw of a running session should fail")
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		out, _, _ := client.Read("freeze", ReadModeAll, 0, 0)
+		if len(out) > len(after) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no output after thaw")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestFreezeThenStop(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("freeze-stop", CreateOptions{Command: "sleep 30"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("freeze-stop")
+
+	if _, err := client.Freeze("freeze-stop"); err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	if err := client.Stop("freeze-stop"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	info, err := client.Info("freeze-stop")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.Frozen {
+		t.Error("stopped session still reported frozen")
+	}
+}
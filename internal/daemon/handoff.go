@@ -0,0 +1,276 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// HandoffEnv names the handoff file a restarting daemon passes to its
+// successor. The daemon command reads it at startup (see WithHandoff) and
+// unsets it, so sessions created later do not inherit it.
+const HandoffEnv = "SHELLI_HANDOFF"
+
+// handoffVersion is bumped when handoffState changes incompatibly.
+const handoffVersion = 1
+
+// handoffState is what a restarting daemon passes to the new one besides
+// storage: the inherited file descriptors of its socket and of each running
+// session's PTY, and the process behind each.
+type handoffState struct {
+	Version    int              `json:"version"`
+	ListenerFD int              `json:"listener_fd"`
+	Sessions   []handoffSession `json:"sessions"`
+}
+
+type handoffSession struct {
+	Name string   `json:"name"`
+	PID  int      `json:"pid"`
+	FD   int      `json:"fd"`
+	Cwd  string   `json:"cwd,omitempty"`
+	Env  []string `json:"env,omitempty"`
+}
+
+// WithHandoff takes over the socket and running sessions of the daemon that
+// restarted into this one, from the handoff file at path. An empty path does
+// nothing.
+func WithHandoff(path string) ServerOption {
+	return func(s *Server) {
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path) // #nosec G304 -- written by the previous daemon
+		os.Remove(path)
+		var state handoffState
+		if err == nil {
+			err = json.Unmarshal(data, &state)
+		}
+		if err == nil && state.Version != handoffVersion {
+			err = fmt.Errorf("handoff version %d, want %d", state.Version, handoffVersion)
+		}
+		if err != nil {
+			log.Printf("handoff: %v; running sessions are lost", err)
+			return
+		}
+		s.handoff = &state
+	}
+}
+
+// handleRestart replaces the daemon with a fresh copy of its executable
+// (which may have been upgraded in place), keeping the process ID: the
+// socket and the PTYs of running sessions are inherited across exec, and
+// their processes stay children of the daemon, so the new one reads their
+// output and reaps them as if nothing happened.
+//
+// The daemon stops accepting connections (they queue on the socket for the
+// new daemon), waits up to RestartDrainTimeout for requests in progress,
+// and replies before exec. Streams (read --follow, attach, events) are cut;
+// in-memory extras (images, notifications, jobs, exec progress) and the
+// emulator screens of TUI sessions start over, so take a fresh snapshot.
+// Needs the file backend, since memory-backed output would be lost.
+func (s *Server) handleRestart(conn net.Conn) {
+	fail := func(err error) {
+		s.sendResponse(conn, Response{Success: false, Error: "restart: " + err.Error()})
+	}
+	if _, ok := s.storage.(*FileStorage); !ok {
+		fail(fmt.Errorf("needs the file storage backend (output in memory would be lost)"))
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fail(err)
+		return
+	}
+	if info, err := os.Stat(exe); err != nil || info.Mode()&0111 == 0 {
+		fail(fmt.Errorf("executable %s not found or not executable", exe))
+		return
+	}
+	s.mu.Lock()
+	listener, ok := s.listener.(*net.UnixListener)
+	s.mu.Unlock()
+	if !ok {
+		fail(fmt.Errorf("socket listener not available"))
+		return
+	}
+	lf, err := listener.File()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	s.mu.Lock()
+	if s.restarting {
+		s.mu.Unlock()
+		lf.Close()
+		fail(fmt.Errorf("already in progress"))
+		return
+	}
+	s.restarting = true
+	s.resumeAccept = make(chan struct{})
+	s.mu.Unlock()
+	listener.SetDeadline(time.Now()) // ends Accept; see Start
+
+	deadline := time.Now().Add(RestartDrainTimeout)
+	for s.inflight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(DaemonPollInterval)
+	}
+
+	// Output being stored finishes first; capture reads completing from
+	// here on are left for the new daemon to be lost with the exec, which
+	// is only the instant it takes.
+	s.captureGate.Lock()
+	s.mu.Lock()
+	state := handoffState{Version: handoffVersion, ListenerFD: int(lf.Fd())}
+	for name, h := range s.handles {
+		if h.state != StateRunning || h.pty == nil || h.cmd == nil || h.cmd.Process == nil {
+			continue
+		}
+		state.Sessions = append(state.Sessions, handoffSession{
+			Name: name,
+			PID:  h.cmd.Process.Pid,
+			FD:   int(h.pty.File().Fd()),
+			Cwd:  h.cwd,
+			Env:  h.env,
+		})
+	}
+	path := filepath.Join(s.socketDir, "handoff.json")
+	err = writeHandoff(path, state)
+	if err == nil {
+		err = inheritFDs(state, true)
+	}
+	if err != nil {
+		inheritFDs(state, false) //nolint:errcheck // best effort on the way back
+		os.Remove(path)
+		s.abortRestart(listener, lf)
+		fail(err)
+		return
+	}
+
+	s.storage.(*FileStorage).mu.Lock() // no write is cut short by the exec
+	s.sendResponse(conn, Response{Success: true, Data: map[string]interface{}{
+		"pid":      os.Getpid(),
+		"sessions": len(state.Sessions),
+	}})
+	conn.Close()
+	log.Printf("restarting: handing %d running sessions to %s", len(state.Sessions), exe)
+
+	env := append(os.Environ(), HandoffEnv+"="+path)
+	err = syscall.Exec(exe, os.Args, env) // #nosec G204 -- our own executable
+	// Still here: the exec failed, carry on as before.
+	s.storage.(*FileStorage).mu.Unlock()
+	log.Printf("restart: exec %s: %v", exe, err)
+	inheritFDs(state, false) //nolint:errcheck // best effort on the way back
+	os.Remove(path)
+	s.abortRestart(listener, lf)
+}
+
+// abortRestart undoes a restart that did not happen: called with s.mu and
+// the capture gate held, it releases them and resumes accepting.
+func (s *Server) abortRestart(listener *net.UnixListener, lf *os.File) {
+	lf.Close()
+	listener.SetDeadline(time.Time{})
+	s.restarting = false
+	close(s.resumeAccept)
+	s.mu.Unlock()
+	s.captureGate.Unlock()
+}
+
+func writeHandoff(path string, state handoffState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// inheritFDs clears (inherit) or sets close-on-exec on the handed-off file
+// descriptors.
+func inheritFDs(state handoffState, inherit bool) error {
+	fds := []int{state.ListenerFD}
+	for _, hs := range state.Sessions {
+		fds = append(fds, hs.FD)
+	}
+	for _, fd := range fds {
+		flag := uintptr(syscall.FD_CLOEXEC)
+		if inherit {
+			flag = 0
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_SETFD, flag); errno != 0 {
+			return fmt.Errorf("fcntl fd %d: %w", fd, errno)
+		}
+	}
+	return nil
+}
+
+// handoffListener returns the socket listener inherited from the previous
+// daemon, if any.
+func (s *Server) handoffListener() (net.Listener, error) {
+	if s.handoff == nil {
+		return nil, nil
+	}
+	f := os.NewFile(uintptr(s.handoff.ListenerFD), "shelli.sock")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// adoptSession takes over a session running under the previous daemon: its
+// PTY from the inherited descriptor and its process, still a child of this
+// one. Called from recoverSessions with meta as stored.
+func (s *Server) adoptSession(meta *SessionMeta, hs handoffSession) (*sessionHandle, error) {
+	syscall.CloseOnExec(hs.FD)
+	ptmx := os.NewFile(uintptr(hs.FD), "/dev/ptmx")
+	proc, err := os.FindProcess(hs.PID)
+	if err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+
+	h := &sessionHandle{
+		name:        meta.Name,
+		pid:         hs.PID,
+		command:     meta.Command,
+		state:       StateRunning,
+		createdAt:   meta.CreatedAt,
+		pty:         &ptyHandle{f: ptmx},
+		cmd:         &exec.Cmd{Process: proc},
+		done:        make(chan struct{}),
+		exited:      make(chan struct{}),
+		workspace:   meta.Workspace,
+		cwd:         hs.Cwd,
+		env:         hs.Env,
+		cgroup:      limitsCgroup(meta.Limits),
+		mirrorInput: meta.MirrorInput,
+		onExit:      meta.OnExit,
+
+		capturePriority: meta.CapturePriority,
+		snapshotMode:    meta.SnapshotMode,
+		resizeCrash:     meta.ResizeCrash,
+	}
+	if meta.Encoding != "" {
+		if h.charset, err = lookupEncoding(meta.Encoding); err != nil {
+			log.Printf("handoff[%s]: %v", meta.Name, err)
+		}
+	}
+	h.clock.noteOutput() // uptime falls back to created_at
+	h.bookmarks.set(meta.BookmarkWatches)
+	if meta.TUIMode {
+		h.screen = vterm.New(meta.Cols, meta.Rows)
+		h.screen.SetFrameHistory(meta.FrameHistory)
+		h.screen.SetScrollback(meta.Scrollback)
+		h.screen.SetFrameBoundaries(meta.FrameBoundaries)
+		go h.screen.ReadResponses(ptmx)
+		// The new emulator starts blank; ask the program for a redraw.
+		if meta.SnapshotMode != SnapshotModePassive {
+			s.ptys.NotifyResize(proc)
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// handoffHelperEnv makes TestHandoffHelper run a daemon in the test binary,
+// with its socket in the directory it names, so a restart can exec it.
+const handoffHelperEnv = "SHELLI_TEST_HANDOFF_DIR"
+
+func TestHandoffHelper(t *testing.T) {
+	dir := os.Getenv(handoffHelperEnv)
+	if dir == "" {
+		t.Skip("helper process for TestRestartKeepsSessions")
+	}
+	storage, err := NewFileStorage(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := NewServer(WithStorage(storage), WithSocketDir(dir), WithHandoff(os.Getenv(HandoffEnv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestartKeepsSessions(t *testing.T) {
+	dir := t.TempDir()
+	helper := exec.Command(os.Args[0], "-test.run=^TestHandoffHelper$")
+	helper.Env = append(os.Environ(), handoffHelperEnv+"="+dir)
+	if err := helper.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		helper.Process.Signal(syscall.SIGTERM)
+		helper.Wait()
+	}()
+
+	client := NewClientWithSocketPath(filepath.Join(dir, "shelli.sock"))
+	deadline := time.Now().Add(5 * time.Second)
+	for !client.Ping() {
+		if time.Now().After(deadline) {
+			t.Fatal("helper daemon did not start in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := client.Create("keep", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("keep")
+	if err := client.Send("keep", "echo before-$((1+1))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "keep", "before-2")
+	before, err := client.Info("keep")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+
+	result, err := client.Restart()
+	if err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	if result.PID != helper.Process.Pid || result.Sessions != 1 {
+		t.Errorf("Restart = %+v, want pid %d and 1 session", result, helper.Process.Pid)
+	}
+
+	info, err := client.Info("keep")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.State != string(StateRunning) || info.PID != before.PID {
+		t.Errorf("after restart: state %s pid %d, want running pid %d", info.State, info.PID, before.PID)
+	}
+	if err := client.Send("keep", "echo after-$((2+2))", true); err != nil {
+		t.Fatalf("Send after restart: %v", err)
+	}
+	waitForOutput(t, client, "keep", "after-4")
+	output, _, err := client.Read("keep", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !strings.Contains(output, "before-2") {
+		t.Errorf("output from before the restart is gone: %q", output)
+	}
+}
+
+func TestRestartNeedsFileStorage(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	_, err := client.Restart()
+	if err == nil || !strings.Contains(err.Error(), "file storage") {
+		t.Errorf("Restart with memory storage = %v, want file storage error", err)
+	}
+}
@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+)
+
+// Health statuses, from best to worst.
+const (
+	HealthOK        = "ok"
+	HealthSuspended = "suspended" // stopped by SIGSTOP/SIGTSTP or a tracer
+	HealthZombie    = "zombie"    // exited, not yet reaped
+	HealthPTYError  = "pty_error" // the PTY no longer accepts writes
+	HealthExited    = "exited"
+)
+
+// healthRecentOutput is how recent output must be to count as a sign of
+// life on its own.
+const healthRecentOutput = 30 * time.Second
+
+// Health is a liveness check of a session's child and PTY. list reports
+// the session as running as long as the daemon has not seen the child exit;
+// Health looks closer.
+type Health struct {
+	Status string `json:"status"`
+	// ProcessState is the kernel's state letter for the child (R running,
+	// S sleeping, D disk wait, T stopped, Z zombie, ...); empty when it
+	// cannot be read.
+	ProcessState string `json:"process_state,omitempty"`
+	// PTYOK is set when a zero-byte write to the PTY and reading its
+	// terminal settings both succeed.
+	PTYOK bool `json:"pty_ok"`
+	// Responsive is set when the child is neither stopped nor a zombie and
+	// has produced output recently or its PTY accepts writes.
+	Responsive  bool    `json:"responsive"`
+	IdleSeconds float64 `json:"idle_seconds,omitempty"`
+	Detail      string  `json:"detail,omitempty"`
+}
+
+// checkHealth probes a session. It reads the handle under Server.mu and does
+// the probing without it.
+func (s *Server) checkHealth(name string) (*Health, error) {
+	s.mu.Lock()
+	h, exists := s.handles[name]
+	if !exists {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("session %q not found", name)
+	}
+	state, pid, p := h.state, h.pid, h.pty
+	var idle time.Duration
+	tracked := h.clock.started != 0 // sessions recovered from storage have no output clock
+	if tracked {
+		idle = monoNow() - time.Duration(h.clock.lastOutput.Load())
+	}
+	s.mu.Unlock()
+
+	if state != StateRunning {
+		return &Health{Status: HealthExited}, nil
+	}
+
+	health := &Health{ProcessState: processState(pid), IdleSeconds: idle.Seconds()}
+	if p != nil {
+		_, writeErr := p.File().Write(nil)
+		_, termErr := canonicalMode(p.File())
+		switch {
+		case writeErr != nil:
+			health.Detail = fmt.Sprintf("pty write: %v", writeErr)
+		case termErr != nil:
+			health.Detail = fmt.Sprintf("pty settings: %v", termErr)
+		default:
+			health.PTYOK = true
+		}
+	}
+
+	switch health.ProcessState {
+	case "Z", "X":
+		health.Status = HealthZombie
+	case "T", "t":
+		health.Status = HealthSuspended
+	default:
+		if health.PTYOK {
+			health.Status = HealthOK
+		} else {
+			health.Status = HealthPTYError
+		}
+		recent := tracked && idle < healthRecentOutput
+		health.Responsive = recent || health.PTYOK
+	}
+	return health, nil
+}
+
+func (s *Server) handleHealth(req Request) Response {
+	health, err := s.checkHealth(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	return Response{Success: true, Data: health}
+}
@@ -0,0 +1,36 @@
+package daemon
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processState returns the state letter of pid from /proc/<pid>/stat, or ""
+// if the process is gone.
+func processState(pid int) string {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return ""
+	}
+	// The command name is parenthesised and may contain spaces.
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return ""
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// processCwd returns the working directory pid is in now, or "" if it
+// cannot be told.
+func processCwd(pid int) string {
+	dir, err := os.Readlink("/proc/" + strconv.Itoa(pid) + "/cwd")
+	if err != nil {
+		return ""
+	}
+	return dir
+}
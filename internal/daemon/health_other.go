@@ -0,0 +1,38 @@
+//go:build !linux
+
+package daemon
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// processState returns the state letter of pid as ps reports it, or "" if
+// the process is gone.
+func processState(pid int) string {
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output() // #nosec G204 -- pid is an integer
+	if err != nil {
+		return ""
+	}
+	state := strings.TrimSpace(string(out))
+	if state == "" {
+		return ""
+	}
+	return state[:1]
+}
+
+// processCwd returns the working directory pid is in now as lsof reports
+// it, or "" if it cannot be told.
+func processCwd(pid int) string {
+	out, err := exec.Command("lsof", "-a", "-p", strconv.Itoa(pid), "-d", "cwd", "-Fn").Output() // #nosec G204 -- pid is an integer
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "n") {
+			return line[1:]
+		}
+	}
+	return ""
+}
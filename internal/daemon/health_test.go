@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	info, err := client.Create("probe", CreateOptions{Command: "sh"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	pid := int(info["pid"].(float64))
+	defer client.Kill("probe")
+
+	waitHealth := func(want string) *Health {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			health, err := client.Health("probe")
+			if err != nil {
+				t.Fatalf("health: %v", err)
+			}
+			if health.Status == want {
+				return health
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("status = %q, want %q (%+v)", health.Status, want, health)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	health := waitHealth(HealthOK)
+	if !health.PTYOK || !health.Responsive {
+		t.Errorf("healthy session: %+v", health)
+	}
+
+	if runtime.GOOS == "linux" {
+		if err := syscall.Kill(pid, syscall.SIGSTOP); err != nil {
+			t.Fatalf("SIGSTOP: %v", err)
+		}
+		health = waitHealth(HealthSuspended)
+		if health.ProcessState != "T" || health.Responsive {
+			t.Errorf("suspended session: %+v", health)
+		}
+		sessions, err := client.ListVerbose()
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(sessions) != 1 || sessions[0].Health == nil || sessions[0].Health.Status != HealthSuspended {
+			t.Errorf("list --verbose = %+v", sessions)
+		}
+		if err := syscall.Kill(pid, syscall.SIGCONT); err != nil {
+			t.Fatalf("SIGCONT: %v", err)
+		}
+		waitHealth(HealthOK)
+	}
+
+	if sessions, err := client.List(); err != nil || len(sessions) != 1 || sessions[0].Health != nil {
+		t.Errorf("plain list carries health: %+v, %v", sessions, err)
+	}
+
+	if err := client.Stop("probe"); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	waitHealth(HealthExited)
+
+	if _, err := client.Health("missing"); err == nil {
+		t.Error("health of a missing session should fail")
+	}
+}
@@ -0,0 +1,200 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// Hook events. Pre hooks run before the action and block it by exiting
+// non-zero; post hooks run in the background once it is done. exec goes
+// through send, so send hooks fire for it too.
+const (
+	HookPreCreate  = "pre-create"
+	HookPostCreate = "post-create"
+	HookPreSend    = "pre-send"
+	HookPostSend   = "post-send"
+	HookPreStop    = "pre-stop"
+	HookPostStop   = "post-stop" // also when the process exits on its own
+	// HookExit runs in the background once the session's process has
+	// exited and its status is known, after stop too, but not when the
+	// session is killed or the daemon shuts down. Sessions add their own
+	// with create --on-exit.
+	HookExit = "exit"
+)
+
+var hookEvents = []string{
+	HookPreCreate, HookPostCreate,
+	HookPreSend, HookPostSend,
+	HookPreStop, HookPostStop,
+	HookExit,
+}
+
+// Hooks maps an event to the shell commands run for it, in order.
+type Hooks map[string][]string
+
+// ParseHook parses an "event=command" hook spec.
+func ParseHook(spec string) (event, command string, err error) {
+	event, command, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(command) == "" {
+		return "", "", fmt.Errorf("hook %q: want event=command", spec)
+	}
+	if !slices.Contains(hookEvents, event) {
+		return "", "", fmt.Errorf("hook %q: unknown event %q (valid: %s)", spec, event, strings.Join(hookEvents, ", "))
+	}
+	return event, command, nil
+}
+
+// hookContext describes the session a hook runs for. It is passed to the
+// hook as SHELLI_* environment variables.
+type hookContext struct {
+	session   string
+	state     SessionState
+	command   string
+	pid       int
+	workspace string
+	input     string // send hooks only
+
+	// exit hooks only
+	endReason  string
+	exitCode   *int
+	exitSignal string
+	output     string // the last HookOutputLines lines, ANSI stripped
+}
+
+// hookContext describes h for a hook. The caller holds s.mu.
+func (h *sessionHandle) hookContext() hookContext {
+	return hookContext{
+		session:   h.name,
+		state:     h.state,
+		command:   h.command,
+		pid:       h.pid,
+		workspace: h.workspace,
+	}
+}
+
+func (hc hookContext) env(event string) []string {
+	env := []string{
+		"SHELLI_HOOK=" + event,
+		"SHELLI_SESSION=" + hc.session,
+		"SHELLI_STATE=" + string(hc.state),
+		"SHELLI_COMMAND=" + hc.command,
+	}
+	if hc.pid > 0 {
+		env = append(env, "SHELLI_PID="+strconv.Itoa(hc.pid))
+	}
+	if hc.workspace != "" {
+		env = append(env, "SHELLI_WORKSPACE="+hc.workspace)
+	}
+	if event == HookPreSend || event == HookPostSend {
+		env = append(env, "SHELLI_INPUT="+hc.input)
+	}
+	if event == HookExit {
+		env = append(env, "SHELLI_END_REASON="+hc.endReason, "SHELLI_OUTPUT="+hc.output)
+		if hc.exitCode != nil {
+			env = append(env, "SHELLI_EXIT_CODE="+strconv.Itoa(*hc.exitCode))
+		}
+		if hc.exitSignal != "" {
+			env = append(env, "SHELLI_EXIT_SIGNAL="+hc.exitSignal)
+		}
+	}
+	return env
+}
+
+// hooksFor returns the commands configured for event.
+func (s *Server) hooksFor(event string) []string {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.hooks[event]
+}
+
+// runPreHooks runs the hooks for event in order and returns an error naming
+// the first one that failed, with its output.
+func (s *Server) runPreHooks(event string, hc hookContext) error {
+	for _, command := range s.hooksFor(event) {
+		out, err := runHook(command, hc.env(event))
+		if err != nil {
+			msg := strings.TrimSpace(string(out))
+			if msg == "" {
+				msg = err.Error()
+			}
+			return fmt.Errorf("blocked by %s hook: %s", event, msg)
+		}
+	}
+	return nil
+}
+
+// runPostHooks starts the hooks for event in the background. Failures are
+// only logged.
+func (s *Server) runPostHooks(event string, hc hookContext) {
+	startHooks(event, s.hooksFor(event), hc)
+}
+
+// runExitHooks starts the daemon's exit hooks and the session's own for h,
+// whose process has exited. The caller holds s.mu.
+func (s *Server) runExitHooks(name string, h *sessionHandle) {
+	commands := append(slices.Clone(s.hooksFor(HookExit)), h.onExit...)
+	if len(commands) == 0 {
+		return
+	}
+	hc := h.hookContext()
+	hc.endReason, hc.exitCode, hc.exitSignal = h.endReason, h.exitCode, h.exitSignal
+	hc.output = s.outputTail(name, h, HookOutputLines)
+	startHooks(HookExit, commands, hc)
+}
+
+// outputTail returns the last lines of a session's output, ANSI stripped:
+// from its screen in TUI mode, else from the end of its buffer.
+func (s *Server) outputTail(name string, h *sessionHandle, lines int) string {
+	var text string
+	if h.screen != nil {
+		text = h.screen.String()
+	} else {
+		size, err := s.storage.Size(name)
+		if err != nil {
+			return ""
+		}
+		data, err := s.storage.ReadFrom(name, max(0, size-HookOutputBytes))
+		if err != nil {
+			return ""
+		}
+		text = NormalizeNewlines(vterm.StripDefault(string(data)), NewlinesDisplay)
+	}
+	return LimitLines(strings.TrimRight(text, "\n"), 0, lines)
+}
+
+// startHooks runs commands for event one after another in the background.
+func startHooks(event string, commands []string, hc hookContext) {
+	if len(commands) == 0 {
+		return
+	}
+	env := hc.env(event)
+	go func() {
+		for _, command := range commands {
+			if out, err := runHook(command, env); err != nil {
+				log.Printf("%s hook for %q: %v: %s", event, hc.session, err, bytes.TrimSpace(out))
+			}
+		}
+	}()
+}
+
+func runHook(command string, env []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command) // #nosec G204 -- hooks are configured by the daemon owner
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return out, fmt.Errorf("timed out after %s", HookTimeout)
+	}
+	return out, err
+}
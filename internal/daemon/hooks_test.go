@@ -0,0 +1,190 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseHook(t *testing.T) {
+	event, command, err := ParseHook("pre-create=test -n \"$SHELLI_SESSION\"")
+	if err != nil || event != HookPreCreate || command != `test -n "$SHELLI_SESSION"` {
+		t.Errorf("got %q, %q, %v", event, command, err)
+	}
+	for _, spec := range []string{"pre-create", "pre-create=", "on-boot=true"} {
+		if _, _, err := ParseHook(spec); err == nil {
+			t.Errorf("ParseHook(%q): expected error", spec)
+		}
+	}
+}
+
+func TestPreHooksBlock(t *testing.T) {
+	client, cleanup := setupTestServer(t, WithHooks(Hooks{
+		HookPreCreate: {`[ "$SHELLI_SESSION" != denied ] || { echo "name not allowed"; exit 1; }`},
+		HookPreSend:   {`case "$SHELLI_INPUT" in *forbidden*) echo "input rejected"; exit 1;; esac`},
+	}))
+	defer cleanup()
+
+	_, err := client.Create("denied", CreateOptions{Command: "sh"})
+	if err == nil || !strings.Contains(err.Error(), "name not allowed") {
+		t.Fatalf("Create denied: err = %v", err)
+	}
+	if _, err := client.Info("denied"); err == nil {
+		t.Fatal("blocked session was created")
+	}
+
+	if _, err := client.Create("allowed", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create allowed: %v", err)
+	}
+	defer client.Kill("allowed")
+
+	err = client.Send("allowed", "echo forbidden", true)
+	if err == nil || !strings.Contains(err.Error(), "blocked by pre-send hook: input rejected") {
+		t.Fatalf("Send forbidden: err = %v", err)
+	}
+	if err := client.Send("allowed", "echo fine", true); err != nil {
+		t.Fatalf("Send fine: %v", err)
+	}
+	waitForOutput(t, client, "allowed", "fine")
+
+	// A rejected send releases its idempotency key.
+	if _, err := client.SendOnce("allowed", "echo forbidden", true, "retry-1"); err == nil {
+		t.Fatal("SendOnce forbidden: expected error")
+	}
+	dup, err := client.SendOnce("allowed", "echo keyed", true, "retry-1")
+	if err != nil || dup {
+		t.Fatalf("SendOnce after rejection: duplicate = %v, err = %v", dup, err)
+	}
+	waitForOutput(t, client, "allowed", "keyed")
+}
+
+func TestPreSendHookClaimsKeyFirst(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "hooks.log")
+	client, cleanup := setupTestServer(t, WithHooks(Hooks{
+		HookPreSend: {`echo run >> ` + log + `; sleep 0.3`},
+	}))
+	defer cleanup()
+
+	if _, err := client.Create("slow-hook", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("slow-hook")
+
+	// A retry arriving while the first send's hook runs is a duplicate and
+	// does not run the hook again.
+	var wg sync.WaitGroup
+	dups := make([]bool, 2)
+	for i := range dups {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dup, err := client.SendOnce("slow-hook", "echo once", true, "concurrent")
+			if err != nil {
+				t.Errorf("SendOnce #%d: %v", i+1, err)
+			}
+			dups[i] = dup
+		}(i)
+	}
+	wg.Wait()
+	if dups[0] == dups[1] {
+		t.Errorf("duplicates = %v, want exactly one", dups)
+	}
+	if data, _ := os.ReadFile(log); string(data) != "run\n" {
+		t.Errorf("hook log = %q, want one run", data)
+	}
+}
+
+func TestPostHooks(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "hooks.log")
+	record := `echo "$SHELLI_HOOK $SHELLI_SESSION $SHELLI_STATE" >> ` + log
+	client, cleanup := setupTestServer(t, WithHooks(Hooks{
+		HookPostCreate: {record},
+		HookPostStop:   {record},
+	}))
+	defer cleanup()
+
+	if _, err := client.Create("stopped", CreateOptions{Command: "sleep 5"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("stopped")
+	if _, err := client.Create("exits", CreateOptions{Command: "true"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("exits")
+	if err := client.Stop("stopped"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	want := []string{
+		"post-create stopped running",
+		"post-create exits running",
+		"post-stop stopped stopped",
+		"post-stop exits stopped",
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(log)
+		missing := ""
+		for _, line := range want {
+			if !strings.Contains(string(data), line+"\n") {
+				missing = line
+				break
+			}
+		}
+		if missing == "" {
+			if n := strings.Count(string(data), "post-stop stopped"); n != 1 {
+				t.Errorf("post-stop for stopped session ran %d times", n)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hook log missing %q:\n%s", missing, data)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestExitHooks(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "exit.log")
+	client, cleanup := setupTestServer(t, WithHooks(Hooks{
+		HookExit: {`echo "daemon $SHELLI_SESSION $SHELLI_EXIT_CODE $SHELLI_END_REASON" >> ` + log},
+	}))
+	defer cleanup()
+
+	onExit := `printf '%s\n' "$SHELLI_OUTPUT" > ` + filepath.Join(dir, "$SHELLI_SESSION.out")
+	if _, err := client.Create("fails", CreateOptions{Command: "sh -c 'echo first; echo last; exit 3'", OnExit: []string{onExit}}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("fails")
+	if _, err := client.Create("killed", CreateOptions{Command: "sleep 5", OnExit: []string{onExit}}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := client.Kill("killed"); err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(log)
+		out, _ := os.ReadFile(filepath.Join(dir, "fails.out"))
+		if strings.Contains(string(data), "daemon fails 3 exited\n") && string(out) == "first\nlast\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hook log %q, fails.out %q", data, out)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if data, _ := os.ReadFile(log); strings.Contains(string(data), "killed") {
+		t.Errorf("exit hook ran for a killed session: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "killed.out")); err == nil {
+		t.Error("on-exit hook ran for a killed session")
+	}
+}
@@ -0,0 +1,145 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Idempotency keys let a caller retry send or exec without typing the input
+// twice: the daemon remembers recent keys per session, and a repeated key
+// returns the first call's result instead of running again.
+const (
+	IdempotencyTTL          = 10 * time.Minute // how long a key is remembered
+	IdempotencyMaxKeys      = 100              // keys kept per session; the oldest go first
+	IdempotencyKeyMaxLen    = 256
+	IdempotencyPollInterval = 100 * time.Millisecond // a retry waiting for the first call
+)
+
+// idempotentCall is a remembered key. Guarded by Server.mu.
+type idempotentCall struct {
+	at      time.Time
+	nonce   string // the claiming call, so its own retried claim is no duplicate
+	done    bool
+	outcome string          // exec outcome (ExecCompleted, ...) once done
+	failure string          // the exec's error, if any
+	result  json.RawMessage // the exec's ExecResult once done; nil for send
+}
+
+// KeyClaim answers a claim_key request. Duplicate is set when the key was
+// used before; Done once that call finished, with its result.
+type KeyClaim struct {
+	Duplicate bool            `json:"duplicate"`
+	Done      bool            `json:"done,omitempty"`
+	Outcome   string          `json:"outcome,omitempty"`
+	Failure   string          `json:"failure,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+}
+
+// ValidateIdempotencyKey checks a caller-chosen key.
+func ValidateIdempotencyKey(key string) error {
+	if len(key) > IdempotencyKeyMaxLen {
+		return fmt.Errorf("idempotency key is longer than %d bytes", IdempotencyKeyMaxLen)
+	}
+	return nil
+}
+
+// claimKey returns the call remembered under key, registering a new one when
+// there is none; dup reports that the key belongs to another call. Callers
+// hold Server.mu.
+func (h *sessionHandle) claimKey(key, nonce string, now time.Time) (call *idempotentCall, dup bool) {
+	var oldest string
+	for k, c := range h.keys {
+		if now.Sub(c.at) > IdempotencyTTL {
+			delete(h.keys, k)
+		} else if oldest == "" || c.at.Before(h.keys[oldest].at) {
+			oldest = k
+		}
+	}
+	if c, ok := h.keys[key]; ok {
+		return c, nonce == "" || c.nonce != nonce
+	}
+	if h.keys == nil {
+		h.keys = make(map[string]*idempotentCall)
+	}
+	if len(h.keys) >= IdempotencyMaxKeys {
+		delete(h.keys, oldest)
+	}
+	call = &idempotentCall{at: now, nonce: nonce}
+	h.keys[key] = call
+	return call, false
+}
+
+// claimSendKey registers a send's idempotency key and reports whether it
+// was used before, in which case the input must not be sent again.
+func (s *Server) claimSendKey(h *sessionHandle, key string) (dup bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	call, dup := h.claimKey(key, "", time.Now())
+	if !dup {
+		call.done = true
+	}
+	return dup
+}
+
+// forgetKey drops a key whose call failed before doing anything, so a
+// retry runs it.
+func (s *Server) forgetKey(h *sessionHandle, key string) {
+	if key == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(h.keys, key)
+}
+
+// handleClaimKey registers an exec's idempotency key, or reports the call
+// that already holds it.
+func (s *Server) handleClaimKey(req Request) Response {
+	if req.IdempotencyKey == "" {
+		return Response{Success: false, Error: "idempotency key is required"}
+	}
+	if err := ValidateIdempotencyKey(req.IdempotencyKey); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	call, dup := h.claimKey(req.IdempotencyKey, req.Nonce, time.Now())
+	if !dup {
+		return Response{Success: true, Data: KeyClaim{}}
+	}
+	return Response{Success: true, Data: KeyClaim{
+		Duplicate: true,
+		Done:      call.done,
+		Outcome:   call.outcome,
+		Failure:   call.failure,
+		Result:    call.result,
+	}}
+}
+
+// handleRecordKey stores the result of the exec holding an idempotency key,
+// for retries to return.
+func (s *Server) handleRecordKey(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	call, ok := h.keys[req.IdempotencyKey]
+	if !ok {
+		return Response{Success: false, Error: fmt.Sprintf("idempotency key %q is not known in session %q", req.IdempotencyKey, req.Name)}
+	}
+	call.done = true
+	call.outcome = req.Outcome
+	call.failure = req.Failure
+	call.result = req.Result
+	return Response{Success: true}
+}
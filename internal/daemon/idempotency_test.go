@@ -0,0 +1,113 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClaimKey(t *testing.T) {
+	var h sessionHandle
+	now := time.Unix(1000, 0)
+
+	if _, dup := h.claimKey("a", "n1", now); dup {
+		t.Fatal("first claim is a duplicate")
+	}
+	if _, dup := h.claimKey("a", "n1", now); dup {
+		t.Error("claim retried with the same nonce is a duplicate")
+	}
+	if _, dup := h.claimKey("a", "n2", now); !dup {
+		t.Error("claim by another call is not a duplicate")
+	}
+	if _, dup := h.claimKey("a", "", now); !dup {
+		t.Error("claim without a nonce is not a duplicate")
+	}
+
+	// Expired keys are forgotten.
+	if _, dup := h.claimKey("a", "n2", now.Add(IdempotencyTTL+time.Second)); dup {
+		t.Error("claim after the TTL is a duplicate")
+	}
+
+	// The oldest key goes first once the session holds too many.
+	h.keys = nil
+	for i := 0; i < IdempotencyMaxKeys; i++ {
+		h.claimKey(fmt.Sprintf("k%d", i), "", now.Add(time.Duration(i)*time.Millisecond))
+	}
+	h.claimKey("extra", "", now.Add(time.Second))
+	if len(h.keys) != IdempotencyMaxKeys {
+		t.Errorf("keys = %d, want %d", len(h.keys), IdempotencyMaxKeys)
+	}
+	if _, ok := h.keys["k0"]; ok {
+		t.Error("oldest key was kept")
+	}
+	if _, ok := h.keys["k1"]; !ok {
+		t.Error("second oldest key was evicted")
+	}
+}
+
+func TestIdempotentSendAndExec(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("idem", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("idem")
+
+	for i := 0; i < 2; i++ {
+		dup, err := client.SendOnce("idem", "echo sent-once\n", false, "send-1")
+		if err != nil {
+			t.Fatalf("SendOnce: %v", err)
+		}
+		if dup != (i == 1) {
+			t.Errorf("SendOnce #%d duplicate = %v", i+1, dup)
+		}
+	}
+	waitForOutput(t, client, "idem", "sent-once")
+
+	opts := ExecOptions{Input: "echo exec-once", SettleMs: 300, TimeoutSec: 5, IdempotencyKey: "exec-1"}
+	first, err := client.Exec("idem", opts)
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	second, err := client.Exec("idem", opts)
+	if err != nil {
+		t.Fatalf("repeated Exec: %v", err)
+	}
+	if first.Duplicate || !second.Duplicate || second.Output != first.Output || second.Position != first.Position {
+		t.Errorf("first = %+v, second = %+v", first, second)
+	}
+
+	// A retry racing the first call waits for its result.
+	opts = ExecOptions{Input: "sleep 0.5; echo slow-$((1+1))", WaitPattern: "slow-2", TimeoutSec: 5, IdempotencyKey: "exec-2"}
+	var wg sync.WaitGroup
+	results := make([]*ExecResult, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * 100 * time.Millisecond)
+			results[i], _ = client.Exec("idem", opts)
+		}(i)
+	}
+	wg.Wait()
+	if results[0] == nil || results[1] == nil || !results[1].Duplicate || results[1].Output != results[0].Output {
+		t.Errorf("concurrent results = %+v, %+v", results[0], results[1])
+	}
+
+	all, _, err := client.Read("idem", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	for _, cmd := range []string{"echo sent-once", "echo exec-once", "echo slow-$((1+1))"} {
+		if n := strings.Count(all, cmd); n != 1 {
+			t.Errorf("%q typed %d times, want 1:\n%s", cmd, n, all)
+		}
+	}
+
+	if _, err := client.SendOnce("idem", "x", false, strings.Repeat("k", IdempotencyKeyMaxLen+1)); err == nil {
+		t.Error("overlong idempotency key was accepted")
+	}
+}
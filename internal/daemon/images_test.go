@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInlineImages(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	png := []byte("\x89PNG fake")
+	name := base64.StdEncoding.EncodeToString([]byte("chart.png"))
+	payload := base64.StdEncoding.EncodeToString(png)
+	cmd := fmt.Sprintf(`printf 'before \033]1337;File=name=%s;inline=1:%s\007 after\n'`, name, payload)
+
+	if _, err := client.Create("img", CreateOptions{Command: cmd}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	output := waitForOutput(t, client, "img", "after")
+	if strings.Contains(output, "1337") || strings.Contains(output, payload) {
+		t.Errorf("output still contains the image sequence: %q", output)
+	}
+
+	images, err := client.Images("img")
+	if err != nil {
+		t.Fatalf("Images: %v", err)
+	}
+	if len(images) != 1 {
+		t.Fatalf("got %d images, want 1", len(images))
+	}
+	if images[0].Name != "chart.png" || images[0].Format != "png" || images[0].Bytes != len(png) {
+		t.Errorf("image info = %+v", images[0])
+	}
+
+	img, err := client.Image("img", images[0].ID)
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if !bytes.Equal(img.Data, png) {
+		t.Errorf("image data = %q, want %q", img.Data, png)
+	}
+
+	if _, err := client.Image("img", 99); err == nil {
+		t.Error("expected error for unknown image id")
+	}
+}
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// InputLogMaxBytes caps the input kept per session. Input sent after the
+// cap is reached is not logged and the log is marked truncated.
+const InputLogMaxBytes = 1 << 20
+
+// InputEvent is one piece of input sent to a session.
+type InputEvent struct {
+	At   time.Time `json:"at"`
+	Data string    `json:"data"`
+}
+
+// inputLog records what was sent to a session, so the exploration can be
+// replayed against a fresh one. It is kept in memory only. Hidden commands
+// (probes, background job wrappers) are not logged.
+type inputLog struct {
+	mu        sync.Mutex
+	events    []InputEvent
+	size      int
+	truncated bool
+}
+
+func (l *inputLog) add(at time.Time, data string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.truncated || l.size+len(data) > InputLogMaxBytes {
+		l.truncated = true
+		return
+	}
+	l.events = append(l.events, InputEvent{At: at, Data: data})
+	l.size += len(data)
+}
+
+// InputLog is a session's logged input plus what is needed to start an
+// equivalent session to replay it into.
+type InputLog struct {
+	Name      string       `json:"name"`
+	Command   string       `json:"command"`
+	Cwd       string       `json:"cwd,omitempty"`
+	Cols      int          `json:"cols"`
+	Rows      int          `json:"rows"`
+	TUIMode   bool         `json:"tui_mode,omitempty"`
+	Encoding  string       `json:"encoding,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	Events    []InputEvent `json:"events"`
+	Truncated bool         `json:"truncated,omitempty"`
+}
+
+func (s *Server) handleInputLog(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	storage := s.storage
+	result := InputLog{
+		Name:      h.name,
+		Command:   h.command,
+		Cwd:       h.cwd,
+		CreatedAt: h.createdAt,
+	}
+	s.mu.Unlock()
+
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+	result.Cols = meta.Cols
+	result.Rows = meta.Rows
+	result.TUIMode = meta.TUIMode
+	result.Encoding = meta.Encoding
+
+	h.input.mu.Lock()
+	result.Events = append([]InputEvent{}, h.input.events...)
+	result.Truncated = h.input.truncated
+	h.input.mu.Unlock()
+
+	return Response{Success: true, Data: result}
+}
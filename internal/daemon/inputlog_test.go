@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInputLog(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	if _, err := client.Create("inlog", CreateOptions{Command: "sh", Cwd: dir, Cols: 100, Rows: 30}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("inlog")
+
+	if err := client.Send("inlog", "echo one", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "inlog", "one\r\n")
+	if _, err := client.Probe("inlog", 0); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if err := client.Send("inlog", "\x03", false); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	recorded, err := client.InputLog("inlog")
+	if err != nil {
+		t.Fatalf("InputLog: %v", err)
+	}
+	if recorded.Command != "sh" || recorded.Cwd != dir || recorded.Cols != 100 || recorded.Rows != 30 {
+		t.Errorf("InputLog settings = %q in %q at %dx%d", recorded.Command, recorded.Cwd, recorded.Cols, recorded.Rows)
+	}
+	if len(recorded.Events) != 2 {
+		t.Fatalf("InputLog has %d events, want 2 (probe is hidden): %+v", len(recorded.Events), recorded.Events)
+	}
+	if !strings.HasPrefix(recorded.Events[0].Data, "echo one") || recorded.Events[1].Data != "\x03" {
+		t.Errorf("InputLog events = %q, %q", recorded.Events[0].Data, recorded.Events[1].Data)
+	}
+	if recorded.Events[0].At.Before(recorded.CreatedAt) || recorded.Events[1].At.Before(recorded.Events[0].At) {
+		t.Errorf("InputLog timestamps out of order: created %v, events %v, %v", recorded.CreatedAt, recorded.Events[0].At, recorded.Events[1].At)
+	}
+}
+
+func TestInputLogLimit(t *testing.T) {
+	var l inputLog
+	l.add(time.Now(), strings.Repeat("x", InputLogMaxBytes-1))
+	l.add(time.Now(), "ab")
+	l.add(time.Now(), "c")
+	if len(l.events) != 1 || !l.truncated {
+		t.Errorf("after the limit: %d events, truncated %v", len(l.events), l.truncated)
+	}
+}
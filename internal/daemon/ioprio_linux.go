@@ -0,0 +1,13 @@
+package daemon
+
+import "syscall"
+
+const ioprioWhoPgrp = 2
+
+func setIOPriority(pgid, prio int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoPgrp, uintptr(pgid), uintptr(prio)) // #nosec G115 -- pgid and prio are small non-negative values
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
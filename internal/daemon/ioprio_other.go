@@ -0,0 +1,9 @@
+//go:build !linux
+
+package daemon
+
+import "errors"
+
+func setIOPriority(pgid, prio int) error {
+	return errors.New("ionice is only supported on Linux")
+}
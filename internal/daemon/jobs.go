@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"syscall"
+)
+
+// Job states reported by the jobs action.
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+)
+
+// Job is a command started in the background of a shell session with
+// exec --background.
+type Job struct {
+	ID      int    `json:"id"` // shell job number, for fg %ID
+	PID     int    `json:"pid"`
+	Command string `json:"command"`
+	Started string `json:"started_at"`
+	Status  string `json:"status,omitempty"`
+}
+
+// jobCommand returns the hidden command that reports the PID of the shell's
+// latest background job, framed like probeCommand.
+func jobCommand(command, nonce string) string {
+	pid := "$!"
+	if probeShell(command) == "fish" {
+		pid = "$last_pid"
+	}
+	return fmt.Sprintf(` printf '\137\137shelli:%%s:%%d\n' %s "%s"`+"\n", nonce, pid)
+}
+
+// parseJobPID finds the job command's answer for nonce in output.
+func parseJobPID(output []byte, nonce string) (int, bool) {
+	re := regexp.MustCompile(`__shelli:` + regexp.QuoteMeta(nonce) + `:(\d+)\r?\n`)
+	m := re.FindSubmatch(output)
+	if m == nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(string(m[1]))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+// parseJobNumber finds the "[1] 12345" line an interactive shell prints
+// when it starts a background job.
+func parseJobNumber(output []byte, pid int) (int, bool) {
+	re := regexp.MustCompile(`\[(\d+)\][ \t]+` + strconv.Itoa(pid) + `\b`)
+	m := re.FindSubmatch(output)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(m[1]))
+	return n, err == nil
+}
+
+// nextJobID guesses the job number of a new job when the shell did not
+// print it: one past the highest number still in use.
+func nextJobID(jobs []*Job) int {
+	id := 1
+	for _, j := range jobs {
+		if jobAlive(j.PID) && j.ID >= id {
+			id = j.ID + 1
+		}
+	}
+	return id
+}
+
+// jobAlive reports whether a job's process is still running.
+func jobAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return (err == nil || err == syscall.EPERM) && !processZombie(pid)
+}
+
+// removeMarkedLines drops the lines from the one offset is in that contain
+// marker, such as the echo and answer of a hidden command, keeping
+// everything else the session printed meanwhile. The line offset is in
+// counts whole: the prompt a hidden command was typed after goes with its
+// echo, and the prompt the shell prints after the answer takes its place.
+func removeMarkedLines(storage OutputStorage, name string, offset int64, marker string) error {
+	meta, err := storage.LoadMeta(name)
+	if err != nil {
+		return err
+	}
+	data, err := storage.ReadAll(name)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) <= offset {
+		return nil
+	}
+	offset = int64(bytes.LastIndexByte(data[:offset], '\n') + 1)
+	kept := append([]byte(nil), data[:offset]...)
+	for _, line := range bytes.SplitAfter(data[offset:], []byte("\n")) {
+		if !bytes.Contains(line, []byte(marker)) {
+			kept = append(kept, line...)
+		}
+	}
+	return rewriteOutput(storage, name, meta, kept)
+}
@@ -0,0 +1,7 @@
+package daemon
+
+// processZombie reports whether pid has exited but not been reaped yet;
+// some shells (dash) only reap background jobs at their next prompt.
+func processZombie(pid int) bool {
+	return processState(pid) == "Z"
+}
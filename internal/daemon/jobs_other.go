@@ -0,0 +1,7 @@
+//go:build !linux
+
+package daemon
+
+func processZombie(pid int) bool {
+	return false
+}
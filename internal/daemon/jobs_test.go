@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackgroundJobs(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("jobs", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("jobs")
+
+	first, err := client.Background("jobs", "sleep 30 &", 0)
+	if err != nil {
+		t.Fatalf("Background: %v", err)
+	}
+	if first.PID <= 0 || first.ID != 1 || first.Command != "sleep 30" {
+		t.Errorf("job = %+v, want job 1 with a PID", first)
+	}
+
+	second, err := client.Background("jobs", "sh -c 'sleep 0.3; echo bg-marker'", 0)
+	if err != nil {
+		t.Fatalf("Background: %v", err)
+	}
+	if second.ID != 2 {
+		t.Errorf("second job ID = %d, want 2", second.ID)
+	}
+
+	waitForOutput(t, client, "jobs", "bg-marker\r\n")
+	output, _, err := client.Read("jobs", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if strings.Contains(output, "shelli") {
+		t.Errorf("job query left in buffer: %q", output)
+	}
+
+	syscall.Kill(first.PID, syscall.SIGKILL)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jobs, err := client.Jobs("jobs")
+		if err != nil {
+			t.Fatalf("Jobs: %v", err)
+		}
+		if len(jobs) != 2 {
+			t.Fatalf("jobs = %+v, want 2", jobs)
+		}
+		if jobs[0].Status == JobDone && jobs[1].Status == JobDone {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs never finished: %+v", jobs)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestParseJobNumber(t *testing.T) {
+	tests := []struct {
+		output string
+		pid    int
+		want   int
+		ok     bool
+	}{
+		{"$ sleep 5 &\r\n[1] 4242\r\n$ ", 4242, 1, true},
+		{"[3]\t4242\n", 4242, 3, true},
+		{"[1] 42421\r\n", 4242, 0, false},
+		{"no job here", 4242, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseJobNumber([]byte(tt.output), tt.pid)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseJobNumber(%q, %d) = %d, %v, want %d, %v", tt.output, tt.pid, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package daemon
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestLimitLines(t *testing.T) {
 	tests := []struct {
@@ -26,3 +29,42 @@ func TestLimitLines(t *testing.T) {
 		})
 	}
 }
+
+func TestLimitLinesLongLine(t *testing.T) {
+	long := strings.Repeat("x", MaxLineLength+100)
+	got, truncated := limitLines("a\n"+long+"\nb", 0, 2)
+	if truncated != 1 {
+		t.Fatalf("truncated = %d, want 1", truncated)
+	}
+	lines := strings.Split(got, "\n")
+	if len(lines) != 2 || lines[1] != "b" {
+		t.Fatalf("lines = %q", lines)
+	}
+	want := strings.Repeat("x", MaxLineLength) + "… [100 bytes truncated]"
+	if lines[0] != want {
+		t.Errorf("long line = %q...", lines[0][MaxLineLength-5:])
+	}
+}
+
+func TestTruncateLongLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		maxLen    int
+		expect    string
+		truncated int
+	}{
+		{"short", "abc\ndef", 5, "abc\ndef", 0},
+		{"exact", "abcde", 5, "abcde", 0},
+		{"one long", "abcdefgh\nok", 5, "abcde… [3 bytes truncated]\nok", 1},
+		{"rune boundary", "ab€€", 3, "ab… [6 bytes truncated]", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n := TruncateLongLines(tt.input, tt.maxLen)
+			if got != tt.expect || n != tt.truncated {
+				t.Errorf("TruncateLongLines(%q, %d) = %q, %d; want %q, %d", tt.input, tt.maxLen, got, n, tt.expect, tt.truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceLimits caps what a session's processes may use. Memory and CPU
+// time are rlimits (RLIMIT_AS, RLIMIT_CPU) set before the command starts,
+// so everything it starts inherits them and it cannot raise them. On Linux
+// with cgroup v2 the session also gets a cgroup of its own: memory.max caps
+// its processes together, and CPUPercent, which only a cgroup can enforce,
+// sets cpu.max.
+type ResourceLimits struct {
+	MemoryBytes int64 `json:"memory_bytes,omitempty"` // address space per process; all processes with a cgroup
+	CPUSeconds  int64 `json:"cpu_seconds,omitempty"`  // CPU time per process (SIGXCPU, then SIGKILL)
+	CPUPercent  int   `json:"cpu_percent,omitempty"`  // of one CPU, over 100 for several; cgroup only
+	// Cgroup is the session's cgroup directory, set by the daemon when
+	// it could make one.
+	Cgroup string `json:"cgroup,omitempty"`
+}
+
+func (l *ResourceLimits) empty() bool {
+	return l == nil || (l.MemoryBytes == 0 && l.CPUSeconds == 0 && l.CPUPercent == 0)
+}
+
+// validateLimits checks limits given on create.
+func validateLimits(l *ResourceLimits) error {
+	if l == nil {
+		return nil
+	}
+	if l.MemoryBytes < 0 || l.CPUSeconds < 0 || l.CPUPercent < 0 {
+		return fmt.Errorf("resource limits must not be negative")
+	}
+	if l.MemoryBytes > 0 && l.MemoryBytes < 1024*1024 {
+		return fmt.Errorf("memory limit must be at least 1MB, got %d bytes", l.MemoryBytes)
+	}
+	return nil
+}
+
+// limitArgs wraps a command's argv so the shell sets the rlimits and then
+// execs it: the limits hold from the command's first instruction, on every
+// platform. A limit the shell cannot set keeps the command from running.
+func limitArgs(args []string, l *ResourceLimits) []string {
+	var ulimits []string
+	if l.MemoryBytes > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -v %d", (l.MemoryBytes+1023)/1024))
+	}
+	if l.CPUSeconds > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -t %d", l.CPUSeconds))
+	}
+	if len(ulimits) == 0 {
+		return args
+	}
+	script := strings.Join(ulimits, " && ") + ` && exec "$@"`
+	return append([]string{"sh", "-c", script, "sh"}, args...)
+}
+
+func limitsCgroup(l *ResourceLimits) string {
+	if l == nil {
+		return ""
+	}
+	return l.Cgroup
+}
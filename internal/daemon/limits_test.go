@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLimitArgs(t *testing.T) {
+	args := []string{"sh", "-c", "make -j8"}
+	if got := limitArgs(args, &ResourceLimits{CPUPercent: 50}); !reflect.DeepEqual(got, args) {
+		t.Errorf("cgroup-only limits changed argv: %q", got)
+	}
+
+	got := limitArgs(args, &ResourceLimits{MemoryBytes: 1<<20 + 1, CPUSeconds: 60})
+	want := []string{"sh", "-c", `ulimit -v 1025 && ulimit -t 60 && exec "$@"`, "sh", "sh", "-c", "make -j8"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("limitArgs = %q, want %q", got, want)
+	}
+}
+
+func TestCreateWithLimits(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("tiny", CreateOptions{Command: "sh", Limits: &ResourceLimits{MemoryBytes: 100}}); err == nil {
+		t.Error("create with a 100 byte memory limit succeeded")
+	}
+
+	limits := &ResourceLimits{MemoryBytes: 256 << 20, CPUSeconds: 30, Cgroup: "/ignored"}
+	if _, err := client.Create("limited", CreateOptions{Command: "sh", Limits: limits}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("limited")
+	if err := client.Send("limited", `echo "[$(ulimit -v)/$(ulimit -t)]"`, true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "limited", "[262144/30]")
+
+	info, err := client.Info("limited")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.Limits == nil || info.Limits.MemoryBytes != 256<<20 || info.Limits.CPUSeconds != 30 {
+		t.Fatalf("info limits = %+v", info.Limits)
+	}
+	if info.Limits.Cgroup == "/ignored" {
+		t.Error("cgroup taken from the request")
+	}
+
+	// A sibling gets the same limits.
+	if _, err := client.Sibling("limited", "next", "sh", nil); err != nil {
+		t.Fatalf("sibling: %v", err)
+	}
+	defer client.Kill("next")
+	if err := client.Send("next", `echo "[$(ulimit -t)]"`, true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "next", "[30]")
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// LineRecord is one line of output as read --lines returns it.
+type LineRecord struct {
+	// LineNumber counts lines from the start of the buffer, 1-based, as
+	// search and locate do for the read's newlines mode.
+	LineNumber int    `json:"line_number"`
+	Text       string `json:"text"`   // ANSI stripped, without its line break
+	Offset     int64  `json:"offset"` // buffer position of the line's first byte read
+	// TS is when the line's first byte was stored, to the chunk
+	// granularity; nil when the session has no output times.
+	TS *time.Time `json:"ts,omitempty"`
+	// Partial marks a last line without a line break yet; it may still
+	// grow, so a consumer should read it again rather than keep it.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// readLineRecords splits output, the bytes of a read that ends at end, into
+// LineRecords, keeping req's head or tail lines. It also returns how many
+// kept lines were cut at MaxLineLength.
+func readLineRecords(storage OutputStorage, name, output string, end int64, req Request) ([]LineRecord, int, error) {
+	start := end - int64(len(output))
+	line := 1
+	if start > 0 {
+		data, err := storage.ReadAll(name)
+		if err != nil {
+			return nil, 0, fmt.Errorf("read output: %v", err)
+		}
+		start = min(start, int64(len(data)))
+		for i := range data[:start] {
+			if lineBreakAt(data, i, req.Newlines) {
+				line++
+			}
+		}
+	}
+	chunks, err := storage.Chunks(name)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read chunk times: %v", err)
+	}
+
+	records := splitLineRecords([]byte(output), start, line, req.Newlines, chunks)
+	if req.HeadLines > 0 && req.HeadLines < len(records) {
+		records = records[:req.HeadLines]
+	} else if req.TailLines > 0 && req.TailLines < len(records) {
+		records = records[len(records)-req.TailLines:]
+	}
+	truncated := 0
+	for i := range records {
+		var cut int
+		records[i].Text, cut = TruncateLongLines(records[i].Text, MaxLineLength)
+		truncated += cut
+	}
+	return records, truncated, nil
+}
+
+// splitLineRecords breaks data, found at offset start of the buffer and
+// starting on line number line, into records. Line breaks follow the
+// newlines mode as in Locate; display mode also applies carriage-return
+// overwrites within each line.
+func splitLineRecords(data []byte, start int64, line int, mode string, chunks []Chunk) []LineRecord {
+	records := []LineRecord{}
+	add := func(from, to int, partial bool) {
+		text := string(data[from:to])
+		if mode == NewlinesDisplay {
+			text = NormalizeNewlines(text, mode)
+		}
+		text = strings.TrimRight(vterm.StripDefault(text), "\r")
+		r := LineRecord{LineNumber: line, Text: text, Offset: start + int64(from), Partial: partial}
+		if at, ok := chunkTimeAt(chunks, r.Offset); ok {
+			r.TS = &at
+		}
+		records = append(records, r)
+		line++
+	}
+	from := 0
+	for i := range data {
+		if lineBreakAt(data, i, mode) {
+			add(from, i, false)
+			from = i + 1
+		}
+	}
+	if from < len(data) {
+		add(from, len(data), true)
+	}
+	return records
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitLineRecords(t *testing.T) {
+	t0 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	chunks := []Chunk{{Offset: 100, At: t0}, {Offset: 112, At: t0.Add(time.Second)}}
+	data := []byte("\x1b[31mred\x1b[0m\r\n50%\r100%\r\nprompt$ ")
+
+	records := splitLineRecords(data, 106, 7, NewlinesDisplay, chunks)
+	if len(records) != 3 {
+		t.Fatalf("records = %+v", records)
+	}
+	want := []LineRecord{
+		{LineNumber: 7, Text: "red", Offset: 106},
+		{LineNumber: 8, Text: "100%", Offset: 120},
+		{LineNumber: 9, Text: "prompt$ ", Offset: 130, Partial: true},
+	}
+	for i, r := range records {
+		if r.LineNumber != want[i].LineNumber || r.Text != want[i].Text || r.Offset != want[i].Offset || r.Partial != want[i].Partial {
+			t.Errorf("record %d = %+v, want %+v", i, r, want[i])
+		}
+	}
+	if records[0].TS == nil || !records[0].TS.Equal(t0) || !records[1].TS.Equal(t0.Add(time.Second)) {
+		t.Errorf("times = %v, %v", records[0].TS, records[1].TS)
+	}
+
+	// lf mode also breaks at the lone CR.
+	if got := splitLineRecords(data, 0, 1, NewlinesLF, nil); len(got) != 4 || got[1].Text != "50%" || got[0].TS != nil {
+		t.Errorf("lf records = %+v", got)
+	}
+	if got := splitLineRecords(data, 0, 1, "", nil); len(got) != 3 {
+		t.Errorf("raw records = %+v", got)
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("lines", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("lines")
+
+	if err := client.Send("lines", "printf 'one\\ntwo\\n'", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "lines", "two\r\n")
+	first, err := client.ReadLines("lines", ReadModeNew, "", "", 0, 0)
+	if err != nil {
+		t.Fatalf("ReadLines: %v", err)
+	}
+
+	if err := client.Send("lines", "echo three", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "lines", "three\r\n")
+	all, err := client.ReadLines("lines", ReadModeAll, "", "", 0, 0)
+	if err != nil {
+		t.Fatalf("ReadLines all: %v", err)
+	}
+	var three *LineRecord
+	for i, r := range all.Lines {
+		if r.Text == "three" {
+			three = &all.Lines[i]
+		}
+	}
+	if three == nil || three.TS == nil {
+		t.Fatalf("all lines = %+v", all.Lines)
+	}
+
+	next, err := client.ReadLines("lines", ReadModeNew, "", "", 0, 0)
+	if err != nil {
+		t.Fatalf("ReadLines new: %v", err)
+	}
+	// New reads continue the numbering of the whole buffer.
+	if len(next.Lines) == 0 || next.Lines[0].LineNumber != first.Lines[len(first.Lines)-1].LineNumber {
+		t.Errorf("first read %+v, next read %+v", first.Lines, next.Lines)
+	}
+	found := false
+	for _, r := range next.Lines {
+		if r.Text == "three" {
+			found = r.LineNumber == three.LineNumber && r.Offset == three.Offset
+		}
+	}
+	if !found {
+		t.Errorf("new read lines = %+v, want three as line %d", next.Lines, three.LineNumber)
+	}
+
+	tail, err := client.ReadLines("lines", ReadModeAll, "", "", 0, 1)
+	if err != nil {
+		t.Fatalf("ReadLines tail: %v", err)
+	}
+	if len(tail.Lines) != 1 || !tail.Lines[0].Partial || !strings.ContainsAny(tail.Lines[0].Text, "$#") {
+		t.Errorf("tail = %+v", tail.Lines)
+	}
+}
@@ -0,0 +1,71 @@
+package daemon
+
+import "fmt"
+
+// LinePosition ties a byte position in a session's buffer to its line, so
+// positions from read and exec can be matched with search's line numbers and
+// lines turned back into offsets for ranged reads.
+type LinePosition struct {
+	Position   int64 `json:"position"`
+	Line       int   `json:"line"`       // 1-based, as search numbers lines
+	Column     int   `json:"column"`     // bytes from the start of the line
+	LineStart  int64 `json:"line_start"` // offset of the line's first byte
+	LineEnd    int64 `json:"line_end"`   // offset of its line break, or the buffer size
+	TotalLines int   `json:"total_lines"`
+	Size       int64 `json:"size"`
+}
+
+// lineBreakAt reports whether data[i] ends a line in a newline mode. In lf
+// mode a lone CR also ends one, as NormalizeNewlines turns it into LF;
+// display mode overwrites the line instead, so only LF counts, as in raw.
+func lineBreakAt(data []byte, i int, mode string) bool {
+	switch data[i] {
+	case '\n':
+		return true
+	case '\r':
+		return mode == NewlinesLF && (i+1 == len(data) || data[i+1] != '\n')
+	}
+	return false
+}
+
+// Locate maps a position to its line, or, when line is positive, a line to
+// the position of its first byte.
+func Locate(data []byte, pos int64, line int, mode string) (*LinePosition, error) {
+	size := int64(len(data))
+	if line <= 0 && (pos < 0 || pos > size) {
+		return nil, fmt.Errorf("position %d is outside the buffer (0-%d)", pos, size)
+	}
+
+	lp := &LinePosition{Size: size}
+	n, start, found := 1, int64(0), false
+	for i := range data {
+		if !lineBreakAt(data, i, mode) {
+			continue
+		}
+		if !found && ((line > 0 && n == line) || (line <= 0 && pos <= int64(i))) {
+			end := int64(i)
+			// CRLF ends the line before its CR unless raw bytes are asked for.
+			if mode != "" && mode != NewlinesRaw && data[i] == '\n' && end > start && data[i-1] == '\r' {
+				end--
+			}
+			lp.Line, lp.LineStart, lp.LineEnd = n, start, end
+			found = true
+		}
+		n++
+		start = int64(i) + 1
+	}
+	lp.TotalLines = n
+
+	if !found {
+		if line > 0 && line != n {
+			return nil, fmt.Errorf("line %d is past the end of the buffer (%d lines)", line, n)
+		}
+		lp.Line, lp.LineStart, lp.LineEnd = n, start, size
+	}
+	lp.Position = pos
+	if line > 0 {
+		lp.Position = lp.LineStart
+	}
+	lp.Column = int(lp.Position - lp.LineStart)
+	return lp, nil
+}
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"testing"
+)
+
+func TestLocate(t *testing.T) {
+	data := []byte("one\r\ntwo\rTWO\nthree")
+	tests := []struct {
+		name     string
+		pos      int64
+		line     int
+		mode     string
+		wantLine int
+		wantCol  int
+		wantSpan [2]int64
+		total    int
+	}{
+		{"start", 0, 0, NewlinesRaw, 1, 0, [2]int64{0, 4}, 3},
+		{"at break", 4, 0, NewlinesRaw, 1, 4, [2]int64{0, 4}, 3},
+		{"second line", 9, 0, NewlinesRaw, 2, 4, [2]int64{5, 12}, 3},
+		{"end", 18, 0, NewlinesRaw, 3, 5, [2]int64{13, 18}, 3},
+		{"lone CR splits in lf", 9, 0, NewlinesLF, 3, 0, [2]int64{9, 12}, 4},
+		{"CRLF trimmed in lf", 0, 0, NewlinesLF, 1, 0, [2]int64{0, 3}, 4},
+		{"line to position", 0, 2, NewlinesRaw, 2, 0, [2]int64{5, 12}, 3},
+		{"last line", 0, 4, NewlinesLF, 4, 0, [2]int64{13, 18}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp, err := Locate(data, tt.pos, tt.line, tt.mode)
+			if err != nil {
+				t.Fatalf("Locate: %v", err)
+			}
+			if lp.Line != tt.wantLine || lp.Column != tt.wantCol || lp.LineStart != tt.wantSpan[0] || lp.LineEnd != tt.wantSpan[1] || lp.TotalLines != tt.total {
+				t.Errorf("Locate = %+v, want line %d col %d span %v of %d", lp, tt.wantLine, tt.wantCol, tt.wantSpan, tt.total)
+			}
+		})
+	}
+
+	if _, err := Locate(data, 19, 0, NewlinesRaw); err == nil {
+		t.Error("expected error for a position past the end")
+	}
+	if _, err := Locate(data, 0, 4, NewlinesRaw); err == nil {
+		t.Error("expected error for a line past the end")
+	}
+}
+
+func TestLocateMatchesSearch(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("lines", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("lines")
+
+	if err := client.Send("lines", "printf 'a\\nb\\nlocate-%s\\n' marker", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "lines", "locate-marker\r\n")
+
+	found, err := client.Search(SearchRequest{Name: "lines", Pattern: "^locate-marker"})
+	if err != nil || len(found.Matches) != 1 {
+		t.Fatalf("search: %+v, %v", found, err)
+	}
+
+	lp, err := client.Locate("lines", 0, found.Matches[0].LineNumber, NewlinesLF)
+	if err != nil {
+		t.Fatalf("locate: %v", err)
+	}
+	text, _, err := client.ReadRange("lines", int(lp.LineStart), int(lp.LineEnd-lp.LineStart))
+	if err != nil {
+		t.Fatalf("read range: %v", err)
+	}
+	if text != "locate-marker" {
+		t.Errorf("line %d spans %q, want %q", lp.Line, text, "locate-marker")
+	}
+
+	back, err := client.Locate("lines", lp.LineStart+3, 0, "")
+	if err != nil {
+		t.Fatalf("locate: %v", err)
+	}
+	if back.Line != lp.Line || back.Column != 3 {
+		t.Errorf("position %d maps to line %d col %d, want line %d col 3", lp.LineStart+3, back.Line, back.Column, lp.Line)
+	}
+}
@@ -0,0 +1,354 @@
+package daemon
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Eviction policies for the memory backend's budget (max_memory): what goes
+// once the output of all sessions together is over it. Spill moves the
+// output of the longest-stopped sessions to files in the spill dir, where
+// it stays readable, and truncates (as below) only once no stopped session
+// is left in memory. Truncate drops the oldest output of the sessions read
+// least recently.
+const (
+	EvictionSpill    = "spill"
+	EvictionTruncate = "truncate"
+)
+
+// MemoryEviction reports output the memory budget took from a session:
+// spilled to disk (Policy EvictionSpill) or dropped (EvictionTruncate).
+type MemoryEviction struct {
+	Session string
+	Policy  string
+	Bytes   int64
+}
+
+// MemoryStats is the memory backend's use against its budget.
+type MemoryStats struct {
+	Budget  int64  `json:"budget"` // 0: unbounded
+	Policy  string `json:"policy,omitempty"`
+	Bytes   int64  `json:"memory_bytes"`  // output held in memory
+	Spilled int64  `json:"spilled_bytes"` // output spilled to disk
+}
+
+// ValidateEviction checks an eviction policy name.
+func ValidateEviction(policy string) error {
+	switch policy {
+	case EvictionSpill, EvictionTruncate:
+		return nil
+	}
+	return fmt.Errorf("invalid eviction policy %q (expected spill or truncate)", policy)
+}
+
+// SetMemoryBudget changes the budget for all sessions' output together (0:
+// unbounded) and the eviction policy ("" for spill). It is enforced from
+// the next write. It reports whether either changed.
+func (s *MemoryStorage) SetMemoryBudget(size int, policy string) bool {
+	if policy == "" {
+		policy = EvictionSpill
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxMemory == size && s.eviction == policy {
+		return false
+	}
+	s.maxMemory, s.eviction = size, policy
+	return true
+}
+
+// SetSpillDir sets where the spill policy moves output. Files a previous
+// daemon left there are removed. Without a spill dir, spill truncates.
+func (s *MemoryStorage) SetSpillDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("create spill dir: %w", err)
+	}
+	stale, _ := filepath.Glob(filepath.Join(dir, "*.out"))
+	for _, path := range stale {
+		os.Remove(path)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spillDir = dir
+	return nil
+}
+
+// OnEviction sets fn to be told about each eviction. It is called with the
+// storage locked, so it must not use the storage.
+func (s *MemoryStorage) OnEviction(fn func(MemoryEviction)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onEvict = fn
+}
+
+// Stats returns the output held in memory and spilled against the budget.
+func (s *MemoryStorage) Stats() MemoryStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := MemoryStats{Budget: int64(s.maxMemory), Bytes: s.memoryLocked()}
+	if s.maxMemory > 0 {
+		stats.Policy = s.eviction
+	}
+	for _, size := range s.spilled {
+		stats.Spilled += size
+	}
+	return stats
+}
+
+// Spilled reports whether session's output is in the spill dir.
+func (s *MemoryStorage) Spilled(session string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, spilled := s.spilled[session]
+	return spilled
+}
+
+func (s *MemoryStorage) memoryLocked() int64 {
+	var total int64
+	for _, output := range s.outputs {
+		total += int64(len(output))
+	}
+	return total
+}
+
+func (s *MemoryStorage) spillPath(session string) string {
+	return filepath.Join(s.spillDir, session+".out")
+}
+
+// noteRead records that session's output was read, for the truncate
+// policy. Safe under the read lock.
+func (s *MemoryStorage) noteRead(session string) {
+	if t := s.lastRead[session]; t != nil {
+		t.Store(time.Now().UnixNano())
+	}
+}
+
+// excessLocked is how far the output of all sessions is over the budget.
+func (s *MemoryStorage) excessLocked() int64 {
+	if s.maxMemory <= 0 {
+		return 0
+	}
+	return s.memoryLocked() - int64(s.maxMemory)
+}
+
+// enforceBudget evicts output until all sessions together fit the budget,
+// sparing writing, the session just written to: it is bounded by
+// max_output, and its own drops are reported as buffer_limit. Called
+// unlocked: spill files are written without holding the storage lock, so
+// other sessions' reads and writes do not wait on the disk.
+func (s *MemoryStorage) enforceBudget(writing string) {
+	s.mu.Lock()
+	excess := s.excessLocked()
+	var spills []spillJob
+	if excess > 0 && s.eviction == EvictionSpill && s.spillDir != "" {
+		spills = s.planSpillsLocked(writing, excess)
+	}
+	s.mu.Unlock()
+
+	if len(spills) > 0 {
+		s.spill(spills)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if excess := s.excessLocked(); excess > 0 {
+		s.truncateLeastReadLocked(writing, excess)
+	}
+}
+
+// spillJob is a stopped session's output on its way to the spill dir.
+type spillJob struct {
+	name   string
+	output []byte
+}
+
+// planSpillsLocked picks stopped sessions to spill, longest stopped first,
+// until excess bytes would be out of memory, and marks them in flight so
+// no other write spills them too.
+func (s *MemoryStorage) planSpillsLocked(writing string, excess int64) []spillJob {
+	var stopped []string
+	for name, meta := range s.metas {
+		if name != writing && meta.State == StateStopped && len(s.outputs[name]) > 0 && !s.spilling[name] {
+			stopped = append(stopped, name)
+		}
+	}
+	sort.Slice(stopped, func(i, j int) bool {
+		a, b := s.metas[stopped[i]].StoppedAt, s.metas[stopped[j]].StoppedAt
+		if a == nil || b == nil || a.Equal(*b) {
+			return stopped[i] < stopped[j]
+		}
+		return a.Before(*b)
+	})
+
+	var jobs []spillJob
+	var planned int64
+	for _, name := range stopped {
+		if planned >= excess {
+			break
+		}
+		jobs = append(jobs, spillJob{name: name, output: s.outputs[name]})
+		s.spilling[name] = true
+		planned += int64(len(s.outputs[name]))
+	}
+	return jobs
+}
+
+// spill writes each job's output to the spill dir, then drops it from
+// memory unless the session changed meanwhile (written to, cleared or
+// deleted), in which case the file is removed again. It stops at the first
+// file that cannot be written.
+func (s *MemoryStorage) spill(jobs []spillJob) {
+	var written []spillJob
+	for i, job := range jobs {
+		if err := os.WriteFile(s.spillPath(job.name), job.output, 0600); err != nil {
+			log.Printf("memory budget: spill %s: %v", job.name, err)
+			s.mu.Lock()
+			for _, rest := range jobs[i:] {
+				delete(s.spilling, rest.name)
+			}
+			s.mu.Unlock()
+			break
+		}
+		written = append(written, job)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range written {
+		delete(s.spilling, job.name)
+		current, exists := s.outputs[job.name]
+		if !exists || len(current) != len(job.output) || &current[0] != &job.output[0] {
+			os.Remove(s.spillPath(job.name))
+			continue
+		}
+		size := int64(len(job.output))
+		s.spilled[job.name] = size
+		s.outputs[job.name] = nil
+		s.reportEvictionLocked(MemoryEviction{Session: job.name, Policy: EvictionSpill, Bytes: size})
+	}
+}
+
+// truncateLeastReadLocked drops the oldest output of the sessions read
+// least recently (never read counts from creation) until excess bytes are
+// gone.
+func (s *MemoryStorage) truncateLeastReadLocked(writing string, excess int64) {
+	var names []string
+	for name, output := range s.outputs {
+		if name != writing && len(output) > 0 {
+			names = append(names, name)
+		}
+	}
+	lastRead := func(name string) int64 {
+		if t := s.lastRead[name]; t != nil {
+			return t.Load()
+		}
+		return 0
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := lastRead(names[i]), lastRead(names[j])
+		if a == b {
+			return names[i] < names[j]
+		}
+		return a < b
+	})
+
+	for _, name := range names {
+		if excess <= 0 {
+			break
+		}
+		n := min(excess, int64(len(s.outputs[name])))
+		// Copied, so the dropped bytes are freed rather than kept alive by
+		// the backing array.
+		s.outputs[name] = append([]byte(nil), s.outputs[name][n:]...)
+		s.chunks[name] = dropChunks(s.chunks[name], n)
+		if meta, ok := s.metas[name]; ok {
+			meta.dropOutput(n)
+		}
+		excess -= n
+		s.reportEvictionLocked(MemoryEviction{Session: name, Policy: EvictionTruncate, Bytes: n})
+	}
+}
+
+func (s *MemoryStorage) reportEvictionLocked(ev MemoryEviction) {
+	if s.onEvict != nil {
+		s.onEvict(ev)
+	}
+}
+
+// unspillLocked brings a spilled session's output back into memory, before
+// it is written to or cleared.
+func (s *MemoryStorage) unspillLocked(session string) error {
+	if _, spilled := s.spilled[session]; !spilled {
+		return nil
+	}
+	output, err := readFileFrom(s.spillPath(session), 0)
+	if err != nil {
+		return err
+	}
+	s.outputs[session] = output
+	delete(s.spilled, session)
+	os.Remove(s.spillPath(session))
+	return nil
+}
+
+// memoryCounters counts the memory budget's evictions by policy, for
+// metrics and du.
+type memoryCounters struct {
+	evictions [2]atomic.Int64 // spill, truncate
+	bytes     [2]atomic.Int64
+}
+
+func evictionIndex(policy string) int {
+	if policy == EvictionTruncate {
+		return 1
+	}
+	return 0
+}
+
+// noteEviction counts an eviction and publishes it: a Spill event, or a
+// Truncation for output dropped. Called by the memory storage, locked.
+func (s *Server) noteEviction(ev MemoryEviction) {
+	i := evictionIndex(ev.Policy)
+	s.memCounters.evictions[i].Add(1)
+	s.memCounters.bytes[i].Add(ev.Bytes)
+	header := EventHeader{Session: ev.Session, At: time.Now()}
+	if ev.Policy == EvictionSpill {
+		s.events.publish(Spill{EventHeader: header, Bytes: ev.Bytes})
+		return
+	}
+	s.events.publish(Truncation{EventHeader: header, Reason: TruncationMemoryBudget, Bytes: ev.Bytes})
+}
+
+// memoryUsage fills the memory backend's budget fields of a du report.
+func (s *Server) memoryUsage(report *UsageReport, mem *MemoryStorage) {
+	stats := mem.Stats()
+	report.Memory = &MemoryUsage{
+		MemoryStats: stats,
+		Spills:      s.memCounters.evictions[0].Load(),
+		SpilledOut:  s.memCounters.bytes[0].Load(),
+		Truncations: s.memCounters.evictions[1].Load(),
+		Truncated:   s.memCounters.bytes[1].Load(),
+	}
+	for i := range report.Sessions {
+		u := &report.Sessions[i]
+		if mem.Spilled(u.Name) {
+			u.Spilled = true
+			u.DiskBytes, u.MemoryBytes = u.StoredBytes, 0
+		}
+	}
+}
+
+// MemoryUsage is the memory budget part of a du report: use now, and the
+// evictions (count and bytes, by policy) since the daemon started.
+type MemoryUsage struct {
+	MemoryStats
+	Spills      int64 `json:"spills"`
+	SpilledOut  int64 `json:"spills_bytes"`
+	Truncations int64 `json:"truncations"`
+	Truncated   int64 `json:"truncations_bytes"`
+}
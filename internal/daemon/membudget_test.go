@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newBudgetStorage(t *testing.T, budget int, policy string, names ...string) (*MemoryStorage, *[]MemoryEviction) {
+	t.Helper()
+	s := NewMemoryStorage(1024)
+	if err := s.SetSpillDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := s.Create(name, &SessionMeta{Name: name, State: StateRunning}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Append(name, []byte(strings.Repeat(name, 100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Enforced from the next write.
+	s.SetMemoryBudget(budget, policy)
+	var evictions []MemoryEviction
+	s.OnEviction(func(ev MemoryEviction) { evictions = append(evictions, ev) })
+	return s, &evictions
+}
+
+func TestMemoryBudgetTruncatesLeastRead(t *testing.T) {
+	s, evictions := newBudgetStorage(t, 250, EvictionTruncate, "a", "b")
+	s.UpdateMeta("a", func(m *SessionMeta) { m.ReadPos = 100 })
+	s.Create("c", &SessionMeta{Name: "c", State: StateRunning})
+	// a was read last, so b goes first.
+	time.Sleep(time.Millisecond)
+	s.ReadFrom("a", 0)
+
+	before := s.outputs["b"]
+	if err := s.Append("c", []byte(strings.Repeat("c", 120))); err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := s.Size("b"); size != 30 {
+		t.Errorf("b size = %d, want 30 (70 evicted)", size)
+	}
+	if c := cap(s.outputs["b"]); c > 32 {
+		t.Errorf("b capacity = %d, want it shrunk to the 30 bytes kept", c)
+	}
+	if &s.outputs["b"][0] == &before[70] {
+		t.Error("b still points into its old buffer, keeping the evicted bytes alive")
+	}
+	if size, _ := s.Size("a"); size != 100 {
+		t.Errorf("a size = %d, want 100 (read recently)", size)
+	}
+	if size, _ := s.Size("c"); size != 120 {
+		t.Errorf("c size = %d, want 120 (being written)", size)
+	}
+	want := []MemoryEviction{{Session: "b", Policy: EvictionTruncate, Bytes: 70}}
+	if len(*evictions) != 1 || (*evictions)[0] != want[0] {
+		t.Errorf("evictions = %+v, want %+v", *evictions, want)
+	}
+	if meta, _ := s.LoadMeta("b"); meta.Truncations[""] != 1 {
+		t.Errorf("b truncations = %v, want the unread drop noted", meta.Truncations)
+	}
+	if stats := s.Stats(); stats.Bytes != 250 {
+		t.Errorf("memory = %d, want 250", stats.Bytes)
+	}
+}
+
+func TestMemoryBudgetSpillsStopped(t *testing.T) {
+	s, evictions := newBudgetStorage(t, 250, EvictionSpill, "a", "b", "x")
+	earlier, later := time.Now().Add(-time.Minute), time.Now()
+	s.UpdateMeta("a", func(m *SessionMeta) { m.State, m.StoppedAt = StateStopped, &later })
+	s.UpdateMeta("b", func(m *SessionMeta) { m.State, m.StoppedAt = StateStopped, &earlier })
+
+	if err := s.Append("x", []byte("xx")); err != nil {
+		t.Fatal(err)
+	}
+	// b stopped longest ago: spilling it is enough.
+	if !s.Spilled("b") || s.Spilled("a") {
+		t.Fatalf("spilled a=%v b=%v, want only b", s.Spilled("a"), s.Spilled("b"))
+	}
+	if len(*evictions) != 1 || (*evictions)[0].Policy != EvictionSpill || (*evictions)[0].Bytes != 100 {
+		t.Errorf("evictions = %+v, want b spilled", *evictions)
+	}
+	stats := s.Stats()
+	if stats.Bytes != 202 || stats.Spilled != 100 {
+		t.Errorf("stats = %+v, want 202 in memory and 100 spilled", stats)
+	}
+
+	// A spilled session still reads the same.
+	if size, _ := s.Size("b"); size != 100 {
+		t.Errorf("b size = %d, want 100", size)
+	}
+	if data, _ := s.ReadFrom("b", 90); string(data) != strings.Repeat("b", 10) {
+		t.Errorf("b read from 90 = %q", data)
+	}
+	spillPath := filepath.Join(s.spillDir, "b.out")
+	if _, err := os.Stat(spillPath); err != nil {
+		t.Errorf("spill file: %v", err)
+	}
+
+	// Writing to it brings it back; deleting it removes the file.
+	if err := s.Append("b", []byte("!")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.ReadAll("b"); string(data) != strings.Repeat("b", 100)+"!" {
+		t.Errorf("b after append = %q", data)
+	}
+	if s.Spilled("b") {
+		t.Error("b still spilled after a write")
+	}
+	if _, err := os.Stat(spillPath); !os.IsNotExist(err) {
+		t.Errorf("spill file left behind: %v", err)
+	}
+}
+
+func TestMemoryBudgetSpillFallsBackToTruncate(t *testing.T) {
+	s, evictions := newBudgetStorage(t, 150, EvictionSpill, "a", "b")
+	if err := s.Append("b", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing stopped to spill: a, the other running session, is cut.
+	if len(*evictions) != 1 || (*evictions)[0] != (MemoryEviction{Session: "a", Policy: EvictionTruncate, Bytes: 51}) {
+		t.Errorf("evictions = %+v, want 51 bytes truncated from a", *evictions)
+	}
+}
+
+func TestMemoryBudgetSpillOfChangedSession(t *testing.T) {
+	s, evictions := newBudgetStorage(t, 250, EvictionSpill, "a", "x")
+	s.UpdateMeta("a", func(m *SessionMeta) { m.State = StateStopped })
+
+	// a is cleared while its spill file is written, outside the lock.
+	s.mu.Lock()
+	jobs := s.planSpillsLocked("x", 100)
+	s.mu.Unlock()
+	if len(jobs) != 1 || jobs[0].name != "a" {
+		t.Fatalf("jobs = %+v, want a", jobs)
+	}
+	if err := s.Clear("a"); err != nil {
+		t.Fatal(err)
+	}
+	s.spill(jobs)
+
+	if s.Spilled("a") || len(*evictions) != 0 {
+		t.Errorf("spilled = %v, evictions = %+v, want the stale spill dropped", s.Spilled("a"), *evictions)
+	}
+	if _, err := os.Stat(filepath.Join(s.spillDir, "a.out")); !os.IsNotExist(err) {
+		t.Errorf("stale spill file left behind: %v", err)
+	}
+	if s.spilling["a"] {
+		t.Error("a still marked as spilling")
+	}
+}
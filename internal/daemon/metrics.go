@@ -0,0 +1,280 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsPath is where the metrics endpoint serves.
+const MetricsPath = "/metrics"
+
+// requestBuckets are the upper bounds, in seconds, of the request latency
+// histogram. Waiting actions (wait_any, wait_exit) land in the top ones.
+var requestBuckets = []float64{0.001, 0.005, 0.025, 0.1, 0.5, 2.5, 10, 60}
+
+// requestStat is one action's request count and latency histogram.
+type requestStat struct {
+	ok, failed int64
+	buckets    []int64 // cumulative: buckets[i] counts requests <= requestBuckets[i]
+	sum        float64
+}
+
+// daemonMetrics holds the daemon-wide counters behind the metrics action.
+// Like session traffic, it starts from zero when the daemon restarts.
+type daemonMetrics struct {
+	mu        sync.Mutex
+	requests  map[string]*requestStat // by action
+	waits     map[[2]string]int64     // by kind and outcome
+	throttled map[string]int64        // by limit: session or client
+}
+
+// observe records a request to action that took d.
+func (m *daemonMetrics) observe(action string, d time.Duration, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.requests == nil {
+		m.requests = make(map[string]*requestStat)
+	}
+	stat := m.requests[action]
+	if stat == nil {
+		stat = &requestStat{buckets: make([]int64, len(requestBuckets))}
+		m.requests[action] = stat
+	}
+	if ok {
+		stat.ok++
+	} else {
+		stat.failed++
+	}
+	secs := d.Seconds()
+	stat.sum += secs
+	for i, le := range requestBuckets {
+		if secs <= le {
+			stat.buckets[i]++
+		}
+	}
+}
+
+// countWait records how a wait of kind (exec, wait_any, wait_exit) ended,
+// as one of the exec outcomes.
+func (m *daemonMetrics) countWait(kind, outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.waits == nil {
+		m.waits = make(map[[2]string]int64)
+	}
+	m.waits[[2]string{kind, outcome}]++
+}
+
+// countThrottled records a request refused by the session or client rate
+// limit.
+func (m *daemonMetrics) countThrottled(limit string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.throttled == nil {
+		m.throttled = make(map[string]int64)
+	}
+	m.throttled[limit]++
+}
+
+// sessionMetrics is one session's row in the metrics.
+type sessionMetrics struct {
+	name        string
+	state       SessionState
+	ptyIn       int64
+	ptyOut      int64
+	truncations int64
+	stored      int64
+	capture     CaptureInfo
+	delay       float64 // seconds, summed over capture batches
+}
+
+// metricsWriter writes the Prometheus text exposition format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (mw metricsWriter) header(name, kind, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one value; labels alternate names and values.
+func (mw metricsWriter) sample(name string, value any, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=\"%s\"", labels[i], metricsEscaper.Replace(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(mw.w, "%s %v\n", b.String(), value)
+}
+
+var metricsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetrics writes the daemon's request and wait counters and each
+// session's traffic and storage size in the Prometheus text format.
+func (s *Server) writeMetrics(w io.Writer) {
+	s.mu.Lock()
+	sessions := make([]sessionMetrics, 0, len(s.handles))
+	for _, h := range s.handles {
+		sessions = append(sessions, sessionMetrics{
+			name:        h.name,
+			state:       h.state,
+			ptyIn:       h.traffic.ptyIn.Load(),
+			ptyOut:      h.traffic.ptyOut.Load(),
+			truncations: h.traffic.truncations,
+			capture:     h.captureInfo(),
+			delay:       time.Duration(h.captureStats.delayTotal.Load()).Seconds(),
+		})
+	}
+	storage := s.storage
+	s.mu.Unlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].name < sessions[j].name })
+	for i := range sessions {
+		sessions[i].stored, _ = storage.Size(sessions[i].name) // gone since: report 0
+	}
+
+	mw := metricsWriter{w: w}
+
+	s.metrics.mu.Lock()
+	actions := make([]string, 0, len(s.metrics.requests))
+	for action := range s.metrics.requests {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	mw.header("shelli_requests_total", "counter", "Requests handled, by action and result.")
+	for _, action := range actions {
+		stat := s.metrics.requests[action]
+		mw.sample("shelli_requests_total", stat.ok, "action", action, "result", "ok")
+		mw.sample("shelli_requests_total", stat.failed, "action", action, "result", "error")
+	}
+	mw.header("shelli_request_duration_seconds", "histogram", "Time to handle a request, by action.")
+	for _, action := range actions {
+		stat := s.metrics.requests[action]
+		for i, le := range requestBuckets {
+			mw.sample("shelli_request_duration_seconds_bucket", stat.buckets[i], "action", action, "le", fmt.Sprint(le))
+		}
+		mw.sample("shelli_request_duration_seconds_bucket", stat.ok+stat.failed, "action", action, "le", "+Inf")
+		mw.sample("shelli_request_duration_seconds_sum", stat.sum, "action", action)
+		mw.sample("shelli_request_duration_seconds_count", stat.ok+stat.failed, "action", action)
+	}
+	waits := make([][2]string, 0, len(s.metrics.waits))
+	for key := range s.metrics.waits {
+		waits = append(waits, key)
+	}
+	sort.Slice(waits, func(i, j int) bool {
+		return waits[i][0] < waits[j][0] || waits[i][0] == waits[j][0] && waits[i][1] < waits[j][1]
+	})
+	mw.header("shelli_waits_total", "counter", "Waits by kind (exec, wait_any, wait_exit) and outcome (completed, timeout, error).")
+	for _, key := range waits {
+		mw.sample("shelli_waits_total", s.metrics.waits[key], "kind", key[0], "outcome", key[1])
+	}
+	mw.header("shelli_rate_limited_total", "counter", "Requests refused by the session or client rate limit.")
+	for _, limit := range []string{"client", "session"} {
+		mw.sample("shelli_rate_limited_total", s.metrics.throttled[limit], "limit", limit)
+	}
+	s.metrics.mu.Unlock()
+
+	if mem, ok := storage.(*MemoryStorage); ok {
+		stats := mem.Stats()
+		mw.header("shelli_memory_bytes", "gauge", "Output the memory backend holds in memory, all sessions together.")
+		mw.sample("shelli_memory_bytes", stats.Bytes)
+		mw.header("shelli_memory_budget_bytes", "gauge", "The memory backend's budget for all sessions' output (max_memory; 0: unbounded).")
+		mw.sample("shelli_memory_budget_bytes", stats.Budget)
+		mw.header("shelli_memory_spilled_bytes", "gauge", "Output the memory budget moved to disk.")
+		mw.sample("shelli_memory_spilled_bytes", stats.Spilled)
+		policies := []string{EvictionSpill, EvictionTruncate}
+		mw.header("shelli_memory_evictions_total", "counter", "Evictions by the memory budget, by policy (spill, truncate).")
+		for _, policy := range policies {
+			mw.sample("shelli_memory_evictions_total", s.memCounters.evictions[evictionIndex(policy)].Load(), "policy", policy)
+		}
+		mw.header("shelli_memory_evicted_bytes_total", "counter", "Output spilled or dropped by the memory budget, by policy.")
+		for _, policy := range policies {
+			mw.sample("shelli_memory_evicted_bytes_total", s.memCounters.bytes[evictionIndex(policy)].Load(), "policy", policy)
+		}
+	}
+
+	states := map[SessionState]int{StateRunning: 0, StateStopped: 0}
+	for _, sess := range sessions {
+		states[sess.state]++
+	}
+	mw.header("shelli_sessions", "gauge", "Sessions by state.")
+	for _, state := range []SessionState{StateRunning, StateStopped} {
+		mw.sample("shelli_sessions", states[state], "state", string(state))
+	}
+	mw.header("shelli_session_pty_read_bytes_total", "counter", "Bytes read from the session's PTY (program output).")
+	for _, sess := range sessions {
+		mw.sample("shelli_session_pty_read_bytes_total", sess.ptyIn, "session", sess.name)
+	}
+	mw.header("shelli_session_pty_written_bytes_total", "counter", "Bytes written to the session's PTY (input).")
+	for _, sess := range sessions {
+		mw.sample("shelli_session_pty_written_bytes_total", sess.ptyOut, "session", sess.name)
+	}
+	mw.header("shelli_session_truncations_total", "counter", "Truncations reported to the session's readers (output dropped before it was read).")
+	for _, sess := range sessions {
+		mw.sample("shelli_session_truncations_total", sess.truncations, "session", sess.name)
+	}
+	mw.header("shelli_session_storage_bytes", "gauge", "Output the session has in storage.")
+	for _, sess := range sessions {
+		mw.sample("shelli_session_storage_bytes", sess.stored, "session", sess.name)
+	}
+	mw.header("shelli_session_capture_reads_total", "counter", "PTY reads made by the session's capture, by capture priority.")
+	for _, sess := range sessions {
+		mw.sample("shelli_session_capture_reads_total", sess.capture.Reads, "session", sess.name, "priority", sess.capture.Priority)
+	}
+	mw.header("shelli_session_capture_batches_total", "counter", "Batches of output the session's capture stored (reads coalesce into one under normal and bulk priority).")
+	for _, sess := range sessions {
+		mw.sample("shelli_session_capture_batches_total", sess.capture.Batches, "session", sess.name, "priority", sess.capture.Priority)
+	}
+	mw.header("shelli_session_capture_delay_seconds_total", "counter", "Time from a batch's first PTY read to it being stored, summed over the session's batches.")
+	for _, sess := range sessions {
+		mw.sample("shelli_session_capture_delay_seconds_total", sess.delay, "session", sess.name, "priority", sess.capture.Priority)
+	}
+}
+
+// handleMetrics returns the metrics as Prometheus text.
+func (s *Server) handleMetrics() Response {
+	var buf bytes.Buffer
+	s.writeMetrics(&buf)
+	return Response{Success: true, Data: buf.String()}
+}
+
+// ServeMetrics serves the metrics over HTTP at addr (host:port) under
+// MetricsPath, for Prometheus or an OpenTelemetry collector to scrape, until
+// the daemon shuts down. It returns the address listened on.
+func (s *Server) ServeMetrics(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("metrics listen: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(MetricsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		s.writeMetrics(w)
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	s.mu.Lock()
+	s.metricsServer = srv
+	s.mu.Unlock()
+
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("metrics: %v", err)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
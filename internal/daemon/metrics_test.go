@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("m", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("m")
+
+	if err := client.Send("m", "echo metered", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "m", "metered\r\n")
+	if _, err := client.WaitExit("m", 1); err == nil {
+		t.Fatal("wait exit should time out")
+	}
+	client.send(Request{Action: "bogus"}) //nolint:errcheck
+
+	text, err := client.Metrics()
+	if err != nil {
+		t.Fatalf("Metrics: %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE shelli_request_duration_seconds histogram\n",
+		`shelli_requests_total{action="create",result="ok"} 1` + "\n",
+		`shelli_requests_total{action="unknown",result="error"} 1` + "\n",
+		`shelli_request_duration_seconds_bucket{action="send",le="+Inf"} 1` + "\n",
+		`shelli_waits_total{kind="wait_exit",outcome="timeout"} 1` + "\n",
+		`shelli_sessions{state="running"} 1` + "\n",
+		`shelli_session_pty_written_bytes_total{session="m"} 13` + "\n",
+		`shelli_session_truncations_total{session="m"} 0` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics lack %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "bogus") {
+		t.Error("unknown actions should not get their own label")
+	}
+	if strings.Contains(text, `shelli_session_storage_bytes{session="m"} 0`) {
+		t.Error("storage size should count the output")
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	srv, err := NewServer(WithStorage(NewMemoryStorage(1024)), WithSocketDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	addr, err := srv.ServeMetrics("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ServeMetrics: %v", err)
+	}
+	defer srv.Shutdown()
+
+	resp, err := http.Get("http://" + addr + MetricsPath)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(string(body), `shelli_sessions{state="running"} 0`) {
+		t.Errorf("body = %q", body)
+	}
+
+	if _, err := srv.ServeMetrics("bad address"); err == nil {
+		t.Error("ServeMetrics with a bad address should fail")
+	}
+}
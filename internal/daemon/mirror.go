@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Mirrored input is stored inline as MirrorOpen + input + MirrorClose:
+// reverse video on a terminal, ⟦input: ...⟧ once ANSI is stripped. Control
+// characters in the input are written as escapes (\r, \n, \t, \e, \xNN), so
+// a record never contains ESC before its end.
+const (
+	MirrorOpen  = "\x1b[7m⟦input: "
+	MirrorClose = "⟧\x1b[27m"
+)
+
+var mirrorPattern = regexp.MustCompile(regexp.QuoteMeta(MirrorOpen) + `[^\x1b]*` + regexp.QuoteMeta(MirrorClose))
+
+// mirrorRecord formats input sent to a session for its buffer.
+func mirrorRecord(input string) string {
+	var b strings.Builder
+	b.WriteString(MirrorOpen)
+	for _, r := range input {
+		switch {
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == 0x1b:
+			b.WriteString(`\e`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString(MirrorClose)
+	return b.String()
+}
+
+// RemoveInputMirror drops mirrored input records from output, so patterns
+// only match what the program printed.
+func RemoveInputMirror(output string) string {
+	if !strings.Contains(output, MirrorOpen) {
+		return output
+	}
+	return mirrorPattern.ReplaceAllString(output, "")
+}
+
+// withoutInputMirror is RemoveInputMirror for byte offsets: it returns data
+// without mirror records and a function mapping an offset in that back to
+// one in data.
+func withoutInputMirror(data []byte) ([]byte, func(int) int) {
+	spans := mirrorPattern.FindAllIndex(data, -1)
+	if spans == nil {
+		return data, func(i int) int { return i }
+	}
+	kept := make([]byte, 0, len(data))
+	prev := 0
+	for _, sp := range spans {
+		kept = append(kept, data[prev:sp[0]]...)
+		prev = sp[1]
+	}
+	kept = append(kept, data[prev:]...)
+	return kept, func(i int) int {
+		shift := 0
+		for _, sp := range spans {
+			if sp[0]-shift >= i {
+				break
+			}
+			shift += sp[1] - sp[0]
+		}
+		return i + shift
+	}
+}
+
+// handleMirrorInput turns input mirroring on or off for a running session.
+func (s *Server) handleMirrorInput(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if h.state != StateRunning {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q is stopped", req.Name)}
+	}
+	if h.screen != nil && req.MirrorInput {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q is in TUI mode (input mirroring needs the output buffer)", req.Name)}
+	}
+	h.mirrorInput = req.MirrorInput
+	storage := s.storage
+	s.mu.Unlock()
+
+	storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+		m.MirrorInput = req.MirrorInput
+	})
+	return Response{Success: true, Data: map[string]interface{}{
+		"name":         req.Name,
+		"mirror_input": req.MirrorInput,
+	}}
+}
@@ -0,0 +1,100 @@
+package daemon
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMirrorRecord(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"ls -la\n", MirrorOpen + `ls -la\n` + MirrorClose},
+		{"secret\r", MirrorOpen + `secret\r` + MirrorClose},
+		{"\x1b[A\x03\t", MirrorOpen + `\e[A\x03\t` + MirrorClose},
+		{"héllo", MirrorOpen + "héllo" + MirrorClose},
+	}
+	for _, tt := range tests {
+		if got := mirrorRecord(tt.input); got != tt.want {
+			t.Errorf("mirrorRecord(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveInputMirror(t *testing.T) {
+	output := "Password: " + mirrorRecord("hunter2\n") + "\r\nok\r\n$ " + mirrorRecord("\x1b")
+	if got := RemoveInputMirror(output); got != "Password: \r\nok\r\n$ " {
+		t.Errorf("RemoveInputMirror = %q", got)
+	}
+
+	printed, offset := withoutInputMirror([]byte(output))
+	i := strings.Index(string(printed), "ok")
+	if got := output[offset(i) : offset(i)+2]; got != "ok" {
+		t.Errorf("offset(%d) = %d points at %q, want ok", i, offset(i), got)
+	}
+	if offset(0) != 0 {
+		t.Errorf("offset(0) = %d", offset(0))
+	}
+}
+
+func TestAnyScannerSkipsMirroredInput(t *testing.T) {
+	storage := NewMemoryStorage(0)
+	storage.Create("s", &SessionMeta{Name: "s"})
+	scanner := &anyScanner{
+		storage: storage,
+		re:      regexp.MustCompile(`DONE`),
+		pos:     map[string]int64{},
+		carry:   map[string][]byte{},
+	}
+
+	storage.Append("s", []byte(mirrorRecord("echo DONE\n")))
+	if _, _, ok := scanner.scan("s"); ok {
+		t.Fatal("matched mirrored input")
+	}
+	storage.Append("s", []byte("DONE\r\n"))
+	data, _ := storage.ReadAll("s")
+	match, position, ok := scanner.scan("s")
+	if !ok || match != "DONE" || position != int64(strings.LastIndex(string(data), "DONE")+4) {
+		t.Errorf("scan = %q, %d, %v", match, position, ok)
+	}
+}
+
+func TestMirrorInput(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("mirror", CreateOptions{Command: "sh", MirrorInput: true}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("mirror")
+
+	if err := client.Send("mirror", "stty -echo", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	result, err := client.Exec("mirror", ExecOptions{Input: "echo $((6*7))", WaitPattern: `42`})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	record := mirrorRecord("echo $((6*7))\n")
+	if i := strings.Index(result.Output, record); i < 0 || i > strings.Index(result.Output, "42") {
+		t.Errorf("output %q should record the input before its result", result.Output)
+	}
+
+	if err := client.MirrorInput("mirror", false); err != nil {
+		t.Fatalf("MirrorInput: %v", err)
+	}
+	result, err = client.Exec("mirror", ExecOptions{Input: "echo $((7*7))", WaitPattern: `49`})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if strings.Contains(result.Output, MirrorOpen) {
+		t.Errorf("output %q recorded input after mirroring was turned off", result.Output)
+	}
+
+	if _, err := client.Create("mirror-tui", CreateOptions{Command: "sh", TUIMode: true, MirrorInput: true}); err == nil {
+		client.Kill("mirror-tui")
+		t.Error("Create with TUI mode and input mirroring should fail")
+	}
+}
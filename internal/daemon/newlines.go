@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Newline modes for reads and searches. Stored output mixes CRLF (from the
+// PTY), lone LF and lone CR (progress bars redrawing a line), so line-based
+// operations count lines differently from what a terminal shows. A mode
+// other than raw normalizes output before head/tail limits and search.
+const (
+	NewlinesRaw     = "raw"     // as stored (default)
+	NewlinesLF      = "lf"      // CRLF and lone CR become LF
+	NewlinesDisplay = "display" // CRLF becomes LF; text after a lone CR overwrites the line from its start, as on screen
+)
+
+var newlineModes = []string{NewlinesRaw, NewlinesLF, NewlinesDisplay}
+
+// ValidateNewlines checks a newline mode; "" means raw.
+func ValidateNewlines(mode string) error {
+	switch mode {
+	case "", NewlinesRaw, NewlinesLF, NewlinesDisplay:
+		return nil
+	}
+	return fmt.Errorf("unknown newlines mode %q (valid: %s)", mode, strings.Join(newlineModes, ", "))
+}
+
+// NormalizeNewlines rewrites the line endings of s for mode. Display mode
+// overlays by character, so escape sequences in overwritten text can make
+// it approximate; strip ANSI first or use a TUI session for exact screens.
+func NormalizeNewlines(s, mode string) string {
+	switch mode {
+	case NewlinesLF:
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+		return strings.ReplaceAll(s, "\r", "\n")
+	case NewlinesDisplay:
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+		if !strings.Contains(s, "\r") {
+			return s
+		}
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			lines[i] = overlayCR(line)
+		}
+		return strings.Join(lines, "\n")
+	}
+	return s
+}
+
+// overlayCR renders a line containing carriage returns: each segment is
+// written from column 0 over what came before.
+func overlayCR(line string) string {
+	if !strings.Contains(line, "\r") {
+		return line
+	}
+	var out []rune
+	for _, seg := range strings.Split(line, "\r") {
+		r := []rune(seg)
+		if len(r) >= len(out) {
+			out = r
+		} else {
+			copy(out, r)
+		}
+	}
+	return string(out)
+}
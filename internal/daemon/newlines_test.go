@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeNewlines(t *testing.T) {
+	in := "one\r\ntwo\nprogress 10%\rprogress 100%\r\nabc\rX\r"
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", in},
+		{NewlinesRaw, in},
+		{NewlinesLF, "one\ntwo\nprogress 10%\nprogress 100%\nabc\nX\n"},
+		{NewlinesDisplay, "one\ntwo\nprogress 100%\nXbc"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeNewlines(in, tt.mode); got != tt.want {
+			t.Errorf("NormalizeNewlines(%q) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestValidateNewlines(t *testing.T) {
+	if err := ValidateNewlines("crlf"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+	for _, mode := range []string{"", NewlinesRaw, NewlinesLF, NewlinesDisplay} {
+		if err := ValidateNewlines(mode); err != nil {
+			t.Errorf("ValidateNewlines(%q): %v", mode, err)
+		}
+	}
+}
+
+func TestReadAndSearchNewlines(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("nl", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("nl")
+
+	if err := client.Send("nl", `printf 'step 1\rstep 2\rdone-marker\n'`, true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "nl", "done-marker\r\n")
+	time.Sleep(100 * time.Millisecond)
+
+	result, err := client.ReadNormalized("nl", ReadModeAll, "", NewlinesDisplay, 0, 2)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if strings.Contains(result.Output, "\r") {
+		t.Errorf("display read still has CRs: %q", result.Output)
+	}
+	if !strings.Contains(result.Output, "done-marker\n") || strings.Contains(result.Output, "\nstep 1") {
+		t.Errorf("display read = %q, want the overwritten line only", result.Output)
+	}
+
+	lf, err := client.Search(SearchRequest{Name: "nl", Pattern: "^step 2$", Newlines: NewlinesLF})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(lf.Matches) != 1 {
+		t.Errorf("lf search matches = %d, want 1", len(lf.Matches))
+	}
+	display, err := client.Search(SearchRequest{Name: "nl", Pattern: "^step 2$", Newlines: NewlinesDisplay})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(display.Matches) != 0 {
+		t.Errorf("display search matched overwritten text: %+v", display.Matches)
+	}
+
+	if _, err := client.ReadNormalized("nl", ReadModeAll, "", "crlf", 0, 0); err == nil {
+		t.Error("expected error for unknown newlines mode")
+	}
+}
@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNotifications(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cmd := `printf 'start\007 \033]9;Build finished\007 \033]0;title\007end\n'`
+	if _, err := client.Create("bell", CreateOptions{Command: cmd}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	output := waitForOutput(t, client, "bell", "end")
+	if strings.Contains(output, "Build finished") || strings.Contains(output, "start\x07") {
+		t.Errorf("output still contains notifications: %q", output)
+	}
+	if !strings.Contains(output, "\x1b]0;title\x07") {
+		t.Errorf("title OSC should be kept: %q", output)
+	}
+
+	notes, err := client.Notifications("bell", 0)
+	if err != nil {
+		t.Fatalf("Notifications: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("got %d notifications, want 2: %+v", len(notes), notes)
+	}
+	if notes[0].Kind != "bell" || notes[1].Kind != "notify" || notes[1].Body != "Build finished" {
+		t.Errorf("notifications = %+v", notes)
+	}
+
+	newer, err := client.Notifications("bell", notes[0].ID)
+	if err != nil {
+		t.Fatalf("Notifications after: %v", err)
+	}
+	if len(newer) != 1 || newer[0].ID != notes[1].ID {
+		t.Errorf("after %d: got %+v", notes[0].ID, newer)
+	}
+}
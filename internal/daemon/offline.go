@@ -0,0 +1,100 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// OfflineResult is a read served straight from FileStorage.
+type OfflineResult struct {
+	Output             string
+	Position           int64
+	State              SessionState
+	Cols               int
+	LongLinesTruncated int
+}
+
+// DefaultDataDir returns the directory the daemon keeps session files in
+// when started without --data-dir.
+func DefaultDataDir() (string, error) {
+	runtimeDir, err := RuntimeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runtimeDir, "data"), nil
+}
+
+// OfflineRead reads a session's buffer from the files under dataDir without
+// a daemon, for post-mortem inspection. It never writes: the read position
+// and cursors are used but not advanced. Sealed output segments are read
+// first, then the live output file under a shared lock so a daemon writing
+// at the same time is not torn. Sessions
+// kept by a memory-backed daemon are not on disk and cannot be read.
+func OfflineRead(dataDir, name, mode, cursor string, headLines, tailLines int) (*OfflineResult, error) {
+	if err := ValidateSessionName(name); err != nil {
+		return nil, err
+	}
+
+	meta, err := (&FileStorage{dataDir: dataDir}).LoadMeta(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for _, seg := range scanSegments(dataDir, name) {
+		sealed, err := readSegmentFrom(segmentFile(dataDir, name, seg), seg, 0)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, sealed...)
+	}
+	live, err := readOutputShared(filepath.Join(dataDir, name+".out"))
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, live...)
+
+	result := &OfflineResult{
+		Position: int64(len(data)),
+		State:    meta.State,
+		Cols:     meta.Cols,
+	}
+
+	output := data
+	if mode == "" || mode == ReadModeNew {
+		readPos := meta.ReadPos
+		if cursor != "" {
+			readPos = meta.Cursors[cursor]
+		}
+		output = data[min(readPos, int64(len(data))):]
+	}
+
+	result.Output = string(output)
+	if headLines > 0 || tailLines > 0 {
+		result.Output, result.LongLinesTruncated = limitLines(result.Output, headLines, tailLines)
+	}
+	return result, nil
+}
+
+func readOutputShared(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []byte{}, nil
+		}
+		return nil, fmt.Errorf("open output file: %w", err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f, syscall.LOCK_SH); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("read output: %w", err)
+	}
+	return data, nil
+}
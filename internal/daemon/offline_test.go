@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"testing"
+)
+
+func TestOfflineRead(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := &SessionMeta{Name: "dead", State: StateStopped, Cols: 80, ReadPos: 6}
+	if err := storage.Create("dead", meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Append("dead", []byte("first\nsecond\nthird\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.UpdateMeta("dead", func(m *SessionMeta) {
+		m.Cursors = map[string]int64{"agent": 13}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		mode   string
+		cursor string
+		tail   int
+		want   string
+	}{
+		{"new", ReadModeNew, "", 0, "second\nthird\n"},
+		{"cursor", ReadModeNew, "agent", 0, "third\n"},
+		{"unknown cursor", ReadModeNew, "other", 0, "first\nsecond\nthird\n"},
+		{"all", ReadModeAll, "", 0, "first\nsecond\nthird\n"},
+		{"tail", ReadModeAll, "", 2, "third\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := OfflineRead(dir, "dead", tt.mode, tt.cursor, 0, tt.tail)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Output != tt.want {
+				t.Errorf("output = %q, want %q", result.Output, tt.want)
+			}
+			if result.Position != 19 {
+				t.Errorf("position = %d, want 19", result.Position)
+			}
+			if result.State != StateStopped || result.Cols != 80 {
+				t.Errorf("state = %q, cols = %d", result.State, result.Cols)
+			}
+		})
+	}
+
+	after, err := storage.LoadMeta("dead")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.ReadPos != 6 || after.Cursors["agent"] != 13 {
+		t.Errorf("offline read moved positions: read_pos=%d cursors=%v", after.ReadPos, after.Cursors)
+	}
+}
+
+func TestOfflineReadMissing(t *testing.T) {
+	if _, err := OfflineRead(t.TempDir(), "nope", ReadModeAll, "", 0, 0); err == nil {
+		t.Fatal("expected error for missing session")
+	}
+	if _, err := OfflineRead(t.TempDir(), "../etc", ReadModeAll, "", 0, 0); err == nil {
+		t.Fatal("expected error for invalid session name")
+	}
+}
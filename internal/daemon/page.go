@@ -0,0 +1,248 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PageMaxBytes bounds a page of a read limited by max_lines alone, so a
+// buffer of few, huge lines still comes back in pieces.
+const PageMaxBytes = 1 << 20
+
+// OutputPage is the part of a long output kept by PageOutput, and where the
+// rest is.
+type OutputPage struct {
+	Shown string
+	// OmittedOffset and OmittedBytes locate the dropped bytes within the
+	// paged output.
+	OmittedOffset int
+	OmittedBytes  int
+	OmittedLines  int
+}
+
+// PageOutput keeps at most limit bytes of output: the end of it, or the
+// start when keepHead is set. The cut is moved to a line boundary when the
+// kept part has one, so no partial line is shown. Output within the limit
+// is returned whole.
+func PageOutput(output string, limit int, keepHead bool) OutputPage {
+	if limit <= 0 || len(output) <= limit {
+		return OutputPage{Shown: output}
+	}
+
+	if keepHead {
+		end := limit
+		if nl := strings.LastIndexByte(output[:end], '\n'); nl >= 0 {
+			end = nl + 1
+		} else {
+			for end > 0 && !utf8.RuneStart(output[end]) {
+				end--
+			}
+		}
+		omitted := output[end:]
+		return OutputPage{
+			Shown:         output[:end],
+			OmittedOffset: end,
+			OmittedBytes:  len(omitted),
+			OmittedLines:  countLines(omitted),
+		}
+	}
+
+	start := len(output) - limit
+	if output[start-1] != '\n' {
+		if nl := strings.IndexByte(output[start:len(output)-1], '\n'); nl >= 0 {
+			start += nl + 1
+		} else {
+			for start < len(output) && !utf8.RuneStart(output[start]) {
+				start++
+			}
+		}
+	}
+	omitted := output[:start]
+	return OutputPage{
+		Shown:        output[start:],
+		OmittedBytes: len(omitted),
+		OmittedLines: countLines(omitted),
+	}
+}
+
+// readPage reads at most maxBytes and maxLines lines of a session's output
+// from offset (all of it when both are 0). A page ends after a line break
+// when maxLines cuts it, else before any UTF-8 character the byte limit
+// would split, so the next page starts with it whole.
+func readPage(storage OutputStorage, name string, offset, maxBytes int64, maxLines int) ([]byte, error) {
+	if maxLines > 0 && maxBytes <= 0 {
+		maxBytes = PageMaxBytes
+	}
+	data, err := storage.ReadRange(name, offset, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if maxLines > 0 {
+		if end := lineEnd(data, maxLines); end >= 0 {
+			return data[:end], nil
+		}
+	}
+	if maxBytes <= 0 || int64(len(data)) < maxBytes {
+		return data, nil
+	}
+	for i := len(data) - 1; i > 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i], nil
+			}
+			break
+		}
+	}
+	return data, nil
+}
+
+// lineEnd returns the index just past the n-th line break in data, or -1
+// if it has fewer.
+func lineEnd(data []byte, n int) int {
+	end := 0
+	for i := 0; i < n; i++ {
+		nl := bytes.IndexByte(data[end:], '\n')
+		if nl < 0 {
+			return -1
+		}
+		end += nl + 1
+	}
+	return end
+}
+
+// pageToken is where a paged read or search goes on, handed to clients as
+// an opaque continuation token (next) that they pass back as continue. It
+// carries the request's paging and query settings, so the token alone gets
+// the next page.
+type pageToken struct {
+	Action string `json:"a"`
+	Name   string `json:"n"`
+	// Offset is the byte position of a read's next page, or how many
+	// matches a search has returned.
+	Offset int64 `json:"o,omitempty"`
+
+	// read: a new read (New) goes on from the reader's position, which its
+	// pages move; other reads go on from Offset without moving it.
+	New      bool   `json:"new,omitempty"`
+	Cursor   string `json:"c,omitempty"`
+	MaxBytes int64  `json:"mb,omitempty"`
+	MaxLines int    `json:"ml,omitempty"`
+
+	// search
+	Limit      int64  `json:"l,omitempty"`
+	Pattern    string `json:"p,omitempty"`
+	Before     int    `json:"b,omitempty"`
+	After      int    `json:"af,omitempty"`
+	IgnoreCase bool   `json:"i,omitempty"`
+	StripANSI  bool   `json:"s,omitempty"`
+	Render     bool   `json:"r,omitempty"`
+
+	Newlines string `json:"nl,omitempty"`
+}
+
+func (t pageToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageToken parses a continue token, which must come from the same
+// action on the same session.
+func decodePageToken(token, action, name string) (pageToken, error) {
+	var t pageToken
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(data, &t)
+	}
+	if err != nil {
+		return t, fmt.Errorf("invalid continue token")
+	}
+	if t.Action != action || t.Name != name {
+		return t, fmt.Errorf("continue token is for %s of session %q, not %s of %q", t.Action, t.Name, action, name)
+	}
+	return t, nil
+}
+
+// continueRead turns a read with this token as continue into a read of
+// the next page.
+func (t pageToken) continueRead(req Request) Request {
+	if t.New {
+		req.Mode, req.Cursor = ReadModeNew, t.Cursor
+	} else {
+		req.Mode, req.Offset, req.Limit = ReadModeRange, t.Offset, 0
+	}
+	if req.MaxBytes == 0 && req.MaxLines == 0 {
+		req.MaxBytes, req.MaxLines = t.MaxBytes, t.MaxLines
+	}
+	if req.Newlines == "" {
+		req.Newlines = t.Newlines
+	}
+	return req
+}
+
+// nextReadToken is the token for the page of a read after the one ending
+// at position.
+func nextReadToken(req Request, mode string, position int64) pageToken {
+	return pageToken{
+		Action:   "read",
+		Name:     req.Name,
+		Offset:   position,
+		New:      mode == ReadModeNew,
+		Cursor:   req.Cursor,
+		MaxBytes: req.MaxBytes,
+		MaxLines: req.MaxLines,
+		Newlines: req.Newlines,
+	}
+}
+
+// continueSearch turns a search with this token as continue into a search
+// for the next page of matches.
+func (t pageToken) continueSearch(req Request) Request {
+	req.Pattern, req.Before, req.After = t.Pattern, t.Before, t.After
+	req.IgnoreCase, req.StripANSI, req.Newlines = t.IgnoreCase, t.StripANSI, t.Newlines
+	req.Render = t.Render
+	req.Offset = t.Offset
+	if req.Limit == 0 {
+		req.Limit = t.Limit
+	}
+	return req
+}
+
+// nextSearchToken is the token for the matches of a search after the
+// first shown.
+func nextSearchToken(req Request, shown int64) pageToken {
+	return pageToken{
+		Action:     "search",
+		Name:       req.Name,
+		Offset:     shown,
+		Limit:      req.Limit,
+		Pattern:    req.Pattern,
+		Before:     req.Before,
+		After:      req.After,
+		IgnoreCase: req.IgnoreCase,
+		StripANSI:  req.StripANSI,
+		Render:     req.Render,
+		Newlines:   req.Newlines,
+	}
+}
+
+// setPage adds a page's more flag and, when there is more, its next token
+// to response data.
+func setPage(data map[string]interface{}, next string) {
+	data["more"] = next != ""
+	if next != "" {
+		data["next"] = next
+	}
+}
+
+// countLines counts lines in s, including a final one without a newline.
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,237 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPageOutput(t *testing.T) {
+	output := "line1\nline2\nline3\nline4\n"
+
+	tests := []struct {
+		name     string
+		limit    int
+		keepHead bool
+		want     OutputPage
+	}{
+		{"fits", 100, false, OutputPage{Shown: output}},
+		{"no limit", 0, false, OutputPage{Shown: output}},
+		{"tail on line boundary", 12, false, OutputPage{Shown: "line3\nline4\n", OmittedBytes: 12, OmittedLines: 2}},
+		{"tail skips partial line", 14, false, OutputPage{Shown: "line3\nline4\n", OmittedBytes: 12, OmittedLines: 2}},
+		{"head on line boundary", 8, true, OutputPage{Shown: "line1\n", OmittedOffset: 6, OmittedBytes: 18, OmittedLines: 3}},
+		{"tail single long line", 3, false, OutputPage{Shown: "e4\n", OmittedBytes: 21, OmittedLines: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PageOutput(output, tt.limit, tt.keepHead); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageOutputRuneBoundary(t *testing.T) {
+	output := strings.Repeat("é", 10) // 2 bytes each, no newline
+	head := PageOutput(output, 5, true)
+	if head.Shown != "éé" || head.OmittedOffset != 4 {
+		t.Errorf("head = %+v", head)
+	}
+	tail := PageOutput(output, 5, false)
+	if tail.Shown != "éé" || tail.OmittedBytes != 16 {
+		t.Errorf("tail = %+v", tail)
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("range", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("range")
+	if err := client.Send("range", "echo range-marker", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "range", "range-marker\r\n")
+
+	all, size, err := client.Read("range", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	got, pos, err := client.ReadRange("range", 2, 5)
+	if err != nil {
+		t.Fatalf("ReadRange: %v", err)
+	}
+	if got != all[2:7] || pos != 7 {
+		t.Errorf("ReadRange(2, 5) = %q, %d; want %q, 7", got, pos, all[2:7])
+	}
+
+	rest, pos, err := client.ReadRange("range", 2, 0)
+	if err != nil {
+		t.Fatalf("ReadRange: %v", err)
+	}
+	if rest != all[2:] || pos != size {
+		t.Errorf("ReadRange(2, 0) = %q, %d", rest, pos)
+	}
+
+	// Range reads leave the read position alone.
+	unread, _, err := client.Read("range", ReadModeNew, 0, 0)
+	if err != nil {
+		t.Fatalf("Read new: %v", err)
+	}
+	if !strings.Contains(unread, "range-marker") {
+		t.Errorf("new output after range reads = %q", unread)
+	}
+}
+
+func TestReadPage(t *testing.T) {
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("page", CreateOptions{Command: "sleep 60"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("page")
+	term := fake.terminal(t)
+	term.Write([]byte("abcdéfgh")) // é is bytes 4-5
+	waitForOutput(t, client, "page", "fgh")
+
+	// A page of all output leaves the read position alone.
+	first, err := client.ReadPage("page", PageOptions{Mode: ReadModeAll, MaxBytes: 3})
+	if err != nil {
+		t.Fatalf("ReadPage all: %v", err)
+	}
+	if first.Output != "abc" || first.Position != 3 || !first.More {
+		t.Errorf("all page = %+v, want abc up to 3 and more", first)
+	}
+
+	// New reads page through the backlog; the cut moves back off the é.
+	pages := []ReadResult{
+		{Output: "abcd", Position: 4, More: true},
+		{Output: "éfgh", Position: 9},
+		{Output: "", Position: 9},
+	}
+	for i, want := range pages {
+		got, err := client.ReadPage("page", PageOptions{Mode: ReadModeNew, MaxBytes: 5})
+		if err != nil {
+			t.Fatalf("ReadPage new %d: %v", i, err)
+		}
+		if (got.Next != "") != want.More {
+			t.Errorf("new page %d next = %q, want one only when there is more", i, got.Next)
+		}
+		got.Next = ""
+		if *got != want {
+			t.Errorf("new page %d = %+v, want %+v", i, *got, want)
+		}
+	}
+
+	got, err := client.ReadPage("page", PageOptions{Mode: ReadModeRange, Offset: 6, MaxBytes: 2})
+	if err != nil {
+		t.Fatalf("ReadPage range: %v", err)
+	}
+	if got.Output != "fg" || got.Position != 8 || !got.More {
+		t.Errorf("range page = %+v, want fg up to 8 and more", got)
+	}
+
+	if _, err := client.ReadPage("page", PageOptions{Mode: ReadModeTail, MaxBytes: 5}); err == nil {
+		t.Error("max_bytes tail read succeeded")
+	}
+}
+
+func TestReadPageContinue(t *testing.T) {
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("lines", CreateOptions{Command: "sleep 60"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("lines")
+	term := fake.terminal(t)
+	term.Write([]byte("one\ntwo\nthree\nfour\nfive"))
+	waitForOutput(t, client, "lines", "five")
+
+	// An all read pages by lines; each next token gets the page after it.
+	var got []string
+	opts := PageOptions{Mode: ReadModeAll, MaxLines: 2}
+	for i := 0; ; i++ {
+		page, err := client.ReadPage("lines", opts)
+		if err != nil {
+			t.Fatalf("ReadPage %d: %v", i, err)
+		}
+		got = append(got, page.Output)
+		if page.More != (page.Next != "") {
+			t.Fatalf("page %d: more = %v, next = %q", i, page.More, page.Next)
+		}
+		if !page.More {
+			break
+		}
+		opts = PageOptions{Continue: page.Next}
+	}
+	want := []string{"one\ntwo\n", "three\nfour\n", "five"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+
+	// All reads leave the read position alone.
+	unread, _, err := client.Read("lines", ReadModeNew, 0, 0)
+	if err != nil {
+		t.Fatalf("Read new: %v", err)
+	}
+	if !strings.HasPrefix(unread, "one") {
+		t.Errorf("new output after all pages = %q", unread)
+	}
+
+	if _, err := client.ReadPage("lines", PageOptions{Continue: "bogus"}); err == nil {
+		t.Error("read with a bogus continue token succeeded")
+	}
+	if _, err := client.ReadPage("other", PageOptions{Continue: pageToken{Action: "read", Name: "lines"}.encode()}); err == nil {
+		t.Error("read of another session's token succeeded")
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("hits", CreateOptions{Command: "sleep 60"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("hits")
+	term := fake.terminal(t)
+	term.Write([]byte("hit 1\nmiss\nHIT 2\nhit 3\ndone"))
+	waitForOutput(t, client, "hits", "done")
+
+	first, err := client.Search(SearchRequest{Name: "hits", Pattern: "hit", IgnoreCase: true, Limit: 2})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(first.Matches) != 2 || first.TotalMatches != 3 || first.Next == "" {
+		t.Fatalf("first page = %+v, want 2 of 3 matches and next", first)
+	}
+
+	// The token carries the pattern and ignore_case.
+	rest, err := client.Search(SearchRequest{Name: "hits", Continue: first.Next})
+	if err != nil {
+		t.Fatalf("Search continue: %v", err)
+	}
+	if len(rest.Matches) != 1 || rest.Matches[0].LineNumber != 4 || rest.TotalMatches != 3 || rest.Next != "" {
+		t.Errorf("second page = %+v, want match at line 4 and no next", rest)
+	}
+
+	skipped, err := client.Search(SearchRequest{Name: "hits", Pattern: "hit", Offset: 1})
+	if err != nil {
+		t.Fatalf("Search offset: %v", err)
+	}
+	if len(skipped.Matches) != 1 || skipped.Matches[0].LineNumber != 4 || skipped.Next != "" {
+		t.Errorf("offset search = %+v, want match at line 4 only", skipped)
+	}
+
+	if _, err := client.Search(SearchRequest{Name: "hits", Continue: first.Next, Limit: -1}); err == nil {
+		t.Error("search with negative limit succeeded")
+	}
+}
@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// capturePause lets a session's output be thrown away for a while, e.g.
+// during a noisy download, without stopping the program: the PTY is still
+// drained, only storing is skipped.
+type capturePause struct {
+	paused  atomic.Bool
+	dropped atomic.Int64 // bytes thrown away during the current or last pause
+	total   atomic.Int64 // bytes thrown away over all pauses
+
+	since time.Time // start of the current pause; guarded by Server.mu
+}
+
+// drop counts n bytes of output discarded while paused.
+func (p *capturePause) drop(n int) {
+	p.dropped.Add(int64(n))
+	p.total.Add(int64(n))
+}
+
+// pauseInfo adds the pause state to an info result. Callers hold Server.mu.
+func (h *sessionHandle) pauseInfo(result map[string]interface{}) {
+	if h.pause.paused.Load() {
+		result["paused"] = true
+		result["paused_since"] = h.pause.since.Format(time.RFC3339)
+	}
+	if total := h.pause.total.Load(); total > 0 {
+		result["paused_dropped_bytes"] = total
+	}
+}
+
+// PauseResult reports a resumed session's pause.
+type PauseResult struct {
+	Name         string  `json:"name"`
+	DroppedBytes int64   `json:"dropped_bytes"`
+	PausedFor    float64 `json:"paused_seconds"`
+}
+
+// handlePause stops storing a running session's output until resume.
+func (s *Server) handlePause(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if h.state != StateRunning {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is stopped", req.Name)}
+	}
+	if h.screen != nil {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is in TUI mode (its screen must see all output; nothing piles up to pause)", req.Name)}
+	}
+	if h.pause.paused.Load() {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is already paused", req.Name)}
+	}
+
+	h.pause.dropped.Store(0)
+	h.pause.since = time.Now()
+	h.pause.paused.Store(true)
+	return Response{Success: true, Data: map[string]interface{}{"name": req.Name, "paused": true}}
+}
+
+// handleResume stores a paused session's output again and reports what the
+// pause dropped.
+func (s *Server) handleResume(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if !h.pause.paused.Load() {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is not paused", req.Name)}
+	}
+
+	h.pause.paused.Store(false)
+	return Response{Success: true, Data: PauseResult{
+		Name:         req.Name,
+		DroppedBytes: h.pause.dropped.Load(),
+		PausedFor:    time.Since(h.pause.since).Seconds(),
+	}}
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPauseResume(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("pause", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("pause")
+
+	if err := client.Send("pause", "echo before-$((1+1))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "pause", "before-2")
+
+	if err := client.Pause("pause"); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if err := client.Pause("pause"); err == nil {
+		t.Error("Pause of a paused session should fail")
+	}
+	if err := client.Send("pause", "echo hidden-$((2+2))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	info, err := client.Info("pause")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if !info.Paused || info.PausedSince == "" {
+		t.Errorf("info paused = %v since %q, want paused", info.Paused, info.PausedSince)
+	}
+
+	result, err := client.Resume("pause")
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if result.DroppedBytes == 0 {
+		t.Error("resume reported no dropped bytes")
+	}
+	if _, err := client.Resume("pause"); err == nil {
+		t.Error("Resume of a running session should fail")
+	}
+
+	if err := client.Send("pause", "echo after-$((3+3))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "pause", "after-6")
+
+	all, _, err := client.Read("pause", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if strings.Contains(all, "hidden-4") {
+		t.Errorf("output stored while paused: %q", all)
+	}
+
+	if _, err := client.Create("pause-tui", CreateOptions{Command: "sh", TUIMode: true}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("pause-tui")
+	if err := client.Pause("pause-tui"); err == nil {
+		t.Error("Pause of a TUI session should fail")
+	}
+}
@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// LOCAL_PEERCRED at SOL_LOCAL fills an xucred; the syscall package defines
+// neither.
+const (
+	solLocal      = 0
+	localPeerCred = 1
+)
+
+type xucred struct {
+	Version uint32
+	UID     uint32
+	NGroups int16
+	Groups  [16]uint32
+}
+
+// peerUID returns the user on the other end of a unix socket connection.
+func peerUID(conn *net.UnixConn) (int, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var cred xucred
+	size := uint32(unsafe.Sizeof(cred))
+	var errno syscall.Errno
+	if err := raw.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, solLocal, localPeerCred,
+			uintptr(unsafe.Pointer(&cred)), uintptr(unsafe.Pointer(&size)), 0) // #nosec G103 -- getsockopt needs pointers to the result
+	}); err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(cred.UID), nil
+}
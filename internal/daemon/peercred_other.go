@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package daemon
+
+import "net"
+
+func peerUID(conn *net.UnixConn) (int, error) {
+	return 0, errPeerCredUnsupported
+}
@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// I/O scheduling classes, as in ionice(1).
+const (
+	ioClassNone       = 0
+	ioClassRealtime   = 1
+	ioClassBestEffort = 2
+	ioClassIdle       = 3
+
+	ioPrioClassShift = 13
+)
+
+// ParseIOClass converts an ionice class ("idle", "best-effort[:0-7]",
+// "realtime[:0-7]" or "none") into an I/O priority value.
+func ParseIOClass(spec string) (int, error) {
+	name, levelStr, hasLevel := strings.Cut(spec, ":")
+	level := 4
+	if hasLevel {
+		n, err := strconv.Atoi(levelStr)
+		if err != nil || n < 0 || n > 7 {
+			return 0, fmt.Errorf("invalid ionice level %q (expected 0-7)", levelStr)
+		}
+		level = n
+	}
+
+	var class int
+	switch name {
+	case "none":
+		class = ioClassNone
+	case "realtime":
+		class = ioClassRealtime
+	case "best-effort":
+		class = ioClassBestEffort
+	case "idle":
+		class = ioClassIdle
+	default:
+		return 0, fmt.Errorf("unknown ionice class %q (valid: idle, best-effort, realtime, none)", name)
+	}
+	if hasLevel && (class == ioClassNone || class == ioClassIdle) {
+		return 0, fmt.Errorf("ionice class %q takes no level", name)
+	}
+	if class == ioClassNone || class == ioClassIdle {
+		level = 0
+	}
+	return class<<ioPrioClassShift | level, nil
+}
+
+// validatePriority checks nice and ionice settings before they are applied.
+func validatePriority(nice *int, ioClass string) error {
+	if nice != nil && (*nice < -20 || *nice > 19) {
+		return fmt.Errorf("nice must be between -20 and 19, got %d", *nice)
+	}
+	if ioClass != "" {
+		if _, err := ParseIOClass(ioClass); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPriority sets niceness and I/O priority for every process in the
+// process group. Session commands lead their own group, so pgid is the
+// session PID; processes they start later inherit the settings.
+func applyPriority(pgid int, nice *int, ioClass string) error {
+	if nice != nil {
+		if err := syscall.Setpriority(syscall.PRIO_PGRP, pgid, *nice); err != nil {
+			return fmt.Errorf("set nice %d: %w", *nice, err)
+		}
+	}
+	if ioClass != "" {
+		prio, err := ParseIOClass(ioClass)
+		if err != nil {
+			return err
+		}
+		if err := setIOPriority(pgid, prio); err != nil {
+			return fmt.Errorf("set ionice %s: %w", ioClass, err)
+		}
+	}
+	return nil
+}
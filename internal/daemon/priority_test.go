@@ -0,0 +1,95 @@
+package daemon
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseIOClass(t *testing.T) {
+	tests := []struct {
+		spec string
+		want int
+	}{
+		{"none", 0},
+		{"idle", 3 << 13},
+		{"best-effort", 2<<13 | 4},
+		{"best-effort:7", 2<<13 | 7},
+		{"realtime:0", 1 << 13},
+	}
+	for _, tt := range tests {
+		got, err := ParseIOClass(tt.spec)
+		if err != nil {
+			t.Errorf("ParseIOClass(%q): %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseIOClass(%q) = %d, want %d", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "fast", "idle:1", "best-effort:8", "best-effort:x"} {
+		if _, err := ParseIOClass(spec); err == nil {
+			t.Errorf("ParseIOClass(%q) should fail", spec)
+		}
+	}
+}
+
+func TestCreateWithNice(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("needs /proc")
+	}
+
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	nice := 7
+	data, err := client.Create("niced", CreateOptions{Command: "sleep 30", Nice: &nice})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("niced")
+	pid := int(data["pid"].(float64))
+
+	if got := procNice(t, pid); got != 7 {
+		t.Errorf("nice after create = %d, want 7", got)
+	}
+
+	nice = 11
+	if err := client.Renice("niced", &nice, ""); err != nil {
+		t.Fatalf("renice: %v", err)
+	}
+	if got := procNice(t, pid); got != 11 {
+		t.Errorf("nice after renice = %d, want 11", got)
+	}
+
+	info, err := client.Info("niced")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.Nice == nil || *info.Nice != 11 {
+		t.Errorf("info nice = %v, want 11", info.Nice)
+	}
+
+	bad := 25
+	if err := client.Renice("niced", &bad, ""); err == nil {
+		t.Error("renice out of range should fail")
+	}
+}
+
+// procNice reads a process's niceness from /proc/<pid>/stat.
+func procNice(t *testing.T, pid int) int {
+	t.Helper()
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		t.Fatalf("read stat: %v", err)
+	}
+	// Fields after the parenthesised command name; nice is field 19.
+	fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+	n, err := strconv.Atoi(fields[16])
+	if err != nil {
+		t.Fatalf("parse nice: %v", err)
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// probeCommand returns the hidden command that reports the last exit status
+// and the working directory of a shell session. The marker is written with
+// octal escapes so the terminal's echo of the command never matches it. The
+// leading space keeps it out of history under HISTCONTROL=ignorespace.
+func probeCommand(command, nonce string) string {
+	status := "$?"
+	if probeShell(command) == "fish" {
+		status = "$status"
+	}
+	return fmt.Sprintf(` printf '\137\137shelli:%%s:%%d:%%s\n' %s "%s" "$PWD"`+"\n", nonce, status)
+}
+
+// probeTimeout returns the probe timeout in seconds, defaulting when unset.
+func probeTimeout(timeoutSec int) int {
+	if timeoutSec <= 0 {
+		return DefaultProbeTimeoutSec
+	}
+	return timeoutSec
+}
+
+// probeShell returns the base name of the program a session runs.
+func probeShell(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
+// parseProbe finds the probe answer for nonce in output.
+func parseProbe(output []byte, nonce string) (exitCode int, cwd string, ok bool) {
+	re := regexp.MustCompile(`__shelli:` + regexp.QuoteMeta(nonce) + `:(\d+):([^\r\n]*)\r?\n`)
+	m := re.FindSubmatch(output)
+	if m == nil {
+		return 0, "", false
+	}
+	exitCode, err := strconv.Atoi(string(m[1]))
+	if err != nil {
+		return 0, "", false
+	}
+	return exitCode, string(m[2]), true
+}
+
+// rewriteOutput replaces stored output with data, which keeps the old
+// output's bytes up to some point (less any removed in between). Read
+// position and cursors past its end are moved back to it, and chunk times
+// past it are dropped. Callers hold s.captureGate from reading the output
+// they rewrite until this returns, so no capture lands in between.
+func rewriteOutput(storage OutputStorage, name string, meta *SessionMeta, data []byte) error {
+	chunks, err := storage.Chunks(name)
+	if err != nil {
+		return err
+	}
+	if err := storage.Clear(name); err != nil {
+		return err
+	}
+	if err := storage.Append(name, data); err != nil {
+		return err
+	}
+	end := int64(len(data))
+	if err := storage.SetChunks(name, clipChunks(chunks, end)); err != nil {
+		return err
+	}
+	return storage.UpdateMeta(name, func(m *SessionMeta) {
+		m.ReadPos = min(meta.ReadPos, end)
+		if len(meta.Cursors) > 0 {
+			m.Cursors = make(map[string]int64, len(meta.Cursors))
+			for k, pos := range meta.Cursors {
+				m.Cursors[k] = min(pos, end)
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("probe", CreateOptions{Command: "sh", Cwd: "/tmp"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("probe")
+
+	if err := client.Send("probe", "cd / && false", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "probe", "false")
+
+	before, _, err := client.Read("probe", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	probe, err := client.Probe("probe", 0)
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if probe.ExitCode != 1 || probe.Cwd != "/" {
+		t.Errorf("probe = %+v, want exit 1 in /", probe)
+	}
+
+	after, _, err := client.Read("probe", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if strings.Contains(after, "shelli") || !strings.HasPrefix(after, before) {
+		t.Errorf("probe output left in buffer:\nbefore %q\nafter  %q", before, after)
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	cmd := probeCommand("bash", "abc")
+	if _, _, ok := parseProbe([]byte(cmd), "abc"); ok {
+		t.Error("echo of the probe command must not match")
+	}
+	exitCode, cwd, ok := parseProbe([]byte(cmd+"\r\n__shelli:abc:2:/home/me\r\n$ "), "abc")
+	if !ok || exitCode != 2 || cwd != "/home/me" {
+		t.Errorf("parseProbe = %d, %q, %v", exitCode, cwd, ok)
+	}
+	if !strings.Contains(probeCommand("/usr/bin/fish -l", "x"), "$status") {
+		t.Error("fish probe should read $status")
+	}
+}
+
+func TestRemoveMarkedLinesKeepsOtherOutput(t *testing.T) {
+	storage := NewMemoryStorage(0)
+	if err := storage.Create("p", &SessionMeta{Name: "p"}); err != nil {
+		t.Fatal(err)
+	}
+	before := "$ make\r\nok\r\n$ "
+	storage.Append("p", []byte(before+" printf abc\r\njob output\r\n__shelli:abc:0:/\r\n$ "))
+
+	if err := removeMarkedLines(storage, "p", int64(len(before)), "abc"); err != nil {
+		t.Fatalf("removeMarkedLines: %v", err)
+	}
+	got, _ := storage.ReadAll("p")
+	if want := "$ make\r\nok\r\njob output\r\n$ "; string(got) != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
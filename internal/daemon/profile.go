@@ -0,0 +1,132 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ProfilePathEnv overrides the profile directories: a list separated by
+// the OS path list separator. Later directories win on name clashes.
+const ProfilePathEnv = "SHELLI_PROFILE_PATH"
+
+// Profile is a named set of create options, a JSON file NAME.json in one
+// of ProfileDirs, so a session can be created with "create --profile NAME"
+// instead of repeating its command, env and cwd.
+type Profile struct {
+	Name        string   `json:"-"`
+	Path        string   `json:"-"`
+	Description string   `json:"description,omitempty"`
+	Command     string   `json:"command,omitempty"`
+	Env         []string `json:"env,omitempty"`
+	// Cwd may start with ~/; a relative path is taken from where the
+	// session is created.
+	Cwd  string `json:"cwd,omitempty"`
+	Cols int    `json:"cols,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+	TUI  bool   `json:"tui,omitempty"`
+}
+
+var profileName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// ProfileDirs returns the directories profiles are loaded from: the user's
+// config dir (~/.config/shelli/profiles) and .shelli/profiles in the
+// current git repository, or the SHELLI_PROFILE_PATH list when set.
+func ProfileDirs() []string {
+	if path := os.Getenv(ProfilePathEnv); path != "" {
+		return filepath.SplitList(path)
+	}
+	var dirs []string
+	if config, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(config, "shelli", "profiles"))
+	}
+	if workspace := CurrentWorkspace(); workspace != "" {
+		dirs = append(dirs, filepath.Join(workspace, ".shelli", "profiles"))
+	}
+	return dirs
+}
+
+// LoadProfile finds the profile name in dirs, the last directory holding
+// it winning.
+func LoadProfile(dirs []string, name string) (*Profile, error) {
+	if !profileName.MatchString(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid profile name %q", name)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		path := filepath.Join(dirs[i], name+".json")
+		data, err := os.ReadFile(path) // #nosec G304 -- the user's own profile dirs
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", path, err)
+		}
+		var p Profile
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", path, err)
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", path, err)
+		}
+		p.Name, p.Path = name, path
+		return &p, nil
+	}
+	return nil, fmt.Errorf("profile %q not found (looked in %s)", name, strings.Join(dirs, ", "))
+}
+
+func (p *Profile) validate() error {
+	if p.Cols < 0 || p.Rows < 0 {
+		return fmt.Errorf("cols and rows must not be negative")
+	}
+	for _, kv := range p.Env {
+		if !strings.Contains(kv, "=") {
+			return fmt.Errorf("invalid env entry %q: want KEY=VALUE", kv)
+		}
+	}
+	return nil
+}
+
+// Apply fills in the options opts leaves unset from the profile. The
+// profile's env comes first, so variables given in opts override it.
+func (p *Profile) Apply(opts CreateOptions) (CreateOptions, error) {
+	if opts.Command == "" {
+		opts.Command = p.Command
+	}
+	if len(p.Env) > 0 {
+		opts.Env = append(append([]string(nil), p.Env...), opts.Env...)
+	}
+	if opts.Cwd == "" && p.Cwd != "" {
+		cwd, err := expandProfilePath(p.Cwd)
+		if err != nil {
+			return opts, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		opts.Cwd = cwd
+	}
+	if opts.Cols == 0 {
+		opts.Cols = p.Cols
+	}
+	if opts.Rows == 0 {
+		opts.Rows = p.Rows
+	}
+	opts.TUIMode = opts.TUIMode || p.TUI
+	return opts, nil
+}
+
+func expandProfilePath(path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok || path == "~" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, rest), nil
+	}
+	return filepath.Abs(path)
+}
@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadProfile(t *testing.T) {
+	user, repo := t.TempDir(), t.TempDir()
+	write := func(dir, name, body string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(user, "pg", `{"command": "psql -d user", "env": ["PGUSER=me"]}`)
+	write(repo, "pg", `{"command": "psql -d dev", "env": ["PGHOST=localhost", "PGPORT=5432"], "cwd": "~/db", "cols": 200, "tui": true}`)
+	write(user, "typo", `{"comand": "psql"}`)
+	write(user, "badenv", `{"env": ["PGHOST"]}`)
+	dirs := []string{user, repo}
+
+	p, err := LoadProfile(dirs, "pg")
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if p.Command != "psql -d dev" || p.Path != filepath.Join(repo, "pg.json") {
+		t.Errorf("the repository's profile should win: %+v", p)
+	}
+
+	opts, err := p.Apply(CreateOptions{Env: []string{"PGPORT=6543"}, Rows: 50})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	home, _ := os.UserHomeDir()
+	if opts.Command != "psql -d dev" || opts.Cols != 200 || opts.Rows != 50 || !opts.TUIMode || opts.Cwd != filepath.Join(home, "db") {
+		t.Errorf("options = %+v", opts)
+	}
+	// Given env comes last, so it overrides the profile's.
+	if !slices.Equal(opts.Env, []string{"PGHOST=localhost", "PGPORT=5432", "PGPORT=6543"}) {
+		t.Errorf("env = %q", opts.Env)
+	}
+	if opts, _ := p.Apply(CreateOptions{Command: "pgcli", Cwd: "/tmp"}); opts.Command != "pgcli" || opts.Cwd != "/tmp" {
+		t.Errorf("given options should override the profile: %+v", opts)
+	}
+
+	for name, want := range map[string]string{
+		"typo":    "unknown field",
+		"badenv":  "KEY=VALUE",
+		"missing": "not found",
+		"../pg":   "invalid profile name",
+	} {
+		if _, err := LoadProfile(dirs, name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadProfile(%q) = %v, want an error with %q", name, err, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+// PTYDriver is how the daemon gets terminals for session commands and
+// changes their size. The default, creackPTY, starts commands on a local
+// pseudo-terminal; WithPTYDriver plugs in another, e.g. the fake terminal
+// tests use, or a backend for ConPTY, containers or SSH.
+type PTYDriver interface {
+	// Start starts cmd on a new terminal of the given size and returns the
+	// terminal's master side, which the daemon reads output from and
+	// writes input to.
+	Start(cmd *exec.Cmd, cols, rows int) (*os.File, error)
+	// Resize sets the size of the terminal whose master side is f.
+	Resize(f *os.File, cols, rows int) error
+	// NotifyResize tells the program on a terminal to redraw at its new
+	// size (SIGWINCH for a local PTY).
+	NotifyResize(proc *os.Process) error
+}
+
+// creackPTY is the default PTYDriver, on github.com/creack/pty.
+type creackPTY struct{}
+
+func (creackPTY) Start(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	return pty.StartWithSize(cmd, &pty.Winsize{Cols: clampUint16(cols), Rows: clampUint16(rows)})
+}
+
+func (creackPTY) Resize(f *os.File, cols, rows int) error {
+	return pty.Setsize(f, &pty.Winsize{Cols: clampUint16(cols), Rows: clampUint16(rows)})
+}
+
+func (creackPTY) NotifyResize(proc *os.Process) error {
+	return proc.Signal(syscall.SIGWINCH)
+}
+
+// notifyResize tells a session's program its terminal changed size; a
+// program that is gone has nothing to redraw.
+func (s *Server) notifyResize(cmd *exec.Cmd) {
+	if cmd != nil && cmd.Process != nil {
+		s.ptys.NotifyResize(cmd.Process)
+	}
+}
+
+// ptyHandle is the daemon's side of a session's terminal, closed once.
+// Resizes go through it so none touches the file while it is being closed.
+type ptyHandle struct {
+	f      *os.File
+	mu     sync.Mutex
+	closed bool
+}
+
+func (p *ptyHandle) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	p.f.Close()
+}
+
+// Resize sets the terminal's size with driver; once the terminal is closed
+// it fails with os.ErrClosed.
+func (p *ptyHandle) Resize(driver PTYDriver, cols, rows int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return os.ErrClosed
+	}
+	return driver.Resize(p.f, cols, rows)
+}
+
+func (p *ptyHandle) File() *os.File {
+	return p.f
+}
+
+func clampUint16(v int) uint16 {
+	if v < 0 {
+		return 0
+	}
+	if v > 65535 {
+		return 65535
+	}
+	return uint16(v)
+}
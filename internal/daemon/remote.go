@@ -0,0 +1,229 @@
+package daemon
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HostEnv points clients at a daemon other than the local one:
+// tcp://HOST:PORT for a daemon started with --listen, or
+// ssh://[USER@]HOST[:PORT] to reach the daemon of that machine's user over
+// ssh, which runs "shelli daemon proxy" there.
+const HostEnv = "SHELLI_HOST"
+
+// TokenEnv holds the token of a daemon listening on TCP, on both ends: the
+// daemon accepts it, clients send it.
+const TokenEnv = "SHELLI_TOKEN"
+
+// RemoteDialTimeout bounds connecting to a remote daemon.
+const RemoteDialTimeout = 10 * time.Second
+
+// Dialer opens a connection to a daemon, carrying one request (or stream).
+type Dialer func() (net.Conn, error)
+
+// UnixDialer dials the daemon socket at path.
+func UnixDialer(path string) Dialer {
+	return func() (net.Conn, error) { return net.Dial("unix", path) }
+}
+
+// TCPDialer dials a daemon listening on TCP at addr (host:port).
+func TCPDialer(addr string) Dialer {
+	return func() (net.Conn, error) { return net.DialTimeout("tcp", addr, RemoteDialTimeout) }
+}
+
+// SSHDialer reaches the daemon on host ([user@]host) by running
+// "shelli daemon proxy" there over ssh, one ssh connection per request.
+// ssh runs in batch mode, so keys or an agent must be set up; ControlMaster
+// in ~/.ssh/config saves the handshake on each request.
+func SSHDialer(host, port string) Dialer {
+	return func() (net.Conn, error) {
+		args := []string{"-T", "-q", "-o", "BatchMode=yes"}
+		if port != "" {
+			args = append(args, "-p", port)
+		}
+		args = append(args, host, "shelli", "daemon", "proxy")
+		cmd := exec.Command("ssh", args...) // #nosec G204 -- the host comes from the user's own SHELLI_HOST
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("start ssh: %w", err)
+		}
+
+		local, remote := net.Pipe()
+		go func() {
+			io.Copy(stdin, remote) //nolint:errcheck // ends when either side closes
+			stdin.Close()
+		}()
+		go func() {
+			io.Copy(remote, stdout) //nolint:errcheck // ends when either side closes
+			remote.Close()
+		}()
+		return &sshConn{Conn: local, cmd: cmd}, nil
+	}
+}
+
+// sshConn is the client end of a request proxied over ssh.
+type sshConn struct {
+	net.Conn
+	cmd *exec.Cmd
+}
+
+func (c *sshConn) Close() error {
+	err := c.Conn.Close()
+	c.cmd.Process.Kill() //nolint:errcheck // ssh may have exited already
+	c.cmd.Wait()         //nolint:errcheck // killed above
+	return err
+}
+
+// ParseHost returns the dialer for a HostEnv value.
+func ParseHost(host string) (Dialer, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", HostEnv, host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		return UnixDialer(u.Path), nil
+	case "tcp":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("invalid %s %q: tcp needs a port", HostEnv, host)
+		}
+		return TCPDialer(u.Host), nil
+	case "ssh":
+		if u.Hostname() == "" {
+			return nil, fmt.Errorf("invalid %s %q: ssh needs a host", HostEnv, host)
+		}
+		dest := u.Hostname()
+		if u.User != nil {
+			dest = u.User.Username() + "@" + dest
+		}
+		return SSHDialer(dest, u.Port()), nil
+	}
+	return nil, fmt.Errorf("invalid %s %q: want tcp://HOST:PORT, ssh://[USER@]HOST[:PORT] or unix:///PATH", HostEnv, host)
+}
+
+// ParseListen checks a --listen address, tcp://HOST:PORT, returning
+// HOST:PORT.
+func ParseListen(spec string) (string, error) {
+	addr, ok := strings.CutPrefix(spec, "tcp://")
+	if !ok {
+		return "", fmt.Errorf("invalid listen address %q: want tcp://HOST:PORT", spec)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", spec, err)
+	}
+	return addr, nil
+}
+
+// TokenPath is where a daemon keeps the token it made up for TCP clients.
+func TokenPath() (string, error) {
+	dir, err := RuntimeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "token"), nil
+}
+
+// LoadToken returns the token a TCP listener accepts: $SHELLI_TOKEN, else
+// the contents of path, else a new random token written to path (mode
+// 0600) for the user to copy to clients.
+func LoadToken(path string) (string, error) {
+	if token := os.Getenv(TokenEnv); token != "" {
+		return token, nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- the daemon's own token file
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("read token: %w", err)
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("write token: %w", err)
+	}
+	return token, nil
+}
+
+// ServeTCP accepts clients on addr (host:port) as well as the socket, until
+// the daemon shuts down. Every request must carry token. Traffic is not
+// encrypted: across untrusted networks, prefer ssh:// or an ssh tunnel. It
+// returns the address listened on.
+func (s *Server) ServeTCP(addr, token string) (string, error) {
+	if token == "" {
+		return "", fmt.Errorf("tcp listen: a token is required")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("tcp listen: %w", err)
+	}
+
+	s.mu.Lock()
+	s.tcpListener = listener
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("tcp: %v", err)
+				}
+				return
+			}
+			go s.handleConn(conn, token)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+// Proxy relays one connection between r/w and the daemon, for a client
+// that reaches this machine over ssh: "shelli daemon proxy" is the remote
+// end of SSHDialer.
+func (c *Client) Proxy(r io.Reader, w io.Writer) error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		io.Copy(conn, r) //nolint:errcheck // the client hung up
+		// Closing tells the daemon the client is gone, which ends a
+		// stream.
+		conn.Close()
+	}()
+	_, err = io.Copy(w, conn)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
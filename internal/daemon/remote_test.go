@@ -0,0 +1,117 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeTCP(t *testing.T) {
+	srv, _, cleanup := startTestServer(t)
+	defer cleanup()
+
+	addr, err := srv.ServeTCP("127.0.0.1:0", "secret")
+	if err != nil {
+		t.Fatalf("ServeTCP: %v", err)
+	}
+
+	client := NewClientWithDialer(TCPDialer(addr), "tcp://"+addr, "secret")
+	if err := client.EnsureDaemon(); err != nil {
+		t.Fatalf("EnsureDaemon: %v", err)
+	}
+	if _, err := client.Create("remote", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("remote")
+	if err := client.Send("remote", "echo over-$((6*7))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "remote", "over-42")
+
+	for _, token := range []string{"", "wrong"} {
+		intruder := NewClientWithDialer(TCPDialer(addr), "tcp://"+addr, token)
+		if intruder.Ping() {
+			t.Errorf("ping with token %q should be refused", token)
+		}
+		err := intruder.EnsureDaemon()
+		if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+			t.Errorf("EnsureDaemon with token %q: %v", token, err)
+		}
+		if _, err := intruder.List(); err == nil {
+			t.Errorf("list with token %q should fail", token)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	srv, local, cleanup := startTestServer(t)
+	defer cleanup()
+
+	// The client end of the proxy, as SSHDialer sets it up with ssh in
+	// between.
+	dial := func() (net.Conn, error) {
+		clientEnd, proxyEnd := net.Pipe()
+		go func() {
+			NewClientWithSocketPath(srv.socketPath()).Proxy(proxyEnd, proxyEnd) //nolint:errcheck
+			proxyEnd.Close()
+		}()
+		return clientEnd, nil
+	}
+	client := NewClientWithDialer(dial, "ssh://test", "")
+	if !client.Ping() {
+		t.Fatal("ping through the proxy failed")
+	}
+	if _, err := local.Create("proxied", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer local.Kill("proxied")
+	if err := client.Send("proxied", "echo via-$((1+1))", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "proxied", "via-2")
+}
+
+func TestParseHost(t *testing.T) {
+	for _, host := range []string{"tcp://example.com:7373", "ssh://me@example.com", "ssh://example.com:2222", "unix:///tmp/shelli.sock"} {
+		if _, err := ParseHost(host); err != nil {
+			t.Errorf("ParseHost(%q): %v", host, err)
+		}
+	}
+	for _, host := range []string{"tcp://example.com", "ssh://", "http://example.com:80", "example.com:7373"} {
+		if _, err := ParseHost(host); err == nil {
+			t.Errorf("ParseHost(%q) should fail", host)
+		}
+	}
+
+	if addr, err := ParseListen("tcp://0.0.0.0:7373"); err != nil || addr != "0.0.0.0:7373" {
+		t.Errorf("ParseListen = %q, %v", addr, err)
+	}
+	if _, err := ParseListen("0.0.0.0:7373"); err == nil {
+		t.Error("ParseListen without tcp:// should fail")
+	}
+}
+
+func TestLoadToken(t *testing.T) {
+	t.Setenv(TokenEnv, "")
+	path := filepath.Join(t.TempDir(), "token")
+
+	token, err := LoadToken(path)
+	if err != nil || len(token) < 32 {
+		t.Fatalf("LoadToken = %q, %v", token, err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("token file: %v, %v", fi, err)
+	}
+	again, err := LoadToken(path)
+	if err != nil || again != token {
+		t.Errorf("second LoadToken = %q, %v; want the saved %q", again, err, token)
+	}
+
+	t.Setenv(TokenEnv, "from-env")
+	if token, _ := LoadToken(path); token != "from-env" {
+		t.Errorf("LoadToken with $%s = %q", TokenEnv, token)
+	}
+}
@@ -0,0 +1,169 @@
+package daemon
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/schovi/shelli/internal/vterm"
+	"github.com/schovi/shelli/internal/wait"
+)
+
+// How a run_once wait ended.
+const (
+	RunOnceMatched = "matched" // the wait pattern appeared
+	RunOnceSettled = "settled" // output stopped changing for settle_ms
+	RunOnceExited  = "exited"  // the command finished
+	RunOnceTimeout = "timeout"
+)
+
+// RunOnceDefaultSettleMs is the settle time of a run_once with input and
+// neither a wait pattern nor a settle time, as for exec.
+const RunOnceDefaultSettleMs = 500
+
+// RunOnceResult is the outcome of a throwaway session. Output starts after
+// the input (program banners are left out), or at the start when there is
+// no input or the program exited before the input was sent.
+type RunOnceResult struct {
+	Output   string  `json:"output"`
+	Status   string  `json:"status"`
+	ExitCode *int    `json:"exit_code,omitempty"`
+	Signal   string  `json:"signal,omitempty"`
+	Seconds  float64 `json:"duration_seconds"`
+}
+
+// handleRunOnce creates a session for req.Command, sends req.Input once the
+// program's startup output has settled, waits for req.Pattern, settle or
+// exit, and kills the session, whether or not the wait succeeded. Without
+// input it waits for the command to exit (or the pattern, or settle when
+// set). The session never outlives the request.
+func (s *Server) handleRunOnce(req Request) Response {
+	if req.Command == "" {
+		return Response{Success: false, Error: "command is required"}
+	}
+	var re *regexp.Regexp
+	if req.Pattern != "" {
+		var err error
+		if re, err = regexp.Compile(req.Pattern); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("invalid pattern: %v", err)}
+		}
+	}
+	settleMs := req.SettleMs
+	if settleMs == 0 && re == nil && req.Input != "" {
+		settleMs = RunOnceDefaultSettleMs
+	}
+	timeoutSec := req.TimeoutSec
+	if timeoutSec <= 0 {
+		timeoutSec = 10
+	}
+
+	name := "run-once-" + newNonce()
+	resp := s.handleCreate(Request{
+		Action:    "create",
+		Name:      name,
+		Command:   req.Command,
+		Env:       req.Env,
+		Cwd:       req.Cwd,
+		Cols:      req.Cols,
+		Rows:      req.Rows,
+		Workspace: req.Workspace,
+	})
+	if !resp.Success {
+		return resp
+	}
+	defer s.handleKill(Request{Action: "kill", Name: name})
+
+	s.mu.Lock()
+	h := s.handles[name]
+	exited := h.exited
+	storage := s.storage
+	s.mu.Unlock()
+	if exited == nil { // already exited and recorded
+		closed := make(chan struct{})
+		close(closed)
+		exited = closed
+	}
+
+	start := time.Now()
+	deadline := start.Add(time.Duration(timeoutSec) * time.Second)
+	settle := time.Duration(settleMs) * time.Millisecond
+
+	var from int64
+	status := ""
+	if req.Input != "" {
+		startupSettle := settle
+		if startupSettle == 0 {
+			startupSettle = RunOnceDefaultSettleMs * time.Millisecond
+		}
+		status = awaitOutput(storage, name, exited, 0, nil, startupSettle, deadline)
+		if status == RunOnceSettled {
+			status = ""
+			from, _ = storage.Size(name)
+			sent := s.handleSend(Request{Action: "send", Name: name, Input: req.Input, Newline: true})
+			if !sent.Success {
+				select {
+				case <-exited: // exited just before the input went out
+					status, from = RunOnceExited, 0
+				default:
+					return Response{Success: false, Error: fmt.Sprintf("send input: %s", sent.Error)}
+				}
+			}
+		}
+	}
+	if status == "" {
+		status = awaitOutput(storage, name, exited, from, re, settle, deadline)
+	}
+
+	output, err := storage.ReadFrom(name, from)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+	}
+	result := RunOnceResult{
+		Output:  string(output),
+		Status:  status,
+		Seconds: time.Since(start).Seconds(),
+	}
+	if req.StripANSI {
+		result.Output = vterm.StripDefault(result.Output)
+	}
+	if status == RunOnceExited {
+		s.mu.Lock()
+		result.ExitCode, result.Signal = h.exitCode, h.exitSignal
+		s.mu.Unlock()
+	}
+	return Response{Success: true, Data: result}
+}
+
+// awaitOutput polls a session's output past from until re matches it, it
+// has been quiet for settle (once there is some), the process exits, or
+// deadline passes, and says which happened. A zero settle or nil re is not
+// waited for.
+func awaitOutput(storage OutputStorage, name string, exited <-chan struct{}, from int64, re *regexp.Regexp, settle time.Duration, deadline time.Time) string {
+	lastSize := from
+	lastChange := time.Now()
+	for {
+		select {
+		case <-exited:
+			return RunOnceExited
+		default:
+		}
+
+		size, err := storage.Size(name)
+		if err == nil && size != lastSize {
+			lastSize = size
+			lastChange = time.Now()
+			if re != nil {
+				if data, err := storage.ReadFrom(name, from); err == nil && re.Match(data) {
+					return RunOnceMatched
+				}
+			}
+		}
+		if settle > 0 && lastSize > from && time.Since(lastChange) >= settle {
+			return RunOnceSettled
+		}
+		if time.Now().After(deadline) {
+			return RunOnceTimeout
+		}
+		time.Sleep(wait.DefaultPollInterval)
+	}
+}
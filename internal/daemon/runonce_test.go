@@ -0,0 +1,52 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunOnce(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	t.Run("input", func(t *testing.T) {
+		result, err := client.RunOnce(RunOnceOptions{Command: "sh", Input: "echo $((20+22))", WaitPattern: `42\r?\n`})
+		if err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		if result.Status != RunOnceMatched || !strings.Contains(result.Output, "42") {
+			t.Errorf("RunOnce = %+v, want matched with 42", result)
+		}
+	})
+
+	t.Run("exit", func(t *testing.T) {
+		result, err := client.RunOnce(RunOnceOptions{Command: "sh -c 'echo done; exit 3'"})
+		if err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		if result.Status != RunOnceExited || result.ExitCode == nil || *result.ExitCode != 3 {
+			t.Errorf("RunOnce = %+v, want exited with code 3", result)
+		}
+		if !strings.Contains(result.Output, "done") {
+			t.Errorf("output %q lacks the command's output", result.Output)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		result, err := client.RunOnce(RunOnceOptions{Command: "sleep 30", TimeoutSec: 1})
+		if err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		if result.Status != RunOnceTimeout {
+			t.Errorf("status = %q, want timeout", result.Status)
+		}
+	})
+
+	sessions, err := client.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("sessions left behind: %+v", sessions)
+	}
+}
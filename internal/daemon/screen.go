@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// ScreenReplayBytes is how much of a non-TUI session's buffer the screen
+// action replays into a terminal emulator. A screen's worth of output is
+// almost always within it.
+const ScreenReplayBytes = 256 * 1024
+
+// ScreenCursor is a 0-based cursor position.
+type ScreenCursor struct {
+	Row     int  `json:"row"`
+	Col     int  `json:"col"`
+	Visible bool `json:"visible"`
+}
+
+// ScreenState is a session's terminal as rows of text.
+type ScreenState struct {
+	Rows   []string     `json:"rows"`
+	Cols   int          `json:"cols"`
+	Cursor ScreenCursor `json:"cursor"`
+	// Source is "screen" for a TUI session's live emulator, or "buffer" when
+	// the tail of the output buffer was replayed at the session's size.
+	Source string `json:"source"`
+}
+
+func screenState(screen *vterm.Screen, cols int, styled bool, source string) *ScreenState {
+	col, row, visible := screen.Cursor()
+	return &ScreenState{
+		Rows:   screen.Rows(styled),
+		Cols:   cols,
+		Cursor: ScreenCursor{Row: row, Col: col, Visible: visible},
+		Source: source,
+	}
+}
+
+// handleScreen returns the session's terminal as rows plus the cursor
+// position. TUI sessions report their emulator; other sessions replay the
+// end of their buffer into one, which also works once they stopped.
+func (s *Server) handleScreen(req Request) Response {
+	state, err := s.sessionScreen(req.Name, req.Styled)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	return Response{Success: true, Data: state}
+}
+
+// sessionScreen returns a session's terminal as handleScreen reports it.
+func (s *Server) sessionScreen(name string, styled bool) (*ScreenState, error) {
+	s.mu.Lock()
+	h, exists := s.handles[name]
+	if !exists {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("session %q not found", name)
+	}
+	screen := h.screen
+	storage := s.storage
+	s.mu.Unlock()
+
+	meta, err := storage.LoadMeta(name)
+	if err != nil {
+		return nil, fmt.Errorf("load meta: %v", err)
+	}
+	if screen != nil {
+		return screenState(screen, meta.Cols, styled, "screen"), nil
+	}
+
+	size, err := storage.Size(name)
+	if err != nil {
+		return nil, fmt.Errorf("read output: %v", err)
+	}
+	data, err := storage.ReadFrom(name, max(0, size-ScreenReplayBytes))
+	if err != nil {
+		return nil, fmt.Errorf("read output: %v", err)
+	}
+	if size > ScreenReplayBytes {
+		// Start on a line boundary rather than inside an escape sequence.
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+
+	replay := vterm.New(meta.Cols, meta.Rows)
+	defer replay.Close()
+	// Nothing answers the queries in old output; without a reader the
+	// emulator blocks on the first one (vim and less ask for the cursor).
+	go replay.ReadResponses(io.Discard)
+	replay.Write(data)
+	return screenState(replay, meta.Cols, styled, "buffer"), nil
+}
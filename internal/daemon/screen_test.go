@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScreen(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	for _, opts := range []CreateOptions{
+		{Command: "sh", Cols: 40, Rows: 10, TUIMode: true},
+		{Command: "sh", Cols: 40, Rows: 10},
+	} {
+		name := "screen-plain"
+		source := "buffer"
+		if opts.TUIMode {
+			name, source = "screen-tui", "screen"
+		}
+		t.Run(name, func(t *testing.T) {
+			if _, err := client.Create(name, opts); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			defer client.Kill(name)
+
+			// Clear the screen and draw a marker on row 3 (0-based 2) at col 5.
+			if err := client.Send(name, `printf '\033[H\033[2J\033[3;6Hscreen-%d' 42; sleep 5`, true); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			waitForOutput(t, client, name, "screen-42")
+
+			screen, err := client.Screen(name, false)
+			if err != nil {
+				t.Fatalf("Screen: %v", err)
+			}
+			if screen.Source != source || screen.Cols != 40 || len(screen.Rows) != 10 {
+				t.Fatalf("screen = %s %d cols, %d rows, want %s 40x10", screen.Source, screen.Cols, len(screen.Rows), source)
+			}
+			if screen.Rows[2] != "     screen-42" {
+				t.Errorf("row 2 = %q, rows %q", screen.Rows[2], screen.Rows)
+			}
+			if screen.Cursor.Row != 2 || screen.Cursor.Col != 14 {
+				t.Errorf("cursor = %+v, want row 2 col 14", screen.Cursor)
+			}
+			for i, row := range screen.Rows {
+				if strings.Contains(row, "\x1b") {
+					t.Errorf("row %d has escapes: %q", i, row)
+				}
+			}
+		})
+	}
+}
+
+func TestFrameHistoryOptIn(t *testing.T) {
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("no-history", CreateOptions{Command: "sleep 60", TUIMode: true}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("no-history")
+	fake.terminal(t)
+	if _, err := client.Frames("no-history"); err == nil || !strings.Contains(err.Error(), "--frame-history") {
+		t.Errorf("Frames without history: err = %v, want a hint to --frame-history", err)
+	}
+
+	if _, err := client.Create("history", CreateOptions{Command: "sleep 60", TUIMode: true, FrameHistory: 2}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("history")
+	term := fake.terminal(t)
+	term.Write([]byte("\x1b[2J\x1b[Hfirst"))
+	waitForOutput(t, client, "history", "first")
+	term.Write([]byte("\x1b[2J\x1b[Hsecond"))
+	waitForOutput(t, client, "history", "second")
+
+	out, _, err := client.ReadFrame("history", -1, 0, 0)
+	if err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if !strings.Contains(out, "first") {
+		t.Errorf("frame -1 = %q, want the screen before the redraw", out)
+	}
+}
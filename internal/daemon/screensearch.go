@@ -0,0 +1,85 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// SearchRenderBytes is how much of the end of a non-TUI session's buffer a
+// render search replays into a terminal emulator.
+const SearchRenderBytes = 4 << 20
+
+// ScreenPos is where a render search match is on the terminal: Row 0 is
+// the top row of the screen, negative rows have scrolled off above it (-1
+// is the row just above), and Col is the 0-based column the match starts
+// at.
+type ScreenPos struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+// renderedRows returns a session's output as the terminal shows it: the
+// rows scrolled off the top followed by the screen rows, and the index of
+// the first screen row. TUI sessions give their live screen (and its
+// scrollback, when kept); other sessions replay the last SearchRenderBytes
+// of output at the session's size, and from is where the replay started.
+func renderedRows(name string, screen *vterm.Screen, storage OutputStorage) (rows []string, top int, from int64, err error) {
+	if screen != nil {
+		rows = screen.Scrollback()
+		top = len(rows)
+		return append(rows, screen.Rows(false)...), top, 0, nil
+	}
+
+	meta, err := storage.LoadMeta(name)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("load meta: %v", err)
+	}
+	size, err := storage.Size(name)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("get size: %v", err)
+	}
+	from = max(0, size-SearchRenderBytes)
+	data, err := storage.ReadFrom(name, from)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("read output: %v", err)
+	}
+	if from > 0 {
+		// Start on a line boundary rather than inside an escape sequence.
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+			from += int64(i + 1)
+		}
+	}
+
+	replay := vterm.New(meta.Cols, meta.Rows)
+	defer replay.Close()
+	go replay.ReadResponses(io.Discard)
+	replay.SetScrollback(bytes.Count(data, []byte("\n")) + 1)
+	replay.Write(data)
+	rows = replay.Scrollback()
+	top = len(rows)
+	return append(rows, replay.Rows(false)...), top, from, nil
+}
+
+// placeMatches sets the screen position of each match of a search over
+// rows, whose screen starts at row top.
+func placeMatches(result *SearchResponse, rows []string, top int, req SearchRequest) error {
+	re, err := searchPattern(req)
+	if err != nil {
+		return err
+	}
+	for i := range result.Matches {
+		m := &result.Matches[i]
+		row := rows[m.LineNumber-1]
+		col := 0
+		if loc := re.FindStringIndex(row); loc != nil {
+			col = utf8.RuneCountInString(row[:loc[0]])
+		}
+		m.Screen = &ScreenPos{Row: m.LineNumber - 1 - top, Col: col}
+	}
+	return nil
+}
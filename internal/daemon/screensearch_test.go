@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSearchRender(t *testing.T) {
+	fake := newFakePTY()
+	client, cleanup := setupTestServer(t, WithPTYDriver(fake))
+	defer cleanup()
+
+	if _, err := client.Create("tui", CreateOptions{Command: "sleep 60", Cols: 40, Rows: 5}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("tui")
+	term := fake.terminal(t)
+	var out strings.Builder
+	for i := 1; i <= 6; i++ {
+		fmt.Fprintf(&out, "line %d\r\n", i)
+	}
+	// A status drawn by cursor positioning: raw lines never hold it whole.
+	out.WriteString("\x1b[2;1HStatus:\x1b[2;12Hready")
+	term.Write([]byte(out.String()))
+	waitForOutput(t, client, "tui", "ready")
+
+	raw, err := client.Search(SearchRequest{Name: "tui", Pattern: `Status: +ready`})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if raw.TotalMatches != 0 {
+		t.Errorf("raw search matched %+v", raw.Matches)
+	}
+
+	got, err := client.Search(SearchRequest{Name: "tui", Pattern: `ready`, Render: true})
+	if err != nil {
+		t.Fatalf("Search render: %v", err)
+	}
+	if got.TotalMatches != 1 || got.Matches[0].Screen == nil {
+		t.Fatalf("render search = %+v, want one placed match", got)
+	}
+	if m := got.Matches[0]; *m.Screen != (ScreenPos{Row: 1, Col: 11}) || !strings.HasPrefix(m.Line, "Status:") {
+		t.Errorf("match = %q at %+v, want the status row at 1,11", m.Line, *m.Screen)
+	}
+
+	// Rows that scrolled off the 5-row screen come before it.
+	first, err := client.Search(SearchRequest{Name: "tui", Pattern: `^line 1$`, Render: true})
+	if err != nil {
+		t.Fatalf("Search render scrollback: %v", err)
+	}
+	if first.TotalMatches != 1 || first.Matches[0].Screen.Row != -2 {
+		t.Errorf("line 1 = %+v, want 2 rows above the screen", first.Matches)
+	}
+
+	if _, err := client.Search(SearchRequest{Name: "tui", Pattern: "x", Render: true, Newlines: NewlinesLF}); err == nil {
+		t.Error("render search with newlines succeeded")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,35 +14,26 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
-	"github.com/creack/pty"
 	"github.com/schovi/shelli/internal/vterm"
+	"golang.org/x/text/encoding"
 )
 
-type ptyHandle struct {
-	f         *os.File
-	closeOnce sync.Once
-}
-
-func (p *ptyHandle) Close() {
-	p.closeOnce.Do(func() {
-		p.f.Close()
-	})
-}
-
-func (p *ptyHandle) File() *os.File {
-	return p.f
-}
-
 type SessionInfo struct {
-	Name      string `json:"name"`
-	PID       int    `json:"pid"`
-	Command   string `json:"command"`
-	CreatedAt string `json:"created_at"`
-	State     string `json:"state"`
-	StoppedAt string `json:"stopped_at,omitempty"`
+	Name      string  `json:"name"`
+	PID       int     `json:"pid"`
+	Command   string  `json:"command"`
+	CreatedAt string  `json:"created_at"`
+	State     string  `json:"state"`
+	StoppedAt string  `json:"stopped_at,omitempty"`
+	Workspace string  `json:"workspace,omitempty"`
+	Frozen    bool    `json:"frozen,omitempty"`
+	EndReason string  `json:"end_reason,omitempty"`
+	Health    *Health `json:"health,omitempty"` // list with verbose set
 }
 
 type sessionHandle struct {
@@ -51,11 +43,101 @@ type sessionHandle struct {
 	state     SessionState
 	createdAt time.Time
 	stoppedAt *time.Time
+	workspace string   // git repo root the session was created from
+	cwd       string   // working directory the command started in
+	env       []string // environment given on create (KEY=VALUE), for sibling
+	cgroup    string   // cgroup made for the session's resource limits
+
+	pty     *ptyHandle
+	cmd     *exec.Cmd
+	done    chan struct{}
+	screen  *vterm.Screen // non-nil for TUI sessions
+	capture *rawCapture   // non-nil when raw PTY output is teed to a file
+
+	images      []sessionImage // inline images extracted from output, oldest first
+	nextImageID int
+
+	notifications      []sessionNotification // bells and OSC 9/777, oldest first
+	nextNotificationID int
+
+	// viewers counts attached external viewers. While any are present,
+	// snapshots leave the PTY size alone so their view does not flicker.
+	viewers int
+
+	budget *execBudget   // CPU/wall budget of the latest exec, if any
+	exec   *execProgress // latest exec reported through exec_begin
+	jobs   []*Job        // background jobs started with exec --background
+
+	// altScreen is set while the program is on the alternate screen.
+	altScreen bool
+
+	// exited is closed once the process has exited and exitCode is set;
+	// nil for sessions without a process behind them.
+	exited     chan struct{}
+	exitCode   *int
+	exitSignal string
+
+	// endReason is why the session stopped running (see exit.go), set by
+	// stop and shutdown before capture ends, else from how it ended.
+	endReason string
+	endError  string
+
+	// charset is the session's output/input encoding when it is not UTF-8.
+	charset encoding.Encoding
 
-	pty    *ptyHandle
-	cmd    *exec.Cmd
-	done   chan struct{}
-	screen *vterm.Screen // non-nil for TUI sessions
+	// mirrorInput records sent input in the buffer (see mirror.go).
+	mirrorInput bool
+
+	// banner holds back startup output until the program is ready (see
+	// banner.go); nil unless requested at create.
+	banner *bannerWindow
+
+	// capturePriority sets how output is read off the PTY (see
+	// capturesched.go); captureStats counts how that went.
+	capturePriority string
+	captureStats    captureStats
+
+	// bookmarks matches output against the session's bookmark watches (see
+	// bookmark.go).
+	bookmarks bookmarkWatcher
+
+	// output fans captured output out to its consumers (see fanout.go).
+	output outputHub
+
+	// onExit are the session's own exit hooks (create --on-exit).
+	onExit []string
+
+	// snapshotMode is how snapshots get a fresh screen (see
+	// snapshotmode.go); resizedAt is when a snapshot last resized the PTY,
+	// and resizeCrash is set once the process died right after one.
+	snapshotMode string
+	resizedAt    time.Time
+	resizeCrash  bool
+
+	pause  capturePause
+	freeze sessionFreeze
+
+	// input logs what was sent, for replay (see inputlog.go).
+	input inputLog
+
+	// keys are the session's recent idempotency keys (see idempotency.go).
+	keys map[string]*idempotentCall
+
+	traffic  sessionTraffic
+	clock    sessionClock
+	activity activityMeter
+}
+
+type sessionNotification struct {
+	id int
+	at time.Time
+	n  vterm.Notification
+}
+
+type sessionImage struct {
+	id         int
+	capturedAt time.Time
+	img        vterm.InlineImage
 }
 
 type Server struct {
@@ -68,6 +150,39 @@ type Server struct {
 
 	stoppedTTL      time.Duration
 	cleanupStopChan chan struct{}
+
+	configMu    sync.RWMutex // guards hooks and socketAuth
+	hooks       Hooks
+	socketAuth  socketAuth
+	tokenPath   string // set by WithTokenFile
+	configPath  string
+	configFlags Config
+
+	events eventBus
+
+	metrics       daemonMetrics
+	metricsServer *http.Server // set by ServeMetrics
+	tcpListener   net.Listener // set by ServeTCP
+
+	sessionLimit rateLimiter // per session name
+	clientLimit  rateLimiter // per Request.Client
+
+	clock Clock
+	ptys  PTYDriver
+
+	nextExecID int64
+
+	// Restarts (see handoff.go): handoff is what the previous daemon passed
+	// on; restarting pauses accepting until resumeAccept is closed, once a
+	// failed restart is undone; inflight counts requests being handled; and
+	// captureGate is held (read) by capture while it stores a read.
+	handoff      *handoffState
+	restarting   bool
+	resumeAccept chan struct{}
+	inflight     atomic.Int64
+	captureGate  sync.RWMutex
+
+	memCounters memoryCounters // evictions by the memory budget
 }
 
 type ServerOption func(*Server)
@@ -84,6 +199,38 @@ func WithStoppedTTL(ttl time.Duration) ServerOption {
 	}
 }
 
+// WithHooks sets the commands run around session lifecycle events.
+func WithHooks(hooks Hooks) ServerOption {
+	return func(s *Server) {
+		s.hooks = hooks
+	}
+}
+
+// WithConfig loads settings from the config file at path, with flags
+// (the values given on the daemon command line) taking precedence. The
+// result replaces WithStoppedTTL and WithHooks, and Reload re-reads path.
+func WithConfig(path string, flags Config) ServerOption {
+	return func(s *Server) {
+		s.configPath = path
+		s.configFlags = flags
+	}
+}
+
+// WithClock replaces the clock behind stop times and the stopped-session
+// TTL, kill grace periods, and the settle loops of snapshots and probes.
+func WithClock(clock Clock) ServerOption {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithPTYDriver replaces how session commands get and resize terminals.
+func WithPTYDriver(d PTYDriver) ServerOption {
+	return func(s *Server) {
+		s.ptys = d
+	}
+}
+
 func WithSocketDir(dir string) ServerOption {
 	return func(s *Server) {
 		s.socketDir = dir
@@ -99,8 +246,16 @@ func WithMaxOutputSize(size int) ServerOption {
 	}
 }
 
+// RuntimeDir holds the daemon socket and default data dir. With
+// SHELLI_WORKSPACE_DAEMON=1 inside a git repo it is a per-repo subdirectory.
 func RuntimeDir() (string, error) {
-	return filepath.Join(os.TempDir(), fmt.Sprintf("shelli-%d", os.Getuid())), nil
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("shelli-%d", os.Getuid()))
+	if os.Getenv(WorkspaceDaemonEnv) == "1" {
+		if root := CurrentWorkspace(); root != "" {
+			dir = filepath.Join(dir, workspaceDir(root))
+		}
+	}
+	return dir, nil
 }
 
 func NewServer(opts ...ServerOption) (*Server, error) {
@@ -117,11 +272,22 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 		socketDir:       runtimeDir,
 		storage:         NewMemoryStorage(DefaultMaxOutputSize),
 		cleanupStopChan: make(chan struct{}),
+		clock:           systemClock{},
+		ptys:            creackPTY{},
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
+	if mem, ok := s.storage.(*MemoryStorage); ok {
+		mem.OnEviction(s.noteEviction)
+	}
+
+	if s.configPath != "" {
+		if _, err := s.Reload(); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := s.recoverSessions(); err != nil {
 		return nil, fmt.Errorf("recover sessions: %w", err)
@@ -136,26 +302,54 @@ func (s *Server) recoverSessions() error {
 		return err
 	}
 
+	handedOff := make(map[string]handoffSession)
+	if s.handoff != nil {
+		for _, hs := range s.handoff.Sessions {
+			handedOff[hs.Name] = hs
+		}
+	}
+
 	for _, name := range sessions {
 		meta, err := s.storage.LoadMeta(name)
 		if err != nil {
 			continue
 		}
 
+		if hs, ok := handedOff[name]; ok && meta.State == StateRunning {
+			h, err := s.adoptSession(meta, hs)
+			if err == nil {
+				s.handles[name] = h
+				go s.captureOutput(name, h)
+				continue
+			}
+			log.Printf("handoff[%s]: %v", name, err)
+		}
+
 		if meta.State == StateRunning {
+			// The previous daemon went away without stopping it.
 			meta.State = StateStopped
-			now := time.Now()
+			now := s.clock.Now()
 			meta.StoppedAt = &now
+			meta.EndReason = EndReasonDaemonShutdown
 			s.storage.SaveMeta(name, meta)
 		}
 
 		s.handles[name] = &sessionHandle{
-			name:      meta.Name,
-			pid:       meta.PID,
-			command:   meta.Command,
-			state:     meta.State,
-			createdAt: meta.CreatedAt,
-			stoppedAt: meta.StoppedAt,
+			name:       meta.Name,
+			pid:        meta.PID,
+			command:    meta.Command,
+			state:      meta.State,
+			createdAt:  meta.CreatedAt,
+			stoppedAt:  meta.StoppedAt,
+			workspace:  meta.Workspace,
+			exitCode:   meta.ExitCode,
+			exitSignal: meta.ExitSignal,
+			endReason:  meta.EndReason,
+			endError:   meta.EndError,
+
+			capturePriority: meta.CapturePriority,
+			snapshotMode:    meta.SnapshotMode,
+			resizeCrash:     meta.ResizeCrash,
 		}
 	}
 
@@ -175,31 +369,44 @@ func (s *Server) socketPath() string {
 }
 
 func (s *Server) Start() error {
-	sockPath := s.socketPath()
-	os.Remove(sockPath)
-
-	listener, err := net.Listen("unix", sockPath)
+	listener, err := s.handoffListener()
 	if err != nil {
-		return fmt.Errorf("listen: %w", err)
+		return fmt.Errorf("inherited socket: %w", err)
 	}
+	if listener == nil {
+		sockPath := s.socketPath()
+		os.Remove(sockPath)
+		if listener, err = net.Listen("unix", sockPath); err != nil {
+			return fmt.Errorf("listen: %w", err)
+		}
+	} else {
+		log.Printf("restarted: took over %d running sessions", len(s.handoff.Sessions))
+	}
+	s.mu.Lock()
 	s.listener = listener
+	s.mu.Unlock()
 
-	if s.stoppedTTL > 0 {
-		go s.runCleanup()
-	}
+	go s.runCleanup()
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			s.mu.Lock()
 			isShutdown := s.listener == nil
+			resume := s.resumeAccept
+			restarting := s.restarting
 			s.mu.Unlock()
 			if isShutdown {
 				return nil
 			}
+			if restarting {
+				// Connections queue on the socket for the new daemon.
+				<-resume
+				continue
+			}
 			return err
 		}
-		go s.handleConn(conn)
+		go s.handleConn(conn, "")
 	}
 }
 
@@ -221,7 +428,11 @@ func (s *Server) cleanupExpiredSessions() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	now := time.Now()
+	if s.stoppedTTL <= 0 {
+		return
+	}
+
+	now := s.clock.Now()
 	for name, h := range s.handles {
 		if h.state == StateStopped && h.stoppedAt != nil {
 			if now.Sub(*h.stoppedAt) > s.stoppedTTL {
@@ -230,6 +441,13 @@ func (s *Server) cleanupExpiredSessions() {
 				}
 				s.storage.Delete(name)
 				delete(s.handles, name)
+				s.events.publish(StateChange{
+					EventHeader: EventHeader{Session: name, At: time.Now()},
+					From:        StateStopped,
+					To:          StateStopped,
+					Removed:     true,
+					Expired:     true,
+				})
 			}
 		}
 	}
@@ -237,15 +455,28 @@ func (s *Server) cleanupExpiredSessions() {
 
 func (s *Server) Shutdown() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	// Only captureOutput waits for a session's process: Shutdown kills it
+	// and waits for capture to have reaped it. That needs s.mu, so the
+	// wait comes after unlocking.
+	var exits []chan struct{}
+	defer func() {
+		s.mu.Unlock()
+		for _, exited := range exits {
+			<-exited
+		}
+	}()
 
 	close(s.cleanupStopChan)
 
-	for _, h := range s.handles {
+	for name, h := range s.handles {
 		if h.screen != nil {
 			h.screen.Close()
 		}
 		if h.state == StateRunning {
+			h.endReason = EndReasonDaemonShutdown
+			s.storage.UpdateMeta(name, func(meta *SessionMeta) {
+				meta.EndReason = EndReasonDaemonShutdown
+			})
 			if h.done != nil {
 				close(h.done)
 			}
@@ -254,7 +485,9 @@ func (s *Server) Shutdown() {
 			}
 			if h.cmd != nil {
 				h.cmd.Process.Kill()
-				h.cmd.Wait()
+			}
+			if h.pty != nil && h.exited != nil { // capture is running
+				exits = append(exits, h.exited)
 			}
 		}
 	}
@@ -263,43 +496,105 @@ func (s *Server) Shutdown() {
 		s.listener.Close()
 		s.listener = nil
 	}
+	if s.tcpListener != nil {
+		s.tcpListener.Close()
+	}
+	if s.metricsServer != nil {
+		s.metricsServer.Close()
+	}
 	os.Remove(s.socketPath())
 }
 
 type Request struct {
-	Version    int      `json:"version,omitempty"`
-	Action     string   `json:"action"`
-	Name       string   `json:"name,omitempty"`
-	Command    string   `json:"command,omitempty"`
-	Input      string   `json:"input,omitempty"`
-	Newline    bool     `json:"newline,omitempty"`
-	Mode       string   `json:"mode,omitempty"`
-	HeadLines  int      `json:"head_lines,omitempty"`
-	TailLines  int      `json:"tail_lines,omitempty"`
-	Cursor     string   `json:"cursor,omitempty"`
-	Pattern    string   `json:"pattern,omitempty"`
-	Before     int      `json:"before,omitempty"`
-	After      int      `json:"after,omitempty"`
-	IgnoreCase bool     `json:"ignore_case,omitempty"`
-	StripANSI  bool     `json:"strip_ansi,omitempty"`
-	Cols       int      `json:"cols,omitempty"`
-	Rows       int      `json:"rows,omitempty"`
-	Env        []string `json:"env,omitempty"`
-	Cwd        string   `json:"cwd,omitempty"`
-	TUIMode    bool     `json:"tui_mode,omitempty"`
-	Snapshot    bool `json:"snapshot,omitempty"`
-	SettleMs    int  `json:"settle_ms,omitempty"`
-	TimeoutSec  int  `json:"timeout_sec,omitempty"`
-	IfNotExists bool `json:"if_not_exists,omitempty"`
+	Version            int              `json:"version,omitempty"`
+	Action             string           `json:"action"`
+	Name               string           `json:"name,omitempty"`
+	Command            string           `json:"command,omitempty"`
+	Input              string           `json:"input,omitempty"`
+	Newline            bool             `json:"newline,omitempty"`
+	Mode               string           `json:"mode,omitempty"`
+	HeadLines          int              `json:"head_lines,omitempty"`
+	TailLines          int              `json:"tail_lines,omitempty"`
+	Newlines           string           `json:"newlines,omitempty"`
+	Cursor             string           `json:"cursor,omitempty"`
+	Pattern            string           `json:"pattern,omitempty"`
+	Before             int              `json:"before,omitempty"`
+	After              int              `json:"after,omitempty"`
+	IgnoreCase         bool             `json:"ignore_case,omitempty"`
+	StripANSI          bool             `json:"strip_ansi,omitempty"`
+	Cols               int              `json:"cols,omitempty"`
+	Rows               int              `json:"rows,omitempty"`
+	Env                []string         `json:"env,omitempty"`
+	Cwd                string           `json:"cwd,omitempty"`
+	TUIMode            bool             `json:"tui_mode,omitempty"`
+	Snapshot           bool             `json:"snapshot,omitempty"`
+	HoldSize           bool             `json:"hold_size,omitempty"` // snapshot without the resize jiggle
+	SettleMs           int              `json:"settle_ms,omitempty"`
+	TimeoutSec         int              `json:"timeout_sec,omitempty"`
+	IfNotExists        bool             `json:"if_not_exists,omitempty"`
+	Bundle             *SessionBundle   `json:"bundle,omitempty"`
+	FrameHistory       int              `json:"frame_history,omitempty"`
+	Frame              int              `json:"frame,omitempty"`
+	Scrollback         int              `json:"scrollback,omitempty"`
+	ScreenScrollback   bool             `json:"screen_scrollback,omitempty"`
+	CaptureRaw         string           `json:"capture_raw,omitempty"`
+	FrameBoundaries    []string         `json:"frame_boundaries,omitempty"`
+	ImageID            int              `json:"image_id,omitempty"`
+	AfterID            int              `json:"after_id,omitempty"`
+	Workspace          string           `json:"workspace,omitempty"`
+	Offset             int64            `json:"offset,omitempty"`
+	Limit              int64            `json:"limit,omitempty"`
+	Nice               *int             `json:"nice,omitempty"`
+	IOClass            string           `json:"io_class,omitempty"`
+	Limits             *ResourceLimits  `json:"limits,omitempty"`
+	MaxCPUMs           int64            `json:"max_cpu_ms,omitempty"`
+	MaxWallMs          int64            `json:"max_wall_ms,omitempty"`
+	Encoding           string           `json:"encoding,omitempty"`
+	ExecID             int64            `json:"exec_id,omitempty"`
+	Enter              string           `json:"enter,omitempty"`
+	Outcome            string           `json:"outcome,omitempty"`
+	Filter             string           `json:"filter,omitempty"`
+	Positions          map[string]int64 `json:"positions,omitempty"`
+	Line               int              `json:"line,omitempty"`
+	Verbose            bool             `json:"verbose,omitempty"`
+	Nonce              string           `json:"nonce,omitempty"`
+	MirrorInput        bool             `json:"mirror_input,omitempty"`
+	SwallowOutputUntil string           `json:"swallow_output_until,omitempty"`
+	CapturePriority    string           `json:"capture_priority,omitempty"`
+	SnapshotMode       string           `json:"snapshot_mode,omitempty"`
+	Since              string           `json:"since,omitempty"` // RFC 3339 time for since reads
+	IdleMs             int              `json:"idle_ms,omitempty"`
+	From               string           `json:"from,omitempty"` // diff: a position or cursor name
+	To                 string           `json:"to,omitempty"`
+	IdempotencyKey     string           `json:"idempotency_key,omitempty"`
+	Result             json.RawMessage  `json:"result,omitempty"`  // record_key: the exec's result
+	Failure            string           `json:"failure,omitempty"` // record_key: the exec's error
+	Styled             bool             `json:"styled,omitempty"`
+	Client             string           `json:"client,omitempty"` // who is asking, for per-client rate limits
+	Token              string           `json:"token,omitempty"`  // required by a daemon listening on TCP
+	Bookmark           string           `json:"bookmark,omitempty"`
+	Lines              bool             `json:"lines,omitempty"` // read: return LineRecords instead of a string
+	OnExit             []string         `json:"on_exit,omitempty"`
+	MaxBytes           int64            `json:"max_bytes,omitempty"` // read: page size
+	MaxLines           int              `json:"max_lines,omitempty"` // read: page size in lines
+	Continue           string           `json:"continue,omitempty"`  // read, search: a next token
+	DryRun             bool             `json:"dry_run,omitempty"`   // stop, kill, clear: report the Impact only
+	Render             bool             `json:"render,omitempty"`    // search: match the emulated screen's rows
 }
 
 type Response struct {
 	Success bool        `json:"success"`
 	Error   string      `json:"error,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	// Code classifies some errors (CodeRateLimited); RetryAfter, in
+	// seconds, is when a refused request may be tried again.
+	Code       string  `json:"code,omitempty"`
+	RetryAfter float64 `json:"retry_after,omitempty"`
 }
 
-func (s *Server) handleConn(conn net.Conn) {
+// handleConn serves one request. Connections from TCP clients pass the
+// token every request must carry; the socket passes "".
+func (s *Server) handleConn(conn net.Conn, token string) {
 	defer conn.Close()
 	defer func() {
 		if r := recover(); r != nil {
@@ -316,19 +611,48 @@ func (s *Server) handleConn(conn net.Conn) {
 	if req.Version != ProtocolVersion && req.Version != 0 {
 		s.sendResponse(conn, Response{
 			Success: false,
-			Error: fmt.Sprintf("protocol version mismatch: client=%d, daemon=%d. Restart daemon with: shelli daemon --stop && shelli daemon", req.Version, ProtocolVersion),
+			Error:   fmt.Sprintf("protocol version mismatch: client=%d, daemon=%d. Restart daemon with: shelli daemon --stop && shelli daemon", req.Version, ProtocolVersion),
 		})
 		return
 	}
 
+	if err := s.authorize(conn, req, token); err != nil {
+		log.Printf("refused %s request: %v", req.Action, err)
+		s.sendResponse(conn, Response{Success: false, Error: "unauthorized: " + err.Error(), Code: CodeUnauthorized})
+		return
+	}
+
+	if resp, limited := s.throttle(req); limited {
+		s.sendResponse(conn, resp)
+		return
+	}
+
+	if req.Action == "stream" {
+		s.handleStream(conn, req) // many responses on one connection
+		return
+	}
+	if req.Action == "events" {
+		s.handleEvents(conn, req)
+		return
+	}
+	if req.Action == "restart" {
+		s.handleRestart(conn) // replies, then replaces the process
+		return
+	}
+	s.inflight.Add(1)
+	defer s.inflight.Add(-1)
+
+	start := time.Now()
+	action := req.Action
 	var resp Response
 	switch req.Action {
 	case "create":
 		resp = s.handleCreate(req)
 	case "list":
-		resp = s.handleList()
+		resp = s.handleList(req)
 	case "read":
 		resp = s.handleRead(req)
+		s.countRead(req, resp)
 	case "send":
 		resp = s.handleSend(req)
 	case "stop":
@@ -339,17 +663,97 @@ func (s *Server) handleConn(conn net.Conn) {
 		resp = s.handleSearch(req)
 	case "info":
 		resp = s.handleInfo(req)
+	case "sibling":
+		resp = s.handleSibling(req)
+	case "probe":
+		resp = s.handleProbe(req)
+	case "health":
+		resp = s.handleHealth(req)
+	case "wait_exit":
+		resp = s.handleWaitExit(req)
+	case "track_job":
+		resp = s.handleTrackJob(req)
+	case "jobs":
+		resp = s.handleJobs(req)
+	case "wait_any":
+		resp = s.handleWaitAny(req)
+	case "bookmark":
+		resp = s.handleBookmark(req)
+	case "locate":
+		resp = s.handleLocate(req)
 	case "clear":
 		resp = s.handleClear(req)
+	case "compact":
+		resp = s.handleCompact(req)
 	case "resize":
 		resp = s.handleResize(req)
+	case "fit":
+		resp = s.handleFit(req)
+	case "renice":
+		resp = s.handleRenice(req)
+	case "reload":
+		resp = s.handleReload()
+	case "budget":
+		resp = s.handleBudget(req)
+	case "budget_result":
+		resp = s.handleBudgetResult(req)
+	case "diff":
+		resp = s.handleDiff(req)
+	case "claim_key":
+		resp = s.handleClaimKey(req)
+	case "record_key":
+		resp = s.handleRecordKey(req)
+	case "exec_begin":
+		resp = s.handleExecBegin(req)
+	case "exec_end":
+		resp = s.handleExecEnd(req)
+	case "exec_status":
+		resp = s.handleExecStatus(req)
+	case "remove_delimiter":
+		resp = s.handleRemoveDelimiter(req)
+	case "mirror_input":
+		resp = s.handleMirrorInput(req)
+	case "screen":
+		resp = s.handleScreen(req)
+	case "describe":
+		resp = s.handleDescribe(req)
+	case "input_log":
+		resp = s.handleInputLog(req)
+	case "activity":
+		resp = s.handleActivity(req)
+	case "freeze":
+		resp = s.handleFreeze(req)
+	case "thaw":
+		resp = s.handleThaw(req)
+	case "run_once":
+		resp = s.handleRunOnce(req)
+	case "pause":
+		resp = s.handlePause(req)
+	case "resume":
+		resp = s.handleResume(req)
 	case "size":
 		resp = s.handleSize(req)
+	case "export":
+		resp = s.handleExport(req)
+	case "import":
+		resp = s.handleImport(req)
+	case "frames":
+		resp = s.handleFrames(req)
+	case "images":
+		resp = s.handleImages(req)
+	case "notifications":
+		resp = s.handleNotifications(req)
+	case "du":
+		resp = s.handleDiskUsage()
+	case "metrics":
+		resp = s.handleMetrics()
 	case "ping":
 		resp = Response{Success: true, Data: "pong"}
 	default:
+		action = "unknown" // keep arbitrary names out of the metrics
 		resp = Response{Success: false, Error: "unknown action"}
 	}
+	s.metrics.observe(action, time.Since(start), resp.Success)
 
 	s.sendResponse(conn, resp)
 }
@@ -363,6 +767,26 @@ func (s *Server) handleCreate(req Request) Response {
 		return Response{Success: false, Error: err.Error()}
 	}
 
+	command := req.Command
+	if command == "" {
+		command = os.Getenv("SHELL")
+		if command == "" {
+			command = "/bin/sh"
+		}
+	}
+
+	if len(s.hooksFor(HookPreCreate)) > 0 {
+		s.mu.Lock()
+		_, exists := s.handles[req.Name]
+		s.mu.Unlock()
+		if !exists {
+			hc := hookContext{session: req.Name, command: command, workspace: req.Workspace}
+			if err := s.runPreHooks(HookPreCreate, hc); err != nil {
+				return Response{Success: false, Error: err.Error()}
+			}
+		}
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -378,20 +802,23 @@ func (s *Server) handleCreate(req Request) Response {
 		return Response{Success: false, Error: fmt.Sprintf("session %q already exists", req.Name)}
 	}
 
-	command := req.Command
-	if command == "" {
-		command = os.Getenv("SHELL")
-		if command == "" {
-			command = "/bin/sh"
-		}
+	if err := validateLimits(req.Limits); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	var limits *ResourceLimits
+	if l := req.Limits; !l.empty() {
+		// Cgroup is the daemon's to set.
+		limits = &ResourceLimits{MemoryBytes: l.MemoryBytes, CPUSeconds: l.CPUSeconds, CPUPercent: l.CPUPercent}
 	}
 
-	var cmd *exec.Cmd
+	args := []string{command}
 	if strings.Contains(command, " ") {
-		cmd = exec.Command("sh", "-c", command) // #nosec G702 -- executing user-provided commands is the core feature
-	} else {
-		cmd = exec.Command(command) // #nosec G702 -- executing user-provided commands is the core feature
+		args = []string{"sh", "-c", command}
+	}
+	if limits != nil {
+		args = limitArgs(args, limits)
 	}
+	cmd := exec.Command(args[0], args[1:]...) // #nosec G702 -- executing user-provided commands is the core feature
 
 	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
 	cmd.Env = append(cmd.Env, req.Env...)
@@ -399,6 +826,10 @@ func (s *Server) handleCreate(req Request) Response {
 	if req.Cwd != "" {
 		cmd.Dir = req.Cwd
 	}
+	cwd := cmd.Dir
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
 
 	cols := req.Cols
 	if cols <= 0 {
@@ -409,48 +840,177 @@ func (s *Server) handleCreate(req Request) Response {
 		rows = 24
 	}
 
-	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+	if req.TUIMode {
+		if _, err := vterm.LookupBoundaries(req.FrameBoundaries); err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		if req.MirrorInput {
+			return Response{Success: false, Error: "input mirroring is not available in TUI mode"}
+		}
+		if req.SwallowOutputUntil != "" {
+			return Response{Success: false, Error: "swallowing startup output is not available in TUI mode"}
+		}
+	}
+	var banner *bannerWindow
+	if req.SwallowOutputUntil != "" {
+		w, err := newBannerWindow(req.SwallowOutputUntil, time.Now())
+		if err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		banner = w
+	}
+	if err := validatePriority(req.Nice, req.IOClass); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	capturePriority, err := resolveCapturePriority(req.CapturePriority, req.TUIMode)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	snapshotMode, err := s.resolveSnapshotMode(req.SnapshotMode, command, req.TUIMode)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	var charset encoding.Encoding
+	if req.Encoding != "" {
+		enc, err := lookupEncoding(req.Encoding)
+		if err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		charset = enc
+	}
+
+	var capture *rawCapture
+	if req.CaptureRaw != "" {
+		c, err := openRawCapture(req.CaptureRaw)
+		if err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		capture = c
+	}
+
+	var cgroup string
+	if limits != nil {
+		if cgroup, err = newSessionCgroup(req.Name, limits); err != nil {
+			if capture != nil {
+				capture.Close()
+			}
+			return Response{Success: false, Error: err.Error()}
+		}
+		limits.Cgroup = cgroup
+	}
+
+	ptmx, err := s.ptys.Start(cmd, cols, rows)
 	if err != nil {
+		removeCgroup(cgroup)
+		if capture != nil {
+			capture.Close()
+		}
 		return Response{Success: false, Error: fmt.Sprintf("start pty: %v", err)}
 	}
 
+	err = applyPriority(cmd.Process.Pid, req.Nice, req.IOClass)
+	if err == nil && cgroup != "" {
+		err = joinCgroup(cgroup, cmd.Process.Pid)
+	}
+	if err != nil {
+		ptmx.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+		removeCgroup(cgroup)
+		if capture != nil {
+			capture.Close()
+		}
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	// Capturing a frame renders the whole screen at every redraw, so
+	// history is opt-in.
+	frameHistory := 0
+	if req.TUIMode && req.FrameHistory > 0 {
+		frameHistory = req.FrameHistory
+	}
+	scrollback := 0
+	if req.TUIMode && req.Scrollback > 0 {
+		scrollback = req.Scrollback
+	}
+
 	now := time.Now()
 	meta := &SessionMeta{
-		Name:      req.Name,
-		Command:   command,
-		PID:       cmd.Process.Pid,
-		State:     StateRunning,
-		CreatedAt: now,
-		ReadPos:   0,
-		Cols:      cols,
-		Rows:      rows,
-		TUIMode:   req.TUIMode,
+		Name:         req.Name,
+		Command:      command,
+		PID:          cmd.Process.Pid,
+		State:        StateRunning,
+		CreatedAt:    now,
+		ReadPos:      0,
+		Cols:         cols,
+		Rows:         rows,
+		TUIMode:      req.TUIMode,
+		FrameHistory: frameHistory,
+		Scrollback:   scrollback,
+		CaptureRaw:   req.CaptureRaw,
+		Workspace:    req.Workspace,
+		Nice:         req.Nice,
+		IOClass:      req.IOClass,
+		Limits:       limits,
+		Encoding:     req.Encoding,
+		MirrorInput:  req.MirrorInput,
+		OnExit:       req.OnExit,
+
+		CapturePriority: capturePriority,
+		SnapshotMode:    snapshotMode,
+	}
+	if req.TUIMode {
+		meta.FrameBoundaries = req.FrameBoundaries
 	}
 
 	if err := s.storage.Create(req.Name, meta); err != nil {
 		ptmx.Close()
 		cmd.Process.Kill()
+		cmd.Wait()
+		removeCgroup(cgroup)
+		if capture != nil {
+			capture.Close()
+		}
 		return Response{Success: false, Error: fmt.Sprintf("create storage: %v", err)}
 	}
 
 	h := &sessionHandle{
-		name:      req.Name,
-		pid:       cmd.Process.Pid,
-		command:   command,
-		state:     StateRunning,
-		createdAt: now,
-		pty:       &ptyHandle{f: ptmx},
-		cmd:       cmd,
-		done:      make(chan struct{}),
-	}
+		name:        req.Name,
+		pid:         cmd.Process.Pid,
+		command:     command,
+		state:       StateRunning,
+		createdAt:   now,
+		pty:         &ptyHandle{f: ptmx},
+		cmd:         cmd,
+		done:        make(chan struct{}),
+		exited:      make(chan struct{}),
+		capture:     capture,
+		workspace:   req.Workspace,
+		cwd:         cwd,
+		env:         req.Env,
+		cgroup:      cgroup,
+		charset:     charset,
+		mirrorInput: req.MirrorInput,
+		banner:      banner,
+		onExit:      req.OnExit,
+
+		capturePriority: capturePriority,
+		snapshotMode:    snapshotMode,
+	}
+	h.clock.start()
 	if req.TUIMode {
 		h.screen = vterm.New(cols, rows)
+		h.screen.SetFrameHistory(frameHistory)
+		h.screen.SetScrollback(scrollback)
+		h.screen.SetFrameBoundaries(req.FrameBoundaries) // validated above
 		go h.screen.ReadResponses(ptmx)
 	}
 
 	s.handles[req.Name] = h
 
 	go s.captureOutput(req.Name, h)
+	s.publishState(req.Name, "", StateRunning, false)
+	s.runPostHooks(HookPostCreate, h.hookContext())
 
 	return Response{Success: true, Data: map[string]interface{}{
 		"name":       h.name,
@@ -474,6 +1034,11 @@ func (s *Server) captureOutput(name string, h *sessionHandle) {
 	p := h.pty
 	cmd := h.cmd
 	screen := h.screen
+	capture := h.capture
+	charset := h.charset
+	banner := h.banner
+	cgroup := h.cgroup
+	policy := capturePolicies[h.capturePriority]
 	storage := s.storage
 	s.mu.Unlock()
 
@@ -483,29 +1048,100 @@ func (s *Server) captureOutput(name string, h *sessionHandle) {
 
 	f := p.File()
 
+	var decoder *outputDecoder
+	if charset != nil {
+		decoder = newOutputDecoder(charset)
+	}
+
+	// readErr is the PTY read error that ended capture, nil when told to
+	// stop.
+	var readErr error
 	defer func() {
+		if !ptyClosed(readErr) {
+			// The process may still run; closing the PTY hangs it up
+			// rather than leaving Wait blocked on it.
+			log.Printf("capture[%s]: %v", name, readErr)
+			p.Close()
+		}
 		cmd.Wait()
 		p.Close()
+		removeCgroup(cgroup)
+		if capture != nil {
+			capture.Close()
+		}
+		s.closeBanner(name, banner)
 
 		s.mu.Lock()
 		defer s.mu.Unlock()
 
+		// Still running means the process exited on its own, rather than
+		// through stop or kill.
+		exited := h.state == StateRunning && s.handles[name] == h
+
 		h.pty = nil
 		h.cmd = nil
 		h.done = nil
+		h.capture = nil
+		h.altScreen = false
+		h.pause.paused.Store(false)
+		h.freeze.groups = nil
 		// screen stays alive for post-stop reads
 
 		h.state = StateStopped
-		now := time.Now()
+		now := s.clock.Now()
 		h.stoppedAt = &now
 
 		s.storage.UpdateMeta(name, func(meta *SessionMeta) {
 			meta.State = StateStopped
 			meta.StoppedAt = &now
 		})
+		s.recordExit(name, h, cmd.ProcessState, readErr)
+		if exited {
+			s.noteResizeCrash(name, h)
+		}
+
+		if exited {
+			s.publishStop(name, h)
+			s.runPostHooks(HookPostStop, h.hookContext())
+		}
+		if s.handles[name] == h && h.endReason != EndReasonDaemonShutdown {
+			s.runExitHooks(name, h)
+		}
+	}()
+
+	// Storage first: the other consumers read what it stored.
+	cancels := []func(){
+		h.output.subscribe(func(text []byte) { s.storeOutput(name, screen, storage, text) }),
+		h.output.subscribe(func(text []byte) { s.publishOutput(name, text) }),
+	}
+	if screen == nil {
+		cancels = append(cancels, h.output.subscribe(func(text []byte) {
+			if h.bookmarks.active() {
+				s.watchBookmarks(name, h, storage, text)
+			}
+		}))
+	}
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+
+	var images vterm.ImageExtractor
+	var notify vterm.NotifyExtractor
+	var altScreen vterm.AltScreenTracker
+	defer func() {
+		rest, _ := notify.Process(images.Flush())
+		rest = append(rest, notify.Flush()...)
+		if len(rest) > 0 {
+			h.output.publish(rest)
+		}
 	}()
 
-	buf := make([]byte, ReadBufferSize)
+	if policy.bufferSize == 0 {
+		policy = capturePolicies[CapturePriorityNormal]
+	}
+	buf := make([]byte, policy.bufferSize)
 	for {
 		select {
 		case <-done:
@@ -516,19 +1152,94 @@ func (s *Server) captureOutput(name string, h *sessionHandle) {
 		f.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
 		n, err := f.Read(buf)
 		if n > 0 {
+			first := time.Now()
+			reads := 1
+			if policy.coalesce > 0 && err == nil {
+				n, reads, err = coalesceRead(f, buf, n, first.Add(policy.coalesce))
+			}
+			s.captureGate.RLock()
+			h.traffic.ptyIn.Add(int64(n))
+			h.clock.noteOutput()
+			h.activity.add(n, monoNow())
 			data := buf[:n]
-			if screen != nil {
-				screen.Write(data)
-			} else {
-				storage.Append(name, data)
+			if capture != nil {
+				if err := capture.Write(data); err != nil {
+					log.Printf("capture[%s]: %v (raw capture disabled)", name, err)
+					capture.Close()
+					capture = nil
+				}
+			}
+			if decoder != nil {
+				data = decoder.decode(data)
 			}
+			text, imgs := images.Process(data)
+			if len(imgs) > 0 {
+				s.addImages(h, imgs)
+			}
+			text, notes := notify.Process(text)
+			if len(notes) > 0 {
+				s.addNotifications(h, notes)
+			}
+			if banner != nil && len(text) > 0 {
+				var closed bool
+				if text, closed = banner.swallow(text, time.Now()); closed {
+					s.saveBanner(name, banner)
+				}
+			}
+			if len(text) > 0 {
+				if h.pause.paused.Load() {
+					h.pause.drop(len(text))
+				} else {
+					h.output.publish(text)
+				}
+			}
+			for _, active := range altScreen.Process(text) {
+				s.setAltScreen(name, h, active)
+			}
+			h.captureStats.record(reads, n, time.Since(first))
+			s.captureGate.RUnlock()
 		}
 		if err != nil && !isTimeout(err) {
+			readErr = err
 			return
 		}
 	}
 }
 
+// storeOutput feeds output to the session's screen or storage, publishing
+// any output the memory buffer dropped to make room.
+func (s *Server) storeOutput(name string, screen *vterm.Screen, storage OutputStorage, text []byte) {
+	if screen != nil {
+		screen.Write(text)
+		return
+	}
+	if !s.events.active() {
+		storage.Append(name, text)
+		return
+	}
+	before, _ := storage.Size(name)
+	storage.Append(name, text)
+	after, _ := storage.Size(name)
+	if dropped := before + int64(len(text)) - after; dropped > 0 {
+		s.events.publish(Truncation{
+			EventHeader: EventHeader{Session: name, At: time.Now()},
+			Reason:      TruncationBufferLimit,
+			Bytes:       dropped,
+		})
+	}
+}
+
+// publishOutput publishes output to event subscribers.
+func (s *Server) publishOutput(name string, text []byte) {
+	if !s.events.active() {
+		return
+	}
+	s.events.publish(OutputChunk{
+		EventHeader: EventHeader{Session: name, At: time.Now()},
+		Data:        append([]byte(nil), text...),
+	})
+}
+
 func isTimeout(err error) bool {
 	if netErr, ok := err.(interface{ Timeout() bool }); ok {
 		return netErr.Timeout()
@@ -536,10 +1247,8 @@ func isTimeout(err error) bool {
 	return false
 }
 
-func (s *Server) handleList() Response {
+func (s *Server) handleList(req Request) Response {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	result := make([]SessionInfo, 0, len(s.handles))
 	for _, h := range s.handles {
 		info := SessionInfo{
@@ -548,6 +1257,9 @@ func (s *Server) handleList() Response {
 			Command:   h.command,
 			CreatedAt: h.createdAt.Format(time.RFC3339),
 			State:     string(h.state),
+			Workspace: h.workspace,
+			Frozen:    h.freeze.frozen(),
+			EndReason: h.endReason,
 		}
 		if h.stoppedAt != nil {
 			info.StoppedAt = h.stoppedAt.Format(time.RFC3339)
@@ -555,45 +1267,153 @@ func (s *Server) handleList() Response {
 		result = append(result, info)
 	}
 
+	s.mu.Unlock()
+
 	sort.Slice(result, func(i, j int) bool {
 		return result[i].CreatedAt < result[j].CreatedAt
 	})
 
+	if req.Verbose {
+		for i := range result {
+			result[i].Health, _ = s.checkHealth(result[i].Name)
+		}
+	}
+
 	return Response{Success: true, Data: result}
 }
 
-func (s *Server) handleRead(req Request) Response {
-	if req.Snapshot {
-		return s.handleSnapshot(req)
+func (s *Server) handleDiskUsage() Response {
+	type entry struct {
+		usage SessionUsage
+		since time.Time
 	}
 
 	s.mu.Lock()
-	h, exists := s.handles[req.Name]
-	if !exists {
-		s.mu.Unlock()
-		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
-	}
-	sessState := h.state
-	screen := h.screen
 	storage := s.storage
+	entries := make([]entry, 0, len(s.handles))
+	known := make(map[string]bool, len(s.handles))
+	for _, h := range s.handles {
+		e := entry{
+			usage: SessionUsage{
+				Name:      h.name,
+				State:     string(h.state),
+				CreatedAt: h.createdAt.Format(time.RFC3339),
+			},
+			since: h.createdAt,
+		}
+		if h.stoppedAt != nil {
+			e.usage.StoppedAt = h.stoppedAt.Format(time.RFC3339)
+			e.since = *h.stoppedAt
+		}
+		entries = append(entries, e)
+		known[h.name] = true
+	}
 	s.mu.Unlock()
 
-	if screen != nil {
-		return s.handleReadTUI(req, h, screen)
+	report := UsageReport{Backend: "memory", Sessions: make([]SessionUsage, 0, len(entries))}
+	fs, onDisk := storage.(*FileStorage)
+	if onDisk {
+		report.Backend = "file"
+		report.DataDir = fs.dataDir
 	}
 
-	meta, err := storage.LoadMeta(req.Name)
-	if err != nil {
-		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	now := time.Now()
+	for _, e := range entries {
+		u := e.usage
+		u.AgeSec = now.Sub(e.since).Seconds()
+		u.StoredBytes, _ = storage.Size(u.Name)
+		if onDisk {
+			u.DiskBytes = fs.sessionDiskUsage(u.Name)
+			report.TotalBytes += u.DiskBytes
+		} else {
+			u.MemoryBytes = u.StoredBytes
+			report.TotalBytes += u.MemoryBytes
+		}
+		report.Sessions = append(report.Sessions, u)
 	}
+	sort.Slice(report.Sessions, func(i, j int) bool {
+		return report.Sessions[i].CreatedAt < report.Sessions[j].CreatedAt
+	})
 
-	mode := req.Mode
-	if mode == "" {
-		mode = ReadModeNew
+	if mem, ok := storage.(*MemoryStorage); ok {
+		s.memoryUsage(&report, mem)
 	}
 
-	var result string
+	if onDisk {
+		report.Orphans = fs.orphanFiles(known)
+		for _, o := range report.Orphans {
+			report.TotalBytes += o.Bytes
+		}
+	}
+	return Response{Success: true, Data: report}
+}
+
+func (s *Server) handleRead(req Request) Response {
+	if req.Snapshot {
+		return s.handleSnapshot(req)
+	}
+	if req.Frame != 0 {
+		return s.handleReadFrame(req)
+	}
+	if req.ScreenScrollback {
+		return s.handleReadScrollback(req)
+	}
+	if req.Continue != "" {
+		t, err := decodePageToken(req.Continue, "read", req.Name)
+		if err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		req = t.continueRead(req)
+	}
+	if err := ValidateNewlines(req.Newlines); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	if req.MaxBytes < 0 || req.MaxLines < 0 {
+		return Response{Success: false, Error: "max_bytes and max_lines must not be negative"}
+	}
+	paged := req.MaxBytes > 0 || req.MaxLines > 0
+	if paged && (req.Mode == ReadModeTail || req.Mode == ReadModeBookmark) {
+		return Response{Success: false, Error: fmt.Sprintf("max_bytes and max_lines do not apply to %s reads", req.Mode)}
+	}
+
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	sessState := h.state
+	screen := h.screen
+	storage := s.storage
+	s.mu.Unlock()
+
+	if screen != nil {
+		if req.Mode == ReadModeRange || req.Mode == ReadModeTail || req.Mode == ReadModeSince || req.Mode == ReadModeBookmark {
+			return Response{Success: false, Error: fmt.Sprintf("session %q is in TUI mode (%s reads need raw output)", req.Name, req.Mode)}
+		}
+		if req.Lines {
+			return Response{Success: false, Error: fmt.Sprintf("session %q is in TUI mode (line reads need raw output)", req.Name)}
+		}
+		if paged {
+			return Response{Success: false, Error: fmt.Sprintf("session %q is in TUI mode (paged reads need raw output)", req.Name)}
+		}
+		return s.handleReadTUI(req, h, screen)
+	}
+
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = ReadModeNew
+	}
+
+	var result string
 	var totalLen int64
+	var truncations int64
+	var bookmark *Bookmark
 
 	switch mode {
 	case ReadModeNew:
@@ -614,14 +1434,19 @@ func (s *Server) handleRead(req Request) Response {
 		if readPos >= totalLen {
 			result = ""
 		} else {
-			output, err := storage.ReadFrom(req.Name, readPos)
+			output, err := readPage(storage, req.Name, readPos, req.MaxBytes, req.MaxLines)
 			if err != nil {
 				return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
 			}
 			result = string(output)
+			if paged {
+				totalLen = readPos + int64(len(output))
+			}
 		}
+		truncations = meta.Truncations[req.Cursor]
 
 		storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+			m.takeTruncations(req.Cursor, truncations)
 			if req.Cursor != "" {
 				if m.Cursors == nil {
 					m.Cursors = make(map[string]int64)
@@ -631,8 +1456,72 @@ func (s *Server) handleRead(req Request) Response {
 				m.ReadPos = totalLen
 			}
 		})
+	case ReadModeRange:
+		if req.Offset < 0 || req.Limit < 0 {
+			return Response{Success: false, Error: "offset and limit must not be negative"}
+		}
+		var output []byte
+		if req.MaxLines > 0 || (req.MaxBytes > 0 && (req.Limit == 0 || req.MaxBytes < req.Limit)) {
+			maxBytes := req.MaxBytes
+			if req.Limit > 0 && (maxBytes == 0 || req.Limit < maxBytes) {
+				maxBytes = req.Limit
+			}
+			output, err = readPage(storage, req.Name, req.Offset, maxBytes, req.MaxLines)
+		} else {
+			output, err = storage.ReadRange(req.Name, req.Offset, req.Limit)
+		}
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+		}
+		result = string(output)
+		totalLen = req.Offset + int64(len(output))
+	case ReadModeTail:
+		if req.Limit <= 0 {
+			return Response{Success: false, Error: "tail reads need a positive limit"}
+		}
+		size, err := storage.Size(req.Name)
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("get size: %v", err)}
+		}
+		from := max(0, size-req.Limit-TailBytesLookback)
+		output, err := storage.ReadFrom(req.Name, from)
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+		}
+		result = string(SafeTailBytes(output, int(req.Limit)))
+		totalLen = from + int64(len(output))
+	case ReadModeSince:
+		since, err := time.Parse(time.RFC3339Nano, req.Since)
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("invalid since time %q: %v", req.Since, err)}
+		}
+		from, err := sinceOffset(storage, req.Name, since)
+		if err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		output, err := readPage(storage, req.Name, from, req.MaxBytes, req.MaxLines)
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+		}
+		result = string(output)
+		totalLen = from + int64(len(output))
+	case ReadModeBookmark:
+		if req.Before < 0 || req.After < 0 {
+			return Response{Success: false, Error: "before and after must be non-negative"}
+		}
+		b, ok := meta.bookmark(req.Bookmark)
+		if !ok {
+			return Response{Success: false, Error: fmt.Sprintf("bookmark %q not found in session %q", req.Bookmark, req.Name)}
+		}
+		output, _, end, err := readAround(storage, req.Name, b.Offset, req.Before, req.After)
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+		}
+		result = string(output)
+		totalLen = end
+		bookmark = &b
 	default:
-		output, err := storage.ReadAll(req.Name)
+		output, err := readPage(storage, req.Name, 0, req.MaxBytes, req.MaxLines)
 		if err != nil {
 			return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
 		}
@@ -640,15 +1529,66 @@ func (s *Server) handleRead(req Request) Response {
 		totalLen = int64(len(output))
 	}
 
+	// A page says whether output goes on past it, and hands out a token
+	// for the next one.
+	var next string
+	if paged {
+		size, err := storage.Size(req.Name)
+		if err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("get size: %v", err)}
+		}
+		if totalLen < size {
+			next = nextReadToken(req, mode, totalLen).encode()
+		}
+	}
+
+	if req.Lines {
+		records, truncated, err := readLineRecords(storage, req.Name, result, totalLen, req)
+		if err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		data := map[string]interface{}{
+			"lines":    records,
+			"position": totalLen,
+			"state":    sessState,
+		}
+		if truncated > 0 {
+			data["long_lines_truncated"] = truncated
+		}
+		if mode == ReadModeNew {
+			data["truncations_since_last_read"] = truncations
+		}
+		if paged {
+			setPage(data, next)
+		}
+		return Response{Success: true, Data: data}
+	}
+
+	result = NormalizeNewlines(result, req.Newlines)
+
+	var truncated int
 	if req.HeadLines > 0 || req.TailLines > 0 {
-		result = LimitLines(result, req.HeadLines, req.TailLines)
+		result, truncated = limitLines(result, req.HeadLines, req.TailLines)
 	}
 
-	return Response{Success: true, Data: map[string]interface{}{
+	data := map[string]interface{}{
 		"output":   result,
 		"position": totalLen,
 		"state":    sessState,
-	}}
+	}
+	if truncated > 0 {
+		data["long_lines_truncated"] = truncated
+	}
+	if mode == ReadModeNew {
+		data["truncations_since_last_read"] = truncations
+	}
+	if bookmark != nil {
+		data["bookmark"] = bookmark
+	}
+	if paged {
+		setPage(data, next)
+	}
+	return Response{Success: true, Data: data}
 }
 
 func (s *Server) handleReadTUI(req Request, h *sessionHandle, screen *vterm.Screen) Response {
@@ -663,6 +1603,7 @@ func (s *Server) handleReadTUI(req Request, h *sessionHandle, screen *vterm.Scre
 	}
 
 	var result string
+	var truncations int64
 	currentVersion := int64(screen.Version()) // #nosec G115 -- version counter won't reach int64 max
 
 	switch mode {
@@ -681,8 +1622,10 @@ func (s *Server) handleReadTUI(req Request, h *sessionHandle, screen *vterm.Scre
 		} else {
 			result = screen.Render()
 		}
+		truncations = meta.Truncations[req.Cursor]
 
 		s.storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+			m.takeTruncations(req.Cursor, truncations)
 			if req.Cursor != "" {
 				if m.Cursors == nil {
 					m.Cursors = make(map[string]int64)
@@ -700,35 +1643,261 @@ func (s *Server) handleReadTUI(req Request, h *sessionHandle, screen *vterm.Scre
 		result = LimitLines(result, req.HeadLines, req.TailLines)
 	}
 
-	return Response{Success: true, Data: map[string]interface{}{
+	data := map[string]interface{}{
 		"output":   result,
 		"position": currentVersion,
 		"state":    h.state,
+	}
+	if mode == ReadModeNew {
+		data["truncations_since_last_read"] = truncations
+	}
+	return Response{Success: true, Data: data}
+}
+
+func (s *Server) sessionFrames(name string) ([]vterm.Frame, SessionState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, exists := s.handles[name]
+	if !exists {
+		return nil, "", fmt.Errorf("session %q not found", name)
+	}
+	if h.screen == nil {
+		return nil, "", fmt.Errorf("session %q is not in TUI mode (frame history requires --tui)", name)
+	}
+	if h.screen.FrameHistory() == 0 {
+		return nil, "", fmt.Errorf("session %q keeps no frame history (create it with --frame-history N)", name)
+	}
+	return h.screen.Frames(), h.state, nil
+}
+
+func (s *Server) handleReadFrame(req Request) Response {
+	if req.Frame > 0 {
+		return Response{Success: false, Error: "frame must be negative (-1 is the most recent past frame)"}
+	}
+
+	frames, state, err := s.sessionFrames(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	idx := len(frames) + req.Frame
+	if idx < 0 {
+		return Response{Success: false, Error: fmt.Sprintf("frame %d not available (%d frames captured)", req.Frame, len(frames))}
+	}
+	frame := frames[idx]
+
+	result := frame.Content
+	if req.HeadLines > 0 || req.TailLines > 0 {
+		result = LimitLines(result, req.HeadLines, req.TailLines)
+	}
+
+	return Response{Success: true, Data: map[string]interface{}{
+		"output":      result,
+		"position":    int64(frame.Version), // #nosec G115 -- version counter won't reach int64 max
+		"state":       state,
+		"captured_at": frame.CapturedAt.Format(time.RFC3339Nano),
 	}}
 }
 
+func (s *Server) handleReadScrollback(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	screen := h.screen
+	state := h.state
+	s.mu.Unlock()
+
+	if screen == nil {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is not in TUI mode (screen scrollback requires --tui)", req.Name)}
+	}
+
+	rows := screen.Scrollback()
+	result := screen.String()
+	if len(rows) > 0 {
+		result = strings.Join(rows, "\n") + "\n" + result
+	}
+	if req.HeadLines > 0 || req.TailLines > 0 {
+		result = LimitLines(result, req.HeadLines, req.TailLines)
+	}
+
+	return Response{Success: true, Data: map[string]interface{}{
+		"output":          result,
+		"position":        int64(screen.Version()), // #nosec G115 -- version counter won't reach int64 max
+		"state":           state,
+		"scrollback_rows": len(rows),
+	}}
+}
+
+func (s *Server) addImages(h *sessionHandle, imgs []vterm.InlineImage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, img := range imgs {
+		h.nextImageID++
+		h.images = append(h.images, sessionImage{id: h.nextImageID, capturedAt: now, img: img})
+	}
+	if len(h.images) > MaxSessionImages {
+		h.images = append([]sessionImage(nil), h.images[len(h.images)-MaxSessionImages:]...)
+	}
+}
+
+func (s *Server) handleImages(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	images := h.images
+	s.mu.Unlock()
+
+	if req.ImageID == 0 {
+		result := make([]ImageInfo, 0, len(images))
+		for _, si := range images {
+			result = append(result, si.info())
+		}
+		return Response{Success: true, Data: result}
+	}
+
+	for _, si := range images {
+		if si.id == req.ImageID {
+			return Response{Success: true, Data: ImageData{ImageInfo: si.info(), Data: si.img.Data}}
+		}
+	}
+	return Response{Success: false, Error: fmt.Sprintf("image %d not found in session %q", req.ImageID, req.Name)}
+}
+
+func (si sessionImage) info() ImageInfo {
+	return ImageInfo{
+		ID:         si.id,
+		Protocol:   si.img.Protocol,
+		Name:       si.img.Name,
+		Format:     si.img.Format,
+		Bytes:      len(si.img.Data),
+		CapturedAt: si.capturedAt.Format(time.RFC3339Nano),
+	}
+}
+
+func (s *Server) addNotifications(h *sessionHandle, notes []vterm.Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, n := range notes {
+		h.nextNotificationID++
+		h.notifications = append(h.notifications, sessionNotification{id: h.nextNotificationID, at: now, n: n})
+	}
+	if len(h.notifications) > MaxSessionNotifications {
+		h.notifications = append([]sessionNotification(nil), h.notifications[len(h.notifications)-MaxSessionNotifications:]...)
+	}
+}
+
+func (s *Server) handleNotifications(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	notes := h.notifications
+	s.mu.Unlock()
+
+	result := make([]NotificationInfo, 0, len(notes))
+	for _, sn := range notes {
+		if sn.id <= req.AfterID {
+			continue
+		}
+		result = append(result, NotificationInfo{
+			ID:    sn.id,
+			Kind:  sn.n.Kind,
+			Title: sn.n.Title,
+			Body:  sn.n.Body,
+			At:    sn.at.Format(time.RFC3339Nano),
+		})
+	}
+	return Response{Success: true, Data: result}
+}
+
+func (s *Server) handleFrames(req Request) Response {
+	frames, _, err := s.sessionFrames(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	result := make([]FrameInfo, 0, len(frames))
+	for i, f := range frames {
+		result = append(result, FrameInfo{
+			Index:      i - len(frames),
+			Version:    f.Version,
+			CapturedAt: f.CapturedAt.Format(time.RFC3339Nano),
+			Lines:      strings.Count(f.Content, "\n") + 1,
+		})
+	}
+
+	return Response{Success: true, Data: result}
+}
+
 func LimitLines(output string, head, tail int) string {
+	result, _ := limitLines(output, head, tail)
+	return result
+}
+
+// limitLines is LimitLines, also reporting how many of the kept lines were
+// cut to MaxLineLength. A single huge line would otherwise defeat the limit.
+func limitLines(output string, head, tail int) (string, int) {
 	if output == "" {
-		return ""
+		return "", 0
 	}
 
 	lines := strings.Split(output, "\n")
 
-	if head > 0 {
-		if head >= len(lines) {
-			return output
+	switch {
+	case head > 0:
+		if head < len(lines) {
+			output = strings.Join(lines[:head], "\n")
+		}
+	case tail > 0:
+		if tail < len(lines) {
+			output = strings.Join(lines[len(lines)-tail:], "\n")
 		}
-		return strings.Join(lines[:head], "\n")
 	}
 
-	if tail > 0 {
-		if tail >= len(lines) {
-			return output
+	return TruncateLongLines(output, MaxLineLength)
+}
+
+// TruncateLongLines cuts every line longer than maxLen bytes and marks the
+// cut with an ellipsis and the number of bytes dropped. It returns the
+// result and the number of lines cut.
+func TruncateLongLines(s string, maxLen int) (string, int) {
+	if len(s) <= maxLen {
+		return s, 0
+	}
+	lines := strings.Split(s, "\n")
+	count := 0
+	for i, line := range lines {
+		var cut bool
+		if lines[i], cut = truncateLine(line, maxLen); cut {
+			count++
 		}
-		return strings.Join(lines[len(lines)-tail:], "\n")
 	}
+	if count == 0 {
+		return s, 0
+	}
+	return strings.Join(lines, "\n"), count
+}
 
-	return output
+func truncateLine(line string, maxLen int) (string, bool) {
+	if len(line) <= maxLen {
+		return line, false
+	}
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s… [%d bytes truncated]", line[:cut], len(line)-cut), true
 }
 
 func (s *Server) handleSnapshot(req Request) Response {
@@ -750,44 +1919,61 @@ func (s *Server) handleSnapshot(req Request) Response {
 		s.mu.Unlock()
 		return Response{Success: false, Error: fmt.Sprintf("session %q PTY not available", req.Name)}
 	}
-	ptmx := h.pty.File()
+	p := h.pty
 	cmd := h.cmd
 	screen := h.screen
 	storage := s.storage
+	passive := h.snapshotMode == SnapshotModePassive
+	holdSize := req.HoldSize || h.viewers > 0 || passive
+	if !holdSize {
+		h.resizedAt = s.clock.Now()
+	}
+	traffic := &h.traffic
 	s.mu.Unlock()
 
+	// How the snapshot went, so callers can tell a settled screen from
+	// whatever was there when time ran out.
+	started := s.clock.Now()
+	bytesBefore := traffic.ptyIn.Load()
+	resizeCycles := 0
+	settled := false
+
 	meta, err := storage.LoadMeta(req.Name)
 	if err != nil {
 		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
 	}
 
 	if screen.Version() == 0 {
-		coldDeadline := time.Now().Add(2 * time.Second)
-		for time.Now().Before(coldDeadline) {
-			time.Sleep(SnapshotPollInterval)
+		coldDeadline := s.clock.Now().Add(2 * time.Second)
+		for s.clock.Now().Before(coldDeadline) {
+			s.clock.Sleep(SnapshotPollInterval)
 			if screen.Version() > 0 {
 				break
 			}
 		}
 	}
 
-	tempCols := clampUint16(meta.Cols + 1)
-	tempRows := clampUint16(meta.Rows + 1)
-	if err := pty.Setsize(ptmx, &pty.Winsize{Cols: tempCols, Rows: tempRows}); err != nil {
-		return Response{Success: false, Error: fmt.Sprintf("temporary resize for snapshot: %v", err)}
-	}
-	screen.Resize(int(tempCols), int(tempRows))
-	if cmd != nil && cmd.Process != nil {
-		cmd.Process.Signal(syscall.SIGWINCH)
-	}
-	time.Sleep(SnapshotResizePause)
+	// The resize jiggle forces a full redraw, but anyone watching the PTY
+	// sees it. When the size is held, settle on the emulator's screen.
+	if !holdSize {
+		tempCols := int(clampUint16(meta.Cols + 1))
+		tempRows := int(clampUint16(meta.Rows + 1))
+		if err := p.Resize(s.ptys, tempCols, tempRows); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("temporary resize for snapshot: %v", err)}
+		}
+		screen.Resize(tempCols, tempRows)
+		s.notifyResize(cmd)
+		s.clock.Sleep(SnapshotResizePause)
 
-	if err := pty.Setsize(ptmx, &pty.Winsize{Cols: clampUint16(meta.Cols), Rows: clampUint16(meta.Rows)}); err != nil {
-		return Response{Success: false, Error: fmt.Sprintf("resize for snapshot: %v", err)}
-	}
-	screen.Resize(meta.Cols, meta.Rows)
-	if cmd != nil && cmd.Process != nil {
-		cmd.Process.Signal(syscall.SIGWINCH)
+		if err := p.Resize(s.ptys, meta.Cols, meta.Rows); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("resize for snapshot: %v", err)}
+		}
+		screen.Resize(meta.Cols, meta.Rows)
+		s.notifyResize(cmd)
+		s.mu.Lock()
+		h.resizedAt = s.clock.Now()
+		s.mu.Unlock()
+		resizeCycles++
 	}
 
 	settleMs := req.SettleMs
@@ -805,56 +1991,92 @@ func (s *Server) handleSnapshot(req Request) Response {
 	if timeout > maxTimeout {
 		timeout = maxTimeout
 	}
-	deadline := time.Now().Add(timeout)
+	deadline := s.clock.Now().Add(timeout)
 
 	lastVersion := screen.Version()
-	lastChangeTime := time.Now()
-	for time.Now().Before(deadline) {
-		time.Sleep(SnapshotPollInterval)
+	lastChangeTime := s.clock.Now()
+	for s.clock.Now().Before(deadline) {
+		s.clock.Sleep(SnapshotPollInterval)
 		v := screen.Version()
 		if v != lastVersion {
 			lastVersion = v
-			lastChangeTime = time.Now()
+			lastChangeTime = s.clock.Now()
 			continue
 		}
-		if v > 0 && time.Since(lastChangeTime) >= settleDuration {
+		if v > 0 && s.clock.Now().Sub(lastChangeTime) >= settleDuration {
+			settled = true
 			break
 		}
 	}
 
 	result := screen.String()
 
-	if len(result) == 0 && time.Now().Before(deadline) {
-		if cmd != nil && cmd.Process != nil {
-			cmd.Process.Signal(syscall.SIGWINCH)
+	// With nothing on screen, the latest captured frame is the best there
+	// is.
+	fromFrame := false
+	latestFrame := func() {
+		frames := screen.Frames()
+		for i := len(frames) - 1; i >= 0 && len(result) == 0; i-- {
+			result = frames[i].Content
+			fromFrame = len(result) > 0
 		}
+	}
+
+	// Passive snapshots never signal the program.
+	if len(result) == 0 && passive {
+		latestFrame()
+	}
+
+	if len(result) == 0 && !passive && s.clock.Now().Before(deadline) {
+		s.notifyResize(cmd)
+		resizeCycles++
+		settled = false
 		lastVersion = screen.Version()
-		lastChangeTime = time.Now()
+		lastChangeTime = s.clock.Now()
 		retrySettle := settleDuration * 2
-		for time.Now().Before(deadline) {
-			time.Sleep(SnapshotPollInterval)
+		for s.clock.Now().Before(deadline) {
+			s.clock.Sleep(SnapshotPollInterval)
 			v := screen.Version()
 			if v != lastVersion {
 				lastVersion = v
-				lastChangeTime = time.Now()
+				lastChangeTime = s.clock.Now()
 				continue
 			}
-			if v > 0 && time.Since(lastChangeTime) >= retrySettle {
+			if v > 0 && s.clock.Now().Sub(lastChangeTime) >= retrySettle {
+				settled = true
 				break
 			}
 		}
 		result = screen.String()
 	}
+	if len(result) == 0 && !passive {
+		latestFrame()
+	}
 
 	if req.HeadLines > 0 || req.TailLines > 0 {
 		result = LimitLines(result, req.HeadLines, req.TailLines)
 	}
 
-	return Response{Success: true, Data: map[string]interface{}{
+	data := map[string]interface{}{
 		"output":   result,
 		"position": int64(screen.Version()), // #nosec G115 -- version counter won't reach int64 max
 		"state":    h.state,
-	}}
+
+		"settled":        settled,
+		"waited_ms":      s.clock.Now().Sub(started).Milliseconds(),
+		"resize_cycles":  resizeCycles,
+		"bytes_captured": traffic.ptyIn.Load() - bytesBefore,
+	}
+	if fromFrame {
+		data["from_frame"] = true
+	}
+	if holdSize {
+		data["size_held"] = true
+	}
+	if passive {
+		data["snapshot_mode"] = SnapshotModePassive
+	}
+	return Response{Success: true, Data: data}
 }
 
 func (s *Server) handleSend(req Request) Response {
@@ -868,30 +2090,292 @@ func (s *Server) handleSend(req Request) Response {
 		s.mu.Unlock()
 		return Response{Success: false, Error: fmt.Sprintf("session %q is stopped", req.Name)}
 	}
+	if h.freeze.frozen() {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q is frozen (thaw it first)", req.Name)}
+	}
 	p := h.pty
+	hc := h.hookContext()
+	charset := h.charset
+	mirror := h.mirrorInput
+	banner := h.banner
+	storage := s.storage
 	s.mu.Unlock()
 
 	if p == nil {
 		return Response{Success: false, Error: fmt.Sprintf("session %q not running", req.Name)}
 	}
 
-	data := req.Input
-	if req.Newline {
-		data += "\n"
+	if err := ValidateEnter(req.Enter); err != nil {
+		return Response{Success: false, Error: err.Error()}
 	}
-
-	if _, err := p.File().WriteString(data); err != nil {
+	if err := ValidateIdempotencyKey(req.IdempotencyKey); err != nil {
 		return Response{Success: false, Error: err.Error()}
 	}
 
-	return Response{Success: true}
-}
+	// Claim the key before the hooks run, so a retry arriving while they
+	// do is a duplicate rather than a second send.
+	if req.IdempotencyKey != "" {
+		if s.claimSendKey(h, req.IdempotencyKey) {
+			return Response{Success: true, Data: map[string]interface{}{"duplicate": true}}
+		}
+	}
 
-func (s *Server) handleStop(req Request) Response {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	hc.input = req.Input
+	if err := s.runPreHooks(HookPreSend, hc); err != nil {
+		s.forgetKey(h, req.IdempotencyKey)
+		return Response{Success: false, Error: err.Error()}
+	}
 
-	h, exists := s.handles[req.Name]
+	// Input means the caller considers the program ready.
+	s.closeBanner(req.Name, banner)
+
+	data := req.Input
+	if req.Newline {
+		data += lineEnding(p.File(), req.Enter)
+	}
+	if mirror && !h.pause.paused.Load() {
+		// Before the write, so the record precedes the program's response.
+		// Stored and published like output, but not watched for bookmarks.
+		record := []byte(mirrorRecord(data))
+		s.storeOutput(req.Name, nil, storage, record)
+		s.publishOutput(req.Name, record)
+		h.output.wake()
+	}
+	logged := data
+	if charset != nil {
+		encoded, err := encodeInput(charset, hc.session, data)
+		if err != nil {
+			s.forgetKey(h, req.IdempotencyKey)
+			return Response{Success: false, Error: err.Error()}
+		}
+		data = encoded
+	}
+
+	sentAt := time.Now()
+	n, err := p.File().WriteString(data)
+	h.traffic.ptyOut.Add(int64(n))
+	if err != nil {
+		if n == 0 {
+			s.forgetKey(h, req.IdempotencyKey)
+		}
+		return Response{Success: false, Error: err.Error()}
+	}
+	h.input.add(sentAt, logged)
+
+	s.runPostHooks(HookPostSend, hc)
+	return Response{Success: true}
+}
+
+// handleProbe asks a shell session for its last exit status and working
+// directory. The lines of the probe's echo and answer are removed from the
+// buffer; output the session printed meanwhile stays.
+func (s *Server) handleProbe(req Request) Response {
+	nonce := newNonce()
+	var exitCode int
+	var cwd string
+	start, found, err := s.runHidden(req.Name, "probe", req.TimeoutSec,
+		func(command string) string { return probeCommand(command, nonce) },
+		func(output []byte) bool {
+			var ok bool
+			exitCode, cwd, ok = parseProbe(output, nonce)
+			return ok
+		})
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	s.captureGate.Lock()
+	err = removeMarkedLines(s.storage, req.Name, start, nonce)
+	s.captureGate.Unlock()
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("remove probe output: %v", err)}
+	}
+	if !found {
+		return Response{Success: false, Error: fmt.Sprintf("probe timed out after %ds (is %q a shell?)", probeTimeout(req.TimeoutSec), req.Name)}
+	}
+
+	return Response{Success: true, Data: map[string]interface{}{
+		"exit_code": exitCode,
+		"cwd":       cwd,
+	}}
+}
+
+// handleTrackJob records the shell's latest background job, which the client
+// just started with "<input> &". A hidden command asks the shell for the
+// job's PID; its echo and answer lines are removed from the buffer, while
+// anything the job printed meanwhile stays.
+func (s *Server) handleTrackJob(req Request) Response {
+	nonce := newNonce()
+	var pid int
+	start, found, err := s.runHidden(req.Name, "job tracking", req.TimeoutSec,
+		func(command string) string { return jobCommand(command, nonce) },
+		func(output []byte) bool {
+			var ok bool
+			pid, ok = parseJobPID(output, nonce)
+			return ok
+		})
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	// The shell announces the job ("[1] 12345") just before the hidden command.
+	recent, _ := s.storage.ReadFrom(req.Name, max(0, start-TailBytesLookback))
+	s.captureGate.Lock()
+	err = removeMarkedLines(s.storage, req.Name, start, nonce)
+	s.captureGate.Unlock()
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("remove job query output: %v", err)}
+	}
+	if !found {
+		return Response{Success: false, Error: fmt.Sprintf("no background job PID after %ds (is %q a shell?)", probeTimeout(req.TimeoutSec), req.Name)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	id, ok := parseJobNumber(recent, pid)
+	if !ok {
+		id = nextJobID(h.jobs)
+	}
+	job := &Job{
+		ID:      id,
+		PID:     pid,
+		Command: req.Input,
+		Started: time.Now().Format(time.RFC3339),
+	}
+	// Shells reuse the numbers of finished jobs.
+	jobs := h.jobs[:0]
+	for _, j := range h.jobs {
+		if j.ID != id {
+			jobs = append(jobs, j)
+		}
+	}
+	h.jobs = append(jobs, job)
+
+	result := *job
+	result.Status = JobRunning
+	return Response{Success: true, Data: result}
+}
+
+// handleJobs lists a session's background jobs with their current status.
+func (s *Server) handleJobs(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	running := h.state == StateRunning
+	jobs := make([]Job, len(h.jobs))
+	for i, j := range h.jobs {
+		jobs[i] = *j
+	}
+	s.mu.Unlock()
+
+	for i := range jobs {
+		jobs[i].Status = JobDone
+		if running && jobAlive(jobs[i].PID) {
+			jobs[i].Status = JobRunning
+		}
+	}
+	return Response{Success: true, Data: jobs}
+}
+
+// runHidden writes a hidden command to a shell session and waits until
+// answered finds its reply in the output, then for the prompt that follows
+// to settle. It returns the buffer size from before the command was written;
+// removing the command's traces from there on is up to the caller.
+func (s *Server) runHidden(name, what string, timeoutSec int, command func(shell string) string, answered func(output []byte) bool) (start int64, found bool, err error) {
+	s.mu.Lock()
+	h, ok := s.handles[name]
+	if !ok {
+		s.mu.Unlock()
+		return 0, false, fmt.Errorf("session %q not found", name)
+	}
+	if h.state != StateRunning || h.pty == nil {
+		s.mu.Unlock()
+		return 0, false, fmt.Errorf("session %q is stopped", name)
+	}
+	if h.screen != nil {
+		s.mu.Unlock()
+		return 0, false, fmt.Errorf("session %q is in TUI mode (%s needs a shell session)", name, what)
+	}
+	if h.freeze.frozen() {
+		s.mu.Unlock()
+		return 0, false, fmt.Errorf("session %q is frozen (thaw it first)", name)
+	}
+	p := h.pty
+	shell := h.command
+	storage := s.storage
+	s.mu.Unlock()
+
+	start, err = storage.Size(name)
+	if err != nil {
+		return 0, false, fmt.Errorf("get size: %v", err)
+	}
+
+	n, err := p.File().WriteString(command(shell))
+	h.traffic.ptyOut.Add(int64(n))
+	if err != nil {
+		return 0, false, err
+	}
+
+	deadline := s.clock.Now().Add(time.Duration(probeTimeout(timeoutSec)) * time.Second)
+	lastSize := start
+	lastChange := s.clock.Now()
+	for s.clock.Now().Before(deadline) {
+		size, err := storage.Size(name)
+		if err != nil {
+			return 0, false, fmt.Errorf("get size: %v", err)
+		}
+		if size != lastSize {
+			lastSize = size
+			lastChange = s.clock.Now()
+		}
+		if !found {
+			output, err := storage.ReadFrom(name, start)
+			if err != nil {
+				return 0, false, fmt.Errorf("read output: %v", err)
+			}
+			found = answered(output)
+		}
+		// Once answered, wait for the prompt that follows so it is removed too.
+		if found && s.clock.Now().Sub(lastChange) >= ProbeSettle {
+			break
+		}
+		s.clock.Sleep(SnapshotPollInterval)
+	}
+	return start, found, nil
+}
+
+func (s *Server) handleStop(req Request) Response {
+	if req.DryRun {
+		return s.handleDryRun(req)
+	}
+	if len(s.hooksFor(HookPreStop)) > 0 {
+		s.mu.Lock()
+		h, exists := s.handles[req.Name]
+		running := exists && h.state == StateRunning
+		var hc hookContext
+		if running {
+			hc = h.hookContext()
+		}
+		s.mu.Unlock()
+		if running {
+			if err := s.runPreHooks(HookPreStop, hc); err != nil {
+				return Response{Success: false, Error: err.Error()}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, exists := s.handles[req.Name]
 	if !exists {
 		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
 	}
@@ -914,26 +2398,34 @@ func (s *Server) handleStop(req Request) Response {
 		proc := h.cmd.Process
 		h.cmd = nil
 		proc.Signal(syscall.SIGTERM)
+		h.freeze.thaw() // stopped processes would not act on SIGTERM
 		go func() {
-			time.Sleep(KillGracePeriod)
+			s.clock.Sleep(KillGracePeriod)
 			proc.Signal(syscall.SIGKILL)
 		}()
 	}
 	// screen stays alive for post-stop reads (emulator retains last screen state)
 
 	h.state = StateStopped
-	now := time.Now()
+	now := s.clock.Now()
 	h.stoppedAt = &now
+	h.endReason = EndReasonStopped
 
 	s.storage.UpdateMeta(req.Name, func(meta *SessionMeta) {
 		meta.State = StateStopped
 		meta.StoppedAt = &now
+		meta.EndReason = EndReasonStopped
 	})
 
+	s.publishStop(req.Name, h)
+	s.runPostHooks(HookPostStop, h.hookContext())
 	return Response{Success: true}
 }
 
 func (s *Server) handleKill(req Request) Response {
+	if req.DryRun {
+		return s.handleDryRun(req)
+	}
 	s.mu.Lock()
 
 	h, exists := s.handles[req.Name]
@@ -953,6 +2445,7 @@ func (s *Server) handleKill(req Request) Response {
 		if h.cmd != nil {
 			proc = h.cmd.Process
 		}
+		h.freeze.thaw()
 	}
 
 	if h.screen != nil {
@@ -960,12 +2453,13 @@ func (s *Server) handleKill(req Request) Response {
 	}
 	s.storage.Delete(req.Name)
 	delete(s.handles, req.Name)
+	s.publishState(req.Name, h.state, StateStopped, true)
 	s.mu.Unlock()
 
 	if proc != nil {
 		go func() {
 			proc.Signal(syscall.SIGTERM)
-			time.Sleep(KillGracePeriod)
+			s.clock.Sleep(KillGracePeriod)
 			proc.Signal(syscall.SIGKILL)
 		}()
 	}
@@ -995,10 +2489,58 @@ func (s *Server) handleSize(req Request) Response {
 	return Response{Success: true, Data: map[string]interface{}{"size": size}}
 }
 
+// handleLocate maps a buffer position to its line (req.Offset), or a line to
+// its position (req.Line), counting lines as search does in req.Newlines mode.
+func (s *Server) handleLocate(req Request) Response {
+	if err := ValidateNewlines(req.Newlines); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	screen := h.screen
+	storage := s.storage
+	s.mu.Unlock()
+
+	if screen != nil {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is in TUI mode (positions refer to raw output)", req.Name)}
+	}
+
+	data, err := storage.ReadAll(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+	}
+	lp, err := Locate(data, req.Offset, req.Line, req.Newlines)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	return Response{Success: true, Data: lp}
+}
+
 func (s *Server) handleSearch(req Request) Response {
+	if req.Continue != "" {
+		t, err := decodePageToken(req.Continue, "search", req.Name)
+		if err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		req = t.continueSearch(req)
+	}
 	if req.Before < 0 || req.After < 0 {
 		return Response{Success: false, Error: "before and after must be non-negative"}
 	}
+	if req.Offset < 0 || req.Limit < 0 {
+		return Response{Success: false, Error: "offset and limit must be non-negative"}
+	}
+	if req.Render && req.Newlines != "" {
+		return Response{Success: false, Error: "newlines does not apply to render searches"}
+	}
+	if err := ValidateNewlines(req.Newlines); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
 
 	s.mu.Lock()
 	h, exists := s.handles[req.Name]
@@ -1011,7 +2553,17 @@ func (s *Server) handleSearch(req Request) Response {
 	s.mu.Unlock()
 
 	var output string
-	if screen != nil {
+	var rows []string
+	var top int
+	var renderedFrom int64
+	if req.Render {
+		var err error
+		rows, top, renderedFrom, err = renderedRows(req.Name, screen, storage)
+		if err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		output = strings.Join(rows, "\n")
+	} else if screen != nil {
 		if req.StripANSI {
 			output = screen.String()
 		} else {
@@ -1026,49 +2578,86 @@ func (s *Server) handleSearch(req Request) Response {
 		if req.StripANSI {
 			output = vterm.StripDefault(output)
 		}
+		output = NormalizeNewlines(output, req.Newlines)
+	}
+
+	sreq := SearchRequest{Pattern: req.Pattern, Before: req.Before, After: req.After, IgnoreCase: req.IgnoreCase, Offset: int(req.Offset), Limit: int(req.Limit)}
+	result, err := searchOutput(output, sreq)
+	if err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+	if req.Render {
+		if err := placeMatches(result, rows, top, sreq); err != nil {
+			return Response{Success: false, Error: err.Error()}
+		}
+		result.RenderedFrom = renderedFrom
+	}
+	if shown := int(req.Offset) + len(result.Matches); req.Limit > 0 && shown < result.TotalMatches {
+		result.Next = nextSearchToken(req, int64(shown)).encode()
 	}
+	return Response{Success: true, Data: result}
+}
 
+// searchPattern compiles req.Pattern, case-insensitive with req.IgnoreCase.
+func searchPattern(req SearchRequest) (*regexp.Regexp, error) {
 	patternStr := req.Pattern
 	if req.IgnoreCase {
 		patternStr = "(?i)" + patternStr
 	}
-
 	re, err := regexp.Compile(patternStr)
 	if err != nil {
-		return Response{Success: false, Error: fmt.Sprintf("invalid pattern: %v", err)}
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	return re, nil
+}
+
+// searchOutput finds the lines of output matching req.Pattern, with
+// req.Before and req.After lines of context, keeping the req.Limit matches
+// after the first req.Offset. TotalMatches counts them all.
+func searchOutput(output string, req SearchRequest) (*SearchResponse, error) {
+	re, err := searchPattern(req)
+	if err != nil {
+		return nil, err
 	}
 
 	lines := strings.Split(output, "\n")
-	var matches []map[string]interface{}
+	result := &SearchResponse{}
+	clip := func(line string) string {
+		line, cut := truncateLine(line, MaxLineLength)
+		if cut {
+			result.LongLinesTruncated++
+		}
+		return line
+	}
 
 	for i, line := range lines {
 		if re.MatchString(line) {
+			result.TotalMatches++
+			if result.TotalMatches <= req.Offset || (req.Limit > 0 && len(result.Matches) >= req.Limit) {
+				continue
+			}
 			beforeStart := max(0, i-req.Before)
 			afterEnd := min(len(lines), i+req.After+1)
 
 			beforeLines := make([]string, 0, i-beforeStart)
 			for j := beforeStart; j < i; j++ {
-				beforeLines = append(beforeLines, lines[j])
+				beforeLines = append(beforeLines, clip(lines[j]))
 			}
 
 			afterLines := make([]string, 0, afterEnd-i-1)
 			for j := i + 1; j < afterEnd; j++ {
-				afterLines = append(afterLines, lines[j])
+				afterLines = append(afterLines, clip(lines[j]))
 			}
 
-			matches = append(matches, map[string]interface{}{
-				"line_number": i + 1,
-				"line":        line,
-				"before":      beforeLines,
-				"after":       afterLines,
+			result.Matches = append(result.Matches, SearchMatch{
+				LineNumber: i + 1,
+				Line:       clip(line),
+				Before:     beforeLines,
+				After:      afterLines,
 			})
 		}
 	}
-
-	return Response{Success: true, Data: map[string]interface{}{
-		"matches":       matches,
-		"total_matches": len(matches),
-	}}
+	return result, nil
 }
 
 func (s *Server) handleInfo(req Request) Response {
@@ -1079,7 +2668,10 @@ func (s *Server) handleInfo(req Request) Response {
 		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
 	}
 	storage := s.storage
+	p := h.pty
+	running := h.state == StateRunning
 	s.mu.Unlock()
+	cwd := h.currentCwd(running)
 
 	meta, err := storage.LoadMeta(req.Name)
 	if err != nil {
@@ -1108,34 +2700,391 @@ func (s *Server) handleInfo(req Request) Response {
 		result["stopped_at"] = h.stoppedAt.Format(time.RFC3339)
 	}
 
-	if h.state == StateRunning {
-		result["uptime_seconds"] = time.Since(h.createdAt).Seconds()
+	if meta.ExitCode != nil {
+		result["exit_code"] = *meta.ExitCode
+		if meta.ExitSignal != "" {
+			result["exit_signal"] = meta.ExitSignal
+		}
+	}
+	if meta.EndReason != "" {
+		result["end_reason"] = meta.EndReason
+		if meta.EndError != "" {
+			result["end_error"] = meta.EndError
+		}
 	}
 
 	if len(meta.Cursors) > 0 {
 		result["cursors"] = meta.Cursors
 	}
 
+	if meta.FrameHistory > 0 {
+		result["frame_history"] = meta.FrameHistory
+	}
+
+	if meta.Scrollback > 0 {
+		result["scrollback"] = meta.Scrollback
+	}
+
+	if meta.CaptureRaw != "" {
+		result["capture_raw"] = meta.CaptureRaw
+	}
+
+	if meta.Workspace != "" {
+		result["workspace"] = meta.Workspace
+	}
+
+	if cwd != "" {
+		result["cwd"] = cwd
+	}
+
+	if meta.Nice != nil {
+		result["nice"] = *meta.Nice
+	}
+
+	if meta.IOClass != "" {
+		result["io_class"] = meta.IOClass
+	}
+
+	if meta.Limits != nil {
+		result["limits"] = meta.Limits
+	}
+
+	if meta.Encoding != "" {
+		result["encoding"] = meta.Encoding
+	}
+	if meta.MirrorInput {
+		result["mirror_input"] = true
+	}
+	if meta.SnapshotMode != "" {
+		result["snapshot_mode"] = meta.SnapshotMode
+	}
+	if meta.ResizeCrash {
+		result["resize_crash"] = true
+	}
+	if len(meta.OnExit) > 0 {
+		result["on_exit"] = meta.OnExit
+	}
+
+	if running && p != nil {
+		if canonical, err := canonicalMode(p.File()); err == nil {
+			result["terminal_mode"] = TerminalRaw
+			if canonical {
+				result["terminal_mode"] = TerminalCanonical
+			}
+		}
+	}
+
+	if len(meta.FrameBoundaries) > 0 {
+		result["frame_boundaries"] = meta.FrameBoundaries
+	}
+
+	s.mu.Lock()
+	result["alt_screen"] = h.altScreen
+	h.bannerInfo(result, meta)
+	h.pauseInfo(result)
+	h.freezeInfo(result)
+	h.clockInfo(result)
+	h.trafficInfo(result)
+	if meta.CapturePriority != "" {
+		result["capture"] = h.captureInfo()
+	}
+	s.mu.Unlock()
+
+	if health, err := s.checkHealth(req.Name); err == nil {
+		result["health"] = health
+	}
+
 	return Response{Success: true, Data: result}
 }
 
 func (s *Server) handleClear(req Request) Response {
+	if req.DryRun {
+		return s.handleDryRun(req)
+	}
 	s.mu.Lock()
-	_, exists := s.handles[req.Name]
+	h, exists := s.handles[req.Name]
 	if !exists {
 		s.mu.Unlock()
 		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
 	}
+	h.images = nil
+	h.notifications = nil
+	storage := s.storage
+	s.mu.Unlock()
+
+	// Every reader loses what it had not read yet; tell each on its next read.
+	storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+		m.noteTruncation("")
+		for cursor := range m.Cursors {
+			m.noteTruncation(cursor)
+		}
+		m.Bookmarks = nil
+	})
+	size, _ := storage.Size(req.Name)
+	if err := storage.Clear(req.Name); err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("clear: %v", err)}
+	}
+	s.events.publish(Truncation{
+		EventHeader: EventHeader{Session: req.Name, At: time.Now()},
+		Reason:      TruncationClear,
+		Bytes:       size,
+	})
+
+	return Response{Success: true}
+}
+
+func (s *Server) handleCompact(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if h.screen != nil {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q is in TUI mode; its output is already rendered", req.Name)}
+	}
 	storage := s.storage
 	s.mu.Unlock()
 
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+	data, err := storage.ReadAll(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+	}
+
+	marks := []int64{meta.ReadPos}
+	for _, pos := range meta.Cursors {
+		marks = append(marks, pos)
+	}
+	for _, b := range meta.Bookmarks {
+		marks = append(marks, b.Offset)
+	}
+	compacted, mapped := compactOutput(data, marks)
+
+	// Keep output that arrived while rendering. It is appended raw, after the
+	// compacted text, and capture waits from reading it until the rewrite is
+	// done, so nothing lands in between.
+	s.captureGate.Lock()
+	defer s.captureGate.Unlock()
+	tail, err := storage.ReadFrom(req.Name, int64(len(data)))
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+	}
+	chunks, err := storage.Chunks(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("read chunk times: %v", err)}
+	}
 	if err := storage.Clear(req.Name); err != nil {
 		return Response{Success: false, Error: fmt.Sprintf("clear: %v", err)}
 	}
+	if err := storage.Append(req.Name, append(compacted, tail...)); err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("write output: %v", err)}
+	}
+	storage.SetChunks(req.Name, scaleChunks(chunks, int64(len(data)+len(tail)), int64(len(compacted)+len(tail))))
+
+	storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+		m.ReadPos = mapped[meta.ReadPos]
+		if len(meta.Cursors) > 0 {
+			m.Cursors = make(map[string]int64, len(meta.Cursors))
+			for k, pos := range meta.Cursors {
+				m.Cursors[k] = mapped[pos]
+			}
+		}
+		for i := range m.Bookmarks {
+			if off := m.Bookmarks[i].Offset; off < int64(len(data)) {
+				m.Bookmarks[i].Offset = mapped[off]
+			} else { // set by a watch on the tail
+				m.Bookmarks[i].Offset = off - int64(len(data)) + int64(len(compacted))
+			}
+		}
+	})
+
+	return Response{Success: true, Data: map[string]interface{}{
+		"name":         req.Name,
+		"bytes_before": len(data),
+		"bytes_after":  len(compacted),
+	}}
+}
+
+func (s *Server) handleRenice(req Request) Response {
+	if req.Nice == nil && req.IOClass == "" {
+		return Response{Success: false, Error: "at least one of nice or io_class is required"}
+	}
+	if err := validatePriority(req.Nice, req.IOClass); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if h.state != StateRunning {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q is stopped", req.Name)}
+	}
+	pid := h.pid
+	storage := s.storage
+	s.mu.Unlock()
+
+	if err := applyPriority(pid, req.Nice, req.IOClass); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	var meta SessionMeta
+	storage.UpdateMeta(req.Name, func(m *SessionMeta) {
+		if req.Nice != nil {
+			m.Nice = req.Nice
+		}
+		if req.IOClass != "" {
+			m.IOClass = req.IOClass
+		}
+		meta = *m
+	})
+
+	result := map[string]interface{}{"name": req.Name}
+	if meta.Nice != nil {
+		result["nice"] = *meta.Nice
+	}
+	if meta.IOClass != "" {
+		result["io_class"] = meta.IOClass
+	}
+	return Response{Success: true, Data: result}
+}
+
+// handleBudget starts watching the session's next foreground job against
+// CPU and wall-time limits, replacing any earlier budget.
+func (s *Server) handleBudget(req Request) Response {
+	maxCPU := time.Duration(req.MaxCPUMs) * time.Millisecond
+	maxWall := time.Duration(req.MaxWallMs) * time.Millisecond
+	if maxCPU == 0 && maxWall == 0 {
+		return Response{Success: false, Error: "at least one of max_cpu_ms or max_wall_ms is required"}
+	}
+	if err := validateBudget(maxCPU, maxWall); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if h.state != StateRunning || h.pty == nil {
+		return Response{Success: false, Error: fmt.Sprintf("session %q is stopped", req.Name)}
+	}
+
+	if h.budget != nil {
+		h.budget.Cancel()
+	}
+	h.budget = newExecBudget(maxCPU, maxWall, h.pid)
+	go h.budget.watch(h.pty.File())
+	return Response{Success: true}
+}
+
+// handleExecBegin records that a client started an exec in the session, so
+// exec_status can report its progress. It returns the exec's ID.
+func (s *Server) handleExecBegin(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
 
+	s.nextExecID++
+	h.exec = &execProgress{
+		id:        s.nextExecID,
+		input:     req.Input,
+		startedAt: time.Now(),
+		started:   monoNow(),
+		ptyBase:   h.traffic.ptyIn.Load(),
+		status:    ExecRunning,
+	}
+	return Response{Success: true, Data: map[string]interface{}{"exec_id": h.exec.id}}
+}
+
+// handleExecEnd records how an exec finished. Ending an exec that is no
+// longer the session's latest is a no-op.
+func (s *Server) handleExecEnd(req Request) Response {
+	switch req.Outcome {
+	case ExecCompleted, ExecTimedOut, ExecFailed:
+	default:
+		return Response{Success: false, Error: fmt.Sprintf("unknown exec outcome %q", req.Outcome)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if e := h.exec; e != nil && e.id == req.ExecID && e.status == ExecRunning {
+		e.status = req.Outcome
+		s.metrics.countWait("exec", req.Outcome)
+	}
 	return Response{Success: true}
 }
 
+// handleExecStatus reports the progress of the session's latest exec.
+func (s *Server) handleExecStatus(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	e := h.exec
+	if e == nil || (req.ExecID != 0 && e.id != req.ExecID) {
+		s.mu.Unlock()
+		if req.ExecID != 0 {
+			return Response{Success: false, Error: fmt.Sprintf("exec %d is not the latest exec in session %q", req.ExecID, req.Name)}
+		}
+		return Response{Success: false, Error: fmt.Sprintf("no exec has run in session %q", req.Name)}
+	}
+	status := ExecStatus{
+		ID:      e.id,
+		Session: h.name,
+		Input:   e.input,
+		Status:  e.status,
+		Started: e.startedAt.Format(time.RFC3339),
+	}
+	now := monoNow()
+	status.Elapsed = (now - e.started).Seconds()
+	status.Bytes = h.traffic.ptyIn.Load() - e.ptyBase
+	status.Idle = max(0, now-time.Duration(h.clock.lastOutput.Load())).Seconds()
+	screen := h.screen
+	storage := s.storage
+	s.mu.Unlock()
+
+	status.LastLine = lastLine(screen, storage, req.Name)
+	return Response{Success: true, Data: status}
+}
+
+// handleBudgetResult reports the session's budget at the end of an exec.
+func (s *Server) handleBudgetResult(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	var budget *execBudget
+	if exists {
+		budget = h.budget
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	if budget == nil {
+		return Response{Success: false, Error: fmt.Sprintf("session %q has no exec budget", req.Name)}
+	}
+	return Response{Success: true, Data: budget.Result(true)}
+}
+
 func (s *Server) handleResize(req Request) Response {
 	if req.Cols <= 0 && req.Rows <= 0 {
 		return Response{Success: false, Error: "at least one of cols or rows is required"}
@@ -1175,7 +3124,7 @@ func (s *Server) handleResize(req Request) Response {
 		rows = meta.Rows
 	}
 
-	if err := pty.Setsize(p.File(), &pty.Winsize{Cols: clampUint16(cols), Rows: clampUint16(rows)}); err != nil {
+	if err := p.Resize(s.ptys, cols, rows); err != nil {
 		return Response{Success: false, Error: fmt.Sprintf("resize: %v", err)}
 	}
 
@@ -1183,9 +3132,7 @@ func (s *Server) handleResize(req Request) Response {
 	if h.screen != nil {
 		h.screen.Resize(cols, rows)
 	}
-	if h.cmd != nil && h.cmd.Process != nil {
-		h.cmd.Process.Signal(syscall.SIGWINCH)
-	}
+	s.notifyResize(h.cmd)
 	s.mu.Unlock()
 
 	if err := storage.UpdateMeta(req.Name, func(m *SessionMeta) {
@@ -1194,6 +3141,11 @@ func (s *Server) handleResize(req Request) Response {
 	}); err != nil {
 		return Response{Success: false, Error: fmt.Sprintf("save meta: %v", err)}
 	}
+	s.events.publish(SizeChange{
+		EventHeader: EventHeader{Session: req.Name, At: time.Now()},
+		Cols:        cols,
+		Rows:        rows,
+	})
 
 	return Response{Success: true, Data: map[string]interface{}{
 		"cols": cols,
@@ -1201,12 +3153,134 @@ func (s *Server) handleResize(req Request) Response {
 	}}
 }
 
-func clampUint16(v int) uint16 {
-	if v < 0 {
-		return 0
+func (s *Server) handleExport(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.Name)}
+	}
+	screen := h.screen
+	storage := s.storage
+	s.mu.Unlock()
+
+	h.input.mu.Lock()
+	input := append([]InputEvent(nil), h.input.events...)
+	inputTruncated := h.input.truncated
+	h.input.mu.Unlock()
+
+	meta, err := storage.LoadMeta(req.Name)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+
+	bundle := &SessionBundle{Meta: *meta, Input: input, InputTruncated: inputTruncated}
+	if screen != nil {
+		// TUI sessions have no raw buffer; export the current screen instead.
+		bundle.Output = []byte(screen.String())
+	} else {
+		// Chunk times first: output appended in between has no time yet and
+		// counts as part of the last chunk.
+		if bundle.Chunks, err = storage.Chunks(req.Name); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("read chunk times: %v", err)}
+		}
+		if bundle.Output, err = storage.ReadAll(req.Name); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+		}
+	}
+
+	return Response{Success: true, Data: bundle}
+}
+
+func (s *Server) handleImport(req Request) Response {
+	if req.Bundle == nil {
+		return Response{Success: false, Error: "bundle is required"}
+	}
+
+	name := req.Name
+	if name == "" {
+		name = req.Bundle.Meta.Name
+	}
+	if err := ValidateSessionName(name); err != nil {
+		return Response{Success: false, Error: err.Error()}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.handles[name]; exists {
+		return Response{Success: false, Error: fmt.Sprintf("session %q already exists", name)}
+	}
+
+	meta := importedMeta(name, &req.Bundle.Meta, int64(len(req.Bundle.Output)), s.clock.Now())
+
+	if err := s.storage.Create(name, &meta); err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("create storage: %v", err)}
+	}
+	if len(req.Bundle.Output) > 0 {
+		if err := s.storage.Append(name, req.Bundle.Output); err != nil {
+			s.storage.Delete(name)
+			return Response{Success: false, Error: fmt.Sprintf("write output: %v", err)}
+		}
+		if len(req.Bundle.Chunks) > 0 {
+			s.storage.SetChunks(name, clipChunks(req.Bundle.Chunks, int64(len(req.Bundle.Output))))
+		}
+	}
+
+	h := &sessionHandle{
+		name:      name,
+		command:   meta.Command,
+		state:     StateStopped,
+		createdAt: meta.CreatedAt,
+		stoppedAt: meta.StoppedAt,
+	}
+	for _, ev := range req.Bundle.Input {
+		h.input.add(ev.At, ev.Data)
+	}
+	if req.Bundle.InputTruncated {
+		h.input.truncated = true
+	}
+	s.handles[name] = h
+
+	return Response{Success: true, Data: map[string]interface{}{
+		"name":    name,
+		"command": meta.Command,
+		"bytes":   len(req.Bundle.Output),
+	}}
+}
+
+// importedMeta is the metadata an imported session starts with: the
+// descriptive fields of the bundle's meta and nothing that would make this
+// daemon act on a foreign session. Imported sessions have no process behind
+// them (no PID), so they are always stopped; settings that only matter to a
+// running process (workspace, on-exit commands, limits, watches, ...) and
+// reader state are dropped. TUI exports carry plain screen text, read from
+// storage like any other output.
+func importedMeta(name string, src *SessionMeta, outputLen int64, now time.Time) SessionMeta {
+	meta := SessionMeta{
+		Name:       name,
+		Command:    src.Command,
+		State:      StateStopped,
+		CreatedAt:  src.CreatedAt,
+		StoppedAt:  src.StoppedAt,
+		ExitCode:   src.ExitCode,
+		ExitSignal: src.ExitSignal,
+		EndReason:  src.EndReason,
+		EndError:   src.EndError,
+		Cols:       src.Cols,
+		Rows:       src.Rows,
+		Encoding:   src.Encoding,
+	}
+	if meta.StoppedAt == nil {
+		meta.StoppedAt = &now
+	}
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = *meta.StoppedAt
 	}
-	if v > 65535 {
-		return 65535
+	for _, b := range src.Bookmarks {
+		if b.Offset >= 0 && b.Offset <= outputLen {
+			meta.Bookmarks = append(meta.Bookmarks, b)
+		}
 	}
-	return uint16(v)
+	return meta
 }
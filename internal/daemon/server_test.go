@@ -1,22 +1,30 @@
 package daemon
 
 import (
+	"fmt"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 )
 
-func setupTestServer(t *testing.T) (*Client, func()) {
+func setupTestServer(t *testing.T, opts ...ServerOption) (*Client, func()) {
+	t.Helper()
+	_, client, cleanup := startTestServer(t, opts...)
+	return client, cleanup
+}
+
+// startTestServer is setupTestServer for tests that also need the server.
+func startTestServer(t *testing.T, opts ...ServerOption) (*Server, *Client, func()) {
 	t.Helper()
 
 	tmpDir := t.TempDir()
 
 	storage := NewMemoryStorage(1024 * 1024)
-	srv, err := NewServer(
+	srv, err := NewServer(append([]ServerOption{
 		WithStorage(storage),
 		WithSocketDir(tmpDir),
-	)
+	}, opts...)...)
 	if err != nil {
 		t.Fatalf("NewServer: %v", err)
 	}
@@ -47,7 +55,7 @@ func setupTestServer(t *testing.T) (*Client, func()) {
 		}
 	}
 
-	return client, cleanup
+	return srv, client, cleanup
 }
 
 func waitForOutput(t *testing.T, client *Client, name string, contains string) string {
@@ -182,6 +190,28 @@ func TestLifecycle(t *testing.T) {
 	})
 }
 
+func TestSearchLongLine(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	cmd := fmt.Sprintf("head -c %d /dev/zero | tr '\\0' x; echo; echo done", MaxLineLength*2)
+	if _, err := client.Create("long", CreateOptions{Command: cmd}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	waitForOutput(t, client, "long", "done")
+
+	result, err := client.Search(SearchRequest{Name: "long", Pattern: "^x+", After: 1})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if result.TotalMatches != 1 || result.LongLinesTruncated != 1 {
+		t.Fatalf("matches = %d, truncated = %d", result.TotalMatches, result.LongLinesTruncated)
+	}
+	if len(result.Matches[0].Line) > MaxLineLength+64 {
+		t.Errorf("match line not truncated: %d bytes", len(result.Matches[0].Line))
+	}
+}
+
 func TestSearchBoundsValidation(t *testing.T) {
 	client, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -0,0 +1,55 @@
+package daemon
+
+import "fmt"
+
+// currentCwd is the working directory h's process is in now, for a running
+// session whose directory can be told, else the one it started in. Not
+// called with s.mu held: finding the directory may run lsof.
+func (h *sessionHandle) currentCwd(running bool) string {
+	if running {
+		if cwd := processCwd(h.pid); cwd != "" {
+			return cwd
+		}
+	}
+	return h.cwd
+}
+
+// handleSibling creates session req.Name next to session req.From: in the
+// directory From's process is in now, with the environment From was created
+// with (req.Env adds to it), at From's terminal size and under its resource
+// limits. req.Command is the
+// new session's command, the user's shell by default; From is not touched.
+func (s *Server) handleSibling(req Request) Response {
+	s.mu.Lock()
+	h, exists := s.handles[req.From]
+	if !exists {
+		s.mu.Unlock()
+		return Response{Success: false, Error: fmt.Sprintf("session %q not found", req.From)}
+	}
+	running := h.state == StateRunning
+	env := append([]string(nil), h.env...)
+	workspace := h.workspace
+	s.mu.Unlock()
+
+	meta, err := s.storage.LoadMeta(req.From)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+
+	cwd := h.currentCwd(running)
+	resp := s.handleCreate(Request{
+		Name:      req.Name,
+		Command:   req.Command,
+		Env:       append(env, req.Env...),
+		Cwd:       cwd,
+		Cols:      meta.Cols,
+		Rows:      meta.Rows,
+		Workspace: workspace,
+		Limits:    meta.Limits,
+	})
+	if data, ok := resp.Data.(map[string]interface{}); ok && resp.Success {
+		data["sibling_of"] = req.From
+		data["cwd"] = cwd
+	}
+	return resp
+}
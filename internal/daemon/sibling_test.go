@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSibling(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Create("busy", CreateOptions{Command: "sh", Cwd: dir, Env: []string{"SIBLING_A=one"}, Cols: 100, Rows: 30})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// The quotes keep the echoed command line from matching its output.
+	if err := client.Send("busy", "cd sub && echo mo''ved", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "busy", "moved")
+
+	data, err := client.Sibling("busy", "diag", "sh", []string{"SIBLING_B=two"})
+	if err != nil {
+		t.Fatalf("Sibling: %v", err)
+	}
+	checkCwd := runtime.GOOS == "linux" // elsewhere it depends on lsof
+	if checkCwd && data["cwd"] != sub {
+		t.Errorf("cwd = %v, want %s", data["cwd"], sub)
+	}
+
+	info, err := client.Info("diag")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.Cols != 100 || info.Rows != 30 {
+		t.Errorf("size = %dx%d, want 100x30", info.Cols, info.Rows)
+	}
+	if err := client.Send("diag", "echo \"[$SIBLING_A-$SIBLING_B]\"; pwd", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "diag", "[one-two]")
+	if checkCwd {
+		waitForOutput(t, client, "diag", sub)
+	}
+
+	if _, err := client.Sibling("missing", "other", "", nil); err == nil {
+		t.Error("Sibling of a missing session succeeded")
+	}
+}
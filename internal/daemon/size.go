@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TerminalSize is a session's dimensions.
+type TerminalSize struct {
+	Cols int
+	Rows int
+}
+
+// SizePresets are the named sizes accepted as "preset:<name>".
+var SizePresets = map[string]TerminalSize{
+	"default": {Cols: 80, Rows: 24},
+	"wide":    {Cols: 160, Rows: 40},
+	"tall":    {Cols: 80, Rows: 60},
+	"large":   {Cols: 200, Rows: 60},
+}
+
+// SizePresetNames returns the preset names in sorted order.
+func SizePresetNames() []string {
+	names := make([]string, 0, len(SizePresets))
+	for name := range SizePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseSizePreset resolves "preset:<name>" to its dimensions.
+func ParseSizePreset(spec string) (TerminalSize, error) {
+	name, ok := strings.CutPrefix(spec, "preset:")
+	if !ok {
+		return TerminalSize{}, fmt.Errorf("invalid size %q (expected preset:<name>)", spec)
+	}
+	size, ok := SizePresets[name]
+	if !ok {
+		return TerminalSize{}, fmt.Errorf("unknown size preset %q (valid: %s)", name, strings.Join(SizePresetNames(), ", "))
+	}
+	return size, nil
+}
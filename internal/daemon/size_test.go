@@ -0,0 +1,19 @@
+package daemon
+
+import "testing"
+
+func TestParseSizePreset(t *testing.T) {
+	size, err := ParseSizePreset("preset:wide")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if size != (TerminalSize{Cols: 160, Rows: 40}) {
+		t.Errorf("preset:wide = %+v", size)
+	}
+
+	for _, spec := range []string{"wide", "preset:", "preset:huge", "120x40"} {
+		if _, err := ParseSizePreset(spec); err == nil {
+			t.Errorf("ParseSizePreset(%q) should fail", spec)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotHoldSizeSkipsResize(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("held", CreateOptions{Command: "sh", TUIMode: true}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("held")
+
+	if err := client.Send("held", "trap 'echo got-winch' WINCH; echo ready", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out, _, err := client.Read("held", ReadModeAll, 0, 0)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if strings.Contains(out, "\nready") || strings.HasPrefix(out, "ready") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for ready, got %q", out)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	output, _, err := client.Snapshot("held", 100, 5, 0, 0, true)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if !strings.Contains(output, "ready") {
+		t.Errorf("snapshot should show the screen, got %q", output)
+	}
+
+	// A trapped WINCH is handled once the shell is back at its prompt.
+	if err := client.Send("held", "echo after", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	out, _, err := client.Read("held", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if strings.Contains(out, "\ngot-winch") {
+		t.Errorf("hold_size snapshot should not signal a resize, got %q", out)
+	}
+}
+
+func TestSnapshotPassiveAfterResizeCrash(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("plain", CreateOptions{Command: "sh", SnapshotMode: SnapshotModePassive}); err == nil {
+		t.Error("a snapshot mode without TUI mode should be refused")
+	}
+	if _, err := client.Create("bad", CreateOptions{Command: "sh", TUIMode: true, SnapshotMode: "jiggle"}); err == nil {
+		t.Error("an unknown snapshot mode should be refused")
+	}
+
+	// A program that dies when resized.
+	fragile := `sh -c 'trap "exit 3" WINCH; echo ready; while :; do sleep 0.05; done'`
+	if _, err := client.Create("fragile", CreateOptions{Command: fragile, TUIMode: true}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	waitForOutput(t, client, "fragile", "ready")
+	client.Snapshot("fragile", 100, 2, 0, 0, false)
+	if res, err := client.WaitExit("fragile", 5); err != nil || res.ExitCode == nil || *res.ExitCode != 3 {
+		t.Fatalf("WaitExit = %+v, %v", res, err)
+	}
+	info, err := client.Info("fragile")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if !info.ResizeCrash || info.SnapshotMode != SnapshotModePassive {
+		t.Fatalf("crashed session: snapshot mode %q, resize crash %v", info.SnapshotMode, info.ResizeCrash)
+	}
+
+	// The next session running it snapshots passively, and survives.
+	if _, err := client.Create("again", CreateOptions{Command: fragile, TUIMode: true}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("again")
+	if info, _ := client.Info("again"); info.SnapshotMode != SnapshotModePassive {
+		t.Errorf("snapshot mode = %q, want passive", info.SnapshotMode)
+	}
+	waitForOutput(t, client, "again", "ready")
+	output, _, err := client.Snapshot("again", 100, 2, 0, 0, false)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if !strings.Contains(output, "ready") {
+		t.Errorf("passive snapshot should show the screen, got %q", output)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if info, _ := client.Info("again"); info.State != string(StateRunning) {
+		t.Errorf("a passive snapshot should not resize the program, state %s", info.State)
+	}
+
+	// An explicit mode wins over the crash record.
+	if _, err := client.Create("forced", CreateOptions{Command: fragile, TUIMode: true, SnapshotMode: SnapshotModeResize}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("forced")
+	if info, _ := client.Info("forced"); info.SnapshotMode != SnapshotModeResize {
+		t.Errorf("snapshot mode = %q, want resize", info.SnapshotMode)
+	}
+}
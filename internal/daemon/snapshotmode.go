@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Snapshot modes set how a TUI session's snapshot gets a fresh screen.
+// Resize briefly changes the PTY size and sends SIGWINCH so the program
+// redraws in full; passive never touches the size or signals the program
+// and settles on what the emulator already shows, for programs that crash
+// or corrupt their state when resized.
+const (
+	SnapshotModeResize  = "resize"
+	SnapshotModePassive = "passive"
+)
+
+// ResizeCrashWindow is how soon after a snapshot's resize an abnormal exit
+// counts as caused by it.
+const ResizeCrashWindow = 2 * time.Second
+
+// resolveSnapshotMode validates a create's snapshot mode. Unset, a TUI
+// session is passive when an earlier session running the same command
+// crashed on a snapshot resize, else resize. Called with s.mu held.
+func (s *Server) resolveSnapshotMode(mode, command string, tui bool) (string, error) {
+	switch mode {
+	case "":
+	case SnapshotModeResize, SnapshotModePassive:
+		if !tui {
+			return "", fmt.Errorf("snapshot mode requires TUI mode")
+		}
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid snapshot mode %q (expected resize or passive)", mode)
+	}
+	if !tui {
+		return "", nil
+	}
+	for _, h := range s.handles {
+		if h.resizeCrash && h.command == command {
+			return SnapshotModePassive, nil
+		}
+	}
+	return SnapshotModeResize, nil
+}
+
+// noteResizeCrash switches h to passive snapshots when its process died
+// abnormally (non-zero status or a signal) within ResizeCrashWindow of a
+// snapshot resize, so later sessions running the same command start
+// passive. Called with s.mu held, after recordExit.
+func (s *Server) noteResizeCrash(name string, h *sessionHandle) {
+	if h.resizedAt.IsZero() || h.snapshotMode == SnapshotModePassive {
+		return
+	}
+	if h.exitCode == nil || (*h.exitCode == 0 && h.exitSignal == "") {
+		return
+	}
+	if s.clock.Now().Sub(h.resizedAt) > ResizeCrashWindow {
+		return
+	}
+	h.resizeCrash = true
+	h.snapshotMode = SnapshotModePassive
+	if s.handles[name] == h {
+		s.storage.UpdateMeta(name, func(meta *SessionMeta) {
+			meta.SnapshotMode = SnapshotModePassive
+			meta.ResizeCrash = true
+		})
+	}
+	log.Printf("snapshot[%s]: exited with status %d right after a resize; snapshots of %q are passive from now on", name, *h.exitCode, h.command)
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Step statuses reported in StepResult.
+const (
+	StepOK      = "ok"      // output settled or the pattern matched
+	StepTimeout = "timeout" // wait condition not met in time
+	StepFailed  = "failed"  // probe reported a non-zero exit status
+	StepError   = "error"   // input could not be sent
+	StepSkipped = "skipped" // an earlier step did not succeed
+)
+
+// ExecStep is one entry of an exec script. Unset wait fields fall back to
+// the script defaults.
+type ExecStep struct {
+	Input       string `json:"input"`
+	WaitPattern string `json:"wait_pattern,omitempty"`
+	SettleMs    *int   `json:"settle_ms,omitempty"`
+	TimeoutSec  int    `json:"timeout_sec,omitempty"`
+	Probe       *bool  `json:"probe,omitempty"`
+}
+
+// StepResult is the outcome of one script step.
+type StepResult struct {
+	Step     int    `json:"step"`
+	Input    string `json:"input"`
+	Output   string `json:"output"`
+	Position int    `json:"position"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Cwd      string `json:"cwd,omitempty"`
+}
+
+// ParseSteps reads an exec script: a JSON array of steps, or plain text with
+// one input per line (blank lines and lines starting with # are skipped).
+func ParseSteps(data []byte) ([]ExecStep, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var steps []ExecStep
+		if err := json.Unmarshal(trimmed, &steps); err != nil {
+			return nil, fmt.Errorf("parse steps: %w", err)
+		}
+		if err := ValidateSteps(steps); err != nil {
+			return nil, err
+		}
+		return steps, nil
+	}
+
+	var steps []ExecStep
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		steps = append(steps, ExecStep{Input: line})
+	}
+	return steps, nil
+}
+
+// ValidateSteps checks that every step has input and at most one wait
+// condition.
+func ValidateSteps(steps []ExecStep) error {
+	for i, step := range steps {
+		if step.Input == "" {
+			return fmt.Errorf("step %d: input is required", i+1)
+		}
+		if step.WaitPattern != "" && step.SettleMs != nil && *step.SettleMs > 0 {
+			return fmt.Errorf("step %d: wait_pattern and settle_ms are mutually exclusive", i+1)
+		}
+	}
+	return nil
+}
+
+// options resolves the step's wait settings against the script defaults.
+func (s ExecStep) options(defaults ExecOptions) ExecOptions {
+	opts := defaults
+	opts.Input = s.Input
+	if s.WaitPattern != "" {
+		opts.WaitPattern = s.WaitPattern
+		opts.SettleMs = 0
+		opts.SettleSet = false
+	}
+	if s.SettleMs != nil {
+		opts.WaitPattern = ""
+		opts.SettleMs = *s.SettleMs
+		opts.SettleSet = true
+	}
+	if s.TimeoutSec > 0 {
+		opts.TimeoutSec = s.TimeoutSec
+	}
+	if s.Probe != nil {
+		opts.Probe = *s.Probe
+	}
+	return opts
+}
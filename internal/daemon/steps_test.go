@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSteps(t *testing.T) {
+	steps, err := ParseSteps([]byte("# setup\ncd /tmp\n\nls -la\r\n"))
+	if err != nil {
+		t.Fatalf("ParseSteps lines: %v", err)
+	}
+	if len(steps) != 2 || steps[0].Input != "cd /tmp" || steps[1].Input != "ls -la" {
+		t.Errorf("line steps = %+v", steps)
+	}
+
+	steps, err = ParseSteps([]byte(`[{"input": "make", "wait_pattern": "\\$ $", "timeout_sec": 60}, {"input": "ls", "settle_ms": 100}]`))
+	if err != nil {
+		t.Fatalf("ParseSteps JSON: %v", err)
+	}
+	opts := steps[0].options(ExecOptions{SettleMs: 500, TimeoutSec: 10})
+	if opts.WaitPattern != `\$ $` || opts.SettleMs != 0 || opts.TimeoutSec != 60 {
+		t.Errorf("step 1 options = %+v", opts)
+	}
+	opts = steps[1].options(ExecOptions{WaitPattern: ">", TimeoutSec: 10})
+	if opts.WaitPattern != "" || opts.SettleMs != 100 || opts.TimeoutSec != 10 {
+		t.Errorf("step 2 options = %+v", opts)
+	}
+
+	if _, err := ParseSteps([]byte(`[{"input": ""}]`)); err == nil {
+		t.Error("empty input should be rejected")
+	}
+	if _, err := ParseSteps([]byte(`[{"input": "x", "wait_pattern": "a", "settle_ms": 5}]`)); err == nil {
+		t.Error("wait_pattern with settle_ms should be rejected")
+	}
+}
+
+func TestExecSteps(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("steps", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("steps")
+
+	settle := 200
+	probe := true
+	steps := []ExecStep{
+		{Input: "echo one"},
+		{Input: "false", Probe: &probe},
+		{Input: "echo three"},
+	}
+	defaults := ExecOptions{SettleMs: settle, TimeoutSec: 5}
+
+	results := client.ExecSteps("steps", steps, defaults, false)
+	if len(results) != 3 {
+		t.Fatalf("got %d results", len(results))
+	}
+	if results[0].Status != StepOK || !strings.Contains(results[0].Output, "one") {
+		t.Errorf("step 1 = %+v", results[0])
+	}
+	if results[1].Status != StepFailed || results[1].ExitCode == nil || *results[1].ExitCode != 1 {
+		t.Errorf("step 2 = %+v", results[1])
+	}
+	if results[2].Status != StepSkipped {
+		t.Errorf("step 3 = %+v", results[2])
+	}
+
+	results = client.ExecSteps("steps", []ExecStep{
+		{Input: "echo a", WaitPattern: "never-printed", TimeoutSec: 1},
+		{Input: "echo b"},
+	}, defaults, true)
+	if results[0].Status != StepTimeout || results[1].Status != StepOK {
+		t.Errorf("keep going: %+v", results)
+	}
+}
@@ -16,20 +16,111 @@ type SessionMeta struct {
 	State     SessionState `json:"state"`
 	CreatedAt time.Time    `json:"created_at"`
 	StoppedAt *time.Time   `json:"stopped_at,omitempty"`
-	ReadPos   int64            `json:"read_pos"`
-	Cursors   map[string]int64 `json:"cursors,omitempty"`
-	Cols      int              `json:"cols"`
-	Rows      int          `json:"rows"`
-	TUIMode   bool         `json:"tui_mode,omitempty"`
+	// ExitCode is the process's exit status (128+N when killed by signal
+	// N), set once the daemon saw it exit; ExitSignal names the signal.
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	ExitSignal string `json:"exit_signal,omitempty"`
+	// EndReason is why the session stopped running (EndReasonExited, ...);
+	// EndError the PTY error behind EndReasonPTYError.
+	EndReason       string           `json:"end_reason,omitempty"`
+	EndError        string           `json:"end_error,omitempty"`
+	ReadPos         int64            `json:"read_pos"`
+	Cursors         map[string]int64 `json:"cursors,omitempty"`
+	Cols            int              `json:"cols"`
+	Rows            int              `json:"rows"`
+	TUIMode         bool             `json:"tui_mode,omitempty"`
+	FrameHistory    int              `json:"frame_history,omitempty"`
+	Scrollback      int              `json:"scrollback,omitempty"`
+	CaptureRaw      string           `json:"capture_raw,omitempty"`
+	FrameBoundaries []string         `json:"frame_boundaries,omitempty"`
+	Workspace       string           `json:"workspace,omitempty"`
+	Nice            *int             `json:"nice,omitempty"`
+	IOClass         string           `json:"io_class,omitempty"`
+	Limits          *ResourceLimits  `json:"limits,omitempty"`
+	Encoding        string           `json:"encoding,omitempty"`
+	MirrorInput     bool             `json:"mirror_input,omitempty"`
+	CapturePriority string           `json:"capture_priority,omitempty"`
+	OnExit          []string         `json:"on_exit,omitempty"`
+	SnapshotMode    string           `json:"snapshot_mode,omitempty"`
+	// ResizeCrash is set when the process died right after a snapshot
+	// resized it (see snapshotmode.go).
+	ResizeCrash bool `json:"resize_crash,omitempty"`
+	// Banner is the startup output held back by create's
+	// swallow_output_until instead of being stored.
+	Banner string `json:"banner,omitempty"`
+	// Truncations counts, per reader ("" for the default read position, else
+	// the cursor name), how often output was dropped from under that reader
+	// (clear, or the memory buffer wrapping past its position) since its
+	// last new-mode read.
+	Truncations map[string]int64 `json:"truncations,omitempty"`
+	// Bookmarks are named output positions, set by hand or by the
+	// BookmarkWatches (see bookmark.go).
+	Bookmarks       []Bookmark      `json:"bookmarks,omitempty"`
+	BookmarkWatches []BookmarkWatch `json:"bookmark_watches,omitempty"`
+}
+
+// noteTruncation records that output was dropped from under reader.
+func (m *SessionMeta) noteTruncation(reader string) {
+	if m.Truncations == nil {
+		m.Truncations = make(map[string]int64)
+	}
+	m.Truncations[reader]++
+}
+
+// dropOutput moves the read position, cursors and bookmarks back after n
+// bytes were dropped from the front of the output, noting a truncation for
+// each reader that had not read them yet. Bookmarks into the dropped bytes
+// go with them.
+func (m *SessionMeta) dropOutput(n int64) {
+	if m.ReadPos < n {
+		m.noteTruncation("")
+	}
+	for k, v := range m.Cursors {
+		if v < n {
+			m.noteTruncation(k)
+		}
+	}
+	if m.ReadPos > 0 {
+		m.ReadPos = max(0, m.ReadPos-n)
+	}
+	for k, v := range m.Cursors {
+		m.Cursors[k] = max(0, v-n)
+	}
+	var kept []Bookmark // a new slice: earlier copies of the meta may share the old one
+	for _, b := range m.Bookmarks {
+		if b.Offset >= n {
+			b.Offset -= n
+			kept = append(kept, b)
+		}
+	}
+	m.Bookmarks = kept
+}
+
+// takeTruncations removes n reported truncations from reader's count.
+func (m *SessionMeta) takeTruncations(reader string, n int64) {
+	if n <= 0 {
+		return
+	}
+	if m.Truncations[reader] -= n; m.Truncations[reader] <= 0 {
+		delete(m.Truncations, reader)
+	}
 }
 
 type OutputStorage interface {
 	Append(session string, data []byte) error
 	ReadFrom(session string, offset int64) ([]byte, error)
+	// ReadRange reads at most maxBytes from offset (to the end when
+	// maxBytes <= 0), touching only that part of the output.
+	ReadRange(session string, offset, maxBytes int64) ([]byte, error)
 	ReadAll(session string) ([]byte, error)
 	Size(session string) (int64, error)
 	Clear(session string) error
 
+	// Chunks returns when the stored output was appended, oldest first.
+	// SetChunks replaces them, for output that was rewritten.
+	Chunks(session string) ([]Chunk, error)
+	SetChunks(session string, chunks []Chunk) error
+
 	Create(session string, meta *SessionMeta) error
 	Delete(session string) error
 	Exists(session string) bool
@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+)
+
+// Output compression for FileStorage (output_compression): each sealed
+// segment is gzipped in the background once it is sealed (reads use the
+// plain file until then) and decompressed on the fly by reads,
+// while the live file stays plain for appends. Without a max_file_output
+// cap, output is sealed every CompressSegmentSize bytes so long sessions
+// compress too. Segments sealed before compression was turned on stay
+// plain.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// ValidateCompression checks an output compression name.
+func ValidateCompression(name string) error {
+	switch name {
+	case CompressionNone, CompressionGzip:
+		return nil
+	}
+	return fmt.Errorf("invalid output compression %q (expected gzip or none)", name)
+}
+
+// SetCompression sets how segments sealed from now on are compressed
+// (CompressionNone or "" for not at all). It reports whether it changed.
+func (s *FileStorage) SetCompression(name string) bool {
+	if name == CompressionNone {
+		name = ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.compression == name {
+		return false
+	}
+	s.compression = name
+	return true
+}
+
+// compressInBackground compresses session's newly sealed segment seg
+// without holding s.mu, so the session's appends and reads go on meanwhile.
+func (s *FileStorage) compressInBackground(session string, seg segment) {
+	s.compressions.Add(1)
+	go func() {
+		defer s.compressions.Done()
+		s.compressMu.Lock()
+		defer s.compressMu.Unlock()
+		if err := s.compressSegment(session, seg); err != nil {
+			// Still readable as it is.
+			log.Printf("compress %s: %v", s.segmentPath(session, seg), err)
+		}
+	}()
+}
+
+// compressSegment writes a gzipped copy of the plain segment seg and, if
+// the session still has that segment, swaps it in for the plain file. The
+// plain file goes only once the compressed one is whole.
+func (s *FileStorage) compressSegment(session string, seg segment) error {
+	plain := s.segmentPath(session, seg)
+	packed := seg
+	packed.gz = true
+	path := s.segmentPath(session, packed)
+
+	// Held open to the end, so no other file can take its inode.
+	in, err := os.Open(plain)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	source, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	zw, _ := gzip.NewWriterLevel(out, gzip.BestSpeed)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Meanwhile the cap may have dropped the segment, or the session been
+	// cleared and a new segment sealed under the same name.
+	segs := append([]segment(nil), s.sealedSegments(session)...)
+	i := slices.IndexFunc(segs, func(sealed segment) bool { return sealed.seq == seg.seq && !sealed.gz })
+	if current, err := os.Stat(plain); i < 0 || err != nil || !os.SameFile(source, current) {
+		os.Remove(tmp)
+		return nil
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	packed.disk = info.Size()
+	segs[i] = packed
+	s.setSealedSegments(session, segs)
+	os.Remove(plain)
+	return nil
+}
+
+// readSegmentFrom reads a sealed segment at path from offset to its end,
+// decompressing it when compressed; a missing file reads as empty.
+func readSegmentFrom(path string, seg segment, offset int64) ([]byte, error) {
+	return readSegmentRange(path, seg, offset, 0)
+}
+
+// readSegmentRange is readSegmentFrom stopping after n bytes (n <= 0: at
+// the end). A compressed segment is decompressed only as far as needed.
+func readSegmentRange(path string, seg segment, offset, n int64) ([]byte, error) {
+	if !seg.gz {
+		return readFileRange(path, offset, n)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []byte{}, nil
+		}
+		return nil, fmt.Errorf("open output file: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read compressed output: %w", err)
+	}
+	if _, err := io.CopyN(io.Discard, zr, offset); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read compressed output: %w", err)
+	}
+	var r io.Reader = zr
+	if n > 0 {
+		r = io.LimitReader(zr, n)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read compressed output: %w", err)
+	}
+	return data, nil
+}
+
+// gzipSize returns the uncompressed size of a gzip file from its trailer
+// (modulo 4 GiB; segments are far smaller).
+func gzipSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var trailer [4]byte
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() < 18 {
+		return 0, fmt.Errorf("%s: not a gzip file", path)
+	}
+	if _, err := f.ReadAt(trailer[:], info.Size()-4); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint32(trailer[:])), nil
+}
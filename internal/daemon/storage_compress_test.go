@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStorageCompression(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetMaxOutputSize(80 * 1024) // 10 KB segments
+	s.SetCompression(CompressionGzip)
+	if err := s.Create("log", &SessionMeta{Name: "log"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var all bytes.Buffer
+	for i := 0; all.Len() < 32*1024; i++ {
+		line := fmt.Sprintf("%05d request handled in 3ms\n", i)
+		all.WriteString(line)
+		if err := s.Append("log", []byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Sealed segments are compressed in the background.
+	s.compressions.Wait()
+
+	segs := scanSegments(dir, "log")
+	if len(segs) != 3 {
+		t.Fatalf("segments = %+v, want 3", segs)
+	}
+	for _, seg := range segs {
+		if !seg.gz || seg.disk >= seg.size {
+			t.Errorf("segment %+v not compressed", seg)
+		}
+		plain := seg
+		plain.gz = false
+		if _, err := os.Stat(segmentFile(dir, "log", plain)); !os.IsNotExist(err) {
+			t.Errorf("plain segment %d left behind", seg.seq)
+		}
+	}
+
+	want := all.String()
+	for _, offset := range []int64{0, 100, 10 * 1024, int64(len(want)) - 3} {
+		data, err := s.ReadFrom("log", offset)
+		if err != nil {
+			t.Fatalf("ReadFrom(%d): %v", offset, err)
+		}
+		if string(data) != want[offset:] {
+			t.Errorf("ReadFrom(%d) returned %d bytes, want %d", offset, len(data), len(want)-int(offset))
+		}
+	}
+	if size, _ := s.Size("log"); size != int64(len(want)) {
+		t.Errorf("size = %d, want %d", size, len(want))
+	}
+	if usage := s.sessionDiskUsage("log"); usage >= int64(len(want)) {
+		t.Errorf("disk usage = %d, want below the %d bytes of output", usage, len(want))
+	}
+
+	// A crash between compressing and removing the plain file leaves both.
+	seg := segs[0]
+	data, _ := readSegmentFrom(segmentFile(dir, "log", seg), seg, 0)
+	seg.gz = false
+	if err := os.WriteFile(segmentFile(dir, "log", seg), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	offline, err := OfflineRead(dir, "log", ReadModeAll, "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offline.Output != want {
+		t.Errorf("offline read returned %d bytes, want %d", len(offline.Output), len(want))
+	}
+
+	// So can a crash while compressing, which the next scan removes.
+	partial := segmentFile(dir, "log", segs[0]) + ".tmp"
+	if err := os.WriteFile(partial, []byte("partial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := scanSegments(dir, "log"); len(got) != 3 {
+		t.Errorf("segments with a partial file = %+v, want 3", got)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("partial compressed file left behind: %v", err)
+	}
+
+	if err := s.Delete("log"); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "log*")); len(files) != 0 {
+		t.Errorf("files left after delete: %v", files)
+	}
+}
+
+func TestFileStorageCompressionUncapped(t *testing.T) {
+	s, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetCompression(CompressionGzip)
+	if err := s.Create("big", &SessionMeta{Name: "big"}); err != nil {
+		t.Fatal(err)
+	}
+	chunk := bytes.Repeat([]byte("x"), 1024*1024)
+	for i := 0; i < 9; i++ {
+		if err := s.Append("big", chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.compressions.Wait()
+	// Sealed at CompressSegmentSize, nothing dropped.
+	if segs := s.sealedSegments("big"); len(segs) != 1 || !segs[0].gz || segs[0].size != CompressSegmentSize {
+		t.Errorf("segments = %+v, want one compressed segment of %d bytes", segs, CompressSegmentSize)
+	}
+	if size, _ := s.Size("big"); size != 9*1024*1024 {
+		t.Errorf("size = %d, want all output kept", size)
+	}
+	if data, _ := s.ReadFrom("big", CompressSegmentSize-1); len(data) != 1024*1024+1 {
+		t.Errorf("read across the segment end returned %d bytes", len(data))
+	}
+}
+
+func TestFileStorageCompressionOfClearedSegment(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetMaxOutputSize(8 * 1024) // 1 KB segments
+	s.SetCompression(CompressionGzip)
+	if err := s.Create("log", &SessionMeta{Name: "log"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold compression back until the session is cleared.
+	s.compressMu.Lock()
+	if err := s.Append("log", bytes.Repeat([]byte("x"), 1500)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append("log", []byte("y")); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.sealedSegments("log")) == 0 {
+		t.Fatal("no segment sealed")
+	}
+	if err := s.Clear("log"); err != nil {
+		t.Fatal(err)
+	}
+	s.compressMu.Unlock()
+	s.compressions.Wait()
+
+	if files, _ := filepath.Glob(filepath.Join(dir, "log.out.*")); len(files) != 0 {
+		t.Errorf("segment files after clear: %v", files)
+	}
+	if size, _ := s.Size("log"); size != 0 {
+		t.Errorf("size after clear = %d, want 0", size)
+	}
+}
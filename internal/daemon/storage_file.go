@@ -3,16 +3,34 @@ package daemon
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
 type FileStorage struct {
 	dataDir string
 	mu      sync.RWMutex
+
+	// maxOutputSize caps each session's output on disk (0: unbounded). Past
+	// the cap, output is kept as a ring of numbered segment files; see
+	// rotateLocked.
+	maxOutputSize int64
+	segMu         sync.Mutex
+	sealed        map[string][]segment
+	// compression is how sealed segments are compressed ("" for none);
+	// see storage_compress.go. Segments are compressed in the background,
+	// one at a time under compressMu; compressions counts those running.
+	compression  string
+	compressMu   sync.Mutex
+	compressions sync.WaitGroup
+
+	// lastChunk is the time of each session's newest chunk record, for
+	// coalescing appends (see ChunkTimeGranularity). Guarded by mu.
+	lastChunk map[string]time.Time
 }
 
 func NewFileStorage(dataDir string) (*FileStorage, error) {
@@ -30,6 +48,12 @@ func (s *FileStorage) metaPath(session string) string {
 	return filepath.Join(s.dataDir, session+".meta")
 }
 
+// timesPath is the session's chunk times: one "<offset> <unix nanoseconds>"
+// line per chunk.
+func (s *FileStorage) timesPath(session string) string {
+	return filepath.Join(s.dataDir, session+".times")
+}
+
 func (s *FileStorage) Append(session string, data []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -40,62 +64,101 @@ func (s *FileStorage) Append(session string, data []byte) error {
 	}
 	defer f.Close()
 
+	if err := lockFile(f, syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	if len(data) > 0 {
+		if err := s.recordChunkLocked(session, f); err != nil {
+			return err
+		}
+	}
 	if _, err := f.Write(data); err != nil {
 		return fmt.Errorf("write output: %w", err)
 	}
+
+	if s.sealing() {
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("stat output: %w", err)
+		}
+		if info.Size() >= s.segmentSize() {
+			return s.rotateLocked(session, info.Size())
+		}
+	}
 	return nil
 }
 
+// ReadFrom reads from offset to the end of the output, across the sealed
+// segments and the live file.
 func (s *FileStorage) ReadFrom(session string, offset int64) ([]byte, error) {
+	return s.ReadRange(session, offset, 0)
+}
+
+// ReadRange reads at most maxBytes from offset, opening only the segments
+// the range covers and reading only the covered part of each.
+func (s *FileStorage) ReadRange(session string, offset, maxBytes int64) ([]byte, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	f, err := os.Open(s.outputPath(session))
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []byte{}, nil
+	// left is what the range still needs; 0 reads to the end.
+	left := func(out []byte) int64 {
+		if maxBytes <= 0 {
+			return 0
 		}
-		return nil, fmt.Errorf("open output file: %w", err)
+		return maxBytes - int64(len(out))
 	}
-	defer f.Close()
 
-	if _, err := f.Seek(offset, io.SeekStart); err != nil {
-		return nil, fmt.Errorf("seek: %w", err)
+	var out []byte
+	var pos int64
+	for _, seg := range s.sealedSegments(session) {
+		if maxBytes > 0 && left(out) <= 0 {
+			return out, nil
+		}
+		if offset < pos+seg.size {
+			data, err := readSegmentRange(s.segmentPath(session, seg), seg, max(0, offset-pos), left(out))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, data...)
+		}
+		pos += seg.size
+	}
+	if maxBytes > 0 && left(out) <= 0 {
+		return out, nil
 	}
 
-	data, err := io.ReadAll(f)
+	data, err := readFileRange(s.outputPath(session), max(0, offset-pos), left(out))
 	if err != nil {
-		return nil, fmt.Errorf("read output: %w", err)
+		return nil, err
+	}
+	if out == nil {
+		return data, nil
 	}
-	return data, nil
+	return append(out, data...), nil
 }
 
 func (s *FileStorage) ReadAll(session string) ([]byte, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	data, err := os.ReadFile(s.outputPath(session))
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []byte{}, nil
-		}
-		return nil, fmt.Errorf("read output: %w", err)
-	}
-	return data, nil
+	return s.ReadFrom(session, 0)
 }
 
 func (s *FileStorage) Size(session string) (int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	var size int64
+	for _, seg := range s.sealedSegments(session) {
+		size += seg.size
+	}
+
 	info, err := os.Stat(s.outputPath(session))
 	if err != nil {
 		if os.IsNotExist(err) {
-			return 0, nil
+			return size, nil
 		}
 		return 0, fmt.Errorf("stat output: %w", err)
 	}
-	return info.Size(), nil
+	return size + info.Size(), nil
 }
 
 func (s *FileStorage) Clear(session string) error {
@@ -106,9 +169,11 @@ func (s *FileStorage) Clear(session string) error {
 		return fmt.Errorf("session %q not found", session)
 	}
 
-	if err := os.Truncate(s.outputPath(session), 0); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("truncate output: %w", err)
+	if err := truncateLocked(s.outputPath(session)); err != nil {
+		return err
 	}
+	s.removeSegmentsLocked(session)
+	s.removeChunksLocked(session)
 
 	meta, err := s.loadMetaLocked(session)
 	if err != nil {
@@ -149,6 +214,8 @@ func (s *FileStorage) Create(session string, meta *SessionMeta) error {
 		return fmt.Errorf("create output file: %w", err)
 	}
 	f.Close()
+	s.removeSegmentsLocked(session)
+	s.removeChunksLocked(session)
 
 	return s.saveMetaLocked(session, meta)
 }
@@ -159,6 +226,8 @@ func (s *FileStorage) Delete(session string) error {
 
 	os.Remove(s.outputPath(session))
 	os.Remove(s.metaPath(session))
+	s.removeSegmentsLocked(session)
+	s.removeChunksLocked(session)
 	return nil
 }
 
@@ -243,3 +312,125 @@ func (s *FileStorage) ListSessions() ([]string, error) {
 	}
 	return sessions, nil
 }
+
+// recordChunkLocked notes the time of a write about to go to the end of the
+// live output file f. Callers hold s.mu.
+func (s *FileStorage) recordChunkLocked(session string, f *os.File) error {
+	now := time.Now()
+	if last, ok := s.lastChunk[session]; ok && now.Sub(last) < ChunkTimeGranularity {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat output: %w", err)
+	}
+	offset := info.Size()
+	for _, seg := range s.sealedSegments(session) {
+		offset += seg.size
+	}
+
+	times, err := os.OpenFile(s.timesPath(session), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("open times file: %w", err)
+	}
+	defer times.Close()
+	if _, err := fmt.Fprintf(times, "%d %d\n", offset, now.UnixNano()); err != nil {
+		return fmt.Errorf("write times: %w", err)
+	}
+	if s.lastChunk == nil {
+		s.lastChunk = make(map[string]time.Time)
+	}
+	s.lastChunk[session] = now
+	return nil
+}
+
+func (s *FileStorage) Chunks(session string) ([]Chunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := os.Stat(s.metaPath(session)); os.IsNotExist(err) {
+		return nil, fmt.Errorf("session %q not found", session)
+	}
+	return s.loadChunksLocked(session)
+}
+
+func (s *FileStorage) loadChunksLocked(session string) ([]Chunk, error) {
+	data, err := os.ReadFile(s.timesPath(session))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read times: %w", err)
+	}
+	var chunks []Chunk
+	for _, line := range strings.Split(string(data), "\n") {
+		var offset, nanos int64
+		if _, err := fmt.Sscanf(line, "%d %d", &offset, &nanos); err != nil {
+			continue // empty last line, or a write cut short
+		}
+		chunks = append(chunks, Chunk{Offset: offset, At: time.Unix(0, nanos)})
+	}
+	return chunks, nil
+}
+
+func (s *FileStorage) SetChunks(session string, chunks []Chunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.metaPath(session)); os.IsNotExist(err) {
+		return fmt.Errorf("session %q not found", session)
+	}
+	return s.saveChunksLocked(session, chunks)
+}
+
+func (s *FileStorage) saveChunksLocked(session string, chunks []Chunk) error {
+	var b strings.Builder
+	for _, c := range chunks {
+		fmt.Fprintf(&b, "%d %d\n", c.Offset, c.At.UnixNano())
+	}
+	if err := os.WriteFile(s.timesPath(session), []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("write times: %w", err)
+	}
+	if len(chunks) > 0 {
+		if s.lastChunk == nil {
+			s.lastChunk = make(map[string]time.Time)
+		}
+		s.lastChunk[session] = chunks[len(chunks)-1].At
+	}
+	return nil
+}
+
+// removeChunksLocked deletes a session's chunk times. Callers hold s.mu.
+func (s *FileStorage) removeChunksLocked(session string) {
+	os.Remove(s.timesPath(session))
+	delete(s.lastChunk, session)
+}
+
+// lockFile takes an advisory flock on f, released when f is closed. Offline
+// readers (see OfflineRead) take a shared lock, so they never see a partial
+// write or a half-done truncate from the daemon.
+func lockFile(f *os.File, how int) error {
+	if err := syscall.Flock(int(f.Fd()), how); err != nil { // #nosec G115 -- file descriptors fit in int
+		return fmt.Errorf("lock %s: %w", filepath.Base(f.Name()), err)
+	}
+	return nil
+}
+
+func truncateLocked(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0600)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("open output file: %w", err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f, syscall.LOCK_EX); err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("truncate output: %w", err)
+	}
+	return nil
+}
@@ -2,51 +2,95 @@ package daemon
 
 import (
 	"fmt"
+	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type MemoryStorage struct {
 	mu            sync.RWMutex
 	outputs       map[string][]byte
+	chunks        map[string][]Chunk
 	metas         map[string]*SessionMeta
 	maxOutputSize int
+
+	// The budget for all sessions' output together (see membudget.go).
+	maxMemory int
+	eviction  string
+	spillDir  string
+	spilled   map[string]int64         // session -> size of its output in the spill dir
+	spilling  map[string]bool          // sessions whose spill file is being written
+	lastRead  map[string]*atomic.Int64 // session -> unix nanoseconds of its last read
+	onEvict   func(MemoryEviction)
 }
 
 func NewMemoryStorage(maxOutputSize int) *MemoryStorage {
 	return &MemoryStorage{
 		outputs:       make(map[string][]byte),
+		chunks:        make(map[string][]Chunk),
 		metas:         make(map[string]*SessionMeta),
 		maxOutputSize: maxOutputSize,
+		eviction:      EvictionSpill,
+		spilled:       make(map[string]int64),
+		spilling:      make(map[string]bool),
+		lastRead:      make(map[string]*atomic.Int64),
+	}
+}
+
+// SetMaxOutputSize changes the buffer limit. Buffers over a lower limit
+// are cut on their next write. It reports whether the limit changed.
+func (s *MemoryStorage) SetMaxOutputSize(size int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxOutputSize == size {
+		return false
 	}
+	s.maxOutputSize = size
+	return true
 }
 
 func (s *MemoryStorage) Append(session string, data []byte) error {
+	if err := s.appendOutput(session, data); err != nil {
+		return err
+	}
+	s.enforceBudget(session)
+	return nil
+}
+
+func (s *MemoryStorage) appendOutput(session string, data []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.metas[session]; !exists {
 		return fmt.Errorf("session %q not found", session)
 	}
+	if err := s.unspillLocked(session); err != nil {
+		return err
+	}
 
+	if len(data) > 0 {
+		s.chunks[session] = addChunk(s.chunks[session], int64(len(s.outputs[session])), time.Now())
+	}
 	s.outputs[session] = append(s.outputs[session], data...)
 
 	if s.maxOutputSize > 0 && len(s.outputs[session]) > s.maxOutputSize {
 		excess := len(s.outputs[session]) - s.maxOutputSize
 		s.outputs[session] = s.outputs[session][excess:]
+		s.chunks[session] = dropChunks(s.chunks[session], int64(excess))
 		if meta, ok := s.metas[session]; ok {
-			if meta.ReadPos > 0 {
-				meta.ReadPos = max(0, meta.ReadPos-int64(excess))
-			}
-			for k, v := range meta.Cursors {
-				meta.Cursors[k] = max(0, v-int64(excess))
-			}
+			meta.dropOutput(int64(excess))
 		}
 	}
-
 	return nil
 }
 
 func (s *MemoryStorage) ReadFrom(session string, offset int64) ([]byte, error) {
+	return s.ReadRange(session, offset, 0)
+}
+
+func (s *MemoryStorage) ReadRange(session string, offset, maxBytes int64) ([]byte, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -54,11 +98,19 @@ func (s *MemoryStorage) ReadFrom(session string, offset int64) ([]byte, error) {
 	if !exists {
 		return nil, fmt.Errorf("session %q not found", session)
 	}
+	s.noteRead(session)
+	if _, spilled := s.spilled[session]; spilled {
+		return readFileRange(s.spillPath(session), offset, maxBytes)
+	}
 
 	if offset >= int64(len(output)) {
 		return []byte{}, nil
 	}
-	return append([]byte{}, output[offset:]...), nil
+	end := int64(len(output))
+	if maxBytes > 0 && offset+maxBytes < end {
+		end = offset + maxBytes
+	}
+	return append([]byte{}, output[offset:end]...), nil
 }
 
 func (s *MemoryStorage) ReadAll(session string) ([]byte, error) {
@@ -69,6 +121,10 @@ func (s *MemoryStorage) ReadAll(session string) ([]byte, error) {
 	if !exists {
 		return nil, fmt.Errorf("session %q not found", session)
 	}
+	s.noteRead(session)
+	if _, spilled := s.spilled[session]; spilled {
+		return readFileFrom(s.spillPath(session), 0)
+	}
 	return append([]byte{}, output...), nil
 }
 
@@ -80,6 +136,9 @@ func (s *MemoryStorage) Size(session string) (int64, error) {
 	if !exists {
 		return 0, fmt.Errorf("session %q not found", session)
 	}
+	if size, spilled := s.spilled[session]; spilled {
+		return size, nil
+	}
 	return int64(len(output)), nil
 }
 
@@ -92,6 +151,11 @@ func (s *MemoryStorage) Clear(session string) error {
 	}
 
 	s.outputs[session] = []byte{}
+	delete(s.chunks, session)
+	if _, spilled := s.spilled[session]; spilled {
+		delete(s.spilled, session)
+		os.Remove(s.spillPath(session))
+	}
 	if meta, ok := s.metas[session]; ok {
 		meta.ReadPos = 0
 		meta.Cursors = nil
@@ -109,6 +173,29 @@ func (s *MemoryStorage) Create(session string, meta *SessionMeta) error {
 
 	s.outputs[session] = []byte{}
 	s.metas[session] = meta
+	s.lastRead[session] = new(atomic.Int64)
+	s.lastRead[session].Store(time.Now().UnixNano())
+	return nil
+}
+
+func (s *MemoryStorage) Chunks(session string) ([]Chunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.metas[session]; !exists {
+		return nil, fmt.Errorf("session %q not found", session)
+	}
+	return append([]Chunk(nil), s.chunks[session]...), nil
+}
+
+func (s *MemoryStorage) SetChunks(session string, chunks []Chunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.metas[session]; !exists {
+		return fmt.Errorf("session %q not found", session)
+	}
+	s.chunks[session] = append([]Chunk(nil), chunks...)
 	return nil
 }
 
@@ -117,7 +204,13 @@ func (s *MemoryStorage) Delete(session string) error {
 	defer s.mu.Unlock()
 
 	delete(s.outputs, session)
+	delete(s.chunks, session)
 	delete(s.metas, session)
+	delete(s.lastRead, session)
+	if _, spilled := s.spilled[session]; spilled {
+		delete(s.spilled, session)
+		os.Remove(s.spillPath(session))
+	}
 	return nil
 }
 
@@ -144,10 +237,34 @@ func (s *MemoryStorage) LoadMeta(session string) (*SessionMeta, error) {
 			copied.Cursors[k] = v
 		}
 	}
+	if meta.Truncations != nil {
+		copied.Truncations = make(map[string]int64, len(meta.Truncations))
+		for k, v := range meta.Truncations {
+			copied.Truncations[k] = v
+		}
+	}
 	if meta.StoppedAt != nil {
 		t := *meta.StoppedAt
 		copied.StoppedAt = &t
 	}
+	// Nothing the caller gets may share memory with the stored meta, which
+	// UpdateMeta changes in place (dropOutput, watches counting).
+	if meta.ExitCode != nil {
+		c := *meta.ExitCode
+		copied.ExitCode = &c
+	}
+	if meta.Nice != nil {
+		n := *meta.Nice
+		copied.Nice = &n
+	}
+	if meta.Limits != nil {
+		l := *meta.Limits
+		copied.Limits = &l
+	}
+	copied.FrameBoundaries = slices.Clone(meta.FrameBoundaries)
+	copied.OnExit = slices.Clone(meta.OnExit)
+	copied.Bookmarks = slices.Clone(meta.Bookmarks)
+	copied.BookmarkWatches = slices.Clone(meta.BookmarkWatches)
 	return &copied, nil
 }
 
@@ -0,0 +1,262 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileRingSegments is how many segments a capped session's output file is
+// split into. The cap is kept by deleting whole segments, oldest first, so a
+// capped session retains between about 3/4 of the cap and the cap.
+const FileRingSegments = 8
+
+// segment is a sealed, read-only piece of a session's output, stored as
+// <name>.out.<seq>, or <name>.out.<seq>.gz when compressed (see
+// storage_compress.go). Sealed segments come before the live <name>.out in
+// offset order.
+type segment struct {
+	seq  int
+	size int64 // output bytes
+	disk int64 // file bytes
+	gz   bool
+}
+
+// SetMaxOutputSize caps how much output each session keeps on disk (0:
+// unbounded). It takes effect on the next write. It reports whether the cap
+// changed.
+func (s *FileStorage) SetMaxOutputSize(size int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if int64(size) == s.maxOutputSize {
+		return false
+	}
+	s.maxOutputSize = int64(size)
+	return true
+}
+
+func (s *FileStorage) segmentPath(session string, seg segment) string {
+	return segmentFile(s.dataDir, session, seg)
+}
+
+func segmentFile(dataDir, session string, seg segment) string {
+	path := fmt.Sprintf("%s.out.%d", filepath.Join(dataDir, session), seg.seq)
+	if seg.gz {
+		path += ".gz"
+	}
+	return path
+}
+
+// segmentOwner returns the session a sealed segment file name belongs to.
+func segmentOwner(fileName string) (string, bool) {
+	fileName = strings.TrimSuffix(fileName, ".gz")
+	i := strings.LastIndex(fileName, ".out.")
+	if i <= 0 {
+		return "", false
+	}
+	if _, err := strconv.Atoi(fileName[i+len(".out."):]); err != nil {
+		return "", false
+	}
+	return fileName[:i], true
+}
+
+// scanSegments lists a session's sealed segments on disk, oldest first,
+// removing compressed files a compression cut short left behind.
+func scanSegments(dataDir, session string) []segment {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return nil
+	}
+	var segs []segment
+	for _, entry := range entries {
+		if partial, ok := strings.CutSuffix(entry.Name(), ".gz.tmp"); ok {
+			if owner, ok := segmentOwner(partial); ok && owner == session {
+				os.Remove(filepath.Join(dataDir, entry.Name()))
+			}
+			continue
+		}
+		owner, ok := segmentOwner(entry.Name())
+		if !ok || owner != session {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name, gz := strings.CutSuffix(entry.Name(), ".gz")
+		seq, _ := strconv.Atoi(name[len(session)+len(".out."):])
+		seg := segment{seq: seq, size: info.Size(), disk: info.Size(), gz: gz}
+		if gz {
+			if seg.size, err = gzipSize(filepath.Join(dataDir, entry.Name())); err != nil {
+				continue
+			}
+		}
+		segs = append(segs, seg)
+	}
+	sort.Slice(segs, func(i, j int) bool {
+		if segs[i].seq == segs[j].seq {
+			return segs[i].gz
+		}
+		return segs[i].seq < segs[j].seq
+	})
+	// A crash while compressing can leave a segment both ways; both are
+	// whole, the compressed one is used.
+	segs = slices.CompactFunc(segs, func(a, b segment) bool { return a.seq == b.seq })
+	return segs
+}
+
+// sealedSegments returns a session's sealed segments, oldest first, scanning
+// the data dir the first time a session is seen. Callers hold s.mu.
+func (s *FileStorage) sealedSegments(session string) []segment {
+	s.segMu.Lock()
+	defer s.segMu.Unlock()
+
+	if segs, ok := s.sealed[session]; ok {
+		return segs
+	}
+	segs := scanSegments(s.dataDir, session)
+	if s.sealed == nil {
+		s.sealed = make(map[string][]segment)
+	}
+	s.sealed[session] = segs
+	return segs
+}
+
+func (s *FileStorage) setSealedSegments(session string, segs []segment) {
+	s.segMu.Lock()
+	defer s.segMu.Unlock()
+
+	if segs == nil {
+		delete(s.sealed, session)
+		return
+	}
+	if s.sealed == nil {
+		s.sealed = make(map[string][]segment)
+	}
+	s.sealed[session] = segs
+}
+
+// sealing reports whether the live output file is sealed into segments:
+// to keep the cap, or to compress it. Callers hold s.mu.
+func (s *FileStorage) sealing() bool {
+	return s.maxOutputSize > 0 || s.compression != ""
+}
+
+// segmentSize is how large the live output file grows before it is sealed.
+func (s *FileStorage) segmentSize() int64 {
+	if s.maxOutputSize == 0 {
+		return CompressSegmentSize
+	}
+	return max(1, s.maxOutputSize/FileRingSegments)
+}
+
+// rotateLocked seals the live output file of activeSize bytes into the next
+// segment, starting its compression when set to, and deletes the oldest segments until
+// the session fits its cap again, moving readers back by what was dropped.
+// Callers hold s.mu.
+func (s *FileStorage) rotateLocked(session string, activeSize int64) error {
+	segs := append([]segment(nil), s.sealedSegments(session)...)
+	seq := 1
+	if len(segs) > 0 {
+		seq = segs[len(segs)-1].seq + 1
+	}
+	seg := segment{seq: seq, size: activeSize, disk: activeSize}
+	if err := os.Rename(s.outputPath(session), s.segmentPath(session, seg)); err != nil {
+		return fmt.Errorf("seal output segment: %w", err)
+	}
+	f, err := os.OpenFile(s.outputPath(session), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("create output file: %w", err)
+	}
+	f.Close()
+	if s.compression != "" {
+		s.compressInBackground(session, seg)
+	}
+	segs = append(segs, seg)
+	if s.maxOutputSize == 0 {
+		s.setSealedSegments(session, segs)
+		return nil
+	}
+
+	// Leave room for a full live segment on top of the sealed ones, but keep
+	// the newest segment even when a single large write overshoots the cap.
+	var total, dropped int64
+	for _, seg := range segs {
+		total += seg.size
+	}
+	for len(segs) > 1 && total+s.segmentSize() > s.maxOutputSize {
+		if err := os.Remove(s.segmentPath(session, segs[0])); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove output segment: %w", err)
+		}
+		total -= segs[0].size
+		dropped += segs[0].size
+		segs = segs[1:]
+	}
+	s.setSealedSegments(session, segs)
+
+	if dropped == 0 {
+		return nil
+	}
+	if chunks, err := s.loadChunksLocked(session); err == nil {
+		if err := s.saveChunksLocked(session, dropChunks(chunks, dropped)); err != nil {
+			return err
+		}
+	}
+	meta, err := s.loadMetaLocked(session)
+	if err != nil {
+		return err
+	}
+	meta.dropOutput(dropped)
+	return s.saveMetaLocked(session, meta)
+}
+
+// removeSegmentsLocked deletes all of a session's sealed segments. Callers
+// hold s.mu.
+func (s *FileStorage) removeSegmentsLocked(session string) {
+	for _, seg := range scanSegments(s.dataDir, session) {
+		os.Remove(s.segmentPath(session, seg))
+		if seg.gz {
+			seg.gz = false
+			os.Remove(s.segmentPath(session, seg)) // left by a crash while compressing
+		}
+	}
+	s.setSealedSegments(session, nil)
+}
+
+// readFileFrom reads path from offset to its end; a missing file reads as
+// empty.
+func readFileFrom(path string, offset int64) ([]byte, error) {
+	return readFileRange(path, offset, 0)
+}
+
+// readFileRange reads at most n bytes of path from offset (n <= 0: to the
+// end); a missing file reads as empty.
+func readFileRange(path string, offset, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []byte{}, nil
+		}
+		return nil, fmt.Errorf("open output file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek: %w", err)
+	}
+	var r io.Reader = f
+	if n > 0 {
+		r = io.LimitReader(f, n)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read output: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,180 @@
+package daemon
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFileStorageRing(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetMaxOutputSize(80) // 10-byte segments
+	if err := s.Create("ring", &SessionMeta{Name: "ring"}); err != nil {
+		t.Fatal(err)
+	}
+	s.UpdateMeta("ring", func(m *SessionMeta) {
+		m.ReadPos = 5
+		m.Cursors = map[string]int64{"ahead": 75}
+	})
+
+	var all string
+	for i := 0; i < 12; i++ {
+		chunk := strings.Repeat(string(rune('a'+i)), 10)
+		all += chunk
+		if err := s.Append("ring", []byte(chunk)); err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+	}
+
+	// 120 bytes written into a cap of 80: the oldest segments are gone and
+	// at most seven sealed segments remain beside an empty live file.
+	size, err := s.Size("ring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size > 80 || size < 60 {
+		t.Fatalf("size = %d, want within the cap", size)
+	}
+	kept := all[len(all)-int(size):]
+
+	data, err := s.ReadAll("ring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != kept {
+		t.Errorf("ReadAll = %q, want %q", data, kept)
+	}
+	for _, offset := range []int64{0, 3, 10, 15, size - 1, size, size + 5} {
+		data, err := s.ReadFrom("ring", offset)
+		if err != nil {
+			t.Fatalf("ReadFrom(%d): %v", offset, err)
+		}
+		if want := kept[min(offset, size):]; string(data) != want {
+			t.Errorf("ReadFrom(%d) = %q, want %q", offset, data, want)
+		}
+	}
+
+	meta, err := s.LoadMeta("ring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped := int64(len(all)) - size
+	if meta.ReadPos != 0 || meta.Truncations[""] == 0 {
+		t.Errorf("read pos = %d, truncations = %d; want 0 and counted", meta.ReadPos, meta.Truncations[""])
+	}
+	if meta.Cursors["ahead"] != 75-dropped || meta.Truncations["ahead"] != 0 {
+		t.Errorf("cursor = %d, truncations = %d; want %d and none", meta.Cursors["ahead"], meta.Truncations["ahead"], 75-dropped)
+	}
+
+	// A fresh storage over the same dir sees the same output, as after a
+	// daemon restart or in an offline read.
+	reopened, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := reopened.ReadAll("ring"); string(data) != kept {
+		t.Errorf("reopened ReadAll = %q, want %q", data, kept)
+	}
+	if usage := reopened.sessionDiskUsage("ring"); usage < size {
+		t.Errorf("disk usage = %d, want at least %d", usage, size)
+	}
+	if orphans := reopened.orphanFiles(map[string]bool{"ring": true}); len(orphans) != 0 {
+		t.Errorf("segments reported as orphans: %+v", orphans)
+	}
+	offline, err := OfflineRead(dir, "ring", ReadModeAll, "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offline.Output != kept {
+		t.Errorf("offline output = %q, want %q", offline.Output, kept)
+	}
+
+	if err := s.Clear("ring"); err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := s.Size("ring"); size != 0 {
+		t.Errorf("size after clear = %d", size)
+	}
+	if err := s.Delete("ring"); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("files left after delete: %d", len(entries))
+	}
+}
+
+func TestSegmentOwner(t *testing.T) {
+	tests := []struct {
+		file  string
+		owner string
+		ok    bool
+	}{
+		{"build.out.3", "build", true},
+		{"build.out.3.gz", "build", true},
+		{"build.out.3.gz.tmp", "", false},
+		{"a.out.b.out.12", "a.out.b", true},
+		{"build.out", "", false},
+		{"build.meta", "", false},
+		{"x.out.out", "", false},
+		{".out.1", "", false},
+	}
+	for _, tt := range tests {
+		owner, ok := segmentOwner(tt.file)
+		if owner != tt.owner || ok != tt.ok {
+			t.Errorf("segmentOwner(%q) = %q, %v; want %q, %v", tt.file, owner, ok, tt.owner, tt.ok)
+		}
+	}
+}
+
+func TestStorageReadRange(t *testing.T) {
+	var all string
+	for i := 0; i < 6; i++ {
+		all += strings.Repeat(string(rune('a'+i)), 10)
+	}
+
+	plain, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain.SetMaxOutputSize(80) // 10-byte segments
+	packed, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed.SetMaxOutputSize(80)
+	packed.SetCompression(CompressionGzip)
+
+	for name, s := range map[string]OutputStorage{"file": plain, "gzip": packed, "memory": NewMemoryStorage(1024)} {
+		t.Run(name, func(t *testing.T) {
+			if err := s.Create("range", &SessionMeta{Name: "range"}); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < len(all); i += 10 {
+				if err := s.Append("range", []byte(all[i:i+10])); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, tt := range []struct{ offset, max int64 }{
+				{0, 0}, {0, 5}, {0, 10}, {5, 10}, {8, 25}, {25, 100}, {55, 10}, {60, 10}, {70, 1},
+			} {
+				data, err := s.ReadRange("range", tt.offset, tt.max)
+				if err != nil {
+					t.Fatalf("ReadRange(%d, %d): %v", tt.offset, tt.max, err)
+				}
+				want := all[min(tt.offset, 60):]
+				if tt.max > 0 && int64(len(want)) > tt.max {
+					want = want[:tt.max]
+				}
+				if string(data) != want {
+					t.Errorf("ReadRange(%d, %d) = %q, want %q", tt.offset, tt.max, data, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// streamPoll is how often a stream reads even without a wakeup, in case
+// the session's lifecycle events were dropped.
+const streamPoll = time.Second
+
+// StreamModePeek has a stream follow the output stored from req.Offset on
+// without consuming it: read positions and cursors stay where they are.
+const StreamModePeek = "peek"
+
+// StreamChunk is one frame of a stream: output that is new since the last
+// frame, or, with End set, the end of the stream.
+type StreamChunk struct {
+	Output   string `json:"output,omitempty"`
+	Position int64  `json:"position"`
+	State    string `json:"state"`
+	End      bool   `json:"end,omitempty"`
+}
+
+// handleStream pushes a session's new output over conn until the session
+// stops or the client hangs up. Frames are Responses carrying a StreamChunk,
+// one JSON object per line. Output is consumed like a read in "new" mode (of
+// req.Cursor, if set), so the stream starts with the unread output; in
+// StreamModePeek it starts at req.Offset and nothing is consumed.
+func (s *Server) handleStream(conn net.Conn, req Request) {
+	// Output wakes the stream through the session's output hub; the event
+	// bus only tells it about the session stopping or going away.
+	sub, err := s.subscribe(req.Name, true) // session names have no glob characters
+	if err != nil {
+		s.sendResponse(conn, Response{Success: false, Error: err.Error()})
+		return
+	}
+	defer sub.Close()
+
+	var output <-chan struct{} // nil for a missing session, whose read fails
+	s.mu.Lock()
+	if h, ok := s.handles[req.Name]; ok {
+		wake, stop := h.output.listen()
+		defer stop()
+		output = wake
+	}
+	s.mu.Unlock()
+
+	// The client sends nothing after the request; its reads end when it
+	// hangs up.
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn) //nolint:errcheck // any error means the client is gone
+		close(gone)
+	}()
+
+	enc := json.NewEncoder(conn)
+	read := Request{Action: "read", Name: req.Name, Mode: ReadModeNew, Cursor: req.Cursor}
+	next := func() (StreamChunk, Response) {
+		resp := s.handleRead(read)
+		s.countRead(read, resp)
+		if !resp.Success {
+			return StreamChunk{}, resp
+		}
+		data, _ := resp.Data.(map[string]interface{})
+		chunk := StreamChunk{}
+		chunk.Output, _ = data["output"].(string)
+		chunk.Position, _ = data["position"].(int64)
+		if state, ok := data["state"].(SessionState); ok {
+			chunk.State = string(state)
+		}
+		return chunk, resp
+	}
+	if req.Mode == StreamModePeek {
+		offset := req.Offset
+		next = func() (StreamChunk, Response) { return s.peekChunk(req.Name, &offset) }
+	}
+
+	// flush sends the unread output, reporting whether the stream goes on.
+	flush := func() bool {
+		chunk, resp := next()
+		if !resp.Success {
+			enc.Encode(resp) //nolint:errcheck // the stream ends either way
+			return false
+		}
+		if chunk.Output != "" {
+			if err := enc.Encode(Response{Success: true, Data: chunk}); err != nil {
+				return false
+			}
+		}
+		if chunk.State != string(StateRunning) {
+			chunk.Output, chunk.End = "", true
+			enc.Encode(Response{Success: true, Data: chunk}) //nolint:errcheck // the stream ends either way
+			return false
+		}
+		return true
+	}
+
+	if !flush() {
+		return
+	}
+	ticker := time.NewTicker(streamPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-gone:
+			return
+		case <-output:
+		case _, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			// Take whatever else is queued, so a burst of output goes out
+			// as one frame.
+			for drained := false; !drained; {
+				select {
+				case <-sub.C:
+				default:
+					drained = true
+				}
+			}
+		case <-ticker.C:
+		}
+		if !flush() {
+			return
+		}
+	}
+}
+
+// peekChunk returns the output stored from *offset on and moves *offset past
+// it, leaving read positions alone. Output dropped from the front moves the
+// offset back to the end of what is left.
+func (s *Server) peekChunk(name string, offset *int64) (StreamChunk, Response) {
+	s.mu.Lock()
+	storage := s.storage
+	s.mu.Unlock()
+
+	meta, err := storage.LoadMeta(name)
+	if err != nil {
+		return StreamChunk{}, Response{Success: false, Error: fmt.Sprintf("load meta: %v", err)}
+	}
+	size, err := storage.Size(name)
+	if err != nil {
+		return StreamChunk{}, Response{Success: false, Error: fmt.Sprintf("get size: %v", err)}
+	}
+	*offset = min(max(*offset, 0), size)
+	data, err := storage.ReadFrom(name, *offset)
+	if err != nil {
+		return StreamChunk{}, Response{Success: false, Error: fmt.Sprintf("read output: %v", err)}
+	}
+	*offset += int64(len(data))
+	return StreamChunk{Output: string(data), Position: *offset, State: string(meta.State)}, Response{Success: true}
+}
@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("s", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("s")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := client.Send("s", "sleep 0.3; echo stream-$((40+2))", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	var output strings.Builder
+	var last StreamChunk
+	exited := false
+	err := client.Stream(ctx, "s", "", func(chunk StreamChunk) error {
+		output.WriteString(chunk.Output)
+		last = chunk
+		if !exited && strings.Contains(output.String(), "stream-42\r\n") {
+			exited = true
+			client.Send("s", "exit", true)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if !strings.Contains(output.String(), "stream-42") {
+		t.Errorf("streamed output %q lacks the command's output", output.String())
+	}
+	if !last.End || last.State != string(StateStopped) {
+		t.Errorf("last chunk = %+v, want the end of a stopped session", last)
+	}
+
+	// The stream consumed the output, as a read would.
+	rest, _, err := client.Read("s", ReadModeNew, 0, 0)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if rest != "" {
+		t.Errorf("read after stream = %q, want nothing new", rest)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("s", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("s")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	start := time.Now()
+	if err := client.Stream(ctx, "s", "", func(StreamChunk) error { return nil }); err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Stream returned %v after cancel", elapsed)
+	}
+}
+
+func TestStreamPeek(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("s", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("s")
+
+	if err := client.Send("s", "echo before-$((1+1))", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "s", "before-2")
+	offset, err := client.Size("s")
+	if err != nil {
+		t.Fatalf("size: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Send("s", "echo peek-$((40+2)); exit", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	var output strings.Builder
+	err = client.Peek(ctx, "s", int64(offset), func(chunk StreamChunk) error {
+		output.WriteString(chunk.Output)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+	if !strings.Contains(output.String(), "peek-42") || strings.Contains(output.String(), "before-2") {
+		t.Errorf("peeked output %q, want only what followed the offset", output.String())
+	}
+
+	// Nothing was consumed.
+	rest, _, err := client.Read("s", ReadModeNew, 0, 0)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(rest, "before-2") || !strings.Contains(rest, "peek-42") {
+		t.Errorf("read after peek = %q, want all the output", rest)
+	}
+}
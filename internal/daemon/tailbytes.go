@@ -0,0 +1,69 @@
+package daemon
+
+import "unicode/utf8"
+
+// TailBytesLookback is how far before a tail-bytes cut the daemon reads to
+// find an escape sequence the cut would land in.
+const TailBytesLookback = 4096
+
+// SafeTailBytes returns at most the last n bytes of data, moving the start
+// forward so it is not inside a UTF-8 character or an ANSI escape sequence.
+// Bytes of data before the last n are only looked at, never returned; pass
+// up to TailBytesLookback of them so sequences that start there are found.
+func SafeTailBytes(data []byte, n int) []byte {
+	if n <= 0 {
+		return nil
+	}
+	if n >= len(data) {
+		return data
+	}
+	start := len(data) - n
+
+	// Find the last ESC before the cut and see whether its sequence
+	// extends past it.
+	for i := start - 1; i >= 0 && i >= start-TailBytesLookback; i-- {
+		if data[i] == 0x1b {
+			if end := escapeEnd(data, i); end > start {
+				start = end
+			}
+			break
+		}
+	}
+
+	for start < len(data) && !utf8.RuneStart(data[start]) {
+		start++
+	}
+	return data[start:]
+}
+
+// escapeEnd returns the index just past the escape sequence starting at
+// data[i] (an ESC), or len(data) if it is not terminated.
+func escapeEnd(data []byte, i int) int {
+	j := i + 1
+	if j >= len(data) {
+		return len(data)
+	}
+	switch data[j] {
+	case '[': // CSI: parameters and intermediates, then a final byte
+		for j++; j < len(data); j++ {
+			if data[j] >= 0x40 && data[j] <= 0x7e {
+				return j + 1
+			}
+		}
+		return len(data)
+	case ']', 'P', '_', '^', 'X': // OSC, DCS, APC, PM, SOS: until BEL or ST
+		for j++; j < len(data); j++ {
+			if data[j] == 0x07 {
+				return j + 1
+			}
+			if data[j] == 0x1b && j+1 < len(data) && data[j+1] == '\\' {
+				return j + 2
+			}
+		}
+		return len(data)
+	case '(', ')', '*', '+', '#', '%': // charset designation and friends
+		return min(j+2, len(data))
+	default:
+		return j + 1
+	}
+}
@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSafeTailBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		n    int
+		want string
+	}{
+		{"whole buffer", "hello", 10, "hello"},
+		{"plain cut", "hello world", 5, "world"},
+		{"mid rune", "añb", 2, "b"},
+		{"mid CSI", "ab\x1b[31mred", 5, "red"},
+		{"after CSI", "ab\x1b[31mred", 3, "red"},
+		{"at ESC", "ab\x1b[31mred", 8, "\x1b[31mred"},
+		{"mid OSC", "x\x1b]0;title\x07tail", 8, "tail"},
+		{"mid OSC with ST", "x\x1b]8;;http://a\x1b\\link", 10, "link"},
+		{"unterminated", "ok\x1b[12", 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(SafeTailBytes([]byte(tt.data), tt.n)); got != tt.want {
+				t.Errorf("SafeTailBytes(%q, %d) = %q, want %q", tt.data, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTailBytes(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("tailb", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("tailb")
+
+	if err := client.Send("tailb", "echo tail-bytes-marker", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "tailb", "\ntail-bytes-marker")
+
+	all, _, err := client.Read("tailb", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	output, pos, err := client.ReadTailBytes("tailb", 30)
+	if err != nil {
+		t.Fatalf("tail bytes: %v", err)
+	}
+	if len(output) > 30 || !strings.HasSuffix(all, output) {
+		t.Errorf("tail = %q, want a suffix of %q of at most 30 bytes", output, all)
+	}
+	if pos != len(all) {
+		t.Errorf("position = %d, want %d", pos, len(all))
+	}
+
+	if _, _, err := client.ReadTailBytes("tailb", 0); err == nil {
+		t.Error("expected error for zero limit")
+	}
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ClientIDEnv names the caller in requests, for per-client rate limits.
+// Unset, a client is named after its process, so each CLI invocation is a
+// client of its own while a long-running MCP server is one client.
+const ClientIDEnv = "SHELLI_CLIENT"
+
+// CodeRateLimited is the Response.Code of a request refused by a rate limit;
+// Response.RetryAfter says when to try again.
+const CodeRateLimited = "rate_limited"
+
+// RateLimitMaxWait is how long a Client waits out rate limits for one
+// request, following the daemon's retry-after hints, before returning a
+// RateLimitError. Polling loops (exec, read --wait) are slowed, not failed.
+const RateLimitMaxWait = 2 * time.Second
+
+// RateLimitError is a request the daemon kept refusing under a rate limit.
+type RateLimitError struct {
+	Action     string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s (retry after %.2fs)", e.Message, e.RetryAfter.Seconds())
+}
+
+// defaultClientID is the Request.Client a Client sends.
+func defaultClientID() string {
+	if id := os.Getenv(ClientIDEnv); id != "" {
+		return id
+	}
+	return fmt.Sprintf("pid-%d", os.Getpid())
+}
+
+var ratePattern = regexp.MustCompile(`^(\d+)\s*/\s*(s|sec|m|min)$`)
+
+// ParseRate parses a rate limit such as "20/s" or "600/m" into requests per
+// second and a burst: N requests may come at once, and the allowance refills
+// over the unit.
+func ParseRate(s string) (perSec float64, burst int, err error) {
+	m := ratePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, fmt.Errorf("invalid rate %q: want N/s or N/m", s)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate %q: N must be positive", s)
+	}
+	unit := 1.0
+	if m[2] == "m" || m[2] == "min" {
+		unit = 60
+	}
+	return float64(n) / unit, n, nil
+}
+
+// rateLimiter is a token bucket per key. A zero rate allows everything.
+type rateLimiter struct {
+	mu      sync.Mutex
+	perSec  float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	at     time.Duration // monoNow of the last refill
+}
+
+// rateLimiterPrune is how many buckets a limiter holds before it drops the
+// full ones, whose keys have been quiet long enough to refill.
+const rateLimiterPrune = 1024
+
+// set changes the limit, reporting whether it changed. Buckets start over.
+func (l *rateLimiter) set(perSec float64, burst int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perSec == perSec && l.burst == float64(burst) {
+		return false
+	}
+	l.perSec, l.burst = perSec, float64(burst)
+	l.buckets = nil
+	return true
+}
+
+// allow takes a token from key's bucket, or reports how long until one is
+// available.
+func (l *rateLimiter) allow(key string, now time.Duration) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perSec == 0 {
+		return true, 0
+	}
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	b := l.buckets[key]
+	if b == nil {
+		if len(l.buckets) >= rateLimiterPrune {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: l.burst, at: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+(now-b.at).Seconds()*l.perSec)
+	b.at = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.perSec * float64(time.Second))
+	return false, wait
+}
+
+func (l *rateLimiter) prune(now time.Duration) {
+	for key, b := range l.buckets {
+		if b.tokens+(now-b.at).Seconds()*l.perSec >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// unthrottledActions are never rate limited: ping is how clients tell
+// whether the daemon is up, and metrics is how it is watched.
+var unthrottledActions = map[string]bool{
+	"ping":    true,
+	"metrics": true,
+}
+
+// throttle checks req against the per-client and per-session rate limits,
+// returning the refusal when one is exceeded.
+func (s *Server) throttle(req Request) (Response, bool) {
+	if unthrottledActions[req.Action] {
+		return Response{}, false
+	}
+	now := monoNow()
+	if req.Client != "" {
+		if ok, wait := s.clientLimit.allow(req.Client, now); !ok {
+			s.metrics.countThrottled("client")
+			return rateLimited(fmt.Sprintf("rate limited: client %q is over the daemon's client rate limit", req.Client), wait), true
+		}
+	}
+	if req.Name != "" {
+		if ok, wait := s.sessionLimit.allow(req.Name, now); !ok {
+			s.metrics.countThrottled("session")
+			return rateLimited(fmt.Sprintf("rate limited: session %q is over the daemon's session rate limit", req.Name), wait), true
+		}
+	}
+	return Response{}, false
+}
+
+func rateLimited(msg string, retryAfter time.Duration) Response {
+	return Response{Success: false, Error: msg, Code: CodeRateLimited, RetryAfter: retryAfter.Seconds()}
+}
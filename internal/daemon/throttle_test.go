@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in     string
+		perSec float64
+		burst  int
+		ok     bool
+	}{
+		{"20/s", 20, 20, true},
+		{"600/m", 10, 600, true},
+		{"5 / min", 5.0 / 60, 5, true},
+		{"0/s", 0, 0, false},
+		{"20", 0, 0, false},
+		{"20/h", 0, 0, false},
+	}
+	for _, tt := range tests {
+		perSec, burst, err := ParseRate(tt.in)
+		if (err == nil) != tt.ok || perSec != tt.perSec || burst != tt.burst {
+			t.Errorf("ParseRate(%q) = %v, %d, %v", tt.in, perSec, burst, err)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	var l rateLimiter
+	if ok, _ := l.allow("a", 0); !ok {
+		t.Fatal("an unset limiter should allow everything")
+	}
+
+	l.set(2, 2)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a", 0); !ok {
+			t.Fatalf("request %d within the burst was refused", i)
+		}
+	}
+	ok, wait := l.allow("a", 0)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("over the burst: ok=%v wait=%v, want refused for 500ms", ok, wait)
+	}
+	if ok, _ := l.allow("b", 0); !ok {
+		t.Error("keys should have their own buckets")
+	}
+	if ok, _ := l.allow("a", 500*time.Millisecond); !ok {
+		t.Error("the bucket should refill over time")
+	}
+
+	if l.set(2, 2) {
+		t.Error("setting the same limit should report no change")
+	}
+}
+
+func TestSessionRateLimit(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "none.json")
+	client, cleanup := setupTestServer(t, WithConfig(config, Config{SessionRateLimit: "2/s"}))
+	defer cleanup()
+
+	if _, err := client.Create("limited", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("limited")
+
+	// The create took one token; the client waits out the rest.
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, _, err := client.Read("limited", ReadModeAll, 0, 0); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("reads took %v, want them slowed to the limit", elapsed)
+	}
+
+	resp, _, err := client.roundTrip(Request{Action: "info", Name: "limited"})
+	if err != nil {
+		t.Fatalf("roundTrip: %v", err)
+	}
+	if resp.Success || resp.Code != CodeRateLimited || resp.RetryAfter <= 0 || !strings.Contains(resp.Error, "limited") {
+		t.Errorf("over the limit: %+v", resp)
+	}
+	if !client.Ping() {
+		t.Error("ping should not be rate limited")
+	}
+	text, err := client.Metrics()
+	if err != nil {
+		t.Fatalf("Metrics: %v", err)
+	}
+	if strings.Contains(text, `shelli_rate_limited_total{limit="session"} 0`) {
+		t.Error("metrics should count refused requests")
+	}
+}
+
+func TestClientRateLimitError(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "none.json")
+	client, cleanup := setupTestServer(t, WithConfig(config, Config{ClientRateLimit: "2/m"}))
+	defer cleanup()
+
+	t.Setenv(ClientIDEnv, "agent-1")
+	for i := 0; i < 2; i++ {
+		if _, err := client.List(); err != nil {
+			t.Fatalf("list %d: %v", i, err)
+		}
+	}
+	_, err := client.List()
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.RetryAfter < 20*time.Second {
+		t.Fatalf("list over the limit = %v, want a RateLimitError with a long retry-after", err)
+	}
+
+	t.Setenv(ClientIDEnv, "agent-2")
+	if _, err := client.List(); err != nil {
+		t.Errorf("another client should have its own limit: %v", err)
+	}
+}
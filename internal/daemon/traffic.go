@@ -0,0 +1,75 @@
+package daemon
+
+import "sync/atomic"
+
+// ReadStat counts read calls made by one reader and the bytes they returned.
+type ReadStat struct {
+	Calls int64 `json:"calls"`
+	Bytes int64 `json:"bytes"`
+}
+
+// sessionTraffic tracks how much a session moves: bytes read from and
+// written to its PTY, and what each reader pulled out of the daemon. It is
+// kept in memory only and starts from zero when the daemon restarts.
+type sessionTraffic struct {
+	ptyIn  atomic.Int64
+	ptyOut atomic.Int64
+
+	// reads is keyed by cursor name, "" for the default read position.
+	// Guarded by Server.mu.
+	reads map[string]*ReadStat
+
+	// truncations totals the truncations reported to readers. Guarded by
+	// Server.mu.
+	truncations int64
+}
+
+// countRead records a read response against the reader that made it.
+func (s *Server) countRead(req Request, resp Response) {
+	if !resp.Success {
+		return
+	}
+	data, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	output, _ := data["output"].(string)
+	truncations, _ := data["truncations_since_last_read"].(int64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, exists := s.handles[req.Name]
+	if !exists {
+		return
+	}
+	if h.traffic.reads == nil {
+		h.traffic.reads = make(map[string]*ReadStat)
+	}
+	stat := h.traffic.reads[req.Cursor]
+	if stat == nil {
+		stat = &ReadStat{}
+		h.traffic.reads[req.Cursor] = stat
+	}
+	stat.Calls++
+	stat.Bytes += int64(len(output))
+	h.traffic.truncations += truncations
+}
+
+// trafficInfo adds the traffic counters to an info result. Callers hold
+// Server.mu.
+func (h *sessionHandle) trafficInfo(result map[string]interface{}) {
+	result["pty_bytes_in"] = h.traffic.ptyIn.Load()
+	result["pty_bytes_out"] = h.traffic.ptyOut.Load()
+	if stat := h.traffic.reads[""]; stat != nil {
+		result["reads"] = *stat
+	}
+	cursors := make(map[string]ReadStat)
+	for name, stat := range h.traffic.reads {
+		if name != "" {
+			cursors[name] = *stat
+		}
+	}
+	if len(cursors) > 0 {
+		result["cursor_reads"] = cursors
+	}
+}
@@ -0,0 +1,41 @@
+package daemon
+
+import "testing"
+
+func TestInfoTraffic(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("traffic", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("traffic")
+
+	if err := client.Send("traffic", "echo counted", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "traffic", "counted\r\n")
+
+	output, _, err := client.ReadWithCursor("traffic", ReadModeNew, "agent", 0, 0)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	info, err := client.Info("traffic")
+	if err != nil {
+		t.Fatalf("info: %v", err)
+	}
+	if info.PTYBytesOut != int64(len("echo counted\n")) {
+		t.Errorf("pty_bytes_out = %d, want %d", info.PTYBytesOut, len("echo counted\n"))
+	}
+	if info.PTYBytesIn < int64(len("counted\r\n")) {
+		t.Errorf("pty_bytes_in = %d, too small", info.PTYBytesIn)
+	}
+	if info.Reads.Calls == 0 {
+		t.Error("default reads should include waitForOutput polling")
+	}
+	agent := info.CursorReads["agent"]
+	if agent.Calls != 1 || agent.Bytes != int64(len(output)) {
+		t.Errorf("cursor_reads[agent] = %+v, want 1 call, %d bytes", agent, len(output))
+	}
+}
@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"testing"
+)
+
+func TestTruncationsAfterClear(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("trunc", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer client.Kill("trunc")
+
+	if err := client.Send("trunc", "echo before-clear", true); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitForOutput(t, client, "trunc", "before-clear")
+	if _, err := client.ReadDetailed("trunc", ReadModeNew, "agent", 0, 0); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if err := client.Clear("trunc"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+
+	for _, cursor := range []string{"", "agent"} {
+		result, err := client.ReadDetailed("trunc", ReadModeNew, cursor, 0, 0)
+		if err != nil {
+			t.Fatalf("read %q: %v", cursor, err)
+		}
+		if result.Truncations != 1 {
+			t.Errorf("reader %q: truncations = %d, want 1", cursor, result.Truncations)
+		}
+
+		again, err := client.ReadDetailed("trunc", ReadModeNew, cursor, 0, 0)
+		if err != nil {
+			t.Fatalf("read %q again: %v", cursor, err)
+		}
+		if again.Truncations != 0 {
+			t.Errorf("reader %q: second read truncations = %d, want 0", cursor, again.Truncations)
+		}
+	}
+}
+
+func TestMemoryStorageCountsWrapTruncations(t *testing.T) {
+	s := NewMemoryStorage(10)
+	if err := s.Create("s", &SessionMeta{Name: "s"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	s.UpdateMeta("s", func(m *SessionMeta) {
+		m.ReadPos = 2
+		m.Cursors = map[string]int64{"behind": 1, "ahead": 8}
+	})
+
+	if err := s.Append("s", []byte("0123456789abcd")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	meta, err := s.LoadMeta("s")
+	if err != nil {
+		t.Fatalf("load meta: %v", err)
+	}
+	want := map[string]int64{"": 1, "behind": 1}
+	if len(meta.Truncations) != len(want) {
+		t.Fatalf("truncations = %v, want %v", meta.Truncations, want)
+	}
+	for k, v := range want {
+		if meta.Truncations[k] != v {
+			t.Errorf("truncations[%q] = %d, want %d", k, meta.Truncations[k], v)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"os"
+	"strings"
+)
+
+// SessionUsage is one session's entry in a UsageReport.
+type SessionUsage struct {
+	Name        string  `json:"name"`
+	State       string  `json:"state"`
+	CreatedAt   string  `json:"created_at"`
+	StoppedAt   string  `json:"stopped_at,omitempty"`
+	AgeSec      float64 `json:"age_sec"` // since stop for stopped sessions, since creation otherwise
+	StoredBytes int64   `json:"stored_bytes"`
+	DiskBytes   int64   `json:"disk_bytes"`
+	MemoryBytes int64   `json:"memory_bytes"`
+	Spilled     bool    `json:"spilled,omitempty"` // memory backend: output moved to disk by the memory budget
+}
+
+// OrphanFile is a file in the data dir that belongs to no known session,
+// e.g. output left behind by a crash between writing the two session files.
+type OrphanFile struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+// UsageReport lists what each session costs in storage, oldest first.
+type UsageReport struct {
+	Backend    string         `json:"backend"` // "file" or "memory"
+	DataDir    string         `json:"data_dir,omitempty"`
+	Sessions   []SessionUsage `json:"sessions"`
+	Orphans    []OrphanFile   `json:"orphans,omitempty"`
+	TotalBytes int64          `json:"total_bytes"`
+	Memory     *MemoryUsage   `json:"memory,omitempty"` // memory backend
+}
+
+// sessionDiskUsage returns the on-disk size of a session's files, sealed
+// output segments included.
+func (s *FileStorage) sessionDiskUsage(session string) int64 {
+	var total int64
+	for _, seg := range scanSegments(s.dataDir, session) {
+		total += seg.disk
+	}
+	for _, path := range []string{s.outputPath(session), s.metaPath(session), s.timesPath(session)} {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// orphanFiles returns files in the data dir not owned by a session in known.
+func (s *FileStorage) orphanFiles(known map[string]bool) []OrphanFile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(s.dataDir)
+	if err != nil {
+		return nil
+	}
+	var orphans []OrphanFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		session := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, ".out"), ".meta"), ".times")
+		if owner, ok := segmentOwner(name); ok {
+			session = owner
+		}
+		if session != name && known[session] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		orphans = append(orphans, OrphanFile{Name: name, Bytes: info.Size()})
+	}
+	return orphans
+}
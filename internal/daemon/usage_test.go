@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskUsageMemory(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("du", CreateOptions{Command: "sh"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("du")
+	if err := client.Send("du", "echo usage-marker", true); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitForOutput(t, client, "du", "usage-marker")
+
+	report, err := client.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage: %v", err)
+	}
+	if report.Backend != "memory" || report.DataDir != "" {
+		t.Errorf("backend = %q, data dir = %q", report.Backend, report.DataDir)
+	}
+	if len(report.Sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(report.Sessions))
+	}
+	s := report.Sessions[0]
+	if s.Name != "du" || s.State != string(StateRunning) {
+		t.Errorf("session = %+v", s)
+	}
+	if s.StoredBytes == 0 || s.MemoryBytes != s.StoredBytes || s.DiskBytes != 0 {
+		t.Errorf("sizes = stored %d, memory %d, disk %d", s.StoredBytes, s.MemoryBytes, s.DiskBytes)
+	}
+	if report.TotalBytes != s.MemoryBytes {
+		t.Errorf("total = %d, want %d", report.TotalBytes, s.MemoryBytes)
+	}
+}
+
+func TestFileStorageUsage(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Create("kept", &SessionMeta{Name: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Append("kept", []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lost.out"), []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	metaInfo, err := os.Stat(storage.metaPath("kept"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timesInfo, err := os.Stat(storage.timesPath("kept"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storage.sessionDiskUsage("kept"), 10+metaInfo.Size()+timesInfo.Size(); got != want {
+		t.Errorf("disk usage = %d, want %d", got, want)
+	}
+
+	orphans := storage.orphanFiles(map[string]bool{"kept": true})
+	if len(orphans) != 1 || orphans[0].Name != "lost.out" || orphans[0].Bytes != 3 {
+		t.Errorf("orphans = %+v", orphans)
+	}
+}
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// waitAnyCarry is how much already-scanned output is kept in front of new
+// output, so a match split across two chunks is still found.
+const waitAnyCarry = 4096
+
+// WaitAnyResult is the answer to a wait_any call. Without a match, Positions
+// says how far each session was scanned; passing them to the next call
+// resumes the wait without rescanning or missing output.
+type WaitAnyResult struct {
+	Matched   bool             `json:"matched"`
+	Session   string           `json:"session,omitempty"`
+	Match     string           `json:"match,omitempty"`
+	Position  int64            `json:"position,omitempty"` // buffer offset just past the match
+	Positions map[string]int64 `json:"positions"`
+}
+
+// anyScanner looks for a pattern in each session's output from a start
+// position on.
+type anyScanner struct {
+	storage OutputStorage
+	re      *regexp.Regexp
+	pos     map[string]int64
+	carry   map[string][]byte
+}
+
+// scan reads a session's output past its position. It reports the first
+// match, as the matched text and the buffer offset just past it.
+func (a *anyScanner) scan(name string) (string, int64, bool) {
+	size, err := a.storage.Size(name)
+	if err != nil {
+		return "", 0, false
+	}
+	pos := a.pos[name]
+	if pos > size { // cleared
+		pos = 0
+		delete(a.carry, name)
+	}
+	if pos == size {
+		a.pos[name] = pos
+		return "", 0, false
+	}
+	data, err := a.storage.ReadFrom(name, pos)
+	if err != nil {
+		return "", 0, false
+	}
+
+	carry := a.carry[name]
+	text := append(append([]byte(nil), carry...), data...)
+	base := pos - int64(len(carry))
+	a.pos[name] = pos + int64(len(data))
+	// Mirrored input is not the program's output; match around it.
+	printed, offset := withoutInputMirror(text)
+	if loc := a.re.FindIndex(printed); loc != nil {
+		return string(printed[loc[0]:loc[1]]), base + int64(offset(loc[1])), true
+	}
+	a.carry[name] = text[max(0, len(text)-waitAnyCarry):]
+	return "", 0, false
+}
+
+// handleWaitAny waits until the output of any session matching req.Filter
+// (a path.Match pattern; empty for all) matches req.Pattern. Output after
+// each session's read position is considered, or after req.Positions when
+// resuming; TUI sessions are not watched. The wait is capped below the
+// client deadline; the client calls again with the returned positions to
+// wait longer.
+func (s *Server) handleWaitAny(req Request) Response {
+	if req.Pattern == "" {
+		return Response{Success: false, Error: "pattern is required"}
+	}
+	expr := req.Pattern
+	if req.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("invalid pattern: %v", err)}
+	}
+
+	// Subscribe before the first scan so no output falls in between.
+	sub, err := s.Subscribe(req.Filter)
+	if err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("invalid filter: %v", err)}
+	}
+	defer sub.Close()
+
+	s.mu.Lock()
+	var names []string
+	for name, h := range s.handles {
+		if h.screen != nil {
+			continue // TUI output goes to the screen, not the buffer
+		}
+		if ok, _ := path.Match(req.Filter, name); req.Filter == "" || ok {
+			names = append(names, name)
+		}
+	}
+	storage := s.storage
+	s.mu.Unlock()
+	sort.Strings(names)
+
+	if len(names) == 0 && len(req.Positions) == 0 {
+		if req.Filter != "" {
+			return Response{Success: false, Error: fmt.Sprintf("no sessions match %q", req.Filter)}
+		}
+		return Response{Success: false, Error: "no sessions"}
+	}
+
+	scanner := &anyScanner{storage: storage, re: re, pos: make(map[string]int64), carry: make(map[string][]byte)}
+	for _, name := range names {
+		if pos, ok := req.Positions[name]; ok {
+			scanner.pos[name] = pos
+		} else if meta, err := storage.LoadMeta(name); err == nil {
+			scanner.pos[name] = meta.ReadPos
+		}
+	}
+	matched := func(name, match string, position int64) Response {
+		s.metrics.countWait("wait_any", ExecCompleted)
+		return Response{Success: true, Data: WaitAnyResult{
+			Matched:   true,
+			Session:   name,
+			Match:     match,
+			Position:  position,
+			Positions: scanner.pos,
+		}}
+	}
+
+	for _, name := range names {
+		if match, position, ok := scanner.scan(name); ok {
+			return matched(name, match, position)
+		}
+	}
+
+	timeout := time.Duration(req.TimeoutSec) * time.Second
+	if maxTimeout := ClientDeadline - 5*time.Second; timeout <= 0 || timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	dropped := sub.Dropped()
+	for {
+		select {
+		case e := <-sub.C:
+			if _, ok := e.(OutputChunk); !ok {
+				continue
+			}
+			// Events only wake the scan; when some were dropped, every
+			// session may have unseen output.
+			scan := []string{e.SessionName()}
+			if d := sub.Dropped(); d != dropped {
+				dropped = d
+				scan = scan[:0]
+				for name := range scanner.pos {
+					scan = append(scan, name)
+				}
+				sort.Strings(scan)
+			}
+			for _, name := range scan {
+				if match, position, ok := scanner.scan(name); ok {
+					return matched(name, match, position)
+				}
+			}
+		case <-timer.C:
+			s.metrics.countWait("wait_any", ExecTimedOut)
+			return Response{Success: true, Data: WaitAnyResult{Positions: scanner.pos}}
+		}
+	}
+}
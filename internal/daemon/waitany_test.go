@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/schovi/shelli/internal/wait"
+)
+
+func TestAnyScannerSplitMatch(t *testing.T) {
+	storage := NewMemoryStorage(0)
+	storage.Create("s", &SessionMeta{Name: "s"})
+	scanner := &anyScanner{
+		storage: storage,
+		re:      regexp.MustCompile(`FAIL: \w+`),
+		pos:     map[string]int64{},
+		carry:   map[string][]byte{},
+	}
+
+	storage.Append("s", []byte("ok 1\nFA"))
+	if _, _, ok := scanner.scan("s"); ok {
+		t.Fatal("matched a partial line")
+	}
+	storage.Append("s", []byte("IL: TestX\n"))
+	match, position, ok := scanner.scan("s")
+	if !ok || match != "FAIL: TestX" || position != int64(len("ok 1\nFAIL: TestX")) {
+		t.Errorf("scan = %q, %d, %v", match, position, ok)
+	}
+}
+
+func TestWaitAny(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	for _, name := range []string{"job-1", "job-2", "other"} {
+		if _, err := client.Create(name, CreateOptions{Command: "sh"}); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		defer client.Kill(name)
+	}
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		client.Send("other", "echo wait-any-$((40+2))", true)
+		time.Sleep(200 * time.Millisecond)
+		client.Send("job-2", "echo wait-any-$((40+2))", true)
+	}()
+
+	result, err := client.WaitAny("job-*", `wait-any-42`, false, 10)
+	if err != nil {
+		t.Fatalf("WaitAny: %v", err)
+	}
+	if result.Session != "job-2" || result.Match != "wait-any-42" {
+		t.Errorf("result = %+v, want a match in job-2", result)
+	}
+	output, _, err := client.Read("job-2", ReadModeAll, 0, 0)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := output[:result.Position]; got[len(got)-len("wait-any-42"):] != "wait-any-42" {
+		t.Errorf("position %d does not end the match: %q", result.Position, got)
+	}
+
+	if _, err := client.WaitAny("job-*", `never-printed`, false, 1); !errors.Is(err, wait.ErrTimeout) {
+		t.Errorf("expected timeout, got %v", err)
+	}
+	if _, err := client.WaitAny("nothing-*", `x`, false, 1); err == nil {
+		t.Error("expected error for a filter matching no session")
+	}
+}
@@ -0,0 +1,61 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// WorkspaceDaemonEnv, when set to "1", gives every workspace its own daemon:
+// the socket and data dir move to a per-repo subdirectory of the runtime dir,
+// so agents working in different projects never see each other's sessions.
+const WorkspaceDaemonEnv = "SHELLI_WORKSPACE_DAEMON"
+
+// FindWorkspace returns the root of the git repository containing dir (the
+// nearest directory with a .git entry; worktrees have a .git file), or "".
+func FindWorkspace(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// CurrentWorkspace returns the workspace of the working directory, or "".
+func CurrentWorkspace() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return FindWorkspace(wd)
+}
+
+// FilterWorkspace returns the sessions tagged with workspace.
+func FilterWorkspace(sessions []SessionInfo, workspace string) []SessionInfo {
+	filtered := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		if s.Workspace == workspace {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// workspaceDir names the runtime subdirectory of a per-workspace daemon.
+func workspaceDir(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return "ws-" + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindWorkspace(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := filepath.Join(root, "repo")
+	nested := filepath.Join(repo, "a", "b")
+	if err := os.MkdirAll(nested, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	worktree := filepath.Join(root, "wt")
+	if err := os.Mkdir(worktree, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: elsewhere\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{repo, repo},
+		{nested, repo},
+		{worktree, worktree},
+		{root, ""},
+	}
+	for _, tt := range tests {
+		if got := FindWorkspace(tt.dir); got != tt.want {
+			t.Errorf("FindWorkspace(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestRuntimeDirPerWorkspace(t *testing.T) {
+	repo, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+
+	t.Setenv(WorkspaceDaemonEnv, "")
+	shared, _ := RuntimeDir()
+
+	t.Setenv(WorkspaceDaemonEnv, "1")
+	isolated, _ := RuntimeDir()
+	if filepath.Dir(isolated) != shared || !strings.HasPrefix(filepath.Base(isolated), "ws-") {
+		t.Errorf("per-workspace runtime dir = %q, want ws-* under %q", isolated, shared)
+	}
+}
+
+func TestListWorkspace(t *testing.T) {
+	client, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := client.Create("tagged", CreateOptions{Command: "sleep 5", Workspace: "/src/proj"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer client.Kill("tagged")
+
+	sessions, err := client.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Workspace != "/src/proj" {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+	if got := FilterWorkspace(sessions, "/src/other"); len(got) != 0 {
+		t.Errorf("filter other workspace = %+v", got)
+	}
+
+	info, err := client.Info("tagged")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.Workspace != "/src/proj" {
+		t.Errorf("info workspace = %q", info.Workspace)
+	}
+}
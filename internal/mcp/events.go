@@ -0,0 +1,72 @@
+package mcp
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/schovi/shelli/internal/daemon"
+)
+
+// eventsRetry is how long the event bridge waits before reconnecting to a
+// daemon that is not running (yet).
+const eventsRetry = 2 * time.Second
+
+// logLevels are the MCP logging levels, least severe first.
+var logLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// Notification is a JSON-RPC notification: a message without an ID that
+// expects no response.
+type Notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// LogMessage is the params of a notifications/message notification.
+type LogMessage struct {
+	Level  string      `json:"level"`
+	Logger string      `json:"logger,omitempty"`
+	Data   interface{} `json:"data"`
+}
+
+// eventLevel is the log level a lifecycle event is sent at: warning for a
+// process that failed or events lost, info otherwise.
+func eventLevel(ev daemon.LifecycleEvent) string {
+	switch {
+	case ev.Type == daemon.EventDropped:
+		return "warning"
+	case ev.Type == daemon.EventExited && (ev.Signal != "" || ev.ExitCode == nil || *ev.ExitCode != 0):
+		return "warning"
+	}
+	return "info"
+}
+
+// bridgeEvents forwards the daemon's session lifecycle events to the MCP
+// client as notifications/message log messages (logger "shelli") until
+// ctx is done, reconnecting whenever the daemon is not running, so agents
+// learn about sessions dying without polling.
+func (s *Server) bridgeEvents(ctx context.Context, client *daemon.Client) {
+	for ctx.Err() == nil {
+		client.Events(ctx, "", nil, func(ev daemon.LifecycleEvent) error { //nolint:errcheck // reconnected below
+			level := eventLevel(ev)
+			if !s.logEnabled(level) {
+				return nil
+			}
+			s.sendNotification("notifications/message", LogMessage{Level: level, Logger: "shelli", Data: ev})
+			return nil
+		})
+		select {
+		case <-ctx.Done():
+		case <-time.After(eventsRetry):
+		}
+	}
+}
+
+// logEnabled reports whether messages at level pass the level the client
+// set with logging/setLevel.
+func (s *Server) logEnabled(level string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Index(logLevels, level) >= slices.Index(logLevels, s.logLevel)
+}
@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/schovi/shelli/internal/daemon"
+)
+
+func TestEventLevel(t *testing.T) {
+	code := func(n int) *int { return &n }
+	for _, tc := range []struct {
+		ev   daemon.LifecycleEvent
+		want string
+	}{
+		{daemon.LifecycleEvent{Type: daemon.EventCreated}, "info"},
+		{daemon.LifecycleEvent{Type: daemon.EventExited, ExitCode: code(0)}, "info"},
+		{daemon.LifecycleEvent{Type: daemon.EventExited, ExitCode: code(2)}, "warning"},
+		{daemon.LifecycleEvent{Type: daemon.EventExited, ExitCode: code(137), Signal: "killed"}, "warning"},
+		{daemon.LifecycleEvent{Type: daemon.EventDropped, Count: 3}, "warning"},
+	} {
+		if got := eventLevel(tc.ev); got != tc.want {
+			t.Errorf("eventLevel(%+v) = %s, want %s", tc.ev, got, tc.want)
+		}
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	var out bytes.Buffer
+	s := &Server{writer: &out, logLevel: "info"}
+
+	s.handleRequest(&Request{JSONRPC: "2.0", ID: 1, Method: "logging/setLevel", Params: json.RawMessage(`{"level":"verbose"}`)})
+	if !strings.Contains(out.String(), `"error"`) {
+		t.Errorf("an unknown level should be refused: %s", out.String())
+	}
+	out.Reset()
+
+	s.handleRequest(&Request{JSONRPC: "2.0", ID: 2, Method: "logging/setLevel", Params: json.RawMessage(`{"level":"warning"}`)})
+	if strings.Contains(out.String(), `"error"`) {
+		t.Fatalf("setLevel failed: %s", out.String())
+	}
+	if s.logEnabled("info") || !s.logEnabled("warning") || !s.logEnabled("error") {
+		t.Error("only warnings and above should pass after setLevel warning")
+	}
+	out.Reset()
+
+	s.sendNotification("notifications/message", LogMessage{Level: "warning", Logger: "shelli", Data: daemon.LifecycleEvent{Type: daemon.EventKilled, Session: "build"}})
+	var n struct {
+		Method string `json:"method"`
+		Params struct {
+			Level string                `json:"level"`
+			Data  daemon.LifecycleEvent `json:"data"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &n); err != nil {
+		t.Fatalf("notification %q: %v", out.String(), err)
+	}
+	if n.Method != "notifications/message" || n.Params.Level != "warning" || n.Params.Data.Session != "build" {
+		t.Errorf("notification = %+v", n)
+	}
+}
@@ -0,0 +1,341 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/schovi/shelli/internal/daemon"
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// PluginPathEnv overrides the plugin directories: a list separated by the
+// OS path list separator. Later directories win on name clashes.
+const PluginPathEnv = "SHELLI_PLUGIN_PATH"
+
+// PluginManifest defines an MCP tool as a canned exec in a session. Session
+// and Input may reference parameters as {{param}}. Values in Input are
+// shell-quoted, so each placeholder is one word to the shell and must not
+// be quoted again in the template.
+type PluginManifest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Session     string                 `json:"session"`
+	Create      *PluginCreate          `json:"create,omitempty"`
+	Input       string                 `json:"input"`
+	WaitPattern string                 `json:"wait_pattern,omitempty"`
+	SettleMs    *int                   `json:"settle_ms,omitempty"`
+	TimeoutSec  int                    `json:"timeout_sec,omitempty"`
+	StripAnsi   bool                   `json:"strip_ansi,omitempty"`
+	Probe       bool                   `json:"probe,omitempty"`
+	Params      map[string]PluginParam `json:"params,omitempty"`
+	// Parse maps result field names to regexes matched against the
+	// ANSI-stripped output. The last match wins; its first capture group
+	// (or the whole match) becomes the value.
+	Parse map[string]string `json:"parse,omitempty"`
+
+	parse map[string]*regexp.Regexp
+}
+
+// PluginCreate starts the tool's session if it does not exist yet.
+type PluginCreate struct {
+	Command string   `json:"command,omitempty"`
+	Cwd     string   `json:"cwd,omitempty"`
+	Env     []string `json:"env,omitempty"`
+}
+
+// PluginParam is one tool argument.
+type PluginParam struct {
+	Type        string      `json:"type,omitempty"` // string (default), integer, number or boolean
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+}
+
+var (
+	pluginPlaceholder = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+	pluginToolName    = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	pluginParamTypes  = []string{"string", "integer", "number", "boolean"}
+	shellSafeWord     = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+)
+
+// PluginDirs returns the directories plugin manifests are loaded from: the
+// user's config dir (~/.config/shelli/tools), or the SHELLI_PLUGIN_PATH list
+// when set. Manifests in a repository run whatever commands the repository
+// chose, so .shelli/tools in the current git repository is only added when
+// project is set (shelli daemon --mcp --project-tools).
+func PluginDirs(project bool) []string {
+	if path := os.Getenv(PluginPathEnv); path != "" {
+		return filepath.SplitList(path)
+	}
+	var dirs []string
+	if config, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(config, "shelli", "tools"))
+	}
+	if !project {
+		return dirs
+	}
+	if workspace := daemon.CurrentWorkspace(); workspace != "" {
+		dirs = append(dirs, filepath.Join(workspace, ".shelli", "tools"))
+	}
+	return dirs
+}
+
+// LoadPlugins reads every *.json manifest in dirs. A missing directory is
+// not an error; an invalid manifest is reported and skipped. When two
+// manifests share a name, the later directory wins.
+func LoadPlugins(dirs []string) ([]*PluginManifest, []error) {
+	byName := map[string]*PluginManifest{}
+	var order []string
+	var errs []error
+	for _, dir := range dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			m, err := loadPlugin(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if _, seen := byName[m.Name]; !seen {
+				order = append(order, m.Name)
+			}
+			byName[m.Name] = m
+		}
+	}
+
+	plugins := make([]*PluginManifest, 0, len(order))
+	for _, name := range order {
+		plugins = append(plugins, byName[name])
+	}
+	return plugins, errs
+}
+
+func loadPlugin(path string) (*PluginManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	var m PluginManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+func (m *PluginManifest) validate() error {
+	if !pluginToolName.MatchString(m.Name) {
+		return fmt.Errorf("name %q must be 1-64 letters, digits, '_' or '-'", m.Name)
+	}
+	if m.Session == "" || m.Input == "" {
+		return fmt.Errorf("session and input are required")
+	}
+	if m.WaitPattern != "" && m.SettleMs != nil && *m.SettleMs > 0 {
+		return fmt.Errorf("wait_pattern and settle_ms are mutually exclusive")
+	}
+	if m.WaitPattern != "" {
+		if _, err := regexp.Compile(m.WaitPattern); err != nil {
+			return fmt.Errorf("wait_pattern: %w", err)
+		}
+	}
+	for name, p := range m.Params {
+		if p.Type != "" && !slices.Contains(pluginParamTypes, p.Type) {
+			return fmt.Errorf("param %q: unknown type %q", name, p.Type)
+		}
+	}
+	for _, tmpl := range []string{m.Session, m.Input} {
+		for _, match := range pluginPlaceholder.FindAllStringSubmatch(tmpl, -1) {
+			if _, ok := m.Params[match[1]]; !ok {
+				return fmt.Errorf("template references undefined param %q", match[1])
+			}
+		}
+	}
+	m.parse = make(map[string]*regexp.Regexp, len(m.Parse))
+	for field, pattern := range m.Parse {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("parse %q: %w", field, err)
+		}
+		m.parse[field] = re
+	}
+	return nil
+}
+
+// schema returns the tool's JSON input schema.
+func (m *PluginManifest) schema() map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []string
+	for name, p := range m.Params {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		prop := map[string]interface{}{"type": typ}
+		if p.Description != "" {
+			prop["description"] = p.Description
+		}
+		if p.Default != nil {
+			prop["default"] = p.Default
+		}
+		props[name] = prop
+		if p.Required {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// expand fills {{param}} placeholders in tmpl from args, falling back to
+// parameter defaults. With quote set, values are shell-quoted.
+func (m *PluginManifest) expand(tmpl string, args map[string]interface{}, quote bool) (string, error) {
+	var missing string
+	out := pluginPlaceholder.ReplaceAllStringFunc(tmpl, func(ph string) string {
+		name := pluginPlaceholder.FindStringSubmatch(ph)[1]
+		v, ok := args[name]
+		if !ok || v == nil {
+			v = m.Params[name].Default
+		}
+		if v == nil {
+			if m.Params[name].Required && missing == "" {
+				missing = name
+			}
+			return "" // an unset optional param leaves no word behind
+		}
+		s := fmt.Sprint(v)
+		if f, ok := v.(float64); ok && f == float64(int64(f)) {
+			s = fmt.Sprint(int64(f))
+		}
+		if quote {
+			s = shellQuote(s)
+		}
+		return s
+	})
+	if missing != "" {
+		return "", fmt.Errorf("%s is required", missing)
+	}
+	return out, nil
+}
+
+// shellQuote makes s a single word to a POSIX shell.
+func shellQuote(s string) string {
+	if shellSafeWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// parseFields extracts the manifest's parse fields from output.
+func (m *PluginManifest) parseFields(output string) map[string]string {
+	fields := map[string]string{}
+	for field, re := range m.parse {
+		matches := re.FindAllStringSubmatch(output, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		last := matches[len(matches)-1]
+		if len(last) > 1 {
+			fields[field] = last[1]
+		} else {
+			fields[field] = last[0]
+		}
+	}
+	return fields
+}
+
+// registerPlugins adds plugin tools that do not clash with built-in ones.
+func (r *ToolRegistry) registerPlugins(plugins []*PluginManifest) {
+	for _, m := range plugins {
+		if slices.ContainsFunc(r.entries, func(e toolEntry) bool { return e.def.Name == m.Name }) {
+			log.Printf("plugin tool %q skipped: name is taken by a built-in tool", m.Name)
+			continue
+		}
+		description := m.Description
+		if description == "" {
+			description = fmt.Sprintf("Run %q in session %q", m.Input, m.Session)
+		}
+		r.register(m.Name, description, m.schema(), func(args json.RawMessage) (*CallToolResult, error) {
+			return r.callPlugin(m, args)
+		})
+	}
+}
+
+func (r *ToolRegistry) callPlugin(m *PluginManifest, args json.RawMessage) (*CallToolResult, error) {
+	values := map[string]interface{}{}
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &values); err != nil {
+			return nil, fmt.Errorf("parse args: %w", err)
+		}
+	}
+
+	session, err := m.expand(m.Session, values, false)
+	if err != nil {
+		return nil, err
+	}
+	input, err := m.expand(m.Input, values, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Create != nil {
+		if _, err := r.client.Create(session, daemon.CreateOptions{
+			Command:     m.Create.Command,
+			Cwd:         m.Create.Cwd,
+			Env:         m.Create.Env,
+			IfNotExists: true,
+		}); err != nil {
+			return nil, fmt.Errorf("create session: %w", err)
+		}
+	}
+
+	settleMs := 0
+	if m.SettleMs != nil {
+		settleMs = *m.SettleMs
+	}
+	result, execErr := r.client.Exec(session, daemon.ExecOptions{
+		Input:       input,
+		SettleMs:    settleMs,
+		WaitPattern: m.WaitPattern,
+		TimeoutSec:  m.TimeoutSec,
+		SettleSet:   m.SettleMs != nil,
+		Probe:       m.Probe,
+	})
+	if execErr != nil && (result == nil || result.Output == "") {
+		return nil, execErr
+	}
+
+	out := execResultMap(result, ExecArgs{StripAnsi: m.StripAnsi})
+	out["session"] = session
+	if len(m.parse) > 0 {
+		out["fields"] = m.parseFields(vterm.StripDefault(result.Output))
+	}
+	if execErr != nil {
+		out["warning"] = execErr.Error()
+	}
+
+	data, _ := json.MarshalIndent(out, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		IsError: execErr != nil,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePlugin(t *testing.T, dir, file, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPlugins(t *testing.T) {
+	user, project := t.TempDir(), t.TempDir()
+	writePlugin(t, user, "pytest.json", `{"name": "run_pytest", "session": "tests", "input": "pytest -q"}`)
+	writePlugin(t, user, "lint.json", `{"name": "lint", "session": "dev", "input": "make lint"}`)
+	writePlugin(t, project, "pytest.json", `{
+		"name": "run_pytest",
+		"session": "tests",
+		"input": "pytest {{args}} -q",
+		"params": {"args": {"description": "Extra pytest arguments", "default": ""}}
+	}`)
+	writePlugin(t, project, "broken.json", `{"name": "broken", "session": "x", "input": "{{missing}}"}`)
+	writePlugin(t, project, "notes.txt", `not a manifest`)
+
+	plugins, errs := LoadPlugins([]string{user, project, filepath.Join(project, "absent")})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `undefined param "missing"`) {
+		t.Fatalf("errs = %v", errs)
+	}
+	if len(plugins) != 2 {
+		t.Fatalf("got %d plugins, want 2", len(plugins))
+	}
+	if plugins[0].Name != "lint" || plugins[1].Name != "run_pytest" {
+		t.Errorf("order = %s, %s", plugins[0].Name, plugins[1].Name)
+	}
+	if plugins[1].Input != "pytest {{args}} -q" {
+		t.Errorf("project manifest should override user one, got input %q", plugins[1].Input)
+	}
+}
+
+func TestPluginValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       PluginManifest
+		wantErr string
+	}{
+		{"bad name", PluginManifest{Name: "run pytest", Session: "s", Input: "x"}, "must be"},
+		{"no input", PluginManifest{Name: "t", Session: "s"}, "required"},
+		{"bad type", PluginManifest{Name: "t", Session: "s", Input: "x", Params: map[string]PluginParam{"n": {Type: "list"}}}, "unknown type"},
+		{"bad parse", PluginManifest{Name: "t", Session: "s", Input: "x", Parse: map[string]string{"f": "("}}, `parse "f"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.m.validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPluginExpand(t *testing.T) {
+	m := PluginManifest{
+		Name:    "run_pytest",
+		Session: "tests-{{ suite }}",
+		Input:   "pytest {{path}} -x --maxfail={{maxfail}}",
+		Params: map[string]PluginParam{
+			"suite":   {Default: "unit"},
+			"path":    {Required: true},
+			"maxfail": {Type: "integer", Default: 3},
+		},
+	}
+	if err := m.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	args := map[string]interface{}{"path": "tests/api", "maxfail": float64(1)}
+	if got, _ := m.expand(m.Session, args, false); got != "tests-unit" {
+		t.Errorf("session = %q", got)
+	}
+	if got, _ := m.expand(m.Input, args, true); got != "pytest tests/api -x --maxfail=1" {
+		t.Errorf("input = %q", got)
+	}
+	hostile := map[string]interface{}{"path": "x; rm -rf ~ 'y'"}
+	if got, _ := m.expand(m.Input, hostile, true); got != `pytest 'x; rm -rf ~ '\''y'\''' -x --maxfail=3` {
+		t.Errorf("input with shell syntax = %q, want it quoted as one word", got)
+	}
+	if _, err := m.expand(m.Input, map[string]interface{}{}, true); err == nil || !strings.Contains(err.Error(), "path is required") {
+		t.Errorf("missing required param: err = %v", err)
+	}
+
+	schema := m.schema()
+	if req, _ := schema["required"].([]string); len(req) != 1 || req[0] != "path" {
+		t.Errorf("required = %v", schema["required"])
+	}
+	props := schema["properties"].(map[string]interface{})
+	if props["maxfail"].(map[string]interface{})["type"] != "integer" || props["path"].(map[string]interface{})["type"] != "string" {
+		t.Errorf("properties = %v", props)
+	}
+}
+
+func TestPluginDirsProjectOptIn(t *testing.T) {
+	t.Setenv(PluginPathEnv, "")
+	for _, dir := range PluginDirs(false) {
+		if strings.Contains(dir, ".shelli") {
+			t.Errorf("PluginDirs(false) includes repository dir %s", dir)
+		}
+	}
+
+	t.Setenv(PluginPathEnv, "/a"+string(filepath.ListSeparator)+"/b")
+	if dirs := PluginDirs(true); len(dirs) != 2 || dirs[0] != "/a" || dirs[1] != "/b" {
+		t.Errorf("PluginDirs with %s = %v", PluginPathEnv, dirs)
+	}
+}
+
+func TestPluginParseFields(t *testing.T) {
+	m := PluginManifest{
+		Name: "t", Session: "s", Input: "x",
+		Parse: map[string]string{
+			"passed":  `(\d+) passed`,
+			"failed":  `(\d+) failed`,
+			"summary": `=+ .* =+`,
+		},
+	}
+	if err := m.validate(); err != nil {
+		t.Fatal(err)
+	}
+	fields := m.parseFields("1 passed in setup\n==== 12 passed in 0.31s ====\n")
+	if fields["passed"] != "12" {
+		t.Errorf("passed = %q, want last match", fields["passed"])
+	}
+	if _, ok := fields["failed"]; ok {
+		t.Errorf("failed should be absent, got %q", fields["failed"])
+	}
+	if fields["summary"] != "==== 12 passed in 0.31s ====" {
+		t.Errorf("summary = %q", fields["summary"])
+	}
+}
+
+func TestRegisterPluginsSkipsBuiltins(t *testing.T) {
+	r := &ToolRegistry{}
+	r.register("exec", "built-in", nil, nil)
+	r.registerPlugins([]*PluginManifest{
+		{Name: "exec", Session: "s", Input: "x"},
+		{Name: "run_pytest", Session: "s", Input: "pytest"},
+	})
+	defs := r.List()
+	if len(defs) != 2 || defs[0].Description != "built-in" || defs[1].Name != "run_pytest" {
+		t.Errorf("defs = %+v", defs)
+	}
+}
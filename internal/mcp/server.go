@@ -2,11 +2,16 @@ package mcp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"sync"
+
+	"github.com/schovi/shelli/internal/daemon"
 )
 
 const ProtocolVersion = "2024-11-05"
@@ -17,6 +22,12 @@ type Server struct {
 	reader  *bufio.Reader
 	writer  io.Writer
 	mu      sync.Mutex
+
+	// logLevel is the least severe level of log messages sent, set by
+	// logging/setLevel; bridging starts the event bridge once.
+	logLevel string
+	bridging bool
+	ctx      context.Context
 }
 
 func NewServer(tools *ToolRegistry, version string) *Server {
@@ -25,6 +36,8 @@ func NewServer(tools *ToolRegistry, version string) *Server {
 		version: version,
 		reader:  bufio.NewReader(os.Stdin),
 		writer:  os.Stdout,
+
+		logLevel: "info",
 	}
 }
 
@@ -87,11 +100,17 @@ type CallToolResult struct {
 }
 
 type ContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`     // base64, for image blocks
+	MimeType string `json:"mimeType,omitempty"` // for image blocks
 }
 
 func (s *Server) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.ctx = ctx
+
 	for {
 		line, err := s.reader.ReadBytes('\n')
 		if err != nil {
@@ -117,6 +136,9 @@ func (s *Server) handleRequest(req *Request) {
 		s.handleInitialize(req)
 	case "notifications/initialized":
 		// No response needed
+		s.startEventBridge()
+	case "logging/setLevel":
+		s.handleSetLevel(req)
 	case "tools/list":
 		s.handleToolsList(req)
 	case "tools/call":
@@ -132,7 +154,8 @@ func (s *Server) handleInitialize(req *Request) {
 	result := InitializeResult{
 		ProtocolVersion: ProtocolVersion,
 		Capabilities: map[string]any{
-			"tools": map[string]any{},
+			"tools":   map[string]any{},
+			"logging": map[string]any{},
 		},
 	}
 	result.ServerInfo.Name = "shelli"
@@ -141,6 +164,36 @@ func (s *Server) handleInitialize(req *Request) {
 	s.sendResult(req.ID, result)
 }
 
+// startEventBridge starts forwarding session lifecycle events as log
+// messages, once the client has finished initializing.
+func (s *Server) startEventBridge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bridging || s.ctx == nil {
+		return
+	}
+	s.bridging = true
+	go s.bridgeEvents(s.ctx, s.tools.client)
+}
+
+func (s *Server) handleSetLevel(req *Request) {
+	var params struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		s.sendError(req.ID, -32602, "Invalid params", err.Error())
+		return
+	}
+	if !slices.Contains(logLevels, params.Level) {
+		s.sendError(req.ID, -32602, "Invalid params", fmt.Sprintf("unknown log level %q", params.Level))
+		return
+	}
+	s.mu.Lock()
+	s.logLevel = params.Level
+	s.mu.Unlock()
+	s.sendResult(req.ID, map[string]string{})
+}
+
 func (s *Server) handleToolsList(req *Request) {
 	tools := s.tools.List()
 	s.sendResult(req.ID, ToolsListResult{Tools: tools})
@@ -155,8 +208,20 @@ func (s *Server) handleToolsCall(req *Request) {
 
 	result, err := s.tools.Call(params.Name, params.Arguments)
 	if err != nil {
+		text := err.Error()
+		// Tell agents polling too fast how long to back off, in a form
+		// they can act on.
+		var limited *daemon.RateLimitError
+		if errors.As(err, &limited) {
+			data, _ := json.MarshalIndent(map[string]interface{}{
+				"error":               limited.Message,
+				"code":                daemon.CodeRateLimited,
+				"retry_after_seconds": limited.RetryAfter.Seconds(),
+			}, "", "  ")
+			text = string(data)
+		}
 		s.sendResult(req.ID, CallToolResult{
-			Content: []ContentBlock{{Type: "text", Text: err.Error()}},
+			Content: []ContentBlock{{Type: "text", Text: text}},
 			IsError: true,
 		})
 		return
@@ -185,6 +250,15 @@ func (s *Server) sendError(id interface{}, code int, message, data string) {
 	})
 }
 
+func (s *Server) sendNotification(method string, params interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, _ := json.Marshal(Notification{JSONRPC: "2.0", Method: method, Params: params})
+	s.writer.Write(data)
+	s.writer.Write([]byte("\n"))
+}
+
 func (s *Server) send(resp Response) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -4,6 +4,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/schovi/shelli/internal/vterm"
 	"github.com/schovi/shelli/internal/daemon"
@@ -40,6 +42,10 @@ var createSchema = map[string]interface{}{
 			"type":        "string",
 			"description": "Command to run (e.g., 'python3', 'ssh user@host', 'psql -d mydb'). Defaults to user's shell.",
 		},
+		"profile": map[string]interface{}{
+			"type":        "string",
+			"description": "Start from a saved profile (NAME.json in ~/.config/shelli/profiles or the repo's .shelli/profiles) setting command, env, cwd, cols, rows and tui. Other arguments override it; env adds to the profile's.",
+		},
 		"env": map[string]interface{}{
 			"type":        "array",
 			"items":       map[string]interface{}{"type": "string"},
@@ -57,6 +63,49 @@ var createSchema = map[string]interface{}{
 			"type":        "integer",
 			"description": "Terminal rows (default: 24)",
 		},
+		"size": map[string]interface{}{
+			"type":        "string",
+			"description": "Size preset instead of cols/rows: preset:default (80x24), preset:wide (160x40), preset:tall (80x60), preset:large (200x60)",
+		},
+		"encoding": map[string]interface{}{
+			"type":        "string",
+			"description": "Charset of a program that does not speak UTF-8 (e.g. latin1, shift_jis, euc-kr, gbk). Output is converted to UTF-8 and input back to this charset.",
+		},
+		"mirror_input": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Record everything sent to the session inline in its output as ⟦input: ...⟧ (reverse video), so the transcript shows input to programs that do not echo it. Pattern waits ignore these records. Not with tui.",
+		},
+		"swallow_output_until": map[string]interface{}{
+			"type":        "string",
+			"description": "Hold back startup output (REPL banners, login messages) until this regex matches it (e.g. the first prompt), or for this many milliseconds (e.g. \"1500\"), so the first exec reads a clean buffer. The held-back output is kept as the session's banner (info). Sending input ends it early. Not with tui.",
+		},
+		"capture_priority": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"interactive", "normal", "bulk"},
+			"description": "How the daemon reads the session's output. interactive stores every read at once (default with tui); normal batches reads for up to 2ms (default); bulk batches for up to 25ms in a large buffer, for commands that flood output (builds, log tails), so they load the daemon less. info reports the capture stats.",
+		},
+		"on_exit": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "Shell commands run in the background, in order, once the session's process exits (also after stop, not after kill), e.g. to send a notification instead of polling. They get SHELLI_SESSION, SHELLI_EXIT_CODE, SHELLI_EXIT_SIGNAL, SHELLI_END_REASON and the last 20 output lines (ANSI stripped) in SHELLI_OUTPUT.",
+		},
+		"snapshot_mode": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"resize", "passive"},
+			"description": "TUI mode only. How snapshots get a fresh screen: resize (default) briefly resizes the PTY so the app redraws; passive never resizes or signals it and returns the emulator's current screen, for apps that crash or corrupt state when resized. Chosen automatically once the same command crashed right after a snapshot resize.",
+		},
+		"memory_limit": map[string]interface{}{
+			"type":        "string",
+			"description": "Cap the session's memory, e.g. \"512MB\": the address space of each process, and all its processes together on Linux with cgroup v2. Allocations past it fail (or the cgroup OOM-kills).",
+		},
+		"cpu_time_limit": map[string]interface{}{
+			"type":        "integer",
+			"description": "Cap the CPU time of each of the session's processes, in seconds; past it they get SIGXCPU. Stops runaway loops.",
+		},
+		"cpu_limit": map[string]interface{}{
+			"type":        "integer",
+			"description": "Cap the session's CPU use at this percent of one CPU (over 100 for several). Linux with cgroup v2 only; create fails without it.",
+		},
 		"tui": map[string]interface{}{
 			"type":        "boolean",
 			"description": "Enable TUI mode for apps like vim, htop. Auto-truncates buffer on frame boundaries to reduce storage.",
@@ -65,10 +114,48 @@ var createSchema = map[string]interface{}{
 			"type":        "boolean",
 			"description": "If true, return existing running session instead of error when session already exists.",
 		},
+		"frame_history": map[string]interface{}{
+			"type":        "integer",
+			"description": "Number of past TUI frames to keep for read with frame (default: 0 = off, TUI mode only)",
+		},
+		"scrollback": map[string]interface{}{
+			"type":        "integer",
+			"description": "Number of rows scrolled off the TUI screen to keep for read with screen_scrollback (default: 0 = disabled, TUI mode only)",
+		},
+		"frame_boundaries": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "Frame boundary detectors for frame history: clear, altscreen, sync, home (default: all four, TUI mode only). Drop home for apps that move the cursor home without redrawing.",
+		},
 	},
 	"required": []string{"name"},
 }
 
+var siblingSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Existing session to open the new one next to",
+		},
+		"new_name": map[string]interface{}{
+			"type":        "string",
+			"description": "Name of the new session",
+			"pattern":     "^[A-Za-z0-9][A-Za-z0-9._-]*$",
+		},
+		"command": map[string]interface{}{
+			"type":        "string",
+			"description": "Command to run in the new session. Defaults to user's shell (not the original's command).",
+		},
+		"env": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "Environment variables to set on top of the original's (KEY=VALUE format)",
+		},
+	},
+	"required": []string{"name", "new_name"},
+}
+
 var execSchema = map[string]interface{}{
 	"type": "object",
 	"properties": map[string]interface{}{
@@ -88,6 +175,10 @@ var execSchema = map[string]interface{}{
 			"type":        "string",
 			"description": "Wait for regex pattern match (e.g., '>>>' for Python prompt). Mutually exclusive with settle_ms.",
 		},
+		"wait_prompt": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Return once the output ends in an interactive prompt (shell PS1, python >>>, pdb/ipdb, psql =#, node >) with the cursor right after it, instead of settle_ms or wait_pattern. Robust for long or bursty commands in REPLs and shells.",
+		},
 		"timeout_sec": map[string]interface{}{
 			"type":        "integer",
 			"description": "Max wait time in seconds (default: 10)",
@@ -96,109 +187,248 @@ var execSchema = map[string]interface{}{
 			"type":        "boolean",
 			"description": "Remove ANSI escape codes from output (default: false)",
 		},
+		"probe": map[string]interface{}{
+			"type":        "boolean",
+			"description": "After the command, ask the shell for its exit status and working directory and return them as exit_code and cwd. The probe is hidden from the session output. Shell sessions only.",
+		},
+		"structured": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Return echo (the terminal's echo of the input), body (the command's own output), and prompt (trailing prompt line) instead of output. split is false when the echo was not found; body is then the raw output.",
+		},
+		"max_output": map[string]interface{}{
+			"type":        "integer",
+			"description": "Maximum bytes of output (body in structured mode) to return (default: 32768, -1 for no limit). Longer output is cut at a line boundary and a truncated object gives the offset and limit to fetch the rest with read.",
+		},
+		"keep": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"tail", "head"},
+			"description": "Which end of long output to return (default: tail)",
+		},
+		"max_cpu_sec": map[string]interface{}{
+			"type":        "number",
+			"description": "Stop the command (SIGTERM, then SIGKILL) once it has used this many seconds of CPU time (Linux only). The outcome is reported as budget.",
+		},
+		"max_wall_sec": map[string]interface{}{
+			"type":        "number",
+			"description": "Stop the command once it has run this many seconds. Also raises the default timeout to cover it.",
+		},
+		"enter": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"auto", "lf", "cr"},
+			"description": "Line terminator after input (default: auto, which sends CR when the app has the terminal in raw mode, as the Enter key does, and LF otherwise). Use cr for a REPL that ignores LF.",
+		},
+		"background": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Start the input as a background job ('<input> &') and return right away with its job id and pid, so quick checks can run in the same shell meanwhile. Use the jobs tool to see if it is still running, and exec 'fg %<id>' to wait on it. Wrap compound commands in { ...; }. Shell sessions only; no wait options.",
+		},
+		"delimit": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Append a unique marker command to the input and wait for its answer instead of settle_ms or wait_pattern, so the call returns exactly when the command finishes, even after long silent pauses. The marker is removed from the output and its answer gives exit_code and cwd. Input must be one complete command (no trailing '&' or comment). Shell sessions only.",
+		},
+		"idempotency_key": map[string]interface{}{
+			"type":        "string",
+			"description": "Makes retrying safe: if an exec with this key ran in the session in the last 10 minutes, the input is not sent again and that exec's result is returned (after it finishes) with duplicate: true. Use a fresh key per intended command.",
+		},
+		"complete_lines": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Return only whole lines in output (body in structured mode): a trailing line without a newline (spinner, progress bar, prompt) is held back and returned as partial.",
+		},
 	},
 	"required": []string{"name", "input"},
 }
 
-var sendSchema = map[string]interface{}{
+var runOnceSchema = map[string]interface{}{
 	"type": "object",
 	"properties": map[string]interface{}{
-		"name": map[string]interface{}{
+		"command": map[string]interface{}{
 			"type":        "string",
-			"description": "Session name",
+			"description": "Command to run (e.g., 'python3', 'make test')",
 		},
 		"input": map[string]interface{}{
 			"type":        "string",
-			"description": "Input to send (escape sequences interpreted). Mutually exclusive with inputs and input_base64.",
+			"description": "Line to send once the program's startup output has settled (newline added, literal text). Output then starts after it. Omit to just wait for the command to exit.",
 		},
-		"inputs": map[string]interface{}{
+		"settle_ms": map[string]interface{}{
+			"type":        "integer",
+			"description": "Wait for N ms of silence (default: 500 with input; without input the wait ends when the command exits). Mutually exclusive with wait_pattern.",
+		},
+		"wait_pattern": map[string]interface{}{
+			"type":        "string",
+			"description": "Wait for regex pattern match. Mutually exclusive with settle_ms.",
+		},
+		"timeout_sec": map[string]interface{}{
+			"type":        "integer",
+			"description": "Max wait time in seconds (default: 10)",
+		},
+		"env": map[string]interface{}{
 			"type":        "array",
 			"items":       map[string]interface{}{"type": "string"},
-			"description": "PREFERRED for sequences. Send multiple inputs in one call - each as separate PTY write. Use when sending message + Enter (e.g., [\"text\", \"\\r\"]), commands + confirmations, or any multi-step input. More efficient than multiple send calls. Mutually exclusive with input and input_base64.",
+			"description": "Environment variables to set (KEY=VALUE format)",
 		},
-		"input_base64": map[string]interface{}{
+		"cwd": map[string]interface{}{
 			"type":        "string",
-			"description": "Input as base64 (for binary data). Sent as single write, no escape interpretation. Mutually exclusive with input and inputs.",
+			"description": "Working directory",
+		},
+		"cols": map[string]interface{}{
+			"type":        "integer",
+			"description": "Terminal columns (default: 80)",
+		},
+		"rows": map[string]interface{}{
+			"type":        "integer",
+			"description": "Terminal rows (default: 24)",
+		},
+		"strip_ansi": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Remove ANSI escape codes from output (default: false)",
 		},
 	},
-	"required": []string{"name"},
+	"required": []string{"command"},
 }
 
-var readSchema = map[string]interface{}{
+var execScriptSchema = map[string]interface{}{
 	"type": "object",
 	"properties": map[string]interface{}{
 		"name": map[string]interface{}{
 			"type":        "string",
 			"description": "Session name",
 		},
-		"all": map[string]interface{}{
-			"type":        "boolean",
-			"description": "If true, read all output from session start. If false (default), read only new output since last read. Mutually exclusive with head/tail.",
-		},
-		"head": map[string]interface{}{
-			"type":        "integer",
-			"description": "Return first N lines of buffer. Mutually exclusive with all/tail.",
+		"steps": map[string]interface{}{
+			"type":        "array",
+			"description": "Inputs to run in order. Each is sent like exec (newline added) and waited on with its own condition; unset fields use the script defaults.",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"input":        map[string]interface{}{"type": "string"},
+					"wait_pattern": map[string]interface{}{"type": "string"},
+					"settle_ms":    map[string]interface{}{"type": "integer"},
+					"timeout_sec":  map[string]interface{}{"type": "integer"},
+					"probe":        map[string]interface{}{"type": "boolean"},
+				},
+				"required": []string{"input"},
+			},
 		},
-		"tail": map[string]interface{}{
+		"settle_ms": map[string]interface{}{
 			"type":        "integer",
-			"description": "Return last N lines of buffer. Mutually exclusive with all/head.",
+			"description": "Default settle time per step (default: 500). Mutually exclusive with wait_pattern.",
 		},
 		"wait_pattern": map[string]interface{}{
 			"type":        "string",
-			"description": "Wait for regex pattern match before returning",
-		},
-		"settle_ms": map[string]interface{}{
-			"type":        "integer",
-			"description": "Wait for N ms of silence before returning",
+			"description": "Default regex to wait for per step. Mutually exclusive with settle_ms.",
 		},
 		"timeout_sec": map[string]interface{}{
 			"type":        "integer",
-			"description": "Max wait time in seconds (default: 10, only used with wait_pattern or settle_ms)",
+			"description": "Default max wait per step in seconds (default: 10)",
+		},
+		"probe": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Probe exit status and cwd after each step (shell sessions only). A non-zero exit marks the step failed.",
+		},
+		"keep_going": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Run all steps even after one times out or fails (default: stop and mark the rest skipped)",
 		},
 		"strip_ansi": map[string]interface{}{
 			"type":        "boolean",
-			"description": "Remove ANSI escape codes from output",
+			"description": "Remove ANSI escape codes from step outputs",
 		},
-		"snapshot": map[string]interface{}{
+		"structured": map[string]interface{}{
 			"type":        "boolean",
-			"description": "Force TUI redraw via resize and read clean frame. Requires TUI mode (--tui on create). Incompatible with all, wait_pattern.",
+			"description": "Return only each step's body, without the echoed input and trailing prompt",
 		},
-		"cursor": map[string]interface{}{
+	},
+	"required": []string{"name", "steps"},
+}
+
+var locateSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
 			"type":        "string",
-			"description": "Named cursor for per-consumer read tracking. Each cursor maintains its own position.",
+			"description": "Session name",
+		},
+		"position": map[string]interface{}{
+			"type":        "integer",
+			"description": "Byte position to map to its line (e.g. position from read or exec)",
+		},
+		"line": map[string]interface{}{
+			"type":        "integer",
+			"description": "Line number (1-based, e.g. line_number from search) to map to its start position",
+		},
+		"newlines": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"raw", "lf", "display"},
+			"description": "Count lines as search does in this mode (default: raw); use the mode of the search you are following",
 		},
 	},
 	"required": []string{"name"},
 }
 
-var listSchema = map[string]interface{}{
-	"type":       "object",
-	"properties": map[string]interface{}{},
-}
-
-var stopSchema = map[string]interface{}{
+var diffSchema = map[string]interface{}{
 	"type": "object",
 	"properties": map[string]interface{}{
 		"name": map[string]interface{}{
 			"type":        "string",
-			"description": "Session name to stop",
+			"description": "Session name",
+		},
+		"from": map[string]interface{}{
+			"type":        []string{"integer", "string"},
+			"description": "Start: a byte position (e.g. position from read or exec) or a read cursor name (default: the session's read position)",
+		},
+		"to": map[string]interface{}{
+			"type":        []string{"integer", "string"},
+			"description": "End: a byte position or read cursor name (default: the end of the buffer)",
+		},
+		"max_lines": map[string]interface{}{
+			"type":        "integer",
+			"description": "Return at most the last N added lines (default: 500); the summary counts all",
+		},
+		"strip_ansi": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Remove ANSI escape codes from the lines (default: false)",
 		},
 	},
 	"required": []string{"name"},
 }
 
-var killSchema = map[string]interface{}{
+var waitAnySchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"pattern": map[string]interface{}{
+			"type":        "string",
+			"description": "Regex to wait for, e.g. 'FAIL|panic' or 'BUILD (SUCCESS|FAILED)'",
+		},
+		"filter": map[string]interface{}{
+			"type":        "string",
+			"description": "Glob on session names to watch, e.g. 'test-*' (default: all sessions)",
+		},
+		"ignore_case": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Case-insensitive matching",
+		},
+		"timeout_sec": map[string]interface{}{
+			"type":        "integer",
+			"description": "Max wait time in seconds (default: 60)",
+		},
+	},
+	"required": []string{"pattern"},
+}
+
+var waitExitSchema = map[string]interface{}{
 	"type": "object",
 	"properties": map[string]interface{}{
 		"name": map[string]interface{}{
 			"type":        "string",
-			"description": "Session name to kill",
+			"description": "Session name",
+		},
+		"timeout_sec": map[string]interface{}{
+			"type":        "integer",
+			"description": "Max wait time in seconds (default: 60)",
 		},
 	},
 	"required": []string{"name"},
 }
 
-var infoSchema = map[string]interface{}{
+var jobsSchema = map[string]interface{}{
 	"type": "object",
 	"properties": map[string]interface{}{
 		"name": map[string]interface{}{
@@ -209,553 +439,2137 @@ var infoSchema = map[string]interface{}{
 	"required": []string{"name"},
 }
 
-var clearSchema = map[string]interface{}{
+var execStatusSchema = map[string]interface{}{
 	"type": "object",
 	"properties": map[string]interface{}{
 		"name": map[string]interface{}{
 			"type":        "string",
 			"description": "Session name",
 		},
+		"exec_id": map[string]interface{}{
+			"type":        "integer",
+			"description": "Exec to check, as returned by exec (default: the session's latest exec)",
+		},
 	},
 	"required": []string{"name"},
 }
 
-var resizeSchema = map[string]interface{}{
+var sendSchema = map[string]interface{}{
 	"type": "object",
 	"properties": map[string]interface{}{
 		"name": map[string]interface{}{
 			"type":        "string",
 			"description": "Session name",
 		},
-		"cols": map[string]interface{}{
-			"type":        "integer",
-			"description": "Terminal columns (optional, keeps current if not specified)",
+		"input": map[string]interface{}{
+			"type":        "string",
+			"description": "Input to send (escape sequences interpreted). Mutually exclusive with inputs and input_base64.",
 		},
-		"rows": map[string]interface{}{
-			"type":        "integer",
-			"description": "Terminal rows (optional, keeps current if not specified)",
+		"inputs": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "PREFERRED for sequences. Send multiple inputs in one call - each as separate PTY write. Use when sending message + Enter (e.g., [\"text\", \"\\r\"]), commands + confirmations, or any multi-step input. More efficient than multiple send calls. Mutually exclusive with input and input_base64.",
+		},
+		"input_base64": map[string]interface{}{
+			"type":        "string",
+			"description": "Input as base64 (for binary data). Sent as single write, no escape interpretation. Mutually exclusive with input and inputs.",
+		},
+		"idempotency_key": map[string]interface{}{
+			"type":        "string",
+			"description": "Makes retrying safe: input already sent with this key in the last 10 minutes is not sent again (counted in duplicates). With inputs, each is keyed '<key>/<n>'.",
 		},
 	},
 	"required": []string{"name"},
 }
 
-var searchSchema = map[string]interface{}{
+var readSchema = map[string]interface{}{
 	"type": "object",
 	"properties": map[string]interface{}{
 		"name": map[string]interface{}{
 			"type":        "string",
 			"description": "Session name",
 		},
-		"pattern": map[string]interface{}{
-			"type":        "string",
-			"description": "Regex pattern to search for",
+		"all": map[string]interface{}{
+			"type":        "boolean",
+			"description": "If true, read all output from session start. If false (default), read only new output since last read. Mutually exclusive with head/tail.",
 		},
-		"before": map[string]interface{}{
+		"head": map[string]interface{}{
 			"type":        "integer",
-			"description": "Lines of context before each match (default: 0)",
+			"description": "Return first N lines of buffer. Mutually exclusive with all/tail.",
 		},
-		"after": map[string]interface{}{
+		"tail": map[string]interface{}{
 			"type":        "integer",
-			"description": "Lines of context after each match (default: 0)",
+			"description": "Return last N lines of buffer. Mutually exclusive with all/head.",
 		},
-		"around": map[string]interface{}{
-			"type":        "integer",
-			"description": "Lines of context before AND after (shorthand for before+after). Mutually exclusive with before/after.",
+		"wait_pattern": map[string]interface{}{
+			"type":        "string",
+			"description": "Wait for regex pattern match before returning",
 		},
-		"ignore_case": map[string]interface{}{
+		"wait_prompt": map[string]interface{}{
 			"type":        "boolean",
-			"description": "Case-insensitive search (default: false)",
+			"description": "Wait until the output ends in an interactive prompt (shell PS1, python >>>, pdb/ipdb, psql =#, node >) with the cursor right after it. Mutually exclusive with wait_pattern and settle_ms.",
+		},
+		"settle_ms": map[string]interface{}{
+			"type":        "integer",
+			"description": "Wait for N ms of silence before returning",
+		},
+		"timeout_sec": map[string]interface{}{
+			"type":        "integer",
+			"description": "Max wait time in seconds (default: 10, only used with wait_pattern, settle_ms or wait_prompt)",
 		},
 		"strip_ansi": map[string]interface{}{
 			"type":        "boolean",
-			"description": "Strip ANSI escape codes before searching (default: false)",
+			"description": "Remove ANSI escape codes from output",
 		},
-	},
-	"required": []string{"name", "pattern"},
-}
-
-func NewToolRegistry() *ToolRegistry {
-	r := &ToolRegistry{client: daemon.NewClient()}
-	r.register("create", "Create a new interactive shell session. Use for REPLs, SSH, database CLIs, or any stateful workflow.", createSchema, r.callCreate)
-	r.register("exec", "Send a command to a session and wait for output. Adds newline automatically, waits for output to settle or pattern match. Input is sent as literal text (no escape interpretation). For TUI apps or precise control, use 'send' with separate arguments: send session \"hello\" \"\\r\"", execSchema, r.callExec)
-	r.register("send", "Send raw input to a session without waiting. Low-level command for precise control. Escape sequences (\\n, \\r, \\x03, etc.) are always interpreted. No newline added automatically.", sendSchema, r.callSend)
-	r.register("read", "Read output from a session. Can read new output, all output, or wait for specific patterns.", readSchema, r.callRead)
-	r.register("list", "List all active sessions with their status", listSchema, func(_ json.RawMessage) (*CallToolResult, error) {
-		return r.callList()
-	})
-	r.register("stop", "Stop a running session but keep output accessible. Use this to preserve session output after process ends.", stopSchema, r.callStop)
-	r.register("kill", "Kill/terminate a session and delete all output. Use 'stop' instead if you want to preserve output.", killSchema, r.callKill)
-	r.register("info", "Get detailed information about a session including state, PID, command, buffer size, terminal dimensions, and uptime", infoSchema, r.callInfo)
-	r.register("clear", "Clear the output buffer of a session and reset the read position. The session continues running.", clearSchema, r.callClear)
-	r.register("resize", "Resize terminal dimensions of a running session. At least one of cols or rows must be specified.", resizeSchema, r.callResize)
-	r.register("search", "Search session output buffer for regex patterns with context lines", searchSchema, r.callSearch)
-	return r
-}
-
-func (r *ToolRegistry) List() []ToolDef {
-	defs := make([]ToolDef, len(r.entries))
-	for i, e := range r.entries {
-		defs[i] = e.def
-	}
-	return defs
+		"render": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Return the text as it appeared on screen: carriage-return overwrites (progress bars), backspaces, and cursor movement are applied at the session width. Unlike strip_ansi, which only drops sequences. Incompatible with snapshot, frame, screen_scrollback.",
+		},
+		"newlines": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"raw", "lf", "display"},
+			"description": "Normalize line endings before head/tail count lines: raw (default, as stored), lf (CRLF and lone CR become newlines), display (lone CR overwrites the line, as on screen). Incompatible with render, snapshot, frame, screen_scrollback.",
+		},
+		"snapshot": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Force TUI redraw via resize and read clean frame. Requires TUI mode (--tui on create). Incompatible with all, wait_pattern. The result says how it went: settled (false when the screen was still changing or empty at timeout_sec, so output may be mid-redraw: retry or raise settle_ms/timeout_sec), waited_ms, resize_cycles, bytes_captured (output that arrived meanwhile) and from_frame (the screen was empty and output is the latest captured frame).",
+		},
+		"hold_size": map[string]interface{}{
+			"type":        "boolean",
+			"description": "With snapshot: skip the resize and settle on the emulator's screen, so a human watching the session sees no flicker",
+		},
+		"cursor": map[string]interface{}{
+			"type":        "string",
+			"description": "Named cursor for per-consumer read tracking. Each cursor maintains its own position.",
+		},
+		"frame": map[string]interface{}{
+			"type":        "integer",
+			"description": "Read a past TUI frame captured before a redraw (-1 = most recent, -2 = one before). Incompatible with all, snapshot, cursor, wait_pattern, settle_ms.",
+		},
+		"screen_scrollback": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Read rows scrolled off the TUI screen followed by the current screen, to page backwards in pagers and logs. Requires scrollback on create. Use head/tail to page. Incompatible with all, snapshot, cursor, frame, wait_pattern, settle_ms.",
+		},
+		"offset": map[string]interface{}{
+			"type":        "integer",
+			"description": "Read raw buffer bytes from this offset, e.g. the part of a long exec output that was left out (see exec's truncated.offset). Does not move the read position. Not for TUI sessions. Incompatible with all, snapshot, cursor, frame, screen_scrollback, wait_pattern, settle_ms.",
+		},
+		"limit": map[string]interface{}{
+			"type":        "integer",
+			"description": "With offset: maximum bytes to read (default: to the end)",
+		},
+		"max_bytes": map[string]interface{}{
+			"type":        "integer",
+			"description": "Page through a large backlog: return at most N bytes (cut before a split character) plus more: true when output goes on past position, and next, a token for the next page. A new read then moves the read position only past the page, so reading again gets the next one; with all or offset pass next as continue (or position as the next offset). Combines with max_lines, all, offset, cursor, strip_ansi, render. Not for TUI sessions.",
+		},
+		"max_lines": map[string]interface{}{
+			"type":        "integer",
+			"description": "Page through a large backlog by lines: return at most N lines (and at most max_bytes, or 1 MiB), with more and next as for max_bytes. Not for TUI sessions.",
+		},
+		"continue": map[string]interface{}{
+			"type":        "string",
+			"description": "The next token of a paged read: returns the page after it, with the same max_bytes/max_lines unless given. Incompatible with all, offset, cursor.",
+		},
+		"tail_bytes": map[string]interface{}{
+			"type":        "integer",
+			"description": "Return at most the last N bytes of the buffer, starting at a character and escape-sequence boundary. A cheap peek at huge buffers. Does not move the read position. Not for TUI sessions. Incompatible with all, head, tail, offset, snapshot, cursor, frame, screen_scrollback, wait_pattern, settle_ms, newlines.",
+		},
+		"since": map[string]interface{}{
+			"type":        "string",
+			"description": "Return the output that arrived in a time window instead of by byte offset: a duration back from now (\"2m\", \"90s\") or an RFC 3339 time. Precise to about 10ms. Does not move the read position. Combines with head, tail, newlines, strip_ansi, render. Not for TUI sessions.",
+		},
+		"around_bookmark": map[string]interface{}{
+			"type":        "string",
+			"description": "Return the lines around a bookmark (see the bookmark tool), e.g. the failure a watch marked in a huge log. Does not move the read position. Combines with context, head, tail, newlines, strip_ansi, render. Not for TUI sessions.",
+		},
+		"context": map[string]interface{}{
+			"type":        "integer",
+			"description": "With around_bookmark: lines to return before and after the bookmarked line (default: 20)",
+		},
+		"lines": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Return lines: an array of {line_number, text, offset, ts} records instead of output. line_number counts from the start of the buffer (as search and locate do), text is ANSI stripped, ts is when the line arrived, and a last line without a line break yet has partial: true. Combines with all, head, tail, cursor and newlines. Not for TUI sessions.",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var bookmarkSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"action": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"list", "watch", "unwatch", "add", "delete"},
+			"description": "list (default); watch: bookmark every output line matching pattern as bookmark-1, bookmark-2, ...; unwatch: stop the watch named bookmark (all without one); add: bookmark the end of the output now; delete: remove a bookmark",
+		},
+		"pattern": map[string]interface{}{
+			"type":        "string",
+			"description": "With watch: regex matched against each output line, ANSI stripped",
+		},
+		"bookmark": map[string]interface{}{
+			"type":        "string",
+			"description": "The bookmark name (add, delete), or the watch name its bookmarks are numbered after (watch, unwatch; default: match)",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var listSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"here": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Only sessions created from the git repository the MCP server runs in",
+		},
+		"verbose": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Add a health check of each session: status ok, suspended (SIGSTOP), zombie, pty_error or exited, with the process state letter",
+		},
+	},
+}
+
+// dryRunProperty is the dry_run parameter of the destructive tools (stop,
+// kill, clear).
+var dryRunProperty = map[string]interface{}{
+	"type":        "boolean",
+	"description": "Do nothing; return what the call would affect: state, pid, terminates_process, buffer_bytes, unread_bytes, cursors, bookmarks, discarded_bytes, output_kept (read/search still work afterwards), archived (the files stay in data_dir for shelli archive) and removed_after_seconds (stopped_ttl). Use to confirm with the user before destroying output.",
+}
+
+var stopSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name to stop",
+		},
+		"dry_run": dryRunProperty,
+	},
+	"required": []string{"name"},
+}
+
+var killSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name to kill",
+		},
+		"dry_run": dryRunProperty,
+	},
+	"required": []string{"name"},
+}
+
+var infoSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var clearSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"dry_run": dryRunProperty,
+	},
+	"required": []string{"name"},
+}
+
+var mirrorInputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"enabled": map[string]interface{}{
+			"type":        "boolean",
+			"description": "true to record sent input in the output, false to stop",
+		},
+	},
+	"required": []string{"name", "enabled"},
+}
+
+var compactSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var pauseSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var freezeSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var activitySchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"idle_ms": map[string]interface{}{
+			"type":        "integer",
+			"description": "Silence in milliseconds after which the session counts as idle (default: 2000)",
+		},
+		"wait": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Block until the session is idle (or stopped)",
+		},
+		"timeout_sec": map[string]interface{}{
+			"type":        "integer",
+			"description": "Max wait time in seconds with wait (default: 60)",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var resizeSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"cols": map[string]interface{}{
+			"type":        "integer",
+			"description": "Terminal columns (optional, keeps current if not specified)",
+		},
+		"rows": map[string]interface{}{
+			"type":        "integer",
+			"description": "Terminal rows (optional, keeps current if not specified)",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var fitSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"max_cols": map[string]interface{}{
+			"type":        "integer",
+			"description": "Never fit wider than this (optional)",
+		},
+		"max_rows": map[string]interface{}{
+			"type":        "integer",
+			"description": "Never fit taller than this (optional)",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var describeSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var screenSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"styled": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Keep ANSI colors and styles in the rows (default: false, plain text)",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var searchSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"pattern": map[string]interface{}{
+			"type":        "string",
+			"description": "Regex pattern to search for",
+		},
+		"before": map[string]interface{}{
+			"type":        "integer",
+			"description": "Lines of context before each match (default: 0)",
+		},
+		"after": map[string]interface{}{
+			"type":        "integer",
+			"description": "Lines of context after each match (default: 0)",
+		},
+		"around": map[string]interface{}{
+			"type":        "integer",
+			"description": "Lines of context before AND after (shorthand for before+after). Mutually exclusive with before/after.",
+		},
+		"ignore_case": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Case-insensitive search (default: false)",
+		},
+		"strip_ansi": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Strip ANSI escape codes before searching (default: false)",
+		},
+		"newlines": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"raw", "lf", "display"},
+			"description": "Normalize line endings before splitting lines, so line numbers match the screen: raw (default), lf, or display (lone CR overwrites the line)",
+		},
+		"offset": map[string]interface{}{
+			"type":        "integer",
+			"description": "Skip the first N matches (default: 0). total_matches still counts them all.",
+		},
+		"limit": map[string]interface{}{
+			"type":        "integer",
+			"description": "Return at most N matches (default: all), plus next, a token for the rest, when there are more",
+		},
+		"continue": map[string]interface{}{
+			"type":        "string",
+			"description": "The next token of a paged search: returns the matches after it, with the same pattern and settings (limit unless given). Pattern may then be omitted.",
+		},
+		"render": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Search what the terminal shows instead of raw output lines: the buffer is replayed through a terminal emulator at the session's size (TUI sessions use the live screen and its scrollback), so text placed by cursor movement matches as displayed. Each match gets screen: {row, col}; row 0 is the top screen row, negative rows scrolled off above. Incompatible with newlines.",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var extractSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"all": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Extract from all output instead of the new output since the last read (default: false)",
+		},
+		"json_path": map[string]interface{}{
+			"type":        "string",
+			"description": "Return only this field of each value: dot-separated keys and [N] indexes, e.g. .items[0].name. Values without it are left out.",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var imagesSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"id": map[string]interface{}{
+			"type":        "integer",
+			"description": "Image ID to fetch. Omit to list captured images.",
+		},
+	},
+	"required": []string{"name"},
+}
+
+var notificationsSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "Session name",
+		},
+		"after_id": map[string]interface{}{
+			"type":        "integer",
+			"description": "Only return notifications with an ID above this (pass the last seen ID to poll for new ones)",
+		},
+	},
+	"required": []string{"name"},
+}
+
+// NewToolRegistry registers the built-in tools followed by the plugin tools
+// found in pluginDirs.
+func NewToolRegistry(pluginDirs []string) *ToolRegistry {
+	r := &ToolRegistry{client: daemon.NewClient()}
+	r.register("create", "Create a new interactive shell session. Use for REPLs, SSH, database CLIs, or any stateful workflow.", createSchema, r.callCreate)
+	r.register("sibling", "Create a session next to an existing one: in the directory its process is in now, with the environment it was created with and its terminal size, running the user's shell or command. The original is not touched; use for diagnostics beside a busy session instead of interrupting it. Returns the new session and its cwd.", siblingSchema, r.callSibling)
+	r.register("exec", "Send a command to a session and wait for output. Adds newline automatically, waits for output to settle or pattern match. Input is sent as literal text (no escape interpretation). For TUI apps or precise control, use 'send' with separate arguments: send session \"hello\" \"\\r\". On timeout the result includes a 'timeout' object: last_lines, output_grew, prompt_seen and a suggestion (read, retry or interrupt).", execSchema, r.callExec)
+	r.register("run_once", "Run a command in a throwaway session: the daemon creates it, sends input (if any) once startup output settles, waits for wait_pattern, settle or exit, and kills it before returning, also on timeout or error. Use for one-off commands or REPL snippets instead of create + exec + kill. Returns output, status (matched, settled, exited, timeout) and exit_code when the command finished.", runOnceSchema, r.callRunOnce)
+	r.register("exec_script", "Run several commands in one call: each step is sent and waited on in order, returning per-step output and status (ok, timeout, failed, error, skipped). Stops at the first unsuccessful step unless keep_going.", execScriptSchema, r.callExecScript)
+	r.register("exec_status", "Check progress of a session's latest (or given) exec: status (running, completed, timeout, error), elapsed seconds, output bytes, idle seconds and last output line. Use after an exec timed out to see whether the command is still working, or to poll a long command from another client.", execStatusSchema, r.callExecStatus)
+	r.register("jobs", "List background jobs started with exec background: id (shell job number), pid, command and status (running or done).", jobsSchema, r.callJobs)
+	r.register("send", "Send raw input to a session without waiting. Low-level command for precise control. Escape sequences (\\n, \\r, \\x03, etc.) are always interpreted. No newline added automatically.", sendSchema, r.callSend)
+	r.register("read", "Read output from a session. Can read new output, all output, or wait for specific patterns.", readSchema, r.callRead)
+	r.register("list", "List all active sessions with their status; verbose adds a health check of each", listSchema, r.callList)
+	r.register("stop", "Stop a running session but keep output accessible. Use this to preserve session output after process ends. dry_run reports what would be affected without stopping.", stopSchema, r.callStop)
+	r.register("kill", "Kill/terminate a session and delete all output. Use 'stop' instead if you want to preserve output. dry_run reports what would be lost (process, buffer_bytes, unread_bytes) without killing.", killSchema, r.callKill)
+	r.register("info", "Get detailed information about a session including state, PID, command, buffer size, terminal dimensions, and uptime. alt_screen is true while a full-screen app (vim, htop, less) has the alternate screen: drive it with send and read snapshots rather than exec. health.status is ok, suspended (stopped by SIGSTOP), zombie, pty_error or exited: a running state alone does not mean the child can make progress", infoSchema, r.callInfo)
+	r.register("clear", "Clear the output buffer of a session and reset the read position. The session continues running. dry_run reports what would be discarded without clearing.", clearSchema, r.callClear)
+	r.register("mirror_input", "Turn input mirroring on or off for a running session: while on, sent input is recorded inline in the output as ⟦input: ...⟧, showing what was typed into password prompts and other programs that do not echo. Pattern waits ignore the records. Not for TUI sessions.", mirrorInputSchema, r.callMirrorInput)
+	r.register("pause", "Stop storing a session's output until resume, e.g. around a noisy download or verbose build step. The program keeps running and its output is read and discarded (counted as dropped bytes). Not for TUI sessions.", pauseSchema, r.callPause)
+	r.register("freeze", "Halt a session's program with SIGSTOP (its process group and the foreground job), e.g. to hold a runaway or risky task while asking the user what to do. Nothing is lost; thaw continues it. send and exec are refused while frozen; list and info report frozen.", freezeSchema, r.callFreeze)
+	r.register("thaw", "Continue a session halted with freeze (SIGCONT). Returns frozen_seconds.", freezeSchema, r.callThaw)
+	r.register("activity", "Report whether a session is producing output: last_output_at, idle_seconds, bytes_per_second over the last 1s/10s/60s, and idle (silent for idle_ms, default 2000, or stopped). With wait, block until idle: a cheaper, output-free alternative to settle waits for builds, downloads and REPL startups.", activitySchema, r.callActivity)
+	r.register("resume", "Store a paused session's output again. Returns dropped_bytes (output discarded by the pause) and paused_seconds.", pauseSchema, r.callResume)
+	r.register("compact", "Rewrite a session's stored output as plain text (escape sequences rendered away) to reclaim space, e.g. after running a full-screen app without tui mode. Read position and cursors keep their place. Not for TUI sessions.", compactSchema, r.callCompact)
+	r.register("resize", "Resize terminal dimensions of a running session. At least one of cols or rows must be specified.", resizeSchema, r.callResize)
+	r.register("fit", "Shrink a TUI session's terminal to the rows and columns its screen uses (never below 20x2), so snapshots are not padded with blank space. Returns used_cols/used_rows and the size before (from_cols/from_rows) and after (cols/rows). Requires TUI mode; take a new snapshot afterwards, as the app redraws.", fitSchema, r.callFit)
+	r.register("describe", "Sum a session up in one call, to decide what to do next: state, command, uptime_seconds and idle_seconds, at_prompt (the cursor sits right after a shell, python, pdb, psql or node prompt; prompt says which), question (the line the program waits on when it looks like a question, e.g. \"Overwrite? [y/N]\" or \"Password:\" - answer it with send), alt_screen (a full-screen app is up: use send and snapshots), lines (the last 20 lines, ANSI stripped), exit_code/end_reason once stopped, and events (recent lifecycle events: created, resized, output-truncated, exited). Does not move the read position.", describeSchema, r.callDescribe)
+	r.register("screen", "Get a session's terminal as an array of rows (one string per screen row, top to bottom) plus the cursor's 0-based row and col and whether it is visible. Row i is line i of the display, so menus, forms and status bars keep their layout. TUI sessions return the live screen (source: screen); other sessions replay the end of their output at the session's size (source: buffer).", screenSchema, r.callScreen)
+	r.register("search", "Search session output buffer for regex patterns with context lines", searchSchema, r.callSearch)
+	r.register("extract", "Parse the JSON objects and arrays in a session's output (escape sequences stripped) and return them as values, with count and position. Reads the new output since the last read, moving the read position like read, or all output with all. A value must start a line, so the echoed command is skipped and pretty-printed or colored jq output parses as one value. Use after running a command that prints JSON instead of digging it out of the raw output.", extractSchema, r.callExtract)
+	r.register("diff", "What happened while you were away: the lines a session added between two buffer positions (default: since the last read up to now) with a summary of lines and bytes added and the time they span. Nothing is consumed. Positions are byte offsets from read/exec or read cursor names. Not for TUI sessions.", diffSchema, r.callDiff)
+	r.register("locate", "Map a byte position in a session's buffer (from read or exec) to its line number and column, or a line number (from search) to its position. Returns line_start and line_end offsets for a ranged read (read offset/limit) without downloading the buffer. Not for TUI sessions.", locateSchema, r.callLocate)
+	r.register("bookmark", "Name positions in a session's output to return to with read around_bookmark. watch bookmarks every line matching a regex as it arrives (failure-1, failure-2, ...), so the relevant slice of a huge log can be pulled later without reading it all; add marks the end of the output now. Returns the watches and bookmarks (name, offset, matched line). Bookmarks move with the buffer and are dropped with the output they point at. Not for TUI sessions.", bookmarkSchema, r.callBookmark)
+	r.register("wait_any", "Wait until any session's unread output matches a regex and return which session matched first (session, match, position). For parallel jobs in several sessions when the first failure or success matters. filter limits the sessions by name glob; the read position is not moved.", waitAnySchema, r.callWaitAny)
+	r.register("wait_exit", "Wait until the process of a session exits and return exit_code (128+N when killed by signal N, with signal named). For sessions created to run one command (create with command: 'make test'): branch on exit_code instead of parsing output. Returns at once if the process already exited; info also shows exit_code.", waitExitSchema, r.callWaitExit)
+	r.register("notifications", "List bells (BEL) and desktop notifications (OSC 9/777) a session sent, e.g. an app beeping for attention. They are removed from text output.", notificationsSchema, r.callNotifications)
+	r.register("images", "List or fetch inline images (iTerm2 OSC 1337, kitty graphics) a session displayed. They are removed from text output; without id returns the list, with id returns the image.", imagesSchema, r.callImages)
+
+	plugins, errs := LoadPlugins(pluginDirs)
+	for _, err := range errs {
+		log.Printf("%v", err)
+	}
+	r.registerPlugins(plugins)
+	return r
+}
+
+func (r *ToolRegistry) List() []ToolDef {
+	defs := make([]ToolDef, len(r.entries))
+	for i, e := range r.entries {
+		defs[i] = e.def
+	}
+	return defs
+}
+
+func (r *ToolRegistry) Call(name string, args json.RawMessage) (*CallToolResult, error) {
+	if err := r.client.EnsureDaemon(); err != nil {
+		return nil, fmt.Errorf("daemon: %w", err)
+	}
+	for _, e := range r.entries {
+		if e.def.Name == name {
+			return e.handler(args)
+		}
+	}
+	return nil, fmt.Errorf("unknown tool: %s", name)
+}
+
+type CreateArgs struct {
+	Name               string   `json:"name"`
+	Command            string   `json:"command"`
+	Env                []string `json:"env"`
+	Cwd                string   `json:"cwd"`
+	Cols               int      `json:"cols"`
+	Rows               int      `json:"rows"`
+	Size               string   `json:"size"`
+	TUI                bool     `json:"tui"`
+	IfNotExists        bool     `json:"if_not_exists"`
+	FrameHistory       int      `json:"frame_history"`
+	Scrollback         int      `json:"scrollback"`
+	FrameBoundaries    []string `json:"frame_boundaries"`
+	Encoding           string   `json:"encoding"`
+	MirrorInput        bool     `json:"mirror_input"`
+	SwallowOutputUntil string   `json:"swallow_output_until"`
+	CapturePriority    string   `json:"capture_priority"`
+	Profile            string   `json:"profile"`
+	OnExit             []string `json:"on_exit"`
+	SnapshotMode       string   `json:"snapshot_mode"`
+	MemoryLimit        string   `json:"memory_limit"`
+	CPUTimeLimit       int64    `json:"cpu_time_limit"`
+	CPULimit           int      `json:"cpu_limit"`
+}
+
+func (r *ToolRegistry) callCreate(args json.RawMessage) (*CallToolResult, error) {
+	var a CreateArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+	if a.Size != "" {
+		if a.Cols > 0 || a.Rows > 0 {
+			return nil, fmt.Errorf("size cannot be combined with cols or rows")
+		}
+		size, err := daemon.ParseSizePreset(a.Size)
+		if err != nil {
+			return nil, err
+		}
+		a.Cols, a.Rows = size.Cols, size.Rows
+	}
+
+	opts := daemon.CreateOptions{
+		Command:            a.Command,
+		Env:                a.Env,
+		Cwd:                a.Cwd,
+		Cols:               a.Cols,
+		Rows:               a.Rows,
+		TUIMode:            a.TUI,
+		IfNotExists:        a.IfNotExists,
+		FrameHistory:       a.FrameHistory,
+		Scrollback:         a.Scrollback,
+		FrameBoundaries:    a.FrameBoundaries,
+		Encoding:           a.Encoding,
+		MirrorInput:        a.MirrorInput,
+		SwallowOutputUntil: a.SwallowOutputUntil,
+		CapturePriority:    a.CapturePriority,
+		OnExit:             a.OnExit,
+		SnapshotMode:       a.SnapshotMode,
+	}
+	if a.MemoryLimit != "" || a.CPUTimeLimit > 0 || a.CPULimit > 0 {
+		opts.Limits = &daemon.ResourceLimits{CPUSeconds: a.CPUTimeLimit, CPUPercent: a.CPULimit}
+		if a.MemoryLimit != "" {
+			size, err := daemon.ParseSize(a.MemoryLimit)
+			if err != nil {
+				return nil, fmt.Errorf("invalid memory_limit: %w", err)
+			}
+			opts.Limits.MemoryBytes = int64(size)
+		}
+	}
+	if a.Profile != "" {
+		profile, err := daemon.LoadProfile(daemon.ProfileDirs(), a.Profile)
+		if err != nil {
+			return nil, err
+		}
+		if opts, err = profile.Apply(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := r.client.Create(a.Name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	output, _ := json.MarshalIndent(data, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(output)}},
+	}, nil
+}
+
+type SiblingArgs struct {
+	Name    string   `json:"name"`
+	NewName string   `json:"new_name"`
+	Command string   `json:"command"`
+	Env     []string `json:"env"`
+}
+
+func (r *ToolRegistry) callSibling(args json.RawMessage) (*CallToolResult, error) {
+	var a SiblingArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	data, err := r.client.Sibling(a.Name, a.NewName, a.Command, a.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	output, _ := json.MarshalIndent(data, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(output)}},
+	}, nil
+}
+
+type ExecArgs struct {
+	Name           string  `json:"name"`
+	Input          string  `json:"input"`
+	SettleMs       *int    `json:"settle_ms"`
+	WaitPattern    string  `json:"wait_pattern"`
+	TimeoutSec     int     `json:"timeout_sec"`
+	StripAnsi      bool    `json:"strip_ansi"`
+	Structured     bool    `json:"structured"`
+	Probe          bool    `json:"probe"`
+	MaxOutput      *int    `json:"max_output"`
+	Keep           string  `json:"keep"`
+	MaxCPUSec      float64 `json:"max_cpu_sec"`
+	MaxWallSec     float64 `json:"max_wall_sec"`
+	Background     bool    `json:"background"`
+	Enter          string  `json:"enter"`
+	Delimit        bool    `json:"delimit"`
+	WaitPrompt     bool    `json:"wait_prompt"`
+	IdempotencyKey string  `json:"idempotency_key"`
+	CompleteLines  bool    `json:"complete_lines"`
+}
+
+// defaultExecMaxOutput bounds exec output returned to the model unless
+// max_output says otherwise.
+const defaultExecMaxOutput = 32 * 1024
+
+func (r *ToolRegistry) callExec(args json.RawMessage) (*CallToolResult, error) {
+	var a ExecArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	if a.WaitPattern != "" && a.SettleMs != nil && *a.SettleMs > 0 {
+		return nil, fmt.Errorf("wait_pattern and settle_ms are mutually exclusive")
+	}
+
+	if a.Input == "" {
+		return nil, fmt.Errorf("input is required")
+	}
+
+	if a.Keep != "" && a.Keep != "tail" && a.Keep != "head" {
+		return nil, fmt.Errorf("keep must be tail or head")
+	}
+
+	if err := daemon.ValidateEnter(a.Enter); err != nil {
+		return nil, err
+	}
+
+	if a.Delimit && (a.WaitPattern != "" || a.SettleMs != nil || a.Background) {
+		return nil, fmt.Errorf("delimit cannot be combined with wait_pattern, settle_ms or background")
+	}
+
+	if a.WaitPrompt && (a.WaitPattern != "" || a.SettleMs != nil || a.Delimit || a.Background) {
+		return nil, fmt.Errorf("wait_prompt cannot be combined with wait_pattern, settle_ms, delimit or background")
+	}
+
+	if a.Background && (a.IdempotencyKey != "" || a.CompleteLines) {
+		return nil, fmt.Errorf("idempotency_key and complete_lines cannot be combined with background")
+	}
+
+	if a.Background {
+		if a.WaitPattern != "" || a.SettleMs != nil || a.Probe || a.Structured || a.MaxCPUSec > 0 || a.MaxWallSec > 0 {
+			return nil, fmt.Errorf("background cannot be combined with wait_pattern, settle_ms, probe, structured, max_cpu_sec or max_wall_sec")
+		}
+		job, err := r.client.Background(a.Name, a.Input, 0)
+		if err != nil {
+			return nil, err
+		}
+		data, _ := json.MarshalIndent(job, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	settleMs := 0
+	if a.SettleMs != nil {
+		settleMs = *a.SettleMs
+	}
+
+	result, err := r.client.Exec(a.Name, daemon.ExecOptions{
+		Input:          a.Input,
+		SettleMs:       settleMs,
+		WaitPattern:    a.WaitPattern,
+		TimeoutSec:     a.TimeoutSec,
+		SettleSet:      a.SettleMs != nil,
+		Probe:          a.Probe,
+		MaxCPU:         time.Duration(a.MaxCPUSec * float64(time.Second)),
+		MaxWall:        time.Duration(a.MaxWallSec * float64(time.Second)),
+		Enter:          a.Enter,
+		Delimit:        a.Delimit,
+		WaitPrompt:     a.WaitPrompt,
+		IdempotencyKey: a.IdempotencyKey,
+	})
+	if err != nil {
+		if result == nil || (result.Output == "" && result.Timeout == nil) {
+			return nil, err
+		}
+		out := execResultMap(result, a)
+		out["warning"] = err.Error()
+		data, _ := json.MarshalIndent(out, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+			IsError: true,
+		}, nil
+	}
+
+	data, _ := json.MarshalIndent(execResultMap(result, a), "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+func execResultMap(result *daemon.ExecResult, a ExecArgs) map[string]interface{} {
+	clean := func(s string) string {
+		if a.StripAnsi {
+			return vterm.StripDefault(s)
+		}
+		return s
+	}
+
+	limit := defaultExecMaxOutput
+	if a.MaxOutput != nil {
+		limit = *a.MaxOutput
+	}
+	start := result.Position - len(result.Output)
+
+	var out map[string]interface{}
+	var page daemon.OutputPage
+	var paged, partial string
+	if a.Structured {
+		parts := daemon.SplitExecOutput(result.Output, result.Input)
+		if a.CompleteLines {
+			parts.Body, partial = daemon.SplitPartialLine(parts.Body)
+		}
+		page = daemon.PageOutput(parts.Body, limit, a.Keep == "head")
+		paged = parts.Body
+		start += len(parts.Echo)
+		out = map[string]interface{}{
+			"input":    result.Input,
+			"echo":     clean(parts.Echo),
+			"body":     clean(page.Shown),
+			"prompt":   clean(parts.Prompt),
+			"split":    parts.Split,
+			"position": result.Position,
+		}
+	} else {
+		output := result.Output
+		if a.CompleteLines {
+			output, partial = daemon.SplitPartialLine(output)
+		}
+		page = daemon.PageOutput(output, limit, a.Keep == "head")
+		paged = output
+		out = map[string]interface{}{
+			"input":    result.Input,
+			"output":   clean(page.Shown),
+			"position": result.Position,
+		}
+	}
+	if page.OmittedBytes > 0 {
+		out["truncated"] = map[string]interface{}{
+			"total_bytes":   len(paged),
+			"omitted_bytes": page.OmittedBytes,
+			"omitted_lines": page.OmittedLines,
+			"offset":        start + page.OmittedOffset,
+			"limit":         page.OmittedBytes,
+		}
+	}
+	if a.CompleteLines {
+		out["partial"] = clean(partial)
+	}
+	if result.ID != 0 {
+		out["exec_id"] = result.ID
+	}
+	if result.Duplicate {
+		out["duplicate"] = true
+	}
+	if result.Budget != nil {
+		out["budget"] = result.Budget
+	}
+	if result.Probe != nil {
+		out["exit_code"] = result.Probe.ExitCode
+		out["cwd"] = result.Probe.Cwd
+	}
+	if result.Timeout != nil {
+		out["timeout"] = result.Timeout
+	}
+	return out
+}
+
+type RunOnceArgs struct {
+	Command     string   `json:"command"`
+	Input       string   `json:"input"`
+	SettleMs    int      `json:"settle_ms"`
+	WaitPattern string   `json:"wait_pattern"`
+	TimeoutSec  int      `json:"timeout_sec"`
+	Env         []string `json:"env"`
+	Cwd         string   `json:"cwd"`
+	Cols        int      `json:"cols"`
+	Rows        int      `json:"rows"`
+	StripANSI   bool     `json:"strip_ansi"`
+}
+
+func (r *ToolRegistry) callRunOnce(args json.RawMessage) (*CallToolResult, error) {
+	var a RunOnceArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+	if a.Command == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	if a.WaitPattern != "" && a.SettleMs > 0 {
+		return nil, fmt.Errorf("wait_pattern and settle_ms are mutually exclusive")
+	}
+
+	result, err := r.client.RunOnce(daemon.RunOnceOptions{
+		Command:     a.Command,
+		Input:       a.Input,
+		WaitPattern: a.WaitPattern,
+		SettleMs:    a.SettleMs,
+		TimeoutSec:  a.TimeoutSec,
+		Env:         a.Env,
+		Cwd:         a.Cwd,
+		Cols:        a.Cols,
+		Rows:        a.Rows,
+		StripANSI:   a.StripANSI,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+type ExecScriptArgs struct {
+	Name        string            `json:"name"`
+	Steps       []daemon.ExecStep `json:"steps"`
+	SettleMs    *int              `json:"settle_ms"`
+	WaitPattern string            `json:"wait_pattern"`
+	TimeoutSec  int               `json:"timeout_sec"`
+	Probe       bool              `json:"probe"`
+	KeepGoing   bool              `json:"keep_going"`
+	StripAnsi   bool              `json:"strip_ansi"`
+	Structured  bool              `json:"structured"`
+}
+
+func (r *ToolRegistry) callExecScript(args json.RawMessage) (*CallToolResult, error) {
+	var a ExecScriptArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	if a.WaitPattern != "" && a.SettleMs != nil && *a.SettleMs > 0 {
+		return nil, fmt.Errorf("wait_pattern and settle_ms are mutually exclusive")
+	}
+	if len(a.Steps) == 0 {
+		return nil, fmt.Errorf("steps is required")
+	}
+	if err := daemon.ValidateSteps(a.Steps); err != nil {
+		return nil, err
+	}
+
+	settleMs := 0
+	if a.SettleMs != nil {
+		settleMs = *a.SettleMs
+	}
+
+	results := r.client.ExecSteps(a.Name, a.Steps, daemon.ExecOptions{
+		SettleMs:    settleMs,
+		WaitPattern: a.WaitPattern,
+		TimeoutSec:  a.TimeoutSec,
+		SettleSet:   a.SettleMs != nil,
+		Probe:       a.Probe,
+	}, a.KeepGoing)
+
+	failed := false
+	for i := range results {
+		res := &results[i]
+		if a.Structured {
+			res.Output = daemon.SplitExecOutput(res.Output, res.Input).Body
+		}
+		if a.StripAnsi {
+			res.Output = vterm.StripDefault(res.Output)
+		}
+		if res.Status != daemon.StepOK {
+			failed = true
+		}
+	}
+
+	data, _ := json.MarshalIndent(results, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		IsError: failed,
+	}, nil
+}
+
+type BookmarkArgs struct {
+	Name     string `json:"name"`
+	Action   string `json:"action"`
+	Pattern  string `json:"pattern"`
+	Bookmark string `json:"bookmark"`
+}
+
+func (r *ToolRegistry) callBookmark(args json.RawMessage) (*CallToolResult, error) {
+	var a BookmarkArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	set, err := r.client.Bookmark(a.Name, a.Action, a.Bookmark, a.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(set, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+type LocateArgs struct {
+	Name     string `json:"name"`
+	Position *int64 `json:"position"`
+	Line     int    `json:"line"`
+	Newlines string `json:"newlines"`
+}
+
+func (r *ToolRegistry) callLocate(args json.RawMessage) (*CallToolResult, error) {
+	var a LocateArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+	if (a.Position != nil) == (a.Line != 0) {
+		return nil, fmt.Errorf("exactly one of position or line is required")
+	}
+	if a.Line < 0 {
+		return nil, fmt.Errorf("line must be positive")
+	}
+	var position int64
+	if a.Position != nil {
+		position = *a.Position
+	}
+
+	lp, err := r.client.Locate(a.Name, position, a.Line, a.Newlines)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(lp, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+// bufferPosition is a diff endpoint given as a byte position or a cursor
+// name.
+type bufferPosition string
+
+func (p *bufferPosition) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*p = bufferPosition(fmt.Sprint(n))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("want a byte position or cursor name")
+	}
+	*p = bufferPosition(s)
+	return nil
+}
+
+type DiffArgs struct {
+	Name      string         `json:"name"`
+	From      bufferPosition `json:"from"`
+	To        bufferPosition `json:"to"`
+	MaxLines  int            `json:"max_lines"`
+	StripAnsi bool           `json:"strip_ansi"`
+}
+
+func (r *ToolRegistry) callDiff(args json.RawMessage) (*CallToolResult, error) {
+	var a DiffArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+	if a.MaxLines < 0 {
+		return nil, fmt.Errorf("max_lines must not be negative")
+	}
+
+	result, err := r.client.Diff(a.Name, string(a.From), string(a.To), a.MaxLines, a.StripAnsi)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+type WaitAnyArgs struct {
+	Pattern    string `json:"pattern"`
+	Filter     string `json:"filter"`
+	IgnoreCase bool   `json:"ignore_case"`
+	TimeoutSec int    `json:"timeout_sec"`
+}
+
+func (r *ToolRegistry) callWaitAny(args json.RawMessage) (*CallToolResult, error) {
+	var a WaitAnyArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+	if a.TimeoutSec <= 0 {
+		a.TimeoutSec = 60
+	}
+
+	result, err := r.client.WaitAny(a.Filter, a.Pattern, a.IgnoreCase, a.TimeoutSec)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+type WaitExitArgs struct {
+	Name       string `json:"name"`
+	TimeoutSec int    `json:"timeout_sec"`
+}
+
+func (r *ToolRegistry) callWaitExit(args json.RawMessage) (*CallToolResult, error) {
+	var a WaitExitArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+	if a.TimeoutSec <= 0 {
+		a.TimeoutSec = 60
+	}
+
+	result, err := r.client.WaitExit(a.Name, a.TimeoutSec)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+type JobsArgs struct {
+	Name string `json:"name"`
+}
+
+func (r *ToolRegistry) callJobs(args json.RawMessage) (*CallToolResult, error) {
+	var a JobsArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	jobs, err := r.client.Jobs(a.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(jobs, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+type ExecStatusArgs struct {
+	Name   string `json:"name"`
+	ExecID int64  `json:"exec_id"`
+}
+
+func (r *ToolRegistry) callExecStatus(args json.RawMessage) (*CallToolResult, error) {
+	var a ExecStatusArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	status, err := r.client.ExecStatus(a.Name, a.ExecID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(status, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+type SendArgs struct {
+	Name           string   `json:"name"`
+	Input          string   `json:"input"`
+	Inputs         []string `json:"inputs"`
+	InputBase64    string   `json:"input_base64"`
+	IdempotencyKey string   `json:"idempotency_key"`
+}
+
+// send writes one input, skipping it when its idempotency key was used
+// before; duplicate reports the skip.
+func (a SendArgs) send(client *daemon.Client, i, count int, input string) (duplicate bool, err error) {
+	if a.IdempotencyKey == "" {
+		return false, client.Send(a.Name, input, false)
+	}
+	key := a.IdempotencyKey
+	if count > 1 {
+		key = fmt.Sprintf("%s/%d", a.IdempotencyKey, i+1)
+	}
+	return client.SendOnce(a.Name, input, false, key)
+}
+
+func (r *ToolRegistry) callSend(args json.RawMessage) (*CallToolResult, error) {
+	var a SendArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	// Validate mutually exclusive input options
+	inputCount := 0
+	if a.Input != "" {
+		inputCount++
+	}
+	if len(a.Inputs) > 0 {
+		inputCount++
+	}
+	if a.InputBase64 != "" {
+		inputCount++
+	}
+	if inputCount == 0 {
+		return nil, fmt.Errorf("one of input, inputs, or input_base64 is required")
+	}
+	if inputCount > 1 {
+		return nil, fmt.Errorf("input, inputs, and input_base64 are mutually exclusive")
+	}
+
+	// Handle base64 input (no escape interpretation, single write)
+	if a.InputBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(a.InputBase64)
+		if err != nil {
+			return nil, fmt.Errorf("decode input_base64: %w", err)
+		}
+		dup, err := a.send(r.client, 0, 1, string(decoded))
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{
+			"status": "sent",
+			"count":  1,
+			"bytes":  len(decoded),
+		}
+		if a.IdempotencyKey != "" {
+			result["duplicates"] = 0
+			if dup {
+				result["duplicates"] = 1
+				result["bytes"] = 0
+			}
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	// Build list of inputs to send
+	var inputs []string
+	if len(a.Inputs) > 0 {
+		inputs = a.Inputs
+	} else {
+		inputs = []string{a.Input}
+	}
+
+	// Send each input as separate write, with escape interpretation
+	totalBytes, duplicates := 0, 0
+	for i, input := range inputs {
+		processed, err := escape.Interpret(input)
+		if err != nil {
+			return nil, fmt.Errorf("interpret escape sequences: %w", err)
+		}
+
+		dup, err := a.send(r.client, i, len(inputs), processed)
+		if err != nil {
+			return nil, err
+		}
+		if dup {
+			duplicates++
+			continue
+		}
+		totalBytes += len(processed)
+	}
+
+	result := map[string]interface{}{
+		"status": "sent",
+		"count":  len(inputs),
+		"bytes":  totalBytes,
+	}
+	if a.IdempotencyKey != "" {
+		result["duplicates"] = duplicates
+	}
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
 }
 
-func (r *ToolRegistry) Call(name string, args json.RawMessage) (*CallToolResult, error) {
-	if err := r.client.EnsureDaemon(); err != nil {
-		return nil, fmt.Errorf("daemon: %w", err)
+type ReadArgs struct {
+	Name             string `json:"name"`
+	All              bool   `json:"all"`
+	Head             int    `json:"head"`
+	Tail             int    `json:"tail"`
+	WaitPattern      string `json:"wait_pattern"`
+	WaitPrompt       bool   `json:"wait_prompt"`
+	SettleMs         int    `json:"settle_ms"`
+	TimeoutSec       int    `json:"timeout_sec"`
+	StripAnsi        bool   `json:"strip_ansi"`
+	Render           bool   `json:"render"`
+	Snapshot         bool   `json:"snapshot"`
+	HoldSize         bool   `json:"hold_size"`
+	Cursor           string `json:"cursor"`
+	Frame            int    `json:"frame"`
+	ScreenScrollback bool   `json:"screen_scrollback"`
+	Offset           *int   `json:"offset"`
+	Limit            int    `json:"limit"`
+	Newlines         string `json:"newlines"`
+	TailBytes        int    `json:"tail_bytes"`
+	Since            string `json:"since"`
+	AroundBookmark   string `json:"around_bookmark"`
+	Context          *int   `json:"context"`
+	Lines            bool   `json:"lines"`
+	MaxBytes         int64  `json:"max_bytes"`
+	MaxLines         int    `json:"max_lines"`
+	Continue         string `json:"continue"`
+}
+
+func (r *ToolRegistry) callRead(args json.RawMessage) (*CallToolResult, error) {
+	var a ReadArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	modeCount := 0
+	if a.All {
+		modeCount++
+	}
+	if a.Head > 0 {
+		modeCount++
+	}
+	if a.Tail > 0 {
+		modeCount++
+	}
+	if modeCount > 1 {
+		return nil, fmt.Errorf("all, head, and tail are mutually exclusive")
+	}
+
+	if a.Head < 0 || a.Tail < 0 {
+		return nil, fmt.Errorf("head and tail require positive integers")
+	}
+
+	if a.WaitPattern != "" && a.SettleMs > 0 {
+		return nil, fmt.Errorf("wait_pattern and settle_ms are mutually exclusive")
+	}
+
+	if a.WaitPrompt && (a.WaitPattern != "" || a.SettleMs > 0) {
+		return nil, fmt.Errorf("wait_prompt cannot be combined with wait_pattern or settle_ms")
+	}
+
+	if a.All && (a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt) {
+		return nil, fmt.Errorf("all cannot be combined with wait_pattern, settle_ms, or wait_prompt")
+	}
+
+	if a.Cursor != "" && a.Snapshot {
+		return nil, fmt.Errorf("cursor and snapshot are mutually exclusive")
+	}
+
+	if a.Render && (a.Frame != 0 || a.ScreenScrollback || a.Snapshot) {
+		return nil, fmt.Errorf("render cannot be combined with frame, screen_scrollback, or snapshot")
+	}
+
+	if err := daemon.ValidateNewlines(a.Newlines); err != nil {
+		return nil, err
+	}
+	if a.Newlines != "" && (a.Render || a.Frame != 0 || a.ScreenScrollback || a.Snapshot) {
+		return nil, fmt.Errorf("newlines cannot be combined with render, frame, screen_scrollback, or snapshot")
+	}
+
+	if a.MaxBytes != 0 || a.MaxLines != 0 || a.Continue != "" {
+		if a.MaxBytes < 0 || a.MaxLines < 0 {
+			return nil, fmt.Errorf("max_bytes and max_lines must not be negative")
+		}
+		if a.Head > 0 || a.Tail > 0 || a.Limit != 0 || a.Lines || a.Since != "" || a.AroundBookmark != "" || a.TailBytes != 0 || a.Snapshot || a.Frame != 0 || a.ScreenScrollback || a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt || a.Newlines != "" {
+			return nil, fmt.Errorf("max_bytes, max_lines, and continue cannot be combined with head, tail, limit, lines, since, around_bookmark, tail_bytes, snapshot, frame, screen_scrollback, wait_pattern, settle_ms, wait_prompt, or newlines")
+		}
+		if a.Continue != "" && (a.All || a.Offset != nil || a.Cursor != "") {
+			return nil, fmt.Errorf("continue cannot be combined with all, offset, or cursor")
+		}
+		mode := daemon.ReadModeNew
+		var offset int64
+		if a.Offset != nil {
+			if a.All || a.Cursor != "" {
+				return nil, fmt.Errorf("offset cannot be combined with all or cursor")
+			}
+			if *a.Offset < 0 {
+				return nil, fmt.Errorf("offset must not be negative")
+			}
+			mode, offset = daemon.ReadModeRange, int64(*a.Offset)
+		} else if a.All {
+			mode = daemon.ReadModeAll
+		}
+
+		read, err := r.client.ReadPage(a.Name, daemon.PageOptions{
+			Mode:     mode,
+			Cursor:   a.Cursor,
+			Offset:   offset,
+			MaxBytes: a.MaxBytes,
+			MaxLines: a.MaxLines,
+			Continue: a.Continue,
+		})
+		if err != nil {
+			return nil, err
+		}
+		output := read.Output
+		if a.Render {
+			if output, err = r.renderOutput(a.Name, output); err != nil {
+				return nil, err
+			}
+		} else if a.StripAnsi {
+			output = vterm.StripDefault(output)
+		}
+
+		result := map[string]interface{}{
+			"output":   output,
+			"position": read.Position,
+			"more":     read.More,
+		}
+		if read.Next != "" {
+			result["next"] = read.Next
+		}
+		if read.Truncations > 0 {
+			result["truncations_since_last_read"] = read.Truncations
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	if a.Lines {
+		if a.Since != "" || a.AroundBookmark != "" || a.TailBytes != 0 || a.Offset != nil || a.Snapshot || a.Frame != 0 || a.ScreenScrollback || a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt || a.Render {
+			return nil, fmt.Errorf("lines cannot be combined with since, around_bookmark, tail_bytes, offset, snapshot, frame, screen_scrollback, wait_pattern, settle_ms, wait_prompt, or render")
+		}
+		mode := daemon.ReadModeNew
+		if a.All || a.Head > 0 || a.Tail > 0 {
+			mode = daemon.ReadModeAll
+		}
+		result, err := r.client.ReadLines(a.Name, mode, a.Cursor, a.Newlines, a.Head, a.Tail)
+		if err != nil {
+			return nil, err
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	if a.Since != "" {
+		if a.All || a.TailBytes != 0 || a.Offset != nil || a.Snapshot || a.Cursor != "" || a.Frame != 0 || a.ScreenScrollback || a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt {
+			return nil, fmt.Errorf("since cannot be combined with all, tail_bytes, offset, snapshot, cursor, frame, screen_scrollback, wait_pattern, settle_ms, or wait_prompt")
+		}
+		since, err := daemon.ParseSince(a.Since, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		output, pos, err := r.client.ReadSince(a.Name, since)
+		if err != nil {
+			return nil, err
+		}
+		output = daemon.NormalizeNewlines(output, a.Newlines)
+		if a.Head > 0 || a.Tail > 0 {
+			output = daemon.LimitLines(output, a.Head, a.Tail)
+		}
+		if a.Render {
+			if output, err = r.renderOutput(a.Name, output); err != nil {
+				return nil, err
+			}
+		} else if a.StripAnsi {
+			output = vterm.StripDefault(output)
+		}
+
+		result := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+			"since":    since.Format(time.RFC3339Nano),
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	if a.AroundBookmark != "" {
+		if a.Since != "" || a.All || a.TailBytes != 0 || a.Offset != nil || a.Snapshot || a.Cursor != "" || a.Frame != 0 || a.ScreenScrollback || a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt {
+			return nil, fmt.Errorf("around_bookmark cannot be combined with since, all, tail_bytes, offset, snapshot, cursor, frame, screen_scrollback, wait_pattern, settle_ms, or wait_prompt")
+		}
+		lines := 20
+		if a.Context != nil {
+			lines = *a.Context
+		}
+		if lines < 0 {
+			return nil, fmt.Errorf("context must not be negative")
+		}
+
+		output, pos, bookmark, err := r.client.ReadAroundBookmark(a.Name, a.AroundBookmark, lines, lines)
+		if err != nil {
+			return nil, err
+		}
+		output = daemon.NormalizeNewlines(output, a.Newlines)
+		if a.Head > 0 || a.Tail > 0 {
+			output = daemon.LimitLines(output, a.Head, a.Tail)
+		}
+		if a.Render {
+			if output, err = r.renderOutput(a.Name, output); err != nil {
+				return nil, err
+			}
+		} else if a.StripAnsi {
+			output = vterm.StripDefault(output)
+		}
+
+		result := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+			"bookmark": bookmark,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+	if a.Context != nil {
+		return nil, fmt.Errorf("context requires around_bookmark")
+	}
+
+	if a.TailBytes < 0 {
+		return nil, fmt.Errorf("tail_bytes must not be negative")
+	}
+	if a.TailBytes > 0 {
+		if a.All || a.Head > 0 || a.Tail > 0 || a.Offset != nil || a.Snapshot || a.Cursor != "" || a.Frame != 0 || a.ScreenScrollback || a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt || a.Newlines != "" {
+			return nil, fmt.Errorf("tail_bytes cannot be combined with all, head, tail, offset, snapshot, cursor, frame, screen_scrollback, wait_pattern, settle_ms, wait_prompt, or newlines")
+		}
+
+		output, pos, err := r.client.ReadTailBytes(a.Name, a.TailBytes)
+		if err != nil {
+			return nil, err
+		}
+		if a.Render {
+			if output, err = r.renderOutput(a.Name, output); err != nil {
+				return nil, err
+			}
+		} else if a.StripAnsi {
+			output = vterm.StripDefault(output)
+		}
+
+		result := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	if a.Offset != nil {
+		if a.All || a.Snapshot || a.Cursor != "" || a.Frame != 0 || a.ScreenScrollback || a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt {
+			return nil, fmt.Errorf("offset cannot be combined with all, snapshot, cursor, frame, screen_scrollback, wait_pattern, settle_ms, or wait_prompt")
+		}
+		if *a.Offset < 0 || a.Limit < 0 {
+			return nil, fmt.Errorf("offset and limit must not be negative")
+		}
+
+		output, pos, err := r.client.ReadRange(a.Name, *a.Offset, a.Limit)
+		if err != nil {
+			return nil, err
+		}
+		output = daemon.NormalizeNewlines(output, a.Newlines)
+		if a.Head > 0 || a.Tail > 0 {
+			output = daemon.LimitLines(output, a.Head, a.Tail)
+		}
+		if a.Render {
+			if output, err = r.renderOutput(a.Name, output); err != nil {
+				return nil, err
+			}
+		} else if a.StripAnsi {
+			output = vterm.StripDefault(output)
+		}
+
+		result := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	if a.Frame != 0 {
+		if a.All || a.Snapshot || a.Cursor != "" || a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt {
+			return nil, fmt.Errorf("frame cannot be combined with all, snapshot, cursor, wait_pattern, settle_ms, or wait_prompt")
+		}
+
+		output, pos, err := r.client.ReadFrame(a.Name, a.Frame, a.Head, a.Tail)
+		if err != nil {
+			return nil, err
+		}
+
+		if a.StripAnsi {
+			output = vterm.StripDefault(output)
+		}
+
+		result := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+			"frame":    a.Frame,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	if a.ScreenScrollback {
+		if a.All || a.Snapshot || a.Cursor != "" || a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt {
+			return nil, fmt.Errorf("screen_scrollback cannot be combined with all, snapshot, cursor, wait_pattern, settle_ms, or wait_prompt")
+		}
+
+		output, pos, err := r.client.ReadScrollback(a.Name, a.Head, a.Tail)
+		if err != nil {
+			return nil, err
+		}
+
+		if a.StripAnsi {
+			output = vterm.StripDefault(output)
+		}
+
+		result := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	if a.Snapshot {
+		if a.All {
+			return nil, fmt.Errorf("snapshot and all are mutually exclusive")
+		}
+		if a.WaitPattern != "" {
+			return nil, fmt.Errorf("snapshot and wait_pattern are mutually exclusive")
+		}
+
+		snap, err := r.client.SnapshotDetailed(a.Name, a.SettleMs, a.TimeoutSec, a.Head, a.Tail, a.HoldSize)
+		if err != nil {
+			return nil, err
+		}
+
+		output := snap.Output
+		if a.StripAnsi {
+			output = vterm.StripDefault(output)
+		}
+
+		result := map[string]interface{}{
+			"output":         output,
+			"position":       snap.Position,
+			"settled":        snap.Settled,
+			"waited_ms":      snap.WaitedMs,
+			"resize_cycles":  snap.ResizeCycles,
+			"bytes_captured": snap.BytesCaptured,
+		}
+		if snap.FromFrame {
+			result["from_frame"] = true
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
 	}
-	for _, e := range r.entries {
-		if e.def.Name == name {
-			return e.handler(args)
-		}
+
+	mode := daemon.ReadModeNew
+	if a.All || a.Head > 0 || a.Tail > 0 {
+		mode = daemon.ReadModeAll
 	}
-	return nil, fmt.Errorf("unknown tool: %s", name)
-}
 
-type CreateArgs struct {
-	Name        string   `json:"name"`
-	Command     string   `json:"command"`
-	Env         []string `json:"env"`
-	Cwd         string   `json:"cwd"`
-	Cols        int      `json:"cols"`
-	Rows        int      `json:"rows"`
-	TUI         bool     `json:"tui"`
-	IfNotExists bool     `json:"if_not_exists"`
-}
+	if a.WaitPattern != "" || a.SettleMs > 0 || a.WaitPrompt {
+		_, startPos, err := r.client.Read(a.Name, "all", 0, 0)
+		if err != nil {
+			return nil, err
+		}
 
-func (r *ToolRegistry) callCreate(args json.RawMessage) (*CallToolResult, error) {
-	var a CreateArgs
-	if err := json.Unmarshal(args, &a); err != nil {
-		return nil, fmt.Errorf("parse args: %w", err)
+		timeoutSec := a.TimeoutSec
+		if timeoutSec == 0 {
+			timeoutSec = 10
+		}
+
+		output, pos, err := wait.ForOutput(
+			func() (string, int, error) { return r.client.Read(a.Name, "all", 0, 0) },
+			wait.Config{
+				Pattern:       a.WaitPattern,
+				SettleMs:      a.SettleMs,
+				TimeoutSec:    timeoutSec,
+				StartPosition: startPos,
+				SizeFunc:      func() (int, error) { return r.client.Size(a.Name) },
+				MatchFilter:   daemon.RemoveInputMirror,
+				WaitForPrompt: a.WaitPrompt,
+				CursorFunc:    func() (string, int, error) { return r.client.CursorLine(a.Name) },
+			},
+		)
+
+		var warning string
+		if err != nil {
+			if output == "" {
+				return nil, err
+			}
+			warning = err.Error()
+		}
+
+		var truncations int
+		if warning == "" {
+			if advanced, err := r.client.ReadDetailed(a.Name, "new", a.Cursor, 0, 0); err == nil {
+				truncations = advanced.Truncations
+			}
+		}
+
+		output = daemon.NormalizeNewlines(output, a.Newlines)
+		if a.Head > 0 || a.Tail > 0 {
+			output = daemon.LimitLines(output, a.Head, a.Tail)
+		}
+
+		if a.Render {
+			if output, err = r.renderOutput(a.Name, output); err != nil {
+				return nil, err
+			}
+		} else if a.StripAnsi {
+			output = vterm.StripDefault(output)
+		}
+
+		result := map[string]interface{}{
+			"output":   output,
+			"position": pos,
+		}
+		if warning != "" {
+			result["warning"] = warning
+		}
+		if truncations > 0 {
+			result["truncations_since_last_read"] = truncations
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+			IsError: warning != "",
+		}, nil
 	}
 
-	data, err := r.client.Create(a.Name, daemon.CreateOptions{
-		Command:     a.Command,
-		Env:         a.Env,
-		Cwd:         a.Cwd,
-		Cols:        a.Cols,
-		Rows:        a.Rows,
-		TUIMode:     a.TUI,
-		IfNotExists: a.IfNotExists,
-	})
+	read, err := r.client.ReadNormalized(a.Name, mode, a.Cursor, a.Newlines, a.Head, a.Tail)
 	if err != nil {
 		return nil, err
 	}
+	output := read.Output
 
-	output, _ := json.MarshalIndent(data, "", "  ")
+	if a.Render {
+		if output, err = r.renderOutput(a.Name, output); err != nil {
+			return nil, err
+		}
+	} else if a.StripAnsi {
+		output = vterm.StripDefault(output)
+	}
+
+	result := map[string]interface{}{
+		"output":   output,
+		"position": read.Position,
+	}
+	if read.Truncations > 0 {
+		result["truncations_since_last_read"] = read.Truncations
+	}
+	data, _ := json.MarshalIndent(result, "", "  ")
 	return &CallToolResult{
-		Content: []ContentBlock{{Type: "text", Text: string(output)}},
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
 	}, nil
 }
 
-type ExecArgs struct {
-	Name        string `json:"name"`
-	Input       string `json:"input"`
-	SettleMs    *int   `json:"settle_ms"`
-	WaitPattern string `json:"wait_pattern"`
-	TimeoutSec  int    `json:"timeout_sec"`
-	StripAnsi   bool   `json:"strip_ansi"`
-}
-
-func (r *ToolRegistry) callExec(args json.RawMessage) (*CallToolResult, error) {
-	var a ExecArgs
-	if err := json.Unmarshal(args, &a); err != nil {
-		return nil, fmt.Errorf("parse args: %w", err)
+// renderOutput renders output at the session's terminal width.
+func (r *ToolRegistry) renderOutput(name, output string) (string, error) {
+	info, err := r.client.Info(name)
+	if err != nil {
+		return "", err
 	}
+	return vterm.Render(output, info.Cols), nil
+}
 
-	if a.WaitPattern != "" && a.SettleMs != nil && *a.SettleMs > 0 {
-		return nil, fmt.Errorf("wait_pattern and settle_ms are mutually exclusive")
-	}
+type ListArgs struct {
+	Here    bool `json:"here,omitempty"`
+	Verbose bool `json:"verbose,omitempty"`
+}
 
-	if a.Input == "" {
-		return nil, fmt.Errorf("input is required")
+func (r *ToolRegistry) callList(args json.RawMessage) (*CallToolResult, error) {
+	var a ListArgs
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, fmt.Errorf("parse args: %w", err)
+		}
 	}
 
-	settleMs := 0
-	if a.SettleMs != nil {
-		settleMs = *a.SettleMs
+	list := r.client.List
+	if a.Verbose {
+		list = r.client.ListVerbose
 	}
-
-	result, err := r.client.Exec(a.Name, daemon.ExecOptions{
-		Input:       a.Input,
-		SettleMs:    settleMs,
-		WaitPattern: a.WaitPattern,
-		TimeoutSec:  a.TimeoutSec,
-		SettleSet:   a.SettleMs != nil,
-	})
+	sessions, err := list()
 	if err != nil {
-		if result == nil || result.Output == "" {
-			return nil, err
-		}
-		output := result.Output
-		if a.StripAnsi {
-			output = vterm.StripDefault(output)
-		}
-		data, _ := json.MarshalIndent(map[string]interface{}{
-			"input":    result.Input,
-			"output":   output,
-			"position": result.Position,
-			"warning":  err.Error(),
-		}, "", "  ")
-		return &CallToolResult{
-			Content: []ContentBlock{{Type: "text", Text: string(data)}},
-			IsError: true,
-		}, nil
+		return nil, err
 	}
 
-	output := result.Output
-	if a.StripAnsi {
-		output = vterm.StripDefault(output)
+	if a.Here {
+		workspace := daemon.CurrentWorkspace()
+		if workspace == "" {
+			return nil, fmt.Errorf("here: MCP server is not running inside a git repository")
+		}
+		sessions = daemon.FilterWorkspace(sessions, workspace)
 	}
 
-	data, _ := json.MarshalIndent(map[string]interface{}{
-		"input":    result.Input,
-		"output":   output,
-		"position": result.Position,
-	}, "", "  ")
+	data, _ := json.MarshalIndent(sessions, "", "  ")
 	return &CallToolResult{
 		Content: []ContentBlock{{Type: "text", Text: string(data)}},
 	}, nil
 }
 
-type SendArgs struct {
-	Name        string   `json:"name"`
-	Input       string   `json:"input"`
-	Inputs      []string `json:"inputs"`
-	InputBase64 string   `json:"input_base64"`
+type StopArgs struct {
+	Name   string `json:"name"`
+	DryRun bool   `json:"dry_run"`
 }
 
-func (r *ToolRegistry) callSend(args json.RawMessage) (*CallToolResult, error) {
-	var a SendArgs
+func (r *ToolRegistry) callStop(args json.RawMessage) (*CallToolResult, error) {
+	var a StopArgs
 	if err := json.Unmarshal(args, &a); err != nil {
 		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	// Validate mutually exclusive input options
-	inputCount := 0
-	if a.Input != "" {
-		inputCount++
+	if a.DryRun {
+		return r.dryRun("stop", a.Name)
 	}
-	if len(a.Inputs) > 0 {
-		inputCount++
+	if err := r.client.Stop(a.Name); err != nil {
+		return nil, err
 	}
-	if a.InputBase64 != "" {
-		inputCount++
+
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("session %q stopped", a.Name)}},
+	}, nil
+}
+
+type KillArgs struct {
+	Name   string `json:"name"`
+	DryRun bool   `json:"dry_run"`
+}
+
+func (r *ToolRegistry) callKill(args json.RawMessage) (*CallToolResult, error) {
+	var a KillArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
 	}
-	if inputCount == 0 {
-		return nil, fmt.Errorf("one of input, inputs, or input_base64 is required")
+
+	if a.DryRun {
+		return r.dryRun("kill", a.Name)
 	}
-	if inputCount > 1 {
-		return nil, fmt.Errorf("input, inputs, and input_base64 are mutually exclusive")
+	if err := r.client.Kill(a.Name); err != nil {
+		return nil, err
 	}
 
-	// Handle base64 input (no escape interpretation, single write)
-	if a.InputBase64 != "" {
-		decoded, err := base64.StdEncoding.DecodeString(a.InputBase64)
-		if err != nil {
-			return nil, fmt.Errorf("decode input_base64: %w", err)
-		}
-		if err := r.client.Send(a.Name, string(decoded), false); err != nil {
-			return nil, err
-		}
-		result := map[string]interface{}{
-			"status": "sent",
-			"count":  1,
-			"bytes":  len(decoded),
-		}
-		data, _ := json.MarshalIndent(result, "", "  ")
-		return &CallToolResult{
-			Content: []ContentBlock{{Type: "text", Text: string(data)}},
-		}, nil
-	}
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("session %q killed", a.Name)}},
+	}, nil
+}
 
-	// Build list of inputs to send
-	var inputs []string
-	if len(a.Inputs) > 0 {
-		inputs = a.Inputs
-	} else {
-		inputs = []string{a.Input}
+// dryRun returns the Impact of action on session name, for the dry_run
+// parameter of stop, kill and clear.
+func (r *ToolRegistry) dryRun(action, name string) (*CallToolResult, error) {
+	impact, err := r.client.DryRun(action, name)
+	if err != nil {
+		return nil, err
 	}
+	data, _ := json.MarshalIndent(impact, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
 
-	// Send each input as separate write, with escape interpretation
-	totalBytes := 0
-	for _, input := range inputs {
-		processed, err := escape.Interpret(input)
-		if err != nil {
-			return nil, fmt.Errorf("interpret escape sequences: %w", err)
-		}
+type InfoArgs struct {
+	Name string `json:"name"`
+}
 
-		if err := r.client.Send(a.Name, processed, false); err != nil {
-			return nil, err
-		}
-		totalBytes += len(processed)
+func (r *ToolRegistry) callInfo(args json.RawMessage) (*CallToolResult, error) {
+	var a InfoArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	result := map[string]interface{}{
-		"status": "sent",
-		"count":  len(inputs),
-		"bytes":  totalBytes,
+	info, err := r.client.Info(a.Name)
+	if err != nil {
+		return nil, err
 	}
-	data, _ := json.MarshalIndent(result, "", "  ")
+
+	data, _ := json.MarshalIndent(info, "", "  ")
 	return &CallToolResult{
 		Content: []ContentBlock{{Type: "text", Text: string(data)}},
 	}, nil
 }
 
-type ReadArgs struct {
-	Name        string `json:"name"`
-	All         bool   `json:"all"`
-	Head        int    `json:"head"`
-	Tail        int    `json:"tail"`
-	WaitPattern string `json:"wait_pattern"`
-	SettleMs    int    `json:"settle_ms"`
-	TimeoutSec  int    `json:"timeout_sec"`
-	StripAnsi   bool   `json:"strip_ansi"`
-	Snapshot    bool   `json:"snapshot"`
-	Cursor      string `json:"cursor"`
+type ClearArgs struct {
+	Name   string `json:"name"`
+	DryRun bool   `json:"dry_run"`
+}
+
+func (r *ToolRegistry) callClear(args json.RawMessage) (*CallToolResult, error) {
+	var a ClearArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	if a.DryRun {
+		return r.dryRun("clear", a.Name)
+	}
+	if err := r.client.Clear(a.Name); err != nil {
+		return nil, err
+	}
+
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("session %q cleared", a.Name)}},
+	}, nil
+}
+
+type MirrorInputArgs struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
 }
 
-func (r *ToolRegistry) callRead(args json.RawMessage) (*CallToolResult, error) {
-	var a ReadArgs
+func (r *ToolRegistry) callMirrorInput(args json.RawMessage) (*CallToolResult, error) {
+	var a MirrorInputArgs
 	if err := json.Unmarshal(args, &a); err != nil {
 		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	modeCount := 0
-	if a.All {
-		modeCount++
-	}
-	if a.Head > 0 {
-		modeCount++
-	}
-	if a.Tail > 0 {
-		modeCount++
-	}
-	if modeCount > 1 {
-		return nil, fmt.Errorf("all, head, and tail are mutually exclusive")
+	if err := r.client.MirrorInput(a.Name, a.Enabled); err != nil {
+		return nil, err
 	}
 
-	if a.Head < 0 || a.Tail < 0 {
-		return nil, fmt.Errorf("head and tail require positive integers")
+	state := "off"
+	if a.Enabled {
+		state = "on"
 	}
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("input mirroring for session %q is %s", a.Name, state)}},
+	}, nil
+}
 
-	if a.WaitPattern != "" && a.SettleMs > 0 {
-		return nil, fmt.Errorf("wait_pattern and settle_ms are mutually exclusive")
-	}
+type PauseArgs struct {
+	Name string `json:"name"`
+}
 
-	if a.All && (a.WaitPattern != "" || a.SettleMs > 0) {
-		return nil, fmt.Errorf("all cannot be combined with wait_pattern or settle_ms")
+func (r *ToolRegistry) callPause(args json.RawMessage) (*CallToolResult, error) {
+	var a PauseArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	if a.Cursor != "" && a.Snapshot {
-		return nil, fmt.Errorf("cursor and snapshot are mutually exclusive")
+	if err := r.client.Pause(a.Name); err != nil {
+		return nil, err
 	}
 
-	if a.Snapshot {
-		if a.All {
-			return nil, fmt.Errorf("snapshot and all are mutually exclusive")
-		}
-		if a.WaitPattern != "" {
-			return nil, fmt.Errorf("snapshot and wait_pattern are mutually exclusive")
-		}
-
-		output, pos, err := r.client.Snapshot(a.Name, a.SettleMs, a.TimeoutSec, a.Head, a.Tail)
-		if err != nil {
-			return nil, err
-		}
-
-		if a.StripAnsi {
-			output = vterm.StripDefault(output)
-		}
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("output capture of session %q paused", a.Name)}},
+	}, nil
+}
 
-		result := map[string]interface{}{
-			"output":   output,
-			"position": pos,
-		}
-		data, _ := json.MarshalIndent(result, "", "  ")
-		return &CallToolResult{
-			Content: []ContentBlock{{Type: "text", Text: string(data)}},
-		}, nil
+func (r *ToolRegistry) callResume(args json.RawMessage) (*CallToolResult, error) {
+	var a PauseArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	mode := daemon.ReadModeNew
-	if a.All || a.Head > 0 || a.Tail > 0 {
-		mode = daemon.ReadModeAll
+	result, err := r.client.Resume(a.Name)
+	if err != nil {
+		return nil, err
 	}
 
-	if a.WaitPattern != "" || a.SettleMs > 0 {
-		_, startPos, err := r.client.Read(a.Name, "all", 0, 0)
-		if err != nil {
-			return nil, err
-		}
-
-		timeoutSec := a.TimeoutSec
-		if timeoutSec == 0 {
-			timeoutSec = 10
-		}
-
-		output, pos, err := wait.ForOutput(
-			func() (string, int, error) { return r.client.Read(a.Name, "all", 0, 0) },
-			wait.Config{
-				Pattern:       a.WaitPattern,
-				SettleMs:      a.SettleMs,
-				TimeoutSec:    timeoutSec,
-				StartPosition: startPos,
-				SizeFunc:      func() (int, error) { return r.client.Size(a.Name) },
-			},
-		)
-
-		var warning string
-		if err != nil {
-			if output == "" {
-				return nil, err
-			}
-			warning = err.Error()
-		}
-
-		if warning == "" {
-			if a.Cursor != "" {
-				r.client.ReadWithCursor(a.Name, "new", a.Cursor, 0, 0)
-			} else {
-				r.client.Read(a.Name, "new", 0, 0)
-			}
-		}
-
-		if a.Head > 0 || a.Tail > 0 {
-			output = daemon.LimitLines(output, a.Head, a.Tail)
-		}
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
 
-		if a.StripAnsi {
-			output = vterm.StripDefault(output)
-		}
+type FreezeArgs struct {
+	Name string `json:"name"`
+}
 
-		result := map[string]interface{}{
-			"output":   output,
-			"position": pos,
-		}
-		if warning != "" {
-			result["warning"] = warning
-		}
-		data, _ := json.MarshalIndent(result, "", "  ")
-		return &CallToolResult{
-			Content: []ContentBlock{{Type: "text", Text: string(data)}},
-			IsError: warning != "",
-		}, nil
+func (r *ToolRegistry) callFreeze(args json.RawMessage) (*CallToolResult, error) {
+	var a FreezeArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	var output string
-	var pos int
-	var err error
-	if a.Cursor != "" {
-		output, pos, err = r.client.ReadWithCursor(a.Name, mode, a.Cursor, a.Head, a.Tail)
-	} else {
-		output, pos, err = r.client.Read(a.Name, mode, a.Head, a.Tail)
-	}
+	result, err := r.client.Freeze(a.Name)
 	if err != nil {
 		return nil, err
 	}
 
-	if a.StripAnsi {
-		output = vterm.StripDefault(output)
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+func (r *ToolRegistry) callThaw(args json.RawMessage) (*CallToolResult, error) {
+	var a FreezeArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	result := map[string]interface{}{
-		"output":   output,
-		"position": pos,
+	result, err := r.client.Thaw(a.Name)
+	if err != nil {
+		return nil, err
 	}
+
 	data, _ := json.MarshalIndent(result, "", "  ")
 	return &CallToolResult{
 		Content: []ContentBlock{{Type: "text", Text: string(data)}},
 	}, nil
 }
 
-func (r *ToolRegistry) callList() (*CallToolResult, error) {
-	sessions, err := r.client.List()
+type ActivityArgs struct {
+	Name       string `json:"name"`
+	IdleMs     int    `json:"idle_ms"`
+	Wait       bool   `json:"wait"`
+	TimeoutSec int    `json:"timeout_sec"`
+}
+
+func (r *ToolRegistry) callActivity(args json.RawMessage) (*CallToolResult, error) {
+	var a ActivityArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+	if a.IdleMs < 0 {
+		return nil, fmt.Errorf("idle_ms must not be negative")
+	}
+	idle := time.Duration(a.IdleMs) * time.Millisecond
+	if a.TimeoutSec <= 0 {
+		a.TimeoutSec = 60
+	}
+
+	var result *daemon.ActivityResult
+	var err error
+	if a.Wait {
+		result, err = r.client.WaitIdle(a.Name, idle, time.Duration(a.TimeoutSec)*time.Second)
+	} else {
+		result, err = r.client.Activity(a.Name, idle)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	data, _ := json.MarshalIndent(sessions, "", "  ")
+	data, _ := json.MarshalIndent(result, "", "  ")
 	return &CallToolResult{
 		Content: []ContentBlock{{Type: "text", Text: string(data)}},
 	}, nil
 }
 
-type StopArgs struct {
+type CompactArgs struct {
 	Name string `json:"name"`
 }
 
-func (r *ToolRegistry) callStop(args json.RawMessage) (*CallToolResult, error) {
-	var a StopArgs
+func (r *ToolRegistry) callCompact(args json.RawMessage) (*CallToolResult, error) {
+	var a CompactArgs
 	if err := json.Unmarshal(args, &a); err != nil {
 		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	if err := r.client.Stop(a.Name); err != nil {
+	before, after, err := r.client.Compact(a.Name)
+	if err != nil {
 		return nil, err
 	}
 
 	return &CallToolResult{
-		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("session %q stopped", a.Name)}},
+		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("session %q compacted: %d -> %d bytes", a.Name, before, after)}},
 	}, nil
 }
 
-type KillArgs struct {
-	Name string `json:"name"`
+type FitArgs struct {
+	Name    string `json:"name"`
+	MaxCols int    `json:"max_cols"`
+	MaxRows int    `json:"max_rows"`
 }
 
-func (r *ToolRegistry) callKill(args json.RawMessage) (*CallToolResult, error) {
-	var a KillArgs
+func (r *ToolRegistry) callFit(args json.RawMessage) (*CallToolResult, error) {
+	var a FitArgs
 	if err := json.Unmarshal(args, &a); err != nil {
 		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	if err := r.client.Kill(a.Name); err != nil {
+	result, err := r.client.Fit(a.Name, a.MaxCols, a.MaxRows)
+	if err != nil {
 		return nil, err
 	}
 
+	data, _ := json.MarshalIndent(result, "", "  ")
 	return &CallToolResult{
-		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("session %q killed", a.Name)}},
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
 	}, nil
 }
 
-type InfoArgs struct {
+type DescribeArgs struct {
 	Name string `json:"name"`
 }
 
-func (r *ToolRegistry) callInfo(args json.RawMessage) (*CallToolResult, error) {
-	var a InfoArgs
+func (r *ToolRegistry) callDescribe(args json.RawMessage) (*CallToolResult, error) {
+	var a DescribeArgs
 	if err := json.Unmarshal(args, &a); err != nil {
 		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	info, err := r.client.Info(a.Name)
+	result, err := r.client.Describe(a.Name)
 	if err != nil {
 		return nil, err
 	}
 
-	data, _ := json.MarshalIndent(info, "", "  ")
+	data, _ := json.MarshalIndent(result, "", "  ")
 	return &CallToolResult{
 		Content: []ContentBlock{{Type: "text", Text: string(data)}},
 	}, nil
 }
 
-type ClearArgs struct {
-	Name string `json:"name"`
+type ScreenArgs struct {
+	Name   string `json:"name"`
+	Styled bool   `json:"styled"`
 }
 
-func (r *ToolRegistry) callClear(args json.RawMessage) (*CallToolResult, error) {
-	var a ClearArgs
+func (r *ToolRegistry) callScreen(args json.RawMessage) (*CallToolResult, error) {
+	var a ScreenArgs
 	if err := json.Unmarshal(args, &a); err != nil {
 		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
-	if err := r.client.Clear(a.Name); err != nil {
+	result, err := r.client.Screen(a.Name, a.Styled)
+	if err != nil {
 		return nil, err
 	}
 
+	data, _ := json.MarshalIndent(result, "", "  ")
 	return &CallToolResult{
-		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("session %q cleared", a.Name)}},
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
 	}, nil
 }
 
@@ -803,6 +2617,11 @@ type SearchArgs struct {
 	Around     int    `json:"around"`
 	IgnoreCase bool   `json:"ignore_case"`
 	StripAnsi  bool   `json:"strip_ansi"`
+	Newlines   string `json:"newlines"`
+	Offset     int    `json:"offset"`
+	Limit      int    `json:"limit"`
+	Continue   string `json:"continue"`
+	Render     bool   `json:"render"`
 }
 
 func (r *ToolRegistry) callSearch(args json.RawMessage) (*CallToolResult, error) {
@@ -811,9 +2630,15 @@ func (r *ToolRegistry) callSearch(args json.RawMessage) (*CallToolResult, error)
 		return nil, fmt.Errorf("parse args: %w", err)
 	}
 
+	if a.Pattern == "" && a.Continue == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
 	if a.Around > 0 && (a.Before > 0 || a.After > 0) {
 		return nil, fmt.Errorf("around is mutually exclusive with before/after")
 	}
+	if a.Offset < 0 || a.Limit < 0 {
+		return nil, fmt.Errorf("offset and limit must be non-negative")
+	}
 
 	before := a.Before
 	after := a.After
@@ -833,6 +2658,11 @@ func (r *ToolRegistry) callSearch(args json.RawMessage) (*CallToolResult, error)
 		After:      after,
 		IgnoreCase: a.IgnoreCase,
 		StripANSI:  a.StripAnsi,
+		Newlines:   a.Newlines,
+		Offset:     a.Offset,
+		Limit:      a.Limit,
+		Continue:   a.Continue,
+		Render:     a.Render,
 	})
 	if err != nil {
 		return nil, err
@@ -843,3 +2673,105 @@ func (r *ToolRegistry) callSearch(args json.RawMessage) (*CallToolResult, error)
 		Content: []ContentBlock{{Type: "text", Text: string(data)}},
 	}, nil
 }
+
+type ExtractArgs struct {
+	Name     string `json:"name"`
+	All      bool   `json:"all"`
+	JSONPath string `json:"json_path"`
+}
+
+func (r *ToolRegistry) callExtract(args json.RawMessage) (*CallToolResult, error) {
+	var a ExtractArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	mode := daemon.ReadModeNew
+	if a.All {
+		mode = daemon.ReadModeAll
+	}
+	output, pos, err := r.client.Read(a.Name, mode, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	values, err := daemon.ExtractJSONPath(output, a.JSONPath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(map[string]interface{}{
+		"values":   values,
+		"count":    len(values),
+		"position": pos,
+	}, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+type ImagesArgs struct {
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+}
+
+func (r *ToolRegistry) callImages(args json.RawMessage) (*CallToolResult, error) {
+	var a ImagesArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	if a.ID == 0 {
+		images, err := r.client.Images(a.Name)
+		if err != nil {
+			return nil, err
+		}
+		data, _ := json.MarshalIndent(images, "", "  ")
+		return &CallToolResult{
+			Content: []ContentBlock{{Type: "text", Text: string(data)}},
+		}, nil
+	}
+
+	img, err := r.client.Image(a.Name, a.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch img.Format {
+	case "png", "jpeg", "gif":
+		return &CallToolResult{
+			Content: []ContentBlock{{
+				Type:     "image",
+				Data:     base64.StdEncoding.EncodeToString(img.Data),
+				MimeType: "image/" + img.Format,
+			}},
+		}, nil
+	}
+
+	// Raw pixel or unknown formats can't be shown as an image block.
+	data, _ := json.MarshalIndent(img, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
+
+type NotificationsArgs struct {
+	Name    string `json:"name"`
+	AfterID int    `json:"after_id"`
+}
+
+func (r *ToolRegistry) callNotifications(args json.RawMessage) (*CallToolResult, error) {
+	var a NotificationsArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("parse args: %w", err)
+	}
+
+	notes, err := r.client.Notifications(a.Name, a.AfterID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(notes, "", "  ")
+	return &CallToolResult{
+		Content: []ContentBlock{{Type: "text", Text: string(data)}},
+	}, nil
+}
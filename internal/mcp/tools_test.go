@@ -4,7 +4,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/schovi/shelli/internal/daemon"
 )
 
 func TestExecArgsValidation(t *testing.T) {
@@ -188,3 +191,38 @@ func TestSendArgsJSONUnmarshal(t *testing.T) {
 		t.Errorf("got InputBase64 %q, want %q", args.InputBase64, "aGVsbG8=")
 	}
 }
+
+func TestExecResultMapTruncates(t *testing.T) {
+	output := "echo big\r\n" + strings.Repeat("0123456789\r\n", 10) + "$ "
+	result := &daemon.ExecResult{Input: "echo big", Output: output, Position: 100 + len(output)}
+	limit := 30
+
+	out := execResultMap(result, ExecArgs{MaxOutput: &limit})
+	shown := out["output"].(string)
+	if !strings.HasSuffix(output, shown) || len(shown) > limit {
+		t.Fatalf("output = %q, want a tail of at most %d bytes", shown, limit)
+	}
+	tr, ok := out["truncated"].(map[string]interface{})
+	if !ok {
+		t.Fatal("missing truncated")
+	}
+	if tr["offset"] != 100 || tr["omitted_bytes"].(int)+len(shown) != len(output) {
+		t.Errorf("truncated = %v", tr)
+	}
+
+	out = execResultMap(result, ExecArgs{MaxOutput: &limit, Keep: "head", Structured: true})
+	body := out["body"].(string)
+	if !strings.HasPrefix(body, "0123456789\r\n") || len(body) > limit {
+		t.Errorf("body = %q", body)
+	}
+	tr = out["truncated"].(map[string]interface{})
+	if want := 100 + len("echo big\r\n") + len(body); tr["offset"] != want {
+		t.Errorf("offset = %v, want %d", tr["offset"], want)
+	}
+
+	noLimit := -1
+	out = execResultMap(result, ExecArgs{MaxOutput: &noLimit})
+	if out["output"] != output || out["truncated"] != nil {
+		t.Errorf("unlimited output was truncated: %v", out)
+	}
+}
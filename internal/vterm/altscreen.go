@@ -0,0 +1,87 @@
+package vterm
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// altScreenSeq matches DEC private mode set/reset sequences, whose modes
+// 1049, 1047 and 47 switch to and from the alternate screen, and RIS, which
+// resets the terminal to the main screen.
+var altScreenSeq = regexp.MustCompile(`\x1b\[\?([0-9;]*)([hl])|\x1bc`)
+
+// maxAltScreenSeqLen bounds how much of an unfinished sequence at the end of
+// a write is held for the next one.
+const maxAltScreenSeqLen = 32
+
+// AltScreenTracker follows a stream of terminal output as it switches to and
+// from the alternate screen, across writes. The zero value starts on the
+// main screen.
+type AltScreenTracker struct {
+	active bool
+	held   []byte
+}
+
+// Active reports whether the stream is on the alternate screen.
+func (t *AltScreenTracker) Active() bool {
+	return t.active
+}
+
+// Process scans p and returns the switches it makes, in order: true for
+// entering the alternate screen, false for leaving it. Setting the mode the
+// screen is already in is not a switch.
+func (t *AltScreenTracker) Process(p []byte) []bool {
+	data := p
+	if len(t.held) > 0 {
+		data = append(t.held, p...)
+		t.held = nil
+	}
+
+	var switches []bool
+	for _, m := range altScreenSeq.FindAllSubmatchIndex(data, -1) {
+		active := false
+		if m[2] >= 0 {
+			if !altScreenMode(string(data[m[2]:m[3]])) {
+				continue
+			}
+			active = data[m[4]] == 'h'
+		}
+		if active != t.active {
+			t.active = active
+			switches = append(switches, active)
+		}
+	}
+
+	// Hold an escape sequence cut off by the end of the write.
+	if i := bytes.LastIndexByte(data, 0x1b); i >= 0 && len(data)-i < maxAltScreenSeqLen && unfinishedCSI(data[i:]) {
+		t.held = append([]byte(nil), data[i:]...)
+	}
+	return switches
+}
+
+func altScreenMode(params string) bool {
+	for _, p := range strings.Split(params, ";") {
+		if p == "1049" || p == "1047" || p == "47" {
+			return true
+		}
+	}
+	return false
+}
+
+// unfinishedCSI reports whether seq, starting at ESC, could still become an
+// alternate screen sequence.
+func unfinishedCSI(seq []byte) bool {
+	if len(seq) == 1 {
+		return true
+	}
+	if seq[1] != '[' {
+		return false
+	}
+	for _, b := range seq[2:] {
+		if b >= 0x40 && b <= 0x7e { // final byte
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package vterm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAltScreenTracker(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		switches []bool
+		active   bool
+	}{
+		{
+			name:   "plain output",
+			writes: []string{"hello \x1b[31mred\x1b[0m\r\n"},
+		},
+		{
+			name:     "enter and leave with 1049",
+			writes:   []string{"\x1b[?1049h\x1b[Hscreen", "\x1b[?1049l$ "},
+			switches: []bool{true, false},
+		},
+		{
+			name:     "older modes and combined parameters",
+			writes:   []string{"\x1b[?1;1047h", "x", "\x1b[?47l"},
+			switches: []bool{true, false},
+		},
+		{
+			name:     "sequence split across writes",
+			writes:   []string{"a\x1b[?10", "49hb"},
+			switches: []bool{true},
+			active:   true,
+		},
+		{
+			name:     "repeated enter is one switch",
+			writes:   []string{"\x1b[?1049h", "\x1b[?1049h"},
+			switches: []bool{true},
+			active:   true,
+		},
+		{
+			name:     "reset returns to the main screen",
+			writes:   []string{"\x1b[?1049h", "\x1b", "c"},
+			switches: []bool{true, false},
+		},
+		{
+			name:   "other private modes",
+			writes: []string{"\x1b[?25l\x1b[?2004h\x1b[?1h"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tracker AltScreenTracker
+			var switches []bool
+			for _, w := range tt.writes {
+				switches = append(switches, tracker.Process([]byte(w))...)
+			}
+			if !reflect.DeepEqual(switches, tt.switches) {
+				t.Errorf("switches = %v, want %v", switches, tt.switches)
+			}
+			if tracker.Active() != tt.active {
+				t.Errorf("Active() = %v, want %v", tracker.Active(), tt.active)
+			}
+		})
+	}
+}
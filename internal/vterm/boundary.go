@@ -0,0 +1,146 @@
+package vterm
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// BoundaryDetector finds the points in raw terminal output where an app
+// starts drawing a new frame. Detect returns [start, end) index pairs of the
+// boundary sequences in data, in order. Sequences longer than
+// maxBoundaryLen bytes may be missed when a PTY read splits them.
+type BoundaryDetector interface {
+	Name() string
+	Detect(data []byte) [][]int
+}
+
+// maxBoundaryLen is the longest boundary sequence guaranteed to be found
+// across write boundaries.
+const maxBoundaryLen = 16
+
+type regexBoundary struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// NewRegexBoundary returns a detector that reports every match of pattern.
+func NewRegexBoundary(name, pattern string) (BoundaryDetector, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("boundary %q: %w", name, err)
+	}
+	return &regexBoundary{name: name, re: re}, nil
+}
+
+func (b *regexBoundary) Name() string { return b.name }
+
+func (b *regexBoundary) Detect(data []byte) [][]int {
+	return b.re.FindAllIndex(data, -1)
+}
+
+var (
+	boundaryMu       sync.RWMutex
+	boundaryRegistry = map[string]BoundaryDetector{}
+)
+
+// RegisterBoundary makes d available to sessions by name.
+func RegisterBoundary(d BoundaryDetector) error {
+	boundaryMu.Lock()
+	defer boundaryMu.Unlock()
+	if _, exists := boundaryRegistry[d.Name()]; exists {
+		return fmt.Errorf("boundary %q already registered", d.Name())
+	}
+	boundaryRegistry[d.Name()] = d
+	return nil
+}
+
+// LookupBoundaries resolves detector names. An empty list returns the
+// default detectors.
+func LookupBoundaries(names []string) ([]BoundaryDetector, error) {
+	if len(names) == 0 {
+		names = DefaultBoundaries
+	}
+	boundaryMu.RLock()
+	defer boundaryMu.RUnlock()
+	detectors := make([]BoundaryDetector, 0, len(names))
+	for _, name := range names {
+		d, ok := boundaryRegistry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown frame boundary %q (available: %v)", name, boundaryNamesLocked())
+		}
+		detectors = append(detectors, d)
+	}
+	return detectors, nil
+}
+
+// BoundaryNames returns the registered detector names, sorted.
+func BoundaryNames() []string {
+	boundaryMu.RLock()
+	defer boundaryMu.RUnlock()
+	return boundaryNamesLocked()
+}
+
+func boundaryNamesLocked() []string {
+	names := make([]string, 0, len(boundaryRegistry))
+	for name := range boundaryRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultBoundaries are the detectors used when a session does not choose.
+var DefaultBoundaries = []string{"clear", "altscreen", "sync", "home"}
+
+func init() {
+	builtin := []struct{ name, pattern string }{
+		{"clear", `\x1b\[2J|\x1bc`},    // clear screen, full reset
+		{"altscreen", `\x1b\[\?1049h`}, // alt screen enter
+		{"sync", `\x1b\[\?2026h`},      // synchronized update begin
+		{"home", `\x1b\[(?:1;1)?H`},    // cursor home
+	}
+	for _, b := range builtin {
+		d, err := NewRegexBoundary(b.name, b.pattern)
+		if err != nil {
+			panic(err)
+		}
+		if err := RegisterBoundary(d); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// detectBoundaries merges the boundaries found by detectors, ordered by
+// start and with overlapping matches dropped.
+func detectBoundaries(detectors []BoundaryDetector, data []byte) [][]int {
+	if len(detectors) == 1 {
+		return detectors[0].Detect(data)
+	}
+	var all [][]int
+	for _, d := range detectors {
+		all = append(all, d.Detect(data)...)
+	}
+	slices.SortFunc(all, func(a, b []int) int { return a[0] - b[0] })
+
+	merged := all[:0]
+	end := -1
+	for _, m := range all {
+		if m[0] < end {
+			continue
+		}
+		merged = append(merged, m)
+		end = m[1]
+	}
+	return merged
+}
+
+func defaultDetectors() []BoundaryDetector {
+	detectors, err := LookupBoundaries(nil)
+	if err != nil {
+		panic(err)
+	}
+	return detectors
+}
@@ -0,0 +1,74 @@
+package vterm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookupBoundaries(t *testing.T) {
+	detectors, err := LookupBoundaries(nil)
+	if err != nil {
+		t.Fatalf("LookupBoundaries(nil): %v", err)
+	}
+	if len(detectors) != len(DefaultBoundaries) {
+		t.Errorf("got %d default detectors, want %d", len(detectors), len(DefaultBoundaries))
+	}
+
+	if _, err := LookupBoundaries([]string{"clear", "nope"}); err == nil {
+		t.Error("expected error for unknown boundary")
+	}
+}
+
+func TestRegisterBoundaryDuplicate(t *testing.T) {
+	d, err := NewRegexBoundary("clear", `x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterBoundary(d); err == nil {
+		t.Error("expected error registering duplicate name")
+	}
+}
+
+func TestDetectBoundariesMerge(t *testing.T) {
+	a, _ := NewRegexBoundary("a", `\x1b\[2J`)
+	b, _ := NewRegexBoundary("b", `\x1b\[2J\x1b\[H|\x1b\[H`)
+
+	data := []byte("x\x1b[2J\x1b[Hy\x1b[Hz")
+	got := detectBoundaries([]BoundaryDetector{a, b}, data)
+	want := [][]int{{1, 5}, {9, 12}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("detectBoundaries = %v, want %v", got, want)
+	}
+}
+
+func TestScreen_CustomFrameBoundary(t *testing.T) {
+	d, err := NewRegexBoundary("test-banner", `=== PAGE ===`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterBoundary(d); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(40, 5)
+	defer s.Close()
+	s.SetFrameHistory(5)
+	if err := s.SetFrameBoundaries([]string{"test-banner"}); err != nil {
+		t.Fatalf("SetFrameBoundaries: %v", err)
+	}
+
+	s.Write([]byte("page one\r\n=== PAGE ===\x1b[2J\x1b[Hpage two"))
+
+	frames := s.Frames()
+	if len(frames) != 1 {
+		t.Fatalf("got %d frames, want 1 (only the banner is a boundary)", len(frames))
+	}
+	if !strings.Contains(frames[0].Content, "page one") {
+		t.Errorf("frames[0] = %q, want page one", frames[0].Content)
+	}
+
+	if err := s.SetFrameBoundaries([]string{"missing"}); err == nil {
+		t.Error("expected error for unknown boundary")
+	}
+}
@@ -0,0 +1,91 @@
+package vterm
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateCorpus = flag.Bool("update", false, "rewrite corpus golden files from current output")
+
+const corpusFrameHistory = 20
+
+// TestCorpus runs raw PTY captures in testdata/corpus through Strip and a
+// TUI Screen (with frame history) and compares against golden files:
+//
+//	<name>.raw     raw PTY output
+//	<name>.strip   StripDefault(raw)
+//	<name>.screen  plain screen after writing raw to an 80x24 Screen
+//	<name>.frames  frames captured by the Screen, stripped
+//
+// Record a capture with shelli create <name> --tui --capture-raw FILE,
+// copying FILE to <name>.raw while the program is still on screen (its exit
+// would leave the alternate screen and an empty .screen), then run
+// go test ./internal/vterm -run TestCorpus -update and review the goldens.
+// Line-oriented captures (ls-columns, grep-color) never redraw, so their
+// .frames stay empty.
+func TestCorpus(t *testing.T) {
+	raws, err := filepath.Glob(filepath.Join("testdata", "corpus", "*.raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raws) == 0 {
+		t.Fatal("no corpus captures found")
+	}
+
+	for _, rawPath := range raws {
+		base := strings.TrimSuffix(rawPath, ".raw")
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			raw, err := os.ReadFile(rawPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := New(80, 24)
+			defer s.Close()
+			go s.ReadResponses(io.Discard)
+			s.SetFrameHistory(corpusFrameHistory)
+			s.Write(raw)
+
+			var frames strings.Builder
+			for i, f := range s.Frames() {
+				fmt.Fprintf(&frames, "--- frame %d ---\n%s\n", i+1, trimLines(StripDefault(f.Content)))
+			}
+
+			checkGolden(t, base+".strip", StripDefault(string(raw)))
+			checkGolden(t, base+".screen", s.String())
+			checkGolden(t, base+".frames", frames.String())
+		})
+	}
+}
+
+func checkGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *updateCorpus {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden (run with -update to create): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s mismatch\n--- got ---\n%s\n--- want ---\n%s", filepath.Base(path), got, want)
+	}
+}
+
+// trimLines drops trailing spaces from each line so goldens survive editors
+// that strip trailing whitespace.
+func trimLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
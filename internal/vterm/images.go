@@ -0,0 +1,284 @@
+package vterm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+)
+
+// MaxInlineImageBytes caps the encoded size of one inline image. Larger
+// sequences are still removed from the text stream but not kept.
+const MaxInlineImageBytes = 16 << 20
+
+// InlineImage is an image an app sent with the iTerm2 (OSC 1337 File=) or
+// kitty graphics (APC G) protocol.
+type InlineImage struct {
+	Protocol string // "iterm2" or "kitty"
+	Name     string // file name, when the app sent one
+	Format   string // png, jpeg, gif, rgb, rgba, or "" when unknown
+	Data     []byte // decoded image bytes
+}
+
+var (
+	iterm2Intro = []byte("\x1b]1337;File=")
+	kittyIntro  = []byte("\x1b_G")
+)
+
+// ImageExtractor removes inline image sequences from a stream of terminal
+// output and decodes them. Sequences may span any number of writes. The
+// zero value is ready to use.
+type ImageExtractor struct {
+	held     []byte // bytes that may start a sequence, or its terminator
+	inSeq    bool
+	protocol string
+	seq      []byte
+	overflow bool
+
+	// kitty transmissions split into m=1 chunks
+	kittyCtl     map[string]string
+	kittyPayload []byte
+	kittyDrop    bool
+}
+
+// Process returns p with inline image sequences removed, and the images
+// completed within it. A possible sequence start at the end of p is held
+// back until the next call.
+func (e *ImageExtractor) Process(p []byte) ([]byte, []InlineImage) {
+	data := p
+	if len(e.held) > 0 {
+		data = append(e.held, p...)
+		e.held = nil
+	}
+
+	var text []byte
+	var images []InlineImage
+	for len(data) > 0 {
+		if e.inSeq {
+			end, termLen := e.findTerminator(data)
+			if end < 0 {
+				body := data
+				if body[len(body)-1] == 0x1b {
+					e.held = []byte{0x1b}
+					body = body[:len(body)-1]
+				}
+				e.appendSeq(body)
+				break
+			}
+			e.appendSeq(data[:end])
+			data = data[end+termLen:]
+			if img, ok := e.finishSeq(); ok {
+				images = append(images, img)
+			}
+			continue
+		}
+
+		start, protocol, introLen := nextImageIntro(data)
+		if start < 0 {
+			keep := heldPrefixLen(data)
+			text = appendText(text, p, data[:len(data)-keep])
+			if keep > 0 {
+				e.held = append([]byte(nil), data[len(data)-keep:]...)
+			}
+			break
+		}
+		text = appendText(text, p, data[:start])
+		e.inSeq = true
+		e.protocol = protocol
+		e.seq = e.seq[:0]
+		e.overflow = false
+		data = data[start+introLen:]
+	}
+	return text, images
+}
+
+// Flush returns bytes held back by Process that turned out not to start an
+// image sequence. Call it when the stream ends.
+func (e *ImageExtractor) Flush() []byte {
+	if e.inSeq {
+		return nil
+	}
+	held := e.held
+	e.held = nil
+	return held
+}
+
+// appendText appends chunk to text. When nothing has been removed yet and
+// chunk is all of p, p is returned as is to avoid a copy.
+func appendText(text, p, chunk []byte) []byte {
+	if text == nil && len(chunk) == len(p) && (len(p) == 0 || &chunk[0] == &p[0]) {
+		return p
+	}
+	return append(text, chunk...)
+}
+
+func nextImageIntro(data []byte) (int, string, int) {
+	i := bytes.Index(data, iterm2Intro)
+	k := bytes.Index(data, kittyIntro)
+	switch {
+	case i < 0 && k < 0:
+		return -1, "", 0
+	case k < 0 || (i >= 0 && i < k):
+		return i, "iterm2", len(iterm2Intro)
+	default:
+		return k, "kitty", len(kittyIntro)
+	}
+}
+
+// heldPrefixLen returns the length of the longest suffix of data that is a
+// proper prefix of an image introducer.
+func heldPrefixLen(data []byte) int {
+	longest := 0
+	for _, intro := range [][]byte{iterm2Intro, kittyIntro} {
+		for n := min(len(intro)-1, len(data)); n > longest; n-- {
+			if bytes.HasSuffix(data, intro[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+func (e *ImageExtractor) findTerminator(data []byte) (int, int) {
+	st := bytes.Index(data, []byte("\x1b\\"))
+	if e.protocol == "iterm2" {
+		if bel := bytes.IndexByte(data, 0x07); bel >= 0 && (st < 0 || bel < st) {
+			return bel, 1
+		}
+	}
+	if st < 0 {
+		return -1, 0
+	}
+	return st, 2
+}
+
+// appendSeq collects sequence bytes. Past MaxInlineImageBytes the rest is
+// dropped; the start (holding kitty control data) is kept.
+func (e *ImageExtractor) appendSeq(b []byte) {
+	if e.overflow {
+		return
+	}
+	if len(e.seq)+len(b) > MaxInlineImageBytes {
+		e.overflow = true
+		return
+	}
+	e.seq = append(e.seq, b...)
+}
+
+func (e *ImageExtractor) finishSeq() (InlineImage, bool) {
+	e.inSeq = false
+	if e.protocol == "iterm2" {
+		if e.overflow {
+			return InlineImage{}, false
+		}
+		return parseITerm2(e.seq)
+	}
+	return e.finishKitty(e.overflow)
+}
+
+// parseITerm2 parses "<args>:<base64>" from OSC 1337 File=.
+func parseITerm2(seq []byte) (InlineImage, bool) {
+	args, payload, ok := bytes.Cut(seq, []byte(":"))
+	if !ok {
+		return InlineImage{}, false
+	}
+	data, err := decodeBase64(payload)
+	if err != nil || len(data) == 0 {
+		return InlineImage{}, false
+	}
+	img := InlineImage{Protocol: "iterm2", Data: data, Format: sniffImageFormat(data)}
+	for _, kv := range strings.Split(string(args), ";") {
+		k, v, _ := strings.Cut(kv, "=")
+		if k == "name" {
+			if name, err := base64.StdEncoding.DecodeString(v); err == nil {
+				img.Name = string(name)
+			}
+		}
+	}
+	return img, true
+}
+
+// finishKitty handles one APC G command: "<key=value,...>;<base64>". A
+// transmission split with m=1 is accumulated until its final chunk.
+func (e *ImageExtractor) finishKitty(overflow bool) (InlineImage, bool) {
+	ctlPart, payload, _ := bytes.Cut(e.seq, []byte(";"))
+	ctl := parseKittyControl(string(ctlPart))
+
+	if e.kittyCtl == nil {
+		e.kittyCtl = ctl
+	}
+	if overflow || e.kittyDrop || len(e.kittyPayload)+len(payload) > MaxInlineImageBytes {
+		e.kittyDrop = true
+		e.kittyPayload = nil
+	} else {
+		e.kittyPayload = append(e.kittyPayload, payload...)
+	}
+	if ctl["m"] == "1" {
+		return InlineImage{}, false
+	}
+
+	first, encoded, drop := e.kittyCtl, e.kittyPayload, e.kittyDrop
+	e.kittyCtl, e.kittyPayload, e.kittyDrop = nil, nil, false
+	if drop {
+		return InlineImage{}, false
+	}
+
+	switch first["a"] {
+	case "", "t", "T":
+	default:
+		return InlineImage{}, false // query, delete, or display of an earlier image
+	}
+	data, err := decodeBase64(encoded)
+	if err != nil || len(data) == 0 {
+		return InlineImage{}, false
+	}
+
+	img := InlineImage{Protocol: "kitty", Data: data}
+	switch first["f"] {
+	case "100":
+		img.Format = "png"
+	case "24":
+		img.Format = "rgb"
+	case "", "32":
+		img.Format = "rgba"
+	}
+	if first["o"] == "z" {
+		img.Format += "+zlib"
+	}
+	return img, true
+}
+
+func parseKittyControl(s string) map[string]string {
+	ctl := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			ctl[k] = v
+		}
+	}
+	return ctl
+}
+
+func decodeBase64(b []byte) ([]byte, error) {
+	clean := bytes.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == ' ' {
+			return -1
+		}
+		return r
+	}, b)
+	if data, err := base64.StdEncoding.DecodeString(string(clean)); err == nil {
+		return data, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(string(clean), "="))
+}
+
+func sniffImageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		return "png"
+	case bytes.HasPrefix(data, []byte("\xff\xd8")):
+		return "jpeg"
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return "gif"
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package vterm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\nfake image body")
+
+func iterm2Seq(name string, data []byte, term string) string {
+	return "\x1b]1337;File=name=" + base64.StdEncoding.EncodeToString([]byte(name)) +
+		";inline=1:" + base64.StdEncoding.EncodeToString(data) + term
+}
+
+func TestImageExtractor_ITerm2(t *testing.T) {
+	for _, term := range []string{"\x07", "\x1b\\"} {
+		var e ImageExtractor
+		in := "before " + iterm2Seq("cat.png", testPNG, term) + " after"
+
+		text, images := e.Process([]byte(in))
+		if string(text) != "before  after" {
+			t.Errorf("text = %q, want %q", text, "before  after")
+		}
+		if len(images) != 1 {
+			t.Fatalf("got %d images, want 1", len(images))
+		}
+		img := images[0]
+		if img.Protocol != "iterm2" || img.Name != "cat.png" || img.Format != "png" {
+			t.Errorf("image = %+v", img)
+		}
+		if !bytes.Equal(img.Data, testPNG) {
+			t.Errorf("data = %q, want %q", img.Data, testPNG)
+		}
+	}
+}
+
+func TestImageExtractor_SplitAtEveryByte(t *testing.T) {
+	in := []byte("a\x1b[31mb" + iterm2Seq("x.png", testPNG, "\x1b\\") + "c\x1b_Gf=100;" +
+		base64.StdEncoding.EncodeToString(testPNG) + "\x1b\\d")
+
+	for cut := 0; cut <= len(in); cut++ {
+		var e ImageExtractor
+		t1, i1 := e.Process(in[:cut])
+		t2, i2 := e.Process(in[cut:])
+		text := append(append(append([]byte(nil), t1...), t2...), e.Flush()...)
+		if string(text) != "a\x1b[31mbcd" {
+			t.Fatalf("cut %d: text = %q", cut, text)
+		}
+		if n := len(i1) + len(i2); n != 2 {
+			t.Fatalf("cut %d: got %d images, want 2", cut, n)
+		}
+	}
+}
+
+func TestImageExtractor_KittyChunked(t *testing.T) {
+	enc := base64.StdEncoding.EncodeToString(testPNG)
+	in := "\x1b_Ga=T,f=100,m=1;" + enc[:8] + "\x1b\\" +
+		"\x1b_Gm=1;" + enc[8:16] + "\x1b\\" +
+		"\x1b_Gm=0;" + enc[16:] + "\x1b\\done"
+
+	var e ImageExtractor
+	text, images := e.Process([]byte(in))
+	if string(text) != "done" {
+		t.Errorf("text = %q, want %q", text, "done")
+	}
+	if len(images) != 1 {
+		t.Fatalf("got %d images, want 1", len(images))
+	}
+	if images[0].Format != "png" || !bytes.Equal(images[0].Data, testPNG) {
+		t.Errorf("image = %+v", images[0])
+	}
+}
+
+func TestImageExtractor_KittyQueryIgnored(t *testing.T) {
+	var e ImageExtractor
+	text, images := e.Process([]byte("x\x1b_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\x1b\\y"))
+	if string(text) != "xy" {
+		t.Errorf("text = %q, want %q", text, "xy")
+	}
+	if len(images) != 0 {
+		t.Errorf("got %d images for a query, want 0", len(images))
+	}
+}
+
+func TestImageExtractor_PassThrough(t *testing.T) {
+	var e ImageExtractor
+	in := []byte("plain \x1b]0;title\x07 text\x1b]")
+	text, images := e.Process(in)
+	if string(text) != "plain \x1b]0;title\x07 text" {
+		t.Errorf("text = %q", text)
+	}
+	if len(images) != 0 {
+		t.Errorf("got %d images, want 0", len(images))
+	}
+	if held := e.Flush(); string(held) != "\x1b]" {
+		t.Errorf("Flush = %q, want held prefix", held)
+	}
+}
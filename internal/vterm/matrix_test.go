@@ -0,0 +1,80 @@
+package vterm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// sgrPattern matches SGR (color and style) sequences.
+var sgrPattern = regexp.MustCompile(`\x1b\[[0-9;:]*m`)
+
+// matrixThemes restyle a capture as if it came from a terminal with another
+// color setup. Text and layout must not depend on the theme.
+var matrixThemes = map[string]func(string) string{
+	"original":   func(s string) string { return s },
+	"monochrome": func(s string) string { return sgrPattern.ReplaceAllString(s, "") },
+	"256-color": func(s string) string {
+		return sgrPattern.ReplaceAllLiteralString(s, "\x1b[0;38;5;208;48;5;17m")
+	},
+	"truecolor": func(s string) string {
+		return sgrPattern.ReplaceAllLiteralString(s, "\x1b[1;38;2;250;189;47;48:2::40:40:40m")
+	},
+}
+
+var matrixWidths = []int{40, 80, 132}
+
+// TestRenderMatrix runs the corpus captures through Strip and a Screen at
+// several widths under each theme: the text must match the original theme's,
+// and no screen line may be wider than the terminal.
+func TestRenderMatrix(t *testing.T) {
+	raws, err := filepath.Glob(filepath.Join("testdata", "corpus", "*.raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rawPath := range raws {
+		raw, err := os.ReadFile(rawPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimSuffix(filepath.Base(rawPath), ".raw")
+
+		for _, cols := range matrixWidths {
+			wantStrip := Strip(string(raw), cols)
+			wantScreen := screenText(string(raw), cols)
+
+			for theme, restyle := range matrixThemes {
+				input := restyle(string(raw))
+				t.Run(fmt.Sprintf("%s/%s/%dcols", name, theme, cols), func(t *testing.T) {
+					if got := Strip(input, cols); got != wantStrip {
+						t.Errorf("Strip differs from original theme\n--- got ---\n%s\n--- want ---\n%s", got, wantStrip)
+					}
+					screen := screenText(input, cols)
+					if screen != wantScreen {
+						t.Errorf("screen differs from original theme\n--- got ---\n%s\n--- want ---\n%s", screen, wantScreen)
+					}
+					for i, line := range strings.Split(screen, "\n") {
+						if w := ansi.StringWidth(line); w > cols {
+							t.Errorf("screen line %d is %d cells wide, terminal has %d", i+1, w, cols)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func screenText(s string, cols int) string {
+	screen := New(cols, 24)
+	defer screen.Close()
+	go screen.ReadResponses(io.Discard)
+	screen.Write([]byte(s))
+	return screen.String()
+}
@@ -0,0 +1,152 @@
+package vterm
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// maxHeldOSC bounds how much of an unterminated OSC sequence is held back
+// waiting for its terminator. Longer sequences are passed through as is.
+const maxHeldOSC = 4096
+
+// Notification is an attention request from an app: a bell (BEL), or a
+// desktop notification sent with OSC 9 or OSC 777;notify.
+type Notification struct {
+	Kind  string // "bell" or "notify"
+	Title string
+	Body  string
+}
+
+// osc9Progress matches OSC 9 subcommands (ConEmu progress and friends),
+// which are not notifications.
+var osc9Progress = regexp.MustCompile(`^\d+;`)
+
+// NotifyExtractor removes bells and notification sequences from a stream of
+// terminal output and reports them. BEL bytes that terminate other OSC
+// sequences are left alone. The zero value is ready to use.
+type NotifyExtractor struct {
+	held    []byte
+	passOSC bool // inside a long OSC that is being passed through
+}
+
+// Process returns p with bells and notifications removed, and the
+// notifications found. An incomplete OSC sequence at the end of p is held
+// back until the next call.
+func (e *NotifyExtractor) Process(p []byte) ([]byte, []Notification) {
+	data := p
+	if len(e.held) > 0 {
+		data = append(e.held, p...)
+		e.held = nil
+	}
+	if !e.passOSC && bytes.IndexAny(data, "\x07\x1b") < 0 {
+		return data, nil
+	}
+
+	out := make([]byte, 0, len(data))
+	var events []Notification
+	i := 0
+	for i < len(data) {
+		if e.passOSC {
+			end, termLen := oscTerminator(data[i:])
+			if end < 0 {
+				rest := data[i:]
+				if rest[len(rest)-1] == 0x1b {
+					e.held = []byte{0x1b}
+					rest = rest[:len(rest)-1]
+				}
+				out = append(out, rest...)
+				break
+			}
+			out = append(out, data[i:i+end+termLen]...)
+			i += end + termLen
+			e.passOSC = false
+			continue
+		}
+
+		j := bytes.IndexAny(data[i:], "\x07\x1b")
+		if j < 0 {
+			out = append(out, data[i:]...)
+			break
+		}
+		out = append(out, data[i:i+j]...)
+		i += j
+
+		if data[i] == 0x07 {
+			events = append(events, Notification{Kind: "bell"})
+			i++
+			continue
+		}
+
+		// ESC
+		if i+1 == len(data) {
+			e.held = append([]byte(nil), data[i:]...)
+			break
+		}
+		if data[i+1] != ']' {
+			out = append(out, data[i])
+			i++
+			continue
+		}
+
+		end, termLen := oscTerminator(data[i+2:])
+		if end < 0 {
+			if len(data)-i > maxHeldOSC {
+				out = append(out, data[i:]...)
+				e.passOSC = true
+				if data[len(data)-1] == 0x1b {
+					out = out[:len(out)-1]
+					e.held = []byte{0x1b}
+				}
+			} else {
+				e.held = append([]byte(nil), data[i:]...)
+			}
+			break
+		}
+
+		seqEnd := i + 2 + end + termLen
+		if n, ok := parseNotification(data[i+2 : i+2+end]); ok {
+			events = append(events, n)
+		} else {
+			out = append(out, data[i:seqEnd]...)
+		}
+		i = seqEnd
+	}
+	return out, events
+}
+
+// Flush returns bytes held back by Process. Call it when the stream ends.
+func (e *NotifyExtractor) Flush() []byte {
+	held := e.held
+	e.held = nil
+	return held
+}
+
+func oscTerminator(data []byte) (int, int) {
+	bel := bytes.IndexByte(data, 0x07)
+	st := bytes.Index(data, []byte("\x1b\\"))
+	switch {
+	case bel < 0 && st < 0:
+		return -1, 0
+	case st < 0 || (bel >= 0 && bel < st):
+		return bel, 1
+	default:
+		return st, 2
+	}
+}
+
+// parseNotification recognizes "9;<body>" and "777;notify;<title>;<body>".
+func parseNotification(osc []byte) (Notification, bool) {
+	s := string(osc)
+	if body, ok := strings.CutPrefix(s, "9;"); ok {
+		if osc9Progress.MatchString(body) {
+			return Notification{}, false
+		}
+		return Notification{Kind: "notify", Body: body}, true
+	}
+	if rest, ok := strings.CutPrefix(s, "777;notify;"); ok {
+		title, body, _ := strings.Cut(rest, ";")
+		return Notification{Kind: "notify", Title: title, Body: body}, true
+	}
+	return Notification{}, false
+}
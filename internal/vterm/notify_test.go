@@ -0,0 +1,97 @@
+package vterm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotifyExtractor(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		text   string
+		events []Notification
+	}{
+		{
+			name:  "plain text passes through",
+			input: "hello \x1b[31mred\x1b[0m",
+			text:  "hello \x1b[31mred\x1b[0m",
+		},
+		{
+			name:   "bare bell",
+			input:  "done\x07!",
+			text:   "done!",
+			events: []Notification{{Kind: "bell"}},
+		},
+		{
+			name:  "BEL terminating a title OSC is not a bell",
+			input: "\x1b]0;my title\x07text",
+			text:  "\x1b]0;my title\x07text",
+		},
+		{
+			name:   "OSC 9 notification",
+			input:  "a\x1b]9;Build finished\x07b",
+			text:   "ab",
+			events: []Notification{{Kind: "notify", Body: "Build finished"}},
+		},
+		{
+			name:   "OSC 777 notification with ST",
+			input:  "a\x1b]777;notify;CI;tests passed\x1b\\b",
+			text:   "ab",
+			events: []Notification{{Kind: "notify", Title: "CI", Body: "tests passed"}},
+		},
+		{
+			name:  "OSC 9 progress is not a notification",
+			input: "\x1b]9;4;1;50\x07x",
+			text:  "\x1b]9;4;1;50\x07x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e NotifyExtractor
+			text, events := e.Process([]byte(tt.input))
+			text = append(text, e.Flush()...)
+			if string(text) != tt.text {
+				t.Errorf("text = %q, want %q", text, tt.text)
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Errorf("events = %+v, want %+v", events, tt.events)
+			}
+		})
+	}
+}
+
+func TestNotifyExtractor_SplitAtEveryByte(t *testing.T) {
+	in := []byte("x\x07y\x1b]0;title\x07z\x1b]777;notify;T;B\x1b\\w\x1b[1mq")
+	for cut := 0; cut <= len(in); cut++ {
+		var e NotifyExtractor
+		t1, e1 := e.Process(in[:cut])
+		t2, e2 := e.Process(in[cut:])
+		text := string(t1) + string(t2) + string(e.Flush())
+		if text != "xy\x1b]0;title\x07zw\x1b[1mq" {
+			t.Fatalf("cut %d: text = %q", cut, text)
+		}
+		if n := len(e1) + len(e2); n != 2 {
+			t.Fatalf("cut %d: got %d events, want 2", cut, n)
+		}
+	}
+}
+
+func TestNotifyExtractor_LongOSCPassesThrough(t *testing.T) {
+	var e NotifyExtractor
+	long := make([]byte, maxHeldOSC+10)
+	for i := range long {
+		long[i] = 'a'
+	}
+	in := append([]byte("\x1b]8;;"), long...)
+
+	text, events := e.Process(in)
+	if len(text) != len(in) || len(events) != 0 {
+		t.Fatalf("long OSC: got %d bytes, %d events", len(text), len(events))
+	}
+	// The terminator of the passed-through OSC must not count as a bell.
+	text, events = e.Process([]byte("\x07after"))
+	if string(text) != "\x07after" || len(events) != 0 {
+		t.Errorf("after long OSC: text = %q, events = %v", text, events)
+	}
+}
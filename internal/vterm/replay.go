@@ -0,0 +1,55 @@
+package vterm
+
+import (
+	"bytes"
+	"strings"
+)
+
+// SplitFrames splits raw terminal output into frames for replay.
+// Output containing redraw sequences (the default boundaries) is cut before
+// each one, merging cuts that would produce a frame without visible content.
+// Plain line-based output is split into one frame per line.
+func SplitFrames(data []byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+
+	matches := detectBoundaries(defaultDetectors(), data)
+	if len(matches) == 0 {
+		return splitLines(data)
+	}
+
+	var frames [][]byte
+	start := 0
+	for _, m := range matches {
+		if m[0] == start || !hasVisibleContent(data[start:m[0]]) {
+			continue
+		}
+		frames = append(frames, data[start:m[0]])
+		start = m[0]
+	}
+	frames = append(frames, data[start:])
+	return frames
+}
+
+func splitLines(data []byte) [][]byte {
+	var frames [][]byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			frames = append(frames, data)
+			break
+		}
+		frames = append(frames, data[:i+1])
+		data = data[i+1:]
+	}
+	return frames
+}
+
+func hasVisibleContent(data []byte) bool {
+	s := string(data)
+	for _, re := range ansiPatterns {
+		s = re.ReplaceAllString(s, "")
+	}
+	return strings.TrimSpace(s) != ""
+}
@@ -0,0 +1,52 @@
+package vterm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitFrames_Empty(t *testing.T) {
+	if frames := SplitFrames(nil); frames != nil {
+		t.Errorf("SplitFrames(nil) = %q, want nil", frames)
+	}
+}
+
+func TestSplitFrames_Lines(t *testing.T) {
+	frames := SplitFrames([]byte("one\ntwo\nthree"))
+	want := []string{"one\n", "two\n", "three"}
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames %q, want %d", len(frames), frames, len(want))
+	}
+	for i, f := range frames {
+		if string(f) != want[i] {
+			t.Errorf("frame %d = %q, want %q", i, f, want[i])
+		}
+	}
+}
+
+func TestSplitFrames_Redraws(t *testing.T) {
+	data := []byte("\x1b[2J\x1b[Hfirst frame\x1b[2J\x1b[Hsecond frame\x1b[Hthird")
+	frames := SplitFrames(data)
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames %q, want 3", len(frames), frames)
+	}
+	if !bytes.HasPrefix(frames[0], []byte("\x1b[2J\x1b[H")) {
+		t.Errorf("first frame should keep clear+home together, got %q", frames[0])
+	}
+	if !bytes.Contains(frames[1], []byte("second frame")) {
+		t.Errorf("second frame = %q", frames[1])
+	}
+	if !bytes.Equal(bytes.Join(frames, nil), data) {
+		t.Error("frames do not reassemble to the original data")
+	}
+}
+
+func TestSplitFrames_LeadingContent(t *testing.T) {
+	frames := SplitFrames([]byte("$ htop\r\n\x1b[?1049h\x1b[Hdashboard"))
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames %q, want 2", len(frames), frames)
+	}
+	if string(frames[0]) != "$ htop\r\n" {
+		t.Errorf("frame 0 = %q", frames[0])
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/charmbracelet/x/vt"
 )
 
 // Screen wraps a thread-safe VT emulator for TUI session handling.
-// It replaces FrameDetector + TerminalResponder + raw byte storage for TUI sessions.
+// The daemon creates one per TUI session and feeds it all PTY output; reads
+// and snapshots render it, and it answers the app's terminal queries.
 // The emulator IS the screen state; no separate buffer needed.
 type Screen struct {
 	emu     *vt.SafeEmulator
@@ -23,6 +25,37 @@ type Screen struct {
 	respPW     *io.PipeWriter
 	bridgeDone chan struct{}
 	closeOnce  sync.Once
+
+	// Frame history ring: the rendered screen captured just before each
+	// redraw sequence, so content that flashed and got repainted survives.
+	framesMu   sync.Mutex
+	frames     []Frame
+	frameLimit int
+	frameTrail []byte // last raw bytes written, to match boundaries split across writes
+	boundaries []BoundaryDetector
+
+	// Damage tracking: rendered rows as of the last check and the version
+	// at which each row was last seen to change. Refreshed lazily by
+	// ChangedRows, so stamps are >= the real change version (never missed,
+	// occasionally reported late).
+	damageMu     sync.Mutex
+	damageLines  []string
+	damageStamps []uint64
+	damageAt     uint64
+
+	// Scrollback: plain-text rows that scrolled off the top of the screen.
+	scrollMu    sync.Mutex
+	scrollback  []string
+	scrollLimit int
+
+	cursorHidden atomic.Bool // set by DECTCEM (ESC[?25l)
+}
+
+// Frame is a rendered screen captured before a redraw.
+type Frame struct {
+	Version    uint64    `json:"version"`
+	CapturedAt time.Time `json:"captured_at"`
+	Content    string    `json:"content"`
 }
 
 func New(cols, rows int) *Screen {
@@ -32,19 +65,142 @@ func New(cols, rows int) *Screen {
 		respPR:     pr,
 		respPW:     pw,
 		bridgeDone: make(chan struct{}),
+		boundaries: defaultDetectors(),
 	}
+	s.emu.SetCallbacks(vt.Callbacks{
+		CursorVisibility: func(visible bool) { s.cursorHidden.Store(!visible) },
+	})
 	go s.bridgeResponses()
 	return s
 }
 
+// SetFrameHistory sets how many past frames are kept. Zero disables history.
+func (s *Screen) SetFrameHistory(limit int) {
+	s.framesMu.Lock()
+	defer s.framesMu.Unlock()
+	s.frameLimit = max(0, limit)
+	if len(s.frames) > s.frameLimit {
+		s.frames = append([]Frame(nil), s.frames[len(s.frames)-s.frameLimit:]...)
+	}
+}
+
+// SetFrameBoundaries selects the registered boundary detectors used for
+// frame history. An empty list restores the defaults.
+func (s *Screen) SetFrameBoundaries(names []string) error {
+	detectors, err := LookupBoundaries(names)
+	if err != nil {
+		return err
+	}
+	s.framesMu.Lock()
+	defer s.framesMu.Unlock()
+	s.boundaries = detectors
+	return nil
+}
+
 func (s *Screen) Write(p []byte) (int, error) {
-	n, err := s.emu.Write(p)
+	var n int
+	var err error
+	if s.historyEnabled() {
+		n, err = s.writeCapturingFrames(p)
+	} else {
+		n, err = s.writeTracked(p)
+	}
 	if n > 0 {
 		s.version.Add(1)
 	}
 	return n, err
 }
 
+// frameTrailLen is enough to hold any boundary prefix left at the end of a
+// write.
+const frameTrailLen = maxBoundaryLen - 1
+
+// writeCapturingFrames feeds p to the emulator in pieces, capturing the
+// screen before every redraw sequence it contains.
+//
+// PTY reads can cut a redraw sequence in half, so boundaries are matched
+// against the raw tail of the previous write followed by p. A boundary that
+// starts in the tail is captured before any of p is written; the emulator
+// has not acted on the incomplete sequence yet, so the screen is still the
+// old frame.
+func (s *Screen) writeCapturingFrames(p []byte) (int, error) {
+	data, trailLen, detectors := s.withFrameTrail(p)
+
+	written := 0
+	for _, m := range detectBoundaries(detectors, data) {
+		if m[1] <= trailLen {
+			continue // handled by the previous write
+		}
+		start := max(0, m[0]-trailLen)
+		if start > written {
+			n, err := s.writeTracked(p[written:start])
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+		s.captureFrame()
+	}
+	n, err := s.writeTracked(p[written:])
+	return written + n, err
+}
+
+// withFrameTrail returns the trail of the previous write followed by p, the
+// trail length, and the active boundary detectors. The last frameTrailLen
+// bytes become the new trail.
+func (s *Screen) withFrameTrail(p []byte) ([]byte, int, []BoundaryDetector) {
+	s.framesMu.Lock()
+	defer s.framesMu.Unlock()
+	trailLen := len(s.frameTrail)
+	data := append(append([]byte(nil), s.frameTrail...), p...)
+	s.frameTrail = append([]byte(nil), data[max(0, len(data)-frameTrailLen):]...)
+	return data, trailLen, s.boundaries
+}
+
+// FrameHistory returns how many past frames are kept.
+func (s *Screen) FrameHistory() int {
+	s.framesMu.Lock()
+	defer s.framesMu.Unlock()
+	return s.frameLimit
+}
+
+func (s *Screen) historyEnabled() bool {
+	s.framesMu.Lock()
+	defer s.framesMu.Unlock()
+	return s.frameLimit > 0
+}
+
+func (s *Screen) captureFrame() {
+	if s.String() == "" {
+		return
+	}
+	content := s.Render()
+
+	s.framesMu.Lock()
+	defer s.framesMu.Unlock()
+	if s.frameLimit == 0 {
+		return
+	}
+	if len(s.frames) > 0 && s.frames[len(s.frames)-1].Content == content {
+		return
+	}
+	s.frames = append(s.frames, Frame{
+		Version:    s.version.Load(),
+		CapturedAt: time.Now(),
+		Content:    content,
+	})
+	if len(s.frames) > s.frameLimit {
+		s.frames = s.frames[len(s.frames)-s.frameLimit:]
+	}
+}
+
+// Frames returns captured frames, oldest first.
+func (s *Screen) Frames() []Frame {
+	s.framesMu.Lock()
+	defer s.framesMu.Unlock()
+	return append([]Frame(nil), s.frames...)
+}
+
 // String returns plain text screen content with \r\n normalized to \n
 // and trailing empty lines removed.
 func (s *Screen) String() string {
@@ -62,8 +218,85 @@ func (s *Screen) Render() string {
 	return out
 }
 
+// Rows returns every screen row, top to bottom: plain text with trailing
+// spaces removed, or ANSI-styled when styled is set.
+func (s *Screen) Rows(styled bool) []string {
+	if styled {
+		return s.Lines()
+	}
+	out := strings.ReplaceAll(s.emu.String(), "\r\n", "\n")
+	out = strings.ReplaceAll(out, "\r", "")
+	rows := strings.Split(out, "\n")
+	for i, row := range rows {
+		rows[i] = strings.TrimRight(row, " ")
+	}
+	if h := s.emu.Height(); len(rows) < h {
+		rows = append(rows, make([]string, h-len(rows))...)
+	}
+	return rows
+}
+
+// Cursor returns the 0-based cursor position and whether the program left
+// the cursor visible.
+func (s *Screen) Cursor() (col, row int, visible bool) {
+	pos := s.emu.CursorPosition()
+	return pos.X, pos.Y, !s.cursorHidden.Load()
+}
+
 func (s *Screen) Resize(cols, rows int) {
 	s.emu.Resize(cols, rows)
+
+	s.damageMu.Lock()
+	s.damageLines = nil
+	s.damageStamps = nil
+	s.damageMu.Unlock()
+}
+
+// ChangedRows returns the 0-based indices of screen rows whose content or
+// style changed after version since. After a resize every row is reported
+// as changed at the current version.
+func (s *Screen) ChangedRows(since uint64) []int {
+	s.damageMu.Lock()
+	defer s.damageMu.Unlock()
+
+	s.refreshDamageLocked()
+
+	var rows []int
+	for i, v := range s.damageStamps {
+		if v > since {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+// Lines returns the ANSI-styled content of each screen row.
+func (s *Screen) Lines() []string {
+	lines := strings.Split(s.Render(), "\n")
+	if h := s.emu.Height(); len(lines) < h {
+		lines = append(lines, make([]string, h-len(lines))...)
+	}
+	return lines
+}
+
+func (s *Screen) refreshDamageLocked() {
+	v := s.version.Load()
+	if s.damageLines != nil && v == s.damageAt {
+		return
+	}
+
+	lines := s.Lines()
+	stamps := make([]uint64, len(lines))
+	for i, line := range lines {
+		if i < len(s.damageLines) && s.damageLines[i] == line {
+			stamps[i] = s.damageStamps[i]
+		} else {
+			stamps[i] = v
+		}
+	}
+	s.damageLines = lines
+	s.damageStamps = stamps
+	s.damageAt = v
 }
 
 func (s *Screen) Version() uint64 {
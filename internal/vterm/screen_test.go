@@ -2,6 +2,11 @@ package vterm
 
 import (
 	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -111,3 +116,171 @@ func TestScreen_RenderContainsANSI(t *testing.T) {
 		t.Errorf("Render() = %q, want to contain 'red text'", rendered)
 	}
 }
+
+func TestScreen_FrameHistory(t *testing.T) {
+	s := New(40, 5)
+	defer s.Close()
+	s.SetFrameHistory(2)
+
+	s.Write([]byte("\x1b[2J\x1b[Hframe one"))
+	s.Write([]byte("\x1b[2J\x1b[Hframe two\x1b[2J\x1b[Hframe three"))
+	s.Write([]byte("\x1b[2J\x1b[Hframe four"))
+
+	frames := s.Frames()
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	if !strings.Contains(frames[0].Content, "frame two") {
+		t.Errorf("frames[0] = %q, want frame two", frames[0].Content)
+	}
+	if !strings.Contains(frames[1].Content, "frame three") {
+		t.Errorf("frames[1] = %q, want frame three", frames[1].Content)
+	}
+	if !strings.Contains(s.String(), "frame four") {
+		t.Errorf("current screen = %q, want frame four", s.String())
+	}
+}
+
+func TestScreen_FrameHistorySplitBoundary(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+	}{
+		{"clear split after ESC[", []string{"frame one\x1b[", "2J\x1b[Hframe two"}},
+		{"alt screen split mid-param", []string{"frame one\x1b[?10", "49hframe two"}},
+		{"home split before final byte", []string{"frame one\x1b[1;1", "Hframe two"}},
+		{"boundary spread over three writes", []string{"frame one\x1b", "[?20", "26hframe two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(40, 5)
+			defer s.Close()
+			s.SetFrameHistory(5)
+
+			for _, c := range tt.chunks {
+				s.Write([]byte(c))
+			}
+
+			frames := s.Frames()
+			if len(frames) != 1 {
+				t.Fatalf("got %d frames, want 1", len(frames))
+			}
+			if !strings.Contains(frames[0].Content, "frame one") {
+				t.Errorf("frames[0] = %q, want frame one", frames[0].Content)
+			}
+		})
+	}
+}
+
+func TestScreen_FrameHistoryDisabled(t *testing.T) {
+	s := New(40, 5)
+	defer s.Close()
+
+	s.Write([]byte("\x1b[2J\x1b[Hone\x1b[2J\x1b[Htwo"))
+	if frames := s.Frames(); len(frames) != 0 {
+		t.Errorf("got %d frames with history disabled, want 0", len(frames))
+	}
+}
+
+func TestScreen_ChangedRows(t *testing.T) {
+	s := New(20, 4)
+	defer s.Close()
+
+	s.Write([]byte("\x1b[1;1Htop\x1b[3;1Hthird"))
+	v1 := s.Version()
+	if rows := s.ChangedRows(0); len(rows) != 4 {
+		t.Errorf("ChangedRows(0) = %v, want all 4 rows on first check", rows)
+	}
+
+	s.Write([]byte("\x1b[3;1Hchanged"))
+	rows := s.ChangedRows(v1)
+	if len(rows) != 1 || rows[0] != 2 {
+		t.Errorf("ChangedRows(v1) = %v, want [2]", rows)
+	}
+
+	v2 := s.Version()
+	if rows := s.ChangedRows(v2); len(rows) != 0 {
+		t.Errorf("ChangedRows(v2) = %v, want none", rows)
+	}
+
+	s.Write([]byte("\x1b[1;1H\x1b[31mtop\x1b[0m"))
+	rows = s.ChangedRows(v2)
+	if len(rows) != 1 || rows[0] != 0 {
+		t.Errorf("style change: ChangedRows(v2) = %v, want [0]", rows)
+	}
+}
+
+func TestScreen_Lines(t *testing.T) {
+	s := New(20, 3)
+	defer s.Close()
+
+	s.Write([]byte("\x1b[2;1Hmiddle"))
+	lines := s.Lines()
+	if len(lines) != 3 {
+		t.Fatalf("Lines() returned %d rows, want 3", len(lines))
+	}
+	if !strings.Contains(lines[1], "middle") {
+		t.Errorf("Lines()[1] = %q, want to contain middle", lines[1])
+	}
+}
+
+func TestScreenRowsAndCursor(t *testing.T) {
+	s := New(20, 4)
+	defer s.Close()
+	s.Write([]byte("top\r\n\x1b[3;5Hmid  \x1b[?25l"))
+
+	rows := s.Rows(false)
+	want := []string{"top", "", "    mid", ""}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Rows = %q, want %q", rows, want)
+	}
+	if col, row, visible := s.Cursor(); col != 9 || row != 2 || visible {
+		t.Errorf("Cursor = %d, %d, %v, want 9, 2, hidden", col, row, visible)
+	}
+
+	s.Write([]byte("\x1b[?25h"))
+	if _, _, visible := s.Cursor(); !visible {
+		t.Error("cursor should be visible after DECTCEM set")
+	}
+	if styled := s.Rows(true); len(styled) != 4 {
+		t.Errorf("styled rows = %d, want 4", len(styled))
+	}
+}
+
+// TestScreen_FrameHistoryRealCaptures feeds real recordings from the corpus
+// in small writes, so their redraw sequences land split at every position,
+// and expects the frames of writing each capture at once.
+func TestScreen_FrameHistoryRealCaptures(t *testing.T) {
+	for _, name := range []string{"vim", "less", "top"} {
+		raw, err := os.ReadFile(filepath.Join("testdata", "corpus", name+".raw"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames := func(chunk int) []string {
+			s := New(80, 24)
+			defer s.Close()
+			go s.ReadResponses(io.Discard)
+			s.SetFrameHistory(corpusFrameHistory)
+			for data := raw; len(data) > 0; {
+				n := min(chunk, len(data))
+				s.Write(data[:n])
+				data = data[n:]
+			}
+			var contents []string
+			for _, f := range s.Frames() {
+				contents = append(contents, f.Content)
+			}
+			return contents
+		}
+
+		whole := frames(len(raw))
+		if len(whole) == 0 {
+			t.Fatalf("%s: no frames", name)
+		}
+		for _, chunk := range []int{1, 2, 3, 5, 8, 13} {
+			if got := frames(chunk); !slices.Equal(got, whole) {
+				t.Errorf("%s in %d-byte writes: %d frames, want the %d of one write", name, chunk, len(got), len(whole))
+			}
+		}
+	}
+}
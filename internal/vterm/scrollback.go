@@ -0,0 +1,134 @@
+package vterm
+
+import (
+	"bytes"
+	"strings"
+)
+
+// SetScrollback sets how many rows scrolled off the top of the screen are
+// kept. Zero disables scrollback.
+func (s *Screen) SetScrollback(limit int) {
+	s.scrollMu.Lock()
+	defer s.scrollMu.Unlock()
+	s.scrollLimit = max(0, limit)
+	if len(s.scrollback) > s.scrollLimit {
+		s.scrollback = append([]string(nil), s.scrollback[len(s.scrollback)-s.scrollLimit:]...)
+	}
+}
+
+// Scrollback returns rows that scrolled off the top of the screen, oldest
+// first, as plain text.
+func (s *Screen) Scrollback() []string {
+	s.scrollMu.Lock()
+	defer s.scrollMu.Unlock()
+	return append([]string(nil), s.scrollback...)
+}
+
+func (s *Screen) scrollbackEnabled() bool {
+	s.scrollMu.Lock()
+	defer s.scrollMu.Unlock()
+	return s.scrollLimit > 0
+}
+
+// writeTracked writes p to the emulator, recording scrolled-off rows when
+// scrollback is enabled.
+//
+// The emulator has no scrollback of its own, so rows are recovered by
+// comparing the screen before and after each piece of input: when the old
+// rows reappear shifted up by k, the top k old rows scrolled off. Input is
+// fed in pieces of at most half a screen of newlines so a shift is always
+// observable. Newline runs are written separately from the text between
+// them so that a row is complete before it scrolls away.
+func (s *Screen) writeTracked(p []byte) (int, error) {
+	if !s.scrollbackEnabled() {
+		return s.emu.Write(p)
+	}
+
+	step := max(1, s.emu.Height()/2)
+	written := 0
+	for written < len(p) {
+		end := written + nextPiece(p[written:], step)
+		before := s.plainLines()
+		n, err := s.emu.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if k := detectScroll(before, s.plainLines()); k > 0 {
+			s.appendScrollback(before[:k])
+		}
+	}
+	return written, nil
+}
+
+func (s *Screen) appendScrollback(rows []string) {
+	s.scrollMu.Lock()
+	defer s.scrollMu.Unlock()
+	for _, row := range rows {
+		s.scrollback = append(s.scrollback, strings.TrimRight(row, " "))
+	}
+	if len(s.scrollback) > s.scrollLimit {
+		s.scrollback = s.scrollback[len(s.scrollback)-s.scrollLimit:]
+	}
+}
+
+func (s *Screen) plainLines() []string {
+	out := strings.ReplaceAll(s.emu.String(), "\r\n", "\n")
+	lines := strings.Split(out, "\n")
+	if h := s.emu.Height(); len(lines) < h {
+		lines = append(lines, make([]string, h-len(lines))...)
+	}
+	return lines
+}
+
+// nextPiece returns the length of the next piece of p to write: either a
+// run of line breaks holding at most n newlines, or the text up to the next
+// newline.
+func nextPiece(p []byte, n int) int {
+	if p[0] != '\n' {
+		if i := bytes.IndexByte(p, '\n'); i > 0 {
+			return i
+		}
+		return len(p)
+	}
+	newlines := 0
+	for i, c := range p {
+		switch c {
+		case '\n':
+			if newlines == n {
+				return i
+			}
+			newlines++
+		case '\r':
+		default:
+			return i
+		}
+	}
+	return len(p)
+}
+
+// detectScroll returns how many rows the screen scrolled up between before
+// and after, or 0 if no shift explains the change better than none.
+func detectScroll(before, after []string) int {
+	h := min(len(before), len(after))
+	bestK, bestMatches := 0, -1
+	for k := 0; k < h; k++ {
+		matches, compared := 0, 0
+		for i := 0; i+k < h; i++ {
+			if strings.TrimSpace(before[i+k]) == "" {
+				continue
+			}
+			compared++
+			if before[i+k] == after[i] {
+				matches++
+			}
+		}
+		if compared == 0 || matches*2 <= compared {
+			continue
+		}
+		if matches > bestMatches {
+			bestK, bestMatches = k, matches
+		}
+	}
+	return bestK
+}
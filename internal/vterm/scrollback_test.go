@@ -0,0 +1,97 @@
+package vterm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestScreen_ScrollbackCapturesScrolledRows(t *testing.T) {
+	s := New(20, 4)
+	defer s.Close()
+	s.SetScrollback(100)
+
+	for i := 1; i <= 10; i++ {
+		s.Write([]byte(fmt.Sprintf("line %d\r\n", i)))
+	}
+
+	sb := s.Scrollback()
+	if len(sb) == 0 {
+		t.Fatal("expected scrollback rows")
+	}
+	if sb[0] != "line 1" {
+		t.Errorf("first scrollback row = %q, want %q", sb[0], "line 1")
+	}
+	joined := strings.Join(sb, "\n") + "\n" + s.String()
+	for i := 1; i <= 10; i++ {
+		if !strings.Contains(joined, fmt.Sprintf("line %d", i)) {
+			t.Errorf("line %d missing from scrollback+screen: %q", i, joined)
+		}
+	}
+}
+
+func TestScreen_ScrollbackSingleLargeWrite(t *testing.T) {
+	s := New(20, 4)
+	defer s.Close()
+	s.SetScrollback(100)
+
+	var b strings.Builder
+	for i := 1; i <= 12; i++ {
+		fmt.Fprintf(&b, "row %02d\r\n", i)
+	}
+	s.Write([]byte(b.String()))
+
+	joined := strings.Join(s.Scrollback(), "\n") + "\n" + s.String()
+	for i := 1; i <= 12; i++ {
+		if !strings.Contains(joined, fmt.Sprintf("row %02d", i)) {
+			t.Errorf("row %02d missing: %q", i, joined)
+		}
+	}
+}
+
+func TestScreen_ScrollbackLimit(t *testing.T) {
+	s := New(20, 2)
+	defer s.Close()
+	s.SetScrollback(3)
+
+	for i := 1; i <= 10; i++ {
+		s.Write([]byte(fmt.Sprintf("n%d\r\n", i)))
+	}
+	if sb := s.Scrollback(); len(sb) != 3 {
+		t.Errorf("scrollback len = %d (%q), want 3", len(sb), sb)
+	}
+}
+
+func TestScreen_ScrollbackDisabled(t *testing.T) {
+	s := New(20, 2)
+	defer s.Close()
+
+	for i := 1; i <= 5; i++ {
+		s.Write([]byte(fmt.Sprintf("n%d\r\n", i)))
+	}
+	if sb := s.Scrollback(); len(sb) != 0 {
+		t.Errorf("scrollback = %q with scrollback disabled, want empty", sb)
+	}
+}
+
+func TestDetectScroll(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []string
+		after  []string
+		want   int
+	}{
+		{"unchanged", []string{"a", "b", "c"}, []string{"a", "b", "c"}, 0},
+		{"scroll one", []string{"a", "b", "c"}, []string{"b", "c", "d"}, 1},
+		{"scroll two", []string{"a", "b", "c", "d"}, []string{"c", "d", "e", "f"}, 2},
+		{"redraw", []string{"a", "b", "c"}, []string{"x", "y", "z"}, 0},
+		{"append below", []string{"a", "", ""}, []string{"a", "b", ""}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectScroll(tt.before, tt.after); got != tt.want {
+				t.Errorf("detectScroll() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
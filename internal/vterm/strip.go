@@ -9,7 +9,7 @@ import (
 )
 
 var ansiPatterns = []*regexp.Regexp{
-	regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z~]`),            // CSI sequences (colors, cursor, etc)
+	regexp.MustCompile(`\x1b\[[0-9;:]*[A-Za-z~]`),           // CSI sequences (colors, cursor, etc)
 	regexp.MustCompile(`\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`), // OSC sequences
 	regexp.MustCompile(`\x1b[()][AB012]`),                    // Character set selection
 	regexp.MustCompile(`\x1b[=>]`),                           // Keypad modes
@@ -20,12 +20,28 @@ var ansiPatterns = []*regexp.Regexp{
 
 var cursorAnyPattern = regexp.MustCompile(`\x1b\[\d*;?\d*[HFfGdABCD]`)
 
+// tabStopPattern matches HTS (set tab stop) and TBC (clear tab stops). Output
+// that redefines tab stops needs the emulator to place tabs correctly.
+var tabStopPattern = regexp.MustCompile(`\x1bH|\x1b\[\d*g`)
+
+// scrollPattern matches DECSTBM (set scrolling region) and SU/SD (scroll
+// up/down). Pagers and status-bar TUIs scroll within a region, which only the
+// emulator can reproduce.
+var scrollPattern = regexp.MustCompile(`\x1b\[\d*(?:;\d*)?r|\x1b\[\d*[ST]`)
+
+// editPattern matches IL/DL (insert/delete line) and ICH/DCH (insert/delete
+// character), used by editors to patch the screen in place.
+var editPattern = regexp.MustCompile(`\x1b\[\d*[LMP@]`)
+
 // loneNewline matches \n not preceded by \r (standalone line feeds).
 var loneNewline = regexp.MustCompile(`(?:^|[^\r])\n`)
 
-// Strip removes ANSI escape sequences from s. When cursor positioning sequences
-// are detected, a temporary VT emulator is used for correct rendering. Otherwise,
+// Strip removes ANSI escape sequences from s. When cursor positioning or other
+// screen-editing sequences are detected, a temporary VT emulator is used for correct rendering. Otherwise,
 // a fast regex-based strip is used.
+//
+// The emulator measures grapheme clusters by display width, so wide runes
+// (CJK, emoji) take two cells and cursor-addressed columns stay aligned.
 func Strip(s string, cols int) string {
 	if s == "" {
 		return ""
@@ -34,14 +50,60 @@ func Strip(s string, cols int) string {
 		cols = 200
 	}
 
-	if !cursorAnyPattern.MatchString(s) {
+	if !needsEmulator(s) {
 		result := s
 		for _, re := range ansiPatterns {
 			result = re.ReplaceAllString(result, "")
 		}
 		return result
 	}
+	return emulate(s, cols)
+}
+
+// renderChunkLines bounds how many lines Render feeds one emulator, so that
+// long output does not scroll off its screen.
+const renderChunkLines = 1000
 
+// Render returns the text s leaves on a terminal cols wide: carriage-return
+// overwrites, backspaces, and cursor movement are applied, not just dropped
+// as with Strip. Long input is rendered in chunks of lines.
+func Render(s string, cols int) string {
+	if s == "" {
+		return ""
+	}
+	if cols <= 0 {
+		cols = 200
+	}
+
+	var b strings.Builder
+	for s != "" {
+		end := len(s)
+		for i, from := 0, 0; i < renderChunkLines; i++ {
+			j := strings.IndexByte(s[from:], '\n')
+			if j < 0 {
+				break
+			}
+			from += j + 1
+			if i == renderChunkLines-1 {
+				end = from
+			}
+		}
+		chunk := s[:end]
+		s = s[end:]
+
+		out := emulate(chunk, cols)
+		b.WriteString(out)
+		// The emulator drops trailing blank lines; keep the line break that
+		// ended the chunk.
+		if strings.HasSuffix(chunk, "\n") && !strings.HasSuffix(out, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// emulate renders s on a temporary VT emulator and returns the plain text.
+func emulate(s string, cols int) string {
 	rows := strings.Count(s, "\n") + 100
 	if rows > 5000 {
 		rows = 5000
@@ -49,7 +111,7 @@ func Strip(s string, cols int) string {
 
 	// VT emulator treats \n as line-feed-only (no carriage return).
 	// Real terminals with ONLCR convert \n to \r\n. Pre-process to match.
-	input := normalizeNewlines(s)
+	input := NormalizeNewlines(s)
 
 	emu := vt.NewEmulator(cols, rows)
 
@@ -77,14 +139,23 @@ func Strip(s string, cols int) string {
 	return trimTrailingEmptyLines(result)
 }
 
+// needsEmulator reports whether s contains sequences that the regex strip
+// cannot render faithfully.
+func needsEmulator(s string) bool {
+	return cursorAnyPattern.MatchString(s) ||
+		tabStopPattern.MatchString(s) ||
+		scrollPattern.MatchString(s) ||
+		editPattern.MatchString(s)
+}
+
 // StripDefault strips ANSI with a default column width of 200.
 func StripDefault(s string) string {
 	return Strip(s, 200)
 }
 
-// normalizeNewlines converts standalone \n (not preceded by \r) to \r\n,
+// NormalizeNewlines converts standalone \n (not preceded by \r) to \r\n,
 // matching what a real terminal driver does with ONLCR.
-func normalizeNewlines(s string) string {
+func NormalizeNewlines(s string) string {
 	// Fast path: no standalone \n
 	if !loneNewline.MatchString(s) {
 		return s
@@ -1,6 +1,9 @@
 package vterm
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestStrip(t *testing.T) {
 	tests := []struct {
@@ -344,6 +347,56 @@ func TestStrip(t *testing.T) {
 			input:    "\x1b[1;1H\x1b[15~hello",
 			expected: "hello",
 		},
+		{
+			name:     "wide CJK cells keep columns aligned",
+			input:    "\x1b[1;1H名前\x1b[1;8Hok\x1b[2;1Hname\x1b[2;8Hok",
+			expected: "名前   ok\nname   ok",
+		},
+		{
+			name:     "tabs expand to 8-column stops under cursor positioning",
+			input:    "\x1b[1;1Ha\tb\tc\x1b[2;1Hlong1\tz",
+			expected: "a       b       c\nlong1   z",
+		},
+		{
+			name:     "custom tab stops from TBC and HTS",
+			input:    "\x1b[3gabc\x1bH\r\nx\ty\r\nxy\tz",
+			expected: "abc\nx  y\nxy z",
+		},
+		{
+			name:     "newline at bottom of scrolling region scrolls only the region",
+			input:    "line1\nline2\nline3\nline4\x1b[2;3r\n\n\nnew",
+			expected: "line1\nline3\nnew\nline4",
+		},
+		{
+			name:     "scroll up within region",
+			input:    "\x1b[1;1Htop\r\nb1\r\nb2\r\nstatus\x1b[2;3r\x1b[S",
+			expected: "top\nb2\n\nstatus",
+		},
+		{
+			name:     "insert line pushes rows down",
+			input:    "line1\nline2\x1b[L",
+			expected: "line1\n\nline2",
+		},
+		{
+			name:     "delete line pulls rows up",
+			input:    "line1\nline2\nline3\x1b[1A\x1b[M",
+			expected: "line1\nline3",
+		},
+		{
+			name:     "delete characters shifts rest of line left",
+			input:    "abcdef\r\x1b[2P",
+			expected: "cdef",
+		},
+		{
+			name:     "insert characters shifts rest of line right",
+			input:    "abcdef\r\x1b[2@XY",
+			expected: "XYabcdef",
+		},
+		{
+			name:     "emoji occupies two cells",
+			input:    "\x1b[1;1H😀\x1b[1;4Hx\x1b[2;1Hab\x1b[2;4Hx",
+			expected: "😀 x\nab x",
+		},
 	}
 
 	for _, tt := range tests {
@@ -372,3 +425,69 @@ func TestStripDefaultCols(t *testing.T) {
 		t.Errorf("Strip with cols=-1: got %q, want %q", got, "hello")
 	}
 }
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		cols     int
+		expected string
+	}{
+		{
+			name:     "carriage return overwrite",
+			input:    "progress 10%\rprogress 99%\n",
+			cols:     80,
+			expected: "progress 99%\n",
+		},
+		{
+			name:     "partial overwrite keeps tail",
+			input:    "downloading...\rdone\n",
+			cols:     80,
+			expected: "doneloading...\n",
+		},
+		{
+			name:     "backspace",
+			input:    "abc\b\bX\n",
+			cols:     80,
+			expected: "aXc\n",
+		},
+		{
+			name:     "colors dropped",
+			input:    "\x1b[32mok\x1b[0m\n",
+			cols:     80,
+			expected: "ok\n",
+		},
+		{
+			name:     "wraps at width",
+			input:    "abcdef\n",
+			cols:     4,
+			expected: "abcd\nef\n",
+		},
+		{
+			name:     "no trailing newline",
+			input:    "a\rb",
+			cols:     80,
+			expected: "b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.input, tt.cols); got != tt.expected {
+				t.Errorf("Render(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderChunks(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < renderChunkLines*2+5; i++ {
+		b.WriteString("50%\r100%\n")
+	}
+	got := Render(b.String(), 80)
+	want := strings.Repeat("100%\n", renderChunkLines*2+5)
+	if got != want {
+		t.Errorf("Render over chunks: got %d bytes, want %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,94 @@
+package wait
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/schovi/shelli/internal/vterm"
+)
+
+// PromptTailBytes is how much of the end of the output prompt detection
+// looks at, so waits on large outputs stay cheap.
+const PromptTailBytes = 4096
+
+// Prompt is a built-in pattern for an interactive prompt. Patterns match the
+// whole line the program is waiting on, trailing space included.
+type Prompt struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Prompts are the prompts DetectPrompt knows, most specific first.
+var Prompts = []Prompt{
+	{"python", regexp.MustCompile(`^(>>>|\.\.\.) $`)},
+	{"pdb", regexp.MustCompile(`^\(Pdb\) $`)},
+	{"ipdb", regexp.MustCompile(`^ipdb> $`)},
+	{"psql", regexp.MustCompile(`^[\w.-]+[=\-(*'"$][#>] $`)},
+	{"node", regexp.MustCompile(`^> $`)},
+	{"shell", regexp.MustCompile(`[$#%❯] $`)},
+}
+
+// CursorFunc returns the terminal line the cursor is on and the cursor's
+// 0-based column.
+type CursorFunc func() (line string, col int, err error)
+
+// DetectPrompt reports which prompt the output ends in. The last line must
+// be unterminated, as the cursor sits at the end of a prompt; carriage
+// returns and ANSI sequences are applied or dropped first.
+func DetectPrompt(output string) (name string, ok bool) {
+	if len(output) > PromptTailBytes {
+		output = output[len(output)-PromptTailBytes:]
+	}
+	line := output[strings.LastIndexByte(output, '\n')+1:]
+	line = line[strings.LastIndexByte(line, '\r')+1:]
+	return matchPrompt(vterm.StripDefault(line))
+}
+
+// PromptAtCursor reports which prompt the terminal line ends in when the
+// cursor sits right after it, with nothing but blanks beyond. Screens trim
+// trailing blanks, so the line is padded out to the cursor.
+func PromptAtCursor(line string, col int) (name string, ok bool) {
+	runes := []rune(line)
+	if col < 0 {
+		return "", false
+	}
+	if col > len(runes) {
+		runes = append(runes, []rune(strings.Repeat(" ", col-len(runes)))...)
+	}
+	if strings.TrimSpace(string(runes[col:])) != "" {
+		return "", false
+	}
+	return matchPrompt(string(runes[:col]))
+}
+
+func matchPrompt(line string) (string, bool) {
+	if strings.TrimSpace(line) == "" {
+		return "", false
+	}
+	for _, p := range Prompts {
+		if p.Pattern.MatchString(line) {
+			return p.Name, true
+		}
+	}
+	return "", false
+}
+
+// promptSeen reports whether the wait for a prompt is over. Stream output
+// must end in one; with a CursorFunc the terminal must agree that the cursor
+// sits right after it. Full-screen output is judged by the cursor alone.
+func (cfg Config) promptSeen(output string) (bool, error) {
+	if !cfg.FullOutput || cfg.CursorFunc == nil {
+		if _, ok := DetectPrompt(output); !ok {
+			return false, nil
+		}
+		if cfg.CursorFunc == nil {
+			return true, nil
+		}
+	}
+	line, col, err := cfg.CursorFunc()
+	if err != nil {
+		return false, err
+	}
+	_, ok := PromptAtCursor(line, col)
+	return ok, nil
+}
@@ -0,0 +1,105 @@
+package wait
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectPrompt(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"ls\r\nfile\r\nuser@host:~$ ", "shell"},
+		{"\x1b[01;32muser\x1b[00m:\x1b[01;34m~\x1b[00m$ ", "shell"},
+		{"root@box:/# ", "shell"},
+		{"%                    \r \r~ ❯ ", "shell"},
+		{"print(1)\r\n1\r\n>>> ", "python"},
+		{"def f():\r\n... ", "python"},
+		{"> /tmp/x.py(3)<module>()\r\n-> x = 1\r\n(Pdb) ", "pdb"},
+		{"ipdb> ", "ipdb"},
+		{"SELECT 1;\r\n(1 row)\r\n\r\npostgres=# ", "psql"},
+		{"app_db-> ", "psql"},
+		{"1 + 1\r\n2\r\n> ", "node"},
+		{"user@host:~$ ls\r\n", ""}, // the prompt scrolled away
+		{"Progress: 50%", ""},       // no trailing space
+		{"Continue? [y/N] ", ""},    // not a known prompt
+		{strings.Repeat("x", 10000) + "\r\n$ ", "shell"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := DetectPrompt(tt.output)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("DetectPrompt(%q) = %q, %v, want %q", tt.output, got, ok, tt.want)
+		}
+	}
+}
+
+func TestPromptAtCursor(t *testing.T) {
+	tests := []struct {
+		line string
+		col  int
+		want string
+	}{
+		{"user@host:~$", 13, "shell"}, // trailing blank trimmed by the screen
+		{">>>", 4, "python"},
+		{"user@host:~$ ls", 13, ""}, // typed input after the prompt
+		{"user@host:~$", 3, ""},
+		{"", 0, ""},
+	}
+	for _, tt := range tests {
+		got, ok := PromptAtCursor(tt.line, tt.col)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("PromptAtCursor(%q, %d) = %q, %v, want %q", tt.line, tt.col, got, ok, tt.want)
+		}
+	}
+}
+
+func TestForOutput_WaitForPrompt(t *testing.T) {
+	chunks := []string{"make\r\n", "building...\r\n", "done\r\nuser@host:~$ "}
+	calls := 0
+	readFn := func() (string, int, error) {
+		out := strings.Join(chunks[:min(calls+1, len(chunks))], "")
+		calls++
+		return out, len(out), nil
+	}
+
+	got, _, err := ForOutput(readFn, Config{
+		WaitForPrompt: true,
+		TimeoutSec:    2,
+		PollInterval:  10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(got, "$ ") || calls != 3 {
+		t.Errorf("got %q after %d reads, want the prompt after 3", got, calls)
+	}
+}
+
+func TestForOutput_WaitForPromptCursor(t *testing.T) {
+	readFn := func() (string, int, error) {
+		return "done\r\n$ ", 9, nil
+	}
+	cursorCol := 5 // the terminal still has the cursor elsewhere
+	cursorFn := func() (string, int, error) {
+		return "$", cursorCol, nil
+	}
+
+	cfg := Config{
+		WaitForPrompt: true,
+		CursorFunc:    cursorFn,
+		TimeoutSec:    1,
+		PollInterval:  10 * time.Millisecond,
+	}
+	_, _, err := ForOutput(readFn, cfg)
+	if err == nil || !strings.Contains(err.Error(), "waiting for a prompt") {
+		t.Fatalf("expected prompt timeout, got %v", err)
+	}
+
+	cursorCol = 2
+	if _, _, err := ForOutput(readFn, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package wait
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"time"
@@ -8,6 +9,9 @@ import (
 
 const DefaultPollInterval = 50 * time.Millisecond
 
+// ErrTimeout is wrapped by the error ForOutput returns when it gives up.
+var ErrTimeout = errors.New("timeout")
+
 type ReadFunc func() (output string, position int, err error)
 type SizeFunc func() (int, error)
 
@@ -19,6 +23,14 @@ type Config struct {
 	PollInterval  time.Duration
 	SizeFunc      SizeFunc
 	FullOutput    bool // When true, treat output as full content (TUI mode)
+	// MatchFilter, when set, is applied to the output before Pattern is
+	// matched, e.g. to hide text the program did not print.
+	MatchFilter func(string) string
+	// WaitForPrompt ends the wait once the output ends in an interactive
+	// prompt (see Prompts), checked against the terminal's cursor when
+	// CursorFunc is set.
+	WaitForPrompt bool
+	CursorFunc    CursorFunc
 }
 
 func ForOutput(readFn ReadFunc, cfg Config) (string, int, error) {
@@ -46,7 +58,8 @@ func ForOutput(readFn ReadFunc, cfg Config) (string, int, error) {
 	for time.Now().Before(deadline) {
 		if cfg.SizeFunc != nil {
 			size, sizeErr := cfg.SizeFunc()
-			if sizeErr == nil && size == lastPos {
+			settled := cfg.SettleMs > 0 && time.Since(lastChangeTime) >= settleDuration
+			if sizeErr == nil && size == lastPos && !settled {
 				time.Sleep(pollInterval)
 				continue
 			}
@@ -79,10 +92,24 @@ func ForOutput(readFn ReadFunc, cfg Config) (string, int, error) {
 			}
 		}
 
-		if re != nil && re.MatchString(newOutput) {
+		matchOutput := newOutput
+		if cfg.MatchFilter != nil {
+			matchOutput = cfg.MatchFilter(newOutput)
+		}
+		if re != nil && re.MatchString(matchOutput) {
 			return newOutput, pos, nil
 		}
 
+		if cfg.WaitForPrompt && pos > cfg.StartPosition {
+			seen, err := cfg.promptSeen(matchOutput)
+			if err != nil {
+				return "", 0, err
+			}
+			if seen {
+				return newOutput, pos, nil
+			}
+		}
+
 		if cfg.SettleMs > 0 && pos > cfg.StartPosition && time.Since(lastChangeTime) >= settleDuration {
 			return newOutput, pos, nil
 		}
@@ -108,7 +135,10 @@ func ForOutput(readFn ReadFunc, cfg Config) (string, int, error) {
 	}
 
 	if re != nil {
-		return newOutput, pos, fmt.Errorf("timeout waiting for pattern %q", cfg.Pattern)
+		return newOutput, pos, fmt.Errorf("%w waiting for pattern %q", ErrTimeout, cfg.Pattern)
+	}
+	if cfg.WaitForPrompt {
+		return newOutput, pos, fmt.Errorf("%w waiting for a prompt", ErrTimeout)
 	}
-	return newOutput, pos, fmt.Errorf("timeout waiting for output to settle")
+	return newOutput, pos, fmt.Errorf("%w waiting for output to settle", ErrTimeout)
 }
@@ -267,3 +267,30 @@ func TestForOutput_SizeFunc_SkipsReadWhenUnchanged(t *testing.T) {
 		t.Errorf("expected readFn called <=2 times, got %d", readCount)
 	}
 }
+
+func TestForOutput_SizeFunc_Settles(t *testing.T) {
+	readFn := func() (string, int, error) {
+		return "done\n", 5, nil
+	}
+	sizeFunc := func() (int, error) {
+		return 5, nil
+	}
+	cfg := Config{
+		SettleMs:      50,
+		TimeoutSec:    2,
+		StartPosition: 0,
+		PollInterval:  10 * time.Millisecond,
+		SizeFunc:      sizeFunc,
+	}
+	start := time.Now()
+	output, _, err := ForOutput(readFn, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "done\n" {
+		t.Errorf("output = %q", output)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("settle took %v, want about 50ms", elapsed)
+	}
+}